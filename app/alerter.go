@@ -0,0 +1,388 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+// Notifier types
+const (
+	WebhookNotifier = "webhook"
+	SlackNotifier   = "slack"
+)
+
+// InboundInternetPortField is a condition field matched against the ports
+// the internet connects to a node on, rather than its metadata or metrics.
+const InboundInternetPortField = "inbound_internet_port"
+
+const notifyTimeout = 10 * time.Second
+
+// Rule is a user-defined alert. It fires for every node of the topology
+// which starts matching all of its conditions, e.g.
+//
+//	{"name": "sshd exposed", "topology": "containers",
+//	 "conditions": [{"field": "inbound_internet_port", "op": "==", "value": "22"}],
+//	 "notifier": {"type": "slack", "url": "https://hooks.slack.com/services/..."}}
+type Rule struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	Topology   string      `json:"topology"`
+	Conditions []Condition `json:"conditions"`
+	Notifier   Notifier    `json:"notifier"`
+}
+
+// Condition compares a metadata row or metric of a node, by ID, with a
+// value. Values are compared as numbers when both sides are.
+type Condition struct {
+	Field string `json:"field"`
+	Op    string `json:"op"` // one of ==, !=, <, <=, >, >=
+	Value string `json:"value"`
+}
+
+// Notifier is where the notifications of a rule are sent.
+type Notifier struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// Notification is posted to webhooks when a rule fires.
+type Notification struct {
+	RuleID    string    `json:"rule_id"`
+	Rule      string    `json:"rule"`
+	Topology  string    `json:"topology"`
+	NodeID    string    `json:"node_id"`
+	Label     string    `json:"label"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+var conditionOps = map[string]func(c int) bool{
+	"==": func(c int) bool { return c == 0 },
+	"!=": func(c int) bool { return c != 0 },
+	"<":  func(c int) bool { return c < 0 },
+	"<=": func(c int) bool { return c <= 0 },
+	">":  func(c int) bool { return c > 0 },
+	">=": func(c int) bool { return c >= 0 },
+}
+
+// validate checks a rule can be evaluated, against the topologies of the
+// given registry.
+func (rule Rule) validate(registry *Registry) error {
+	if _, ok := registry.get(rule.Topology); !ok {
+		return fmt.Errorf("unknown topology: %q", rule.Topology)
+	}
+	if len(rule.Conditions) == 0 {
+		return fmt.Errorf("rule has no conditions")
+	}
+	for _, c := range rule.Conditions {
+		if _, ok := conditionOps[c.Op]; !ok {
+			return fmt.Errorf("invalid operator: %q", c.Op)
+		}
+	}
+	if rule.Notifier.Type != WebhookNotifier && rule.Notifier.Type != SlackNotifier {
+		return fmt.Errorf("invalid notifier type: %q", rule.Notifier.Type)
+	}
+	if rule.Notifier.URL == "" {
+		return fmt.Errorf("notifier has no URL")
+	}
+	return nil
+}
+
+func (c Condition) matches(value string) bool {
+	cmp := strings.Compare(value, c.Value)
+	if a, err := strconv.ParseFloat(value, 64); err == nil {
+		if b, err := strconv.ParseFloat(c.Value, 64); err == nil {
+			switch {
+			case a < b:
+				cmp = -1
+			case a > b:
+				cmp = 1
+			default:
+				cmp = 0
+			}
+		}
+	}
+	return conditionOps[c.Op](cmp)
+}
+
+// Alerter periodically evaluates rules against the rendered topologies of
+// a Reporter, and notifies about the nodes which start matching them.
+type Alerter struct {
+	reporter Reporter
+	registry *Registry
+	client   *http.Client
+	quit     chan struct{}
+
+	mtx    sync.Mutex
+	nextID int
+	rules  map[string]Rule
+	firing map[string]map[string]struct{} // rule ID -> node IDs
+}
+
+// NewAlerter makes a new Alerter, without any rules. Rules are only kept
+// in memory.
+func NewAlerter(rep Reporter) *Alerter {
+	return &Alerter{
+		reporter: rep,
+		registry: topologyRegistry,
+		client:   &http.Client{Timeout: notifyTimeout},
+		quit:     make(chan struct{}),
+		rules:    map[string]Rule{},
+		firing:   map[string]map[string]struct{}{},
+	}
+}
+
+// Start evaluates the rules every interval, until stopped.
+func (a *Alerter) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				a.Evaluate(context.Background(), time.Now())
+			case <-a.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops evaluating the rules.
+func (a *Alerter) Stop() {
+	close(a.quit)
+}
+
+// Rules returns all rules.
+func (a *Alerter) Rules() []Rule {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	result := make([]Rule, 0, len(a.rules))
+	for i := 1; i <= a.nextID; i++ {
+		if rule, ok := a.rules[strconv.Itoa(i)]; ok {
+			result = append(result, rule)
+		}
+	}
+	return result
+}
+
+// Rule returns the rule with the given ID.
+func (a *Alerter) Rule(id string) (Rule, bool) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	rule, ok := a.rules[id]
+	return rule, ok
+}
+
+// AddRule adds a rule, returning it with its newly assigned ID.
+func (a *Alerter) AddRule(rule Rule) (Rule, error) {
+	if err := rule.validate(a.registry); err != nil {
+		return rule, err
+	}
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.nextID++
+	rule.ID = strconv.Itoa(a.nextID)
+	a.rules[rule.ID] = rule
+	return rule, nil
+}
+
+// UpdateRule replaces the rule with the same ID. Nodes matching the new
+// rule are notified about again.
+func (a *Alerter) UpdateRule(rule Rule) (bool, error) {
+	if err := rule.validate(a.registry); err != nil {
+		return false, err
+	}
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if _, ok := a.rules[rule.ID]; !ok {
+		return false, nil
+	}
+	a.rules[rule.ID] = rule
+	delete(a.firing, rule.ID)
+	return true, nil
+}
+
+// DeleteRule deletes the rule with the given ID.
+func (a *Alerter) DeleteRule(id string) bool {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	_, ok := a.rules[id]
+	delete(a.rules, id)
+	delete(a.firing, id)
+	return ok
+}
+
+// Evaluate evaluates all rules against the report at the given time, and
+// sends notifications for the nodes which didn't match them last time.
+func (a *Alerter) Evaluate(ctx context.Context, timestamp time.Time) {
+	rules := a.Rules()
+	if len(rules) == 0 {
+		return
+	}
+	rpt, err := a.reporter.Report(ctx, timestamp)
+	if err != nil {
+		log.Errorf("Error getting report for alerts: %v", err)
+		return
+	}
+	rc := RenderContextForReporter(a.reporter, rpt)
+	for _, rule := range rules {
+		matching, err := a.matchingNodes(rc, rule)
+		if err != nil {
+			log.Errorf("Error evaluating rule %s: %v", rule.ID, err)
+			continue
+		}
+		for _, n := range a.update(rule, matching) {
+			a.notify(rule, Notification{
+				RuleID:    rule.ID,
+				Rule:      rule.Name,
+				Topology:  rule.Topology,
+				NodeID:    n.ID,
+				Label:     n.Label,
+				Timestamp: timestamp,
+			})
+		}
+	}
+}
+
+// update records the nodes matching a rule, returning those which didn't
+// match it before.
+func (a *Alerter) update(rule Rule, matching []detailed.NodeSummary) []detailed.NodeSummary {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if _, ok := a.rules[rule.ID]; !ok {
+		return nil
+	}
+	var (
+		previous = a.firing[rule.ID]
+		firing   = map[string]struct{}{}
+		result   []detailed.NodeSummary
+	)
+	for _, n := range matching {
+		firing[n.ID] = struct{}{}
+		if _, ok := previous[n.ID]; !ok {
+			result = append(result, n)
+		}
+	}
+	a.firing[rule.ID] = firing
+	return result
+}
+
+func (a *Alerter) matchingNodes(rc detailed.RenderContext, rule Rule) ([]detailed.NodeSummary, error) {
+	renderer, filter, err := a.registry.RendererForTopology(rule.Topology, nil, rc.Report)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		nodes  = render.Render(rc.Report, renderer, filter).Nodes
+		result []detailed.NodeSummary
+	)
+	for _, id := range sortedIDs(nodes) {
+		summary, ok := detailed.MakeNodeSummary(rc, nodes[id])
+		if !ok || summary.Pseudo {
+			continue
+		}
+		values := nodeValues(summary)
+		matches := true
+		for _, c := range rule.Conditions {
+			if c.Field == InboundInternetPortField {
+				matches = inboundInternetPortMatches(rc, rule.Topology, nodes, nodes[id], c)
+			} else {
+				value, ok := values[c.Field]
+				matches = ok && c.matches(value)
+			}
+			if !matches {
+				break
+			}
+		}
+		if matches {
+			result = append(result, summary)
+		}
+	}
+	return result, nil
+}
+
+func sortedIDs(nodes report.Nodes) []string {
+	ids := make(report.IDList, 0, len(nodes))
+	for id := range nodes {
+		ids = ids.Add(id)
+	}
+	return ids
+}
+
+// nodeValues gathers the metadata and metrics of a node summary by ID.
+func nodeValues(summary detailed.NodeSummary) map[string]string {
+	values := map[string]string{}
+	for _, row := range summary.Metadata {
+		values[row.ID] = row.Value
+	}
+	for _, row := range summary.Metrics {
+		if !row.ValueEmpty {
+			values[row.ID] = strconv.FormatFloat(row.Value, 'f', -1, 64)
+		}
+	}
+	return values
+}
+
+// inboundInternetPortMatches tells whether the internet connects to the
+// node on any port matching the condition.
+func inboundInternetPortMatches(rc detailed.RenderContext, topologyID string, nodes report.Nodes, n report.Node, c Condition) bool {
+	incomingInternet, ok := nodes[render.IncomingInternetID]
+	if !ok || !incomingInternet.Adjacency.Contains(n.ID) {
+		return false
+	}
+	for _, summary := range detailed.ConnectionsSummaries(topologyID, rc, nodes, n) {
+		for _, conn := range summary.Connections {
+			if conn.NodeID != render.IncomingInternetID {
+				continue
+			}
+			for _, row := range conn.Metadata {
+				if row.ID == "port" && c.matches(row.Value) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (a *Alerter) notify(rule Rule, n Notification) {
+	var body interface{} = n
+	if rule.Notifier.Type == SlackNotifier {
+		body = map[string]string{
+			"text": fmt.Sprintf("Scope alert %q: %s (%s) in %s", n.Rule, n.Label, n.NodeID, n.Topology),
+		}
+	}
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, &codec.JsonHandle{}).Encode(body); err != nil {
+		log.Errorf("Error encoding notification: %v", err)
+		return
+	}
+	req, err := http.NewRequest("POST", rule.Notifier.URL, &buf)
+	if err != nil {
+		log.Errorf("Error notifying about rule %s: %v", rule.ID, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		log.Errorf("Error notifying about rule %s: %v", rule.ID, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Errorf("Error notifying about rule %s: %s", rule.ID, resp.Status)
+	}
+}
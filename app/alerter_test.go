@@ -0,0 +1,117 @@
+package app_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestAlerter(t *testing.T) {
+	notifications := make(chan app.Notification, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n app.Notification
+		if err := codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&n); err != nil {
+			t.Error(err)
+		}
+		notifications <- n
+	}))
+	defer hook.Close()
+
+	var (
+		ctx      = context.Background()
+		now      = time.Now()
+		a        = app.NewAlerter(app.StaticCollector(fixture.Report))
+		notifier = app.Notifier{Type: app.WebhookNotifier, URL: hook.URL}
+	)
+	if _, err := a.AddRule(app.Rule{Name: "bad", Topology: "foobar", Notifier: notifier}); err == nil {
+		t.Error("expected an error for an unknown topology")
+	}
+	for _, rule := range []app.Rule{
+		{
+			Name:       "server",
+			Topology:   "containers",
+			Conditions: []app.Condition{{Field: docker.ContainerID, Op: "==", Value: fixture.ServerContainerID}},
+			Notifier:   notifier,
+		},
+		{
+			Name:       "busy",
+			Topology:   "containers",
+			Conditions: []app.Condition{{Field: docker.CPUTotalUsage, Op: ">", Value: "1000"}},
+			Notifier:   notifier,
+		},
+		{
+			Name:       "exposed",
+			Topology:   "containers",
+			Conditions: []app.Condition{{Field: app.InboundInternetPortField, Op: "==", Value: fixture.ServerPort}},
+			Notifier:   notifier,
+		},
+	} {
+		if _, err := a.AddRule(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a.Evaluate(ctx, now)
+	have := map[string]string{}
+	for len(notifications) > 0 {
+		n := <-notifications
+		have[n.Rule] = n.NodeID
+	}
+	want := map[string]string{
+		"server":  fixture.ServerContainerNodeID,
+		"exposed": fixture.ServerContainerNodeID,
+	}
+	equals(t, want, have)
+
+	// Nodes still matching aren't notified about again.
+	a.Evaluate(ctx, now)
+	equals(t, 0, len(notifications))
+}
+
+func TestAlertRoutes(t *testing.T) {
+	router := mux.NewRouter()
+	app.RegisterAlertRoutes(router, app.NewAlerter(app.StaticCollector(fixture.Report)))
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	rule := app.Rule{
+		Name:       "pseudo",
+		Topology:   "containers",
+		Conditions: []app.Condition{{Field: docker.ContainerID, Op: "==", Value: render.IncomingInternetID}},
+		Notifier:   app.Notifier{Type: app.SlackNotifier, URL: "http://localhost"},
+	}
+	if err := codec.NewEncoder(&buf, &codec.JsonHandle{}).Encode(rule); err != nil {
+		t.Fatal(err)
+	}
+	res, body := checkRequest(t, ts, "POST", "/api/alerts/rules", buf.Bytes())
+	equals(t, http.StatusCreated, res.StatusCode)
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&rule); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, "1", rule.ID)
+
+	var rules []app.Rule
+	if err := codec.NewDecoderBytes(getRawJSON(t, ts, "/api/alerts/rules"), &codec.JsonHandle{}).Decode(&rules); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, []app.Rule{rule}, rules)
+
+	res, _ = checkRequest(t, ts, "POST", "/api/alerts/rules", []byte(`{"topology": "containers"}`))
+	equals(t, http.StatusBadRequest, res.StatusCode)
+
+	res, _ = checkRequest(t, ts, "DELETE", "/api/alerts/rules/1", nil)
+	equals(t, http.StatusNoContent, res.StatusCode)
+	is404(t, ts, "/api/alerts/rules/1")
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+)
+
+// RegisterAlertRoutes registers the routes to manage the rules of an
+// Alerter.
+func RegisterAlertRoutes(router *mux.Router, a *Alerter) {
+	router.
+		Methods("GET").
+		Path("/api/alerts/rules").
+		HandlerFunc(requestContextDecorator(handleListRules(a)))
+	router.
+		Methods("POST").
+		Path("/api/alerts/rules").
+		HandlerFunc(requestContextDecorator(handleAddRule(a)))
+	router.
+		Methods("GET").
+		Path("/api/alerts/rules/{id}").
+		HandlerFunc(requestContextDecorator(handleGetRule(a)))
+	router.
+		Methods("PUT").
+		Path("/api/alerts/rules/{id}").
+		HandlerFunc(requestContextDecorator(handleUpdateRule(a)))
+	router.
+		Methods("DELETE").
+		Path("/api/alerts/rules/{id}").
+		HandlerFunc(requestContextDecorator(handleDeleteRule(a)))
+}
+
+func handleListRules(a *Alerter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		respondWith(w, http.StatusOK, a.Rules())
+	}
+}
+
+func handleGetRule(a *Alerter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		rule, ok := a.Rule(mux.Vars(r)["id"])
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		respondWith(w, http.StatusOK, rule)
+	}
+}
+
+func handleAddRule(a *Alerter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		var rule Rule
+		err := codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&rule)
+		defer r.Body.Close()
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		rule, err = a.AddRule(rule)
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		respondWith(w, http.StatusCreated, rule)
+	}
+}
+
+func handleUpdateRule(a *Alerter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		var rule Rule
+		err := codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&rule)
+		defer r.Body.Close()
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		rule.ID = mux.Vars(r)["id"]
+		found, err := a.UpdateRule(rule)
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		respondWith(w, http.StatusOK, rule)
+	}
+}
+
+func handleDeleteRule(a *Alerter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if !a.DeleteRule(mux.Vars(r)["id"]) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,219 @@
+package app
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+// AnnotationStore is somewhere the annotations of nodes are kept across
+// restarts of the app, like a ReportStore.
+type AnnotationStore interface {
+	LoadAnnotations(ctx context.Context) ([]detailed.Annotation, error)
+	StoreAnnotations(ctx context.Context, annotations []detailed.Annotation) error
+}
+
+// Annotations are the annotations users have made of nodes, by node ID,
+// kept in memory and, if there is one, in an AnnotationStore.
+type Annotations struct {
+	store AnnotationStore
+
+	mtx         sync.Mutex // also serialises stores
+	annotations map[string]detailed.Annotation
+}
+
+// NewAnnotations makes Annotations, loading them from store, nil to only
+// keep them in memory.
+func NewAnnotations(ctx context.Context, store AnnotationStore) (*Annotations, error) {
+	a := &Annotations{
+		store:       store,
+		annotations: map[string]detailed.Annotation{},
+	}
+	if store != nil {
+		loaded, err := store.LoadAnnotations(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, annotation := range loaded {
+			a.annotations[annotation.NodeID] = annotation
+		}
+	}
+	return a, nil
+}
+
+// All returns all annotations, by node ID.
+func (a *Annotations) All() map[string]detailed.Annotation {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	result := make(map[string]detailed.Annotation, len(a.annotations))
+	for id, annotation := range a.annotations {
+		result[id] = annotation
+	}
+	return result
+}
+
+// List returns all annotations, sorted by node ID.
+func (a *Annotations) List() []detailed.Annotation {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	return a.list()
+}
+
+func (a *Annotations) list() []detailed.Annotation {
+	result := make([]detailed.Annotation, 0, len(a.annotations))
+	for _, annotation := range a.annotations {
+		result = append(result, annotation)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].NodeID < result[j].NodeID })
+	return result
+}
+
+// Get returns the annotation of a node.
+func (a *Annotations) Get(nodeID string) (detailed.Annotation, bool) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	annotation, ok := a.annotations[nodeID]
+	return annotation, ok
+}
+
+// Set sets the annotation of its node, replacing any it had.
+func (a *Annotations) Set(ctx context.Context, annotation detailed.Annotation) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	old, existed := a.annotations[annotation.NodeID]
+	a.annotations[annotation.NodeID] = annotation
+	if err := a.save(ctx); err != nil {
+		if existed {
+			a.annotations[annotation.NodeID] = old
+		} else {
+			delete(a.annotations, annotation.NodeID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete deletes the annotation of a node, returning whether it had one.
+func (a *Annotations) Delete(ctx context.Context, nodeID string) (bool, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	old, ok := a.annotations[nodeID]
+	if !ok {
+		return false, nil
+	}
+	delete(a.annotations, nodeID)
+	if err := a.save(ctx); err != nil {
+		a.annotations[nodeID] = old
+		return false, err
+	}
+	return true, nil
+}
+
+// save stores all annotations. Called with a.mtx held.
+func (a *Annotations) save(ctx context.Context) error {
+	if a.store == nil {
+		return nil
+	}
+	return a.store.StoreAnnotations(ctx, a.list())
+}
+
+// RegisterAnnotationRoutes registers the routes to annotate nodes. Node IDs
+// are query-escaped, as for /api/topology/{topology}/{id}.
+func RegisterAnnotationRoutes(router *mux.Router, a *Annotations) {
+	router.
+		Methods("GET").
+		Path("/api/annotations").
+		HandlerFunc(requestContextDecorator(handleListAnnotations(a)))
+	router.
+		Methods("GET").
+		MatcherFunc(URLMatcher("/api/annotations/{id}")).
+		HandlerFunc(requestContextDecorator(handleGetAnnotation(a)))
+	router.
+		Methods("PUT").
+		MatcherFunc(URLMatcher("/api/annotations/{id}")).
+		HandlerFunc(requestContextDecorator(handleSetAnnotation(a)))
+	router.
+		Methods("DELETE").
+		MatcherFunc(URLMatcher("/api/annotations/{id}")).
+		HandlerFunc(requestContextDecorator(handleDeleteAnnotation(a)))
+}
+
+func handleListAnnotations(a *Annotations) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		respondWith(w, http.StatusOK, a.List())
+	}
+}
+
+func handleGetAnnotation(a *Annotations) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		annotation, ok := a.Get(mux.Vars(r)["id"])
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		respondWith(w, http.StatusOK, annotation)
+	}
+}
+
+// handleSetAnnotation sets the note, tags and pin of a node, noting who
+// did, and when.
+func handleSetAnnotation(a *Annotations) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		var annotation detailed.Annotation
+		err := codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&annotation)
+		defer r.Body.Close()
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		annotation.NodeID = mux.Vars(r)["id"]
+		annotation.Tags = normaliseTags(annotation.Tags)
+		annotation.User = requestUser(r)
+		annotation.Updated = mtime.Now().UTC()
+		if err := a.Set(ctx, annotation); err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		respondWith(w, http.StatusOK, annotation)
+	}
+}
+
+func handleDeleteAnnotation(a *Annotations) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		found, err := a.Delete(ctx, mux.Vars(r)["id"])
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// normaliseTags trims tags, dropping empty and repeated ones, and sorts
+// them.
+func normaliseTags(tags []string) []string {
+	seen := map[string]struct{}{}
+	var result []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if _, ok := seen[tag]; ok || tag == "" {
+			continue
+		}
+		seen[tag] = struct{}{}
+		result = append(result, tag)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,100 @@
+package app_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+type mockAnnotationStore struct {
+	annotations []detailed.Annotation
+	err         error
+}
+
+func (s *mockAnnotationStore) LoadAnnotations(context.Context) ([]detailed.Annotation, error) {
+	return s.annotations, nil
+}
+
+func (s *mockAnnotationStore) StoreAnnotations(_ context.Context, annotations []detailed.Annotation) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.annotations = annotations
+	return nil
+}
+
+func TestAnnotations(t *testing.T) {
+	ctx := context.Background()
+	store := &mockAnnotationStore{annotations: []detailed.Annotation{{NodeID: "a", Note: "known good"}}}
+	annotations, err := app.NewAnnotations(ctx, store)
+	ok(t, err)
+	if _, found := annotations.Get("a"); !found {
+		t.Fatal("want the stored annotations loaded")
+	}
+
+	ok(t, annotations.Set(ctx, detailed.Annotation{NodeID: "b", Pinned: true}))
+	equals(t, []string{"a", "b"}, []string{store.annotations[0].NodeID, store.annotations[1].NodeID})
+
+	// Annotations which can't be stored are not kept
+	store.err = fmt.Errorf("unavailable")
+	if err := annotations.Set(ctx, detailed.Annotation{NodeID: "c"}); err == nil {
+		t.Error("want an error")
+	}
+	if found, err := annotations.Delete(ctx, "a"); err == nil || found {
+		t.Error("want an error")
+	}
+	equals(t, 2, len(annotations.List()))
+}
+
+func TestAPIAnnotations(t *testing.T) {
+	annotations, err := app.NewAnnotations(context.Background(), nil)
+	ok(t, err)
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterAnnotationRoutes(router, annotations)
+	app.RegisterTopologyRoutes(router, app.WebReporter{Reporter: app.StaticCollector(fixture.Report), Annotations: annotations}, map[string]bool{})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	path := "/api/annotations/" + url.QueryEscape(fixture.ServerContainerNodeID)
+	is404(t, ts, path)
+	res, body := checkRequest(t, ts, "PUT", path, []byte(`{"note": "suspicious", "tags": ["web", " db", "web", ""], "pinned": true}`))
+	equals(t, http.StatusOK, res.StatusCode)
+
+	var annotation detailed.Annotation
+	ok(t, codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&annotation))
+	equals(t, fixture.ServerContainerNodeID, annotation.NodeID)
+	equals(t, []string{"db", "web"}, annotation.Tags)
+	if annotation.Updated.IsZero() {
+		t.Error("want when the annotation was updated")
+	}
+
+	var list []detailed.Annotation
+	ok(t, codec.NewDecoderBytes(getRawJSON(t, ts, "/api/annotations"), &codec.JsonHandle{}).Decode(&list))
+	equals(t, 1, len(list))
+
+	// Annotations are merged into the nodes rendered
+	var node app.APINode
+	body = getRawJSON(t, ts, "/api/topology/containers/"+url.QueryEscape(fixture.ServerContainerNodeID))
+	ok(t, codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&node))
+	if have := node.Node.Annotation; have == nil || !reflect.DeepEqual(annotation.Tags, have.Tags) || have.Note != "suspicious" || !have.Pinned {
+		t.Errorf("want %v, have %v", annotation, have)
+	}
+
+	res, _ = checkRequest(t, ts, "DELETE", path, nil)
+	equals(t, http.StatusNoContent, res.StatusCode)
+	res, _ = checkRequest(t, ts, "DELETE", path, nil)
+	equals(t, http.StatusNotFound, res.StatusCode)
+	res, _ = checkRequest(t, ts, "PUT", path, []byte(`not json`))
+	equals(t, http.StatusBadRequest, res.StatusCode)
+}
@@ -0,0 +1,342 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+// AnomalyConfig is how an AnomalyDetector learns baselines, and what it
+// makes of departures from them.
+type AnomalyConfig struct {
+	Topologies []string      // IDs of the topologies to learn
+	Alpha      float64       // weight of each new sample in the baselines, 0 to 1
+	Threshold  float64       // how many standard deviations from the baseline is anomalous
+	Warmup     int           // samples to learn before flagging anomalies
+	Forget     time.Duration // how long to remember nodes and edges gone
+}
+
+// DefaultAnomalyConfig is the AnomalyConfig of containers, pods, services
+// and hosts with baselines of about the last 20 samples.
+var DefaultAnomalyConfig = AnomalyConfig{
+	Topologies: []string{containersID, podsID, servicesID, hostsID},
+	Alpha:      0.1,
+	Threshold:  4,
+	Warmup:     10,
+	Forget:     24 * time.Hour,
+}
+
+// ewma is an exponentially weighted moving average, and variance, of
+// the samples of something.
+type ewma struct {
+	mean, variance float64
+	samples        int
+	lastSeen       time.Time
+}
+
+func (b *ewma) add(x, alpha float64, timestamp time.Time) {
+	if b.samples == 0 {
+		b.mean = x
+	} else {
+		diff := x - b.mean
+		incr := alpha * diff
+		b.mean += incr
+		b.variance = (1 - alpha) * (b.variance + diff*incr)
+	}
+	b.samples++
+	b.lastSeen = timestamp
+}
+
+// deviates tells whether x is further than threshold standard deviations
+// from the mean, in the direction of sign if not 0. Small deviations, less
+// than a tenth of the mean, never are, so steady levels don't make
+// anomalies of noise.
+func (b *ewma) deviates(x, threshold float64, sign int) bool {
+	diff := x - b.mean
+	if (sign > 0 && diff <= 0) || (sign < 0 && diff >= 0) {
+		return false
+	}
+	tolerance := math.Max(threshold*math.Sqrt(b.variance), 0.1*math.Abs(b.mean))
+	return math.Abs(diff) > tolerance
+}
+
+type edgeKey struct{ src, dst string }
+
+// edge is the baseline of an edge, which is new until learnt, if it
+// appeared after its source.
+type edge struct {
+	ewma
+	new bool
+}
+
+type metricKey struct{ node, metric string }
+
+// learnt is what an AnomalyDetector learnt of a topology.
+type learnt struct {
+	nodes   map[string]*ewma // of the presence of nodes, to tell new ones
+	edges   map[edgeKey]*edge
+	metrics map[metricKey]*ewma
+}
+
+// AnomalyDetector periodically learns the baselines of the connection
+// counts of the edges, and the metrics, of the nodes of topologies of a
+// Reporter, and flags departures from them: new edges of known nodes,
+// spikes of connections, and metrics far from their usual levels.
+type AnomalyDetector struct {
+	reporter Reporter
+	registry *Registry
+	config   AnomalyConfig
+	quit     chan struct{}
+
+	mtx       sync.Mutex
+	learnt    map[string]*learnt // by topology ID
+	anomalies []detailed.Anomaly
+}
+
+// NewAnomalyDetector makes a new AnomalyDetector, which has learnt
+// nothing yet.
+func NewAnomalyDetector(rep Reporter, config AnomalyConfig) (*AnomalyDetector, error) {
+	for _, id := range config.Topologies {
+		if _, ok := topologyRegistry.get(id); !ok {
+			return nil, fmt.Errorf("unknown topology: %q", id)
+		}
+	}
+	if config.Alpha <= 0 || config.Alpha > 1 {
+		return nil, fmt.Errorf("alpha must be in (0, 1]: %v", config.Alpha)
+	}
+	return &AnomalyDetector{
+		reporter: rep,
+		registry: topologyRegistry,
+		config:   config,
+		quit:     make(chan struct{}),
+		learnt:   map[string]*learnt{},
+	}, nil
+}
+
+// Start learns and detects every interval, until stopped.
+func (d *AnomalyDetector) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.Detect(context.Background(), time.Now())
+			case <-d.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops learning and detecting.
+func (d *AnomalyDetector) Stop() {
+	close(d.quit)
+}
+
+// List returns the anomalies detected last, by topology, node and kind.
+func (d *AnomalyDetector) List() []detailed.Anomaly {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return append([]detailed.Anomaly(nil), d.anomalies...)
+}
+
+// All returns the anomalies detected last, by node ID.
+func (d *AnomalyDetector) All() map[string][]detailed.Anomaly {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	result := map[string][]detailed.Anomaly{}
+	for _, a := range d.anomalies {
+		result[a.NodeID] = append(result[a.NodeID], a)
+	}
+	return result
+}
+
+// Detect compares the report at the given time with the baselines, to
+// detect anomalies, then learns it. Anomalies still there since the last
+// time keep when they started.
+func (d *AnomalyDetector) Detect(ctx context.Context, timestamp time.Time) {
+	rpt, err := d.reporter.Report(ctx, timestamp)
+	if err != nil {
+		log.Errorf("Error getting report for anomalies: %v", err)
+		return
+	}
+	rc := RenderContextForReporter(d.reporter, rpt)
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	since := map[detailed.Anomaly]time.Time{}
+	for _, a := range d.anomalies {
+		since[anomalyKey(a)] = a.Since
+	}
+	var anomalies []detailed.Anomaly
+	for _, topologyID := range d.config.Topologies {
+		renderer, filter, err := d.registry.RendererForTopology(topologyID, nil, rpt)
+		if err != nil {
+			log.Errorf("Error rendering %s for anomalies: %v", topologyID, err)
+			continue
+		}
+		l, ok := d.learnt[topologyID]
+		if !ok {
+			l = &learnt{nodes: map[string]*ewma{}, edges: map[edgeKey]*edge{}, metrics: map[metricKey]*ewma{}}
+			d.learnt[topologyID] = l
+		}
+		nodes := render.Render(rpt, renderer, filter).Nodes
+		anomalies = append(anomalies, d.detect(rc, topologyID, l, nodes, timestamp)...)
+	}
+	for i, a := range anomalies {
+		if t, ok := since[anomalyKey(a)]; ok {
+			anomalies[i].Since = t
+		}
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		a, b := anomalies[i], anomalies[j]
+		if a.Topology != b.Topology {
+			return a.Topology < b.Topology
+		}
+		if a.NodeID != b.NodeID {
+			return a.NodeID < b.NodeID
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Peer+a.Metric < b.Peer+b.Metric
+	})
+	d.anomalies = anomalies
+}
+
+// anomalousAlpha is the weight of anomalous samples in baselines: less
+// than that of others, so anomalies stand out for a while before they
+// become the new normal.
+func anomalousAlpha(alpha float64) float64 {
+	return alpha / 10
+}
+
+// anomalyKey identifies an anomaly across detections.
+func anomalyKey(a detailed.Anomaly) detailed.Anomaly {
+	a.Value, a.Baseline, a.Since = 0, 0, time.Time{}
+	return a
+}
+
+// detect detects the anomalies of the rendered nodes of a topology, and
+// learns them. Called with d.mtx held.
+func (d *AnomalyDetector) detect(rc detailed.RenderContext, topologyID string, l *learnt, nodes report.Nodes, timestamp time.Time) []detailed.Anomaly {
+	var (
+		result []detailed.Anomaly
+		c      = d.config
+	)
+	anomaly := func(kind, nodeID string) detailed.Anomaly {
+		return detailed.Anomaly{Kind: kind, Topology: topologyID, NodeID: nodeID, Since: timestamp}
+	}
+	weights := render.EdgeWeights(nodes)
+	for _, id := range sortedIDs(nodes) {
+		n := nodes[id]
+		if n.Topology == render.Pseudo {
+			continue
+		}
+		known := l.nodes[id] != nil && l.nodes[id].samples >= c.Warmup
+		for dstID, weight := range weights[id] {
+			key := edgeKey{id, dstID}
+			e, ok := l.edges[key]
+			if !ok {
+				e = &edge{new: known}
+				l.edges[key] = e
+			}
+			x, alpha := float64(weight.Connections), c.Alpha
+			switch {
+			case e.samples < c.Warmup:
+				if e.new {
+					a := anomaly(detailed.AnomalyNewEdge, id)
+					a.Peer, a.Value = dstID, x
+					result = append(result, a)
+				}
+			case e.deviates(x, c.Threshold, 1):
+				a := anomaly(detailed.AnomalyEdgeSpike, id)
+				a.Peer, a.Value, a.Baseline = dstID, x, e.mean
+				result = append(result, a)
+				alpha = anomalousAlpha(alpha)
+			}
+			e.add(x, alpha, timestamp)
+		}
+		if summary, ok := detailed.MakeNodeSummary(rc, n); ok {
+			for _, row := range summary.Metrics {
+				if row.ValueEmpty {
+					continue
+				}
+				key := metricKey{id, row.ID}
+				b, ok := l.metrics[key]
+				if !ok {
+					b = &ewma{}
+					l.metrics[key] = b
+				}
+				alpha := c.Alpha
+				if b.samples >= c.Warmup && b.deviates(row.Value, c.Threshold, 0) {
+					a := anomaly(detailed.AnomalyMetric, id)
+					a.Metric, a.Value, a.Baseline = row.ID, row.Value, b.mean
+					result = append(result, a)
+					alpha = anomalousAlpha(alpha)
+				}
+				b.add(row.Value, alpha, timestamp)
+			}
+		}
+		if l.nodes[id] == nil {
+			l.nodes[id] = &ewma{}
+		}
+		l.nodes[id].add(1, c.Alpha, timestamp)
+	}
+	d.forget(l, timestamp)
+	return result
+}
+
+// forget forgets the nodes, edges and metrics not seen for a while.
+func (d *AnomalyDetector) forget(l *learnt, timestamp time.Time) {
+	cutoff := timestamp.Add(-d.config.Forget)
+	for k, b := range l.nodes {
+		if b.lastSeen.Before(cutoff) {
+			delete(l.nodes, k)
+		}
+	}
+	for k, b := range l.edges {
+		if b.lastSeen.Before(cutoff) {
+			delete(l.edges, k)
+		}
+	}
+	for k, b := range l.metrics {
+		if b.lastSeen.Before(cutoff) {
+			delete(l.metrics, k)
+		}
+	}
+}
+
+// RegisterAnomalyRoutes registers the route to list anomalies, optionally
+// of a ?topology= only.
+func RegisterAnomalyRoutes(router *mux.Router, d *AnomalyDetector) {
+	router.
+		Methods("GET").
+		Path("/api/anomalies").
+		HandlerFunc(requestContextDecorator(handleListAnomalies(d)))
+}
+
+func handleListAnomalies(d *AnomalyDetector) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		topologyID := r.URL.Query().Get("topology")
+		result := []detailed.Anomaly{}
+		for _, a := range d.List() {
+			if topologyID == "" || a.Topology == topologyID {
+				result = append(result, a)
+			}
+		}
+		respondWith(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,103 @@
+package app_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+// changingReporter returns whichever report it was last given.
+type changingReporter struct {
+	app.StaticCollector
+	rpt report.Report
+}
+
+func (r *changingReporter) Report(context.Context, time.Time) (report.Report, error) {
+	return r.rpt, nil
+}
+
+func TestAnomalyDetector(t *testing.T) {
+	config := app.DefaultAnomalyConfig
+	config.Topologies = []string{"containers"}
+	config.Warmup = 3
+	if _, err := app.NewAnomalyDetector(app.StaticCollector(fixture.Report), app.AnomalyConfig{Topologies: []string{"nonesuch"}, Alpha: 0.1}); err == nil {
+		t.Error("expected an error for an unknown topology")
+	}
+	var (
+		ctx = context.Background()
+		now = time.Now()
+		rep = &changingReporter{rpt: fixture.Report}
+	)
+	d, err := app.NewAnomalyDetector(rep, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < config.Warmup; i++ {
+		d.Detect(ctx, now)
+	}
+	if anomalies := d.List(); len(anomalies) != 0 {
+		t.Fatalf("Expected no anomalies in a steady topology, got %v", anomalies)
+	}
+
+	// A spike of connections from the client, a new edge from the server,
+	// and a spike of the CPU of the server
+	rpt := fixture.Report.Copy()
+	rpt.ID = "spikes" // not to get the memoised renders of the fixture
+	ep := rpt.Endpoint.Nodes[fixture.Client54001NodeID]
+	rpt.Endpoint.Nodes[fixture.Client54001NodeID] = ep.WithCounters(map[string]int{endpoint.SampledConnections: 100})
+	ep = rpt.Endpoint.Nodes[fixture.Server80NodeID]
+	rpt.Endpoint.Nodes[fixture.Server80NodeID] = ep.WithAdjacent(fixture.Client54002NodeID)
+	server := rpt.Container.Nodes[fixture.ServerContainerNodeID]
+	rpt.Container.Nodes[fixture.ServerContainerNodeID] = server.WithMetrics(report.Metrics{
+		docker.CPUTotalUsage: report.MakeSingletonMetric(now, 1e6),
+	})
+	rep.rpt = rpt
+	later := now.Add(time.Minute)
+	d.Detect(ctx, later)
+	d.Detect(ctx, later.Add(time.Minute))
+
+	kinds := map[string]detailed.Anomaly{}
+	for _, a := range d.List() {
+		kinds[a.Kind] = a
+	}
+	if a, ok := kinds[detailed.AnomalyEdgeSpike]; !ok || a.NodeID != fixture.ClientContainerNodeID || a.Peer != fixture.ServerContainerNodeID {
+		t.Errorf("Expected a spike of connections from the client to the server, got %v", d.List())
+	} else if !a.Since.Equal(later) {
+		t.Errorf("Expected the spike to have started at %v, got %v", later, a.Since)
+	}
+	if a, ok := kinds[detailed.AnomalyNewEdge]; !ok || a.NodeID != fixture.ServerContainerNodeID || a.Peer != fixture.ClientContainerNodeID {
+		t.Errorf("Expected a new edge from the server to the client, got %v", d.List())
+	}
+	if a, ok := kinds[detailed.AnomalyMetric]; !ok || a.NodeID != fixture.ServerContainerNodeID || a.Metric != docker.CPUTotalUsage {
+		t.Errorf("Expected the CPU of the server to be anomalous, got %v", d.List())
+	}
+	if len(d.All()[fixture.ServerContainerNodeID]) == 0 {
+		t.Errorf("Expected anomalies of the server, got %v", d.All())
+	}
+
+	router := mux.NewRouter()
+	app.RegisterAnomalyRoutes(router, d)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+	for topology, want := range map[string]int{"": len(d.List()), "containers": len(d.List()), "hosts": 0} {
+		_, body := checkGet(t, ts, "/api/anomalies?topology="+topology)
+		var have []detailed.Anomaly
+		if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&have); err != nil {
+			t.Fatal(err)
+		}
+		if len(have) != want {
+			t.Errorf("%q: expected %d anomalies, got %v", topology, want, have)
+		}
+	}
+}
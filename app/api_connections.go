@@ -0,0 +1,126 @@
+package app
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+// Formats the connection tables of a node can be exported in
+const (
+	csvFormat    = "csv"
+	ndjsonFormat = "ndjson"
+)
+
+// ConnectionRecord is a row of a node's connection tables, as exported
+// in NDJSON, one per line.
+type ConnectionRecord struct {
+	Table      string            `json:"table"`
+	ID         string            `json:"id"`
+	NodeID     string            `json:"nodeId"`
+	Label      string            `json:"label"`
+	LabelMinor string            `json:"labelMinor,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// makeConnectionsExportHandler serves the connection tables of a node as
+// a downloadable file in the given format. It accepts the same
+// connections_* query parameters as the node handler.
+func makeConnectionsExportHandler(rep Reporter, format string) rendererHandler {
+	return func(ctx context.Context, renderer render.Renderer, transformer render.Transformer, rc detailed.RenderContext, w http.ResponseWriter, r *http.Request) {
+		node, status, err := renderNode(ctx, rep, renderer, transformer, rc, r)
+		if err != nil {
+			respondWithNodeError(w, r, status, err)
+			return
+		}
+		filename := fmt.Sprintf("%s-connections.%s", mux.Vars(r)["topology"], format)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.Header().Add("Cache-Control", "no-cache")
+		switch format {
+		case csvFormat:
+			w.Header().Set("Content-Type", "text/csv")
+			err = writeConnectionsCSV(w, node.Connections)
+		case ndjsonFormat:
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			err = writeConnectionsNDJSON(w, node.Connections)
+		}
+		if err != nil {
+			log.Errorf("Error exporting connections: %v", err)
+		}
+	}
+}
+
+// writeConnectionsCSV writes a header with the union of the columns of
+// all tables, followed by a line per connection.
+func writeConnectionsCSV(w http.ResponseWriter, summaries []detailed.ConnectionsSummary) error {
+	var (
+		columns = []string{}
+		seen    = map[string]struct{}{}
+	)
+	for _, summary := range summaries {
+		for _, column := range summary.Columns {
+			if _, ok := seen[column.ID]; !ok {
+				seen[column.ID] = struct{}{}
+				columns = append(columns, column.ID)
+			}
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append([]string{"table", "id", "node_id", "label", "label_minor"}, columns...)); err != nil {
+		return err
+	}
+	for _, summary := range summaries {
+		for _, c := range summary.Connections {
+			values := map[string]string{}
+			for _, row := range c.Metadata {
+				values[row.ID] = row.Value
+			}
+			record := []string{summary.ID, c.ID, c.NodeID, c.Label, c.LabelMinor}
+			for _, column := range columns {
+				record = append(record, values[column])
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeConnectionsNDJSON(w http.ResponseWriter, summaries []detailed.ConnectionsSummary) error {
+	encoder := codec.NewEncoder(w, &codec.JsonHandle{})
+	for _, summary := range summaries {
+		for _, c := range summary.Connections {
+			record := ConnectionRecord{
+				Table:      summary.ID,
+				ID:         c.ID,
+				NodeID:     c.NodeID,
+				Label:      c.Label,
+				LabelMinor: c.LabelMinor,
+			}
+			if len(c.Metadata) > 0 {
+				record.Metadata = map[string]string{}
+				for _, row := range c.Metadata {
+					record.Metadata[row.ID] = row.Value
+				}
+			}
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+			if _, err := w.Write([]byte("\n")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
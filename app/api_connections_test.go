@@ -0,0 +1,59 @@
+package app_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"net/url"
+	"testing"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestAPIConnectionsCSV(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	nodeURL := "/api/topology/containers/" + url.QueryEscape(fixture.ServerContainerNodeID)
+	is404(t, ts, "/api/topology/containers/foo/connections.csv")
+	is400(t, ts, nodeURL+"/connections.csv?connections_port=foo")
+
+	res, body := checkGet(t, ts, nodeURL+"/connections.csv")
+	equals(t, 200, res.StatusCode)
+	equals(t, "text/csv", res.Header.Get("Content-Type"))
+	records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	ok(t, err)
+	assert(t, len(records) > 0, "expected a header")
+	equals(t, []string{"table", "id", "node_id", "label", "label_minor", "port", "protocol", "count", "rate", "bytes_sent", "bytes_received", "retransmits", "resets", "rtt_p50", "rtt_p99", "sni"}, records[0])
+	incoming := 0
+	for _, record := range records[1:] {
+		if record[0] == "incoming-connections" {
+			incoming++
+			equals(t, "80", record[5])
+		}
+	}
+	equals(t, 2, incoming)
+}
+
+func TestAPIConnectionsNDJSON(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	nodeURL := "/api/topology/containers/" + url.QueryEscape(fixture.ServerContainerNodeID)
+
+	res, body := checkGet(t, ts, nodeURL+"/connections.ndjson?connections_cidr="+url.QueryEscape(fixture.RandomClientIP+"/32"))
+	equals(t, 200, res.StatusCode)
+	equals(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+	var records []app.ConnectionRecord
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		var record app.ConnectionRecord
+		ok(t, codec.NewDecoderBytes(scanner.Bytes(), &codec.JsonHandle{}).Decode(&record))
+		records = append(records, record)
+	}
+	equals(t, 1, len(records))
+	equals(t, "incoming-connections", records[0].Table)
+	equals(t, fixture.RandomClientIP, records[0].Label)
+	equals(t, "1", records[0].Metadata["count"])
+}
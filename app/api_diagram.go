@@ -0,0 +1,59 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/render/diagram"
+)
+
+var diagramFormats = map[string]struct {
+	contentType string
+	extension   string
+	write       func(io.Writer, diagram.Diagram) error
+}{
+	"dot":     {"text/vnd.graphviz; charset=utf-8", "dot", diagram.WriteDOT},
+	"d2":      {"text/plain; charset=utf-8", "d2", diagram.WriteD2},
+	"mermaid": {"text/plain; charset=utf-8", "mmd", diagram.WriteMermaid},
+}
+
+// handleDiagram writes a topology, rendered with the options given as for
+// /api/topology/{topology}, as the text of a diagram in format dot (the
+// default), d2 or mermaid.
+func handleDiagram(ctx context.Context, renderer render.Renderer, transformer render.Transformer, rc detailed.RenderContext, w http.ResponseWriter, r *http.Request) {
+	format := r.Form.Get("format")
+	if format == "" {
+		format = "dot"
+	}
+	f, ok := diagramFormats[format]
+	if !ok {
+		respondWith(w, http.StatusBadRequest, fmt.Errorf("unknown format: %q", format))
+		return
+	}
+
+	var (
+		topologyID = mux.Vars(r)["topology"]
+		title      = topologyID
+	)
+	if desc, ok := topologyRegistry.get(topologyID); ok {
+		title = desc.Name
+	}
+	d := diagram.Diagram{
+		Title: title,
+		Nodes: detailed.Summaries(rc, render.Render(rc.Report, renderer, transformer).Nodes),
+	}
+
+	w.Header().Set("Content-Type", f.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", topologyID+"."+f.extension))
+	w.Header().Add("Cache-Control", "no-cache")
+	if err := f.write(w, d); err != nil {
+		log.Errorf("Error writing diagram: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"golang.org/x/net/context"
+	apinetworkingv1 "k8s.io/api/networking/v1"
+
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+// networkPolicyDocument is a suggested network policy as kubectl takes it.
+type networkPolicyDocument struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec apinetworkingv1.NetworkPolicySpec `json:"spec"`
+}
+
+// handleNetworkPolicies suggests, as YAML, network policies allowing the
+// traffic observed to and from the pods of ?namespace=, or of its
+// deployment ?deployment=, and no other.
+func handleNetworkPolicies(ctx context.Context, rep Reporter, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondWith(w, http.StatusBadRequest, err)
+		return
+	}
+	namespace, deployment := r.Form.Get("namespace"), r.Form.Get("deployment")
+	if namespace == "" {
+		respondWith(w, http.StatusBadRequest, fmt.Errorf("namespace is required"))
+		return
+	}
+	timestamp := deserializeTimestamp(r.Form.Get("timestamp"))
+	rpt, err := rep.Report(ctx, timestamp)
+	if err != nil {
+		respondWith(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	selected := func(pod report.Node) bool {
+		ns, _ := pod.Latest.Lookup(kubernetes.Namespace)
+		return ns == namespace
+	}
+	if deployment != "" {
+		deploymentID, ok := findDeployment(rpt, namespace, deployment)
+		if !ok {
+			respondWith(w, http.StatusNotFound, fmt.Errorf("deployment not found: %s/%s", namespace, deployment))
+			return
+		}
+		selected = func(pod report.Node) bool {
+			ids, _ := pod.Parents.Lookup(report.Deployment)
+			return ids.Contains(deploymentID)
+		}
+	} else if !hasNamespace(rpt, namespace) {
+		respondWith(w, http.StatusNotFound, fmt.Errorf("namespace not found: %s", namespace))
+		return
+	}
+
+	nodes := render.Render(rpt, render.PodRenderer, render.FilterUnconnectedPseudo).Nodes
+	policies := detailed.SuggestNetworkPolicies(RenderContextForReporter(rep, rpt), nodes, selected)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Network policies allowing the traffic observed until %s\n", timestamp.UTC().Format(time.RFC3339))
+	if len(policies) == 0 {
+		fmt.Fprintf(&buf, "# No pods with labels to select them by\n")
+	}
+	for _, p := range policies {
+		doc := networkPolicyDocument{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy", Spec: p.Spec}
+		doc.Metadata.Name, doc.Metadata.Namespace = p.Name, p.Namespace
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		buf.WriteString("---\n")
+		buf.Write(out)
+	}
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Write(buf.Bytes())
+}
+
+func findDeployment(rpt report.Report, namespace, name string) (string, bool) {
+	for id, n := range rpt.Deployment.Nodes {
+		ns, _ := n.Latest.Lookup(kubernetes.Namespace)
+		if deployment, _ := n.Latest.Lookup(kubernetes.Name); ns == namespace && deployment == name {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+func hasNamespace(rpt report.Report, namespace string) bool {
+	for _, n := range rpt.Namespace.Nodes {
+		if name, _ := n.Latest.Lookup(kubernetes.Name); name == namespace {
+			return true
+		}
+	}
+	for _, n := range rpt.Pod.Nodes {
+		if ns, _ := n.Latest.Lookup(kubernetes.Namespace); ns == namespace {
+			return true
+		}
+	}
+	return false
+}
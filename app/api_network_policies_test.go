@@ -0,0 +1,65 @@
+package app_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/gorilla/mux"
+	apinetworkingv1 "k8s.io/api/networking/v1"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestAPINetworkPolicies(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	deploymentID := report.MakeDeploymentNodeID("pong")
+	rpt.Deployment.AddNode(report.MakeNodeWith(deploymentID, map[string]string{
+		kubernetes.Name:      "pong",
+		kubernetes.Namespace: fixture.KubernetesNamespace,
+	}))
+	for id, app := range map[string]string{fixture.ClientPodNodeID: "client", fixture.ServerPodNodeID: "server"} {
+		rpt.Pod.Nodes[id] = rpt.Pod.Nodes[id].WithLatests(map[string]string{
+			kubernetes.LabelPrefix + "app":               app,
+			kubernetes.LabelPrefix + "pod-template-hash": id,
+		})
+	}
+	rpt.Pod.Nodes[fixture.ServerPodNodeID] = rpt.Pod.Nodes[fixture.ServerPodNodeID].WithParents(rpt.Pod.Nodes[fixture.ServerPodNodeID].Parents.Add(report.Deployment, report.MakeStringSet(deploymentID)))
+
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, app.StaticCollector(rpt), map[string]bool{})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	is400(t, ts, "/api/networkpolicies")
+	is404(t, ts, "/api/networkpolicies?namespace=nowhere")
+	is404(t, ts, "/api/networkpolicies?namespace=ping&deployment=nothing")
+
+	res, body := checkGet(t, ts, "/api/networkpolicies?namespace=ping&deployment=pong")
+	equals(t, 200, res.StatusCode)
+	equals(t, "application/x-yaml", res.Header.Get("Content-Type"))
+	var policy apinetworkingv1.NetworkPolicy
+	if err := yaml.Unmarshal(body, &policy); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, "pong", policy.Name)
+	equals(t, fixture.KubernetesNamespace, policy.Namespace)
+	// Labels telling revisions apart are left out of selectors
+	equals(t, map[string]string{"app": "server"}, policy.Spec.PodSelector.MatchLabels)
+	equals(t, 2, len(policy.Spec.Ingress))
+	equals(t, "51.52.53.54/32", policy.Spec.Ingress[0].From[0].IPBlock.CIDR)
+	equals(t, map[string]string{"app": "client"}, policy.Spec.Ingress[1].From[0].PodSelector.MatchLabels)
+	for _, rule := range policy.Spec.Ingress {
+		equals(t, 1, len(rule.Ports))
+		equals(t, 80, rule.Ports[0].Port.IntValue())
+	}
+	equals(t, 0, len(policy.Spec.Egress))
+
+	// Every pod of the namespace gets a policy
+	_, body = checkGet(t, ts, "/api/networkpolicies?namespace=ping")
+	equals(t, 2, strings.Count(string(body), "kind: NetworkPolicy"))
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"golang.org/x/net/context"
@@ -10,15 +11,27 @@ import (
 	"github.com/weaveworks/scope/report"
 )
 
-// Raw report handler
+// Raw report handler. It responds with JSON, unless protobuf is
+// accepted.
 func makeRawReportHandler(rep Reporter) CtxHandlerFunc {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-		report, err := rep.Report(ctx, time.Now())
+		timestamp := deserializeTimestamp(r.URL.Query().Get("timestamp"))
+		rpt, err := rep.Report(ctx, timestamp)
 		if err != nil {
 			respondWith(w, http.StatusInternalServerError, err)
 			return
 		}
-		respondWith(w, http.StatusOK, report)
+		if strings.Contains(r.Header.Get("Accept"), report.ProtobufContentType) {
+			buf, err := rpt.MarshalProtobuf()
+			if err != nil {
+				respondWith(w, http.StatusInternalServerError, err)
+				return
+			}
+			w.Header().Set("Content-Type", report.ProtobufContentType)
+			w.Write(buf)
+			return
+		}
+		respondWith(w, http.StatusOK, rpt)
 	}
 }
 
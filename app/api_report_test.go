@@ -1,6 +1,7 @@
 package app_test
 
 import (
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
@@ -33,3 +34,26 @@ func TestAPIReport(t *testing.T) {
 		t.Fatalf("JSON parse error: %s", err)
 	}
 }
+
+func TestAPIReportProtobuf(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL+"/api/report", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", report.ProtobufContentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	equals(t, report.ProtobufContentType, resp.Header.Get("Content-Type"))
+
+	var r report.Report
+	if err := r.ReadProtobuf(resp.Body, false); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, len(fixture.Report.Container.Nodes), len(r.Container.Nodes))
+}
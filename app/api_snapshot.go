@@ -0,0 +1,75 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/render/layout"
+	"github.com/weaveworks/scope/render/snapshot"
+)
+
+const defaultSnapshotLayout = "force"
+
+var snapshotFormats = map[string]struct {
+	contentType string
+	write       func(io.Writer, snapshot.Snapshot) error
+}{
+	"svg":  {"image/svg+xml", snapshot.WriteSVG},
+	"png":  {"image/png", snapshot.WritePNG},
+	"html": {"text/html; charset=utf-8", snapshot.WriteHTML},
+}
+
+// handleSnapshot draws a topology, rendered with the options given as for
+// /api/topology/{topology}, as a file to download in format svg (the
+// default), png or html, laid out as per layout, force or layered.
+func handleSnapshot(ctx context.Context, renderer render.Renderer, transformer render.Transformer, rc detailed.RenderContext, w http.ResponseWriter, r *http.Request) {
+	format := r.Form.Get("format")
+	if format == "" {
+		format = "svg"
+	}
+	f, ok := snapshotFormats[format]
+	if !ok {
+		respondWith(w, http.StatusBadRequest, fmt.Errorf("unknown format: %q", format))
+		return
+	}
+	algorithm := r.Form.Get("layout")
+	if algorithm == "" {
+		algorithm = defaultSnapshotLayout
+	}
+	lay, ok := layout.Algorithms[algorithm]
+	if !ok {
+		respondWith(w, http.StatusBadRequest, fmt.Errorf("unknown layout: %q", algorithm))
+		return
+	}
+
+	var (
+		topologyID = mux.Vars(r)["topology"]
+		timestamp  = deserializeTimestamp(r.Form.Get("timestamp"))
+		nodes      = render.Render(rc.Report, renderer, transformer).Nodes
+		title      = topologyID
+	)
+	if desc, ok := topologyRegistry.get(topologyID); ok {
+		title = desc.Name
+	}
+	s := snapshot.Snapshot{
+		Title:  title,
+		Time:   timestamp,
+		Nodes:  detailed.Summaries(rc, nodes),
+		Layout: lay(nodes, nil),
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", topologyID, timestamp.UTC().Format("20060102T150405Z"), format)
+	w.Header().Set("Content-Type", f.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Add("Cache-Control", "no-cache")
+	if err := f.write(w, s); err != nil {
+		log.Errorf("Error writing snapshot: %v", err)
+	}
+}
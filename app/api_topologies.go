@@ -14,6 +14,7 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/probe/kubernetes"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/report"
@@ -23,18 +24,29 @@ const (
 	apiTopologyURL         = "/api/topology/"
 	processesID            = "processes"
 	processesByNameID      = "processes-by-name"
+	processesByTreeID      = "processes-by-tree"
+	processesByUnitID      = "processes-by-unit"
 	systemGroupID          = "system"
 	containersID           = "containers"
 	containersByHostnameID = "containers-by-hostname"
 	containersByImageID    = "containers-by-image"
+	containersByNetworkID  = "containers-by-network"
+	containersByVolumeID   = "containers-by-volume"
 	podsID                 = "pods"
 	kubeControllersID      = "kube-controllers"
 	servicesID             = "services"
+	customResourcesID      = "custom-resources"
 	hostsID                = "hosts"
+	internetExposedID      = "internet-exposed"
 	weaveID                = "weave"
 	ecsTasksID             = "ecs-tasks"
 	ecsServicesID          = "ecs-services"
 	swarmServicesID        = "swarm-services"
+	swarmTasksID           = "swarm-tasks"
+	composeServicesID      = "compose-services"
+	nomadJobsID            = "nomad-jobs"
+	nomadTaskGroupsID      = "nomad-task-groups"
+	nomadAllocationsID     = "nomad-allocations"
 )
 
 var (
@@ -64,6 +76,13 @@ func namespaceFilters(namespaces []string, noneLabel string) APITopologyOptionGr
 func updateFilters(rpt report.Report, topologies []APITopologyDesc) []APITopologyDesc {
 	topologies = updateKubeFilters(rpt, topologies)
 	topologies = updateSwarmFilters(rpt, topologies)
+	topologies = updateNetworkPolicyFilters(rpt, topologies)
+	topologies = updateVulnerabilityFilters(rpt, topologies)
+	topologies = updatePrivilegeFilters(rpt, topologies)
+	topologies = updateZoneFilters(rpt, topologies)
+	topologies = updateGroupByOptions(rpt, topologies)
+	topologies = updatePortFilters(rpt, topologies)
+	topologies = updateUninstrumentedOptions(rpt, topologies)
 	return topologies
 }
 
@@ -85,7 +104,7 @@ func updateSwarmFilters(rpt report.Report, topologies []APITopologyDesc) []APITo
 	}
 	topologies = append([]APITopologyDesc{}, topologies...) // Make a copy so we can make changes safely
 	for i, t := range topologies {
-		if t.id == containersID || t.id == swarmServicesID {
+		if t.id == containersID || t.id == swarmServicesID || t.id == swarmTasksID {
 			topologies[i] = mergeTopologyFilters(t, []APITopologyOptionGroup{
 				namespaceFilters(ns, "All Stacks"),
 			})
@@ -95,24 +114,352 @@ func updateSwarmFilters(rpt report.Report, topologies []APITopologyDesc) []APITo
 }
 
 func updateKubeFilters(rpt report.Report, topologies []APITopologyDesc) []APITopologyDesc {
-	ns := []string{}
+	var (
+		ns             = []string{}
+		clusters       = []string{}
+		seenNamespaces = map[string]struct{}{}
+		seenClusters   = map[string]struct{}{}
+	)
 	for _, n := range rpt.Namespace.Nodes {
 		name, ok := n.Latest.Lookup(kubernetes.Name)
 		if !ok {
 			continue
 		}
-		ns = append(ns, name)
+		// Namespaces of the same name in several clusters are filtered on together
+		if _, ok := seenNamespaces[name]; !ok {
+			seenNamespaces[name] = struct{}{}
+			ns = append(ns, name)
+		}
+		if cluster, ok := n.Latest.Lookup(kubernetes.Cluster); ok {
+			if _, ok := seenClusters[cluster]; !ok {
+				seenClusters[cluster] = struct{}{}
+				clusters = append(clusters, cluster)
+			}
+		}
 	}
 	if len(ns) == 0 {
 		return topologies
 	}
 	sort.Strings(ns)
+	filters := []APITopologyOptionGroup{namespaceFilters(ns, "All Namespaces")}
+	// Only offer to filter by cluster when the app shows several
+	if len(clusters) > 1 {
+		sort.Strings(clusters)
+		filters = append(filters, clusterFilters(clusters))
+	}
 	topologies = append([]APITopologyDesc{}, topologies...) // Make a copy so we can make changes safely
 	for i, t := range topologies {
-		if t.id == containersID || t.id == podsID || t.id == servicesID || t.id == kubeControllersID {
-			topologies[i] = mergeTopologyFilters(t, []APITopologyOptionGroup{
-				namespaceFilters(ns, "All Namespaces"),
-			})
+		if t.id == containersID || t.id == podsID || t.id == servicesID || t.id == kubeControllersID || t.id == customResourcesID {
+			topologies[i] = mergeTopologyFilters(t, filters)
+		}
+		if t.id == hostsID && len(clusters) > 1 {
+			topologies[i] = mergeTopologyFilters(t, []APITopologyOptionGroup{clusterFilters(clusters)})
+		}
+	}
+	return topologies
+}
+
+func clusterFilters(clusters []string) APITopologyOptionGroup {
+	options := APITopologyOptionGroup{ID: "cluster", Default: "", SelectType: "union", NoneLabel: "All Clusters"}
+	for _, cluster := range clusters {
+		options.Options = append(options.Options, APITopologyOption{
+			Value: cluster, Label: cluster, filter: render.IsCluster(cluster), filterPseudo: false,
+		})
+	}
+	return options
+}
+
+// updateZoneFilters lets hosts be filtered by the availability zones of
+// the clouds they run in, when they're in several.
+func updateZoneFilters(rpt report.Report, topologies []APITopologyDesc) []APITopologyDesc {
+	zones := []string{}
+	seenZones := map[string]struct{}{}
+	for _, n := range rpt.Host.Nodes {
+		zone, ok := n.Latest.Lookup(host.Zone)
+		if !ok {
+			continue
+		}
+		if _, ok := seenZones[zone]; !ok {
+			seenZones[zone] = struct{}{}
+			zones = append(zones, zone)
+		}
+	}
+	if len(zones) < 2 {
+		return topologies
+	}
+	sort.Strings(zones)
+	options := APITopologyOptionGroup{ID: "zone", Default: "", SelectType: "union", NoneLabel: "All Zones"}
+	for _, zone := range zones {
+		options.Options = append(options.Options, APITopologyOption{
+			Value: zone, Label: zone, filter: render.IsAvailabilityZone(zone), filterPseudo: false,
+		})
+	}
+	topologies = append([]APITopologyDesc{}, topologies...) // Make a copy so we can make changes safely
+	for i, t := range topologies {
+		if t.id == hostsID {
+			topologies[i] = mergeTopologyFilters(t, []APITopologyOptionGroup{options})
+		}
+	}
+	return topologies
+}
+
+// maxPortFilters is how many of the ports most connected to edges on them
+// can be hidden.
+const maxPortFilters = 20
+
+// connectedTopologies are those whose nodes have the endpoints they
+// connect from and to as children.
+var connectedTopologies = map[string]struct{}{
+	processesID:            {},
+	processesByNameID:      {},
+	containersID:           {},
+	containersByHostnameID: {},
+	containersByImageID:    {},
+	podsID:                 {},
+	kubeControllersID:      {},
+	servicesID:             {},
+	hostsID:                {},
+}
+
+// updatePortFilters lets the edges of topologies be hidden when all their
+// connections are to some ports, e.g. those Prometheus scrapes, offering
+// the ports most connected to.
+func updatePortFilters(rpt report.Report, topologies []APITopologyDesc) []APITopologyDesc {
+	counts := map[string]int{}
+	for _, n := range rpt.Endpoint.Nodes {
+		for _, dst := range n.Adjacency {
+			if _, _, port, protocol, ok := report.ParseEndpointNodeIDWithProtocol(dst); ok {
+				counts[render.MakePortProtocol(port, protocol)]++
+			}
+		}
+	}
+	if len(counts) == 0 {
+		return topologies
+	}
+	ports := make([]string, 0, len(counts))
+	for port := range counts {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if counts[ports[i]] != counts[ports[j]] {
+			return counts[ports[i]] > counts[ports[j]]
+		}
+		return ports[i] < ports[j]
+	})
+	if len(ports) > maxPortFilters {
+		ports = ports[:maxPortFilters]
+	}
+	sort.Strings(ports)
+	options := APITopologyOptionGroup{
+		ID: "hide_ports", Default: "", SelectType: "union", NoneLabel: "All Ports",
+		transformer: func(values []string) render.Transformer {
+			hidden := render.HideEdgesOnPorts{}
+			for _, v := range values {
+				hidden[v] = struct{}{}
+			}
+			return hidden
+		},
+	}
+	for _, port := range ports {
+		options.Options = append(options.Options, APITopologyOption{
+			Value: port, Label: "Hide :" + port, filter: nil, filterPseudo: false,
+		})
+	}
+	topologies = append([]APITopologyDesc{}, topologies...) // Make a copy so we can make changes safely
+	for i, t := range topologies {
+		if _, ok := connectedTopologies[t.id]; ok {
+			topologies[i] = mergeTopologyFilters(t, []APITopologyOptionGroup{options})
+		}
+	}
+	return topologies
+}
+
+// groupableTopologies are the report topologies the nodes of API
+// topologies are in, for those whose nodes can be grouped by any key.
+var groupableTopologies = map[string]string{
+	containersID: report.Container,
+	podsID:       report.Pod,
+	servicesID:   report.Service,
+	hostsID:      report.Host,
+}
+
+// updateGroupByOptions lets the nodes of topologies be grouped by the
+// value of any metadata key or label they share with other nodes, e.g.
+// containers by their team label, or hosts by region.
+func updateGroupByOptions(rpt report.Report, topologies []APITopologyDesc) []APITopologyDesc {
+	var (
+		result = topologies
+		copied = false
+	)
+	for i, t := range topologies {
+		name, ok := groupableTopologies[t.id]
+		if !ok {
+			continue
+		}
+		topology, ok := rpt.Topology(name)
+		if !ok {
+			continue
+		}
+		options := groupByOptions(topology)
+		if len(options) == 0 {
+			continue
+		}
+		if !copied {
+			result = append([]APITopologyDesc{}, topologies...) // Make a copy so we can make changes safely
+			copied = true
+		}
+		result[i] = mergeTopologyFilters(t, []APITopologyOptionGroup{{
+			ID:      "group",
+			Default: "",
+			Options: append([]APITopologyOption{{Value: "", Label: "Ungrouped"}}, options...),
+		}})
+	}
+	return result
+}
+
+// groupByOptions are the metadata keys and labels at least two nodes of
+// the topology have the same value for.
+func groupByOptions(t report.Topology) []APITopologyOption {
+	labels := map[string]string{}
+	for _, template := range t.MetadataTemplates {
+		if template.From == "" || template.From == report.FromLatest {
+			labels[template.ID] = template.Label
+		}
+	}
+	type keyValue struct{ key, value string }
+	var (
+		seen   = map[keyValue]struct{}{}
+		shared = map[string]struct{}{}
+	)
+	for _, n := range t.Nodes {
+		n.Latest.ForEach(func(key string, _ time.Time, value string) {
+			if _, ok := labels[key]; !ok {
+				for _, template := range t.TableTemplates {
+					if name, ok := report.WithoutPrefix(key, template.Prefix); ok && template.Type == report.PropertyListType {
+						labels[key] = fmt.Sprintf("%s: %s", template.Label, name)
+						break
+					}
+				}
+				if _, ok := labels[key]; !ok {
+					return
+				}
+			}
+			kv := keyValue{key, value}
+			if _, ok := seen[kv]; ok {
+				shared[key] = struct{}{}
+			}
+			seen[kv] = struct{}{}
+		})
+	}
+	options := []APITopologyOption{}
+	for key := range shared {
+		options = append(options, APITopologyOption{
+			Value: key, Label: "By " + labels[key], transformer: render.GroupBy{Key: key},
+		})
+	}
+	sort.Slice(options, func(i, j int) bool { return options[i].Label < options[j].Label })
+	return options
+}
+
+// updateNetworkPolicyFilters lets pods and containers be shown with only
+// the traffic no network policy covers, when there are network policies.
+func updateNetworkPolicyFilters(rpt report.Report, topologies []APITopologyDesc) []APITopologyDesc {
+	if len(render.NetworkPolicies(rpt)) == 0 {
+		return topologies
+	}
+	policyFilter := APITopologyOptionGroup{
+		ID:      "policy",
+		Default: "all",
+		Options: []APITopologyOption{
+			{Value: "all", Label: "All Traffic", filter: nil, filterPseudo: false},
+			{Value: "uncovered", Label: "Traffic No Policy Covers", filter: nil, filterPseudo: false, transformer: render.UncoveredTraffic{Report: rpt}},
+		},
+	}
+	topologies = append([]APITopologyDesc{}, topologies...) // Make a copy so we can make changes safely
+	for i, t := range topologies {
+		if t.id == containersID || t.id == podsID {
+			topologies[i] = mergeTopologyFilters(t, []APITopologyOptionGroup{policyFilter})
+		}
+	}
+	return topologies
+}
+
+// updateUninstrumentedOptions lets the peers no probe reports on, left
+// out of topologies otherwise, be shown grouped by subnet or domain.
+func updateUninstrumentedOptions(rpt report.Report, topologies []APITopologyDesc) []APITopologyDesc {
+	uninstrumented := APITopologyOptionGroup{
+		ID:      "uninstrumented",
+		Default: "",
+		Options: []APITopologyOption{
+			{Value: "", Label: "Hide Uninstrumented", filter: nil, filterPseudo: false},
+			{Value: render.BySubnet, Label: "Uninstrumented by Subnet", filter: nil, filterPseudo: false, transformer: render.Uninstrumented{Report: rpt, By: render.BySubnet}},
+			{Value: render.ByDomain, Label: "Uninstrumented by Domain", filter: nil, filterPseudo: false, transformer: render.Uninstrumented{Report: rpt, By: render.ByDomain}},
+		},
+	}
+	topologies = append([]APITopologyDesc{}, topologies...) // Make a copy so we can make changes safely
+	for i, t := range topologies {
+		if _, ok := connectedTopologies[t.id]; ok {
+			topologies[i] = mergeTopologyFilters(t, []APITopologyOptionGroup{uninstrumented})
+		}
+	}
+	return topologies
+}
+
+// updateVulnerabilityFilters lets containers be shown with only those
+// running images with critical CVEs, when images have been scanned.
+func updateVulnerabilityFilters(rpt report.Report, topologies []APITopologyDesc) []APITopologyDesc {
+	scanned := false
+	for _, n := range rpt.Container.Nodes {
+		if _, ok := n.Latest.Lookup(docker.ImageCVEsCritical); ok {
+			scanned = true
+			break
+		}
+	}
+	if !scanned {
+		return topologies
+	}
+	vulnerabilityFilter := APITopologyOptionGroup{
+		ID:      "vulnerabilities",
+		Default: "all",
+		Options: []APITopologyOption{
+			{Value: "all", Label: "All Images", filter: nil, filterPseudo: false},
+			{Value: "critical", Label: "Images With Critical CVEs", filter: render.HasCriticalVulnerabilities, filterPseudo: false},
+		},
+	}
+	topologies = append([]APITopologyDesc{}, topologies...) // Make a copy so we can make changes safely
+	for i, t := range topologies {
+		if t.id == containersID {
+			topologies[i] = mergeTopologyFilters(t, []APITopologyOptionGroup{vulnerabilityFilter})
+		}
+	}
+	return topologies
+}
+
+// updatePrivilegeFilters lets containers be shown with only the
+// privileged ones, when probes report the privileges of containers.
+func updatePrivilegeFilters(rpt report.Report, topologies []APITopologyDesc) []APITopologyDesc {
+	reported := false
+	for _, n := range rpt.Container.Nodes {
+		if _, ok := n.Latest.Lookup(docker.ContainerPrivileged); ok {
+			reported = true
+			break
+		}
+	}
+	if !reported {
+		return topologies
+	}
+	privilegeFilter := APITopologyOptionGroup{
+		ID:      "privileges",
+		Default: "all",
+		Options: []APITopologyOption{
+			{Value: "all", Label: "All Containers", filter: nil, filterPseudo: false},
+			{Value: "privileged", Label: "Privileged Containers", filter: render.IsPrivilegedContainer, filterPseudo: false},
+		},
+	}
+	topologies = append([]APITopologyDesc{}, topologies...) // Make a copy so we can make changes safely
+	for i, t := range topologies {
+		if t.id == containersID {
+			topologies[i] = mergeTopologyFilters(t, []APITopologyOptionGroup{privilegeFilter})
 		}
 	}
 	return topologies
@@ -203,9 +550,25 @@ func MakeRegistry() *Registry {
 			Options:     unconnectedFilter,
 			HideIfEmpty: true,
 		},
+		APITopologyDesc{
+			id:          processesByTreeID,
+			parent:      processesID,
+			renderer:    render.ProcessTreeRenderer,
+			Name:        "by tree",
+			Options:     unconnectedFilter,
+			HideIfEmpty: true,
+		},
+		APITopologyDesc{
+			id:          processesByUnitID,
+			parent:      processesID,
+			renderer:    render.SystemdUnitRenderer,
+			Name:        "by systemd unit",
+			Options:     unconnectedFilter,
+			HideIfEmpty: true,
+		},
 		APITopologyDesc{
 			id:       containersID,
-			renderer: render.ContainerWithImageNameRenderer,
+			renderer: render.Memoise(render.MergeSidecars(render.ContainerWithImageNameRenderer)),
 			Name:     "Containers",
 			Rank:     2,
 			Options:  containerFilters,
@@ -224,9 +587,25 @@ func MakeRegistry() *Registry {
 			Name:     "by image",
 			Options:  containerFilters,
 		},
+		APITopologyDesc{
+			id:          containersByNetworkID,
+			parent:      containersID,
+			renderer:    render.DockerNetworkRenderer,
+			Name:        "by network",
+			Options:     containerFilters,
+			HideIfEmpty: true,
+		},
+		APITopologyDesc{
+			id:          containersByVolumeID,
+			parent:      containersID,
+			renderer:    render.DockerVolumeRenderer,
+			Name:        "by volume",
+			Options:     containerFilters,
+			HideIfEmpty: true,
+		},
 		APITopologyDesc{
 			id:          podsID,
-			renderer:    render.PodRenderer,
+			renderer:    render.EntryPoints(render.PodRenderer),
 			Name:        "Pods",
 			Rank:        3,
 			Options:     []APITopologyOptionGroup{unmanagedFilter},
@@ -248,6 +627,14 @@ func MakeRegistry() *Registry {
 			Options:     []APITopologyOptionGroup{unmanagedFilter},
 			HideIfEmpty: true,
 		},
+		APITopologyDesc{
+			id:          customResourcesID,
+			parent:      podsID,
+			renderer:    render.CustomResourceRenderer,
+			Name:        "custom resources",
+			Options:     []APITopologyOptionGroup{unmanagedFilter},
+			HideIfEmpty: true,
+		},
 		APITopologyDesc{
 			id:          ecsTasksID,
 			renderer:    render.ECSTaskRenderer,
@@ -264,6 +651,30 @@ func MakeRegistry() *Registry {
 			Options:     []APITopologyOptionGroup{unmanagedFilter},
 			HideIfEmpty: true,
 		},
+		APITopologyDesc{
+			id:          nomadJobsID,
+			renderer:    render.NomadJobRenderer,
+			Name:        "Nomad jobs",
+			Rank:        3,
+			Options:     []APITopologyOptionGroup{unmanagedFilter},
+			HideIfEmpty: true,
+		},
+		APITopologyDesc{
+			id:          nomadTaskGroupsID,
+			parent:      nomadJobsID,
+			renderer:    render.NomadTaskGroupRenderer,
+			Name:        "task groups",
+			Options:     []APITopologyOptionGroup{unmanagedFilter},
+			HideIfEmpty: true,
+		},
+		APITopologyDesc{
+			id:          nomadAllocationsID,
+			parent:      nomadJobsID,
+			renderer:    render.NomadAllocationRenderer,
+			Name:        "allocations",
+			Options:     []APITopologyOptionGroup{unmanagedFilter},
+			HideIfEmpty: true,
+		},
 		APITopologyDesc{
 			id:          swarmServicesID,
 			renderer:    render.SwarmServiceRenderer,
@@ -272,6 +683,30 @@ func MakeRegistry() *Registry {
 			Options:     []APITopologyOptionGroup{unmanagedFilter},
 			HideIfEmpty: true,
 		},
+		APITopologyDesc{
+			id:          swarmTasksID,
+			parent:      swarmServicesID,
+			renderer:    render.SwarmTaskRenderer,
+			Name:        "tasks",
+			Options:     []APITopologyOptionGroup{unmanagedFilter},
+			HideIfEmpty: true,
+		},
+		APITopologyDesc{
+			id:          composeServicesID,
+			renderer:    render.ComposeServiceRenderer,
+			Name:        "Compose services",
+			Rank:        3,
+			Options:     []APITopologyOptionGroup{unmanagedFilter},
+			HideIfEmpty: true,
+		},
+		APITopologyDesc{
+			id:          internetExposedID,
+			parent:      containersID,
+			renderer:    render.InternetExposedRenderer,
+			Name:        "exposed to the internet",
+			Options:     containerFilters,
+			HideIfEmpty: true,
+		},
 		APITopologyDesc{
 			id:       hostsID,
 			renderer: render.HostRenderer,
@@ -324,6 +759,10 @@ type APITopologyOptionGroup struct {
 	SelectType string `json:"selectType,omitempty"`
 	// For "union" type, this is the label the UI should use to represent the case where nothing is selected
 	NoneLabel string `json:"noneLabel,omitempty"`
+
+	// transformer, if any, makes the one transformer of all the options
+	// picked, for options which only make sense together.
+	transformer func(values []string) render.Transformer
 }
 
 // Get the render filters to use for this option group, if any, or nil otherwise.
@@ -368,6 +807,33 @@ func (g APITopologyOptionGroup) filter(value string) render.FilterFunc {
 	return render.AnyFilterFunc(filters...)
 }
 
+// Get the transformers to apply for this option group, beyond its filter.
+func (g APITopologyOptionGroup) transformers(value string) []render.Transformer {
+	values := []string{value}
+	if g.SelectType == "union" {
+		values = strings.Split(value, ",")
+	}
+	var (
+		result []render.Transformer
+		picked []string
+	)
+	for _, opt := range g.Options {
+		for _, v := range values {
+			if v != opt.Value {
+				continue
+			}
+			picked = append(picked, v)
+			if opt.transformer != nil {
+				result = append(result, opt.transformer)
+			}
+		}
+	}
+	if g.transformer != nil && len(picked) > 0 {
+		result = append(result, g.transformer(picked))
+	}
+	return result
+}
+
 // APITopologyOption describes a &param=value to a given topology.
 type APITopologyOption struct {
 	Value string `json:"value"`
@@ -375,6 +841,9 @@ type APITopologyOption struct {
 
 	filter       render.FilterFunc
 	filterPseudo bool
+	// transformer, if any, applies to the whole rendered topology, for
+	// options which depend on more than each node, e.g. on its edges.
+	transformer render.Transformer
 }
 
 type topologyStats struct {
@@ -406,7 +875,7 @@ func AddContainerFilters(newFilters ...APITopologyOption) {
 func (r *Registry) AddContainerFilters(newFilters ...APITopologyOption) {
 	r.Lock()
 	defer r.Unlock()
-	for _, key := range []string{containersID, containersByHostnameID, containersByImageID} {
+	for _, key := range []string{containersID, containersByHostnameID, containersByImageID, containersByNetworkID, containersByVolumeID, internetExposedID} {
 		for i := range r.items[key].Options {
 			if r.items[key].Options[i].ID == systemGroupID {
 				r.items[key].Options[i].Options = append(r.items[key].Options[i].Options, newFilters...)
@@ -520,15 +989,22 @@ func (r *Registry) RendererForTopology(topologyID string, values url.Values, rpt
 		return topology.renderer, render.FilterUnconnectedPseudo, nil
 	}
 
-	var filters []render.FilterFunc
+	var (
+		filters      []render.FilterFunc
+		transformers []render.Transformer
+	)
 	for _, group := range topology.Options {
 		value := values.Get(group.ID)
 		if filter := group.filter(value); filter != nil {
 			filters = append(filters, filter)
 		}
+		transformers = append(transformers, group.transformers(value)...)
 	}
 	if len(filters) > 0 {
-		return topology.renderer, render.Transformers([]render.Transformer{render.ComposeFilterFuncs(filters...), render.FilterUnconnectedPseudo}), nil
+		transformers = append([]render.Transformer{render.ComposeFilterFuncs(filters...)}, transformers...)
+	}
+	if len(transformers) > 0 {
+		return topology.renderer, render.Transformers(append(transformers, render.FilterUnconnectedPseudo)), nil
 	}
 	return topology.renderer, render.FilterUnconnectedPseudo, nil
 }
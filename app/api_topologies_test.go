@@ -10,6 +10,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/ugorji/go/codec"
 	apiv1 "k8s.io/api/core/v1"
+	apinetworkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/weaveworks/common/test"
@@ -41,7 +42,7 @@ func TestAPITopology(t *testing.T) {
 	if err := decoder.Decode(&topologies); err != nil {
 		t.Fatalf("JSON parse error: %s", err)
 	}
-	equals(t, 6, len(topologies))
+	equals(t, 8, len(topologies))
 
 	for _, topology := range topologies {
 		is200(t, ts, topology.URL)
@@ -50,8 +51,8 @@ func TestAPITopology(t *testing.T) {
 			is200(t, ts, subTopology.URL)
 		}
 
-		// TODO: add ECS nodes in report fixture
-		if topology.Name == "Tasks" || topology.Name == "services" {
+		// TODO: add ECS, Compose and Nomad nodes in report fixture
+		if topology.Name == "Tasks" || topology.Name == "services" || topology.Name == "Compose services" || topology.Name == "Nomad jobs" {
 			continue
 		}
 
@@ -201,7 +202,7 @@ func TestAPITopologyAddsKubernetes(t *testing.T) {
 	if err := decoder.Decode(&topologies); err != nil {
 		t.Fatalf("JSON parse error: %s", err)
 	}
-	equals(t, 6, len(topologies))
+	equals(t, 8, len(topologies))
 
 	// Enable the kubernetes topologies
 	rpt := report.MakeReport()
@@ -235,7 +236,7 @@ func TestAPITopologyAddsKubernetes(t *testing.T) {
 	if err := decoder.Decode(&topologies); err != nil {
 		t.Fatalf("JSON parse error: %s", err)
 	}
-	equals(t, 6, len(topologies))
+	equals(t, 8, len(topologies))
 
 	found := false
 	for _, topology := range topologies {
@@ -248,3 +249,152 @@ func TestAPITopologyAddsKubernetes(t *testing.T) {
 		t.Error("Could not find pods topology")
 	}
 }
+
+func TestRendererForTopologyCluster(t *testing.T) {
+	input := fixture.Report.Copy()
+	for id, cluster := range map[string]string{"ns-east": "east", "ns-west": "west"} {
+		input.Namespace.AddNode(report.MakeNodeWith(report.MakeNamespaceNodeID(id), map[string]string{
+			kubernetes.Name:    fixture.KubernetesNamespace,
+			kubernetes.Cluster: cluster,
+		}))
+	}
+	input.Pod.Nodes[fixture.ClientPodNodeID] = input.Pod.Nodes[fixture.ClientPodNodeID].WithLatests(map[string]string{
+		kubernetes.Cluster: "east",
+	})
+	input.Pod.Nodes[fixture.ServerPodNodeID] = input.Pod.Nodes[fixture.ServerPodNodeID].WithLatests(map[string]string{
+		kubernetes.Cluster: "west",
+	})
+
+	topologyRegistry := app.MakeRegistry()
+	urlvalues := url.Values{}
+	urlvalues.Set("cluster", "east")
+	urlvalues.Set("pseudo", "hide")
+	renderer, filter, err := topologyRegistry.RendererForTopology("pods", urlvalues, input)
+	if err != nil {
+		t.Fatalf("Topology Registry Report error: %s", err)
+	}
+
+	have := render.Render(input, renderer, filter).Nodes
+	if _, ok := have[fixture.ClientPodNodeID]; !ok {
+		t.Errorf("Expected pod %s of the cluster to be rendered", fixture.ClientPodNodeID)
+	}
+	if _, ok := have[fixture.ServerPodNodeID]; ok {
+		t.Errorf("Expected pod %s of another cluster not to be rendered", fixture.ServerPodNodeID)
+	}
+}
+
+func TestRendererForTopologyUncoveredTraffic(t *testing.T) {
+	// The client pod of the fixture gets to nothing
+	policies := kubernetes.Policies{{Name: "deny-client-egress", Spec: apinetworkingv1.NetworkPolicySpec{
+		PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "client"}},
+		PolicyTypes: []apinetworkingv1.PolicyType{apinetworkingv1.PolicyTypeEgress},
+	}}}
+	input := fixture.Report.Copy()
+	input.Namespace.AddNode(report.MakeNodeWith(report.MakeNamespaceNodeID("ping"), map[string]string{
+		kubernetes.Name:            fixture.KubernetesNamespace,
+		kubernetes.NetworkPolicies: policies.String(),
+	}))
+	input.Pod.Nodes[fixture.ClientPodNodeID] = input.Pod.Nodes[fixture.ClientPodNodeID].WithLatests(map[string]string{
+		kubernetes.LabelPrefix + "app": "client",
+	})
+
+	topologyRegistry := app.MakeRegistry()
+	urlvalues := url.Values{}
+	urlvalues.Set("policy", "uncovered")
+	renderer, filter, err := topologyRegistry.RendererForTopology("pods", urlvalues, input)
+	if err != nil {
+		t.Fatalf("Topology Registry Report error: %s", err)
+	}
+
+	summaries := detailed.Summaries(detailed.RenderContext{Report: input}, render.Render(input, renderer, render.FilterUnconnectedPseudo).Nodes)
+	if verdict := summaries[fixture.ClientPodNodeID].Policies[fixture.ServerPodNodeID]; verdict != kubernetes.PolicyDenied {
+		t.Errorf("Expected traffic from %s to %s to be denied, got %q", fixture.ClientPodNodeID, fixture.ServerPodNodeID, verdict)
+	}
+	if verdict := summaries[render.IncomingInternetID].Policies[fixture.ServerPodNodeID]; verdict != kubernetes.PolicyUnspecified {
+		t.Errorf("Expected traffic from %s to %s to be unspecified, got %q", render.IncomingInternetID, fixture.ServerPodNodeID, verdict)
+	}
+
+	have := map[string]report.IDList{}
+	for id, n := range render.Render(input, renderer, filter).Nodes {
+		have[id] = n.Adjacency
+	}
+	want := map[string]report.IDList{
+		render.IncomingInternetID: report.MakeIDList(fixture.ServerPodNodeID),
+		fixture.ServerPodNodeID:   report.MakeIDList(),
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
+func TestRendererForTopologyPrivileged(t *testing.T) {
+	input := fixture.Report.Copy()
+	input.ID = "privileged"
+	input.Container.Nodes[fixture.ClientContainerNodeID] = input.Container.Nodes[fixture.ClientContainerNodeID].WithLatests(map[string]string{
+		docker.ContainerPrivileged: "true",
+	})
+	input.Container.Nodes[fixture.ServerContainerNodeID] = input.Container.Nodes[fixture.ServerContainerNodeID].WithLatests(map[string]string{
+		docker.ContainerPrivileged: "false",
+	})
+
+	topologyRegistry := app.MakeRegistry()
+	urlvalues := url.Values{}
+	urlvalues.Set("privileges", "privileged")
+	urlvalues.Set("pseudo", "hide")
+	renderer, filter, err := topologyRegistry.RendererForTopology("containers", urlvalues, input)
+	if err != nil {
+		t.Fatalf("Topology Registry Report error: %s", err)
+	}
+
+	have := render.Render(input, renderer, filter).Nodes
+	if _, ok := have[fixture.ClientContainerNodeID]; !ok {
+		t.Errorf("Expected privileged container %s to be rendered", fixture.ClientContainerNodeID)
+	}
+	if _, ok := have[fixture.ServerContainerNodeID]; ok {
+		t.Errorf("Expected unprivileged container %s not to be rendered", fixture.ServerContainerNodeID)
+	}
+}
+
+func TestRendererForTopologyGroupBy(t *testing.T) {
+	team := kubernetes.LabelPrefix + "team"
+	input := fixture.Report.Copy()
+	input.Pod = input.Pod.WithTableTemplates(kubernetes.TableTemplates)
+	for _, id := range []string{fixture.ClientPodNodeID, fixture.ServerPodNodeID} {
+		input.Pod.Nodes[id] = input.Pod.Nodes[id].WithLatests(map[string]string{team: "shop"})
+	}
+
+	topologyRegistry := app.MakeRegistry()
+	urlvalues := url.Values{}
+	urlvalues.Set("group", team)
+	urlvalues.Set("pseudo", "hide")
+	renderer, filter, err := topologyRegistry.RendererForTopology("pods", urlvalues, input)
+	if err != nil {
+		t.Fatalf("Topology Registry Report error: %s", err)
+	}
+
+	have := render.Render(input, renderer, filter).Nodes
+	for _, id := range []string{fixture.ClientPodNodeID, fixture.ServerPodNodeID} {
+		if _, ok := have[id]; ok {
+			t.Errorf("Expected pod %s to be in its group", id)
+		}
+	}
+	if count, _ := have["shop"].Counters.Lookup(report.Pod); count != 2 {
+		t.Errorf("Expected 2 pods in the group, got %d", count)
+	}
+}
+
+func TestRendererForTopologyHidePorts(t *testing.T) {
+	topologyRegistry := app.MakeRegistry()
+	urlvalues := url.Values{}
+	urlvalues.Set("hide_ports", render.MakePortProtocol(fixture.ServerPort, report.TCP))
+	renderer, filter, err := topologyRegistry.RendererForTopology("containers", urlvalues, fixture.Report)
+	if err != nil {
+		t.Fatalf("Topology Registry Report error: %s", err)
+	}
+
+	for id, n := range render.Render(fixture.Report, renderer, filter).Nodes {
+		if n.Adjacency.Contains(fixture.ServerContainerNodeID) {
+			t.Errorf("Expected the edge from %s to the server hidden", id)
+		}
+	}
+}
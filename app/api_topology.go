@@ -1,21 +1,41 @@
 package app
 
 import (
+	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
+	opentracing "github.com/opentracing/opentracing-go"
 	"golang.org/x/net/context"
 
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/render/layout"
 	"github.com/weaveworks/scope/report"
 )
 
 const (
 	websocketLoop = 1 * time.Second
+
+	// Query parameters used to page through the connection tables of a node
+	connectionsOffsetParam = "connections_offset"
+	connectionsLimitParam  = "connections_limit"
+	// Query parameter asking for a single, bidirectional connections table
+	connectionsMergeParam = "connections_merge"
+	// Query parameter asking for the connection tables to be aggregated
+	// over a longer window than the app's default one, e.g. "15m"
+	connectionsWindowParam = "connections_window"
+	// Query parameters restricting the connection tables to a destination
+	// port or port range (e.g. "80" or "8000-8100"), and to remote
+	// addresses within comma-separated CIDRs
+	connectionsPortParam = "connections_port"
+	connectionsCIDRParam = "connections_cidr"
 )
 
 // APITopology is returned by the /api/topology/{name} handler.
@@ -33,6 +53,13 @@ func RenderContextForReporter(rep Reporter, r report.Report) detailed.RenderCont
 	rc := detailed.RenderContext{Report: r}
 	if wrep, ok := rep.(WebReporter); ok {
 		rc.MetricsGraphURL = wrep.MetricsGraphURL
+		rc.GeoIP = wrep.GeoIP
+		if wrep.Annotations != nil {
+			rc.Annotations = wrep.Annotations.All()
+		}
+		if wrep.Anomalies != nil {
+			rc.Anomalies = wrep.Anomalies.All()
+		}
 	}
 	return rc
 }
@@ -41,18 +68,53 @@ type rendererHandler func(context.Context, render.Renderer, render.Transformer,
 
 // Full topology.
 func handleTopology(ctx context.Context, renderer render.Renderer, transformer render.Transformer, rc detailed.RenderContext, w http.ResponseWriter, r *http.Request) {
-	respondWith(w, http.StatusOK, APITopology{
+	span, _ := opentracing.StartSpanFromContext(ctx, "render topology")
+	topology := APITopology{
 		Nodes: detailed.Summaries(rc, render.Render(rc.Report, renderer, transformer).Nodes),
-	})
+	}
+	span.Finish()
+	respondWith(w, http.StatusOK, topology)
+}
+
+// makeNodeHandler gives handleNode access to the reporter, which it
+// needs for windowed connection tables.
+func makeNodeHandler(rep Reporter) rendererHandler {
+	return func(ctx context.Context, renderer render.Renderer, transformer render.Transformer, rc detailed.RenderContext, w http.ResponseWriter, r *http.Request) {
+		handleNode(ctx, rep, renderer, transformer, rc, w, r)
+	}
 }
 
 // Individual nodes.
-func handleNode(ctx context.Context, renderer render.Renderer, transformer render.Transformer, rc detailed.RenderContext, w http.ResponseWriter, r *http.Request) {
+func handleNode(ctx context.Context, rep Reporter, renderer render.Renderer, transformer render.Transformer, rc detailed.RenderContext, w http.ResponseWriter, r *http.Request) {
+	detailedNode, status, err := renderNode(ctx, rep, renderer, transformer, rc, r)
+	if err != nil {
+		respondWithNodeError(w, r, status, err)
+		return
+	}
+	respondWith(w, http.StatusOK, APINode{Node: detailedNode})
+}
+
+var errNodeNotFound = fmt.Errorf("node not found")
+
+func respondWithNodeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if err == errNodeNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	respondWith(w, status, err)
+}
+
+// renderNode produces the detailed node requested, honouring the
+// connections_* query parameters. On failure, it returns the HTTP
+// status to respond with.
+func renderNode(ctx context.Context, rep Reporter, renderer render.Renderer, transformer render.Transformer, rc detailed.RenderContext, r *http.Request) (detailed.Node, int, error) {
 	var (
 		vars       = mux.Vars(r)
 		topologyID = vars["topology"]
 		nodeID     = vars["id"]
 	)
+	span, ctx := opentracing.StartSpanFromContext(ctx, "render node")
+	defer span.Finish()
 	// We must not lose the node during filtering. We achieve that by
 	// (1) rendering the report with the base renderer, without
 	// filtering, which gives us the node (if it exists at all), and
@@ -61,8 +123,7 @@ func handleNode(ctx context.Context, renderer render.Renderer, transformer rende
 	nodes := renderer.Render(rc.Report)
 	node, ok := nodes.Nodes[nodeID]
 	if !ok {
-		http.NotFound(w, r)
-		return
+		return detailed.Node{}, http.StatusNotFound, errNodeNotFound
 	}
 	nodes = transformer.Transform(nodes)
 	if filteredNode, ok := nodes.Nodes[nodeID]; ok {
@@ -71,7 +132,117 @@ func handleNode(ctx context.Context, renderer render.Renderer, transformer rende
 		nodes.Nodes[nodeID] = node
 		nodes.Filtered--
 	}
-	respondWith(w, http.StatusOK, APINode{Node: detailed.MakeNode(topologyID, rc, nodes.Nodes, node)})
+	offset, limit, err := parseConnectionsPage(r)
+	if err != nil {
+		return detailed.Node{}, http.StatusBadRequest, err
+	}
+	if rc.ConnectionsFilter, err = parseConnectionsFilter(r); err != nil {
+		return detailed.Node{}, http.StatusBadRequest, err
+	}
+	if v := r.URL.Query().Get(connectionsMergeParam); v != "" {
+		if rc.MergeConnections, err = strconv.ParseBool(v); err != nil {
+			return detailed.Node{}, http.StatusBadRequest, fmt.Errorf("invalid value for %s: %q", connectionsMergeParam, v)
+		}
+	}
+	detailedNode := detailed.MakeNode(topologyID, rc, nodes.Nodes, node)
+	if v := r.URL.Query().Get(connectionsWindowParam); v != "" {
+		window, err := time.ParseDuration(v)
+		if err != nil || window <= 0 {
+			return detailed.Node{}, http.StatusBadRequest, fmt.Errorf("invalid value for %s: %q", connectionsWindowParam, v)
+		}
+		connections, err := windowedConnections(ctx, rep, renderer, transformer, rc, topologyID, nodeID, node, window, deserializeTimestamp(r.URL.Query().Get("timestamp")))
+		if err != nil {
+			return detailed.Node{}, http.StatusBadRequest, err
+		}
+		detailedNode.Connections = connections
+	}
+	for i, summary := range detailedNode.Connections {
+		detailedNode.Connections[i] = summary.Page(offset, limit)
+	}
+	return detailedNode, http.StatusOK, nil
+}
+
+// windowedConnections renders the connection tables of a node from the
+// reports received within the given window, rather than from those of
+// the default window. The node itself is kept from the default window,
+// in case it has gone away since.
+func windowedConnections(
+	ctx context.Context,
+	rep Reporter,
+	renderer render.Renderer,
+	transformer render.Transformer,
+	rc detailed.RenderContext,
+	topologyID, nodeID string,
+	node report.Node,
+	window time.Duration,
+	timestamp time.Time,
+) ([]detailed.ConnectionsSummary, error) {
+	wrep, ok := windowedReporter(rep)
+	if !ok {
+		return nil, fmt.Errorf("%s is not supported by this app", connectionsWindowParam)
+	}
+	rpt, err := wrep.ReportWindow(ctx, timestamp, window)
+	if err != nil {
+		return nil, err
+	}
+	rpt.Window = window
+	rc.Report = rpt
+	nodes := render.Render(rpt, renderer, transformer)
+	if windowedNode, ok := nodes.Nodes[nodeID]; ok {
+		node = windowedNode
+	} else {
+		nodes.Nodes[nodeID] = node
+	}
+	return detailed.ConnectionsSummaries(topologyID, rc, nodes.Nodes, node), nil
+}
+
+// parseConnectionsPage extracts the offset and limit for the connection
+// tables from the request. Both default to zero, i.e. all connections.
+func parseConnectionsPage(r *http.Request) (offset, limit int, err error) {
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{
+		{connectionsOffsetParam, &offset},
+		{connectionsLimitParam, &limit},
+	} {
+		v := r.URL.Query().Get(p.name)
+		if v == "" {
+			continue
+		}
+		if *p.value, err = strconv.Atoi(v); err != nil || *p.value < 0 {
+			return 0, 0, fmt.Errorf("invalid value for %s: %q", p.name, v)
+		}
+	}
+	return offset, limit, nil
+}
+
+// parseConnectionsFilter extracts the port range and networks the
+// connection tables should be restricted to from the request.
+func parseConnectionsFilter(r *http.Request) (detailed.ConnectionsFilter, error) {
+	var filter detailed.ConnectionsFilter
+	if v := r.URL.Query().Get(connectionsPortParam); v != "" {
+		min, max := v, v
+		if i := strings.Index(v, "-"); i >= 0 {
+			min, max = v[:i], v[i+1:]
+		}
+		var err1, err2 error
+		filter.MinPort, err1 = strconv.Atoi(min)
+		filter.MaxPort, err2 = strconv.Atoi(max)
+		if err1 != nil || err2 != nil || filter.MinPort < 1 || filter.MaxPort > 65535 || filter.MinPort > filter.MaxPort {
+			return detailed.ConnectionsFilter{}, fmt.Errorf("invalid value for %s: %q", connectionsPortParam, v)
+		}
+	}
+	if v := r.URL.Query().Get(connectionsCIDRParam); v != "" {
+		for _, cidr := range strings.Split(v, ",") {
+			_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+			if err != nil {
+				return detailed.ConnectionsFilter{}, fmt.Errorf("invalid value for %s: %q", connectionsCIDRParam, v)
+			}
+			filter.Networks = append(filter.Networks, network)
+		}
+	}
+	return filter, nil
 }
 
 // Websocket for the full topology.
@@ -93,8 +264,20 @@ func handleWebsocket(
 			return
 		}
 	}
+	algorithm := r.Form.Get("layout")
+	layouts := layoutsForReporter(rep)
+	if algorithm != "" {
+		if layouts == nil {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("topologies aren't laid out by this app"))
+			return
+		}
+		if _, ok := layout.Algorithms[algorithm]; !ok {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("unknown layout: %q", algorithm))
+			return
+		}
+	}
 
-	conn, err := xfer.Upgrade(w, r, nil)
+	conn, err := xfer.UpgradeJSON(w, r)
 	if err != nil {
 		// log.Info("Upgrade:", err)
 		return
@@ -116,6 +299,7 @@ func handleWebsocket(
 
 	var (
 		previousTopo     detailed.NodeSummaries
+		previousLayout   layout.Layout
 		tick             = time.Tick(loop)
 		wait             = make(chan struct{}, 1)
 		topologyID       = mux.Vars(r)["topology"]
@@ -145,9 +329,19 @@ func handleWebsocket(
 			log.Errorf("Error generating report: %v", err)
 			return
 		}
-		newTopo := detailed.Summaries(RenderContextForReporter(rep, re), render.Render(re, renderer, filter).Nodes)
+		span, _ := opentracing.StartSpanFromContext(ctx, "render topology")
+		nodes := render.Render(re, renderer, filter).Nodes
+		newTopo := detailed.Summaries(RenderContextForReporter(rep, re), nodes)
+		span.Finish()
 		diff := detailed.TopoDiff(previousTopo, newTopo)
 		previousTopo = newTopo
+		if algorithm != "" {
+			span, _ := opentracing.StartSpanFromContext(ctx, "lay out topology")
+			newLayout := layouts.Layout(topologyID, algorithm, r.Form, nodes)
+			span.Finish()
+			diff.Layout = layout.Moved(previousLayout, newLayout)
+			previousLayout = newLayout
+		}
 
 		if err := conn.WriteJSON(diff); err != nil {
 			if !xfer.IsExpectedWSCloseError(err) {
@@ -164,3 +358,118 @@ func handleWebsocket(
 		}
 	}
 }
+
+// Websocket for the details of an individual node. The first message
+// carries the node in full; later ones only the changes, with the rows
+// of the connection tables diffed individually.
+func handleNodeWebsocket(
+	ctx context.Context,
+	rep Reporter,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if err := r.ParseForm(); err != nil {
+		respondWith(w, http.StatusInternalServerError, err)
+		return
+	}
+	var (
+		topologyID = mux.Vars(r)["topology"]
+		loop       = websocketLoop
+	)
+	if _, ok := topologyRegistry.get(topologyID); !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if t := r.Form.Get("t"); t != "" {
+		var err error
+		if loop, err = time.ParseDuration(t); err != nil {
+			respondWith(w, http.StatusBadRequest, t)
+			return
+		}
+	}
+
+	var (
+		startReportingAt = deserializeTimestamp(r.Form.Get("timestamp"))
+		channelOpenedAt  = time.Now()
+	)
+	renderCurrent := func() (detailed.Node, int, error) {
+		reportTimestamp := startReportingAt.Add(time.Since(channelOpenedAt))
+		re, err := rep.Report(ctx, reportTimestamp)
+		if err != nil {
+			return detailed.Node{}, http.StatusInternalServerError, err
+		}
+		renderer, filter, err := topologyRegistry.RendererForTopology(topologyID, r.Form, re)
+		if err != nil {
+			return detailed.Node{}, http.StatusInternalServerError, err
+		}
+		return renderNode(ctx, rep, renderer, filter, RenderContextForReporter(rep, re), r)
+	}
+
+	// Render once before upgrading, so bad requests get a proper response
+	node, status, err := renderCurrent()
+	if err != nil {
+		respondWithNodeError(w, r, status, err)
+		return
+	}
+
+	conn, err := xfer.UpgradeJSON(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	quit := make(chan struct{})
+	go func(c xfer.Websocket) {
+		for { // just discard everything the browser sends
+			if _, _, err := c.ReadMessage(); err != nil {
+				if !xfer.IsExpectedWSCloseError(err) {
+					log.Error("err:", err)
+				}
+				close(quit)
+				break
+			}
+		}
+	}(conn)
+
+	var (
+		previous *detailed.Node
+		tick     = time.Tick(loop)
+		wait     = make(chan struct{}, 1)
+	)
+	rep.WaitOn(ctx, wait)
+	defer rep.UnWait(ctx, wait)
+
+	for {
+		var diff detailed.NodeDiff
+		switch {
+		case err == nil:
+			diff = detailed.NodeDetailsDiff(previous, node)
+			previous = &node
+		case err == errNodeNotFound && previous != nil:
+			// The node has gone, possibly only for now; if it comes
+			// back, start afresh.
+			diff = detailed.NodeDiff{Reset: true}
+			previous = nil
+		case err != errNodeNotFound:
+			log.Errorf("Error rendering node: %v", err)
+			return
+		}
+
+		if !diff.Empty() {
+			if err := conn.WriteJSON(diff); err != nil {
+				if !xfer.IsExpectedWSCloseError(err) {
+					log.Errorf("cannot serialize node diff: %s", err)
+				}
+				return
+			}
+		}
+
+		select {
+		case <-wait:
+		case <-tick:
+		case <-quit:
+			return
+		}
+		node, _, err = renderCurrent()
+	}
+}
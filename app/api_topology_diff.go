@@ -0,0 +1,201 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+// Query parameters of the topology diff handler, both ISO8601
+// timestamps. to defaults to now.
+const (
+	diffFromParam = "from"
+	diffToParam   = "to"
+)
+
+// APITopologyDiff is returned by the /api/diff/{topology} handler. It
+// describes what changed in a topology between two points in time.
+type APITopologyDiff struct {
+	From         time.Time              `json:"from"`
+	To           time.Time              `json:"to"`
+	Added        []detailed.NodeSummary `json:"added"`
+	Removed      []detailed.NodeSummary `json:"removed"`
+	Updated      []APINodeDiff          `json:"updated"`
+	AddedEdges   []APIEdge              `json:"added_edges"`
+	RemovedEdges []APIEdge              `json:"removed_edges"`
+}
+
+// APINodeDiff lists the metadata changes of a node present at both
+// points in time.
+type APINodeDiff struct {
+	ID      string              `json:"id"`
+	Label   string              `json:"label"`
+	Changes []APIMetadataChange `json:"changes"`
+}
+
+// APIMetadataChange is a metadata row which changed. From or To are
+// empty when the row was added or removed.
+type APIMetadataChange struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+// APIEdge is an edge between two nodes of a topology.
+type APIEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// makeTopologyDiff returns a handler that yields an APITopologyDiff.
+func (r *Registry) makeTopologyDiff(rep Reporter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+		topologyID := mux.Vars(req)["topology"]
+		if _, ok := r.get(topologyID); !ok {
+			http.NotFound(w, req)
+			return
+		}
+		query := req.URL.Query()
+		if query.Get(diffFromParam) == "" {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("missing %s parameter", diffFromParam))
+			return
+		}
+		from, err := parseTimestamp(query.Get(diffFromParam))
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		to, err := parseTimestamp(query.Get(diffToParam))
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+
+		req.ParseForm()
+		fromNodes, err := r.renderSummaries(ctx, rep, topologyID, req.Form, from)
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		toNodes, err := r.renderSummaries(ctx, rep, topologyID, req.Form, to)
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		diff := diffTopologies(fromNodes, toNodes)
+		diff.From, diff.To = from, to
+		respondWith(w, http.StatusOK, diff)
+	}
+}
+
+// parseTimestamp parses an ISO8601 timestamp, defaulting to now when it
+// is empty. Unlike deserializeTimestamp, it fails on invalid ones.
+func parseTimestamp(timestamp string) (time.Time, error) {
+	if timestamp == "" {
+		return time.Now(), nil
+	}
+	result, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return result, fmt.Errorf("invalid timestamp %q: %v", timestamp, err)
+	}
+	return result, nil
+}
+
+// renderSummaries renders a topology as it was at the given time.
+func (r *Registry) renderSummaries(ctx context.Context, rep Reporter, topologyID string, values url.Values, timestamp time.Time) (detailed.NodeSummaries, error) {
+	rpt, err := rep.Report(ctx, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	renderer, filter, err := r.RendererForTopology(topologyID, values, rpt)
+	if err != nil {
+		return nil, err
+	}
+	return detailed.Summaries(RenderContextForReporter(rep, rpt), render.Render(rpt, renderer, filter).Nodes), nil
+}
+
+func diffTopologies(from, to detailed.NodeSummaries) APITopologyDiff {
+	diff := APITopologyDiff{
+		Added:        []detailed.NodeSummary{},
+		Removed:      []detailed.NodeSummary{},
+		Updated:      []APINodeDiff{},
+		AddedEdges:   []APIEdge{},
+		RemovedEdges: []APIEdge{},
+	}
+	for _, id := range sortedSummaryIDs(to) {
+		node := to[id]
+		old, ok := from[id]
+		if !ok {
+			diff.Added = append(diff.Added, node)
+			continue
+		}
+		if changes := diffMetadata(old.Metadata, node.Metadata); len(changes) > 0 {
+			diff.Updated = append(diff.Updated, APINodeDiff{ID: id, Label: node.Label, Changes: changes})
+		}
+	}
+	for _, id := range sortedSummaryIDs(from) {
+		if _, ok := to[id]; !ok {
+			diff.Removed = append(diff.Removed, from[id])
+		}
+	}
+	diff.AddedEdges = edgesMissing(to, from)
+	diff.RemovedEdges = edgesMissing(from, to)
+	return diff
+}
+
+func sortedSummaryIDs(nodes detailed.NodeSummaries) []string {
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// diffMetadata compares metadata rows by ID, in the order of the newer
+// ones followed by those which were removed.
+func diffMetadata(from, to []report.MetadataRow) []APIMetadataChange {
+	var (
+		changes []APIMetadataChange
+		old     = map[string]report.MetadataRow{}
+	)
+	for _, row := range from {
+		old[row.ID] = row
+	}
+	for _, row := range to {
+		if oldRow, ok := old[row.ID]; !ok || oldRow.Value != row.Value {
+			changes = append(changes, APIMetadataChange{ID: row.ID, Label: row.Label, From: oldRow.Value, To: row.Value})
+		}
+		delete(old, row.ID)
+	}
+	for _, row := range from {
+		if _, ok := old[row.ID]; ok {
+			changes = append(changes, APIMetadataChange{ID: row.ID, Label: row.Label, From: row.Value})
+		}
+	}
+	return changes
+}
+
+// edgesMissing returns the edges of a which aren't in b.
+func edgesMissing(a, b detailed.NodeSummaries) []APIEdge {
+	result := []APIEdge{}
+	for _, id := range sortedSummaryIDs(a) {
+		for _, target := range a[id].Adjacency {
+			if other, ok := b[id]; ok && other.Adjacency.Contains(target) {
+				continue
+			}
+			result = append(result, APIEdge{Source: id, Target: target})
+		}
+	}
+	return result
+}
@@ -0,0 +1,66 @@
+package app_test
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestAPITopologyDiff(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	var (
+		ctx     = context.Background()
+		c       = app.NewCollectorWithHistory(10*time.Second, time.Hour)
+		hostA   = report.MakeHostNodeID("a")
+		hostB   = report.MakeHostNodeID("b")
+		hostRpt = func(nodes ...report.Node) report.Report {
+			rpt := report.MakeReport()
+			for _, n := range nodes {
+				rpt.Host.AddNode(n.WithTopology(report.Host))
+			}
+			rpt.Host = rpt.Host.WithMetadataTemplates(host.MetadataTemplates)
+			return rpt
+		}
+	)
+	c.Add(ctx, hostRpt(report.MakeNodeWith(hostA, map[string]string{host.OS: "linux"})), nil)
+	mtime.NowForce(now.Add(time.Minute))
+	c.Add(ctx, hostRpt(
+		report.MakeNodeWith(hostA, map[string]string{host.OS: "darwin"}),
+		report.MakeNodeWith(hostB, map[string]string{host.OS: "linux"}),
+	), nil)
+
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, c, map[string]bool{})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	is404(t, ts, "/api/diff/foobar?from="+url.QueryEscape(now.Format(time.RFC3339)))
+	is400(t, ts, "/api/diff/hosts")
+	is400(t, ts, "/api/diff/hosts?from=yesterday")
+
+	var diff app.APITopologyDiff
+	body := getRawJSON(t, ts, "/api/diff/hosts?from="+url.QueryEscape(now.Format(time.RFC3339))+
+		"&to="+url.QueryEscape(now.Add(time.Minute).Format(time.RFC3339)))
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&diff); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, 1, len(diff.Added))
+	equals(t, hostB, diff.Added[0].ID)
+	equals(t, 0, len(diff.Removed))
+	equals(t, 1, len(diff.Updated))
+	equals(t, hostA, diff.Updated[0].ID)
+	equals(t, []app.APIMetadataChange{{ID: host.OS, Label: "OS", From: "linux", To: "darwin"}}, diff.Updated[0].Changes)
+}
@@ -2,15 +2,25 @@ package app_test
 
 import (
 	"fmt"
+	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"github.com/klauspost/compress/zstd"
 	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
 
+	"github.com/weaveworks/common/mtime"
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/render/detailed"
 	"github.com/weaveworks/scope/render/expected"
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/fixture"
 )
 
@@ -117,6 +127,112 @@ func TestAPITopologyHosts(t *testing.T) {
 	}
 }
 
+func TestAPITopologyNodeConnectionsPaging(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	nodeURL := "/api/topology/containers/" + url.QueryEscape(fixture.ServerContainerNodeID)
+	is400(t, ts, nodeURL+"?connections_limit=foo")
+	is400(t, ts, nodeURL+"?connections_offset=-1")
+
+	getNode := func(query string) app.APINode {
+		body := getRawJSON(t, ts, nodeURL+query)
+		var node app.APINode
+		decoder := codec.NewDecoderBytes(body, &codec.JsonHandle{})
+		if err := decoder.Decode(&node); err != nil {
+			t.Fatal(err)
+		}
+		return node
+	}
+
+	all := getNode("")
+	incoming := all.Node.Connections[0]
+	equals(t, 2, incoming.Total)
+	equals(t, 2, len(incoming.Connections))
+
+	first := getNode("?connections_limit=1").Node.Connections[0]
+	equals(t, 2, first.Total)
+	equals(t, []detailed.Connection{incoming.Connections[0]}, first.Connections)
+
+	second := getNode("?connections_offset=1&connections_limit=1").Node.Connections[0]
+	equals(t, 2, second.Total)
+	equals(t, 1, second.Offset)
+	equals(t, []detailed.Connection{incoming.Connections[1]}, second.Connections)
+
+	rest := getNode("?connections_offset=1&connections_limit=" + strconv.Itoa(int(^uint(0)>>1))).Node.Connections[0]
+	equals(t, []detailed.Connection{incoming.Connections[1]}, rest.Connections)
+
+	beyond := getNode("?connections_offset=5").Node.Connections[0]
+	equals(t, 2, beyond.Total)
+	equals(t, 0, len(beyond.Connections))
+}
+
+func TestAPITopologyNodeConnectionsWindow(t *testing.T) {
+	nodeURL := "/api/topology/containers/" + url.QueryEscape(fixture.ServerContainerNodeID)
+
+	// The static collector has no history to aggregate over
+	ts := topologyServer()
+	is400(t, ts, nodeURL+"?connections_window=1m")
+	ts.Close()
+
+	// Connections were only seen 30s ago, outside the default window
+	ctx := context.Background()
+	c := app.NewCollectorWithHistory(15*time.Second, time.Minute)
+	mtime.NowForce(time.Now().Add(-30 * time.Second))
+	c.Add(ctx, fixture.Report, nil)
+	mtime.NowReset()
+	withoutEndpoints := fixture.Report.Copy()
+	withoutEndpoints.Endpoint = report.MakeTopology()
+	c.Add(ctx, withoutEndpoints, nil)
+
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, c, map[string]bool{})
+	ts = httptest.NewServer(router)
+	defer ts.Close()
+	is400(t, ts, nodeURL+"?connections_window=foo")
+	is400(t, ts, nodeURL+"?connections_window=-1m")
+
+	getNode := func(query string) app.APINode {
+		body := getRawJSON(t, ts, nodeURL+query)
+		var node app.APINode
+		decoder := codec.NewDecoderBytes(body, &codec.JsonHandle{})
+		if err := decoder.Decode(&node); err != nil {
+			t.Fatal(err)
+		}
+		return node
+	}
+
+	equals(t, 0, len(getNode("").Node.Connections[0].Connections))
+	windowed := getNode("?connections_window=1m").Node.Connections[0]
+	equals(t, 2, len(windowed.Connections))
+}
+
+func TestAPITopologyNodeConnectionsFilter(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	nodeURL := "/api/topology/containers/" + url.QueryEscape(fixture.ServerContainerNodeID)
+	is400(t, ts, nodeURL+"?connections_port=foo")
+	is400(t, ts, nodeURL+"?connections_port=90-80")
+	is400(t, ts, nodeURL+"?connections_port=0")
+	is400(t, ts, nodeURL+"?connections_cidr=10.0.0.0")
+
+	incoming := func(query string) []detailed.Connection {
+		body := getRawJSON(t, ts, nodeURL+query)
+		var node app.APINode
+		decoder := codec.NewDecoderBytes(body, &codec.JsonHandle{})
+		if err := decoder.Decode(&node); err != nil {
+			t.Fatal(err)
+		}
+		return node.Node.Connections[0].Connections
+	}
+
+	equals(t, 2, len(incoming("?connections_port=80")))
+	equals(t, 2, len(incoming("?connections_port=1-1024")))
+	equals(t, 0, len(incoming("?connections_port=81-90")))
+	internet := incoming("?connections_cidr=" + url.QueryEscape("172.16.0.0/12, 51.52.0.0/16"))
+	equals(t, 1, len(internet))
+	equals(t, fixture.RandomClientIP, internet[0].Label)
+}
+
 // Basic websocket test
 func TestAPITopologyWebsocket(t *testing.T) {
 	ts := topologyServer()
@@ -152,4 +268,120 @@ func TestAPITopologyWebsocket(t *testing.T) {
 	equals(t, 0, len(d.Remove))
 }
 
+func TestAPITopologyWebsocketLayout(t *testing.T) {
+	router := mux.NewRouter()
+	app.RegisterTopologyRoutes(router, app.WebReporter{Reporter: app.StaticCollector(fixture.Report), Layouts: app.NewLayouts()}, map[string]bool{})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+	is400(t, ts, "/api/topology/processes/ws?layout=nonesuch")
+
+	ts.URL = "ws" + ts.URL[len("http"):]
+	ws, _, err := (&websocket.Dialer{}).Dial(ts.URL+"/api/topology/processes/ws?layout=layered", nil)
+	ok(t, err)
+	defer ws.Close()
+
+	_, p, err := ws.ReadMessage()
+	ok(t, err)
+	var d detailed.Diff
+	if err := codec.NewDecoderBytes(p, &codec.JsonHandle{}).Decode(&d); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	for _, n := range d.Add {
+		if _, ok := d.Layout[n.ID]; !ok {
+			t.Errorf("%s not laid out: %v", n.ID, d.Layout)
+		}
+	}
+
+	// Layouts are only for apps asked to lay out topologies
+	plain := topologyServer()
+	defer plain.Close()
+	is400(t, plain, "/api/topology/processes/ws?layout=force")
+}
+
+func TestAPISnapshot(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	for format, contentType := range map[string]string{"": "image/svg+xml", "png": "image/png", "html": "text/html; charset=utf-8"} {
+		res, body := checkGet(t, ts, "/api/snapshot/containers?layout=layered&format="+format)
+		equals(t, 200, res.StatusCode)
+		equals(t, contentType, res.Header.Get("Content-Type"))
+		if len(body) == 0 {
+			t.Errorf("%s: no snapshot", format)
+		}
+	}
+	is400(t, ts, "/api/snapshot/containers?format=gif")
+	is400(t, ts, "/api/snapshot/containers?layout=nonesuch")
+	is404(t, ts, "/api/snapshot/nonesuch")
+}
+
+func TestAPIDiagram(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	for format, prefix := range map[string]string{"": "digraph \"Containers\" {", "d2": "title: \"Containers\"", "mermaid": "---\ntitle: \"Containers\""} {
+		res, body := checkGet(t, ts, "/api/diagram/containers?format="+format)
+		equals(t, 200, res.StatusCode)
+		if !strings.HasPrefix(string(body), prefix) {
+			t.Errorf("%s: unexpected diagram: %s", format, body)
+		}
+	}
+	is400(t, ts, "/api/diagram/containers?format=gif")
+	is404(t, ts, "/api/diagram/nonesuch")
+}
+
+func TestAPITopologyWebsocketZstd(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	ts.URL = "ws" + ts.URL[len("http"):]
+
+	dialer := &websocket.Dialer{Subprotocols: []string{xfer.ZstdWebsocketProtocol}}
+	ws, res, err := dialer.Dial(ts.URL+"/api/topology/processes/ws", nil)
+	ok(t, err)
+	defer ws.Close()
+	equals(t, xfer.ZstdWebsocketProtocol, res.Header.Get("Sec-Websocket-Protocol"))
+
+	messageType, p, err := ws.ReadMessage()
+	ok(t, err)
+	equals(t, websocket.BinaryMessage, messageType)
+	decoder, err := zstd.NewReader(nil)
+	ok(t, err)
+	defer decoder.Close()
+	p, err = decoder.DecodeAll(p, nil)
+	ok(t, err)
+	var d detailed.Diff
+	if err := codec.NewDecoderBytes(p, &codec.JsonHandle{}).Decode(&d); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	equals(t, 6, len(d.Add))
+}
+
+func TestAPITopologyNodeWebsocket(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	nodeURL := "/api/topology/containers/" + url.QueryEscape(fixture.ServerContainerNodeID) + "/ws"
+	is404(t, ts, "/api/topology/containers/foo/ws")
+	is400(t, ts, nodeURL+"?connections_port=foo")
+
+	ts.URL = "ws" + ts.URL[len("http"):]
+	dialer := &websocket.Dialer{}
+	ws, res, err := dialer.Dial(ts.URL+nodeURL, nil)
+	ok(t, err)
+	defer ws.Close()
+	equals(t, 101, res.StatusCode)
+
+	_, p, err := ws.ReadMessage()
+	ok(t, err)
+	var d detailed.NodeDiff
+	decoder := codec.NewDecoderBytes(p, &codec.JsonHandle{})
+	if err := decoder.Decode(&d); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	equals(t, true, d.Reset)
+	assert(t, d.Node != nil, "expected the node in the first message")
+	equals(t, fixture.ServerContainerNodeID, d.Node.ID)
+	equals(t, 0, len(d.Node.Connections))
+	equals(t, "incoming-connections", d.Connections[0].ID)
+	assert(t, d.Connections[0].Summary != nil, "expected the header of the connections table")
+	equals(t, 2, len(d.Connections[0].Add))
+}
+
 func newu64(value uint64) *uint64 { return &value }
@@ -0,0 +1,427 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+const (
+	// maxAuditEvents is how many of the last events the audit log keeps in
+	// memory to query; the file has them all.
+	maxAuditEvents = 10000
+
+	// maxAuditPipes bounds the controls remembered for the pipes they
+	// opened, in case the pipes are never connected to.
+	maxAuditPipes = 1000
+
+	defaultAuditQueryLimit = 100
+)
+
+// Kinds of audit events
+const (
+	AuditControl   = "control"
+	AuditPipeStart = "pipe_start"
+	AuditPipeEnd   = "pipe_end"
+)
+
+// AuditEvent is a record of the audit log: a control invoked, or a pipe,
+// e.g. a terminal, opened or closed by a user.
+type AuditEvent struct {
+	Time       time.Time         `json:"time"`
+	Kind       string            `json:"kind"`
+	User       string            `json:"user,omitempty"` // as told by the Policy
+	RemoteAddr string            `json:"remote_addr,omitempty"`
+	ProbeID    string            `json:"probe_id,omitempty"`
+	NodeID     string            `json:"node_id,omitempty"`
+	Control    string            `json:"control,omitempty"`
+	Args       map[string]string `json:"args,omitempty"`
+	Result     string            `json:"result,omitempty"` // "ok" or the error of a control
+	PipeID     string            `json:"pipe_id,omitempty"`
+
+	// Of the ends of pipes
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	BytesIn         int64   `json:"bytes_in,omitempty"`  // from the user
+	BytesOut        int64   `json:"bytes_out,omitempty"` // to the user
+	Transcript      string  `json:"transcript,omitempty"`
+}
+
+// AuditQuery selects audit events. Zero fields select all.
+type AuditQuery struct {
+	Since, Until time.Time
+	Kind         string
+	User         string
+	NodeID       string
+	Limit        int // of the last events selected
+}
+
+func (q AuditQuery) matches(e AuditEvent) bool {
+	return (q.Since.IsZero() || !e.Time.Before(q.Since)) &&
+		(q.Until.IsZero() || e.Time.Before(q.Until)) &&
+		(q.Kind == "" || e.Kind == q.Kind) &&
+		(q.User == "" || e.User == q.User) &&
+		(q.NodeID == "" || e.NodeID == q.NodeID)
+}
+
+// AuditLog records who invoked which controls, and opened which pipes, to a
+// file of JSON lines, keeping the last events in memory to query. Pipes'
+// output can be kept too, as transcripts.
+type AuditLog struct {
+	transcripts string
+
+	mtx    sync.Mutex
+	file   *os.File
+	events []AuditEvent
+	pipes  map[string]AuditEvent // the controls which opened pipes, by pipe ID
+}
+
+// NewAuditLog opens the audit log in the given file, reading back the last
+// events it has. Transcripts of pipes are written to the given directory,
+// unless empty.
+func NewAuditLog(path, transcripts string) (*AuditLog, error) {
+	l := &AuditLog{
+		transcripts: transcripts,
+		pipes:       map[string]AuditEvent{},
+	}
+	if transcripts != "" {
+		if err := os.MkdirAll(transcripts, 0700); err != nil {
+			return nil, err
+		}
+	}
+	if err := l.load(path); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	l.file = f
+	return l, nil
+}
+
+func (l *AuditLog) load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e AuditEvent
+		if err := codec.NewDecoderBytes(scanner.Bytes(), &codec.JsonHandle{}).Decode(&e); err != nil {
+			return fmt.Errorf("error reading audit log %s, line %d: %v", path, line, err)
+		}
+		l.append(e)
+	}
+	return scanner.Err()
+}
+
+// append adds an event to the ones in memory. Called with l.mtx held, or
+// before l is shared.
+func (l *AuditLog) append(e AuditEvent) {
+	if len(l.events) >= maxAuditEvents {
+		l.events = append(l.events[:0], l.events[len(l.events)-maxAuditEvents+1:]...)
+	}
+	l.events = append(l.events, e)
+}
+
+// Record adds an event to the audit log.
+func (l *AuditLog) Record(e AuditEvent) {
+	if e.Time.IsZero() {
+		e.Time = mtime.Now()
+	}
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, &codec.JsonHandle{}).Encode(e); err != nil {
+		log.Errorf("Error encoding audit event: %v", err)
+		return
+	}
+	buf = append(buf, '\n')
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if _, err := l.file.Write(buf); err != nil {
+		log.Errorf("Error writing audit log: %v", err)
+	}
+	l.append(e)
+}
+
+// Query returns the events selected, oldest first.
+func (l *AuditLog) Query(q AuditQuery) []AuditEvent {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	result := []AuditEvent{}
+	for _, e := range l.events {
+		if q.matches(e) {
+			result = append(result, e)
+		}
+	}
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[len(result)-q.Limit:]
+	}
+	return result
+}
+
+// Close closes the file of the audit log.
+func (l *AuditLog) Close() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.file.Close()
+}
+
+// rememberPipe keeps the control which opened a pipe, for the events of
+// the pipe.
+func (l *AuditLog) rememberPipe(e AuditEvent) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if len(l.pipes) >= maxAuditPipes {
+		for id := range l.pipes {
+			delete(l.pipes, id)
+			break
+		}
+	}
+	l.pipes[e.PipeID] = e
+}
+
+func (l *AuditLog) pipeControl(pipeID string, forget bool) AuditEvent {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	e := l.pipes[pipeID]
+	if forget {
+		delete(l.pipes, pipeID)
+	}
+	return e
+}
+
+// auditRequest returns who made the request of a context, and from where.
+func auditRequest(ctx context.Context) (user, remoteAddr string) {
+	if r, ok := ctx.Value(RequestCtxKey).(*http.Request); ok {
+		return requestUser(r), r.RemoteAddr
+	}
+	return "", ""
+}
+
+// NewAuditedControlRouter makes a ControlRouter recording the controls
+// handled by the given one in the audit log.
+func NewAuditedControlRouter(cr ControlRouter, l *AuditLog) ControlRouter {
+	return &auditedControlRouter{ControlRouter: cr, log: l}
+}
+
+type auditedControlRouter struct {
+	ControlRouter
+	log *AuditLog
+}
+
+func (cr *auditedControlRouter) Handle(ctx context.Context, probeID string, req xfer.Request) (xfer.Response, error) {
+	res, err := cr.ControlRouter.Handle(ctx, probeID, req)
+	e := AuditEvent{
+		Kind:    AuditControl,
+		ProbeID: probeID,
+		NodeID:  req.NodeID,
+		Control: req.Control,
+		Args:    req.ControlArgs,
+		Result:  "ok",
+		PipeID:  res.Pipe,
+	}
+	e.User, e.RemoteAddr = auditRequest(ctx)
+	if err != nil {
+		e.Result = err.Error()
+	} else if res.Error != "" {
+		e.Result = res.Error
+	}
+	cr.log.Record(e)
+	if res.Pipe != "" {
+		cr.log.rememberPipe(e)
+	}
+	return res, err
+}
+
+// NewAuditedPipeRouter makes a PipeRouter recording the sessions of users on
+// the pipes of the given one in the audit log, with their transcripts if
+// the log keeps them.
+func NewAuditedPipeRouter(pr PipeRouter, l *AuditLog) PipeRouter {
+	return &auditedPipeRouter{
+		PipeRouter: pr,
+		log:        l,
+		sessions:   map[pipeSessionKey]*pipeSession{},
+	}
+}
+
+type auditedPipeRouter struct {
+	PipeRouter
+	log *AuditLog
+
+	mtx      sync.Mutex
+	sessions map[pipeSessionKey]*pipeSession
+}
+
+// pipeSessionKey tells apart the sessions of several users on a pipe, by
+// their requests.
+type pipeSessionKey struct {
+	id      string
+	request interface{}
+}
+
+type pipeSession struct {
+	io.ReadWriter
+	start             time.Time
+	event             AuditEvent
+	bytesIn, bytesOut int64
+
+	mtx        sync.Mutex
+	transcript *os.File
+}
+
+// Read is the output of the pipe to the user.
+func (s *pipeSession) Read(p []byte) (int, error) {
+	n, err := s.ReadWriter.Read(p)
+	atomic.AddInt64(&s.bytesOut, int64(n))
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.transcript != nil && n > 0 {
+		if _, err := s.transcript.Write(p[:n]); err != nil {
+			log.Errorf("Error writing transcript of pipe %s: %v", s.event.PipeID, err)
+			s.closeTranscript()
+		}
+	}
+	return n, err
+}
+
+// closeTranscript is called with s.mtx held.
+func (s *pipeSession) closeTranscript() {
+	if s.transcript != nil {
+		s.transcript.Close()
+		s.transcript = nil
+	}
+}
+
+// Write is the input of the user to the pipe.
+func (s *pipeSession) Write(p []byte) (int, error) {
+	n, err := s.ReadWriter.Write(p)
+	atomic.AddInt64(&s.bytesIn, int64(n))
+	return n, err
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+func (pr *auditedPipeRouter) Get(ctx context.Context, id string, e End) (xfer.Pipe, io.ReadWriter, error) {
+	pipe, endIO, err := pr.PipeRouter.Get(ctx, id, e)
+	if err != nil || e != UIEnd {
+		return pipe, endIO, err
+	}
+	control := pr.log.pipeControl(id, false)
+	s := &pipeSession{
+		ReadWriter: endIO,
+		start:      mtime.Now(),
+		event: AuditEvent{
+			Kind:    AuditPipeStart,
+			ProbeID: control.ProbeID,
+			NodeID:  control.NodeID,
+			Control: control.Control,
+			PipeID:  id,
+		},
+	}
+	s.event.User, s.event.RemoteAddr = auditRequest(ctx)
+	if pr.log.transcripts != "" {
+		name := fmt.Sprintf("%s-%d.log", unsafeFileChars.ReplaceAllString(id, "_"), s.start.UnixNano())
+		path := filepath.Join(pr.log.transcripts, name)
+		if f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600); err != nil {
+			log.Errorf("Error creating transcript of pipe %s: %v", id, err)
+		} else {
+			s.transcript = f
+			s.event.Transcript = path
+		}
+	}
+	pr.log.Record(s.event)
+
+	pr.mtx.Lock()
+	pr.sessions[pipeSessionKey{id, ctx.Value(RequestCtxKey)}] = s
+	pr.mtx.Unlock()
+	return pipe, s, nil
+}
+
+func (pr *auditedPipeRouter) Release(ctx context.Context, id string, e End) error {
+	err := pr.PipeRouter.Release(ctx, id, e)
+	if e != UIEnd {
+		return err
+	}
+	key := pipeSessionKey{id, ctx.Value(RequestCtxKey)}
+	pr.mtx.Lock()
+	s, ok := pr.sessions[key]
+	delete(pr.sessions, key)
+	pr.mtx.Unlock()
+	if !ok {
+		return err
+	}
+	s.mtx.Lock()
+	s.closeTranscript()
+	s.mtx.Unlock()
+	end := s.event
+	end.Time = time.Time{}
+	end.Kind = AuditPipeEnd
+	end.DurationSeconds = mtime.Now().Sub(s.start).Seconds()
+	end.BytesIn = atomic.LoadInt64(&s.bytesIn)
+	end.BytesOut = atomic.LoadInt64(&s.bytesOut)
+	pr.log.Record(end)
+	return err
+}
+
+func (pr *auditedPipeRouter) Delete(ctx context.Context, id string) error {
+	pr.log.pipeControl(id, true)
+	return pr.PipeRouter.Delete(ctx, id)
+}
+
+// RegisterAuditRoutes registers the route querying the audit log, e.g.
+// /api/audit?since=2006-01-02T15:04:05Z&kind=control&user=alice&node=...&limit=100
+func RegisterAuditRoutes(router *mux.Router, l *AuditLog) {
+	router.Methods("GET").
+		Name("api_audit").
+		Path("/api/audit").
+		HandlerFunc(requestContextDecorator(handleAuditQuery(l)))
+}
+
+func handleAuditQuery(l *AuditLog) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		values := r.URL.Query()
+		q := AuditQuery{
+			Kind:   values.Get("kind"),
+			User:   values.Get("user"),
+			NodeID: values.Get("node"),
+			Limit:  defaultAuditQueryLimit,
+		}
+		var err error
+		for param, t := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+			if v := values.Get(param); v != "" {
+				if *t, err = time.Parse(time.RFC3339, v); err != nil {
+					respondWith(w, http.StatusBadRequest, fmt.Errorf("invalid %s: %v", param, err))
+					return
+				}
+			}
+		}
+		if v := values.Get("limit"); v != "" {
+			if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 0 {
+				respondWith(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+				return
+			}
+		}
+		respondWith(w, http.StatusOK, l.Query(q))
+	}
+}
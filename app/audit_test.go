@@ -0,0 +1,160 @@
+package app
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	audit, err := NewAuditLog(path, filepath.Join(dir, "transcripts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cr := NewAuditedControlRouter(NewLocalControlRouter(), audit)
+	pr := NewAuditedPipeRouter(NewLocalPipeRouter(), audit)
+	defer pr.Stop()
+	if _, err := cr.Register(context.Background(), "probe", func(req xfer.Request) xfer.Response {
+		if req.Control == "exec" {
+			return xfer.Response{Pipe: "pipe1"}
+		}
+		return xfer.ResponseErrorf("no such control")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests as let through by an Authorizer, with who made them.
+	request := func() context.Context {
+		r := httptest.NewRequest("POST", "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), userCtxKey, "alice"))
+		return context.WithValue(context.Background(), RequestCtxKey, r)
+	}
+	if _, err := cr.Handle(request(), "probe", xfer.Request{NodeID: "node", Control: "stop"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cr.Handle(request(), "probe", xfer.Request{NodeID: "node", Control: "exec", ControlArgs: map[string]string{"cmd": "sh"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := request()
+	_, ui, err := pr.Get(ctx, "pipe1", UIEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, probe, err := pr.Get(context.Background(), "pipe1", ProbeEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go probe.Write([]byte("$ "))
+	buf := make([]byte, 2)
+	if _, err := ui.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	go probe.Read(make([]byte, 3))
+	if _, err := ui.Write([]byte("ls\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := pr.Release(ctx, "pipe1", UIEnd); err != nil {
+		t.Fatal(err)
+	}
+	if err := audit.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The events are read back when the log is opened again.
+	audit, err = NewAuditLog(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Close()
+	events := audit.Query(AuditQuery{User: "alice"})
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %v", events)
+	}
+	if e := events[0]; e.Kind != AuditControl || e.Control != "stop" || e.Result != "no such control" || e.RemoteAddr == "" {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if e := events[1]; e.Kind != AuditControl || e.Result != "ok" || e.PipeID != "pipe1" || e.Args["cmd"] != "sh" {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if e := events[2]; e.Kind != AuditPipeStart || e.NodeID != "node" || e.Control != "exec" {
+		t.Errorf("unexpected event %+v", e)
+	}
+	end := events[3]
+	if end.Kind != AuditPipeEnd || end.NodeID != "node" || end.BytesIn != 3 || end.BytesOut != 2 || end.Transcript == "" {
+		t.Errorf("unexpected event %+v", end)
+	}
+	if transcript, err := ioutil.ReadFile(end.Transcript); err != nil || string(transcript) != "$ " {
+		t.Errorf("unexpected transcript %q: %v", transcript, err)
+	}
+	if events := audit.Query(AuditQuery{Kind: AuditControl, Limit: 1}); len(events) != 1 || events[0].Control != "exec" {
+		t.Errorf("unexpected events %v", events)
+	}
+
+	// Querying over HTTP
+	router := mux.NewRouter()
+	RegisterAuditRoutes(router, audit)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	for query, expected := range map[string]int{
+		"":                            4,
+		"?kind=pipe_end":              1,
+		"?node=other":                 0,
+		"?since=2100-01-01T00:00:00Z": 0,
+		"?limit=2":                    2,
+	} {
+		resp, err := http.Get(server.URL + "/api/audit" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var events []AuditEvent
+		err = codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(&events)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != expected {
+			t.Errorf("%q: expected %d events, got %d", query, expected, len(events))
+		}
+	}
+	if resp, err := http.Get(server.URL + "/api/audit?since=yesterday"); err != nil {
+		t.Fatal(err)
+	} else if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestAuthorizerUser(t *testing.T) {
+	policy := &staticPolicy{
+		Tokens: map[string]Role{"a": RoleOperator, "b": RoleOperator},
+		Names:  map[string]string{"a": "alice"},
+	}
+	var user string
+	handler := Authorizer{Policy: policy}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user = requestUser(r)
+	}))
+	for token, expected := range map[string]string{"a": "alice", "b": "token:3e23e816"} {
+		r := httptest.NewRequest("POST", "/api/control/probe/node/stop", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if user != expected {
+			t.Errorf("expected user %q, got %q", expected, user)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package app
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/probe/host"
+)
+
+// Role is what a user of the app is allowed to do. Every role is allowed
+// what the ones before it are.
+type Role int
+
+// Roles, from least to most allowed.
+const (
+	RoleNone     Role = iota // unauthenticated
+	RoleViewer               // see topologies
+	RoleOperator             // invoke node controls, open terminals
+	RoleAdmin                // invoke plugin controls, profile the app and probes
+)
+
+var roleNames = map[Role]string{
+	RoleNone:     "none",
+	RoleViewer:   "viewer",
+	RoleOperator: "operator",
+	RoleAdmin:    "admin",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// ParseRole parses the name of a role.
+func ParseRole(name string) (Role, error) {
+	for role, n := range roleNames {
+		if n == name {
+			return role, nil
+		}
+	}
+	return RoleNone, fmt.Errorf("unknown role %q", name)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (r *Role) UnmarshalText(text []byte) error {
+	role, err := ParseRole(string(text))
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
+
+// Policy tells the role of the user making a request. Requests which don't
+// authenticate have RoleNone, unless the policy has a role for anyone.
+// Errors are for credentials which don't hold.
+type Policy interface {
+	Role(r *http.Request) (Role, error)
+}
+
+// identifier is implemented by Policies which can tell who makes requests,
+// for the audit log.
+type identifier interface {
+	User(r *http.Request) string
+}
+
+// userCtxKey is the key of the user making a request, in its context.
+const userCtxKey contextKey = contextKey("user")
+
+// requestUser returns the user an Authorizer found making a request, if
+// any.
+func requestUser(r *http.Request) string {
+	user, _ := r.Context().Value(userCtxKey).(string)
+	return user
+}
+
+// requestToken returns the bearer token of a request, or else the token of
+// its session, if any.
+func requestToken(r *http.Request) string {
+	const prefix = "Bearer "
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, prefix) {
+		return strings.TrimSpace(strings.TrimPrefix(header, prefix))
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// staticPolicy gives roles to bearer tokens, as listed in a file.
+type staticPolicy struct {
+	Default Role              `json:"default"` // of requests without a token
+	Tokens  map[string]Role   `json:"tokens"`
+	Names   map[string]string `json:"names"` // of the users of tokens, optional
+}
+
+// NewStaticPolicy reads a Policy from a JSON file giving roles to bearer
+// tokens, and optionally to requests without any, e.g.
+//
+//	{"default": "viewer", "tokens": {"s3cr3t": "admin"}, "names": {"s3cr3t": "alice"}}
+func NewStaticPolicy(path string) (Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var p staticPolicy
+	if err := codec.NewDecoder(f, &codec.JsonHandle{}).Decode(&p); err != nil {
+		return nil, fmt.Errorf("error reading policy %s: %v", path, err)
+	}
+	return &p, nil
+}
+
+func (p *staticPolicy) Role(r *http.Request) (Role, error) {
+	token := requestToken(r)
+	if token == "" {
+		return p.Default, nil
+	}
+	role, ok := p.Tokens[token]
+	if !ok {
+		return RoleNone, fmt.Errorf("unknown token")
+	}
+	return role, nil
+}
+
+// User returns the name of the user of the token of a request, or else a
+// digest of the token, which doesn't give it away.
+func (p *staticPolicy) User(r *http.Request) string {
+	token := requestToken(r)
+	if token == "" {
+		return ""
+	}
+	if name, ok := p.Names[token]; ok {
+		return name
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:4])
+}
+
+// probeAuthorization is the Authorization header of probes, with their
+// token, see probe/appclient.
+const probeAuthorization = "Scope-Probe token="
+
+// isProbeRequest tells whether a request is one only probes make, rather
+// than users.
+func isProbeRequest(r *http.Request) bool {
+	path := r.URL.Path
+	return r.Method == "POST" && path == "/api/report" ||
+		path == "/api/control/ws" ||
+		strings.HasPrefix(path, "/api/pipe/") && strings.HasSuffix(path, "/probe")
+}
+
+// isSharedProbeRequest tells whether a request is one probes make, which
+// users can too: probes share DNS resolutions, and close their pipes.
+func isSharedProbeRequest(r *http.Request) bool {
+	path := r.URL.Path
+	return path == "/api/dns" ||
+		r.Method == "DELETE" && strings.HasPrefix(path, "/api/pipe/")
+}
+
+// isVerifiedProbe tells whether a request is from a probe the app can
+// trust: one which gave a verified client certificate, or the token of
+// probes, if any.
+func isVerifiedProbe(r *http.Request, probeToken string) bool {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return true
+	}
+	header := r.Header.Get("Authorization")
+	return probeToken != "" &&
+		subtle.ConstantTimeCompare([]byte(header), []byte(probeAuthorization+probeToken)) == 1
+}
+
+// adminControls are the node controls only admins can invoke: those
+// profiling probes, as /api/profile/ does the app.
+var adminControls = map[string]struct{}{
+	host.ProfileProbeCPU:  {},
+	host.ProfileProbeHeap: {},
+}
+
+// requiredRole returns the role needed for a request, or false for requests
+// from probes, which aren't users.
+func requiredRole(r *http.Request, probeToken string) (Role, bool) {
+	path := r.URL.Path
+	switch {
+	case isProbeRequest(r), isSharedProbeRequest(r) && isVerifiedProbe(r, probeToken):
+		return RoleNone, false
+	case strings.HasPrefix(path, "/api/control/"):
+		// Plugin controls are prefixed with their plugin ID, see
+		// probe/plugins.
+		parts := strings.Split(path, "/")
+		control := parts[len(parts)-1]
+		if _, ok := adminControls[control]; ok || strings.Contains(control, "~") {
+			return RoleAdmin, true
+		}
+		return RoleOperator, true
+	case strings.HasPrefix(path, "/api/pipe/"):
+		return RoleOperator, true
+	case strings.HasPrefix(path, "/api/annotations/") && r.Method != "GET",
+		strings.HasPrefix(path, "/api/views/") && r.Method != "GET",
+		path == "/api/exe-hashes/list" && r.Method != "GET",
+		strings.HasPrefix(path, "/api/alerts/rules") && r.Method != "GET",
+		path == "/api/dns" && r.Method != "GET":
+		return RoleOperator, true
+	case strings.HasPrefix(path, "/debug/"), path == "/api/audit", strings.HasPrefix(path, "/api/recordings"),
+		strings.HasPrefix(path, "/api/profile/"):
+		return RoleAdmin, true
+	case strings.HasPrefix(path, "/api"), strings.HasPrefix(path, "/views/"), path == "/metrics":
+		return RoleViewer, true
+	}
+	// The UI itself is no secret.
+	return RoleNone, true
+}
+
+// Authorizer is middleware letting through only the requests of users with
+// the role they need, by its Policy. Requests let through carry who made
+// them, if the Policy can tell.
+type Authorizer struct {
+	Policy Policy
+	// Optional; the token of probes, which lets them share DNS resolutions
+	// and close pipes without a client certificate.
+	ProbeToken string
+}
+
+// Wrap implements middleware.Interface
+func (a Authorizer) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required, ok := requiredRole(r, a.ProbeToken)
+		if !ok || required == RoleNone {
+			next.ServeHTTP(w, r)
+			return
+		}
+		role, err := a.Policy.Role(r)
+		if err != nil || role == RoleNone {
+			if err == nil {
+				err = fmt.Errorf("authentication required")
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scope"`)
+			respondWith(w, http.StatusUnauthorized, err)
+			return
+		}
+		if role < required {
+			respondWith(w, http.StatusForbidden, fmt.Errorf("%s role required, have %s", required, role))
+			return
+		}
+		if ider, ok := a.Policy.(identifier); ok {
+			if user := ider.User(r); user != "" {
+				r = r.WithContext(context.WithValue(r.Context(), userCtxKey, user))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ProbeCertificates is middleware letting through only the requests of
+// probes which gave a verified client certificate, so not just anyone can
+// publish reports, or take the other end of controls and pipes.
+type ProbeCertificates struct{}
+
+// Wrap implements middleware.Interface
+func (ProbeCertificates) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isProbeRequest(r) && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			respondWith(w, http.StatusUnauthorized, fmt.Errorf("client certificate required"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,238 @@
+package app
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/common/mtime"
+)
+
+const (
+	oidcTimeout = 10 * time.Second
+
+	// oidcRefreshInterval bounds how often the keys of the issuer are
+	// fetched again, on tokens signed with keys we don't know.
+	oidcRefreshInterval = time.Minute
+)
+
+// OIDCConfig configures a Policy giving roles to the users of ID tokens, by
+// their claims.
+type OIDCConfig struct {
+	IssuerURL string
+	ClientID  string          // the audience of the tokens
+	Claim     string          // e.g. "groups"
+	Roles     map[string]Role // by value of Claim
+	Default   Role            // of users with no value of Claim in Roles
+}
+
+type oidcPolicy struct {
+	config OIDCConfig
+	client *http.Client
+
+	mtx         sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]*rsa.PublicKey // by key ID
+	lastRefresh time.Time
+}
+
+// oidcDiscovery is the part of the OIDC discovery document of the issuer we
+// use.
+type oidcDiscovery struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewOIDCPolicy makes a Policy out of OIDC ID tokens, passed as bearer
+// tokens, e.g. by an authenticating proxy in front of the app, or kept in
+// the sessions of an OIDCLogin. The tokens are checked against the keys of
+// the issuer, fetched as needed.
+func NewOIDCPolicy(config OIDCConfig) (Policy, error) {
+	if config.IssuerURL == "" || config.ClientID == "" {
+		return nil, fmt.Errorf("OIDC issuer and client ID required")
+	}
+	return &oidcPolicy{
+		config: config,
+		client: &http.Client{Timeout: oidcTimeout},
+		keys:   map[string]*rsa.PublicKey{},
+	}, nil
+}
+
+func (p *oidcPolicy) Role(r *http.Request) (Role, error) {
+	token := requestToken(r)
+	if token == "" {
+		return RoleNone, nil
+	}
+	claims, err := p.validate(token)
+	if err != nil {
+		return RoleNone, err
+	}
+
+	role := p.config.Default
+	var values []string
+	switch v := claims[p.config.Claim].(type) {
+	case string:
+		values = []string{v}
+	case []interface{}:
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	for _, v := range values {
+		if r, ok := p.config.Roles[v]; ok && r > role {
+			role = r
+		}
+	}
+	return role, nil
+}
+
+// User returns who the ID token of a request is of, by their email,
+// username or else subject.
+func (p *oidcPolicy) User(r *http.Request) string {
+	token := requestToken(r)
+	if token == "" {
+		return ""
+	}
+	claims, err := p.validate(token)
+	if err != nil {
+		return ""
+	}
+	for _, claim := range []string{"email", "preferred_username", "sub"} {
+		if v, ok := claims[claim].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// validate checks an ID token, returning its claims.
+func (p *oidcPolicy) validate(token string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, p.key); err != nil {
+		return nil, err
+	}
+	if !claims.VerifyIssuer(p.config.IssuerURL, true) {
+		return nil, fmt.Errorf("token of another issuer")
+	}
+	if !hasAudience(claims, p.config.ClientID) {
+		return nil, fmt.Errorf("token for another audience")
+	}
+	return claims, nil
+}
+
+// hasAudience is MapClaims.VerifyAudience, for lists of audiences too.
+func hasAudience(claims jwt.MapClaims, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// key is the jwt.Keyfunc of ID tokens.
+func (p *oidcPolicy) key(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Header["alg"])
+	}
+	kid, _ := token.Header["kid"].(string)
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if mtime.Now().Sub(p.lastRefresh) < oidcRefreshInterval {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	p.lastRefresh = mtime.Now()
+	keys, err := p.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// discover returns the discovery document of the issuer, fetching it the
+// first time. Called with p.mtx held.
+func (p *oidcPolicy) discover() (*oidcDiscovery, error) {
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	var discovery oidcDiscovery
+	if err := p.getJSON(strings.TrimSuffix(p.config.IssuerURL, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// fetchKeys gets the RSA keys of the issuer. Called with p.mtx held.
+func (p *oidcPolicy) fetchKeys() (map[string]*rsa.PublicKey, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("bad key %q: %v", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("bad key %q: %v", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+func (p *oidcPolicy) getJSON(url string, v interface{}) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(v)
+}
@@ -0,0 +1,239 @@
+package app_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/weaveworks/scope/app"
+)
+
+func checkAuthorization(t *testing.T, policy app.Policy, token, method, path string, want int) {
+	handler := app.Authorizer{Policy: policy, ProbeToken: "t"}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(method, path, nil)
+	if strings.HasPrefix(token, "Scope-Probe ") {
+		req.Header.Set("Authorization", token)
+	} else if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != want {
+		t.Errorf("%s %s with token %q: want %d, have %d", method, path, token, want, w.Code)
+	}
+}
+
+func TestStaticPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "authz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policy.json")
+	if err := ioutil.WriteFile(path, []byte(`{"default": "viewer", "tokens": {"op": "operator", "root": "admin"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := app.NewStaticPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		token, method, path string
+		want                int
+	}{
+		{"", "GET", "/api/topology/containers", http.StatusOK},
+		{"", "GET", "/", http.StatusOK},
+		{"", "POST", "/api/report", http.StatusOK},
+		{"", "GET", "/api/pipe/p/probe", http.StatusOK},
+		{"bad", "GET", "/api/topology", http.StatusUnauthorized},
+		{"", "POST", "/api/control/probe/node/docker_stop_container", http.StatusForbidden},
+		{"op", "POST", "/api/control/probe/node/docker_stop_container", http.StatusOK},
+		{"op", "GET", "/api/pipe/p", http.StatusOK},
+		{"op", "POST", "/api/control/probe/node/traffic-control~set", http.StatusForbidden},
+		{"root", "POST", "/api/control/probe/node/traffic-control~set", http.StatusOK},
+		{"", "POST", "/api/control/batch/docker_restart_container", http.StatusForbidden},
+		{"op", "POST", "/api/control/batch/docker_restart_container", http.StatusOK},
+		{"op", "POST", "/api/control/batch/traffic-control~set", http.StatusForbidden},
+		{"root", "POST", "/api/control/batch/traffic-control~set", http.StatusOK},
+		{"", "GET", "/api/annotations/n", http.StatusOK},
+		{"", "PUT", "/api/annotations/n", http.StatusForbidden},
+		{"op", "PUT", "/api/annotations/n", http.StatusOK},
+		{"bad", "GET", "/views/v", http.StatusUnauthorized},
+		{"", "PUT", "/api/views/v", http.StatusForbidden},
+		{"op", "PUT", "/api/views/v", http.StatusOK},
+		{"", "GET", "/api/exe-hashes/list", http.StatusOK},
+		{"", "PUT", "/api/exe-hashes/list", http.StatusForbidden},
+		{"op", "PUT", "/api/exe-hashes/list", http.StatusOK},
+		{"op", "GET", "/debug/pprof/", http.StatusForbidden},
+		{"root", "GET", "/debug/pprof/", http.StatusOK},
+		{"", "GET", "/api/alerts/rules", http.StatusOK},
+		{"", "POST", "/api/alerts/rules", http.StatusForbidden},
+		{"", "PUT", "/api/alerts/rules/r", http.StatusForbidden},
+		{"", "DELETE", "/api/alerts/rules/r", http.StatusForbidden},
+		{"op", "DELETE", "/api/alerts/rules/r", http.StatusOK},
+		{"", "GET", "/api/dns", http.StatusOK},
+		{"", "POST", "/api/dns", http.StatusForbidden},
+		{"op", "POST", "/api/dns", http.StatusOK},
+		{"", "DELETE", "/api/pipe/p", http.StatusForbidden},
+		// Probes, which aren't users
+		{"Scope-Probe token=t", "GET", "/api/dns", http.StatusOK},
+		{"Scope-Probe token=t", "POST", "/api/dns", http.StatusOK},
+		{"Scope-Probe token=t", "DELETE", "/api/pipe/p", http.StatusOK},
+		{"Scope-Probe token=t", "GET", "/api/pipe/p", http.StatusForbidden},
+		{"Scope-Probe token=forged", "POST", "/api/dns", http.StatusForbidden},
+		{"Scope-Probe token=forged", "DELETE", "/api/pipe/p", http.StatusForbidden},
+		{"Scope-Probe token=tt", "DELETE", "/api/pipe/p", http.StatusForbidden},
+		{"op", "GET", "/api/recordings", http.StatusForbidden},
+		{"root", "GET", "/api/recordings/r", http.StatusOK},
+		{"op", "GET", "/api/profile/heap", http.StatusForbidden},
+		{"op", "POST", "/api/control/probe/node/host_profile_probe_cpu", http.StatusForbidden},
+		{"root", "POST", "/api/control/probe/node/host_profile_probe_heap", http.StatusOK},
+		{"op", "POST", "/api/control/batch/host_profile_probe_heap", http.StatusForbidden},
+		{"root", "GET", "/api/profile/heap", http.StatusOK},
+	} {
+		checkAuthorization(t, policy, c.token, c.method, c.path, c.want)
+	}
+}
+
+// fakeIssuer is an OIDC issuer, of the ID tokens it signs, and of codes
+// which are the nonces of the ID tokens to issue for them.
+type fakeIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	t   *testing.T
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := &fakeIssuer{key: key, t: t}
+	i.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer": %q, "authorization_endpoint": "%s/auth", "token_endpoint": "%s/token", "jwks_uri": "%s/keys"}`, i.URL, i.URL, i.URL, i.URL)
+		case "/keys":
+			fmt.Fprintf(w, `{"keys": [{"kty": "RSA", "kid": "k1", "n": %q, "e": %q}]}`,
+				base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()))
+		case "/token":
+			if user, secret, _ := r.BasicAuth(); user != "scope" || secret != "secret" {
+				http.Error(w, `{"error": "invalid_client"}`, http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprintf(w, `{"id_token": %q}`, i.sign(jwt.MapClaims{
+				"aud": "scope", "groups": "ops", "nonce": r.FormValue("code"),
+			}))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return i
+}
+
+// sign signs an ID token, valid for an hour unless given otherwise.
+func (i *fakeIssuer) sign(claims jwt.MapClaims) string {
+	claims["iss"] = i.URL
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "k1"
+	s, err := token.SignedString(i.key)
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	return s
+}
+
+func oidcConfig(issuer string) app.OIDCConfig {
+	return app.OIDCConfig{
+		IssuerURL: issuer,
+		ClientID:  "scope",
+		Claim:     "groups",
+		Roles:     map[string]app.Role{"ops": app.RoleOperator},
+		Default:   app.RoleViewer,
+	}
+}
+
+func TestOIDCPolicy(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.Close()
+	policy, err := app.NewOIDCPolicy(oidcConfig(issuer.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		viewer   = issuer.sign(jwt.MapClaims{"aud": "scope", "groups": []string{"devs"}})
+		operator = issuer.sign(jwt.MapClaims{"aud": []string{"other", "scope"}, "groups": []string{"devs", "ops"}})
+		expired  = issuer.sign(jwt.MapClaims{"aud": "scope", "exp": time.Now().Add(-time.Hour).Unix(), "groups": "ops"})
+		audience = issuer.sign(jwt.MapClaims{"aud": "other", "groups": "ops"})
+	)
+
+	const control = "/api/control/probe/node/docker_stop_container"
+	checkAuthorization(t, policy, "", "GET", "/api/topology", http.StatusUnauthorized)
+	checkAuthorization(t, policy, viewer, "GET", "/api/topology", http.StatusOK)
+	checkAuthorization(t, policy, viewer, "POST", control, http.StatusForbidden)
+	checkAuthorization(t, policy, operator, "POST", control, http.StatusOK)
+	checkAuthorization(t, policy, expired, "POST", control, http.StatusUnauthorized)
+	checkAuthorization(t, policy, audience, "POST", control, http.StatusUnauthorized)
+}
+
+// rolePolicy gives everyone a role.
+type rolePolicy app.Role
+
+func (p rolePolicy) Role(*http.Request) (app.Role, error) {
+	return app.Role(p), nil
+}
+
+func TestProbeCertificates(t *testing.T) {
+	// As in prog/app.go, without a probe token
+	handler := app.ProbeCertificates{}.Wrap(app.Authorizer{Policy: rolePolicy(app.RoleViewer)}.Wrap(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	for _, c := range []struct {
+		method, path    string
+		probe, verified bool
+		want            int
+	}{
+		{"POST", "/api/report", false, false, http.StatusUnauthorized},
+		{"POST", "/api/report", false, true, http.StatusOK},
+		{"GET", "/api/control/ws", false, false, http.StatusUnauthorized},
+		{"GET", "/api/pipe/p/probe", false, false, http.StatusUnauthorized},
+		{"GET", "/api/pipe/p", false, false, http.StatusForbidden},
+		{"GET", "/api/topology", false, false, http.StatusOK},
+		{"POST", "/api/dns", true, false, http.StatusForbidden},
+		{"POST", "/api/dns", true, true, http.StatusOK},
+		{"DELETE", "/api/pipe/p", true, false, http.StatusForbidden},
+		{"DELETE", "/api/pipe/p", true, true, http.StatusOK},
+		{"DELETE", "/api/pipe/p", false, false, http.StatusForbidden}, // by viewers
+	} {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		if c.probe {
+			req.Header.Set("Authorization", "Scope-Probe token=t")
+		}
+		if c.verified {
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != c.want {
+			t.Errorf("%s %s, probe %v, verified %v: want %d, have %d", c.method, c.path, c.probe, c.verified, c.want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+// maxBatchControlConcurrency is how many nodes of a batch are controlled
+// at once.
+const maxBatchControlConcurrency = 10
+
+// BatchControlRequest is a request to invoke a control on a set of nodes:
+// those of its IDs, or, if none, all the nodes of a topology with its
+// options (i.e. filters) which have the control.
+type BatchControlRequest struct {
+	NodeIDs  []string            `json:"nodeIds,omitempty"`
+	Topology string              `json:"topology,omitempty"`
+	Options  map[string][]string `json:"options,omitempty"` // e.g. {"namespace": ["prod"]}, as the UI has them
+	Args     map[string]string   `json:"args,omitempty"`
+}
+
+// BatchControlResult is the result of a control of a node of a batch.
+type BatchControlResult struct {
+	NodeID   string         `json:"nodeId"`
+	ProbeID  string         `json:"probeId,omitempty"`
+	Response *xfer.Response `json:"response,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// BatchControlResponse has the results of a batch by node, and counts, so
+// partial failures stand out.
+type BatchControlResponse struct {
+	Control   string               `json:"control"`
+	Results   []BatchControlResult `json:"results"`
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+}
+
+// RegisterBatchControlRoutes registers the route to invoke a control on a
+// set of nodes. The control is in the path, so plugin controls need the
+// roles they do alone.
+func RegisterBatchControlRoutes(router *mux.Router, rep Reporter, cr ControlRouter) {
+	router.
+		Methods("POST").
+		MatcherFunc(URLMatcher("/api/control/batch/{control}")).
+		HandlerFunc(requestContextDecorator(handleBatchControl(rep, cr)))
+}
+
+func handleBatchControl(rep Reporter, cr ControlRouter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		control := mux.Vars(r)["control"]
+		var batch BatchControlRequest
+		err := codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&batch)
+		defer r.Body.Close()
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(batch.NodeIDs) == 0 && batch.Topology == "" {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("neither node IDs nor a topology"))
+			return
+		}
+		rpt, err := rep.Report(ctx, deserializeTimestamp(r.URL.Query().Get("timestamp")))
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		nodeIDs := batch.NodeIDs
+		if len(nodeIDs) == 0 {
+			if nodeIDs, err = nodesWithControl(rpt, batch.Topology, batch.Options, control); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		respondWith(w, http.StatusOK, batchControl(ctx, cr, rpt, control, nodeIDs, batch.Args))
+	}
+}
+
+// nodesWithControl returns the IDs of the nodes of a rendered topology
+// which have a control.
+func nodesWithControl(rpt report.Report, topologyID string, options map[string][]string, control string) ([]string, error) {
+	renderer, filter, err := topologyRegistry.RendererForTopology(topologyID, url.Values(options), rpt)
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for id, n := range render.Render(rpt, renderer, filter).Nodes {
+		if t, ok := rpt.Topology(n.Topology); ok {
+			if _, ok := controlProbeID(t, id, control); ok {
+				result = append(result, id)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// controlProbeID returns the ID of the probe to invoke a control of a node
+// of a topology with, if the node has the control, and it is not dead.
+func controlProbeID(t report.Topology, nodeID, control string) (string, bool) {
+	n, ok := t.Nodes[nodeID]
+	if !ok {
+		return "", false
+	}
+	if _, ok := t.Controls[control]; !ok {
+		return "", false
+	}
+	probeID, ok := n.Latest.Lookup(report.ControlProbeID)
+	if !ok {
+		return "", false
+	}
+	data, ok := n.LatestControls.Lookup(control)
+	if !ok || data.Dead {
+		return "", false
+	}
+	return probeID, true
+}
+
+// batchControl invokes a control on nodes, a few at a time, with the
+// probes which report them, returning the results in the order of the
+// nodes.
+func batchControl(ctx context.Context, cr ControlRouter, rpt report.Report, control string, nodeIDs []string, args map[string]string) BatchControlResponse {
+	var (
+		results = make([]BatchControlResult, len(nodeIDs))
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, maxBatchControlConcurrency)
+	)
+	for i, nodeID := range nodeIDs {
+		probeID, found := "", false
+		rpt.WalkTopologies(func(t *report.Topology) {
+			if !found {
+				probeID, found = controlProbeID(*t, nodeID, control)
+			}
+		})
+		if !found {
+			results[i] = BatchControlResult{NodeID: nodeID, Error: "control not available on node"}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, nodeID, probeID string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			result := BatchControlResult{NodeID: nodeID, ProbeID: probeID}
+			res, err := cr.Handle(ctx, probeID, xfer.Request{
+				NodeID:      nodeID,
+				Control:     control,
+				ControlArgs: args,
+			})
+			switch {
+			case err != nil:
+				result.Error = err.Error()
+			case res.Error != "":
+				result.Error = res.Error
+			default:
+				result.Response = &res
+			}
+			results[i] = result
+		}(i, nodeID, probeID)
+	}
+	wg.Wait()
+
+	response := BatchControlResponse{Control: control, Results: results}
+	for _, r := range results {
+		if r.Error != "" {
+			response.Failed++
+		} else {
+			response.Succeeded++
+		}
+	}
+	return response
+}
@@ -0,0 +1,89 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestAPIBatchControl(t *testing.T) {
+	rpt := report.MakeReport()
+	rpt.Container.Controls.AddControl(report.Control{ID: docker.RestartContainer, Human: "Restart"})
+	for id, c := range map[string]struct {
+		probeID string
+		dead    bool
+	}{
+		"web":  {"probe1", false},
+		"db":   {"probe2", false},
+		"old":  {"probe1", true},
+		"gone": {"probe3", false},
+	} {
+		rpt.Container.AddNode(report.MakeNodeWith(report.MakeContainerNodeID(id), map[string]string{
+			docker.ContainerID:    id,
+			report.ControlProbeID: c.probeID,
+		}).WithTopology(report.Container).WithLatestControls(map[string]report.NodeControlData{
+			docker.RestartContainer: {Dead: c.dead},
+		}))
+	}
+
+	cr := app.NewLocalControlRouter()
+	var (
+		mtx       sync.Mutex
+		restarted = map[string]string{}
+	)
+	for _, probeID := range []string{"probe1", "probe2"} {
+		probeID := probeID
+		cr.Register(context.Background(), probeID, func(req xfer.Request) xfer.Response {
+			if req.NodeID == report.MakeContainerNodeID("db") {
+				return xfer.ResponseErrorf("database is busy")
+			}
+			mtx.Lock()
+			defer mtx.Unlock()
+			restarted[req.NodeID] = probeID + " " + req.ControlArgs["reason"]
+			return xfer.Response{}
+		})
+	}
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterBatchControlRoutes(router, app.StaticCollector(rpt), cr)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, body := checkRequest(t, ts, "POST", "/api/control/batch/"+docker.RestartContainer, []byte(`{"nodeIds": ["web;<container>", "db;<container>", "old;<container>", "gone;<container>"], "args": {"reason": "upgrade"}}`))
+	equals(t, http.StatusOK, res.StatusCode)
+	var batch app.BatchControlResponse
+	ok(t, codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&batch))
+	equals(t, 1, batch.Succeeded)
+	equals(t, 3, batch.Failed)
+	errors := map[string]string{}
+	for _, r := range batch.Results {
+		errors[r.NodeID] = r.Error
+	}
+	equals(t, map[string]string{
+		"web;<container>":  "",
+		"db;<container>":   "database is busy",
+		"old;<container>":  "control not available on node",
+		"gone;<container>": "probe probe3 is not connected right now",
+	}, errors)
+	equals(t, map[string]string{"web;<container>": "probe1 upgrade"}, restarted)
+
+	// All the nodes of a topology with the control
+	res, body = checkRequest(t, ts, "POST", "/api/control/batch/"+docker.RestartContainer, []byte(`{"topology": "containers", "options": {"system": ["all"]}}`))
+	equals(t, http.StatusOK, res.StatusCode)
+	batch = app.BatchControlResponse{}
+	ok(t, codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&batch))
+	equals(t, 1, batch.Succeeded)
+	equals(t, 2, batch.Failed) // not the dead one
+
+	res, _ = checkRequest(t, ts, "POST", "/api/control/batch/"+docker.RestartContainer, []byte(`{}`))
+	equals(t, http.StatusBadRequest, res.StatusCode)
+}
@@ -0,0 +1,368 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ghodss/yaml"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+// Annotations of the catalog entities exported, linking them back to the
+// nodes they are of.
+const (
+	CatalogNodeIDAnnotation   = "weave.works/scope-node-id"
+	CatalogTopologyAnnotation = "weave.works/scope-topology"
+)
+
+const (
+	catalogAPIVersion    = "backstage.io/v1alpha1"
+	catalogNamespace     = "default"
+	catalogPushTimeout   = 30 * time.Second
+	defaultCatalogFile   = "catalog-info.yaml"
+	maxCatalogNameLength = 63
+)
+
+// CatalogEntity is an entity of a Backstage service catalog, as in its
+// catalog-info.yaml files.
+type CatalogEntity struct {
+	APIVersion string          `json:"apiVersion"`
+	Kind       string          `json:"kind"` // Component or API
+	Metadata   CatalogMetadata `json:"metadata"`
+	Spec       CatalogSpec     `json:"spec"`
+}
+
+// CatalogMetadata is the metadata of a CatalogEntity.
+type CatalogMetadata struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// CatalogSpec is the spec of a CatalogEntity. Relations are by entity
+// reference, e.g. component:default/web.
+type CatalogSpec struct {
+	Type         string   `json:"type"`
+	Lifecycle    string   `json:"lifecycle"`
+	Owner        string   `json:"owner"`
+	DependsOn    []string `json:"dependsOn,omitempty"`
+	ProvidesAPIs []string `json:"providesApis,omitempty"`
+	ConsumesAPIs []string `json:"consumesApis,omitempty"`
+	Definition   string   `json:"definition,omitempty"` // of APIs
+}
+
+func (e CatalogEntity) ref() string {
+	return strings.ToLower(e.Kind) + ":" + e.Metadata.Namespace + "/" + e.Metadata.Name
+}
+
+var invalidCatalogName = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// catalogName makes a valid name of an entity of a label: letters, digits
+// and -_. up to 63 characters, starting and ending with a letter or digit.
+func catalogName(label string) string {
+	name := invalidCatalogName.ReplaceAllString(label, "-")
+	if len(name) > maxCatalogNameLength {
+		name = name[:maxCatalogNameLength]
+	}
+	name = strings.Trim(name, "-_.")
+	if name == "" {
+		name = "unnamed"
+	}
+	return name
+}
+
+// CatalogEntities converts the rendered nodes of a topology to catalog
+// entities: a component of each node, depending on those it connects to,
+// and providing an API to them, if any node connects to it. Components
+// are in the catalog namespaces of the Kubernetes namespaces of their
+// nodes, if they have one. Pseudo nodes are left out.
+func CatalogEntities(rc detailed.RenderContext, topologyID string, nodes report.Nodes, owner string) []CatalogEntity {
+	var (
+		components = map[string]*CatalogEntity{} // by node ID
+		taken      = map[string]struct{}{}       // component refs
+	)
+	for _, id := range sortedIDs(nodes) {
+		summary, ok := detailed.MakeNodeSummary(rc, nodes[id])
+		if !ok || summary.Pseudo {
+			continue
+		}
+		namespace := catalogNamespace
+		if ns, ok := nodes[id].Latest.Lookup(kubernetes.Namespace); ok {
+			namespace = catalogName(ns)
+		}
+		component := &CatalogEntity{
+			APIVersion: catalogAPIVersion,
+			Kind:       "Component",
+			Metadata: CatalogMetadata{
+				Name:      catalogName(summary.Label),
+				Namespace: namespace,
+				Annotations: map[string]string{
+					CatalogNodeIDAnnotation:   id,
+					CatalogTopologyAnnotation: topologyID,
+				},
+			},
+			Spec: CatalogSpec{Type: "service", Lifecycle: "production", Owner: owner},
+		}
+		// Nodes of the same label get numbered names
+		base := component.Metadata.Name
+		for i := 2; ; i++ {
+			if _, ok := taken[component.ref()]; !ok {
+				break
+			}
+			component.Metadata.Name = fmt.Sprintf("%s-%d", base, i)
+		}
+		taken[component.ref()] = struct{}{}
+		components[id] = component
+	}
+
+	apis := map[string]*CatalogEntity{} // by node ID of the provider
+	for _, id := range sortedIDs(nodes) {
+		consumer, ok := components[id]
+		if !ok {
+			continue
+		}
+		for _, dstID := range nodes[id].Adjacency {
+			provider, ok := components[dstID]
+			if !ok || dstID == id {
+				continue
+			}
+			api, ok := apis[dstID]
+			if !ok {
+				api = &CatalogEntity{
+					APIVersion: catalogAPIVersion,
+					Kind:       "API",
+					Metadata: CatalogMetadata{
+						Name:        provider.Metadata.Name,
+						Namespace:   provider.Metadata.Namespace,
+						Annotations: provider.Metadata.Annotations,
+					},
+					Spec: CatalogSpec{
+						Type:       "tcp",
+						Lifecycle:  "production",
+						Owner:      owner,
+						Definition: fmt.Sprintf("Connections to %s, as observed by Weave Scope", provider.Metadata.Name),
+					},
+				}
+				apis[dstID] = api
+				provider.Spec.ProvidesAPIs = []string{api.ref()}
+			}
+			consumer.Spec.DependsOn = append(consumer.Spec.DependsOn, provider.ref())
+			consumer.Spec.ConsumesAPIs = append(consumer.Spec.ConsumesAPIs, api.ref())
+		}
+	}
+
+	result := make([]CatalogEntity, 0, len(components)+len(apis))
+	for _, c := range components {
+		result = append(result, *c)
+	}
+	for _, api := range apis {
+		result = append(result, *api)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ref() < result[j].ref() })
+	return result
+}
+
+// MarshalCatalog encodes catalog entities as YAML documents, as
+// catalog-info.yaml files have them.
+func MarshalCatalog(entities []CatalogEntity) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by Weave Scope from the dependencies observed; edits are overwritten\n")
+	for _, e := range entities {
+		out, err := yaml.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString("---\n")
+		buf.Write(out)
+	}
+	return buf.Bytes(), nil
+}
+
+// CatalogSink is somewhere catalog-info.yaml files are pushed to, for a
+// service catalog to pick up.
+type CatalogSink interface {
+	Push(ctx context.Context, catalog []byte) error
+}
+
+// NewCatalogSink makes a CatalogSink of a URL: http(s):// URLs are posted
+// to, and file:// ones are of clones of git repositories to commit the
+// catalog to, as catalog-info.yaml unless the URL has a fragment naming
+// another file, and to push from.
+func NewCatalogSink(rawurl string) (CatalogSink, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return HTTPCatalogSink{URL: rawurl, Client: &http.Client{Timeout: catalogPushTimeout}}, nil
+	case "file":
+		file := u.Fragment
+		if file == "" {
+			file = defaultCatalogFile
+		}
+		return GitCatalogSink{Dir: u.Path, File: file}, nil
+	}
+	return nil, fmt.Errorf("unsupported catalog URL: %q", rawurl)
+}
+
+// HTTPCatalogSink posts catalogs to a URL.
+type HTTPCatalogSink struct {
+	URL    string
+	Client *http.Client
+}
+
+// Push implements CatalogSink
+func (s HTTPCatalogSink) Push(ctx context.Context, catalog []byte) error {
+	req, err := http.NewRequest("POST", s.URL, bytes.NewReader(catalog))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-yaml")
+	resp, err := s.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("error pushing catalog to %s: %s", s.URL, resp.Status)
+	}
+	return nil
+}
+
+// GitCatalogSink commits catalogs to a file of a clone of a git
+// repository, and pushes them upstream, with the git command.
+type GitCatalogSink struct {
+	Dir  string
+	File string // relative to Dir
+}
+
+// Push implements CatalogSink
+func (s GitCatalogSink) Push(ctx context.Context, catalog []byte) error {
+	if err := s.git(ctx, "pull", "--ff-only"); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(s.Dir, s.File), catalog, 0644); err != nil {
+		return err
+	}
+	if err := s.git(ctx, "add", "--", s.File); err != nil {
+		return err
+	}
+	// Nothing to commit unless the catalog changed
+	if err := s.git(ctx, "diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+	if err := s.git(ctx, "commit", "-m", "Update the dependencies observed by Weave Scope"); err != nil {
+		return err
+	}
+	return s.git(ctx, "push")
+}
+
+func (s GitCatalogSink) git(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = s.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %v: %s", args[0], err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// CatalogExporter periodically exports the rendered topology of a
+// Reporter to a service catalog, as Backstage entities, so the catalog
+// keeps up with the dependencies observed. Catalogs are only pushed when
+// they change.
+type CatalogExporter struct {
+	reporter Reporter
+	registry *Registry
+	topology string
+	owner    string
+	sink     CatalogSink
+	quit     chan struct{}
+
+	last []byte // only touched by Export, which is not called concurrently
+}
+
+// NewCatalogExporter makes a new CatalogExporter of a topology, whose
+// components are all owned by owner.
+func NewCatalogExporter(rep Reporter, topologyID, owner string, sink CatalogSink) (*CatalogExporter, error) {
+	if _, ok := topologyRegistry.get(topologyID); !ok {
+		return nil, fmt.Errorf("unknown topology: %q", topologyID)
+	}
+	return &CatalogExporter{
+		reporter: rep,
+		registry: topologyRegistry,
+		topology: topologyID,
+		owner:    owner,
+		sink:     sink,
+		quit:     make(chan struct{}),
+	}, nil
+}
+
+// Start exports the catalog every interval, until stopped.
+func (e *CatalogExporter) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := e.Export(context.Background(), time.Now()); err != nil {
+					log.Errorf("Error exporting catalog: %v", err)
+				}
+			case <-e.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops exporting the catalog.
+func (e *CatalogExporter) Stop() {
+	close(e.quit)
+}
+
+// Export exports the catalog of the report at the given time, unless it
+// is the one last exported.
+func (e *CatalogExporter) Export(ctx context.Context, timestamp time.Time) error {
+	rpt, err := e.reporter.Report(ctx, timestamp)
+	if err != nil {
+		return err
+	}
+	renderer, filter, err := e.registry.RendererForTopology(e.topology, nil, rpt)
+	if err != nil {
+		return err
+	}
+	var (
+		rc    = RenderContextForReporter(e.reporter, rpt)
+		nodes = render.Render(rpt, renderer, filter).Nodes
+	)
+	catalog, err := MarshalCatalog(CatalogEntities(rc, e.topology, nodes, e.owner))
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(catalog, e.last) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, catalogPushTimeout)
+	defer cancel()
+	if err := e.sink.Push(ctx, catalog); err != nil {
+		return err
+	}
+	e.last = catalog
+	return nil
+}
@@ -0,0 +1,129 @@
+package app_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestCatalogExporter(t *testing.T) {
+	pushes := make(chan string, 10)
+	catalog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		pushes <- string(body)
+	}))
+	defer catalog.Close()
+
+	if _, err := app.NewCatalogExporter(app.StaticCollector(fixture.Report), "nonesuch", "team", nil); err == nil {
+		t.Error("expected an error for an unknown topology")
+	}
+	sink, err := app.NewCatalogSink(catalog.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := app.NewCatalogExporter(app.StaticCollector(fixture.Report), "containers", "team", sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := e.Export(ctx, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(pushes) != 1 {
+		t.Fatalf("Expected the catalog pushed once, as it didn't change, got %d pushes", len(pushes))
+	}
+
+	byNode := map[string][]app.CatalogEntity{}
+	for _, doc := range strings.Split(<-pushes, "---\n")[1:] {
+		var entity app.CatalogEntity
+		if err := yaml.Unmarshal([]byte(doc), &entity); err != nil {
+			t.Fatal(err)
+		}
+		id := entity.Metadata.Annotations[app.CatalogNodeIDAnnotation]
+		byNode[id] = append(byNode[id], entity)
+	}
+	client, server := byNode[fixture.ClientContainerNodeID], byNode[fixture.ServerContainerNodeID]
+	if len(client) != 1 || len(server) != 2 {
+		t.Fatalf("Expected a component of the client, and a component and API of the server, got %v and %v", client, server)
+	}
+	if server[0].Kind != "Component" {
+		server[0], server[1] = server[1], server[0]
+	}
+	ref := func(e app.CatalogEntity) string {
+		return strings.ToLower(e.Kind) + ":" + e.Metadata.Namespace + "/" + e.Metadata.Name
+	}
+	serverComponent, serverAPI := ref(server[0]), ref(server[1])
+	if deps := client[0].Spec.DependsOn; len(deps) != 1 || deps[0] != serverComponent {
+		t.Errorf("Expected the client to depend on %s, got %v", serverComponent, deps)
+	}
+	if apis := client[0].Spec.ConsumesAPIs; len(apis) != 1 || apis[0] != serverAPI {
+		t.Errorf("Expected the client to consume %s, got %v", serverAPI, apis)
+	}
+	if apis := server[0].Spec.ProvidesAPIs; len(apis) != 1 || apis[0] != serverAPI {
+		t.Errorf("Expected the server to provide %s, got %v", serverAPI, apis)
+	}
+	if owner := client[0].Spec.Owner; owner != "team" {
+		t.Errorf("Expected components owned by team, got %q", owner)
+	}
+}
+
+func TestGitCatalogSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var (
+		upstream = filepath.Join(dir, "upstream.git")
+		clone    = filepath.Join(dir, "clone")
+	)
+	git := func(dir string, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+		return string(out)
+	}
+	git(dir, "init", "--bare", upstream)
+	git(dir, "clone", upstream, clone)
+	git(clone, "config", "user.name", "Scope")
+	git(clone, "config", "user.email", "scope@example.com")
+	git(clone, "commit", "--allow-empty", "-m", "Initial commit")
+	git(clone, "push", "origin", "HEAD")
+
+	sink, err := app.NewCatalogSink("file://" + clone + "#catalog/scope.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(clone, "catalog"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, catalog := range []string{"one", "one", "two"} {
+		if err := sink.Push(ctx, []byte(catalog)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if have := git(upstream, "show", "HEAD:catalog/scope.yaml"); have != "two" {
+		t.Errorf("Expected the last catalog pushed, got %q", have)
+	}
+	if commits := strings.Count(git(upstream, "log", "--oneline"), "\n"); commits != 3 {
+		t.Errorf("Expected a commit of each change, got %d commits", commits)
+	}
+}
@@ -4,14 +4,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	opentracing "github.com/opentracing/opentracing-go"
 	"golang.org/x/net/context"
 
 	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/common/geoip"
 	"github.com/weaveworks/scope/report"
 )
 
@@ -35,12 +38,32 @@ type Reporter interface {
 	UnWait(context.Context, chan struct{})
 }
 
+// WindowedReporter is a Reporter which can also produce reports merged
+// over a window longer than its default one, e.g. to show connections
+// which were too short-lived to appear in the latest reports.
+type WindowedReporter interface {
+	ReportWindow(context.Context, time.Time, time.Duration) (report.Report, error)
+}
+
 // WebReporter is a reporter that creates reports whose data is eventually
 // displayed on websites. It carries fields that will be forwarded to the
 // detailed.RenderContext
 type WebReporter struct {
 	Reporter
 	MetricsGraphURL string
+	GeoIP           geoip.Resolver
+	Layouts         *Layouts
+	Annotations     *Annotations
+	Anomalies       *AnomalyDetector
+}
+
+// windowedReporter returns the WindowedReporter behind rep, if any.
+func windowedReporter(rep Reporter) (WindowedReporter, bool) {
+	if wrep, ok := rep.(WebReporter); ok {
+		rep = wrep.Reporter
+	}
+	w, ok := rep.(WindowedReporter)
+	return w, ok
 }
 
 // Adder is something that can accept reports. It's a convenient interface for
@@ -66,7 +89,9 @@ type collector struct {
 	reports    []report.Report
 	timestamps []time.Time
 	window     time.Duration
+	history    time.Duration
 	cached     *report.Report
+	cachedFrom time.Time
 	merger     Merger
 	waitableCondition
 }
@@ -102,8 +127,20 @@ func (wc *waitableCondition) Broadcast() {
 
 // NewCollector returns a collector ready for use.
 func NewCollector(window time.Duration) Collector {
+	return NewCollectorWithHistory(window, window)
+}
+
+// NewCollectorWithHistory returns a collector which keeps reports for
+// the given history, so they can be merged over windows longer than
+// the default window by ReportWindow. A history shorter than the
+// window is ignored.
+func NewCollectorWithHistory(window, history time.Duration) Collector {
+	if history < window {
+		history = window
+	}
 	return &collector{
-		window: window,
+		window:  window,
+		history: history,
 		waitableCondition: waitableCondition{
 			waiters: map[chan struct{}]struct{}{},
 		},
@@ -111,12 +148,29 @@ func NewCollector(window time.Duration) Collector {
 	}
 }
 
+// reportTimestamp is when the report being added was published: now,
+// unless it is replayed.
+func reportTimestamp(ctx context.Context) time.Time {
+	now := mtime.Now()
+	if t, ok := ctx.Value(ReportTimestampCtxKey).(time.Time); ok && t.Before(now) {
+		return t
+	}
+	return now
+}
+
 // Add adds a report to the collector's internal state. It implements Adder.
-func (c *collector) Add(_ context.Context, rpt report.Report, _ []byte) error {
+// Reports replayed by probes are added at the time they were published.
+func (c *collector) Add(ctx context.Context, rpt report.Report, _ []byte) error {
+	timestamp := reportTimestamp(ctx)
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
-	c.reports = append(c.reports, rpt)
-	c.timestamps = append(c.timestamps, mtime.Now())
+	i := sort.Search(len(c.timestamps), func(i int) bool { return c.timestamps[i].After(timestamp) })
+	c.reports = append(c.reports, report.Report{})
+	copy(c.reports[i+1:], c.reports[i:])
+	c.reports[i] = rpt
+	c.timestamps = append(c.timestamps, time.Time{})
+	copy(c.timestamps[i+1:], c.timestamps[i:])
+	c.timestamps[i] = timestamp
 
 	c.clean()
 	c.cached = nil
@@ -126,21 +180,65 @@ func (c *collector) Add(_ context.Context, rpt report.Report, _ []byte) error {
 	return nil
 }
 
-// Report returns a merged report over all added reports. It implements
-// Reporter.
-func (c *collector) Report(_ context.Context, timestamp time.Time) (report.Report, error) {
+// Report returns a merged report over the reports received within the
+// window before timestamp. It implements Reporter.
+func (c *collector) Report(ctx context.Context, timestamp time.Time) (report.Report, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "merge reports")
+	defer span.Finish()
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
+	// Reports of the past, as opposed to the latest ones, are merged on
+	// demand without caching.
+	oldest := timestamp.Add(-c.window)
+	if len(c.timestamps) > 0 && timestamp.Before(c.timestamps[len(c.timestamps)-1]) {
+		return c.merger.Merge(c.between(oldest, timestamp)), nil
+	}
+
 	// If the oldest report is still within range,
 	// and there is a cached report, return that.
 	if c.cached != nil && len(c.reports) > 0 {
-		oldest := timestamp.Add(-c.window)
-		if c.timestamps[0].After(oldest) {
+		if c.cachedFrom.After(oldest) {
+			span.SetTag("cached", true)
 			return *c.cached, nil
 		}
 	}
 
+	reports, from := c.since(oldest)
+	span.SetTag("reports", len(reports))
+	rpt := c.merger.Merge(reports)
+	c.cached = &rpt
+	c.cachedFrom = from
+	return rpt, nil
+}
+
+// ReportWindow returns a merged report over all added reports received
+// within the given window before timestamp. Windows longer than the
+// collector's history are truncated to it. It implements
+// WindowedReporter.
+func (c *collector) ReportWindow(_ context.Context, timestamp time.Time, window time.Duration) (report.Report, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.merger.Merge(c.between(timestamp.Add(-window), timestamp)), nil
+}
+
+// between returns the (upgraded) reports received after oldest and not
+// after newest.
+func (c *collector) between(oldest, newest time.Time) []report.Report {
+	reports, _ := c.since(oldest)
+	offset := len(c.reports) - len(reports)
+	for i := range reports {
+		if c.timestamps[offset+i].After(newest) {
+			return reports[:i]
+		}
+	}
+	return reports
+}
+
+// since returns the (upgraded) reports received after oldest, and the
+// timestamp of the first of them.
+func (c *collector) since(oldest time.Time) ([]report.Report, time.Time) {
 	c.clean()
 	c.quantise()
 
@@ -148,9 +246,12 @@ func (c *collector) Report(_ context.Context, timestamp time.Time) (report.Repor
 		c.reports[i] = c.reports[i].Upgrade()
 	}
 
-	rpt := c.merger.Merge(c.reports)
-	c.cached = &rpt
-	return rpt, nil
+	for i, t := range c.timestamps {
+		if t.After(oldest) {
+			return c.reports[i:], t
+		}
+	}
+	return nil, time.Time{}
 }
 
 // HasReports indicates whether the collector contains reports between
@@ -167,17 +268,17 @@ func (c *collector) HasReports(ctx context.Context, timestamp time.Time) (bool,
 }
 
 // HasHistoricReports indicates whether the collector contains reports
-// older than now-app.window.
+// older than now-app.window, i.e. whether it keeps a history.
 func (c *collector) HasHistoricReports() bool {
-	return false
+	return c.history > c.window
 }
 
-// remove reports older than the app.window
+// remove reports older than the app.window (or the history, if longer)
 func (c *collector) clean() {
 	var (
 		cleanedReports    = make([]report.Report, 0, len(c.reports))
 		cleanedTimestamps = make([]time.Time, 0, len(c.timestamps))
-		oldest            = mtime.Now().Add(-c.window)
+		oldest            = mtime.Now().Add(-c.history)
 	)
 	for i, r := range c.reports {
 		if c.timestamps[i].After(oldest) {
@@ -196,6 +297,9 @@ func (c *collector) clean() {
 // reportQuantisationInterval of 3s and reports with timestamps [0, 1,
 // 2, 5, 6, 7], the result contains merged reports with
 // timestamps/content of [0:{0,1,2}, 5:{5,6,7}].
+//
+// Reports older than the window are only kept for looking at the past,
+// so they are merged further, into one report per window.
 func (c *collector) quantise() {
 	if len(c.reports) == 0 {
 		return
@@ -206,8 +310,13 @@ func (c *collector) quantise() {
 	)
 	quantumStartIdx := 0
 	quantumStartTimestamp := c.timestamps[0]
+	recent := mtime.Now().Add(-c.window)
 	for i, t := range c.timestamps {
-		if t.Sub(quantumStartTimestamp) < reportQuantisationInterval {
+		interval := reportQuantisationInterval
+		if c.history > c.window && t.Before(recent) {
+			interval = c.window
+		}
+		if t.Sub(quantumStartTimestamp) < interval {
 			continue
 		}
 		quantisedReports = append(quantisedReports, c.merger.Merge(c.reports[quantumStartIdx:i]))
@@ -130,3 +130,127 @@ func TestCollectorWait(t *testing.T) {
 		t.Fatal("Didn't unblock")
 	}
 }
+
+func TestCollectorReportWindow(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	ctx := context.Background()
+	window := 10 * time.Second
+	c := app.NewCollectorWithHistory(window, time.Minute)
+
+	r1 := report.MakeReport()
+	r1.Endpoint.AddNode(report.MakeNode("foo"))
+	c.Add(ctx, r1, nil)
+
+	mtime.NowForce(now.Add(30 * time.Second))
+	r2 := report.MakeReport()
+	r2.Endpoint.AddNode(report.MakeNode("bar"))
+	c.Add(ctx, r2, nil)
+
+	// The default window only covers the latest report
+	have, err := c.Report(ctx, mtime.Now())
+	if err != nil {
+		t.Error(err)
+	}
+	if want := r2; !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+
+	// Longer windows reach back into the history
+	have, err = c.(app.WindowedReporter).ReportWindow(ctx, mtime.Now(), time.Minute)
+	if err != nil {
+		t.Error(err)
+	}
+	if want := r1.Merge(r2); !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+
+	// Reports older than the history are dropped
+	mtime.NowForce(now.Add(2 * time.Minute))
+	have, err = c.(app.WindowedReporter).ReportWindow(ctx, mtime.Now(), time.Hour)
+	if err != nil {
+		t.Error(err)
+	}
+	if want := report.MakeReport(); !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
+func TestCollectorTimeTravel(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	ctx := context.Background()
+	window := 10 * time.Second
+	c := app.NewCollectorWithHistory(window, time.Hour)
+	if !c.HasHistoricReports() {
+		t.Error("collector with a history has no historic reports")
+	}
+	if app.NewCollector(window).HasHistoricReports() {
+		t.Error("collector without a history has historic reports")
+	}
+
+	for i, id := range []string{"foo", "bar", "baz"} {
+		mtime.NowForce(now.Add(time.Duration(i) * time.Minute))
+		rpt := report.MakeReport()
+		rpt.Endpoint.AddNode(report.MakeNode(id))
+		c.Add(ctx, rpt, nil)
+	}
+
+	for _, c2 := range []struct {
+		at   time.Duration
+		want []string
+	}{
+		{5 * time.Second, []string{"foo"}},
+		{time.Minute, []string{"bar"}},
+		{90 * time.Second, []string{}},
+		{2 * time.Minute, []string{"baz"}},
+	} {
+		have, err := c.Report(ctx, now.Add(c2.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for id := range have.Endpoint.Nodes {
+			ids = append(ids, id)
+		}
+		if !reflect.DeepEqual(c2.want, ids) {
+			t.Errorf("at %v: want %v, have %v", c2.at, c2.want, ids)
+		}
+	}
+}
+
+func TestCollectorReplayedReports(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	c := app.NewCollectorWithHistory(10*time.Second, time.Hour)
+	rpt := report.MakeReport()
+	rpt.Endpoint.AddNode(report.MakeNode("live"))
+	c.Add(context.Background(), rpt, nil)
+
+	replayed := report.MakeReport()
+	replayed.Endpoint.AddNode(report.MakeNode("replayed"))
+	ctx := context.WithValue(context.Background(), app.ReportTimestampCtxKey, now.Add(-time.Minute))
+	c.Add(ctx, replayed, nil)
+
+	for _, c2 := range []struct {
+		at   time.Time
+		want string
+	}{
+		{now.Add(-time.Minute), "replayed"},
+		{now, "live"},
+	} {
+		have, err := c.Report(context.Background(), c2.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := have.Endpoint.Nodes[c2.want]; !ok || len(have.Endpoint.Nodes) != 1 {
+			t.Errorf("at %v: want %s, have %v", c2.at, c2.want, have.Endpoint.Nodes)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package app
 
 import (
+	"fmt"
 	"net/http"
 	"net/rpc"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
+	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/ugorji/go/codec"
 	"golang.org/x/net/context"
 
@@ -38,19 +40,42 @@ func handleControl(cr ControlRouter) CtxHandlerFunc {
 		)
 
 		if r.ContentLength > 0 {
-			err := codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&controlArgs)
+			// The values of parameters, as the UI collects them in
+			// forms, may be numbers, and pass to probes as strings.
+			var args map[string]interface{}
+			err := codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&args)
 			defer r.Body.Close()
 			if err != nil {
 				respondWith(w, http.StatusBadRequest, err)
 				return
 			}
+			controlArgs = make(map[string]string, len(args))
+			for k, v := range args {
+				switch v := v.(type) {
+				case string:
+					controlArgs[k] = v
+				case nil:
+				case map[string]interface{}, []interface{}:
+					respondWith(w, http.StatusBadRequest, fmt.Errorf("argument %s is not a scalar", k))
+					return
+				default:
+					controlArgs[k] = fmt.Sprint(v)
+				}
+			}
 		}
 
-		result, err := cr.Handle(ctx, probeID, xfer.Request{
+		span, ctx := opentracing.StartSpanFromContext(ctx, "control "+control)
+		defer span.Finish()
+		span.SetTag("probe_id", probeID)
+		span.SetTag("node_id", nodeID)
+		req := xfer.Request{
 			NodeID:      nodeID,
 			Control:     control,
 			ControlArgs: controlArgs,
-		})
+			Trace:       map[string]string{},
+		}
+		opentracing.GlobalTracer().Inject(span.Context(), opentracing.TextMap, opentracing.TextMapCarrier(req.Trace))
+		result, err := cr.Handle(ctx, probeID, req)
 		if err != nil {
 			respondWith(w, http.StatusBadRequest, err.Error())
 			return
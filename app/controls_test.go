@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -40,6 +41,11 @@ func TestControl(t *testing.T) {
 			t.Fatalf("'%s' != 'control'", req.Control)
 		}
 
+		// Numbers of forms pass as strings
+		if want := map[string]string{"replicas": "3", "signal": "HUP"}; !reflect.DeepEqual(want, req.ControlArgs) {
+			t.Fatalf("%v != %v", req.ControlArgs, want)
+		}
+
 		return xfer.Response{
 			Value: "foo",
 		}
@@ -60,7 +66,7 @@ func TestControl(t *testing.T) {
 	resp, err := httpClient.Post(
 		server.URL+"/api/control/foo/nodeid/control",
 		"application/json",
-		strings.NewReader("{}"),
+		strings.NewReader(`{"replicas": 3, "signal": "HUP"}`),
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"text/template"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/render"
+)
+
+// CustomTopology is a topology operators define, rendering the nodes of a
+// built-in one, optionally filtered, grouped and relabelled.
+type CustomTopology struct {
+	ID      string                 `json:"id"`
+	Name    string                 `json:"name"`
+	Parent  string                 `json:"parent,omitempty"` // ID of the topology it is a sub-topology of, if any
+	Rank    int                    `json:"rank,omitempty"`
+	Base    string                 `json:"base"`              // ID of the topology whose nodes it renders
+	GroupBy string                 `json:"groupBy,omitempty"` // Latest key to group the nodes by
+	Filters []CustomTopologyFilter `json:"filters,omitempty"`
+	Label   string                 `json:"label,omitempty"` // template, see render.Relabel
+}
+
+// CustomTopologyFilter keeps the nodes with, or without, a value for a
+// Latest key, e.g. a label.
+type CustomTopologyFilter struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Exclude bool   `json:"exclude,omitempty"`
+}
+
+// LoadCustomTopologies adds the custom topologies listed in a JSON file to
+// the default Registry, e.g.
+//
+//	[{"id": "teams", "name": "Teams", "base": "containers",
+//	  "groupBy": "docker_label_team", "filters": [{"key": "docker_label_env", "value": "prod"}],
+//	  "label": "{{.docker_label_team}} ({{.count}})"}]
+func LoadCustomTopologies(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var topologies []CustomTopology
+	if err := codec.NewDecoder(f, &codec.JsonHandle{}).Decode(&topologies); err != nil {
+		return fmt.Errorf("error reading custom topologies %s: %v", path, err)
+	}
+	return topologyRegistry.AddCustomTopologies(topologies...)
+}
+
+// AddCustomTopologies adds custom topologies to this Registry, after the
+// topologies they are based on.
+func (r *Registry) AddCustomTopologies(topologies ...CustomTopology) error {
+	for _, t := range topologies {
+		desc, err := r.customTopologyDesc(t)
+		if err != nil {
+			return fmt.Errorf("custom topology %q: %v", t.ID, err)
+		}
+		r.Add(desc)
+	}
+	return nil
+}
+
+func (r *Registry) customTopologyDesc(t CustomTopology) (APITopologyDesc, error) {
+	if t.ID == "" || t.Name == "" {
+		return APITopologyDesc{}, fmt.Errorf("missing id or name")
+	}
+	if _, ok := r.get(t.ID); ok {
+		return APITopologyDesc{}, fmt.Errorf("there already is a topology of that id")
+	}
+	if t.Parent != "" {
+		if _, ok := r.get(t.Parent); !ok {
+			return APITopologyDesc{}, fmt.Errorf("unknown parent topology %q", t.Parent)
+		}
+	}
+	base, ok := r.get(t.Base)
+	if !ok {
+		return APITopologyDesc{}, fmt.Errorf("unknown base topology %q", t.Base)
+	}
+
+	var transformers render.Transformers
+	if len(t.Filters) > 0 {
+		filters := make([]render.FilterFunc, 0, len(t.Filters))
+		for _, f := range t.Filters {
+			filter := render.HasLatest(f.Key, f.Value)
+			if f.Exclude {
+				filter = render.Complement(filter)
+			}
+			filters = append(filters, render.AnyFilterFunc(render.IsPseudoTopology, filter))
+		}
+		transformers = append(transformers, render.ComposeFilterFuncs(filters...))
+	}
+	if t.GroupBy != "" {
+		transformers = append(transformers, render.GroupBy{Key: t.GroupBy})
+	}
+	if t.Label != "" {
+		tmpl, err := template.New(t.ID).Option("missingkey=zero").Parse(t.Label)
+		if err != nil {
+			return APITopologyDesc{}, fmt.Errorf("bad label template: %v", err)
+		}
+		transformers = append(transformers, render.Relabel{Template: tmpl})
+	}
+
+	return APITopologyDesc{
+		id:          t.ID,
+		parent:      t.Parent,
+		renderer:    render.CustomRenderer{RenderFunc: transformers.Transform, Renderer: base.renderer},
+		Name:        t.Name,
+		Rank:        t.Rank,
+		HideIfEmpty: true,
+		Options:     []APITopologyOptionGroup{unmanagedFilter},
+	}, nil
+}
@@ -0,0 +1,49 @@
+package app_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestCustomTopologies(t *testing.T) {
+	topologyRegistry := app.MakeRegistry()
+	err := topologyRegistry.AddCustomTopologies(app.CustomTopology{
+		ID:      "foos",
+		Name:    "Foos",
+		Base:    "containers",
+		GroupBy: docker.LabelPrefix + "foo2",
+		Filters: []app.CustomTopologyFilter{{Key: docker.LabelPrefix + "foo1", Value: "bar1"}},
+		Label:   `foo {{index . "docker_label_foo2"}} ({{.count}})`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renderer, filter, err := topologyRegistry.RendererForTopology("foos", url.Values{}, fixture.Report)
+	if err != nil {
+		t.Fatalf("Topology Registry Report error: %s", err)
+	}
+	summaries := detailed.Summaries(detailed.RenderContext{Report: fixture.Report}, render.Render(fixture.Report, renderer, filter).Nodes)
+	if have := summaries["bar2"].Label; have != "foo bar2 (1)" {
+		t.Errorf("Expected the containers grouped and relabelled, got %q in %v", have, summaries)
+	}
+	if _, ok := summaries[fixture.ClientContainerNodeID]; ok {
+		t.Errorf("Expected the client container filtered out")
+	}
+
+	for _, bad := range []app.CustomTopology{
+		{ID: "foos", Name: "Foos again", Base: "containers"},
+		{ID: "bars", Name: "Bars", Base: "nothing"},
+		{ID: "bazs", Name: "Bazs", Base: "containers", Label: "{{.oops"},
+	} {
+		if err := topologyRegistry.AddCustomTopologies(bad); err == nil {
+			t.Errorf("Expected an error adding %v", bad)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// baselineExpiry is how long the baseline of a probe is kept after its last
+// delta, so probes which went away don't leak.
+const baselineExpiry = 5 * time.Minute
+
+// errUnknownBaseline is returned for deltas against a report the app
+// doesn't have, e.g. because it was restarted since. The probe publishes a
+// new full report soon enough.
+type errUnknownBaseline struct {
+	probeID, baseline string
+}
+
+func (e errUnknownBaseline) Error() string {
+	return fmt.Sprintf("unknown baseline %s for probe %s", e.baseline, e.probeID)
+}
+
+// deltaReassembler keeps the last full report published by every probe
+// publishing deltas, to reassemble their deltas into full reports.
+type deltaReassembler struct {
+	mtx       sync.Mutex
+	baselines map[string]baseline // by probe ID
+}
+
+type baseline struct {
+	report   report.Report
+	lastUsed time.Time
+}
+
+func newDeltaReassembler() *deltaReassembler {
+	return &deltaReassembler{
+		baselines: map[string]baseline{},
+	}
+}
+
+// reassemble returns the full report of a delta published by the given
+// probe. Deltas without a baseline are full reports, and become the
+// baseline of the next ones.
+func (d *deltaReassembler) reassemble(probeID string, delta report.Delta) (report.Report, error) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	now := mtime.Now()
+	if delta.Baseline == "" {
+		for id, b := range d.baselines {
+			if now.Sub(b.lastUsed) > baselineExpiry {
+				delete(d.baselines, id)
+			}
+		}
+		d.baselines[probeID] = baseline{report: delta.Report, lastUsed: now}
+		return delta.Report, nil
+	}
+	b, ok := d.baselines[probeID]
+	if !ok || b.report.ID != delta.Baseline {
+		return report.Report{}, errUnknownBaseline{probeID: probeID, baseline: delta.Baseline}
+	}
+	b.lastUsed = now
+	d.baselines[probeID] = b
+	return delta.Apply(b.report)
+}
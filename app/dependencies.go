@@ -0,0 +1,289 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+// maxDependencyEvents is how many of the latest events are kept for
+// /api/dependencies/new.
+const maxDependencyEvents = 1000
+
+// DefaultDependencyTopologies are the topologies new dependencies are
+// tracked in by default: those whose node IDs outlive the pods and
+// containers they are of.
+var DefaultDependencyTopologies = []string{servicesID, kubeControllersID, containersByImageID, hostsID}
+
+// SeenEdge is an edge of a topology, and when it was last seen.
+type SeenEdge struct {
+	Topology string    `json:"topology"`
+	Src      string    `json:"src"`
+	Dst      string    `json:"dst"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// DependencyEvent is posted to webhooks, and listed at
+// /api/dependencies/new, when a node connects to one it didn't within the
+// lookback window, e.g. as an attacker moves laterally, or a service is
+// misconfigured.
+type DependencyEvent struct {
+	Topology  string    `json:"topology"`
+	NodeID    string    `json:"nodeId"`
+	Label     string    `json:"label"`
+	PeerID    string    `json:"peerId"`
+	PeerLabel string    `json:"peerLabel"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DependencyStore is somewhere the edges seen are kept across restarts of
+// the app, like an AnnotationStore.
+type DependencyStore interface {
+	LoadDependencies(ctx context.Context) ([]SeenEdge, error)
+	StoreDependencies(ctx context.Context, edges []SeenEdge) error
+}
+
+type seenKey struct{ topology, src, dst string }
+
+// DependencyTracker periodically records the edges of the rendered
+// topologies of a Reporter, and notifies about those it never saw within
+// the lookback window. Topologies are only learnt the first time the
+// tracker sees them, so not all their edges are new.
+type DependencyTracker struct {
+	reporter   Reporter
+	registry   *Registry
+	store      DependencyStore
+	topologies []string
+	lookback   time.Duration
+	webhook    string
+	client     *http.Client
+	quit       chan struct{}
+
+	mtx      sync.Mutex
+	seen     map[seenKey]time.Time
+	observed map[string]struct{} // topology IDs with history
+	events   []DependencyEvent
+}
+
+// NewDependencyTracker makes a DependencyTracker of topologies, loading
+// the edges seen from store, nil to only keep them in memory, and posting
+// events to webhook, if not empty.
+func NewDependencyTracker(ctx context.Context, rep Reporter, topologies []string, lookback time.Duration, store DependencyStore, webhook string) (*DependencyTracker, error) {
+	for _, id := range topologies {
+		if _, ok := topologyRegistry.get(id); !ok {
+			return nil, fmt.Errorf("unknown topology: %q", id)
+		}
+	}
+	t := &DependencyTracker{
+		reporter:   rep,
+		registry:   topologyRegistry,
+		store:      store,
+		topologies: topologies,
+		lookback:   lookback,
+		webhook:    webhook,
+		client:     &http.Client{Timeout: notifyTimeout},
+		quit:       make(chan struct{}),
+		seen:       map[seenKey]time.Time{},
+		observed:   map[string]struct{}{},
+	}
+	if store != nil {
+		edges, err := store.LoadDependencies(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range edges {
+			t.seen[seenKey{e.Topology, e.Src, e.Dst}] = e.LastSeen
+			t.observed[e.Topology] = struct{}{}
+		}
+	}
+	return t, nil
+}
+
+// Start records the edges every interval, until stopped.
+func (t *DependencyTracker) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := t.Track(context.Background(), time.Now()); err != nil {
+					log.Errorf("Error tracking dependencies: %v", err)
+				}
+			case <-t.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops recording the edges.
+func (t *DependencyTracker) Stop() {
+	close(t.quit)
+}
+
+// Events returns the latest events since the time given, oldest first.
+func (t *DependencyTracker) Events(since time.Time) []DependencyEvent {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	i := sort.Search(len(t.events), func(i int) bool { return t.events[i].Timestamp.After(since) })
+	return append([]DependencyEvent(nil), t.events[i:]...)
+}
+
+// Track records the edges of the report at the given time, and notifies
+// about the new ones.
+func (t *DependencyTracker) Track(ctx context.Context, timestamp time.Time) error {
+	rpt, err := t.reporter.Report(ctx, timestamp)
+	if err != nil {
+		return err
+	}
+	rc := RenderContextForReporter(t.reporter, rpt)
+	var events []DependencyEvent
+	t.mtx.Lock()
+	cutoff := timestamp.Add(-t.lookback)
+	for key, lastSeen := range t.seen {
+		if lastSeen.Before(cutoff) {
+			delete(t.seen, key)
+		}
+	}
+	for _, topologyID := range t.topologies {
+		renderer, filter, err := t.registry.RendererForTopology(topologyID, nil, rpt)
+		if err != nil {
+			t.mtx.Unlock()
+			return err
+		}
+		nodes := render.Render(rpt, renderer, filter).Nodes
+		events = append(events, t.track(rc, topologyID, nodes, timestamp)...)
+	}
+	t.events = append(t.events, events...)
+	if len(t.events) > maxDependencyEvents {
+		t.events = t.events[len(t.events)-maxDependencyEvents:]
+	}
+	edges := t.seenEdges()
+	t.mtx.Unlock()
+
+	for _, e := range events {
+		t.notify(e)
+	}
+	if t.store != nil {
+		return t.store.StoreDependencies(ctx, edges)
+	}
+	return nil
+}
+
+// track records the edges between the rendered nodes of a topology,
+// returning the events of the new ones. Called with t.mtx held.
+func (t *DependencyTracker) track(rc detailed.RenderContext, topologyID string, nodes report.Nodes, timestamp time.Time) []DependencyEvent {
+	_, observed := t.observed[topologyID]
+	t.observed[topologyID] = struct{}{}
+	label := func(n report.Node) string {
+		if summary, ok := detailed.MakeNodeSummary(rc, n); ok {
+			return summary.Label
+		}
+		return n.ID
+	}
+	var result []DependencyEvent
+	for _, id := range sortedIDs(nodes) {
+		n := nodes[id]
+		if n.Topology == render.Pseudo {
+			continue
+		}
+		for _, dstID := range n.Adjacency {
+			dst, ok := nodes[dstID]
+			if !ok || dst.Topology == render.Pseudo || dstID == id {
+				continue
+			}
+			key := seenKey{topologyID, id, dstID}
+			if _, ok := t.seen[key]; !ok && observed {
+				result = append(result, DependencyEvent{
+					Topology:  topologyID,
+					NodeID:    id,
+					Label:     label(n),
+					PeerID:    dstID,
+					PeerLabel: label(dst),
+					Timestamp: timestamp,
+				})
+			}
+			t.seen[key] = timestamp
+		}
+	}
+	return result
+}
+
+// seenEdges returns the edges seen, in order. Called with t.mtx held.
+func (t *DependencyTracker) seenEdges() []SeenEdge {
+	result := make([]SeenEdge, 0, len(t.seen))
+	for key, lastSeen := range t.seen {
+		result = append(result, SeenEdge{Topology: key.topology, Src: key.src, Dst: key.dst, LastSeen: lastSeen})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Topology != b.Topology {
+			return a.Topology < b.Topology
+		}
+		if a.Src != b.Src {
+			return a.Src < b.Src
+		}
+		return a.Dst < b.Dst
+	})
+	return result
+}
+
+func (t *DependencyTracker) notify(e DependencyEvent) {
+	if t.webhook == "" {
+		return
+	}
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, &codec.JsonHandle{}).Encode(e); err != nil {
+		log.Errorf("Error encoding dependency event: %v", err)
+		return
+	}
+	resp, err := t.client.Post(t.webhook, "application/json", &buf)
+	if err != nil {
+		log.Errorf("Error notifying about new dependency of %s: %v", e.NodeID, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Errorf("Error notifying about new dependency of %s: %s", e.NodeID, resp.Status)
+	}
+}
+
+// RegisterDependencyRoutes registers the route of the feed of new
+// dependencies, optionally only those since ?since=, in RFC3339.
+func RegisterDependencyRoutes(router *mux.Router, t *DependencyTracker) {
+	router.
+		Methods("GET").
+		Path("/api/dependencies/new").
+		HandlerFunc(requestContextDecorator(handleNewDependencies(t)))
+}
+
+func handleNewDependencies(t *DependencyTracker) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		var since time.Time
+		if s := r.URL.Query().Get("since"); s != "" {
+			var err error
+			if since, err = time.Parse(time.RFC3339, s); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		events := t.Events(since)
+		if events == nil {
+			events = []DependencyEvent{}
+		}
+		respondWith(w, http.StatusOK, events)
+	}
+}
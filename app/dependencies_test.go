@@ -0,0 +1,120 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+type mockDependencyStore struct {
+	edges []app.SeenEdge
+}
+
+func (s *mockDependencyStore) LoadDependencies(context.Context) ([]app.SeenEdge, error) {
+	return s.edges, nil
+}
+
+func (s *mockDependencyStore) StoreDependencies(_ context.Context, edges []app.SeenEdge) error {
+	s.edges = edges
+	return nil
+}
+
+func TestDependencyTracker(t *testing.T) {
+	events := make(chan app.DependencyEvent, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e app.DependencyEvent
+		if err := codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		events <- e
+	}))
+	defer hook.Close()
+
+	var (
+		ctx        = context.Background()
+		now        = time.Now()
+		store      = &mockDependencyStore{}
+		topologies = []string{"containers"}
+		rep        = app.StaticCollector(fixture.Report)
+	)
+	if _, err := app.NewDependencyTracker(ctx, rep, []string{"nonesuch"}, time.Hour, nil, ""); err == nil {
+		t.Error("expected an error for an unknown topology")
+	}
+	tracker, err := app.NewDependencyTracker(ctx, rep, topologies, time.Hour, store, hook.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The edges seen first are learnt, not new
+	if err := tracker.Track(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(tracker.Events(time.Time{})) != 0 || len(store.edges) != 1 {
+		t.Fatalf("Expected the edge of the client learnt, got events %v and edges %v", tracker.Events(time.Time{}), store.edges)
+	}
+
+	// Once forgotten, they are new when seen again, also after restarting
+	store.edges[0].LastSeen = now.Add(-2 * time.Hour)
+	tracker, err = app.NewDependencyTracker(ctx, rep, topologies, time.Hour, store, hook.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := now.Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		if err := tracker.Track(ctx, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := app.DependencyEvent{
+		Topology:  "containers",
+		NodeID:    fixture.ClientContainerNodeID,
+		Label:     "client",
+		PeerID:    fixture.ServerContainerNodeID,
+		PeerLabel: "server",
+		Timestamp: later,
+	}
+	have := tracker.Events(now)
+	if len(have) != 1 {
+		t.Fatalf("Expected one new dependency, got %v", have)
+	}
+	if !have[0].Timestamp.Equal(want.Timestamp) {
+		t.Errorf("Expected the event at %v, got %v", want.Timestamp, have[0].Timestamp)
+	}
+	have[0].Timestamp = want.Timestamp
+	if have[0] != want {
+		t.Errorf("Expected %v, got %v", want, have[0])
+	}
+	select {
+	case e := <-events:
+		if e.NodeID != want.NodeID || e.PeerID != want.PeerID {
+			t.Errorf("Expected the webhook to get %v, got %v", want, e)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the webhook to get the new dependency")
+	}
+	if len(tracker.Events(later)) != 0 {
+		t.Errorf("Expected no events since %v, got %v", later, tracker.Events(later))
+	}
+
+	router := mux.NewRouter()
+	app.RegisterDependencyRoutes(router, tracker)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+	_, body := checkGet(t, ts, "/api/dependencies/new?since="+url.QueryEscape(now.Format(time.RFC3339)))
+	var feed []app.DependencyEvent
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&feed); err != nil {
+		t.Fatal(err)
+	}
+	if len(feed) != 1 || feed[0].NodeID != want.NodeID {
+		t.Errorf("Expected the new dependency in the feed, got %v", feed)
+	}
+	is400(t, ts, "/api/dependencies/new?since=yesterday")
+}
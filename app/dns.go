@@ -0,0 +1,55 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// RegisterDNSRoutes registers the routes probes use to share their DNS
+// resolutions.
+func RegisterDNSRoutes(router *mux.Router, c DNSCache) {
+	router.
+		Methods("GET").
+		Path("/api/dns").
+		HandlerFunc(requestContextDecorator(handleDNSLookup(c)))
+	router.
+		Methods("POST").
+		Path("/api/dns").
+		HandlerFunc(requestContextDecorator(handleDNSAdd(c)))
+}
+
+// handleDNSLookup responds with the records known for the addresses
+// given in the address query parameters.
+func handleDNSLookup(c DNSCache) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		records, err := c.Lookup(ctx, r.URL.Query()["address"])
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		respondWith(w, http.StatusOK, records)
+	}
+}
+
+// handleDNSAdd stores the records posted by a probe.
+func handleDNSAdd(c DNSCache) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		var records report.DNSRecords
+		err := codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&records)
+		defer r.Body.Close()
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := c.Add(ctx, records); err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bluele/gcache"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	dnsCacheSize       = 100000
+	dnsCacheExpiration = 30 * time.Minute // same as the probes' own cache
+)
+
+// DNSCache is a thing that stores the DNS resolutions made by probes,
+// so they can be shared with the other probes instead of every probe
+// resolving the same addresses.
+type DNSCache interface {
+	Lookup(ctx context.Context, addresses []string) (report.DNSRecords, error)
+	Add(ctx context.Context, records report.DNSRecords) error
+}
+
+// NewLocalDNSCache creates a new DNSCache that stores resolutions
+// locally, in memory.
+func NewLocalDNSCache() DNSCache {
+	return &localDNSCache{
+		cache: gcache.New(dnsCacheSize).LRU().Expiration(dnsCacheExpiration).Build(),
+	}
+}
+
+type localDNSCache struct {
+	sync.Mutex // serialises Adds, gcache takes care of the rest
+	cache      gcache.Cache
+}
+
+func (l *localDNSCache) Lookup(_ context.Context, addresses []string) (report.DNSRecords, error) {
+	records := report.DNSRecords{}
+	for _, address := range addresses {
+		if v, err := l.cache.Get(address); err == nil {
+			records[address] = v.(report.DNSRecord)
+		}
+	}
+	return records, nil
+}
+
+func (l *localDNSCache) Add(_ context.Context, records report.DNSRecords) error {
+	l.Lock()
+	defer l.Unlock()
+	for address, record := range records {
+		if v, err := l.cache.Get(address); err == nil {
+			record = v.(report.DNSRecord).Merge(record)
+		}
+		l.cache.Set(address, record)
+	}
+	return nil
+}
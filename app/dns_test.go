@@ -0,0 +1,63 @@
+package app_test
+
+import (
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/weaveworks/common/test"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/appclient"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestDNSCache(t *testing.T) {
+	router := mux.NewRouter()
+	app.RegisterDNSRoutes(router, app.NewLocalDNSCache())
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ip, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := url.URL{Scheme: "http", Host: ip + ":" + port}
+	probe := func(id string) appclient.AppClient {
+		client, err := appclient.NewAppClient(appclient.ProbeConfig{ProbeID: id}, ip+":"+port, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+	probe1, probe2 := probe("probe1"), probe("probe2")
+	defer probe1.Stop()
+	defer probe2.Stop()
+
+	if err := probe1.PublishDNS(report.DNSRecords{
+		"1.2.3.4": {Reverse: report.MakeStringSet("a.example.com")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := probe2.PublishDNS(report.DNSRecords{
+		"1.2.3.4": {Reverse: report.MakeStringSet("b.example.com")},
+		"5.6.7.8": {Reverse: report.MakeStringSet("c.example.com")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	have, err := probe1.LookupDNS([]string{"1.2.3.4", "9.9.9.9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := report.DNSRecords{
+		"1.2.3.4": {Reverse: report.MakeStringSet("a.example.com", "b.example.com")},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
@@ -0,0 +1,278 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+// Verdicts on the executables of processes, by their hashes.
+const (
+	ExeAllowed  = "allowed"
+	ExeDenied   = "denied"
+	ExeUnlisted = "unlisted"
+)
+
+// ExeHashList lists the SHA-256 of the executables processes may, and may
+// not, run, as the probes report them with -probe.processes.exe-hashes.
+type ExeHashList struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// normalise lower-cases, sorts and dedups the hashes, checking they are
+// hex SHA-256.
+func (l ExeHashList) normalise() (ExeHashList, error) {
+	clean := func(sums []string) ([]string, error) {
+		set := map[string]struct{}{}
+		for _, sum := range sums {
+			sum = strings.ToLower(strings.TrimSpace(sum))
+			if len(sum) != 64 || strings.Trim(sum, "0123456789abcdef") != "" {
+				return nil, fmt.Errorf("not a hex SHA-256: %q", sum)
+			}
+			set[sum] = struct{}{}
+		}
+		result := make([]string, 0, len(set))
+		for sum := range set {
+			result = append(result, sum)
+		}
+		sort.Strings(result)
+		return result, nil
+	}
+	allow, err := clean(l.Allow)
+	if err != nil {
+		return ExeHashList{}, err
+	}
+	deny, err := clean(l.Deny)
+	if err != nil {
+		return ExeHashList{}, err
+	}
+	return ExeHashList{Allow: allow, Deny: deny}, nil
+}
+
+// ExeHashes holds the allow and deny lists of executables, in memory and,
+// if there is one, in a JSON file.
+type ExeHashes struct {
+	path string
+
+	mtx   sync.Mutex // also serialises writes of the file
+	list  ExeHashList
+	allow map[string]struct{}
+	deny  map[string]struct{}
+}
+
+// NewExeHashes makes ExeHashes, loading the lists from path if it exists.
+// Empty path to only keep them in memory.
+func NewExeHashes(path string) (*ExeHashes, error) {
+	h := &ExeHashes{path: path}
+	list := ExeHashList{}
+	if path != "" {
+		f, err := os.Open(path)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return nil, err
+		default:
+			defer f.Close()
+			if err := codec.NewDecoder(f, &codec.JsonHandle{}).Decode(&list); err != nil {
+				return nil, fmt.Errorf("error reading executable hashes %s: %v", path, err)
+			}
+		}
+	}
+	list, err := list.normalise()
+	if err != nil {
+		return nil, err
+	}
+	h.set(list)
+	return h, nil
+}
+
+func (h *ExeHashes) set(list ExeHashList) {
+	h.list = list
+	h.allow = map[string]struct{}{}
+	for _, sum := range list.Allow {
+		h.allow[sum] = struct{}{}
+	}
+	h.deny = map[string]struct{}{}
+	for _, sum := range list.Deny {
+		h.deny[sum] = struct{}{}
+	}
+}
+
+// List returns the allow and deny lists.
+func (h *ExeHashes) List() ExeHashList {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.list
+}
+
+// Set replaces the allow and deny lists, writing them to the file, if any.
+func (h *ExeHashes) Set(list ExeHashList) error {
+	list, err := list.normalise()
+	if err != nil {
+		return err
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if h.path != "" {
+		var buf []byte
+		if err := codec.NewEncoderBytes(&buf, &codec.JsonHandle{Indent: 2}).Encode(list); err != nil {
+			return err
+		}
+		// Renamed into place, so a crash leaves the old lists
+		tmp, err := ioutil.TempFile(filepath.Dir(h.path), ".exe-hashes")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		if _, err := tmp.Write(buf); err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), h.path); err != nil {
+			return err
+		}
+	}
+	h.set(list)
+	return nil
+}
+
+// Verdict tells whether the executable of a hash is allowed, denied or
+// neither. Denial wins over allowance.
+func (h *ExeHashes) Verdict(sum string) string {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	sum = strings.ToLower(sum)
+	if _, ok := h.deny[sum]; ok {
+		return ExeDenied
+	}
+	if _, ok := h.allow[sum]; ok {
+		return ExeAllowed
+	}
+	return ExeUnlisted
+}
+
+// ExeHashMatch is a process whose executable was hashed, and the verdict
+// on it.
+type ExeHashMatch struct {
+	NodeID  string `json:"id"`
+	Host    string `json:"host"`
+	PID     string `json:"pid"`
+	Name    string `json:"name"`
+	SHA256  string `json:"sha256"`
+	Verdict string `json:"verdict"`
+}
+
+// Match returns the processes of a report whose executables were hashed,
+// sorted by host, with the verdicts on them.
+func (h *ExeHashes) Match(rpt report.Report) []ExeHashMatch {
+	result := []ExeHashMatch{}
+	for id, n := range rpt.Process.Nodes {
+		sum, ok := n.Latest.Lookup(process.ExeSHA256)
+		if !ok {
+			continue
+		}
+		pid, _ := n.Latest.Lookup(process.PID)
+		name, _ := n.Latest.Lookup(process.Name)
+		result = append(result, ExeHashMatch{
+			NodeID:  id,
+			Host:    report.ExtractHostID(n),
+			PID:     pid,
+			Name:    name,
+			SHA256:  sum,
+			Verdict: h.Verdict(sum),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Host != result[j].Host {
+			return result[i].Host < result[j].Host
+		}
+		return result[i].NodeID < result[j].NodeID
+	})
+	return result
+}
+
+// RegisterExeHashRoutes registers the routes to get and set the allow and
+// deny lists of executables, and to match the processes of the current
+// report against them, optionally only those of a verdict, e.g.
+// /api/exe-hashes?verdict=denied.
+func RegisterExeHashRoutes(router *mux.Router, rep Reporter, h *ExeHashes) {
+	router.
+		Methods("GET").
+		Path("/api/exe-hashes").
+		HandlerFunc(requestContextDecorator(handleMatchExeHashes(rep, h)))
+	router.
+		Methods("GET").
+		Path("/api/exe-hashes/list").
+		HandlerFunc(requestContextDecorator(handleGetExeHashList(h)))
+	router.
+		Methods("PUT").
+		Path("/api/exe-hashes/list").
+		HandlerFunc(requestContextDecorator(handleSetExeHashList(h)))
+}
+
+func handleMatchExeHashes(rep Reporter, h *ExeHashes) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		verdict := r.URL.Query().Get("verdict")
+		switch verdict {
+		case "", ExeAllowed, ExeDenied, ExeUnlisted:
+		default:
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("unknown verdict: %q", verdict))
+			return
+		}
+		rpt, err := rep.Report(ctx, deserializeTimestamp(r.URL.Query().Get("timestamp")))
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		result := []ExeHashMatch{}
+		for _, m := range h.Match(rpt) {
+			if verdict == "" || m.Verdict == verdict {
+				result = append(result, m)
+			}
+		}
+		respondWith(w, http.StatusOK, result)
+	}
+}
+
+func handleGetExeHashList(h *ExeHashes) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		respondWith(w, http.StatusOK, h.List())
+	}
+}
+
+func handleSetExeHashList(h *ExeHashes) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		var list ExeHashList
+		err := codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&list)
+		defer r.Body.Close()
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		if _, err := list.normalise(); err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := h.Set(list); err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		respondWith(w, http.StatusOK, h.List())
+	}
+}
@@ -0,0 +1,78 @@
+package app_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+var (
+	sshdSHA256  = strings.Repeat("a", 64)
+	minerSHA256 = strings.Repeat("b", 64)
+	curlSHA256  = strings.Repeat("c", 64)
+)
+
+func TestAPIExeHashes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exe-hashes")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "exe-hashes.json")
+	ok(t, ioutil.WriteFile(path, []byte(`{"allow": ["`+strings.ToUpper(sshdSHA256)+`"]}`), 0600))
+	hashes, err := app.NewExeHashes(path)
+	ok(t, err)
+	equals(t, app.ExeAllowed, hashes.Verdict(sshdSHA256))
+
+	rpt := report.MakeReport()
+	for pid, p := range map[string]struct{ name, sum string }{
+		"1": {"sshd", sshdSHA256},
+		"2": {"xmrig", minerSHA256},
+		"3": {"curl", curlSHA256},
+		"4": {"[kthreadd]", ""},
+	} {
+		latests := map[string]string{process.PID: pid, process.Name: p.name, report.HostNodeID: report.MakeHostNodeID("host1")}
+		if p.sum != "" {
+			latests[process.ExeSHA256] = p.sum
+		}
+		rpt.Process.AddNode(report.MakeNodeWith(report.MakeProcessNodeID("host1", pid), latests))
+	}
+
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterExeHashRoutes(router, app.StaticCollector(rpt), hashes)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, _ := checkRequest(t, ts, "PUT", "/api/exe-hashes/list", []byte(`{"allow": ["`+sshdSHA256+`"], "deny": ["`+minerSHA256+`"]}`))
+	equals(t, http.StatusOK, res.StatusCode)
+	res, _ = checkRequest(t, ts, "PUT", "/api/exe-hashes/list", []byte(`{"deny": ["not a hash"]}`))
+	equals(t, http.StatusBadRequest, res.StatusCode)
+
+	var matches []app.ExeHashMatch
+	ok(t, codec.NewDecoderBytes(getRawJSON(t, ts, "/api/exe-hashes"), &codec.JsonHandle{}).Decode(&matches))
+	verdicts := map[string]string{}
+	for _, m := range matches {
+		equals(t, "host1", m.Host)
+		verdicts[m.Name] = m.Verdict
+	}
+	equals(t, map[string]string{"sshd": app.ExeAllowed, "xmrig": app.ExeDenied, "curl": app.ExeUnlisted}, verdicts)
+
+	ok(t, codec.NewDecoderBytes(getRawJSON(t, ts, "/api/exe-hashes?verdict=denied"), &codec.JsonHandle{}).Decode(&matches))
+	equals(t, 1, len(matches))
+	equals(t, "xmrig", matches[0].Name)
+	is400(t, ts, "/api/exe-hashes?verdict=maybe")
+
+	// The lists are kept in the file for the next start
+	reloaded, err := app.NewExeHashes(path)
+	ok(t, err)
+	equals(t, app.ExeHashList{Allow: []string{sshdSHA256}, Deny: []string{minerSHA256}}, reloaded.List())
+}
@@ -0,0 +1,242 @@
+package app
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// DefaultFlowLogFormat is the fields of the records of VPC flow logs in the
+// default format (version 2).
+var DefaultFlowLogFormat = []string{
+	"version", "account-id", "interface-id", "srcaddr", "dstaddr", "srcport", "dstport",
+	"protocol", "packets", "bytes", "start", "end", "action", "log-status",
+}
+
+// FlowLogSource is somewhere VPC flow logs are delivered to, e.g. an S3
+// bucket or a Kinesis stream.
+type FlowLogSource interface {
+	// Fetch returns the lines of the flow logs delivered since it was
+	// last called. Files of flow logs start with a header line, naming the
+	// fields of their records.
+	Fetch(ctx context.Context) ([]string, error)
+}
+
+// FlowLogRecord is an accepted flow between two endpoints, as a network
+// interface of a VPC saw it.
+type FlowLogRecord struct {
+	InterfaceID string
+	InstanceID  string // if the format of the flow logs has it
+	SrcAddr     string
+	SrcPort     string
+	DstAddr     string
+	DstPort     string
+	Protocol    string // report.TCP or report.UDP
+	Direction   string // "ingress" or "egress", if the format of the flow logs has it
+	End         time.Time
+}
+
+// IANA numbers of the protocols we make edges of.
+var flowLogProtocols = map[string]string{
+	"6":  report.TCP,
+	"17": report.UDP,
+}
+
+// ParseFlowLog parses the lines of flow logs, in the given format unless
+// a header line gives another. Records of rejected, or unknown, flows, of
+// protocols without ports, and which can't be parsed are skipped.
+func ParseFlowLog(lines []string, format []string) []FlowLogRecord {
+	var records []FlowLogRecord
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "version" {
+			format = fields
+			continue
+		}
+		if len(fields) != len(format) {
+			continue
+		}
+		values := make(map[string]string, len(fields))
+		for i, field := range fields {
+			values[format[i]] = field
+		}
+		protocol, ok := flowLogProtocols[values["protocol"]]
+		if !ok || values["action"] != "ACCEPT" || values["log-status"] != "OK" {
+			continue
+		}
+		end, err := strconv.ParseInt(values["end"], 10, 64)
+		if err != nil {
+			continue
+		}
+		record := FlowLogRecord{
+			InterfaceID: values["interface-id"],
+			InstanceID:  values["instance-id"],
+			SrcAddr:     values["srcaddr"],
+			SrcPort:     values["srcport"],
+			DstAddr:     values["dstaddr"],
+			DstPort:     values["dstport"],
+			Protocol:    protocol,
+			Direction:   values["flow-direction"],
+			End:         time.Unix(end, 0),
+		}
+		if record.InstanceID == "-" {
+			record.InstanceID = ""
+		}
+		if record.Direction == "-" {
+			record.Direction = ""
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+type flowLogKey struct {
+	interfaceID, protocol, srcAddr, srcPort, dstAddr, dstPort string
+}
+
+// FlowLogIngester adds the flows of VPC flow logs to a collector, as
+// endpoints with edges between them, so connections to and from instances
+// without probes show up too. Endpoints of the flow logs get the same IDs
+// as those of probes, so the two are merged.
+//
+// Flow logs are delivered minutes after the fact, so the flows seen over
+// the retention are reported again every half window.
+type FlowLogIngester struct {
+	source    FlowLogSource
+	adder     Adder
+	format    []string
+	retention time.Duration
+	cluster   string
+	quit      chan struct{}
+
+	mtx   sync.Mutex
+	flows map[flowLogKey]FlowLogRecord // the latest record of each flow
+}
+
+// NewFlowLogIngester makes a new FlowLogIngester, fetching flow logs in the
+// given format, unless they have a header, from the source. The addresses
+// of the flows are scoped by the cluster, if any, as the probes in it scope
+// theirs.
+func NewFlowLogIngester(source FlowLogSource, adder Adder, format []string, retention time.Duration, cluster string) *FlowLogIngester {
+	return &FlowLogIngester{
+		source:    source,
+		adder:     adder,
+		format:    format,
+		retention: retention,
+		cluster:   cluster,
+		quit:      make(chan struct{}),
+		flows:     map[flowLogKey]FlowLogRecord{},
+	}
+}
+
+// Start ingests flow logs every interval, until stopped.
+func (i *FlowLogIngester) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := i.Ingest(context.Background(), time.Now()); err != nil {
+					log.Errorf("Error ingesting VPC flow logs: %v", err)
+				}
+			case <-i.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops ingesting flow logs.
+func (i *FlowLogIngester) Stop() {
+	close(i.quit)
+}
+
+// Ingest fetches the flow logs delivered since last time, and adds a
+// report of the flows seen over the retention before now. The report is
+// added even if fetching fails, so flows seen before don't disappear.
+func (i *FlowLogIngester) Ingest(ctx context.Context, now time.Time) error {
+	lines, fetchErr := i.source.Fetch(ctx)
+	records := ParseFlowLog(lines, i.format)
+
+	i.mtx.Lock()
+	for _, record := range records {
+		key := flowLogKey{record.InterfaceID, record.Protocol, record.SrcAddr, record.SrcPort, record.DstAddr, record.DstPort}
+		if old, ok := i.flows[key]; !ok || record.End.After(old.End) {
+			i.flows[key] = record
+		}
+	}
+	for key, record := range i.flows {
+		if record.End.Before(now.Add(-i.retention)) {
+			delete(i.flows, key)
+		}
+	}
+	rpt := i.report()
+	i.mtx.Unlock()
+
+	if len(rpt.Endpoint.Nodes) > 0 {
+		if err := i.adder.Add(ctx, rpt, nil); err != nil {
+			return err
+		}
+	}
+	return fetchErr
+}
+
+// report makes a report of the flows. Only one end of a flow is the
+// address of the interface which saw it, which the direction of the flow
+// tells. Otherwise, it's the address seen in the most flows of the
+// interface. Needs i.mtx held.
+func (i *FlowLogIngester) report() report.Report {
+	rpt := report.MakeReport()
+	seen := map[string]map[string]int{} // interface ID -> address -> flows
+	for _, record := range i.flows {
+		addresses, ok := seen[record.InterfaceID]
+		if !ok {
+			addresses = map[string]int{}
+			seen[record.InterfaceID] = addresses
+		}
+		addresses[record.SrcAddr]++
+		addresses[record.DstAddr]++
+	}
+	interfaceAddress := func(record FlowLogRecord) string {
+		switch record.Direction {
+		case "ingress":
+			return record.DstAddr
+		case "egress":
+			return record.SrcAddr
+		}
+		if seen[record.InterfaceID][record.SrcAddr] >= seen[record.InterfaceID][record.DstAddr] {
+			return record.SrcAddr
+		}
+		return record.DstAddr
+	}
+
+	for _, record := range i.flows {
+		srcID := report.MakeClusterEndpointNodeID(i.cluster, "", "", record.SrcAddr, record.SrcPort, record.Protocol)
+		dstID := report.MakeClusterEndpointNodeID(i.cluster, "", "", record.DstAddr, record.DstPort, record.Protocol)
+		src := report.MakeNode(srcID).WithTopology(report.Endpoint).WithAdjacent(dstID)
+		dst := report.MakeNode(dstID).WithTopology(report.Endpoint)
+
+		latests := map[string]string{report.VPCInterfaceID: record.InterfaceID}
+		if record.InstanceID != "" {
+			latests[report.EC2InstanceID] = record.InstanceID
+		}
+		if interfaceAddress(record) == record.SrcAddr {
+			src = src.WithLatests(latests)
+		} else {
+			dst = dst.WithLatests(latests)
+		}
+		rpt.Endpoint.AddNode(src)
+		rpt.Endpoint.AddNode(dst)
+	}
+	return rpt
+}
@@ -0,0 +1,125 @@
+package app_test
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/report"
+)
+
+type mockFlowLogSource struct {
+	lines []string
+}
+
+func (s *mockFlowLogSource) Fetch(context.Context) ([]string, error) {
+	lines := s.lines
+	s.lines = nil
+	return lines, nil
+}
+
+func TestParseFlowLog(t *testing.T) {
+	records := app.ParseFlowLog([]string{
+		"2 123456789010 eni-1235b8ca 172.31.16.139 172.31.16.21 20641 22 6 20 4249 1418530010 1418530070 ACCEPT OK",
+		"2 123456789010 eni-1235b8ca 172.31.9.69 172.31.9.12 49761 3389 6 20 4249 1418530010 1418530070 REJECT OK",
+		"2 123456789010 eni-1235b8ca 172.31.9.69 172.31.9.12 0 0 1 4 336 1432917027 1432917142 ACCEPT OK",
+		"2 123456789010 eni-1a2b3c4d - - - - - - - 1431280876 1431280934 - NODATA",
+		"version interface-id instance-id srcaddr dstaddr srcport dstport protocol start end action log-status flow-direction",
+		"5 eni-1a2b3c4d i-01234567890123456 10.0.1.5 10.0.0.220 53 49152 17 1566848875 1566848933 ACCEPT OK egress",
+	}, app.DefaultFlowLogFormat)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 accepted TCP or UDP flows, got %v", records)
+	}
+	if want := (app.FlowLogRecord{
+		InterfaceID: "eni-1a2b3c4d",
+		InstanceID:  "i-01234567890123456",
+		SrcAddr:     "10.0.1.5",
+		SrcPort:     "53",
+		DstAddr:     "10.0.0.220",
+		DstPort:     "49152",
+		Protocol:    report.UDP,
+		Direction:   "egress",
+		End:         time.Unix(1566848933, 0),
+	}); records[1] != want {
+		t.Errorf("Expected %v, got %v", want, records[1])
+	}
+}
+
+type mockAdder struct {
+	reports []report.Report
+}
+
+func (a *mockAdder) Add(_ context.Context, rpt report.Report, _ []byte) error {
+	a.reports = append(a.reports, rpt)
+	return nil
+}
+
+func TestFlowLogIngester(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	source := &mockFlowLogSource{lines: []string{
+		// 10.0.0.5 is in both flows of eni-1, so it has the interface
+		"2 123456789010 eni-1 10.0.0.5 10.0.0.6 40000 80 6 10 840 1499999900 1499999940 ACCEPT OK",
+		"2 123456789010 eni-1 10.0.0.7 10.0.0.5 50000 22 6 10 840 1499999900 1499999950 ACCEPT OK",
+		// Older than the retention
+		"2 123456789010 eni-2 10.0.0.8 10.0.0.9 40000 80 6 10 840 1499990000 1499990060 ACCEPT OK",
+	}}
+	adder := &mockAdder{}
+	ingester := app.NewFlowLogIngester(source, adder, app.DefaultFlowLogFormat, 15*time.Minute, "")
+	ctx := context.Background()
+	if err := ingester.Ingest(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(adder.reports) != 1 {
+		t.Fatalf("Expected a report to be added, got %d", len(adder.reports))
+	}
+	rpt := adder.reports[0]
+	if len(rpt.Endpoint.Nodes) != 4 {
+		t.Fatalf("Expected the 4 endpoints of the recent flows, got %v", rpt.Endpoint.Nodes)
+	}
+	src := rpt.Endpoint.Nodes[report.MakeEndpointNodeID("", "", "10.0.0.5", "40000")]
+	if !src.Adjacency.Contains(report.MakeEndpointNodeID("", "", "10.0.0.6", "80")) {
+		t.Errorf("Expected an edge to the destination, got %v", src.Adjacency)
+	}
+	for id, want := range map[string]string{
+		report.MakeEndpointNodeID("", "", "10.0.0.5", "40000"): "eni-1",
+		report.MakeEndpointNodeID("", "", "10.0.0.5", "22"):    "eni-1",
+		report.MakeEndpointNodeID("", "", "10.0.0.6", "80"):    "",
+		report.MakeEndpointNodeID("", "", "10.0.0.7", "50000"): "",
+	} {
+		if have, _ := rpt.Endpoint.Nodes[id].Latest.Lookup(report.VPCInterfaceID); have != want {
+			t.Errorf("Expected endpoint %s on interface %q, got %q", id, want, have)
+		}
+	}
+
+	// Flows are reported again, until they are older than the retention
+	if err := ingester.Ingest(ctx, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(adder.reports) != 2 || len(adder.reports[1].Endpoint.Nodes) != 4 {
+		t.Errorf("Expected the flows to be reported again, got %v", adder.reports[1:])
+	}
+	if err := ingester.Ingest(ctx, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(adder.reports) != 2 {
+		t.Errorf("Expected no report once the flows are too old, got %v", adder.reports[2:])
+	}
+}
+
+func TestFlowLogIngesterCluster(t *testing.T) {
+	source := &mockFlowLogSource{lines: []string{
+		"2 123456789010 eni-1 10.0.0.5 10.0.0.6 40000 80 6 10 840 1499999900 1499999940 ACCEPT OK",
+	}}
+	adder := &mockAdder{}
+	ingester := app.NewFlowLogIngester(source, adder, app.DefaultFlowLogFormat, 15*time.Minute, "east")
+	if err := ingester.Ingest(context.Background(), time.Unix(1500000000, 0)); err != nil {
+		t.Fatal(err)
+	}
+	// The endpoints of the probes of the cluster
+	src, ok := adder.reports[0].Endpoint.Nodes[report.MakeClusterEndpointNodeID("east", "", "", "10.0.0.5", "40000", "")]
+	if !ok || !src.Adjacency.Contains(report.MakeClusterEndpointNodeID("east", "", "", "10.0.0.6", "80", "")) {
+		t.Errorf("Expected endpoints scoped by the cluster, got %v", adder.reports[0].Endpoint.Nodes)
+	}
+}
@@ -0,0 +1,551 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	netcontext "golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+// graphqlSchema maps the topologies the app renders, their nodes and the
+// probes publishing reports to GraphQL, for /api/graphql.
+const graphqlSchema = `
+schema {
+	query: Query
+}
+
+type Query {
+	# The top-level topologies, with their sub-topologies.
+	topologies(timestamp: String): [Topology!]!
+	# A topology, rendered with the given options, e.g. {name: "system",
+	# value: "application"}. Timestamps are RFC3339; the default is now.
+	topology(id: ID!, options: [Option!], timestamp: String): Topology
+	# The probes publishing reports.
+	probes(timestamp: String): [Probe!]!
+}
+
+input Option {
+	name: String!
+	value: String!
+}
+
+type Topology {
+	id: ID!
+	name: String!
+	rank: Int!
+	subTopologies: [Topology!]!
+	# The nodes, or only those matching a query as for /api/query but
+	# without naming the topology, e.g. "label~\"api-*\" cpu>80%".
+	nodes(query: String): [Node!]!
+	node(id: ID!): Node
+	edges: [Edge!]!
+}
+
+type Edge {
+	source: Node!
+	target: Node!
+}
+
+type Node {
+	id: ID!
+	label: String!
+	labelMinor: String!
+	rank: String!
+	shape: String!
+	pseudo: Boolean!
+	metadata: [MetadataRow!]!
+	metrics: [Metric!]!
+	parents: [Parent!]!
+	children: [NodeGroup!]!
+	adjacent: [Node!]!
+}
+
+type MetadataRow {
+	id: ID!
+	label: String!
+	value: String!
+}
+
+type Metric {
+	id: ID!
+	label: String!
+	format: String!
+	group: String!
+	value: Float
+	min: Float!
+	max: Float!
+	samples: [Sample!]!
+}
+
+type Sample {
+	timestamp: String!
+	value: Float!
+}
+
+type Parent {
+	id: ID!
+	label: String!
+	topologyId: ID!
+	node: Node
+}
+
+type NodeGroup {
+	id: ID!
+	label: String!
+	topologyId: ID!
+	nodes: [Node!]!
+}
+
+type Probe {
+	id: ID!
+	hostname: String!
+	version: String!
+	lastSeen: String!
+}
+`
+
+// The resolvers take the standard library's context, rather than
+// golang.org/x/net/context as the rest of the app, as graphql-go only
+// recognises that.
+type graphqlResolver struct {
+	rep Reporter
+}
+
+func (r *graphqlResolver) report(ctx context.Context, timestamp *string) (*graphqlReport, error) {
+	t := time.Now()
+	if timestamp != nil {
+		var err error
+		if t, err = time.Parse(time.RFC3339, *timestamp); err != nil {
+			return nil, err
+		}
+	}
+	rpt, err := r.rep.Report(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	return &graphqlReport{
+		rpt:        rpt,
+		rc:         RenderContextForReporter(r.rep, rpt),
+		topologies: map[string]*graphqlTopology{},
+	}, nil
+}
+
+func (r *graphqlResolver) Topologies(ctx context.Context, args struct{ Timestamp *string }) ([]*graphqlTopology, error) {
+	rpt, err := r.report(ctx, args.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	result := []*graphqlTopology{}
+	topologyRegistry.walk(func(desc APITopologyDesc) {
+		result = append(result, rpt.topology(desc))
+	})
+	return result, nil
+}
+
+type graphqlOption struct {
+	Name, Value string
+}
+
+func (r *graphqlResolver) Topology(ctx context.Context, args struct {
+	ID        graphql.ID
+	Options   *[]graphqlOption
+	Timestamp *string
+}) (*graphqlTopology, error) {
+	desc, ok := topologyRegistry.get(string(args.ID))
+	if !ok {
+		return nil, nil
+	}
+	rpt, err := r.report(ctx, args.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if args.Options == nil {
+		return rpt.topology(desc), nil
+	}
+	options := url.Values{}
+	for _, o := range *args.Options {
+		options.Add(o.Name, o.Value)
+	}
+	return &graphqlTopology{report: rpt, desc: desc, options: options}, nil
+}
+
+func (r *graphqlResolver) Probes(ctx context.Context, args struct{ Timestamp *string }) ([]*graphqlProbe, error) {
+	rpt, err := r.report(ctx, args.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	result := []*graphqlProbe{}
+	for _, id := range sortedIDs(rpt.rpt.Host.Nodes) {
+		n := rpt.rpt.Host.Nodes[id]
+		probeID, _ := n.Latest.Lookup(report.ControlProbeID)
+		hostname, _ := n.Latest.Lookup(host.HostName)
+		version, dt, _ := n.Latest.LookupEntry(host.ScopeVersion)
+		result = append(result, &graphqlProbe{probeDesc{
+			ID:       probeID,
+			Hostname: hostname,
+			Version:  version,
+			LastSeen: dt,
+		}})
+	}
+	return result, nil
+}
+
+// graphqlReport is the report a query is resolved against, with the
+// topologies rendered from it so far with their default options, which
+// parents and children are looked up in.
+type graphqlReport struct {
+	rpt report.Report
+	rc  detailed.RenderContext
+
+	mtx        sync.Mutex
+	topologies map[string]*graphqlTopology
+}
+
+func (r *graphqlReport) topology(desc APITopologyDesc) *graphqlTopology {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	t, ok := r.topologies[desc.id]
+	if !ok {
+		t = &graphqlTopology{report: r, desc: desc}
+		r.topologies[desc.id] = t
+	}
+	return t
+}
+
+// lookup returns a node of a topology rendered with its default options,
+// or nil.
+func (r *graphqlReport) lookup(topologyID, id string) (*graphqlNode, error) {
+	desc, ok := topologyRegistry.get(topologyID)
+	if !ok {
+		return nil, nil
+	}
+	return r.topology(desc).Node(struct{ ID graphql.ID }{graphql.ID(id)})
+}
+
+// graphqlTopology is a topology, rendered on first use.
+type graphqlTopology struct {
+	report  *graphqlReport
+	desc    APITopologyDesc
+	options url.Values
+
+	once      sync.Once
+	err       error
+	nodes     report.Nodes
+	summaries map[string]*graphqlNode
+}
+
+func (t *graphqlTopology) render() error {
+	t.once.Do(func() {
+		renderer, filter, err := topologyRegistry.RendererForTopology(t.desc.id, t.options, t.report.rpt)
+		if err != nil {
+			t.err = err
+			return
+		}
+		t.nodes = render.Render(t.report.rpt, renderer, filter).Nodes
+		t.summaries = map[string]*graphqlNode{}
+		for id, n := range t.nodes {
+			if summary, ok := detailed.MakeNodeSummary(t.report.rc, n); ok {
+				t.summaries[id] = &graphqlNode{topology: t, summary: summary}
+			}
+		}
+	})
+	return t.err
+}
+
+func (t *graphqlTopology) ID() graphql.ID { return graphql.ID(t.desc.id) }
+func (t *graphqlTopology) Name() string   { return t.desc.Name }
+func (t *graphqlTopology) Rank() int32    { return int32(t.desc.Rank) }
+
+func (t *graphqlTopology) SubTopologies() []*graphqlTopology {
+	result := []*graphqlTopology{}
+	for _, sub := range t.desc.SubTopologies {
+		result = append(result, t.report.topology(sub))
+	}
+	return result
+}
+
+func (t *graphqlTopology) Nodes(args struct{ Query *string }) ([]*graphqlNode, error) {
+	if err := t.render(); err != nil {
+		return nil, err
+	}
+	var ids []string
+	if args.Query == nil {
+		ids = sortedIDs(t.nodes)
+	} else {
+		q, err := parseQuery(*args.Query)
+		if err != nil {
+			return nil, err
+		}
+		if q.Topology != "" {
+			return nil, fmt.Errorf("queries of nodes can't name a topology")
+		}
+		ids = q.Run(t.report.rc, t.nodes)
+	}
+	result := []*graphqlNode{}
+	for _, id := range ids {
+		if n, ok := t.summaries[id]; ok {
+			result = append(result, n)
+		}
+	}
+	return result, nil
+}
+
+func (t *graphqlTopology) Node(args struct{ ID graphql.ID }) (*graphqlNode, error) {
+	if err := t.render(); err != nil {
+		return nil, err
+	}
+	return t.summaries[string(args.ID)], nil
+}
+
+func (t *graphqlTopology) Edges() ([]*graphqlEdge, error) {
+	if err := t.render(); err != nil {
+		return nil, err
+	}
+	result := []*graphqlEdge{}
+	for _, id := range sortedIDs(t.nodes) {
+		source, ok := t.summaries[id]
+		if !ok {
+			continue
+		}
+		for _, dst := range t.nodes[id].Adjacency {
+			if target, ok := t.summaries[dst]; ok {
+				result = append(result, &graphqlEdge{source, target})
+			}
+		}
+	}
+	return result, nil
+}
+
+type graphqlEdge struct {
+	source, target *graphqlNode
+}
+
+func (e *graphqlEdge) Source() *graphqlNode { return e.source }
+func (e *graphqlEdge) Target() *graphqlNode { return e.target }
+
+// graphqlNode is a node of a topology. Children nodes may have been
+// filtered out of their own topology, and so have no adjacent nodes nor
+// children.
+type graphqlNode struct {
+	topology *graphqlTopology
+	summary  detailed.NodeSummary
+}
+
+func (n *graphqlNode) ID() graphql.ID     { return graphql.ID(n.summary.ID) }
+func (n *graphqlNode) Label() string      { return n.summary.Label }
+func (n *graphqlNode) LabelMinor() string { return n.summary.LabelMinor }
+func (n *graphqlNode) Rank() string       { return n.summary.Rank }
+func (n *graphqlNode) Shape() string      { return n.summary.Shape }
+func (n *graphqlNode) Pseudo() bool       { return n.summary.Pseudo }
+
+func (n *graphqlNode) Metadata() []*graphqlMetadataRow {
+	result := []*graphqlMetadataRow{}
+	for _, row := range n.summary.Metadata {
+		result = append(result, &graphqlMetadataRow{row})
+	}
+	return result
+}
+
+func (n *graphqlNode) Metrics() []*graphqlMetric {
+	result := []*graphqlMetric{}
+	for _, row := range n.summary.Metrics {
+		result = append(result, &graphqlMetric{row})
+	}
+	return result
+}
+
+func (n *graphqlNode) Parents() []*graphqlParent {
+	result := []*graphqlParent{}
+	for _, p := range n.summary.Parents {
+		result = append(result, &graphqlParent{report: n.topology.report, parent: p})
+	}
+	return result
+}
+
+func (n *graphqlNode) Children() ([]*graphqlNodeGroup, error) {
+	if err := n.topology.render(); err != nil {
+		return nil, err
+	}
+	result := []*graphqlNodeGroup{}
+	node, ok := n.topology.nodes[n.summary.ID]
+	if !ok {
+		return result, nil
+	}
+	rc := n.topology.report.rc
+	for _, group := range detailed.MakeNode(n.topology.desc.id, rc, n.topology.nodes, node).Children {
+		result = append(result, &graphqlNodeGroup{report: n.topology.report, group: group})
+	}
+	return result, nil
+}
+
+func (n *graphqlNode) Adjacent() ([]*graphqlNode, error) {
+	if err := n.topology.render(); err != nil {
+		return nil, err
+	}
+	result := []*graphqlNode{}
+	for _, id := range n.topology.nodes[n.summary.ID].Adjacency {
+		if adjacent, ok := n.topology.summaries[id]; ok {
+			result = append(result, adjacent)
+		}
+	}
+	return result, nil
+}
+
+type graphqlMetadataRow struct {
+	row report.MetadataRow
+}
+
+func (r *graphqlMetadataRow) ID() graphql.ID { return graphql.ID(r.row.ID) }
+func (r *graphqlMetadataRow) Label() string  { return r.row.Label }
+func (r *graphqlMetadataRow) Value() string  { return r.row.Value }
+
+type graphqlMetric struct {
+	row report.MetricRow
+}
+
+func (m *graphqlMetric) ID() graphql.ID { return graphql.ID(m.row.ID) }
+func (m *graphqlMetric) Label() string  { return m.row.Label }
+func (m *graphqlMetric) Format() string { return m.row.Format }
+func (m *graphqlMetric) Group() string  { return m.row.Group }
+
+func (m *graphqlMetric) Value() *float64 {
+	if m.row.ValueEmpty {
+		return nil
+	}
+	return &m.row.Value
+}
+
+func (m *graphqlMetric) Min() float64 {
+	if m.row.Metric == nil {
+		return 0
+	}
+	return m.row.Metric.Min
+}
+
+func (m *graphqlMetric) Max() float64 {
+	if m.row.Metric == nil {
+		return 0
+	}
+	return m.row.Metric.Max
+}
+
+func (m *graphqlMetric) Samples() []*graphqlSample {
+	result := []*graphqlSample{}
+	if m.row.Metric != nil {
+		for _, s := range m.row.Metric.Samples {
+			result = append(result, &graphqlSample{s})
+		}
+	}
+	return result
+}
+
+type graphqlSample struct {
+	sample report.Sample
+}
+
+func (s *graphqlSample) Timestamp() string { return s.sample.Timestamp.Format(time.RFC3339Nano) }
+func (s *graphqlSample) Value() float64    { return s.sample.Value }
+
+type graphqlParent struct {
+	report *graphqlReport
+	parent detailed.Parent
+}
+
+func (p *graphqlParent) ID() graphql.ID         { return graphql.ID(p.parent.ID) }
+func (p *graphqlParent) Label() string          { return p.parent.Label }
+func (p *graphqlParent) TopologyID() graphql.ID { return graphql.ID(p.parent.TopologyID) }
+
+func (p *graphqlParent) Node() (*graphqlNode, error) {
+	return p.report.lookup(p.parent.TopologyID, p.parent.ID)
+}
+
+type graphqlNodeGroup struct {
+	report *graphqlReport
+	group  detailed.NodeSummaryGroup
+}
+
+func (g *graphqlNodeGroup) ID() graphql.ID         { return graphql.ID(g.group.ID) }
+func (g *graphqlNodeGroup) Label() string          { return g.group.Label }
+func (g *graphqlNodeGroup) TopologyID() graphql.ID { return graphql.ID(g.group.TopologyID) }
+
+func (g *graphqlNodeGroup) Nodes() ([]*graphqlNode, error) {
+	result := []*graphqlNode{}
+	desc, ok := topologyRegistry.get(g.group.TopologyID)
+	if !ok {
+		return result, nil
+	}
+	topology := g.report.topology(desc)
+	for _, summary := range g.group.Nodes {
+		n, err := g.report.lookup(g.group.TopologyID, summary.ID)
+		if err != nil {
+			return nil, err
+		}
+		if n == nil {
+			n = &graphqlNode{topology: topology, summary: summary}
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+type graphqlProbe struct {
+	desc probeDesc
+}
+
+func (p *graphqlProbe) ID() graphql.ID   { return graphql.ID(p.desc.ID) }
+func (p *graphqlProbe) Hostname() string { return p.desc.Hostname }
+func (p *graphqlProbe) Version() string  { return p.desc.Version }
+func (p *graphqlProbe) LastSeen() string { return p.desc.LastSeen.Format(time.RFC3339) }
+
+// graphqlRequest is a GraphQL query, POSTed as JSON or in the parameters
+// of a GET, with the variables JSON encoded.
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// makeGraphQLHandler serves GraphQL queries over the reports of rep.
+func makeGraphQLHandler(rep Reporter) CtxHandlerFunc {
+	schema := graphql.MustParseSchema(graphqlSchema, &graphqlResolver{rep: rep})
+	return func(ctx netcontext.Context, w http.ResponseWriter, r *http.Request) {
+		var req graphqlRequest
+		if r.Method == "POST" {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+		} else {
+			query := r.URL.Query()
+			req.Query = query.Get("query")
+			req.OperationName = query.Get("operationName")
+			if variables := query.Get("variables"); variables != "" {
+				if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+					respondWith(w, http.StatusBadRequest, err)
+					return
+				}
+			}
+		}
+
+		// The response is encoded with encoding/json, which the data, a
+		// json.RawMessage, relies on.
+		response := schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package app_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestAPIGraphQL(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	type node struct {
+		ID       string
+		Label    string
+		Adjacent []struct{ ID string }
+		Parents  []struct {
+			TopologyID string
+			Node       *struct{ Label string }
+		}
+		Children []struct {
+			TopologyID string
+			Nodes      []struct{ ID string }
+		}
+		Metrics []struct {
+			ID    string
+			Value *float64
+		}
+	}
+	var result struct {
+		Data struct {
+			Topology struct {
+				Nodes []node
+				Edges []struct {
+					Source, Target struct{ ID string }
+				}
+			}
+			Probes []struct{ Hostname string }
+		}
+		Errors []struct{ Message string }
+	}
+	query := `query($query: String) {
+		topology(id: "containers") {
+			nodes(query: $query) {
+				id label
+				adjacent { id }
+				parents { topologyId node { label } }
+				children { topologyId nodes { id } }
+				metrics { id value }
+			}
+			edges { source { id } target { id } }
+		}
+		probes { hostname }
+	}`
+	body, _ := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": map[string]interface{}{"query": `label~"cli*"`},
+	})
+	_, raw := checkRequest(t, ts, "POST", "/api/graphql", body)
+	if err := json.Unmarshal(raw, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors)
+	}
+
+	nodes := result.Data.Topology.Nodes
+	if len(nodes) != 1 || nodes[0].ID != fixture.ClientContainerNodeID || nodes[0].Label != "client" {
+		t.Fatalf("want the client container, have %v", nodes)
+	}
+	client := nodes[0]
+	if len(client.Adjacent) != 1 || client.Adjacent[0].ID != fixture.ServerContainerNodeID {
+		t.Errorf("want the client adjacent to the server, have %v", client.Adjacent)
+	}
+	parents := map[string]string{}
+	for _, p := range client.Parents {
+		if p.Node != nil {
+			parents[p.TopologyID] = p.Node.Label
+		}
+	}
+	if want := map[string]string{"hosts": "client", "pods": "pong-a", "containers-by-image": "image/client"}; !reflect.DeepEqual(want, parents) {
+		t.Errorf("want parents %v, have %v", want, parents)
+	}
+	if len(client.Children) != 1 || client.Children[0].TopologyID != "processes" || len(client.Children[0].Nodes) != 2 {
+		t.Errorf("want the two client processes as children, have %v", client.Children)
+	}
+	if len(client.Metrics) != 2 || client.Metrics[0].Value == nil || *client.Metrics[0].Value != 0.03 {
+		t.Errorf("want the client metrics, have %v", client.Metrics)
+	}
+
+	edges := result.Data.Topology.Edges
+	found := false
+	for _, e := range edges {
+		found = found || (e.Source.ID == fixture.ClientContainerNodeID && e.Target.ID == fixture.ServerContainerNodeID)
+	}
+	if !found {
+		t.Errorf("want an edge from the client to the server, have %v", edges)
+	}
+	if len(result.Data.Probes) != 2 {
+		t.Errorf("want two probes, have %v", result.Data.Probes)
+	}
+
+	// Queries may be GET too, and errors are reported in the response
+	for _, query := range []string{
+		`{ topology(id: "containers") { nodes(query: "topology=hosts") { id } } }`,
+		`{ topology(id: "containers") { nonesuch } }`,
+		`{ topologies(timestamp: "yesterday") { id } }`,
+	} {
+		raw := getRawJSON(t, ts, "/api/graphql?query="+url.QueryEscape(query))
+		result.Errors = nil
+		if err := json.Unmarshal(raw, &result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Errors) == 0 {
+			t.Errorf("%s: want errors", query)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+// ingestSweepInterval is how often the buckets of probes and tenants which
+// have stopped publishing are forgotten.
+const ingestSweepInterval = time.Minute
+
+var (
+	ingestReports = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "scope",
+		Name:      "ingest_reports_total",
+		Help:      "Reports let through the ingestion limits.",
+	})
+	ingestBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "scope",
+		Name:      "ingest_bytes_total",
+		Help:      "Bytes of reports let through the ingestion limits.",
+	})
+	ingestLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scope",
+		Name:      "ingest_limited_reports_total",
+		Help:      "Reports rejected, or delayed over gRPC, for being over an ingestion limit.",
+	}, []string{"limit"})
+)
+
+// MustRegisterIngestMetrics registers the metrics of IngestLimiters.
+func MustRegisterIngestMetrics() {
+	prometheus.MustRegister(ingestReports)
+	prometheus.MustRegister(ingestBytes)
+	prometheus.MustRegister(ingestLimited)
+}
+
+// IngestLimits are the most reports, and bytes of reports, each probe and
+// each tenant may publish per second, on average over Burst. Zero rates are
+// unlimited.
+type IngestLimits struct {
+	ProbeReports, ProbeBytes   float64
+	TenantReports, TenantBytes float64
+	Burst                      time.Duration
+}
+
+// tokenBucket lets through rate tokens a second, up to its capacity at
+// once. A take bigger than the capacity is let through once the bucket is
+// full, leaving it in debt.
+type tokenBucket struct {
+	rate, capacity, tokens float64
+	last                   time.Time
+}
+
+func newTokenBucket(rate float64, burst time.Duration, min float64) *tokenBucket {
+	capacity := math.Max(rate*burst.Seconds(), min)
+	return &tokenBucket{rate: rate, capacity: capacity, tokens: capacity, last: mtime.Now()}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// wait returns how long until n tokens can be taken.
+func (b *tokenBucket) wait(n float64) time.Duration {
+	need := math.Min(n, b.capacity)
+	if b.tokens >= need {
+		return 0
+	}
+	return time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) full() bool {
+	return b.tokens >= b.capacity
+}
+
+// ingestBuckets are the buckets of the reports and bytes of a probe or a
+// tenant, nil when unlimited.
+type ingestBuckets struct {
+	reports, bytes *tokenBucket
+}
+
+// IngestLimiter enforces IngestLimits on the reports probes publish.
+type IngestLimiter struct {
+	limits   IngestLimits
+	tenantID func(context.Context) (string, error)
+
+	mtx       sync.Mutex
+	probes    map[string]*ingestBuckets
+	tenants   map[string]*ingestBuckets
+	lastSweep time.Time
+}
+
+// NewIngestLimiter makes an IngestLimiter. Tenants are told by tenantID, of
+// the context of requests, as in multitenant.UserIDer; nil for a single
+// tenant.
+func NewIngestLimiter(limits IngestLimits, tenantID func(context.Context) (string, error)) *IngestLimiter {
+	return &IngestLimiter{
+		limits:    limits,
+		tenantID:  tenantID,
+		probes:    map[string]*ingestBuckets{},
+		tenants:   map[string]*ingestBuckets{},
+		lastSweep: mtime.Now(),
+	}
+}
+
+func (l *IngestLimiter) newBuckets(reports, bytes float64) *ingestBuckets {
+	b := &ingestBuckets{}
+	if reports > 0 {
+		b.reports = newTokenBucket(reports, l.limits.Burst, 1)
+	}
+	if bytes > 0 {
+		b.bytes = newTokenBucket(bytes, l.limits.Burst, 0)
+	}
+	return b
+}
+
+// reserve takes a report of n bytes of a probe and tenant out of their
+// buckets, if they have room for it. Otherwise it returns how long until
+// they do, and the limit they are over.
+func (l *IngestLimiter) reserve(probeID, tenantID string, n int64) (time.Duration, string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	now := mtime.Now()
+	if now.Sub(l.lastSweep) > ingestSweepInterval {
+		l.sweep(now)
+	}
+
+	probe, ok := l.probes[probeID]
+	if !ok {
+		probe = l.newBuckets(l.limits.ProbeReports, l.limits.ProbeBytes)
+		l.probes[probeID] = probe
+	}
+	tenant, ok := l.tenants[tenantID]
+	if !ok {
+		tenant = l.newBuckets(l.limits.TenantReports, l.limits.TenantBytes)
+		l.tenants[tenantID] = tenant
+	}
+	checks := []struct {
+		limit  string
+		bucket *tokenBucket
+		n      float64
+	}{
+		{"probe_reports", probe.reports, 1},
+		{"probe_bytes", probe.bytes, float64(n)},
+		{"tenant_reports", tenant.reports, 1},
+		{"tenant_bytes", tenant.bytes, float64(n)},
+	}
+	for _, c := range checks {
+		if c.bucket == nil {
+			continue
+		}
+		c.bucket.refill(now)
+		if wait := c.bucket.wait(c.n); wait > 0 {
+			return wait, c.limit
+		}
+	}
+	for _, c := range checks {
+		if c.bucket != nil {
+			c.bucket.tokens -= c.n
+		}
+	}
+	return 0, ""
+}
+
+// take takes bytes, only known once read, out of the buckets of a probe
+// and tenant, whether or not they have room for them.
+func (l *IngestLimiter) take(probeID, tenantID string, n int64) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	for _, b := range []*ingestBuckets{l.probes[probeID], l.tenants[tenantID]} {
+		if b != nil && b.bytes != nil {
+			b.bytes.tokens -= float64(n)
+		}
+	}
+}
+
+// sweep forgets the buckets which are full again, as those of probes which
+// have gone away are. Called with l.mtx held.
+func (l *IngestLimiter) sweep(now time.Time) {
+	for _, buckets := range []map[string]*ingestBuckets{l.probes, l.tenants} {
+		for id, b := range buckets {
+			full := true
+			for _, bucket := range []*tokenBucket{b.reports, b.bytes} {
+				if bucket != nil {
+					bucket.refill(now)
+					full = full && bucket.full()
+				}
+			}
+			if full {
+				delete(buckets, id)
+			}
+		}
+	}
+	l.lastSweep = now
+}
+
+// admit tells whether a report may be ingested, counting it in the
+// metrics, or else how long to wait.
+func (l *IngestLimiter) admit(probeID, tenantID string, n int64) (time.Duration, string) {
+	wait, limit := l.reserve(probeID, tenantID, n)
+	if wait > 0 {
+		ingestLimited.WithLabelValues(limit).Inc()
+		return wait, limit
+	}
+	ingestReports.Inc()
+	ingestBytes.Add(float64(n))
+	return 0, ""
+}
+
+// tenant returns the tenant of the context of a request, if any.
+func (l *IngestLimiter) tenant(ctx context.Context) string {
+	if l.tenantID == nil {
+		return ""
+	}
+	id, err := l.tenantID(ctx)
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// requestProbeID returns the ID of the probe making a request, or else its
+// address, for probes too old to say.
+func requestProbeID(r *http.Request) string {
+	if id := r.Header.Get(xfer.ScopeProbeIDHeader); id != "" {
+		return id
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Wrap implements middleware.Interface, answering reports published over
+// the limits with 429 Too Many Requests, saying when to retry.
+func (l *IngestLimiter) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/report" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		probeID := requestProbeID(r)
+		tenantID := l.tenant(context.WithValue(context.Background(), RequestCtxKey, r))
+		n := r.ContentLength
+		if n < 0 {
+			n = 0
+		}
+		if wait, limit := l.admit(probeID, tenantID, n); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			respondWith(w, http.StatusTooManyRequests, fmt.Errorf("over the %s ingestion limit", limit))
+			return
+		}
+		if r.ContentLength < 0 {
+			body := &countingReader{ReadCloser: r.Body}
+			r.Body = body
+			defer func() {
+				n := atomic.LoadInt64(&body.n)
+				l.take(probeID, tenantID, n)
+				ingestBytes.Add(float64(n))
+			}()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// wait blocks until a report of a probe may be ingested, for streams which
+// are slowed down rather than rejected.
+func (l *IngestLimiter) wait(ctx context.Context, probeID string, n int64) error {
+	tenantID := l.tenant(ctx)
+	for {
+		wait, _ := l.admit(probeID, tenantID, n)
+		if wait == 0 {
+			return nil
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+func TestIngestLimiter(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	tenantID := func(ctx context.Context) (string, error) {
+		return ctx.Value(RequestCtxKey).(*http.Request).Header.Get("X-Tenant"), nil
+	}
+	limiter := NewIngestLimiter(IngestLimits{
+		ProbeReports:  1,
+		TenantReports: 1,
+		TenantBytes:   100,
+		Burst:         2 * time.Second,
+	}, tenantID)
+	handler := limiter.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	publish := func(probe, tenant string, size int) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/api/report", strings.NewReader(strings.Repeat("x", size)))
+		r.Header.Set(xfer.ScopeProbeIDHeader, probe)
+		r.Header.Set("X-Tenant", tenant)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	expect := func(w *httptest.ResponseRecorder, code int, retryAfter string) {
+		t.Helper()
+		if w.Code != code || w.Header().Get("Retry-After") != retryAfter {
+			t.Errorf("expected %d (retry after %q), got %d (retry after %q)", code, retryAfter, w.Code, w.Header().Get("Retry-After"))
+		}
+	}
+
+	// Each probe may burst two reports, then one a second.
+	expect(publish("p1", "a", 10), http.StatusOK, "")
+	expect(publish("p1", "a", 10), http.StatusOK, "")
+	expect(publish("p1", "a", 10), http.StatusTooManyRequests, "1")
+
+	// Tenant a has had its burst of reports too.
+	expect(publish("p2", "a", 10), http.StatusTooManyRequests, "1")
+	expect(publish("p2", "b", 10), http.StatusOK, "")
+
+	// Bytes: b has 190 of 200 left. Reports bigger than the burst are let
+	// through only once the bucket is full, leaving it in debt.
+	expect(publish("p3", "b", 500), http.StatusTooManyRequests, "1")
+	mtime.NowForce(now.Add(time.Second))
+	expect(publish("p3", "b", 500), http.StatusOK, "")
+	expect(publish("p4", "b", 10), http.StatusTooManyRequests, "4")
+
+	// Other requests are not limited.
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/topology", nil))
+		expect(w, http.StatusOK, "")
+	}
+
+	// Buckets back to full are forgotten.
+	mtime.NowForce(now.Add(time.Hour))
+	limiter.reserve("p5", "c", 0)
+	if len(limiter.probes) != 1 || len(limiter.tenants) != 1 {
+		t.Errorf("expected buckets to be swept, have %d and %d", len(limiter.probes), len(limiter.tenants))
+	}
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"bytes"
+	"io"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/weaveworks/scope/common/ingest"
+	"github.com/weaveworks/scope/report"
+)
+
+// ingester receives reports over gRPC streams, as an alternative to
+// posting them to /api/report one request at a time.
+type ingester struct {
+	adder   Adder
+	limiter *IngestLimiter
+}
+
+// RegisterIngester registers the gRPC report ingestion service, adding
+// the reports it receives to the given Adder. Streams over the limits of
+// the IngestLimiter, if any, are slowed down.
+func RegisterIngester(server *grpc.Server, a Adder, l *IngestLimiter) {
+	ingest.RegisterIngesterServer(server, &ingester{adder: a, limiter: l})
+}
+
+// Publish implements ingest.IngesterServer. Reports are acked once added,
+// so a busy collector slows down the probes waiting for acks.
+func (i *ingester) Publish(stream ingest.Ingester_PublishServer) error {
+	ctx := stream.Context()
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := i.add(ctx, msg.GetReport()); err != nil {
+			log.Errorf("Error adding streamed report: %v", err)
+			return err
+		}
+		if err := stream.Send(&ingest.Ack{}); err != nil {
+			return err
+		}
+	}
+}
+
+func (i *ingester) add(ctx context.Context, buf []byte) error {
+	var rpt report.Report
+	if err := rpt.ReadBinary(bytes.NewReader(buf), true, &codec.MsgpackHandle{}); err != nil {
+		return err
+	}
+	if i.limiter != nil {
+		var probeID string
+		for _, n := range rpt.Host.Nodes {
+			probeID, _ = n.Latest.Lookup(report.ControlProbeID)
+			break
+		}
+		if err := i.limiter.wait(ctx, probeID, int64(len(buf))); err != nil {
+			return err
+		}
+	}
+	return i.adder.Add(ctx, rpt, buf)
+}
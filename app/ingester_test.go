@@ -0,0 +1,72 @@
+package app_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/common/ingest"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestIngester(t *testing.T) {
+	ctx := context.Background()
+	c := app.NewCollector(time.Minute)
+	server := grpc.NewServer()
+	app.RegisterIngester(server, c, nil)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	stream, err := ingest.NewIngesterClient(conn).Publish(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"foo", "bar"} {
+		rpt := report.MakeReport()
+		rpt.Endpoint.AddNode(report.MakeNode(id))
+		var buf bytes.Buffer
+		if err := rpt.WriteBinary(&buf, gzip.DefaultCompression); err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Send(&ingest.Report{Report: buf.Bytes()}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	have, err := c.Report(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"foo", "bar"} {
+		if _, ok := have.Endpoint.Nodes[id]; !ok {
+			t.Errorf("streamed report with %s missing", id)
+		}
+	}
+
+	// Garbage ends the stream.
+	if err := stream.Send(&ingest.Report{Report: []byte("foo")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err == nil {
+		t.Error("expected an error for an undecodable report")
+	}
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/bluele/gcache"
+
+	"github.com/weaveworks/scope/render/layout"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	layoutCacheSize       = 1000
+	layoutCacheExpiration = 10 * time.Minute
+)
+
+// Layouts lays out topologies for the websockets asking for it with
+// ?layout=force or ?layout=layered. Layouts are cached by topology, its
+// options and the algorithm, so that clients watching the same topology
+// share them, and are only recomputed, from where the nodes were, when
+// the topology changes.
+type Layouts struct {
+	cache gcache.Cache
+}
+
+type cachedLayout struct {
+	fingerprint uint64
+	layout      layout.Layout
+}
+
+// NewLayouts makes a new Layouts.
+func NewLayouts() *Layouts {
+	return &Layouts{
+		cache: gcache.New(layoutCacheSize).LRU().Expiration(layoutCacheExpiration).Build(),
+	}
+}
+
+// Layout returns the layout of the nodes of a topology, rendered with the
+// given options. Layouts computed at once for the same topology are both
+// computed, the last one being cached.
+func (l *Layouts) Layout(topologyID, algorithm string, options url.Values, nodes report.Nodes) layout.Layout {
+	key := layoutKey(topologyID, algorithm, options)
+	fingerprint := topologyFingerprint(nodes)
+	var previous layout.Layout
+	if v, err := l.cache.Get(key); err == nil {
+		cached := v.(cachedLayout)
+		if cached.fingerprint == fingerprint {
+			return cached.layout
+		}
+		previous = cached.layout
+	}
+	result := layout.Algorithms[algorithm](nodes, previous)
+	l.cache.Set(key, cachedLayout{fingerprint: fingerprint, layout: result})
+	return result
+}
+
+// layoutKey identifies the layouts of a topology, ignoring the parameters
+// of websockets which don't change what is rendered.
+func layoutKey(topologyID, algorithm string, options url.Values) string {
+	filtered := url.Values{}
+	for k, v := range options {
+		switch k {
+		case "t", "timestamp", "layout":
+		default:
+			filtered[k] = v
+		}
+	}
+	return topologyID + "/" + algorithm + "?" + filtered.Encode()
+}
+
+// topologyFingerprint hashes the nodes and edges of a topology, all that
+// layouts depend on.
+func topologyFingerprint(nodes report.Nodes) uint64 {
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	h := fnv.New64a()
+	for _, id := range ids {
+		h.Write([]byte(id))
+		binary.Write(h, binary.LittleEndian, uint32(len(nodes[id].Adjacency)))
+		for _, dst := range nodes[id].Adjacency {
+			h.Write([]byte(dst))
+			h.Write([]byte{0})
+		}
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// layoutsForReporter returns the Layouts of rep, nil if it doesn't lay out
+// topologies.
+func layoutsForReporter(rep Reporter) *Layouts {
+	if wrep, ok := rep.(WebReporter); ok {
+		return wrep.Layouts
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package multitenant
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/instrument"
+)
+
+// S3FlowLogSource is an app.FlowLogSource reading the VPC flow logs
+// delivered to an S3 bucket, under
+//
+//	<prefix>/AWSLogs/<account>/vpcflowlogs/<region>/<yyyy>/<mm>/<dd>/...log.gz
+//
+// Objects are read in the order of their keys, which is that of their
+// delivery as long as the prefix has the flow logs of a single account
+// and region.
+type S3FlowLogSource struct {
+	store  S3Store
+	prefix string
+
+	mtx    sync.Mutex
+	from   time.Time // objects modified before are skipped, until one is read
+	marker string    // the key of the last object read
+}
+
+// NewS3FlowLogSource creates a new S3FlowLogSource, reading the flow logs
+// delivered under the given prefix of the bucket after from.
+func NewS3FlowLogSource(config *aws.Config, bucketName, prefix string, from time.Time) *S3FlowLogSource {
+	return &S3FlowLogSource{
+		store:  NewS3Client(config, bucketName),
+		prefix: strings.Trim(prefix, "/"),
+		from:   from,
+	}
+}
+
+// Fetch implements app.FlowLogSource.
+func (s *S3FlowLogSource) Fetch(ctx context.Context) ([]string, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var keys []string
+	err := instrument.TimeRequestHistogram(ctx, "S3.List", s3RequestDuration, func(_ context.Context) error {
+		input := &s3.ListObjectsInput{
+			Bucket: aws.String(s.store.bucketName),
+			Prefix: aws.String(s.prefix),
+		}
+		if s.marker != "" {
+			input.Marker = aws.String(s.marker)
+		}
+		return s.store.s3.ListObjectsPages(input, func(page *s3.ListObjectsOutput, _ bool) bool {
+			for _, object := range page.Contents {
+				if aws.TimeValue(object.LastModified).Before(s.from) {
+					continue
+				}
+				keys = append(keys, aws.StringValue(object.Key))
+			}
+			return true
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing %s: %v", s.prefix, err)
+	}
+
+	var lines []string
+	for _, key := range keys {
+		buf, err := s.store.fetchBytes(ctx, key)
+		if err != nil {
+			return lines, err
+		}
+		if strings.HasSuffix(key, ".gz") {
+			if buf, err = gunzip(buf); err != nil {
+				return lines, fmt.Errorf("Error reading %s: %v", key, err)
+			}
+		}
+		lines = append(lines, splitLines(buf)...)
+		s.marker, s.from = key, time.Time{}
+	}
+	return lines, nil
+}
+
+// KinesisFlowLogSource is an app.FlowLogSource reading the VPC flow logs
+// a CloudWatch Logs subscription puts in a Kinesis stream. Records put
+// there by anything else are taken to be lines of flow logs.
+type KinesisFlowLogSource struct {
+	kinesis *kinesis.Kinesis
+	stream  string
+
+	mtx       sync.Mutex
+	iterators map[string]string // shard ID -> iterator
+	closed    map[string]bool   // shard IDs of the shards read to their end
+}
+
+// NewKinesisFlowLogSource creates a new KinesisFlowLogSource, reading the
+// stream from the records put in it from now on.
+func NewKinesisFlowLogSource(config *aws.Config, stream string) *KinesisFlowLogSource {
+	return &KinesisFlowLogSource{
+		kinesis: kinesis.New(session.New(config)),
+		stream:  stream,
+	}
+}
+
+// Fetch implements app.FlowLogSource.
+func (s *KinesisFlowLogSource) Fetch(ctx context.Context) ([]string, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// Shards come and go as the stream is resharded. Those which appear
+	// after we first looked are read from their start.
+	iteratorType := kinesis.ShardIteratorTypeTrimHorizon
+	if s.iterators == nil {
+		s.iterators, s.closed = map[string]string{}, map[string]bool{}
+		iteratorType = kinesis.ShardIteratorTypeLatest
+	}
+	shards, err := s.shards()
+	if err != nil {
+		return nil, err
+	}
+	for _, shard := range shards {
+		if _, ok := s.iterators[shard]; ok || s.closed[shard] {
+			continue
+		}
+		resp, err := s.kinesis.GetShardIterator(&kinesis.GetShardIteratorInput{
+			StreamName:        aws.String(s.stream),
+			ShardId:           aws.String(shard),
+			ShardIteratorType: aws.String(iteratorType),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Error getting an iterator of shard %s: %v", shard, err)
+		}
+		s.iterators[shard] = aws.StringValue(resp.ShardIterator)
+	}
+
+	var lines []string
+	for shard, iterator := range s.iterators {
+		resp, err := s.kinesis.GetRecords(&kinesis.GetRecordsInput{ShardIterator: aws.String(iterator)})
+		if err != nil {
+			return lines, fmt.Errorf("Error getting records of shard %s: %v", shard, err)
+		}
+		for _, record := range resp.Records {
+			recordLines, err := decodeFlowLogRecord(record.Data)
+			if err != nil {
+				return lines, fmt.Errorf("Error decoding record %s: %v", aws.StringValue(record.SequenceNumber), err)
+			}
+			lines = append(lines, recordLines...)
+		}
+		if resp.NextShardIterator == nil {
+			delete(s.iterators, shard)
+			s.closed[shard] = true
+		} else {
+			s.iterators[shard] = *resp.NextShardIterator
+		}
+	}
+	return lines, nil
+}
+
+func (s *KinesisFlowLogSource) shards() ([]string, error) {
+	var result []string
+	input := &kinesis.DescribeStreamInput{StreamName: aws.String(s.stream)}
+	for {
+		resp, err := s.kinesis.DescribeStream(input)
+		if err != nil {
+			return nil, fmt.Errorf("Error describing stream %s: %v", s.stream, err)
+		}
+		for _, shard := range resp.StreamDescription.Shards {
+			result = append(result, aws.StringValue(shard.ShardId))
+		}
+		if !aws.BoolValue(resp.StreamDescription.HasMoreShards) || len(result) == 0 {
+			return result, nil
+		}
+		input.ExclusiveStartShardId = aws.String(result[len(result)-1])
+	}
+}
+
+// What CloudWatch Logs puts in Kinesis streams, gzip'd.
+type cloudWatchLogsData struct {
+	MessageType string `json:"messageType"`
+	LogEvents   []struct {
+		Message string `json:"message"`
+	} `json:"logEvents"`
+}
+
+// decodeFlowLogRecord returns the lines of flow logs of a Kinesis record.
+func decodeFlowLogRecord(data []byte) ([]string, error) {
+	if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		return splitLines(data), nil
+	}
+	buf, err := gunzip(data)
+	if err != nil {
+		return nil, err
+	}
+	var logs cloudWatchLogsData
+	if err := codec.NewDecoderBytes(buf, &codec.JsonHandle{}).Decode(&logs); err != nil {
+		return nil, err
+	}
+	// Subscriptions are checked with CONTROL_MESSAGEs, which have no flow
+	// logs.
+	if logs.MessageType != "DATA_MESSAGE" {
+		return nil, nil
+	}
+	lines := make([]string, 0, len(logs.LogEvents))
+	for _, event := range logs.LogEvents {
+		lines = append(lines, event.Message)
+	}
+	return lines, nil
+}
+
+func gunzip(buf []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func splitLines(buf []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
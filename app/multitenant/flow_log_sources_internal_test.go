@@ -0,0 +1,43 @@
+package multitenant
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeFlowLogRecord(t *testing.T) {
+	gzipped := func(s string) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		w.Write([]byte(s))
+		w.Close()
+		return buf.Bytes()
+	}
+	for _, tc := range []struct {
+		data []byte
+		want []string
+	}{
+		{
+			data: gzipped(`{"messageType":"DATA_MESSAGE","owner":"123456789010","logGroup":"flows","logStream":"eni-1-all","logEvents":[{"id":"1","timestamp":1499999940000,"message":"2 123456789010 eni-1 10.0.0.5 10.0.0.6 40000 80 6 10 840 1499999900 1499999940 ACCEPT OK"}]}`),
+			want: []string{"2 123456789010 eni-1 10.0.0.5 10.0.0.6 40000 80 6 10 840 1499999900 1499999940 ACCEPT OK"},
+		},
+		{
+			data: gzipped(`{"messageType":"CONTROL_MESSAGE","logEvents":[{"id":"","timestamp":1499999940000,"message":"CWL CONTROL MESSAGE: Checking health of destination Kinesis stream."}]}`),
+			want: nil,
+		},
+		{
+			data: []byte("2 123456789010 eni-1 10.0.0.5 10.0.0.6 40000 80 6 10 840 1499999900 1499999940 ACCEPT OK\n"),
+			want: []string{"2 123456789010 eni-1 10.0.0.5 10.0.0.6 40000 80 6 10 840 1499999900 1499999940 ACCEPT OK"},
+		},
+	} {
+		have, err := decodeFlowLogRecord(tc.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tc.want, have) {
+			t.Errorf("Expected %v, got %v", tc.want, have)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package multitenant
 
 import (
 	"bytes"
+	"io/ioutil"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/prometheus/client_golang/prometheus"
@@ -22,6 +24,8 @@ var (
 	}, []string{"method", "status_code"})
 )
 
+const noSuchKeyError = "NoSuchKey"
+
 // S3Store is an S3 client that stores and retrieves Reports.
 type S3Store struct {
 	s3         *s3.S3
@@ -86,6 +90,26 @@ func (store *S3Store) fetchReport(ctx context.Context, key string) (*report.Repo
 	return report.MakeFromBinary(resp.Body)
 }
 
+// fetchBytes fetches an object, or nil if there is none.
+func (store *S3Store) fetchBytes(ctx context.Context, key string) ([]byte, error) {
+	var resp *s3.GetObjectOutput
+	err := instrument.TimeRequestHistogram(ctx, "S3.Get", s3RequestDuration, func(_ context.Context) error {
+		var err error
+		resp, err = store.s3.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(store.bucketName),
+			Key:    aws.String(key),
+		})
+		return err
+	})
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == noSuchKeyError {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
 // StoreReportBytes stores a report.
 func (store *S3Store) StoreReportBytes(ctx context.Context, key string, buf []byte) (int, error) {
 	err := instrument.TimeRequestHistogram(ctx, "S3.Put", s3RequestDuration, func(_ context.Context) error {
@@ -0,0 +1,259 @@
+package multitenant
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/instrument"
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	reportKeyBucket     = time.Hour
+	reportKeyBucketFmt  = "2006-01-02/15"
+	reportKeySuffix     = ".msgpack.gz"
+	annotationsKey      = "annotations.json"
+	viewsKey            = "views.json"
+	dependenciesKey     = "dependencies.json"
+	recordingsPrefix    = "recordings"
+	recordingSuffix     = ".json"
+	recordingCastSuffix = ".cast"
+)
+
+// S3ReportStore is an app.ReportStore keeping reports in an S3 bucket,
+// or anything else speaking its API. Reports are stored under keys
+// bucketed by the hour they were received in, e.g.
+//
+//	<prefix>/2017-03-03/16/1488557088545489008.msgpack.gz
+//
+// so the reports of a window can be found by listing a few buckets.
+type S3ReportStore struct {
+	store  S3Store
+	prefix string
+}
+
+// NewS3ReportStore creates a new S3ReportStore, storing reports under the
+// given prefix of the bucket.
+func NewS3ReportStore(config *aws.Config, bucketName, prefix string) *S3ReportStore {
+	return &S3ReportStore{
+		store:  NewS3Client(config, bucketName),
+		prefix: strings.Trim(prefix, "/"),
+	}
+}
+
+// StoreReport implements app.ReportStore.
+func (s *S3ReportStore) StoreReport(ctx context.Context, timestamp time.Time, buf []byte) error {
+	_, err := s.store.StoreReportBytes(ctx, s.reportKey(timestamp), buf)
+	return err
+}
+
+// FetchReports implements app.ReportStore.
+func (s *S3ReportStore) FetchReports(ctx context.Context, from, through time.Time) ([]report.Report, error) {
+	var keys []string
+	for _, bucket := range s.buckets(from, through) {
+		bucketKeys, err := s.store.listKeys(ctx, bucket+"/")
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range bucketKeys {
+			if t, ok := reportKeyTime(key); ok && t.After(from) && !t.After(through) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	// Keys sort by time, as long as timestamps have 19 digits.
+	sort.Strings(keys)
+
+	reports, _, err := s.store.FetchReports(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]report.Report, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, reports[key])
+	}
+	return result, nil
+}
+
+// LoadAnnotations implements app.AnnotationStore. Annotations are kept as
+// JSON, under <prefix>/annotations.json.
+func (s *S3ReportStore) LoadAnnotations(ctx context.Context) ([]detailed.Annotation, error) {
+	buf, err := s.store.fetchBytes(ctx, path.Join(s.prefix, annotationsKey))
+	if err != nil || buf == nil {
+		return nil, err
+	}
+	var annotations []detailed.Annotation
+	if err := codec.NewDecoderBytes(buf, &codec.JsonHandle{}).Decode(&annotations); err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
+
+// StoreAnnotations implements app.AnnotationStore.
+func (s *S3ReportStore) StoreAnnotations(ctx context.Context, annotations []detailed.Annotation) error {
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, &codec.JsonHandle{}).Encode(annotations); err != nil {
+		return err
+	}
+	_, err := s.store.StoreReportBytes(ctx, path.Join(s.prefix, annotationsKey), buf)
+	return err
+}
+
+// LoadViews implements app.ViewStore. Views are kept as JSON, under
+// <prefix>/views.json.
+func (s *S3ReportStore) LoadViews(ctx context.Context) ([]app.View, error) {
+	buf, err := s.store.fetchBytes(ctx, path.Join(s.prefix, viewsKey))
+	if err != nil || buf == nil {
+		return nil, err
+	}
+	var views []app.View
+	if err := codec.NewDecoderBytes(buf, &codec.JsonHandle{}).Decode(&views); err != nil {
+		return nil, err
+	}
+	return views, nil
+}
+
+// StoreViews implements app.ViewStore.
+func (s *S3ReportStore) StoreViews(ctx context.Context, views []app.View) error {
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, &codec.JsonHandle{}).Encode(views); err != nil {
+		return err
+	}
+	_, err := s.store.StoreReportBytes(ctx, path.Join(s.prefix, viewsKey), buf)
+	return err
+}
+
+// LoadDependencies implements app.DependencyStore. The edges seen are
+// kept as JSON, under <prefix>/dependencies.json.
+func (s *S3ReportStore) LoadDependencies(ctx context.Context) ([]app.SeenEdge, error) {
+	buf, err := s.store.fetchBytes(ctx, path.Join(s.prefix, dependenciesKey))
+	if err != nil || buf == nil {
+		return nil, err
+	}
+	var edges []app.SeenEdge
+	if err := codec.NewDecoderBytes(buf, &codec.JsonHandle{}).Decode(&edges); err != nil {
+		return nil, err
+	}
+	return edges, nil
+}
+
+// StoreDependencies implements app.DependencyStore.
+func (s *S3ReportStore) StoreDependencies(ctx context.Context, edges []app.SeenEdge) error {
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, &codec.JsonHandle{}).Encode(edges); err != nil {
+		return err
+	}
+	_, err := s.store.StoreReportBytes(ctx, path.Join(s.prefix, dependenciesKey), buf)
+	return err
+}
+
+// StoreRecording implements app.RecordingStore. Recordings are kept under
+// <prefix>/recordings/, as <id>.json for what they are of, and <id>.cast.
+func (s *S3ReportStore) StoreRecording(ctx context.Context, r app.Recording, cast []byte) error {
+	if _, err := s.store.StoreReportBytes(ctx, s.recordingKey(r.ID, recordingCastSuffix), cast); err != nil {
+		return err
+	}
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, &codec.JsonHandle{}).Encode(r); err != nil {
+		return err
+	}
+	_, err := s.store.StoreReportBytes(ctx, s.recordingKey(r.ID, recordingSuffix), buf)
+	return err
+}
+
+// LoadRecordings implements app.RecordingStore.
+func (s *S3ReportStore) LoadRecordings(ctx context.Context) ([]app.Recording, error) {
+	keys, err := s.store.listKeys(ctx, path.Join(s.prefix, recordingsPrefix)+"/")
+	if err != nil {
+		return nil, err
+	}
+	var recordings []app.Recording
+	for _, key := range keys {
+		if !strings.HasSuffix(key, recordingSuffix) {
+			continue
+		}
+		buf, err := s.store.fetchBytes(ctx, key)
+		if err != nil {
+			return nil, err
+		} else if buf == nil {
+			continue
+		}
+		var r app.Recording
+		if err := codec.NewDecoderBytes(buf, &codec.JsonHandle{}).Decode(&r); err != nil {
+			return nil, err
+		}
+		recordings = append(recordings, r)
+	}
+	return recordings, nil
+}
+
+// FetchRecording implements app.RecordingStore.
+func (s *S3ReportStore) FetchRecording(ctx context.Context, id string) ([]byte, error) {
+	return s.store.fetchBytes(ctx, s.recordingKey(id, recordingCastSuffix))
+}
+
+func (s *S3ReportStore) recordingKey(id, suffix string) string {
+	return path.Join(s.prefix, recordingsPrefix, path.Base(id)+suffix)
+}
+
+func (s *S3ReportStore) reportKey(timestamp time.Time) string {
+	timestamp = timestamp.UTC()
+	return path.Join(s.bucket(timestamp), strconv.FormatInt(timestamp.UnixNano(), 10)+reportKeySuffix)
+}
+
+func (s *S3ReportStore) bucket(timestamp time.Time) string {
+	return path.Join(s.prefix, timestamp.UTC().Format(reportKeyBucketFmt))
+}
+
+// buckets returns the key prefixes of all the buckets overlapping the
+// time between from and through.
+func (s *S3ReportStore) buckets(from, through time.Time) []string {
+	var result []string
+	for t := from.UTC().Truncate(reportKeyBucket); !t.After(through); t = t.Add(reportKeyBucket) {
+		result = append(result, s.bucket(t))
+	}
+	return result
+}
+
+func reportKeyTime(key string) (time.Time, bool) {
+	name := path.Base(key)
+	if !strings.HasSuffix(name, reportKeySuffix) {
+		return time.Time{}, false
+	}
+	nanos, err := strconv.ParseInt(strings.TrimSuffix(name, reportKeySuffix), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos), true
+}
+
+// listKeys lists the keys starting with prefix.
+func (store *S3Store) listKeys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := instrument.TimeRequestHistogram(ctx, "S3.List", s3RequestDuration, func(_ context.Context) error {
+		return store.s3.ListObjectsPages(&s3.ListObjectsInput{
+			Bucket: aws.String(store.bucketName),
+			Prefix: aws.String(prefix),
+		}, func(page *s3.ListObjectsOutput, _ bool) bool {
+			for _, object := range page.Contents {
+				keys = append(keys, aws.StringValue(object.Key))
+			}
+			return true
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing %s: %v", prefix, err)
+	}
+	return keys, nil
+}
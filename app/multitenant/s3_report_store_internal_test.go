@@ -0,0 +1,30 @@
+package multitenant
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReportKeys(t *testing.T) {
+	var (
+		store     = S3ReportStore{prefix: "scope"}
+		timestamp = time.Date(2017, 3, 3, 16, 4, 48, 545489008, time.UTC)
+	)
+	key := store.reportKey(timestamp)
+	if want := "scope/2017-03-03/16/1488557088545489008.msgpack.gz"; key != want {
+		t.Errorf("want %q, have %q", want, key)
+	}
+	if have, ok := reportKeyTime(key); !ok || !have.Equal(timestamp) {
+		t.Errorf("want %v, have %v", timestamp, have)
+	}
+
+	if have, want := store.recordingKey("1488557088545489008-pipe", recordingCastSuffix), "scope/recordings/1488557088545489008-pipe.cast"; have != want {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	want := []string{"scope/2017-03-03/15", "scope/2017-03-03/16"}
+	if have := store.buckets(timestamp.Add(-time.Hour), timestamp); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
@@ -0,0 +1,271 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	sessionCookie = "scope_session"
+	stateCookie   = "scope_oidc_state"
+
+	loginPath    = "/login"
+	callbackPath = "/oauth2/callback"
+	logoutPath   = "/logout"
+
+	// loginTimeout is how long users have to log in with the issuer.
+	loginTimeout = 10 * time.Minute
+)
+
+// OIDCLogin logs the users of the UI in with an OIDC issuer, by the
+// authorization code flow, keeping the ID tokens they get in session
+// cookies. It is a Policy too, of the tokens of the sessions, or of bearer
+// tokens for API clients.
+type OIDCLogin struct {
+	*oidcPolicy
+	login  OIDCLoginConfig
+	origin string // of the app, e.g. https://scope.example.com
+}
+
+// OIDCLoginConfig configures the client of the issuer logging users in.
+type OIDCLoginConfig struct {
+	ClientSecret string
+	// The URL of /oauth2/callback of the app, as seen by browsers and
+	// registered with the issuer.
+	RedirectURL string
+	Scopes      []string // e.g. openid, email, groups
+}
+
+// NewOIDCLogin makes an OIDCLogin.
+func NewOIDCLogin(config OIDCConfig, login OIDCLoginConfig) (*OIDCLogin, error) {
+	policy, err := NewOIDCPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(login.RedirectURL)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsAbs() || u.Path != callbackPath {
+		return nil, fmt.Errorf("redirect URL %q is not the absolute URL of %s", login.RedirectURL, callbackPath)
+	}
+	return &OIDCLogin{
+		oidcPolicy: policy.(*oidcPolicy),
+		login:      login,
+		origin:     u.Scheme + "://" + u.Host,
+	}, nil
+}
+
+func (l *OIDCLogin) endpoints() (*oidcDiscovery, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.discover()
+}
+
+func (l *OIDCLogin) secure() bool {
+	return strings.HasPrefix(l.login.RedirectURL, "https:")
+}
+
+// Wrap implements middleware.Interface, serving the login routes, sending
+// users of the UI without a session to log in, and turning away requests
+// other sites forge with sessions. API requests are left to an Authorizer.
+func (l *OIDCLogin) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case loginPath:
+			l.redirectToIssuer(w, r, r.URL.Query().Get("return"))
+			return
+		case callbackPath:
+			l.callback(w, r)
+			return
+		case logoutPath:
+			l.setCookie(w, sessionCookie, "", time.Unix(0, 0))
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		if required, ok := requiredRole(r, ""); ok && required == RoleNone && r.Method == "GET" && !l.loggedIn(r) {
+			l.redirectToIssuer(w, r, r.URL.RequestURI())
+			return
+		}
+		if l.forged(r) {
+			respondWith(w, http.StatusForbidden, fmt.Errorf("cross-site request"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loggedIn tells whether a request is of a user with a valid session, or
+// bearer token, whatever their role.
+func (l *OIDCLogin) loggedIn(r *http.Request) bool {
+	token := requestToken(r)
+	if token == "" {
+		return false
+	}
+	_, err := l.validate(token)
+	return err == nil
+}
+
+// forged tells whether a request by a session, which browsers send with
+// its cookie whichever site makes it, changes something (or opens a pipe)
+// from another site than the app. Bearer tokens browsers don't send unasked.
+func (l *OIDCLogin) forged(r *http.Request) bool {
+	if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		return false
+	}
+	if _, err := r.Cookie(sessionCookie); err != nil {
+		return false
+	}
+	websocket := strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+	if (r.Method == "GET" || r.Method == "HEAD") && !websocket {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		if u, err := url.Parse(r.Referer()); err == nil && u.Host != "" {
+			origin = u.Scheme + "://" + u.Host
+		}
+	}
+	return origin != l.origin
+}
+
+func (l *OIDCLogin) setCookie(w http.ResponseWriter, name, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   l.secure(),
+		HttpOnly: true,
+	})
+}
+
+// redirectToIssuer sends the user to the issuer to log in, to come back to
+// the given path of the app.
+func (l *OIDCLogin) redirectToIssuer(w http.ResponseWriter, r *http.Request, returnTo string) {
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") {
+		returnTo = "/"
+	}
+	endpoints, err := l.endpoints()
+	if err != nil {
+		respondWith(w, http.StatusBadGateway, err)
+		return
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		respondWith(w, http.StatusInternalServerError, err)
+		return
+	}
+	state := hex.EncodeToString(buf)
+	l.setCookie(w, stateCookie, state+":"+base64.RawURLEncoding.EncodeToString([]byte(returnTo)), time.Now().Add(loginTimeout))
+
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {l.config.ClientID},
+		"redirect_uri":  {l.login.RedirectURL},
+		"scope":         {strings.Join(l.login.Scopes, " ")},
+		"state":         {state},
+		"nonce":         {state},
+	}
+	separator := "?"
+	if strings.Contains(endpoints.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	http.Redirect(w, r, endpoints.AuthorizationEndpoint+separator+query.Encode(), http.StatusFound)
+}
+
+// callback is where the issuer sends users back to, with the code to get
+// their ID token with.
+func (l *OIDCLogin) callback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil {
+		respondWith(w, http.StatusBadRequest, fmt.Errorf("login expired, try again"))
+		return
+	}
+	l.setCookie(w, stateCookie, "", time.Unix(0, 0))
+	parts := strings.SplitN(cookie.Value, ":", 2)
+	query := r.URL.Query()
+	if len(parts) != 2 || query.Get("state") != parts[0] {
+		respondWith(w, http.StatusBadRequest, fmt.Errorf("login state mismatch"))
+		return
+	}
+	if e := query.Get("error"); e != "" {
+		respondWith(w, http.StatusUnauthorized, fmt.Errorf("login failed: %s %s", e, query.Get("error_description")))
+		return
+	}
+	returnTo, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		respondWith(w, http.StatusBadRequest, err)
+		return
+	}
+
+	token, err := l.exchange(query.Get("code"))
+	if err != nil {
+		log.Errorf("Error getting ID token: %v", err)
+		respondWith(w, http.StatusBadGateway, err)
+		return
+	}
+	claims, err := l.validate(token)
+	if err != nil {
+		respondWith(w, http.StatusUnauthorized, err)
+		return
+	}
+	if claims["nonce"] != parts[0] {
+		respondWith(w, http.StatusUnauthorized, fmt.Errorf("login nonce mismatch"))
+		return
+	}
+	var expires time.Time
+	if exp, ok := claims["exp"].(float64); ok {
+		expires = time.Unix(int64(exp), 0)
+	}
+	l.setCookie(w, sessionCookie, token, expires)
+	http.Redirect(w, r, string(returnTo), http.StatusFound)
+}
+
+// exchange gets the ID token of an authorization code from the issuer.
+func (l *OIDCLogin) exchange(code string) (string, error) {
+	endpoints, err := l.endpoints()
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {l.login.RedirectURL},
+	}
+	req, err := http.NewRequest("POST", endpoints.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(l.config.ClientID), url.QueryEscape(l.login.ClientSecret))
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var response struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(&response); err != nil {
+		return "", fmt.Errorf("%s: %v", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s %s", resp.Status, response.Error, response.ErrorDescription)
+	}
+	if response.IDToken == "" {
+		return "", fmt.Errorf("no ID token")
+	}
+	return response.IDToken, nil
+}
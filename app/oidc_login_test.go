@@ -0,0 +1,104 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/scope/app"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.Close()
+	login, err := app.NewOIDCLogin(oidcConfig(issuer.URL), app.OIDCLoginConfig{
+		ClientSecret: "secret",
+		RedirectURL:  "http://scope.example.com/oauth2/callback",
+		Scopes:       []string{"openid", "groups"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := login.Wrap(app.Authorizer{Policy: login}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	serve := func(path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// The UI sends users to the issuer, the API tells them off.
+	if w := serve("/api/topology", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected API request to be unauthorized, got %d", w.Code)
+	}
+	w := serve("/some/page?x=1", nil)
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected a redirect to log in, got %d", w.Code)
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := location.Query()
+	if !strings.HasPrefix(location.String(), issuer.URL+"/auth?") || query.Get("client_id") != "scope" || query.Get("scope") != "openid groups" {
+		t.Fatalf("Unexpected redirect to %s", location)
+	}
+	state := w.Result().Cookies()
+
+	// A callback of another login fails.
+	if w := serve("/oauth2/callback?state=other&code=x", state); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected mismatched state to fail, got %d", w.Code)
+	}
+
+	// The issuer sends the user back, with a code.
+	w = serve("/oauth2/callback?state="+query.Get("state")+"&code="+query.Get("nonce"), state)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/some/page?x=1" {
+		t.Fatalf("Expected a redirect back, got %d to %s: %s", w.Code, w.Header().Get("Location"), w.Body.String())
+	}
+	var session []*http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "scope_session" && c.Value != "" {
+			session = append(session, c)
+		}
+	}
+	if len(session) != 1 || !session[0].HttpOnly {
+		t.Fatalf("Expected a session cookie, got %v", w.Result().Cookies())
+	}
+
+	// The session holds for the UI and the API, operators included.
+	if w := serve("/some/page", session); w.Code != http.StatusOK {
+		t.Errorf("Expected UI request to be let through, got %d", w.Code)
+	}
+	if w := serve("/api/topology", session); w.Code != http.StatusOK {
+		t.Errorf("Expected API request to be let through, got %d", w.Code)
+	}
+	control := func(header, value string) int {
+		req := httptest.NewRequest("POST", "/api/control/probe/node/docker_stop_container", nil)
+		req.AddCookie(session[0])
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := control("Origin", "http://scope.example.com"); code != http.StatusOK {
+		t.Errorf("Expected control to be let through, got %d", code)
+	}
+	if code := control("Referer", "http://scope.example.com/some/page"); code != http.StatusOK {
+		t.Errorf("Expected control to be let through, got %d", code)
+	}
+
+	// Other sites can't invoke controls with the session.
+	if code := control("Origin", "http://evil.example.com"); code != http.StatusForbidden {
+		t.Errorf("Expected cross-site control to be forbidden, got %d", code)
+	}
+	if code := control("", ""); code != http.StatusForbidden {
+		t.Errorf("Expected control of unknown origin to be forbidden, got %d", code)
+	}
+}
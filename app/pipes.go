@@ -1,7 +1,10 @@
 package app
 
 import (
+	"fmt"
+	"io"
 	"net/http"
+	"path"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
@@ -22,6 +25,11 @@ func RegisterPipeRoutes(router *mux.Router, pr PipeRouter) {
 		Path("/api/pipe/{pipeID}").
 		HandlerFunc(requestContextDecorator(handlePipeWs(pr, UIEnd)))
 
+	router.Methods("GET").
+		Name("api_pipe_pipeid_download").
+		Path("/api/pipe/{pipeID}/download").
+		HandlerFunc(requestContextDecorator(handlePipeDownload(pr)))
+
 	router.Methods("GET").
 		Name("api_pipe_pipeid_probe").
 		Path("/api/pipe/{pipeID}/probe").
@@ -72,6 +80,33 @@ func handlePipeWs(pr PipeRouter, end End) CtxHandlerFunc {
 	}
 }
 
+// handlePipeDownload streams what the probe writes to a pipe, e.g. a packet
+// capture, as a file, until the probe closes it. Its name is ?name=, or the
+// ID of the pipe.
+func handlePipeDownload(pr PipeRouter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["pipeID"]
+		_, endIO, err := pr.Get(ctx, id, UIEnd)
+		if err != nil {
+			log.Debugf("Error getting pipe %s: %v", id, err)
+			http.NotFound(w, r)
+			return
+		}
+		defer pr.Release(ctx, id, UIEnd)
+
+		name := path.Base(r.URL.Query().Get("name"))
+		if name == "." || name == "/" {
+			name = id
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		// Pipes closing close their ends.
+		if _, err := io.Copy(w, endIO); err != nil && err != io.ErrClosedPipe {
+			log.Errorf("Error downloading pipe %s: %v", id, err)
+		}
+	}
+}
+
 func deletePipe(pr PipeRouter) CtxHandlerFunc {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 		pipeID := mux.Vars(r)["pipeID"]
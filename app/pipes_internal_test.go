@@ -3,6 +3,7 @@ package app
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -141,3 +142,68 @@ func TestPipeClose(t *testing.T) {
 		return pipe.Closed()
 	})
 }
+
+func TestPipeDownload(t *testing.T) {
+	router := mux.NewRouter()
+	pr := NewLocalPipeRouter()
+	RegisterPipeRoutes(router, pr)
+	defer pr.Stop()
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ip, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := url.URL{Scheme: "http", Host: ip + ":" + port}
+	client, err := appclient.NewAppClient(appclient.ProbeConfig{ProbeID: "foo"}, ip+":"+port, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	pipeID, pipe, err := controls.NewPipe(adapter{client}, "appid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type download struct {
+		resp *http.Response
+		body []byte
+		err  error
+	}
+	downloaded := make(chan download)
+	go func() {
+		resp, err := http.Get(server.URL + "/api/pipe/" + pipeID + "/download?name=capture.pcap")
+		if err != nil {
+			downloaded <- download{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		downloaded <- download{resp, body, err}
+	}()
+
+	// The file ends when the probe closes the pipe.
+	local, _ := pipe.Ends()
+	msg := []byte("hello world")
+	if _, err := local.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := pipe.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d := <-downloaded
+	if d.err != nil {
+		t.Fatal(d.err)
+	}
+	if !bytes.Equal(d.body, msg) {
+		t.Errorf("%q != %q", d.body, msg)
+	}
+	if have, want := d.resp.Header.Get("Content-Disposition"), `attachment; filename="capture.pcap"`; have != want {
+		t.Errorf("%q != %q", have, want)
+	}
+}
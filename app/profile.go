@@ -0,0 +1,49 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/common/profile"
+)
+
+// RegisterProfileRoutes registers the route taking profiles of the app, to
+// download, e.g. /api/profile/cpu?seconds=60 or /api/profile/heap. Those of
+// probes are taken with the controls of their hosts.
+func RegisterProfileRoutes(router *mux.Router) {
+	router.Methods("GET").
+		Name("api_profile_kind").
+		Path("/api/profile/{kind}").
+		HandlerFunc(requestContextDecorator(handleProfile))
+}
+
+func handleProfile(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	kind := mux.Vars(r)["kind"]
+	duration := profile.DefaultDuration
+	if seconds := r.URL.Query().Get("seconds"); seconds != "" {
+		n, err := strconv.Atoi(seconds)
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("invalid seconds: %q", seconds))
+			return
+		}
+		duration = time.Duration(n) * time.Second
+	}
+	// The profile is buffered, so errors are told with their status rather
+	// than in a broken file. CPU profiles stop when the client goes away.
+	var buf bytes.Buffer
+	start := mtime.Now()
+	if err := profile.Write(r.Context(), &buf, kind, duration); err != nil {
+		respondWith(w, http.StatusBadRequest, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", profile.Filename("app", kind, start)))
+	w.Write(buf.Bytes())
+}
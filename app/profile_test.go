@@ -0,0 +1,37 @@
+package app_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/app"
+)
+
+func TestAPIProfile(t *testing.T) {
+	router := mux.NewRouter()
+	app.RegisterProfileRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	for _, path := range []string{"/api/profile/heap", "/api/profile/cpu?seconds=1"} {
+		res, body := checkGet(t, ts, path)
+		equals(t, http.StatusOK, res.StatusCode)
+		// Profiles are gzipped protobufs.
+		if !bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+			t.Errorf("%s: expected a profile, got %q", path, body)
+		}
+		if disposition := res.Header.Get("Content-Disposition"); !strings.HasPrefix(disposition, `attachment; filename="scope-app-`) {
+			t.Errorf("%s: unexpected disposition %q", path, disposition)
+		}
+	}
+
+	for _, path := range []string{"/api/profile/cpu?seconds=forever", "/api/profile/cpu?seconds=3600", "/api/profile/goroutines"} {
+		res, _ := checkGet(t, ts, path)
+		equals(t, http.StatusBadRequest, res.StatusCode)
+	}
+}
@@ -0,0 +1,478 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+// Query is a parsed query over the nodes of a rendered topology, e.g.
+//
+//	topology=containers label~"api-*" cpu>80% connectedTo(internet)
+//
+// Terms are and'ed, unless separated by or; not negates a term, and terms
+// can be grouped in parentheses. Fields are compared as in alert
+// Conditions, with ~ and !~ matching globs too, case-insensitively. Fields
+// are the IDs of metadata and metrics, id, label, or cpu and memory for the
+// metric of either a node has; percentages are of the maximum of metrics.
+// connectedTo(x) matches nodes connected, either way, to nodes with IDs or
+// labels matching the glob, or to the internet.
+type Query struct {
+	Topology string
+	expr     queryExpr // nil matches all nodes
+}
+
+// QueryResult is what /api/query returns.
+type QueryResult struct {
+	Topology string   `json:"topology"`
+	Nodes    []string `json:"nodes"`
+}
+
+const internetTarget = "internet"
+
+// queryExpr is a term of a query, or a combination of them.
+type queryExpr interface {
+	matches(n *queryNode) bool
+}
+
+type (
+	andExpr  []queryExpr
+	orExpr   []queryExpr
+	notExpr  struct{ queryExpr }
+	compExpr struct {
+		field, op, value string
+		percent          bool
+		glob             *regexp.Regexp // of ~ and !~
+	}
+	connectedToExpr struct {
+		pattern *regexp.Regexp
+		target  string
+	}
+)
+
+func (e andExpr) matches(n *queryNode) bool {
+	for _, sub := range e {
+		if !sub.matches(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e orExpr) matches(n *queryNode) bool {
+	for _, sub := range e {
+		if sub.matches(n) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e notExpr) matches(n *queryNode) bool {
+	return !e.queryExpr.matches(n)
+}
+
+func (e compExpr) matches(n *queryNode) bool {
+	value, ok := n.value(e.field, e.percent)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "~":
+		return e.glob.MatchString(value)
+	case "!~":
+		return !e.glob.MatchString(value)
+	}
+	return Condition{Op: e.op, Value: e.value}.matches(value)
+}
+
+func (e connectedToExpr) matches(n *queryNode) bool {
+	for _, id := range n.adjacent {
+		if e.target == internetTarget {
+			if id == render.IncomingInternetID || id == render.OutgoingInternetID {
+				return true
+			}
+			continue
+		}
+		if e.pattern.MatchString(id) {
+			return true
+		}
+		if other, ok := n.env.summaries[id]; ok && e.pattern.MatchString(other.Label) {
+			return true
+		}
+	}
+	return false
+}
+
+// globRegexp makes an anchored, case-insensitive regexp out of a glob,
+// where * matches anything and ? any character.
+func globRegexp(glob string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(glob)
+	quoted = strings.Replace(quoted, `\*`, ".*", -1)
+	quoted = strings.Replace(quoted, `\?`, ".", -1)
+	return regexp.MustCompile("(?i)^" + quoted + "$")
+}
+
+// queryEnv is the rendered topology a query is evaluated against.
+type queryEnv struct {
+	summaries map[string]detailed.NodeSummary
+}
+
+// queryNode is a node a query is evaluated against.
+type queryNode struct {
+	env      *queryEnv
+	summary  detailed.NodeSummary
+	values   map[string]string
+	adjacent []string
+}
+
+// value returns the value of a field of the node, as a percentage of the
+// maximum of metrics if asked to.
+func (n *queryNode) value(field string, percent bool) (string, bool) {
+	switch field {
+	case "id":
+		return n.summary.ID, !percent
+	case "label":
+		return n.summary.Label, !percent
+	}
+	for _, row := range n.summary.Metrics {
+		if row.ValueEmpty || !(row.ID == field || (field == "cpu" || field == "memory") && strings.Contains(row.ID, field)) {
+			continue
+		}
+		value := row.Value
+		if percent && row.Format != report.PercentFormat {
+			if row.Metric == nil || row.Metric.Max <= 0 {
+				return "", false
+			}
+			value = value / row.Metric.Max * 100
+		}
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	}
+	if percent {
+		return "", false
+	}
+	value, ok := n.values[field]
+	return value, ok
+}
+
+// ParseQuery parses a query. Queries must name their topology.
+func ParseQuery(text string) (Query, error) {
+	q, err := parseQuery(text)
+	if err != nil {
+		return Query{}, err
+	}
+	if q.Topology == "" {
+		return Query{}, fmt.Errorf("no topology in query, e.g. topology=containers")
+	}
+	return q, nil
+}
+
+// parseQuery parses a query, whether or not it names its topology.
+func parseQuery(text string) (Query, error) {
+	tokens, err := tokenizeQuery(text)
+	if err != nil {
+		return Query{}, err
+	}
+	var (
+		p     = &queryParser{tokens: tokens}
+		q     Query
+		terms []queryExpr
+	)
+	for p.more() {
+		// topology=... is only allowed at the top level
+		if p.atTopology() {
+			p.next()
+			p.next()
+			if q.Topology != "" {
+				return Query{}, fmt.Errorf("more than one topology")
+			}
+			if q.Topology = p.next(); q.Topology == "" {
+				return Query{}, fmt.Errorf("topology expected")
+			}
+			continue
+		}
+		term, err := p.parseOr()
+		if err != nil {
+			return Query{}, err
+		}
+		terms = append(terms, term)
+	}
+	switch len(terms) {
+	case 0:
+	case 1:
+		q.expr = terms[0]
+	default:
+		q.expr = andExpr(terms)
+	}
+	return q, nil
+}
+
+var queryOps = []string{"==", "!=", "<=", ">=", "!~", "=", "<", ">", "~"}
+
+// tokenizeQuery splits a query into words, quoted strings (unquoted),
+// operators and parentheses. Quoted strings are returned with a leading
+// quote, so they can't be mistaken for keywords.
+func tokenizeQuery(text string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(text); {
+		c := rune(text[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			s, n, err := unquotePrefix(text[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, `"`+s)
+			i += n
+		default:
+			if op := opPrefix(text[i:]); op != "" {
+				tokens = append(tokens, op)
+				i += len(op)
+				continue
+			}
+			j := i
+			for j < len(text) && !unicode.IsSpace(rune(text[j])) && !strings.ContainsRune(`()"`, rune(text[j])) && opPrefix(text[j:]) == "" {
+				j++
+			}
+			tokens = append(tokens, text[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+func opPrefix(s string) string {
+	for _, op := range queryOps {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// unquotePrefix unquotes the quoted string s starts with, returning its
+// length in s.
+func unquotePrefix(s string) (string, int, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			unquoted, err := strconv.Unquote(s[:i+1])
+			return unquoted, i + 1, err
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string %s", s)
+}
+
+type queryParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *queryParser) more() bool { return p.pos < len(p.tokens) }
+
+func (p *queryParser) peek(i int) string {
+	if p.pos+i >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos+i]
+}
+
+func (p *queryParser) atTopology() bool {
+	return p.peek(0) == "topology" && (p.peek(1) == "=" || p.peek(1) == "==")
+}
+
+func (p *queryParser) next() string {
+	t := p.peek(0)
+	if p.more() {
+		p.pos++
+	}
+	return strings.TrimPrefix(t, `"`)
+}
+
+func (p *queryParser) expect(token string) error {
+	if t := p.peek(0); t != token {
+		return fmt.Errorf("%q expected, got %q", token, t)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *queryParser) parseOr() (queryExpr, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	terms := orExpr{first}
+	for strings.EqualFold(p.peek(0), "or") {
+		p.next()
+		term, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return terms, nil
+}
+
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	var terms andExpr
+	for p.more() && p.peek(0) != ")" && !strings.EqualFold(p.peek(0), "or") && !p.atTopology() {
+		term, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	switch len(terms) {
+	case 0:
+		return nil, fmt.Errorf("term expected, got %q", p.peek(0))
+	case 1:
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+func (p *queryParser) parseUnary() (queryExpr, error) {
+	switch t := p.peek(0); {
+	case strings.EqualFold(t, "not"):
+		p.next()
+		term, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{term}, nil
+	case t == "(":
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(")")
+	case strings.EqualFold(t, "connectedTo") && p.peek(1) == "(":
+		p.next()
+		p.next()
+		target := p.next()
+		if target == "" || target == ")" {
+			return nil, fmt.Errorf("connectedTo needs a node")
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return connectedToExpr{pattern: globRegexp(target), target: target}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *queryParser) parseComparison() (queryExpr, error) {
+	field := p.peek(0)
+	if field == "" || strings.HasPrefix(field, `"`) || opPrefix(field) != "" || field == "(" || field == ")" {
+		return nil, fmt.Errorf("field expected, got %q", field)
+	}
+	p.next()
+	op := p.next()
+	if op == "=" {
+		op = "=="
+	}
+	if _, ok := conditionOps[op]; !ok && op != "~" && op != "!~" {
+		return nil, fmt.Errorf("operator expected after %s, got %q", field, op)
+	}
+	if !p.more() {
+		return nil, fmt.Errorf("value expected after %s%s", field, op)
+	}
+	value := p.next()
+	e := compExpr{field: field, op: op, value: value}
+	if op == "~" || op == "!~" {
+		e.glob = globRegexp(value)
+	} else if strings.HasSuffix(value, "%") {
+		if _, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64); err == nil {
+			e.value = strings.TrimSuffix(value, "%")
+			e.percent = true
+		}
+	}
+	return e, nil
+}
+
+// Run evaluates the query against the given nodes of its topology,
+// returning the IDs of those matching, sorted.
+func (q Query) Run(rc detailed.RenderContext, nodes report.Nodes) []string {
+	env := &queryEnv{summaries: map[string]detailed.NodeSummary{}}
+	adjacent := map[string][]string{}
+	for id, n := range nodes {
+		for _, dst := range n.Adjacency {
+			adjacent[id] = append(adjacent[id], dst)
+			adjacent[dst] = append(adjacent[dst], id)
+		}
+		if summary, ok := detailed.MakeNodeSummary(rc, n); ok {
+			env.summaries[id] = summary
+		}
+	}
+	result := []string{}
+	for _, id := range sortedIDs(nodes) {
+		summary, ok := env.summaries[id]
+		if !ok || summary.Pseudo {
+			continue
+		}
+		n := &queryNode{
+			env:      env,
+			summary:  summary,
+			values:   nodeValues(summary),
+			adjacent: adjacent[id],
+		}
+		if q.expr == nil || q.expr.matches(n) {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// handleQuery runs the query in q against the topology it names, as per
+// the other parameters of the request, like /api/topology/{topology}.
+func handleQuery(ctx context.Context, rep Reporter, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondWith(w, http.StatusBadRequest, err)
+		return
+	}
+	q, err := ParseQuery(r.Form.Get("q"))
+	if err != nil {
+		respondWith(w, http.StatusBadRequest, err)
+		return
+	}
+	if _, ok := topologyRegistry.get(q.Topology); !ok {
+		respondWith(w, http.StatusBadRequest, fmt.Errorf("unknown topology: %q", q.Topology))
+		return
+	}
+	rpt, err := rep.Report(ctx, deserializeTimestamp(r.Form.Get("timestamp")))
+	if err != nil {
+		respondWith(w, http.StatusInternalServerError, err)
+		return
+	}
+	renderer, filter, err := topologyRegistry.RendererForTopology(q.Topology, r.Form, rpt)
+	if err != nil {
+		respondWith(w, http.StatusInternalServerError, err)
+		return
+	}
+	nodes := render.Render(rpt, renderer, filter).Nodes
+	respondWith(w, http.StatusOK, QueryResult{
+		Topology: q.Topology,
+		Nodes:    q.Run(RenderContextForReporter(rep, rpt), nodes),
+	})
+}
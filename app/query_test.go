@@ -0,0 +1,57 @@
+package app_test
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestAPIQuery(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	var (
+		client = fixture.ClientContainerNodeID
+		server = fixture.ServerContainerNodeID
+	)
+	for query, want := range map[string][]string{
+		"topology=containers":                                                                {server, client},
+		`topology=containers label~"CLI*"`:                                                   {client},
+		`topology=containers not label~"cli*"`:                                               {server},
+		"topology=containers label=client or label=nonesuch":                                 {client},
+		"topology=containers connectedTo(internet)":                                          {server},
+		"topology=containers connectedTo(client)":                                            {server},
+		"topology=containers (connectedTo(client) or cpu>0.04) docker_cpu_total_usage>=0.05": {server},
+		"topology=containers cpu<0.04":                                                       {client},
+		"topology=containers memory>50%":                                                     {server, client},
+		"topology=containers nonesuch=1":                                                     {},
+	} {
+		body := getRawJSON(t, ts, "/api/query?q="+url.QueryEscape(query))
+		var have app.QueryResult
+		if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&have); err != nil {
+			t.Fatal(err)
+		}
+		if have.Topology != "containers" || !reflect.DeepEqual(want, have.Nodes) {
+			t.Errorf("%s: want %v, have %v", query, want, have.Nodes)
+		}
+	}
+
+	for _, query := range []string{
+		"",
+		"label=client",
+		"topology=nonesuch",
+		"topology=containers topology=hosts",
+		"topology=containers label",
+		"topology=containers label? client",
+		`topology=containers label="client`,
+		"topology=containers (label=client",
+		"topology=containers connectedTo()",
+	} {
+		is400(t, ts, "/api/query?q="+url.QueryEscape(query))
+	}
+}
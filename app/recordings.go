@@ -0,0 +1,356 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+const (
+	// maxRecordings is how many recordings are kept in memory, without a
+	// RecordingStore.
+	maxRecordings = 100
+	// maxRecordingBytes bounds recordings, in case of terminals left open
+	// with a lot of output.
+	maxRecordingBytes = 16 * 1024 * 1024
+	// maxRecordingPipes bounds the controls remembered for the terminals
+	// they opened, in case the pipes are never connected to.
+	maxRecordingPipes = 1000
+
+	recordingWidth, recordingHeight = 80, 24
+)
+
+// Recording is what a recording of a terminal session is of: who used
+// which terminal (attach or exec) of which node, and when.
+type Recording struct {
+	ID              string    `json:"id"`
+	PipeID          string    `json:"pipe_id"`
+	ProbeID         string    `json:"probe_id"`
+	NodeID          string    `json:"node_id"`
+	Control         string    `json:"control"`
+	User            string    `json:"user,omitempty"` // as told by the Policy
+	RemoteAddr      string    `json:"remote_addr,omitempty"`
+	Start           time.Time `json:"start"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Keystrokes      bool      `json:"keystrokes"`          // if the input of the user was recorded
+	Truncated       bool      `json:"truncated,omitempty"` // at maxRecordingBytes
+	Size            int       `json:"size"`
+}
+
+// RecordingStore is somewhere recordings of terminal sessions are kept
+// across restarts of the app, like a ReportStore.
+type RecordingStore interface {
+	StoreRecording(ctx context.Context, r Recording, cast []byte) error
+	LoadRecordings(ctx context.Context) ([]Recording, error)
+	// FetchRecording returns the cast of a recording, nil if there is
+	// none.
+	FetchRecording(ctx context.Context, id string) ([]byte, error)
+}
+
+// Recordings records the terminal sessions users open through pipes, as
+// asciicast v2 (https://docs.asciinema.org/manual/asciicast/v2/), which
+// asciinema can replay. The output of terminals is always recorded; the
+// input of users, if asked, as it may have passwords.
+type Recordings struct {
+	store      RecordingStore
+	keystrokes bool
+
+	mtx        sync.Mutex
+	recordings []Recording       // oldest first
+	casts      map[string][]byte // by recording ID, without a store
+	pipes      map[string]Recording
+	sessions   map[pipeSessionKey]*recordingSession
+}
+
+// NewRecordings makes Recordings, loading what there is of them from
+// store, nil to only keep the last ones in memory.
+func NewRecordings(ctx context.Context, store RecordingStore, keystrokes bool) (*Recordings, error) {
+	r := &Recordings{
+		store:      store,
+		keystrokes: keystrokes,
+		casts:      map[string][]byte{},
+		pipes:      map[string]Recording{},
+		sessions:   map[pipeSessionKey]*recordingSession{},
+	}
+	if store != nil {
+		loaded, err := store.LoadRecordings(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(loaded, func(i, j int) bool { return loaded[i].Start.Before(loaded[j].Start) })
+		r.recordings = loaded
+	}
+	return r, nil
+}
+
+// List returns the recordings of a node and user, either or both of which
+// can be empty for all, oldest first.
+func (r *Recordings) List(nodeID, user string) []Recording {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	result := []Recording{}
+	for _, recording := range r.recordings {
+		if (nodeID == "" || recording.NodeID == nodeID) && (user == "" || recording.User == user) {
+			result = append(result, recording)
+		}
+	}
+	return result
+}
+
+// Fetch returns the cast of a recording, nil if there is none.
+func (r *Recordings) Fetch(ctx context.Context, id string) ([]byte, error) {
+	if r.store != nil {
+		return r.store.FetchRecording(ctx, id)
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.casts[id], nil
+}
+
+func (r *Recordings) add(ctx context.Context, recording Recording, cast []byte) {
+	if r.store != nil {
+		if err := r.store.StoreRecording(ctx, recording, cast); err != nil {
+			log.Errorf("Error storing recording of pipe %s: %v", recording.PipeID, err)
+			return
+		}
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.recordings = append(r.recordings, recording)
+	if r.store == nil {
+		r.casts[recording.ID] = cast
+		if len(r.recordings) > maxRecordings {
+			delete(r.casts, r.recordings[0].ID)
+			r.recordings = r.recordings[1:]
+		}
+	}
+}
+
+// NewRecordingControlRouter makes a ControlRouter remembering the
+// terminals opened by the controls the given one handles, to record them.
+func NewRecordingControlRouter(cr ControlRouter, r *Recordings) ControlRouter {
+	return &recordingControlRouter{ControlRouter: cr, recordings: r}
+}
+
+type recordingControlRouter struct {
+	ControlRouter
+	recordings *Recordings
+}
+
+func (cr *recordingControlRouter) Handle(ctx context.Context, probeID string, req xfer.Request) (xfer.Response, error) {
+	res, err := cr.ControlRouter.Handle(ctx, probeID, req)
+	// Terminals, i.e. attach and exec, are raw; logs and the like not.
+	if err != nil || res.Pipe == "" || !res.RawTTY {
+		return res, err
+	}
+	r := cr.recordings
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if len(r.pipes) >= maxRecordingPipes {
+		for id := range r.pipes {
+			delete(r.pipes, id)
+			break
+		}
+	}
+	r.pipes[res.Pipe] = Recording{PipeID: res.Pipe, ProbeID: probeID, NodeID: req.NodeID, Control: req.Control}
+	return res, err
+}
+
+// NewRecordingPipeRouter makes a PipeRouter recording the sessions of users
+// on the terminals of the given one.
+func NewRecordingPipeRouter(pr PipeRouter, r *Recordings) PipeRouter {
+	return &recordingPipeRouter{PipeRouter: pr, recordings: r}
+}
+
+type recordingPipeRouter struct {
+	PipeRouter
+	recordings *Recordings
+}
+
+type recordingSession struct {
+	io.ReadWriter
+	recording  Recording
+	keystrokes bool
+
+	mtx     sync.Mutex
+	events  bytes.Buffer
+	partial [2][]byte // incomplete UTF-8 at the ends of the output and input
+}
+
+// Read is the output of the terminal to the user.
+func (s *recordingSession) Read(p []byte) (int, error) {
+	n, err := s.ReadWriter.Read(p)
+	s.record(0, "o", p[:n])
+	return n, err
+}
+
+// Write is the input of the user to the terminal.
+func (s *recordingSession) Write(p []byte) (int, error) {
+	n, err := s.ReadWriter.Write(p)
+	if s.keystrokes {
+		s.record(1, "i", p[:n])
+	}
+	return n, err
+}
+
+// record adds an event of output ("o") or input ("i"). Events are of
+// text, so runes cut in two by reads wait for their ends.
+func (s *recordingSession) record(stream int, kind string, p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.recording.Truncated {
+		return
+	}
+	data, partial := splitUTF8(append(s.partial[stream], p...))
+	s.partial[stream] = append([]byte(nil), partial...)
+	if len(data) == 0 {
+		return
+	}
+	var event []byte
+	seconds := mtime.Now().Sub(s.recording.Start).Seconds()
+	if err := codec.NewEncoderBytes(&event, &codec.JsonHandle{}).Encode([]interface{}{seconds, kind, string(data)}); err != nil {
+		log.Errorf("Error encoding recording of pipe %s: %v", s.recording.PipeID, err)
+		return
+	}
+	if s.events.Len()+len(event)+1 > maxRecordingBytes {
+		s.recording.Truncated = true
+		return
+	}
+	s.events.Write(event)
+	s.events.WriteByte('\n')
+}
+
+// splitUTF8 splits an incomplete rune off the end of p.
+func splitUTF8(p []byte) ([]byte, []byte) {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return p[:i], p[i:]
+			}
+			break
+		}
+	}
+	return p, nil
+}
+
+// cast returns the recording, and the asciicast of the session.
+func (s *recordingSession) cast() (Recording, []byte, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	recording := s.recording
+	recording.DurationSeconds = mtime.Now().Sub(recording.Start).Seconds()
+	var header []byte
+	if err := codec.NewEncoderBytes(&header, &codec.JsonHandle{}).Encode(map[string]interface{}{
+		"version":   2,
+		"width":     recordingWidth,
+		"height":    recordingHeight,
+		"timestamp": recording.Start.Unix(),
+		"title":     fmt.Sprintf("%s of %s", recording.Control, recording.NodeID),
+	}); err != nil {
+		return recording, nil, err
+	}
+	cast := make([]byte, 0, len(header)+1+s.events.Len())
+	cast = append(append(header, '\n'), s.events.Bytes()...)
+	recording.Size = len(cast)
+	return recording, cast, nil
+}
+
+func (pr *recordingPipeRouter) Get(ctx context.Context, id string, e End) (xfer.Pipe, io.ReadWriter, error) {
+	pipe, endIO, err := pr.PipeRouter.Get(ctx, id, e)
+	if err != nil || e != UIEnd {
+		return pipe, endIO, err
+	}
+	r := pr.recordings
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	recording, ok := r.pipes[id]
+	if !ok {
+		return pipe, endIO, nil
+	}
+	recording.Start = mtime.Now()
+	recording.ID = fmt.Sprintf("%d-%s", recording.Start.UnixNano(), unsafeFileChars.ReplaceAllString(id, "_"))
+	recording.Keystrokes = r.keystrokes
+	recording.User, recording.RemoteAddr = auditRequest(ctx)
+	s := &recordingSession{ReadWriter: endIO, recording: recording, keystrokes: r.keystrokes}
+	r.sessions[pipeSessionKey{id, ctx.Value(RequestCtxKey)}] = s
+	return pipe, s, nil
+}
+
+func (pr *recordingPipeRouter) Release(ctx context.Context, id string, e End) error {
+	err := pr.PipeRouter.Release(ctx, id, e)
+	if e != UIEnd {
+		return err
+	}
+	r := pr.recordings
+	key := pipeSessionKey{id, ctx.Value(RequestCtxKey)}
+	r.mtx.Lock()
+	s, ok := r.sessions[key]
+	delete(r.sessions, key)
+	r.mtx.Unlock()
+	if !ok {
+		return err
+	}
+	recording, cast, castErr := s.cast()
+	if castErr != nil {
+		log.Errorf("Error encoding recording of pipe %s: %v", id, castErr)
+		return err
+	}
+	r.add(ctx, recording, cast)
+	return err
+}
+
+func (pr *recordingPipeRouter) Delete(ctx context.Context, id string) error {
+	pr.recordings.mtx.Lock()
+	delete(pr.recordings.pipes, id)
+	pr.recordings.mtx.Unlock()
+	return pr.PipeRouter.Delete(ctx, id)
+}
+
+// RegisterRecordingRoutes registers the routes listing recordings, e.g.
+// /api/recordings?node=...&user=alice, and replaying them, as
+// /api/recordings/{id}, e.g. with asciinema play.
+func RegisterRecordingRoutes(router *mux.Router, r *Recordings) {
+	router.Methods("GET").
+		Name("api_recordings").
+		Path("/api/recordings").
+		HandlerFunc(requestContextDecorator(func(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+			values := req.URL.Query()
+			respondWith(w, http.StatusOK, r.List(values.Get("node"), values.Get("user")))
+		}))
+	router.Methods("GET").
+		Name("api_recordings_id").
+		Path("/api/recordings/{id}").
+		HandlerFunc(requestContextDecorator(handleGetRecording(r)))
+}
+
+func handleGetRecording(r *Recordings) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+		id := mux.Vars(req)["id"]
+		cast, err := r.Fetch(ctx, id)
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		} else if cast == nil {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-asciicast")
+		w.Write(cast)
+	}
+}
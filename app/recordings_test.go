@@ -0,0 +1,147 @@
+package app
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+func TestRecordings(t *testing.T) {
+	start := time.Date(2017, 3, 3, 16, 4, 48, 0, time.UTC)
+	mtime.NowForce(start)
+	defer mtime.NowReset()
+
+	recordings, err := NewRecordings(context.Background(), nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := NewRecordingControlRouter(NewLocalControlRouter(), recordings)
+	pr := NewRecordingPipeRouter(NewLocalPipeRouter(), recordings)
+	defer pr.Stop()
+	if _, err := cr.Register(context.Background(), "probe", func(req xfer.Request) xfer.Response {
+		switch req.Control {
+		case "exec":
+			return xfer.Response{Pipe: "pipe1", RawTTY: true}
+		case "logs":
+			return xfer.Response{Pipe: "pipe2"}
+		}
+		return xfer.ResponseErrorf("no such control")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func() context.Context {
+		r := httptest.NewRequest("POST", "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), userCtxKey, "alice"))
+		return context.WithValue(context.Background(), RequestCtxKey, r)
+	}
+	for _, control := range []string{"exec", "logs"} {
+		if _, err := cr.Handle(request(), "probe", xfer.Request{NodeID: "node", Control: control}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only terminals are recorded.
+	for _, id := range []string{"pipe1", "pipe2"} {
+		ctx := request()
+		_, ui, err := pr.Get(ctx, id, UIEnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, probe, err := pr.Get(context.Background(), id, ProbeEnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// An é cut in two by reads
+		for _, output := range []string{"$ caf\xc3", "\xa9"} {
+			go probe.Write([]byte(output))
+			buf := make([]byte, len(output))
+			if _, err := ui.Read(buf); err != nil {
+				t.Fatal(err)
+			}
+			mtime.NowForce(mtime.Now().Add(time.Second))
+		}
+		go probe.Read(make([]byte, 3))
+		if _, err := ui.Write([]byte("ls\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := pr.Release(ctx, id, UIEnd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := recordings.List("node", "alice")
+	if len(list) != 1 {
+		t.Fatalf("expected 1 recording, got %v", list)
+	}
+	recording := list[0]
+	if recording.PipeID != "pipe1" || recording.Control != "exec" || recording.DurationSeconds != 2 || !recording.Keystrokes || recording.Truncated {
+		t.Errorf("unexpected recording %+v", recording)
+	}
+	if list := recordings.List("", "bob"); len(list) != 0 {
+		t.Errorf("expected no recordings, got %v", list)
+	}
+
+	router := mux.NewRouter()
+	RegisterRecordingRoutes(router, recordings)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/api/recordings?node=node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list = nil
+	err = codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(&list)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != recording.ID {
+		t.Errorf("unexpected recordings %v", list)
+	}
+
+	resp, err = http.Get(server.URL + "/api/recordings/" + recording.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cast, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("Content-Type") != "application/x-asciicast" {
+		t.Errorf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(string(cast)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected a header and 3 events, got %q", cast)
+	}
+	var header map[string]interface{}
+	if err := codec.NewDecoderBytes([]byte(lines[0]), &codec.JsonHandle{}).Decode(&header); err != nil {
+		t.Fatal(err)
+	}
+	if header["version"] != uint64(2) || header["timestamp"] != uint64(start.Unix()) {
+		t.Errorf("unexpected header %v", header)
+	}
+	for i, expected := range []string{`[0.0,"o","$ caf"]`, `[1.0,"o","é"]`, `[2.0,"i","ls\n"]`} {
+		if lines[i+1] != expected {
+			t.Errorf("expected event %s, got %s", expected, lines[i+1])
+		}
+	}
+
+	if resp, err := http.Get(server.URL + "/api/recordings/nope"); err != nil {
+		t.Fatal(err)
+	} else if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
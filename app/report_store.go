@@ -0,0 +1,130 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+// ReportStore is somewhere reports can be kept for longer than the app
+// holds them in memory, keyed by the time they were received.
+type ReportStore interface {
+	// StoreReport stores a report, as gzip'd msgpack, received at the
+	// given time.
+	StoreReport(ctx context.Context, timestamp time.Time, buf []byte) error
+	// FetchReports returns the reports received after from and until
+	// through, in the order they were received.
+	FetchReports(ctx context.Context, from, through time.Time) ([]report.Report, error)
+}
+
+// storingCollector is a collector which also writes all the reports it
+// is given to a ReportStore, and reads them back from it once they are
+// too old to be held in memory.
+type storingCollector struct {
+	*collector
+	store ReportStore
+
+	fetchMtx        sync.Mutex
+	cachedTimestamp time.Time // of the last report fetched from the store
+	cachedWindow    time.Duration
+	cached          report.Report
+	cachedEmpty     bool
+}
+
+// NewStoringCollector returns a collector keeping the reports of the last
+// history in memory, which also stores all reports in the given store.
+// Reports older than that are fetched from the store when asked for.
+func NewStoringCollector(window, history time.Duration, store ReportStore) Collector {
+	return &storingCollector{
+		collector: NewCollectorWithHistory(window, history).(*collector),
+		store:     store,
+	}
+}
+
+// Add stores a report and adds it to the collector. It implements Adder.
+// Reports replayed by probes are stored at the time they were published.
+func (c *storingCollector) Add(ctx context.Context, rpt report.Report, buf []byte) error {
+	if buf == nil {
+		var b bytes.Buffer
+		if err := rpt.WriteBinary(&b, gzip.DefaultCompression); err != nil {
+			return err
+		}
+		buf = b.Bytes()
+	}
+	// Failing to store a report shouldn't break the live view, nor make
+	// the probe resend it.
+	if err := c.store.StoreReport(ctx, reportTimestamp(ctx), buf); err != nil {
+		log.Errorf("Error storing report: %v", err)
+	}
+	return c.collector.Add(ctx, rpt, buf)
+}
+
+// Report returns a merged report over the reports of the window before
+// timestamp. It implements Reporter.
+func (c *storingCollector) Report(ctx context.Context, timestamp time.Time) (report.Report, error) {
+	return c.ReportWindow(ctx, timestamp, c.window)
+}
+
+// ReportWindow returns a merged report over the reports received within
+// the given window before timestamp. It implements WindowedReporter.
+func (c *storingCollector) ReportWindow(ctx context.Context, timestamp time.Time, window time.Duration) (report.Report, error) {
+	if c.inMemory(timestamp, window) {
+		if window == c.window {
+			return c.collector.Report(ctx, timestamp)
+		}
+		return c.collector.ReportWindow(ctx, timestamp, window)
+	}
+	rpt, _, err := c.fetch(ctx, timestamp, window)
+	return rpt, err
+}
+
+// HasReports indicates whether there are reports between
+// timestamp-app.window and timestamp.
+func (c *storingCollector) HasReports(ctx context.Context, timestamp time.Time) (bool, error) {
+	if c.inMemory(timestamp, c.window) {
+		return c.collector.HasReports(ctx, timestamp)
+	}
+	_, empty, err := c.fetch(ctx, timestamp, c.window)
+	return !empty, err
+}
+
+// HasHistoricReports indicates whether there are reports older than
+// now-app.window, which there are once they are stored.
+func (c *storingCollector) HasHistoricReports() bool {
+	return true
+}
+
+// inMemory tells whether the reports of the window before timestamp are
+// still held in memory.
+func (c *storingCollector) inMemory(timestamp time.Time, window time.Duration) bool {
+	return !timestamp.Add(-window).Before(mtime.Now().Add(-c.history))
+}
+
+// fetch merges the reports of the window before timestamp from the store.
+// The last result is kept, since the same time is usually asked for a few
+// times in a row, e.g. by HasReports and then Report.
+func (c *storingCollector) fetch(ctx context.Context, timestamp time.Time, window time.Duration) (report.Report, bool, error) {
+	c.fetchMtx.Lock()
+	defer c.fetchMtx.Unlock()
+	if timestamp.Equal(c.cachedTimestamp) && window == c.cachedWindow {
+		return c.cached, c.cachedEmpty, nil
+	}
+
+	reports, err := c.store.FetchReports(ctx, timestamp.Add(-window), timestamp)
+	if err != nil {
+		return report.MakeReport(), true, err
+	}
+	for i := range reports {
+		reports[i] = reports[i].Upgrade()
+	}
+	c.cachedTimestamp, c.cachedWindow = timestamp, window
+	c.cached, c.cachedEmpty = c.merger.Merge(reports), len(reports) == 0
+	return c.cached, c.cachedEmpty, nil
+}
@@ -0,0 +1,85 @@
+package app_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/report"
+)
+
+type mockReportStore struct {
+	timestamps []time.Time
+	bufs       [][]byte
+}
+
+func (s *mockReportStore) StoreReport(_ context.Context, timestamp time.Time, buf []byte) error {
+	s.timestamps = append(s.timestamps, timestamp)
+	s.bufs = append(s.bufs, buf)
+	return nil
+}
+
+func (s *mockReportStore) FetchReports(_ context.Context, from, through time.Time) ([]report.Report, error) {
+	var reports []report.Report
+	for i, t := range s.timestamps {
+		if t.After(from) && !t.After(through) {
+			rpt, err := report.MakeFromBinary(bytes.NewReader(s.bufs[i]))
+			if err != nil {
+				return nil, err
+			}
+			reports = append(reports, *rpt)
+		}
+	}
+	return reports, nil
+}
+
+func TestStoringCollector(t *testing.T) {
+	ctx := context.Background()
+	window := 10 * time.Second
+	store := &mockReportStore{}
+	c := app.NewStoringCollector(window, window, store)
+
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	r1 := report.MakeReport()
+	r1.Endpoint.AddNode(report.MakeNode("foo"))
+	c.Add(ctx, r1, nil)
+	if len(store.bufs) != 1 {
+		t.Fatalf("report not stored")
+	}
+
+	// Long after the report left the collector's memory, it is fetched
+	// from the store.
+	mtime.NowForce(now.Add(time.Hour))
+	if has, err := c.HasReports(ctx, now); err != nil || !has {
+		t.Fatalf("no stored reports: %v", err)
+	}
+	have, err := c.Report(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := have.Endpoint.Nodes["foo"]; !ok {
+		t.Errorf("stored report not fetched: %v", have)
+	}
+	if !c.HasHistoricReports() {
+		t.Error("no historic reports")
+	}
+
+	// There is nothing before that.
+	if has, err := c.HasReports(ctx, now.Add(-time.Minute)); err != nil || has {
+		t.Errorf("unexpected stored reports: %v", err)
+	}
+
+	// Replayed reports are stored at the time they were published.
+	published := now.Add(30 * time.Minute)
+	c.Add(context.WithValue(ctx, app.ReportTimestampCtxKey, published), r1, nil)
+	if len(store.timestamps) != 2 || !store.timestamps[1].Equal(published) {
+		t.Errorf("replayed report stored at %v, not %v", store.timestamps, published)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
@@ -14,6 +15,9 @@ import (
 	"github.com/PuerkitoBio/ghost/handlers"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
+	"github.com/klauspost/compress/zstd"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	"github.com/ugorji/go/codec"
 	"golang.org/x/net/context"
 
@@ -38,16 +42,39 @@ type contextKey string
 // RequestCtxKey is key used for request entry in context
 const RequestCtxKey contextKey = contextKey("request")
 
+// ReportTimestampCtxKey is the key of when a report was published, in the
+// context of Adder.Add, for reports probes replay after the fact.
+const ReportTimestampCtxKey contextKey = contextKey("reportTimestamp")
+
 // CtxHandlerFunc is a http.HandlerFunc, with added contexts
 type CtxHandlerFunc func(context.Context, http.ResponseWriter, *http.Request)
 
 func requestContextDecorator(f CtxHandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := context.WithValue(context.Background(), RequestCtxKey, r)
+		span, ctx := startRequestSpan(ctx, r)
+		defer span.Finish()
 		f(ctx, w, r)
 	}
 }
 
+// startRequestSpan starts the span of a request, named after its route and
+// continuing the trace of the caller, if any.
+func startRequestSpan(ctx context.Context, r *http.Request) (opentracing.Span, context.Context) {
+	name := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			name = template
+		}
+	}
+	tracer := opentracing.GlobalTracer()
+	parent, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	span := tracer.StartSpan(r.Method+" "+name, ext.RPCServerOption(parent))
+	ext.HTTPMethod.Set(span, r.Method)
+	ext.HTTPUrl.Set(span, r.URL.String())
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
 // URLMatcher uses request.RequestURI (the raw, unparsed request) to attempt
 // to match pattern.  It does this as go's URL.Parse method is broken, and
 // mistakenly unescapes the Path before parsing it.  This breaks %2F (encoded
@@ -107,8 +134,35 @@ func RegisterTopologyRoutes(router *mux.Router, r Reporter, capabilities map[str
 		Name("api_topology_topology_ws")
 	get.
 		MatcherFunc(URLMatcher("/api/topology/{topology}/{id}")).HandlerFunc(
-		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleNode)))).
+		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, makeNodeHandler(r))))).
 		Name("api_topology_topology_id")
+	get.
+		MatcherFunc(URLMatcher("/api/topology/{topology}/{id}/ws")).HandlerFunc(
+		requestContextDecorator(captureReporter(r, handleNodeWebsocket))). // NB not gzip!
+		Name("api_topology_topology_id_ws")
+	get.
+		MatcherFunc(URLMatcher("/api/topology/{topology}/{id}/connections.csv")).HandlerFunc(
+		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, makeConnectionsExportHandler(r, csvFormat))))).
+		Name("api_topology_topology_id_connections_csv")
+	get.
+		MatcherFunc(URLMatcher("/api/topology/{topology}/{id}/connections.ndjson")).HandlerFunc(
+		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, makeConnectionsExportHandler(r, ndjsonFormat))))).
+		Name("api_topology_topology_id_connections_ndjson")
+	get.HandleFunc("/api/snapshot/{topology}",
+		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleSnapshot)))).
+		Name("api_snapshot_topology")
+	get.HandleFunc("/api/diagram/{topology}",
+		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleDiagram)))).
+		Name("api_diagram_topology")
+	get.HandleFunc("/api/networkpolicies",
+		gzipHandler(requestContextDecorator(captureReporter(r, handleNetworkPolicies))))
+	get.HandleFunc("/api/query",
+		gzipHandler(requestContextDecorator(captureReporter(r, handleQuery))))
+	router.Methods("GET", "POST").Path("/api/graphql").HandlerFunc(
+		gzipHandler(requestContextDecorator(makeGraphQLHandler(r))))
+	get.HandleFunc("/api/diff/{topology}",
+		gzipHandler(requestContextDecorator(topologyRegistry.makeTopologyDiff(r)))).
+		Name("api_diff_topology")
 	get.HandleFunc("/api/report",
 		gzipHandler(requestContextDecorator(makeRawReportHandler(r))))
 	get.HandleFunc("/api/probes",
@@ -117,6 +171,8 @@ func RegisterTopologyRoutes(router *mux.Router, r Reporter, capabilities map[str
 
 // RegisterReportPostHandler registers the handler for report submission
 func RegisterReportPostHandler(a Adder, router *mux.Router) {
+	deltas := newDeltaReassembler()
+	decoder, _ := zstd.NewReader(nil) // only fails on bad options
 	post := router.Methods("POST").Subrouter()
 	post.HandleFunc("/api/report", requestContextDecorator(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 		var (
@@ -125,36 +181,84 @@ func RegisterReportPostHandler(a Adder, router *mux.Router) {
 			reader = io.TeeReader(r.Body, &buf)
 		)
 
-		gzipped := strings.Contains(r.Header.Get("Content-Encoding"), "gzip")
-		if !gzipped {
+		contentEncoding := r.Header.Get("Content-Encoding")
+		gzipped := strings.Contains(contentEncoding, "gzip")
+		zstded := strings.Contains(contentEncoding, xfer.ZstdEncoding)
+		if zstded {
+			body, err := ioutil.ReadAll(r.Body)
+			if err == nil {
+				body, err = decoder.DecodeAll(body, nil)
+			}
+			if err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			reader = bytes.NewReader(body)
+		} else if !gzipped {
 			reader = io.TeeReader(r.Body, gzip.NewWriter(&buf))
 		}
 
 		contentType := r.Header.Get("Content-Type")
 		isMsgpack := strings.HasPrefix(contentType, "application/msgpack")
-		var handle codec.Handle
+		var read func() error
 		switch {
 		case strings.HasPrefix(contentType, "application/json"):
-			handle = &codec.JsonHandle{}
+			read = func() error { return rpt.ReadBinary(reader, gzipped, &codec.JsonHandle{}) }
 		case isMsgpack:
-			handle = &codec.MsgpackHandle{}
+			read = func() error { return rpt.ReadBinary(reader, gzipped, &codec.MsgpackHandle{}) }
+		case strings.HasPrefix(contentType, report.ProtobufContentType):
+			read = func() error { return rpt.ReadProtobuf(reader, gzipped) }
+		case strings.HasPrefix(contentType, report.DeltaContentType):
+			read = func() error {
+				var delta report.Delta
+				if err := delta.ReadBinary(reader, gzipped); err != nil {
+					return err
+				}
+				var err error
+				rpt, err = deltas.reassemble(r.Header.Get(xfer.ScopeProbeIDHeader), delta)
+				return err
+			}
 		default:
 			respondWith(w, http.StatusBadRequest, fmt.Errorf("Unsupported Content-Type: %v", contentType))
 			return
 		}
 
-		if err := rpt.ReadBinary(reader, gzipped, handle); err != nil {
-			respondWith(w, http.StatusBadRequest, err)
+		span, _ := opentracing.StartSpanFromContext(ctx, "decode report")
+		span.SetTag("content_type", contentType)
+		span.SetTag("content_encoding", contentEncoding)
+		err := read()
+		if err != nil {
+			ext.Error.Set(span, true)
+		}
+		span.Finish()
+		if err != nil {
+			code := http.StatusBadRequest
+			if _, ok := err.(errUnknownBaseline); ok {
+				code = http.StatusConflict
+			}
+			respondWith(w, code, err)
 			return
 		}
 
 		// a.Add(..., buf) assumes buf is gzip'd msgpack
-		if !isMsgpack {
+		if !isMsgpack || zstded {
 			buf = bytes.Buffer{}
 			rpt.WriteBinary(&buf, gzip.DefaultCompression)
 		}
 
-		if err := a.Add(ctx, rpt, buf.Bytes()); err != nil {
+		if ts := r.Header.Get(xfer.ScopeReportTimestampHeader); ts != "" {
+			timestamp, err := time.Parse(time.RFC3339Nano, ts)
+			if err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			ctx = context.WithValue(ctx, ReportTimestampCtxKey, timestamp)
+		}
+
+		span, ctx = opentracing.StartSpanFromContext(ctx, "add report")
+		err = a.Add(ctx, rpt, buf.Bytes())
+		span.Finish()
+		if err != nil {
 			log.Errorf("Error Adding report: %v", err)
 			respondWith(w, http.StatusInternalServerError, err)
 			return
@@ -187,6 +291,8 @@ func apiHandler(rep Reporter, capabilities map[string]bool) CtxHandlerFunc {
 		}
 		newVersion.Lock()
 		defer newVersion.Unlock()
+		// Tell probes which content codings reports may be published in
+		w.Header().Set("Accept-Encoding", "gzip, "+xfer.ZstdEncoding)
 		respondWith(w, http.StatusOK, xfer.Details{
 			ID:           UniqueID,
 			Version:      Version,
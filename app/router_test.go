@@ -2,6 +2,7 @@ package app_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -10,11 +11,14 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/klauspost/compress/zstd"
 	"github.com/ugorji/go/codec"
 	"golang.org/x/net/context"
 
 	"github.com/weaveworks/common/test"
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/fixture"
 )
 
@@ -93,4 +97,106 @@ func TestReportPostHandler(t *testing.T) {
 		err := codec.NewEncoder(buf, &codec.MsgpackHandle{}).Encode(v)
 		return buf.Bytes(), err
 	})
+	test(report.ProtobufContentType, func(v interface{}) ([]byte, error) {
+		return v.(report.Report).MarshalProtobuf()
+	})
+}
+
+func TestReportDeltaPostHandler(t *testing.T) {
+	router := mux.NewRouter()
+	c := app.NewCollector(1 * time.Minute)
+	app.RegisterReportPostHandler(c, router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	post := func(probeID string, delta report.Delta) int {
+		buf := &bytes.Buffer{}
+		if err := delta.WriteBinary(buf, gzip.DefaultCompression); err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", ts.URL+"/api/report", buf)
+		if err != nil {
+			t.Fatalf("Error posting delta: %v", err)
+		}
+		req.Header.Set("Content-Type", report.DeltaContentType)
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set(xfer.ScopeProbeIDHeader, probeID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error posting delta: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	baseline := report.MakeReport()
+	baseline.Host.AddNode(report.MakeNodeWith("a", map[string]string{"os": "linux"}))
+	baseline.Host.AddNode(report.MakeNodeWith("b", map[string]string{"os": "linux"}))
+	rpt := report.MakeReport()
+	rpt.Host.AddNode(report.MakeNodeWith("a", map[string]string{"os": "linux"}))
+	rpt.Host.AddNode(report.MakeNodeWith("c", map[string]string{"os": "linux"}))
+	delta := report.MakeDelta(baseline, rpt)
+
+	if want, have := http.StatusConflict, post("probe", delta); want != have {
+		t.Fatalf("want %d for a delta without baseline, have %d", want, have)
+	}
+	if want, have := http.StatusOK, post("probe", report.Delta{Report: baseline}); want != have {
+		t.Fatalf("want %d posting the baseline, have %d", want, have)
+	}
+	if want, have := http.StatusConflict, post("other", delta); want != have {
+		t.Fatalf("want %d for the delta of another probe, have %d", want, have)
+	}
+	if want, have := http.StatusOK, post("probe", delta); want != have {
+		t.Fatalf("want %d posting the delta, have %d", want, have)
+	}
+
+	have, err := c.Report(context.Background(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both reports are in the window, so b is still there.
+	if _, ok := have.Host.Nodes["c"]; !ok || len(have.Host.Nodes) != 3 {
+		t.Errorf("unexpected hosts: %v", have.Host.Nodes)
+	}
+}
+
+func TestReportPostHandlerZstd(t *testing.T) {
+	router := mux.NewRouter()
+	c := app.NewCollector(1 * time.Minute)
+	app.RegisterReportPostHandler(c, router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	buf := &bytes.Buffer{}
+	if err := codec.NewEncoder(buf, &codec.MsgpackHandle{}).Encode(fixture.Report); err != nil {
+		t.Fatal(err)
+	}
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer encoder.Close()
+
+	req, err := http.NewRequest("POST", ts.URL+"/api/report", bytes.NewReader(encoder.EncodeAll(buf.Bytes(), nil)))
+	if err != nil {
+		t.Fatalf("Error posting report: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("Content-Encoding", xfer.ZstdEncoding)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error posting report: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Error posting report: %d", resp.StatusCode)
+	}
+
+	have, err := c.Report(context.Background(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := len(fixture.Report.Endpoint.Nodes), len(have.Endpoint.Nodes); want != have {
+		t.Errorf("want %d endpoints, have %d", want, have)
+	}
 }
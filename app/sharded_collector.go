@@ -0,0 +1,303 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	// virtualShards is how many points every shard has on the hash ring,
+	// to spread the probes evenly.
+	virtualShards = 64
+
+	shardTimeout = 10 * time.Second
+
+	// shardCacheInterval is how long a merged report of the latest
+	// reports of the shards is reused for.
+	shardCacheInterval = time.Second
+)
+
+// forwardedHeaders are the headers of reports kept when forwarding them to
+// a shard.
+var forwardedHeaders = []string{
+	"Content-Type",
+	"Content-Encoding",
+	xfer.ScopeProbeIDHeader,
+	xfer.ScopeProbeVersionHeader,
+	xfer.ScopeReportTimestampHeader,
+}
+
+// hashRing maps keys to shards by consistent hashing, so adding or removing
+// a shard only moves the keys of that shard.
+type hashRing struct {
+	hashes []uint32
+	shards map[uint32]int
+}
+
+func newHashRing(n int) hashRing {
+	ring := hashRing{shards: map[uint32]int{}}
+	for shard := 0; shard < n; shard++ {
+		for i := 0; i < virtualShards; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(shard) + "-" + strconv.Itoa(i)))
+			if _, ok := ring.shards[hash]; ok {
+				continue
+			}
+			ring.hashes = append(ring.hashes, hash)
+			ring.shards[hash] = shard
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+	return ring
+}
+
+// get returns the shard of a key.
+func (r hashRing) get(key string) int {
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= hash })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.shards[r.hashes[i]]
+}
+
+// ShardedCollector is a Collector in front of other apps, its shards. It
+// forwards the reports of every probe to one of the shards, by probe ID,
+// and merges the reports of all the shards for queries. Only reports are
+// sharded; controls and pipes are dealt with by the app in front.
+type ShardedCollector struct {
+	shards []url.URL
+	ring   hashRing
+	client *http.Client
+	merger Merger
+	waitableCondition
+
+	mtx      sync.Mutex
+	cached   *report.Report
+	cachedAt time.Time
+}
+
+// NewShardedCollector makes a ShardedCollector for the apps at the given
+// URLs. The order of the shards matters to which one gets which probes.
+func NewShardedCollector(shards []url.URL) (*ShardedCollector, error) {
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("no shards")
+	}
+	return &ShardedCollector{
+		shards: shards,
+		ring:   newHashRing(len(shards)),
+		client: &http.Client{Timeout: shardTimeout},
+		merger: NewSmartMerger(),
+		waitableCondition: waitableCondition{
+			waiters: map[chan struct{}]struct{}{},
+		},
+	}, nil
+}
+
+func (c *ShardedCollector) shardURL(shard int, path string, query url.Values) string {
+	u := c.shards[shard]
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// forward posts a report to the shard of its probe, returning the response
+// of the shard.
+func (c *ShardedCollector) forward(ctx context.Context, probeID string, header http.Header, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest("POST", c.shardURL(c.ring.get(probeID), "/api/report", nil), body)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range forwardedHeaders {
+		if v := header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	return c.client.Do(req.WithContext(ctx))
+}
+
+// Add implements Adder, forwarding the (gzip'd msgpack) report to the
+// shard of its probe.
+func (c *ShardedCollector) Add(ctx context.Context, rpt report.Report, buf []byte) error {
+	header := http.Header{}
+	if r, ok := ctx.Value(RequestCtxKey).(*http.Request); ok {
+		header.Set(xfer.ScopeProbeIDHeader, r.Header.Get(xfer.ScopeProbeIDHeader))
+		header.Set(xfer.ScopeProbeVersionHeader, r.Header.Get(xfer.ScopeProbeVersionHeader))
+	}
+	probeID := header.Get(xfer.ScopeProbeIDHeader)
+	if probeID == "" {
+		// e.g. reports ingested over gRPC
+		for _, n := range rpt.Host.Nodes {
+			probeID, _ = n.Latest.Lookup(report.ControlProbeID)
+			break
+		}
+	}
+	header.Set("Content-Type", "application/msgpack")
+	header.Set("Content-Encoding", "gzip")
+	resp, err := c.forward(ctx, probeID, header, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		text, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("shard: %s: %s", resp.Status, text)
+	}
+	return nil
+}
+
+// Report implements Reporter, merging the reports of all the shards at the
+// given time. Shards which fail are left out, unless they all do.
+func (c *ShardedCollector) Report(ctx context.Context, timestamp time.Time) (report.Report, error) {
+	latest := mtime.Now().Sub(timestamp) < shardCacheInterval
+	if latest {
+		c.mtx.Lock()
+		if c.cached != nil && mtime.Now().Sub(c.cachedAt) < shardCacheInterval {
+			defer c.mtx.Unlock()
+			return *c.cached, nil
+		}
+		c.mtx.Unlock()
+	}
+
+	var (
+		query   = url.Values{"timestamp": {timestamp.Format(time.RFC3339Nano)}}
+		reports = make([]report.Report, len(c.shards))
+		errs    = make([]error, len(c.shards))
+		wg      sync.WaitGroup
+	)
+	for i := range c.shards {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reports[i], errs[i] = c.shardReport(ctx, c.shardURL(i, "/api/report", query))
+		}(i)
+	}
+	wg.Wait()
+
+	var ok []report.Report
+	for i, err := range errs {
+		if err != nil {
+			log.Errorf("Error getting report of shard %s: %v", c.shards[i].String(), err)
+			continue
+		}
+		ok = append(ok, reports[i])
+	}
+	if len(ok) == 0 {
+		return report.MakeReport(), fmt.Errorf("no shard answered: %v", errs[0])
+	}
+	rpt := c.merger.Merge(ok)
+	if latest {
+		c.mtx.Lock()
+		c.cached, c.cachedAt = &rpt, mtime.Now()
+		c.mtx.Unlock()
+	}
+	return rpt, nil
+}
+
+func (c *ShardedCollector) shardReport(ctx context.Context, url string) (report.Report, error) {
+	var rpt report.Report
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return rpt, err
+	}
+	req.Header.Set("Accept", report.ProtobufContentType)
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return rpt, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return rpt, fmt.Errorf("%s", resp.Status)
+	}
+	err = rpt.ReadProtobuf(resp.Body, false)
+	return rpt, err
+}
+
+// HasReports implements Reporter; it tells whether any shard has reports.
+// Only the latest reports are checked, as the shards don't tell more.
+func (c *ShardedCollector) HasReports(ctx context.Context, timestamp time.Time) (bool, error) {
+	var lastErr error
+	for i := range c.shards {
+		var hasReports bool
+		if err := c.getJSON(ctx, c.shardURL(i, "/api/probes", url.Values{"sparse": {""}}), &hasReports); err != nil {
+			lastErr = err
+			continue
+		}
+		if hasReports {
+			return true, nil
+		}
+	}
+	return false, lastErr
+}
+
+// HasHistoricReports implements Reporter; it tells whether all the shards
+// keep a history.
+func (c *ShardedCollector) HasHistoricReports() bool {
+	for i := range c.shards {
+		var details xfer.Details
+		if err := c.getJSON(context.Background(), c.shardURL(i, "/api", nil), &details); err != nil {
+			log.Errorf("Error getting details of shard %s: %v", c.shards[i].String(), err)
+			return false
+		}
+		if !details.Capabilities[xfer.HistoricReportsCapability] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *ShardedCollector) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(v)
+}
+
+// RegisterShardedReportPostHandler registers the handler for report
+// submission of a ShardedCollector, forwarding reports to their shard as
+// they are, rather than decoding them first.
+func RegisterShardedReportPostHandler(c *ShardedCollector, router *mux.Router) {
+	post := router.Methods("POST").Subrouter()
+	post.HandleFunc("/api/report", requestContextDecorator(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		resp, err := c.forward(ctx, r.Header.Get(xfer.ScopeProbeIDHeader), r.Header, r.Body)
+		if err != nil {
+			log.Errorf("Error forwarding report: %v", err)
+			respondWith(w, http.StatusBadGateway, err)
+			return
+		}
+		defer resp.Body.Close()
+		if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+}
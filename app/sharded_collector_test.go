@@ -0,0 +1,111 @@
+package app_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestShardedCollector(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		collectors []app.Collector
+		shards     []url.URL
+	)
+	for i := 0; i < 3; i++ {
+		c := app.NewCollector(1 * time.Minute)
+		router := mux.NewRouter()
+		app.RegisterReportPostHandler(c, router)
+		app.RegisterTopologyRoutes(router, app.WebReporter{Reporter: c}, map[string]bool{})
+		ts := httptest.NewServer(router)
+		defer ts.Close()
+		u, err := url.Parse(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		collectors = append(collectors, c)
+		shards = append(shards, *u)
+	}
+
+	sharded, err := app.NewShardedCollector(shards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	app.RegisterShardedReportPostHandler(sharded, router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	if hasReports, err := sharded.HasReports(ctx, time.Now()); err != nil || hasReports {
+		t.Fatalf("Expected no reports: %v, %v", hasReports, err)
+	}
+
+	const probes = 20
+	post := func(probeID string) {
+		rpt := report.MakeReport()
+		rpt.Host.AddNode(report.MakeNodeWith(probeID+";<host>", map[string]string{
+			report.ControlProbeID: probeID,
+		}))
+		buf := &bytes.Buffer{}
+		if err := codec.NewEncoder(buf, &codec.JsonHandle{}).Encode(rpt); err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", ts.URL+"/api/report", buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(xfer.ScopeProbeIDHeader, probeID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Error posting report: %d", resp.StatusCode)
+		}
+	}
+	for i := 0; i < probes; i++ {
+		post(fmt.Sprintf("probe-%d", i))
+	}
+	// Probes stick to their shard.
+	post("probe-0")
+
+	total := 0
+	for i, c := range collectors {
+		rpt, err := c.Report(ctx, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rpt.Host.Nodes) == 0 {
+			t.Errorf("Shard %d got no reports", i)
+		}
+		total += len(rpt.Host.Nodes)
+	}
+	if total != probes {
+		t.Errorf("Expected %d hosts across the shards, got %d", probes, total)
+	}
+
+	rpt, err := sharded.Report(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rpt.Host.Nodes) != probes {
+		t.Errorf("Expected %d hosts in the merged report, got %d", probes, len(rpt.Host.Nodes))
+	}
+	if hasReports, err := sharded.HasReports(ctx, time.Now()); err != nil || !hasReports {
+		t.Errorf("Expected reports: %v, %v", hasReports, err)
+	}
+}
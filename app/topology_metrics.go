@@ -0,0 +1,108 @@
+package app
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/report"
+)
+
+var (
+	topologyNodesDesc = prometheus.NewDesc(
+		"scope_topology_nodes",
+		"Number of nodes of a rendered topology.",
+		[]string{"topology", "pseudo"}, nil,
+	)
+	topologyEdgesDesc = prometheus.NewDesc(
+		"scope_topology_edges",
+		"Number of edges of a rendered topology.",
+		[]string{"topology"}, nil,
+	)
+	containerCPUDesc = prometheus.NewDesc(
+		"scope_container_cpu_usage_percent",
+		"CPU usage of a container, as last reported by its probe.",
+		[]string{"container_id", "container_name", "host"}, nil,
+	)
+	containerMemoryDesc = prometheus.NewDesc(
+		"scope_container_memory_usage_bytes",
+		"Memory usage of a container, as last reported by its probe.",
+		[]string{"container_id", "container_name", "host"}, nil,
+	)
+)
+
+// TopologyMetrics is a prometheus.Collector exporting gauges about the
+// rendered topologies of a Reporter, so they can be alerted on. The
+// current report is rendered on every scrape.
+type TopologyMetrics struct {
+	reporter Reporter
+	registry *Registry
+}
+
+// NewTopologyMetrics makes a new TopologyMetrics for the topologies of
+// the default registry.
+func NewTopologyMetrics(rep Reporter) *TopologyMetrics {
+	return &TopologyMetrics{
+		reporter: rep,
+		registry: topologyRegistry,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *TopologyMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- topologyNodesDesc
+	ch <- topologyEdgesDesc
+	ch <- containerCPUDesc
+	ch <- containerMemoryDesc
+}
+
+// Collect implements prometheus.Collector.
+func (m *TopologyMetrics) Collect(ch chan<- prometheus.Metric) {
+	rpt, err := m.reporter.Report(context.Background(), time.Now())
+	if err != nil {
+		log.Errorf("Error getting report for topology metrics: %v", err)
+		return
+	}
+
+	var descs []APITopologyDesc
+	m.registry.walk(func(desc APITopologyDesc) {
+		descs = append(descs, desc)
+		descs = append(descs, desc.SubTopologies...)
+	})
+	for _, desc := range descs {
+		renderer, filter, err := m.registry.RendererForTopology(desc.id, nil, rpt)
+		if err != nil {
+			continue
+		}
+		stats := computeStats(rpt, renderer, filter)
+		ch <- prometheus.MustNewConstMetric(topologyNodesDesc, prometheus.GaugeValue, float64(stats.NonpseudoNodeCount), desc.id, "false")
+		ch <- prometheus.MustNewConstMetric(topologyNodesDesc, prometheus.GaugeValue, float64(stats.NodeCount-stats.NonpseudoNodeCount), desc.id, "true")
+		ch <- prometheus.MustNewConstMetric(topologyEdgesDesc, prometheus.GaugeValue, float64(stats.EdgeCount), desc.id)
+	}
+
+	for _, node := range rpt.Container.Nodes {
+		collectContainerMetric(ch, containerCPUDesc, node, docker.CPUTotalUsage)
+		collectContainerMetric(ch, containerMemoryDesc, node, docker.MemoryUsage)
+	}
+}
+
+func collectContainerMetric(ch chan<- prometheus.Metric, desc *prometheus.Desc, node report.Node, key string) {
+	metric, ok := node.Metrics[key]
+	if !ok {
+		return
+	}
+	sample, ok := metric.LastSample()
+	if !ok {
+		return
+	}
+	var (
+		id, _       = node.Latest.Lookup(docker.ContainerID)
+		name, _     = node.Latest.Lookup(docker.ContainerName)
+		hostNode, _ = node.Latest.Lookup(report.HostNodeID)
+		host, _     = report.ParseHostNodeID(hostNode)
+	)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, sample.Value, id, name, host)
+}
@@ -0,0 +1,46 @@
+package app_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestTopologyMetrics(t *testing.T) {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		app.NewTopologyMetrics(app.StaticCollector(fixture.Report)).Collect(ch)
+		close(ch)
+	}()
+
+	// The tests only need the name and labels of each metric.
+	have := map[string]float64{}
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		labels := []string{}
+		for _, l := range m.GetLabel() {
+			labels = append(labels, fmt.Sprintf("%s=%s", l.GetName(), l.GetValue()))
+		}
+		name := strings.SplitN(metric.Desc().String(), `"`, 3)[1]
+		have[name+"{"+strings.Join(labels, ",")+"}"] = m.GetGauge().GetValue()
+	}
+
+	for _, key := range []string{
+		"scope_topology_nodes{pseudo=false,topology=containers}",
+		"scope_topology_edges{topology=containers}",
+		"scope_container_cpu_usage_percent{container_id=" + fixture.ServerContainerID + ",container_name=" + fixture.ServerContainerName + ",host=" + fixture.ServerHostID + "}",
+	} {
+		if have[key] == 0 {
+			t.Errorf("missing %s in %v", key, have)
+		}
+	}
+}
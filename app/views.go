@@ -0,0 +1,262 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+)
+
+// View is a view of a topology users save under a name, to come back to
+// and share: the topology, its options (i.e. filters), a search query and
+// the nodes pinned in it.
+type View struct {
+	ID       string              `json:"id"`
+	Name     string              `json:"name"`
+	Topology string              `json:"topology"`
+	Options  map[string][]string `json:"options,omitempty"` // e.g. {"namespace": ["prod"]}, as the UI has them
+	Search   string              `json:"search,omitempty"`
+	Pinned   []string            `json:"pinned,omitempty"` // node IDs
+	User     string              `json:"user,omitempty"`
+	Updated  time.Time           `json:"updated"`
+	URL      string              `json:"url,omitempty"` // of the view in the UI, relative to the app
+}
+
+// uiURL is the URL of the view in the UI, which keeps its state in the
+// fragment, as JSON with slashes and percents replaced.
+func (v View) uiURL() string {
+	state := map[string]interface{}{"topologyId": v.Topology}
+	if len(v.Options) > 0 {
+		state["topologyOptions"] = map[string]map[string][]string{v.Topology: v.Options}
+	}
+	if v.Search != "" {
+		state["searchQuery"] = v.Search
+	}
+	if len(v.Pinned) > 0 {
+		state["pinnedNodes"] = v.Pinned
+	}
+	var (
+		buf []byte
+		h   codec.JsonHandle
+	)
+	h.Canonical = true // so views have the one URL
+	codec.NewEncoderBytes(&buf, &h).Encode(state)
+	encoded := strings.NewReplacer("%", "<PERCENT>", "/", "<SLASH>").Replace(string(buf))
+	return "/#!/state/" + url.PathEscape(encoded)
+}
+
+// ViewStore is somewhere saved views are kept across restarts of the app,
+// like an AnnotationStore.
+type ViewStore interface {
+	LoadViews(ctx context.Context) ([]View, error)
+	StoreViews(ctx context.Context, views []View) error
+}
+
+// Views are the views users have saved, by ID, kept in memory and, if
+// there is one, in a ViewStore.
+type Views struct {
+	store ViewStore
+
+	mtx   sync.Mutex // also serialises stores
+	views map[string]View
+}
+
+// NewViews makes Views, loading them from store, nil to only keep them in
+// memory.
+func NewViews(ctx context.Context, store ViewStore) (*Views, error) {
+	v := &Views{
+		store: store,
+		views: map[string]View{},
+	}
+	if store != nil {
+		loaded, err := store.LoadViews(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, view := range loaded {
+			v.views[view.ID] = view
+		}
+	}
+	return v, nil
+}
+
+// List returns all views, sorted by name.
+func (v *Views) List() []View {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	return v.list()
+}
+
+func (v *Views) list() []View {
+	result := make([]View, 0, len(v.views))
+	for _, view := range v.views {
+		result = append(result, view)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// Get returns a view.
+func (v *Views) Get(id string) (View, bool) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	view, ok := v.views[id]
+	return view, ok
+}
+
+// Set saves a view, replacing any of the same ID.
+func (v *Views) Set(ctx context.Context, view View) error {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	old, existed := v.views[view.ID]
+	v.views[view.ID] = view
+	if err := v.save(ctx); err != nil {
+		if existed {
+			v.views[view.ID] = old
+		} else {
+			delete(v.views, view.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete deletes a view, returning whether there was one.
+func (v *Views) Delete(ctx context.Context, id string) (bool, error) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	old, ok := v.views[id]
+	if !ok {
+		return false, nil
+	}
+	delete(v.views, id)
+	if err := v.save(ctx); err != nil {
+		v.views[id] = old
+		return false, err
+	}
+	return true, nil
+}
+
+// save stores all views. Called with v.mtx held.
+func (v *Views) save(ctx context.Context) error {
+	if v.store == nil {
+		return nil
+	}
+	return v.store.StoreViews(ctx, v.list())
+}
+
+// RegisterViewRoutes registers the routes to save views, and the
+// /views/{id} shortcut to share them, which redirects to the view in the
+// UI. View IDs are query-escaped, like node IDs.
+func RegisterViewRoutes(router *mux.Router, v *Views) {
+	router.
+		Methods("GET").
+		Path("/api/views").
+		HandlerFunc(requestContextDecorator(handleListViews(v)))
+	router.
+		Methods("GET").
+		MatcherFunc(URLMatcher("/api/views/{id}")).
+		HandlerFunc(requestContextDecorator(handleGetView(v)))
+	router.
+		Methods("PUT").
+		MatcherFunc(URLMatcher("/api/views/{id}")).
+		HandlerFunc(requestContextDecorator(handleSetView(v)))
+	router.
+		Methods("DELETE").
+		MatcherFunc(URLMatcher("/api/views/{id}")).
+		HandlerFunc(requestContextDecorator(handleDeleteView(v)))
+	router.
+		Methods("GET").
+		MatcherFunc(URLMatcher("/views/{id}")).
+		HandlerFunc(requestContextDecorator(handleOpenView(v)))
+}
+
+func handleListViews(v *Views) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		respondWith(w, http.StatusOK, v.List())
+	}
+}
+
+func handleGetView(v *Views) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		view, ok := v.Get(mux.Vars(r)["id"])
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		respondWith(w, http.StatusOK, view)
+	}
+}
+
+// handleSetView saves a view of a topology, noting who did, and when.
+func handleSetView(v *Views) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		var view View
+		err := codec.NewDecoder(r.Body, &codec.JsonHandle{}).Decode(&view)
+		defer r.Body.Close()
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		view.ID = mux.Vars(r)["id"]
+		if view.Name == "" {
+			view.Name = view.ID
+		}
+		if _, ok := topologyRegistry.get(view.Topology); !ok {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("topology not found: %q", view.Topology))
+			return
+		}
+		view.User = requestUser(r)
+		view.Updated = mtime.Now().UTC()
+		view.URL = view.uiURL()
+		if err := v.Set(ctx, view); err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		respondWith(w, http.StatusOK, view)
+	}
+}
+
+func handleDeleteView(v *Views) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		found, err := v.Delete(ctx, mux.Vars(r)["id"])
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleOpenView redirects to a view in the UI, relative to where the app
+// is, e.g. behind a proxy with a path prefix.
+func handleOpenView(v *Views) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		view, ok := v.Get(mux.Vars(r)["id"])
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		// Not http.Redirect, which makes the location absolute
+		w.Header().Set("Location", ".."+view.uiURL())
+		w.WriteHeader(http.StatusFound)
+	}
+}
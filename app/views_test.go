@@ -0,0 +1,104 @@
+package app_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/app"
+)
+
+type mockViewStore struct {
+	views []app.View
+	err   error
+}
+
+func (s *mockViewStore) LoadViews(context.Context) ([]app.View, error) {
+	return s.views, nil
+}
+
+func (s *mockViewStore) StoreViews(_ context.Context, views []app.View) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.views = views
+	return nil
+}
+
+func TestViews(t *testing.T) {
+	ctx := context.Background()
+	store := &mockViewStore{views: []app.View{{ID: "edge", Name: "Production edge services", Topology: "services"}}}
+	views, err := app.NewViews(ctx, store)
+	ok(t, err)
+	if _, found := views.Get("edge"); !found {
+		t.Fatal("want the stored views loaded")
+	}
+
+	ok(t, views.Set(ctx, app.View{ID: "db", Name: "Databases", Topology: "containers"}))
+	equals(t, []string{"db", "edge"}, []string{store.views[0].ID, store.views[1].ID})
+
+	// Views which can't be stored are not kept
+	store.err = fmt.Errorf("unavailable")
+	if err := views.Set(ctx, app.View{ID: "web", Topology: "pods"}); err == nil {
+		t.Error("want an error")
+	}
+	if found, err := views.Delete(ctx, "edge"); err == nil || found {
+		t.Error("want an error")
+	}
+	equals(t, 2, len(views.List()))
+}
+
+func TestAPIViews(t *testing.T) {
+	views, err := app.NewViews(context.Background(), nil)
+	ok(t, err)
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterViewRoutes(router, views)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	path := "/api/views/edge"
+	is404(t, ts, path)
+	res, body := checkRequest(t, ts, "PUT", path, []byte(`{"name": "Production edge services", "topology": "containers", "options": {"system": ["application"], "namespace": ["prod", "edge"]}, "search": "app:web", "pinned": ["a;<container>"]}`))
+	equals(t, http.StatusOK, res.StatusCode)
+
+	var view app.View
+	ok(t, codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&view))
+	equals(t, "edge", view.ID)
+	equals(t, []string{"prod", "edge"}, view.Options["namespace"])
+	if view.Updated.IsZero() {
+		t.Error("want when the view was saved")
+	}
+
+	var list []app.View
+	ok(t, codec.NewDecoderBytes(getRawJSON(t, ts, "/api/views"), &codec.JsonHandle{}).Decode(&list))
+	equals(t, 1, len(list))
+
+	// Views are shared by a URL redirecting to them in the UI
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	res, err = client.Get(ts.URL + "/views/edge")
+	ok(t, err)
+	res.Body.Close()
+	equals(t, http.StatusFound, res.StatusCode)
+	location := res.Header.Get("Location")
+	equals(t, ".."+view.URL, location)
+	state, err := url.PathUnescape(strings.TrimPrefix(view.URL, "/#!/state/"))
+	ok(t, err)
+	if !strings.Contains(state, `"topologyId":"containers"`) || !strings.Contains(state, `"searchQuery":"app:web"`) || strings.Contains(state, "/") {
+		t.Errorf("want the state of the view in the UI, have %s", state)
+	}
+
+	res, _ = checkRequest(t, ts, "PUT", "/api/views/bad", []byte(`{"topology": "nothing"}`))
+	equals(t, http.StatusBadRequest, res.StatusCode)
+	res, _ = checkRequest(t, ts, "DELETE", path, nil)
+	equals(t, http.StatusNoContent, res.StatusCode)
+	res, _ = checkRequest(t, ts, "DELETE", path, nil)
+	equals(t, http.StatusNotFound, res.StatusCode)
+	is404(t, ts, "/views/edge")
+}
@@ -394,7 +394,11 @@ export function doControlRequest(nodeId, control, dispatch) {
     success: (res) => {
       dispatch(receiveControlSuccess(nodeId));
       if (res) {
-        if (res.pipe) {
+        if (res.pipe && res.download) {
+          // The pipe streams a file (e.g. a packet capture), not a terminal
+          window.location.href = `${getApiPath()}/api/pipe/${encodeURIComponent(res.pipe)}/download`
+            + `?name=${encodeURIComponent(res.download)}`;
+        } else if (res.pipe) {
           dispatch(blurSearch());
           const resizeTtyControl = res.resize_tty_control &&
             {id: res.resize_tty_control, probeId: control.probeId, nodeId: control.nodeId};
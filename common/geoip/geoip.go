@@ -0,0 +1,227 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Names of the MaxMind GeoLite2 CSV files we know how to load
+const (
+	countryLocationsFile   = "GeoLite2-Country-Locations-en.csv"
+	countryBlocksIPv4File  = "GeoLite2-Country-Blocks-IPv4.csv"
+	countryBlocksIPv6File  = "GeoLite2-Country-Blocks-IPv6.csv"
+	asnBlocksIPv4File      = "GeoLite2-ASN-Blocks-IPv4.csv"
+	asnBlocksIPv6File      = "GeoLite2-ASN-Blocks-IPv6.csv"
+	networkColumn          = "network"
+	geonameIDColumn        = "geoname_id"
+	registeredCountryIDCol = "registered_country_geoname_id"
+	countryISOCodeColumn   = "country_iso_code"
+	countryNameColumn      = "country_name"
+	asNumberColumn         = "autonomous_system_number"
+	asOrganizationColumn   = "autonomous_system_organization"
+)
+
+// Info is what we know about where an address lives.
+type Info struct {
+	Country string
+	ASN     string
+}
+
+// Resolver looks up Info for addresses.
+type Resolver interface {
+	Lookup(net.IP) (Info, bool)
+}
+
+type network struct {
+	net   *net.IPNet
+	value string
+}
+
+// networks is a list of non-overlapping networks, sorted by their first address.
+type networks []network
+
+func (ns networks) Len() int           { return len(ns) }
+func (ns networks) Swap(i, j int)      { ns[i], ns[j] = ns[j], ns[i] }
+func (ns networks) Less(i, j int) bool { return bytes.Compare(ns[i].net.IP, ns[j].net.IP) < 0 }
+
+func (ns networks) lookup(ip net.IP) (string, bool) {
+	// Find the last network starting at or before ip
+	i := sort.Search(len(ns), func(i int) bool {
+		return bytes.Compare(ns[i].net.IP, ip) > 0
+	}) - 1
+	if i < 0 || !ns[i].net.Contains(ip) {
+		return "", false
+	}
+	return ns[i].value, true
+}
+
+// table keeps IPv4 and IPv6 networks apart, as their addresses don't
+// sort meaningfully against each other.
+type table struct {
+	v4, v6 networks
+}
+
+func (t *table) add(n *net.IPNet, value string) {
+	if v4 := n.IP.To4(); v4 != nil && len(n.Mask) == net.IPv4len {
+		t.v4 = append(t.v4, network{&net.IPNet{IP: v4, Mask: n.Mask}, value})
+		return
+	}
+	t.v6 = append(t.v6, network{n, value})
+}
+
+func (t *table) sort() {
+	sort.Sort(t.v4)
+	sort.Sort(t.v6)
+}
+
+func (t *table) lookup(ip net.IP) (string, bool) {
+	if v4 := ip.To4(); v4 != nil {
+		return t.v4.lookup(v4)
+	}
+	return t.v6.lookup(ip.To16())
+}
+
+// Database is a Resolver backed by in-memory tables of networks.
+type Database struct {
+	countries table
+	asns      table
+}
+
+// NewDatabase makes a new, empty Database.
+func NewDatabase() *Database {
+	return &Database{}
+}
+
+// AddCountry records the country for a network. Call Sort once done adding.
+func (d *Database) AddCountry(n *net.IPNet, country string) {
+	d.countries.add(n, country)
+}
+
+// AddASN records the autonomous system for a network. Call Sort once done adding.
+func (d *Database) AddASN(n *net.IPNet, asn string) {
+	d.asns.add(n, asn)
+}
+
+// Sort prepares the database for lookups.
+func (d *Database) Sort() {
+	d.countries.sort()
+	d.asns.sort()
+}
+
+// Lookup implements Resolver.
+func (d *Database) Lookup(ip net.IP) (Info, bool) {
+	country, hasCountry := d.countries.lookup(ip)
+	asn, hasASN := d.asns.lookup(ip)
+	return Info{Country: country, ASN: asn}, hasCountry || hasASN
+}
+
+// LoadGeoLite2CSV loads the MaxMind GeoLite2 Country and ASN CSV files
+// found in dir. Any of the files can be missing, but at least one of
+// the blocks files must be present.
+func LoadGeoLite2CSV(dir string) (*Database, error) {
+	var (
+		db        = NewDatabase()
+		countries = map[string]string{}
+		found     = false
+	)
+	if err := readCSV(filepath.Join(dir, countryLocationsFile), func(row map[string]string) error {
+		name := row[countryNameColumn]
+		if name == "" {
+			name = row[countryISOCodeColumn]
+		}
+		countries[row[geonameIDColumn]] = name
+		return nil
+	}); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, file := range []string{countryBlocksIPv4File, countryBlocksIPv6File} {
+		err := readCSV(filepath.Join(dir, file), func(row map[string]string) error {
+			_, n, err := net.ParseCIDR(row[networkColumn])
+			if err != nil {
+				return err
+			}
+			id := row[geonameIDColumn]
+			if id == "" {
+				id = row[registeredCountryIDCol]
+			}
+			if country, ok := countries[id]; ok && country != "" {
+				db.AddCountry(n, country)
+			}
+			return nil
+		})
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		found = true
+	}
+	for _, file := range []string{asnBlocksIPv4File, asnBlocksIPv6File} {
+		err := readCSV(filepath.Join(dir, file), func(row map[string]string) error {
+			_, n, err := net.ParseCIDR(row[networkColumn])
+			if err != nil {
+				return err
+			}
+			asn := "AS" + row[asNumberColumn]
+			if org := row[asOrganizationColumn]; org != "" {
+				asn = fmt.Sprintf("%s (%s)", asn, org)
+			}
+			db.AddASN(n, asn)
+			return nil
+		})
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("no GeoLite2 blocks files found in %s", dir)
+	}
+	db.Sort()
+	return db, nil
+}
+
+// readCSV calls f with every row of the CSV file at path, keyed by the
+// column names in its header.
+func readCSV(path string, f func(map[string]string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				row[name] = record[i]
+			}
+		}
+		if err := f(row); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package geoip_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/weaveworks/scope/common/geoip"
+)
+
+var geoLite2Files = map[string]string{
+	"GeoLite2-Country-Locations-en.csv": `geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name,is_in_european_union
+2635167,en,EU,Europe,GB,"United Kingdom",1
+6252001,en,NA,"North America",US,"United States",0
+`,
+	"GeoLite2-Country-Blocks-IPv4.csv": `network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider
+8.8.8.0/24,6252001,6252001,,0,0
+51.52.0.0/16,,2635167,,0,0
+`,
+	"GeoLite2-ASN-Blocks-IPv4.csv": `network,autonomous_system_number,autonomous_system_organization
+8.8.8.0/24,15169,"Google LLC"
+`,
+	"GeoLite2-ASN-Blocks-IPv6.csv": `network,autonomous_system_number,autonomous_system_organization
+2001:4860::/32,15169,"Google LLC"
+`,
+}
+
+func TestLoadGeoLite2CSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := geoip.LoadGeoLite2CSV(dir); err == nil {
+		t.Error("expected an error loading an empty directory")
+	}
+
+	for name, content := range geoLite2Files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, err := geoip.LoadGeoLite2CSV(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		ip   string
+		want geoip.Info
+		ok   bool
+	}{
+		{"8.8.8.8", geoip.Info{Country: "United States", ASN: "AS15169 (Google LLC)"}, true},
+		{"8.8.9.1", geoip.Info{}, false},
+		{"51.52.53.54", geoip.Info{Country: "United Kingdom"}, true},
+		{"2001:4860:4860::8888", geoip.Info{ASN: "AS15169 (Google LLC)"}, true},
+		{"10.0.0.1", geoip.Info{}, false},
+	} {
+		have, ok := db.Lookup(net.ParseIP(c.ip))
+		if ok != c.ok || have != c.want {
+			t.Errorf("%s: want %v (%v), have %v (%v)", c.ip, c.want, c.ok, have, ok)
+		}
+	}
+}
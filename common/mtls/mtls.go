@@ -0,0 +1,195 @@
+// Package mtls makes the TLS configurations of probes and apps
+// authenticating each other by certificates, optionally checking the
+// SPIFFE IDs of their peers.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// Config is where to find the certificates of one end.
+type Config struct {
+	CAFile   string // CA of the certificates of peers
+	CertFile string
+	KeyFile  string
+
+	// Optional; the SPIFFE ID peers must have, or, ending in a slash, the
+	// prefix of their IDs, e.g. spiffe://example.org/scope/
+	PeerSPIFFEID string
+}
+
+func (c Config) certificates() ([]tls.Certificate, error) {
+	if c.CertFile == "" && c.KeyFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return []tls.Certificate{cert}, nil
+}
+
+func (c Config) caPool() (*x509.CertPool, error) {
+	if c.CAFile == "" {
+		return nil, nil
+	}
+	pem, err := ioutil.ReadFile(c.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", c.CAFile)
+	}
+	return pool, nil
+}
+
+// Client makes the TLS configuration of clients of the given server,
+// trusting the given roots unless the Config has a CA. Servers with a
+// SPIFFE ID to check are verified by it rather than by name.
+func (c Config) Client(serverName string, roots *x509.CertPool) (*tls.Config, error) {
+	certs, err := c.certificates()
+	if err != nil {
+		return nil, err
+	}
+	pool, err := c.caPool()
+	if err != nil {
+		return nil, err
+	}
+	if pool == nil {
+		pool = roots
+	}
+	config := &tls.Config{
+		Certificates: certs,
+		RootCAs:      pool,
+		ServerName:   serverName,
+	}
+	if c.PeerSPIFFEID != "" {
+		// SPIFFE certificates have no DNS names to verify, so we verify
+		// the chain ourselves.
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("no server certificate")
+			}
+			leaf, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			intermediates := x509.NewCertPool()
+			for _, raw := range rawCerts[1:] {
+				cert, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				intermediates.AddCert(cert)
+			}
+			if _, err := leaf.Verify(x509.VerifyOptions{
+				Roots:         pool,
+				Intermediates: intermediates,
+				KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			}); err != nil {
+				return err
+			}
+			return c.checkSPIFFEID(leaf)
+		}
+	}
+	return config, nil
+}
+
+// Server makes the TLS configuration of servers. With a CA, clients giving
+// certificates must have ones it signed, and which have the SPIFFE ID to
+// check, if any. Certificates are required only if required is.
+func (c Config) Server(required bool) (*tls.Config, error) {
+	certs, err := c.certificates()
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("certificate and key required")
+	}
+	pool, err := c.caPool()
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: certs}
+	if pool == nil {
+		return config, nil
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	if required {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if c.PeerSPIFFEID != "" {
+		config.VerifyPeerCertificate = func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if len(verifiedChains) == 0 {
+				return nil // no certificate given
+			}
+			return c.checkSPIFFEID(verifiedChains[0][0])
+		}
+	}
+	return config, nil
+}
+
+// checkSPIFFEID checks the certificate of a peer has the expected SPIFFE
+// ID, as its URI SAN.
+func (c Config) checkSPIFFEID(cert *x509.Certificate) error {
+	uris, err := uriSANs(cert)
+	if err != nil {
+		return err
+	}
+	for _, uri := range uris {
+		if uri.Scheme != "spiffe" {
+			continue
+		}
+		id := uri.String()
+		if id == c.PeerSPIFFEID || (strings.HasSuffix(c.PeerSPIFFEID, "/") && strings.HasPrefix(id, c.PeerSPIFFEID)) {
+			return nil
+		}
+		return fmt.Errorf("unexpected SPIFFE ID %s", id)
+	}
+	return fmt.Errorf("no SPIFFE ID in certificate of %s", cert.Subject)
+}
+
+var oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+
+// uriSANs returns the URI SANs of a certificate, parsing its
+// subjectAltName extension, as crypto/x509 only does since Go 1.10.
+func uriSANs(cert *x509.Certificate) ([]*url.URL, error) {
+	const tagURI = 6 // of GeneralName, RFC 5280 4.2.1.6
+	var uris []*url.URL
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSubjectAltName) {
+			continue
+		}
+		var names asn1.RawValue
+		if rest, err := asn1.Unmarshal(ext.Value, &names); err != nil {
+			return nil, err
+		} else if len(rest) != 0 || !names.IsCompound || names.Class != asn1.ClassUniversal || names.Tag != asn1.TagSequence {
+			return nil, fmt.Errorf("malformed subject alternative names in certificate of %s", cert.Subject)
+		}
+		for rest := names.Bytes; len(rest) > 0; {
+			var name asn1.RawValue
+			var err error
+			if rest, err = asn1.Unmarshal(rest, &name); err != nil {
+				return nil, err
+			}
+			if name.Class != asn1.ClassContextSpecific || name.Tag != tagURI {
+				continue
+			}
+			uri, err := url.Parse(string(name.Bytes))
+			if err != nil {
+				return nil, fmt.Errorf("malformed URI SAN %q in certificate of %s", name.Bytes, cert.Subject)
+			}
+			uris = append(uris, uri)
+		}
+	}
+	return uris, nil
+}
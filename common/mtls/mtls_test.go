@@ -0,0 +1,160 @@
+package mtls_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/common/mtls"
+)
+
+type certs struct {
+	t   *testing.T
+	dir string
+	ca  *x509.Certificate
+	key *ecdsa.PrivateKey
+}
+
+func (c *certs) write(name string, typ string, der []byte) string {
+	path := filepath.Join(c.dir, name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		c.t.Fatal(err)
+	}
+	return path
+}
+
+// issue makes a certificate with the given SPIFFE ID, returning the paths
+// of it and its key.
+func (c *certs) issue(name, spiffeID string, usage x509.ExtKeyUsage) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	// The URI SAN, and the IP SAN of the test servers, by hand, as
+	// crypto/x509 only writes URI SANs since Go 1.10
+	san, err := asn1.Marshal([]asn1.RawValue{
+		{Class: asn1.ClassContextSpecific, Tag: 6, Bytes: []byte(spiffeID)},
+		{Class: asn1.ClassContextSpecific, Tag: 7, Bytes: net.ParseIP("127.0.0.1").To4()},
+	})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{2, 5, 29, 17}, Value: san},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, c.ca, &key.PublicKey, c.key)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return c.write(name+".pem", "CERTIFICATE", der), c.write(name+"-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func newCerts(t *testing.T) (*certs, string) {
+	dir, err := ioutil.TempDir("", "mtls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &certs{t: t, dir: dir, ca: ca, key: key}
+	return c, c.write("ca.pem", "CERTIFICATE", der)
+}
+
+func TestMutualTLS(t *testing.T) {
+	c, caFile := newCerts(t)
+	defer os.RemoveAll(c.dir)
+	appCert, appKey := c.issue("app", "spiffe://example.org/scope/app", x509.ExtKeyUsageServerAuth)
+	probeCert, probeKey := c.issue("probe", "spiffe://example.org/scope/probe", x509.ExtKeyUsageClientAuth)
+	otherCert, otherKey := c.issue("other", "spiffe://example.org/other", x509.ExtKeyUsageClientAuth)
+
+	serverConfig, err := mtls.Config{
+		CAFile:       caFile,
+		CertFile:     appCert,
+		KeyFile:      appKey,
+		PeerSPIFFEID: "spiffe://example.org/scope/",
+	}.Server(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = serverConfig
+	ts.StartTLS()
+	defer ts.Close()
+
+	get := func(config mtls.Config) error {
+		clientConfig, err := config.Client("127.0.0.1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	probe := mtls.Config{CAFile: caFile, CertFile: probeCert, KeyFile: probeKey}
+	if err := get(probe); err != nil {
+		t.Errorf("Expected the probe to get through: %v", err)
+	}
+	probe.PeerSPIFFEID = "spiffe://example.org/scope/app"
+	if err := get(probe); err != nil {
+		t.Errorf("Expected the probe to get through with verified app: %v", err)
+	}
+	probe.PeerSPIFFEID = "spiffe://example.org/scope/other-app"
+	if err := get(probe); err == nil {
+		t.Errorf("Expected an app of another SPIFFE ID to be rejected")
+	}
+	if err := get(mtls.Config{CAFile: caFile}); err == nil {
+		t.Errorf("Expected no client certificate to be rejected")
+	}
+	if err := get(mtls.Config{CAFile: caFile, CertFile: otherCert, KeyFile: otherKey}); err == nil {
+		t.Errorf("Expected a client of another SPIFFE ID to be rejected")
+	}
+}
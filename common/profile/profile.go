@@ -0,0 +1,58 @@
+// Package profile captures profiles of the process, as go tool pprof reads
+// them, to diagnose the performance of the app and probes where they run.
+package profile
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Kinds of profiles
+const (
+	CPU  = "cpu"
+	Heap = "heap"
+)
+
+const (
+	// DefaultDuration is how long CPU profiles are of, unless told.
+	DefaultDuration = 30 * time.Second
+	// MaxDuration is how long CPU profiles can be of.
+	MaxDuration = 5 * time.Minute
+)
+
+// Write writes a profile of a kind to w: of the CPU over duration, until
+// ctx is done, or of the heap as of now.
+func Write(ctx context.Context, w io.Writer, kind string, duration time.Duration) error {
+	switch kind {
+	case CPU:
+		if duration <= 0 || duration > MaxDuration {
+			return fmt.Errorf("duration must be between 1 and %d seconds", int(MaxDuration.Seconds()))
+		}
+		// This fails if a CPU profile is already being taken.
+		if err := pprof.StartCPUProfile(w); err != nil {
+			return err
+		}
+		select {
+		case <-time.After(duration):
+		case <-ctx.Done():
+		}
+		pprof.StopCPUProfile()
+		return nil
+	case Heap:
+		// So the profile is of the live objects as of now.
+		runtime.GC()
+		return pprof.WriteHeapProfile(w)
+	}
+	return fmt.Errorf("unknown profile: %q", kind)
+}
+
+// Filename is the name to download a profile of a component (e.g. "app")
+// taken at t as.
+func Filename(component, kind string, t time.Time) string {
+	return fmt.Sprintf("scope-%s-%s-%s.pprof", component, kind, t.UTC().Format("20060102T150405Z"))
+}
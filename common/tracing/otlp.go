@@ -0,0 +1,528 @@
+// Package tracing exports OpenTracing spans to OpenTelemetry collectors,
+// over OTLP/HTTP, propagating them with W3C trace context headers.
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+const (
+	tracesPath      = "/v1/traces"
+	traceparent     = "traceparent"
+	flushInterval   = 5 * time.Second
+	maxBatchSize    = 512
+	maxQueuedSpans  = 4096
+	exportTimeout   = 10 * time.Second
+	errorStatusCode = 2
+)
+
+// Tracer is an opentracing.Tracer exporting finished spans, in batches, to
+// the OTLP/HTTP endpoint of an OpenTelemetry collector. Spans are dropped
+// when the collector can't keep up.
+type Tracer struct {
+	url     string
+	service string
+	client  *http.Client
+
+	spans chan *span
+	quit  chan struct{}
+	done  sync.WaitGroup
+}
+
+// NewOTLPTracer makes a Tracer exporting to the given endpoint, e.g.
+// http://otel-collector:4318, on behalf of the given service.
+func NewOTLPTracer(endpoint, service string) (*Tracer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: not http(s)", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = tracesPath
+	}
+	t := &Tracer{
+		url:     u.String(),
+		service: service,
+		client:  &http.Client{Timeout: exportTimeout},
+		spans:   make(chan *span, maxQueuedSpans),
+		quit:    make(chan struct{}),
+	}
+	t.done.Add(1)
+	go t.loop()
+	return t, nil
+}
+
+// Close exports the spans still queued and stops the Tracer.
+func (t *Tracer) Close() error {
+	close(t.quit)
+	t.done.Wait()
+	return nil
+}
+
+// StartSpan implements opentracing.Tracer.
+func (t *Tracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	var options opentracing.StartSpanOptions
+	for _, opt := range opts {
+		opt.Apply(&options)
+	}
+	s := &span{
+		tracer: t,
+		name:   operationName,
+		start:  options.StartTime,
+		tags:   map[string]interface{}{},
+	}
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+	for k, v := range options.Tags {
+		s.tags[k] = v
+	}
+	for _, ref := range options.References {
+		parent, ok := ref.ReferencedContext.(spanContext)
+		if !ok {
+			continue
+		}
+		s.context.traceID = parent.traceID
+		s.parentID = parent.spanID
+		s.context.baggage = copyBaggage(parent.baggage)
+		break
+	}
+	if s.context.traceID == ([16]byte{}) {
+		rand.Read(s.context.traceID[:])
+	}
+	rand.Read(s.context.spanID[:])
+	return s
+}
+
+// Inject implements opentracing.Tracer, for the TextMap and HTTPHeaders
+// formats.
+func (t *Tracer) Inject(sc opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	c, ok := sc.(spanContext)
+	if !ok {
+		return opentracing.ErrInvalidSpanContext
+	}
+	if format != opentracing.TextMap && format != opentracing.HTTPHeaders {
+		return opentracing.ErrUnsupportedFormat
+	}
+	writer, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	writer.Set(traceparent, fmt.Sprintf("00-%x-%x-01", c.traceID, c.spanID))
+	return nil
+}
+
+// Extract implements opentracing.Tracer, for the TextMap and HTTPHeaders
+// formats.
+func (t *Tracer) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	if format != opentracing.TextMap && format != opentracing.HTTPHeaders {
+		return nil, opentracing.ErrUnsupportedFormat
+	}
+	reader, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+	var header string
+	if err := reader.ForeachKey(func(key, val string) error {
+		if strings.ToLower(key) == traceparent {
+			header = val
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if header == "" {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	c, err := parseTraceparent(header)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// parseTraceparent parses a W3C traceparent header, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func parseTraceparent(header string) (spanContext, error) {
+	var c spanContext
+	parts := strings.Split(header, "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return c, opentracing.ErrSpanContextCorrupted
+	}
+	if _, err := hex.Decode(c.traceID[:], []byte(parts[1])); err != nil {
+		return c, opentracing.ErrSpanContextCorrupted
+	}
+	if _, err := hex.Decode(c.spanID[:], []byte(parts[2])); err != nil {
+		return c, opentracing.ErrSpanContextCorrupted
+	}
+	return c, nil
+}
+
+func (t *Tracer) finished(s *span) {
+	select {
+	case t.spans <- s:
+	default:
+		// Not worth blocking the traced code for.
+	}
+}
+
+func (t *Tracer) loop() {
+	defer t.done.Done()
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	var batch []*span
+	for {
+		select {
+		case s := <-t.spans:
+			batch = append(batch, s)
+			if len(batch) < maxBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		case <-t.quit:
+			for len(t.spans) > 0 {
+				batch = append(batch, <-t.spans)
+			}
+			t.export(batch)
+			return
+		}
+		t.export(batch)
+		batch = nil
+	}
+}
+
+func (t *Tracer) export(batch []*span) {
+	if len(batch) == 0 {
+		return
+	}
+	buf, err := json.Marshal(t.request(batch))
+	if err != nil {
+		log.Errorf("Error encoding spans: %v", err)
+		return
+	}
+	resp, err := t.client.Post(t.url, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		log.Warnf("Error exporting %d spans: %v", len(batch), err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Warnf("Error exporting %d spans: %s", len(batch), resp.Status)
+	}
+}
+
+// The OTLP/HTTP JSON encoding of spans, such as we use of it.
+type (
+	exportRequest struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	resource struct {
+		Attributes []keyValue `json:"attributes"`
+	}
+	scopeSpans struct {
+		Scope scope      `json:"scope"`
+		Spans []wireSpan `json:"spans"`
+	}
+	scope struct {
+		Name string `json:"name"`
+	}
+	wireSpan struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              int        `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		Events            []event    `json:"events,omitempty"`
+		Status            *status    `json:"status,omitempty"`
+	}
+	event struct {
+		TimeUnixNano string     `json:"timeUnixNano"`
+		Name         string     `json:"name"`
+		Attributes   []keyValue `json:"attributes,omitempty"`
+	}
+	status struct {
+		Code int `json:"code"`
+	}
+	keyValue struct {
+		Key   string   `json:"key"`
+		Value anyValue `json:"value"`
+	}
+	anyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+var spanKinds = map[interface{}]int{
+	ext.SpanKindRPCServerEnum: 2,
+	ext.SpanKindRPCClientEnum: 3,
+	"server":                  2,
+	"client":                  3,
+	"producer":                4,
+	"consumer":                5,
+}
+
+func (t *Tracer) request(batch []*span) exportRequest {
+	spans := make([]wireSpan, 0, len(batch))
+	for _, s := range batch {
+		spans = append(spans, s.toWire())
+	}
+	return exportRequest{
+		ResourceSpans: []resourceSpans{{
+			Resource: resource{Attributes: []keyValue{attribute("service.name", t.service)}},
+			ScopeSpans: []scopeSpans{{
+				Scope: scope{Name: "github.com/weaveworks/scope"},
+				Spans: spans,
+			}},
+		}},
+	}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func attribute(key string, value interface{}) keyValue {
+	var v anyValue
+	switch value := value.(type) {
+	case string:
+		v.StringValue = &value
+	case bool:
+		v.BoolValue = &value
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		i := fmt.Sprint(value)
+		v.IntValue = &i
+	case float32:
+		f := float64(value)
+		v.DoubleValue = &f
+	case float64:
+		v.DoubleValue = &value
+	default:
+		s := fmt.Sprint(value)
+		v.StringValue = &s
+	}
+	return keyValue{Key: key, Value: v}
+}
+
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+	baggage map[string]string
+}
+
+// ForeachBaggageItem implements opentracing.SpanContext.
+func (c spanContext) ForeachBaggageItem(handler func(k, v string) bool) {
+	for k, v := range c.baggage {
+		if !handler(k, v) {
+			return
+		}
+	}
+}
+
+func copyBaggage(baggage map[string]string) map[string]string {
+	if len(baggage) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(baggage))
+	for k, v := range baggage {
+		result[k] = v
+	}
+	return result
+}
+
+type span struct {
+	tracer   *Tracer
+	parentID [8]byte
+
+	mtx     sync.Mutex
+	context spanContext
+	name    string
+	start   time.Time
+	end     time.Time
+	tags    map[string]interface{}
+	logs    []opentracing.LogRecord
+}
+
+func (s *span) Finish() {
+	s.FinishWithOptions(opentracing.FinishOptions{})
+}
+
+func (s *span) FinishWithOptions(opts opentracing.FinishOptions) {
+	s.mtx.Lock()
+	s.end = opts.FinishTime
+	if s.end.IsZero() {
+		s.end = time.Now()
+	}
+	s.logs = append(s.logs, opts.LogRecords...)
+	for _, ld := range opts.BulkLogData {
+		s.logs = append(s.logs, ld.ToLogRecord())
+	}
+	s.mtx.Unlock()
+	s.tracer.finished(s)
+}
+
+func (s *span) Context() opentracing.SpanContext {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	c := s.context
+	c.baggage = copyBaggage(c.baggage)
+	return c
+}
+
+func (s *span) SetOperationName(operationName string) opentracing.Span {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.name = operationName
+	return s
+}
+
+func (s *span) SetTag(key string, value interface{}) opentracing.Span {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.tags[key] = value
+	return s
+}
+
+func (s *span) LogFields(fields ...otlog.Field) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.logs = append(s.logs, opentracing.LogRecord{Timestamp: time.Now(), Fields: fields})
+}
+
+func (s *span) LogKV(alternatingKeyValues ...interface{}) {
+	fields, err := otlog.InterleavedKVToFields(alternatingKeyValues...)
+	if err != nil {
+		fields = []otlog.Field{otlog.Error(err)}
+	}
+	s.LogFields(fields...)
+}
+
+func (s *span) SetBaggageItem(key, value string) opentracing.Span {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	baggage := copyBaggage(s.context.baggage)
+	if baggage == nil {
+		baggage = map[string]string{}
+	}
+	baggage[key] = value
+	s.context.baggage = baggage
+	return s
+}
+
+func (s *span) BaggageItem(key string) string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.context.baggage[key]
+}
+
+func (s *span) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *span) LogEvent(event string) {
+	s.LogFields(otlog.String("event", event))
+}
+
+func (s *span) LogEventWithPayload(event string, payload interface{}) {
+	s.LogFields(otlog.String("event", event), otlog.Object("payload", payload))
+}
+
+func (s *span) Log(ld opentracing.LogData) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.logs = append(s.logs, ld.ToLogRecord())
+}
+
+// fieldEncoder collects the fields of a log record as attributes.
+type fieldEncoder struct {
+	event      string
+	attributes []keyValue
+}
+
+func (e *fieldEncoder) add(key string, value interface{}) {
+	if key == "event" {
+		e.event = fmt.Sprint(value)
+		return
+	}
+	e.attributes = append(e.attributes, attribute(key, value))
+}
+
+func (e *fieldEncoder) EmitString(key, value string)             { e.add(key, value) }
+func (e *fieldEncoder) EmitBool(key string, value bool)          { e.add(key, value) }
+func (e *fieldEncoder) EmitInt(key string, value int)            { e.add(key, value) }
+func (e *fieldEncoder) EmitInt32(key string, value int32)        { e.add(key, value) }
+func (e *fieldEncoder) EmitInt64(key string, value int64)        { e.add(key, value) }
+func (e *fieldEncoder) EmitUint32(key string, value uint32)      { e.add(key, value) }
+func (e *fieldEncoder) EmitUint64(key string, value uint64)      { e.add(key, value) }
+func (e *fieldEncoder) EmitFloat32(key string, value float32)    { e.add(key, value) }
+func (e *fieldEncoder) EmitFloat64(key string, value float64)    { e.add(key, value) }
+func (e *fieldEncoder) EmitObject(key string, value interface{}) { e.add(key, value) }
+func (e *fieldEncoder) EmitLazyLogger(value otlog.LazyLogger)    { value(e) }
+
+func (s *span) toWire() wireSpan {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	w := wireSpan{
+		TraceID:           hex.EncodeToString(s.context.traceID[:]),
+		SpanID:            hex.EncodeToString(s.context.spanID[:]),
+		Name:              s.name,
+		Kind:              1, // internal
+		StartTimeUnixNano: unixNano(s.start),
+		EndTimeUnixNano:   unixNano(s.end),
+	}
+	if s.parentID != ([8]byte{}) {
+		w.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	for k, v := range s.tags {
+		switch k {
+		case string(ext.SpanKind):
+			if kind, ok := spanKinds[v]; ok {
+				w.Kind = kind
+			}
+		case string(ext.Error):
+			if failed, ok := v.(bool); ok && failed {
+				w.Status = &status{Code: errorStatusCode}
+			}
+		default:
+			w.Attributes = append(w.Attributes, attribute(k, v))
+		}
+	}
+	for _, record := range s.logs {
+		e := fieldEncoder{event: "log"}
+		for _, field := range record.Fields {
+			field.Marshal(&e)
+		}
+		w.Events = append(w.Events, event{
+			TimeUnixNano: unixNano(record.Timestamp),
+			Name:         e.event,
+			Attributes:   e.attributes,
+		})
+	}
+	return w
+}
@@ -0,0 +1,100 @@
+package tracing_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	"github.com/weaveworks/scope/common/tracing"
+)
+
+type exported struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []struct {
+				Key   string `json:"key"`
+				Value struct {
+					StringValue string `json:"stringValue"`
+				} `json:"value"`
+			} `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceID      string `json:"traceId"`
+				SpanID       string `json:"spanId"`
+				ParentSpanID string `json:"parentSpanId"`
+				Name         string `json:"name"`
+				Kind         int    `json:"kind"`
+				Status       *struct {
+					Code int `json:"code"`
+				} `json:"status"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+func TestOTLPTracer(t *testing.T) {
+	requests := make(chan exported, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		var req exported
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		requests <- req
+	}))
+	defer ts.Close()
+
+	tracer, err := tracing.NewOTLPTracer(ts.URL, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A client span, propagated to a server span over HTTP headers.
+	client := tracer.StartSpan("client")
+	header := http.Header{}
+	if err := tracer.Inject(client.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header)); err != nil {
+		t.Fatal(err)
+	}
+	parent, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := tracer.StartSpan("server", ext.RPCServerOption(parent))
+	ext.Error.Set(server, true)
+	server.Finish()
+	client.Finish()
+
+	if _, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(http.Header{})); err != opentracing.ErrSpanContextNotFound {
+		t.Errorf("Expected no span context, got %v", err)
+	}
+
+	tracer.Close()
+	req := <-requests
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Unexpected request: %+v", req)
+	}
+	if attrs := req.ResourceSpans[0].Resource.Attributes; len(attrs) != 1 || attrs[0].Key != "service.name" || attrs[0].Value.StringValue != "test" {
+		t.Errorf("Unexpected resource: %+v", attrs)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	s, c := spans[0], spans[1]
+	if s.Name != "server" || c.Name != "client" {
+		t.Fatalf("Unexpected spans: %+v", spans)
+	}
+	if s.TraceID != c.TraceID || s.ParentSpanID != c.SpanID || c.ParentSpanID != "" {
+		t.Errorf("Server span not a child of the client one: %+v", spans)
+	}
+	if s.Kind != 2 || s.Status == nil || s.Status.Code != 2 {
+		t.Errorf("Unexpected server span: %+v", s)
+	}
+}
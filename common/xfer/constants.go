@@ -12,12 +12,24 @@ const (
 
 	// ScopeProbeVersionHeader is the header we use to carry the probe's version.
 	ScopeProbeVersionHeader = "X-Scope-Probe-Version"
+
+	// ScopeReportTimestampHeader is the header carrying when a report was
+	// published, for reports replayed later, in RFC3339 format.
+	ScopeReportTimestampHeader = "X-Scope-Report-Timestamp"
 )
 
 // HistoricReportsCapability indicates whether reports older than the
 // current time (-app.window) can be retrieved.
 const HistoricReportsCapability = "historic_reports"
 
+// ReportDeltasCapability indicates whether probes may publish reports as
+// deltas against the last full report they published.
+const ReportDeltasCapability = "report_deltas"
+
+// LayoutsCapability indicates whether topology websockets can be asked
+// for the positions of nodes, laid out by the app.
+const LayoutsCapability = "layouts"
+
 // Details are some generic details that can be fetched from /api
 type Details struct {
 	ID           string          `json:"id"`
@@ -26,6 +38,10 @@ type Details struct {
 	Plugins      PluginSpecs     `json:"plugins,omitempty"`
 	Capabilities map[string]bool `json:"capabilities,omitempty"`
 
+	// Encodings are the content codings the app accepts reports in, from
+	// the Accept-Encoding header of its response rather than the body.
+	Encodings []string `json:"-"`
+
 	NewVersion *NewVersionInfo `json:"newVersion,omitempty"`
 }
 
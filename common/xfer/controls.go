@@ -16,6 +16,7 @@ type Request struct {
 	NodeID      string
 	Control     string
 	ControlArgs map[string]string
+	Trace       map[string]string `json:",omitempty"` // context of the span of the request, if traced
 }
 
 // Response is the Probe -> App -> UI message type for the control RPCs.
@@ -27,6 +28,9 @@ type Response struct {
 	Pipe             string `json:"pipe,omitempty"`
 	RawTTY           bool   `json:"raw_tty,omitempty"`
 	ResizeTTYControl string `json:"resize_tty_control,omitempty"`
+	// Set if the pipe streams a file of this name to download, from
+	// /api/pipe/{pipe}/download, rather than a terminal
+	Download string `json:"download,omitempty"`
 
 	// Remove specific fields
 	RemovedNode string `json:"removedNode,omitempty"` // Set if node was removed
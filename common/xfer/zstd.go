@@ -0,0 +1,81 @@
+package xfer
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ugorji/go/codec"
+)
+
+// ZstdEncoding is the content coding of zstd compressed payloads, as used
+// in Content-Encoding and Accept-Encoding headers. Apps list the codings
+// they accept reports in with an Accept-Encoding header on /api.
+const ZstdEncoding = "zstd"
+
+// ZstdWebsocketProtocol is the websocket subprotocol (Sec-WebSocket-Protocol)
+// clients ask for to get the JSON messages of a websocket zstd compressed,
+// as binary messages.
+const ZstdWebsocketProtocol = "zstd.scope.weave.works"
+
+// AcceptedEncodings returns the content codings listed by the
+// Accept-Encoding header, without their q-values.
+func AcceptedEncodings(header http.Header) []string {
+	var codings []string
+	for _, value := range header["Accept-Encoding"] {
+		for _, coding := range strings.Split(value, ",") {
+			if i := strings.Index(coding, ";"); i >= 0 {
+				coding = coding[:i]
+			}
+			if coding = strings.TrimSpace(coding); coding != "" {
+				codings = append(codings, coding)
+			}
+		}
+	}
+	return codings
+}
+
+// UpgradeJSON upgrades the HTTP server connection to the WebSocket protocol,
+// for a websocket the server writes JSON messages to. They are zstd
+// compressed if the client asked for the ZstdWebsocketProtocol.
+func UpgradeJSON(w http.ResponseWriter, r *http.Request) (Websocket, error) {
+	for _, protocol := range websocket.Subprotocols(r) {
+		if protocol != ZstdWebsocketProtocol {
+			continue
+		}
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := Upgrade(w, r, http.Header{"Sec-Websocket-Protocol": {ZstdWebsocketProtocol}})
+		if err != nil {
+			encoder.Close()
+			return nil, err
+		}
+		return &zstdWebsocket{Websocket: conn, encoder: encoder}, nil
+	}
+	return Upgrade(w, r, nil)
+}
+
+type zstdWebsocket struct {
+	Websocket
+	encoder *zstd.Encoder
+}
+
+// WriteJSON writes the zstd compressed JSON encoding of v to the connection,
+// as a binary message.
+func (z *zstdWebsocket) WriteJSON(v interface{}) error {
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, &codec.JsonHandle{}).Encode(v); err != nil {
+		return err
+	}
+	return z.WriteMessage(websocket.BinaryMessage, z.encoder.EncodeAll(buf.Bytes(), nil))
+}
+
+// Close closes the connection
+func (z *zstdWebsocket) Close() error {
+	z.encoder.Close()
+	return z.Websocket.Close()
+}
@@ -1,6 +1,7 @@
 package appclient
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -17,6 +18,7 @@ import (
 	"github.com/ugorji/go/codec"
 
 	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
 )
 
 const (
@@ -32,6 +34,9 @@ type AppClient interface {
 	PipeConnection(string, xfer.Pipe)
 	PipeClose(string) error
 	Publish(io.Reader, bool) error
+	Replay(io.Reader) error
+	LookupDNS([]string) (report.DNSRecords, error)
+	PublishDNS(report.DNSRecords) error
 	Target() url.URL
 	ReTarget(url.URL)
 	Stop()
@@ -65,7 +70,10 @@ type appClient struct {
 
 // NewAppClient makes a new appClient.
 func NewAppClient(pc ProbeConfig, hostname string, target url.URL, control xfer.ControlHandler) (AppClient, error) {
-	httpTransport := pc.getHTTPTransport(hostname)
+	httpTransport, err := pc.getHTTPTransport(hostname)
+	if err != nil {
+		return nil, err
+	}
 	httpClient := cleanhttp.DefaultClient()
 	httpClient.Transport = httpTransport
 	httpClient.Timeout = httpClientTimeout
@@ -195,6 +203,7 @@ func (c *appClient) Details() (xfer.Details, error) {
 	if err := codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(&result); err != nil {
 		return result, err
 	}
+	result.Encodings = xfer.AcceptedEncodings(resp.Header)
 	c.appID = result.ID
 	return result, nil
 }
@@ -286,8 +295,16 @@ func (c *appClient) publish(r io.Reader) error {
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Content-Encoding", "gzip")
-	req.Header.Set("Content-Type", "application/msgpack")
+	if er, ok := r.(encodedReport); ok {
+		req.Header.Set("Content-Encoding", er.contentEncoding)
+		req.Header.Set("Content-Type", er.contentType)
+		if !er.timestamp.IsZero() {
+			req.Header.Set(xfer.ScopeReportTimestampHeader, er.timestamp.Format(time.RFC3339Nano))
+		}
+	} else {
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("Content-Type", "application/msgpack")
+	}
 	// req.Header.Set("Content-Type", "application/binary") // TODO: we should use http.DetectContentType(..) on the gob'ed
 
 	// Make sure this request is cancelled when we stop the client
@@ -344,6 +361,12 @@ func (c *appClient) Publish(r io.Reader, shortcut bool) error {
 	return nil
 }
 
+// Replay implements AppClient. Unlike Publish, it publishes the report
+// right away, so buffered reports aren't dropped from the queue.
+func (c *appClient) Replay(r io.Reader) error {
+	return c.publish(r)
+}
+
 func (c *appClient) pipeConnection(id string, pipe xfer.Pipe) (bool, error) {
 	headers := http.Header{}
 	c.ProbeConfig.authorizeHeaders(headers)
@@ -395,3 +418,52 @@ func (c *appClient) PipeClose(id string) error {
 	resp.Body.Close()
 	return nil
 }
+
+// LookupDNS asks the app for the DNS resolutions other probes made for
+// the given addresses.
+func (c *appClient) LookupDNS(addresses []string) (report.DNSRecords, error) {
+	query := url.Values{"address": addresses}
+	req, err := c.ProbeConfig.authorizedRequest("GET", c.url("/api/dns?"+query.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Cancel = c.quit
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		text, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s: %s", resp.Status, text)
+	}
+	records := report.DNSRecords{}
+	if err := codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// PublishDNS shares DNS resolutions with the other probes, through the app.
+func (c *appClient) PublishDNS(records report.DNSRecords) error {
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, &codec.JsonHandle{}).Encode(records); err != nil {
+		return err
+	}
+	req, err := c.ProbeConfig.authorizedRequest("POST", c.url("/api/dns"), &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Cancel = c.quit
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		text, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, text)
+	}
+	return nil
+}
@@ -123,10 +123,11 @@ func TestAppClientDetails(t *testing.T) {
 	var (
 		id      = "foobarbaz"
 		version = "imalittleteapot"
-		want    = xfer.Details{ID: id, Version: version}
+		want    = xfer.Details{ID: id, Version: version, Encodings: []string{"gzip", xfer.ZstdEncoding}}
 	)
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Encoding", "gzip, "+xfer.ZstdEncoding+";q=0.9")
 		encoder := codec.NewEncoder(w, &codec.JsonHandle{})
 		if err := encoder.Encode(want); err != nil {
 			t.Fatal(err)
@@ -0,0 +1,168 @@
+package appclient
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ugorji/go/codec"
+)
+
+const bufferFileSuffix = ".report"
+
+// DiskBuffer is a bounded, on-disk, queue of the serialised reports an app
+// missed, along with when they were published, so they can be replayed to
+// it later. It survives probe restarts. When full, the oldest reports are
+// dropped.
+type DiskBuffer struct {
+	dir     string
+	maxSize int64
+
+	mtx   sync.Mutex
+	files []string // oldest first
+	sizes map[string]int64
+	size  int64
+	next  uint64
+}
+
+// bufferHeader is the first line of a buffered report file, followed by
+// the serialised report.
+type bufferHeader struct {
+	Timestamp       time.Time `json:"timestamp"`
+	ContentType     string    `json:"content_type"`
+	ContentEncoding string    `json:"content_encoding"`
+}
+
+// NewDiskBuffer makes a DiskBuffer keeping up to maxSize bytes of reports
+// in dir, picking up the reports already there.
+func NewDiskBuffer(dir string, maxSize int64) (*DiskBuffer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	b := &DiskBuffer{
+		dir:     dir,
+		maxSize: maxSize,
+		sizes:   map[string]int64{},
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if !strings.HasSuffix(name, bufferFileSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, bufferFileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		b.files = append(b.files, name)
+		b.sizes[name] = info.Size()
+		b.size += info.Size()
+		if seq >= b.next {
+			b.next = seq + 1
+		}
+	}
+	sort.Strings(b.files) // names are zero padded
+	return b, nil
+}
+
+// Len returns the number of buffered reports.
+func (b *DiskBuffer) Len() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return len(b.files)
+}
+
+// push buffers a report, published at the given time.
+func (b *DiskBuffer) push(er encodedReport, body []byte, timestamp time.Time) error {
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, &codec.JsonHandle{}).Encode(bufferHeader{
+		Timestamp:       timestamp,
+		ContentType:     er.contentType,
+		ContentEncoding: er.contentEncoding,
+	}); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	buf.Write(body)
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	size := int64(buf.Len())
+	if size > b.maxSize {
+		return fmt.Errorf("report of %d bytes exceeds the buffer size", size)
+	}
+	for b.size+size > b.maxSize && len(b.files) > 0 {
+		if err := b.removeOldest(); err != nil {
+			return err
+		}
+	}
+	name := fmt.Sprintf("%020d%s", b.next, bufferFileSuffix)
+	if err := ioutil.WriteFile(filepath.Join(b.dir, name), buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	b.next++
+	b.files = append(b.files, name)
+	b.sizes[name] = size
+	b.size += size
+	return nil
+}
+
+// oldest reads the oldest buffered report, if any.
+func (b *DiskBuffer) oldest() (encodedReport, bool, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if len(b.files) == 0 {
+		return encodedReport{}, false, nil
+	}
+	content, err := ioutil.ReadFile(filepath.Join(b.dir, b.files[0]))
+	if err != nil {
+		return encodedReport{}, false, err
+	}
+	reader := bufio.NewReader(bytes.NewReader(content))
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return encodedReport{}, false, err
+	}
+	var header bufferHeader
+	if err := codec.NewDecoderBytes(line, &codec.JsonHandle{}).Decode(&header); err != nil {
+		return encodedReport{}, false, err
+	}
+	return encodedReport{
+		Reader:          reader,
+		contentType:     header.ContentType,
+		contentEncoding: header.ContentEncoding,
+		timestamp:       header.Timestamp,
+	}, true, nil
+}
+
+// pop drops the oldest buffered report.
+func (b *DiskBuffer) pop() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if len(b.files) == 0 {
+		return nil
+	}
+	return b.removeOldest()
+}
+
+func (b *DiskBuffer) removeOldest() error {
+	name := b.files[0]
+	if err := os.Remove(filepath.Join(b.dir, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	b.files = b.files[1:]
+	b.size -= b.sizes[name]
+	delete(b.sizes, name)
+	return nil
+}
@@ -0,0 +1,66 @@
+package appclient
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDiskBuffer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-buffer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := NewDiskBuffer(dir, 3500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1500000000, 0).UTC()
+	er := encodedReport{contentType: "application/msgpack", contentEncoding: "gzip"}
+	pad := func(s string) []byte { return append([]byte(s), bytes.Repeat([]byte("x"), 1000)...) }
+	for i, body := range []string{"first", "second", "third"} {
+		if err := b.push(er, pad(body), now.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.Len() != 3 {
+		t.Fatalf("want 3 buffered reports, have %d", b.Len())
+	}
+
+	// A report which doesn't fit drops the oldest ones
+	if err := b.push(er, pad("fourth"), now.Add(3*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 3 {
+		t.Fatalf("want 3 buffered reports, have %d", b.Len())
+	}
+	if err := b.push(er, bytes.Repeat([]byte("x"), 4000), now); err == nil {
+		t.Error("expected an error buffering a report bigger than the buffer")
+	}
+
+	// Buffered reports survive restarts
+	b, err = NewDiskBuffer(dir, 3500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, ok, err := b.oldest()
+	if err != nil || !ok {
+		t.Fatalf("want a buffered report, have %v, %v", ok, err)
+	}
+	body, _ := ioutil.ReadAll(have)
+	if string(body) != string(pad("second")) || !have.timestamp.Equal(now.Add(time.Second)) || have.contentType != er.contentType || have.contentEncoding != er.contentEncoding {
+		t.Errorf("unexpected oldest report: %+v %q", have, body)
+	}
+	for b.Len() > 0 {
+		if err := b.pop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok, _ := b.oldest(); ok {
+		t.Error("expected an empty buffer")
+	}
+}
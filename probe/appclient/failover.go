@@ -0,0 +1,212 @@
+package appclient
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/weaveworks/common/mtime"
+)
+
+// FailoverConfig makes a MultiAppClient publish to one target at a time,
+// rather than to all of them: to the apps of the first of the targets
+// which has any available. Targets are available once their apps answer
+// the regular resolution of the targets, so failing over takes up to a DNS
+// poll interval, unless publishing to the primary fails first.
+type FailoverConfig struct {
+	Targets []Target // by priority
+
+	// Optional; buffers the reports published until the primary target
+	// acks them, replaying them to it, so none are lost while it is
+	// unavailable or failing. Publishing to the primary failing fails
+	// over right away.
+	Buffer *DiskBuffer
+}
+
+// NewFailoverAppClient creates a new MultiAppClient publishing with
+// failover. Controls and pipes still work with all the apps.
+func NewFailoverAppClient(clientFactory ClientFactory, noControls bool, config FailoverConfig) MultiAppClient {
+	c := NewMultiAppClient(clientFactory, noControls).(*multiClient)
+	c.failover = &config
+	return c
+}
+
+func (c *multiClient) primary() string {
+	if len(c.failover.Targets) == 0 {
+		return ""
+	}
+	return c.failover.Targets[0].hostname
+}
+
+// byPriority returns the hostnames of the targets by priority, followed by
+// any other hostnames (e.g. from weave DNS).
+func (c *multiClient) byPriority() []string {
+	var (
+		result []string
+		others []string
+		seen   = map[string]struct{}{}
+	)
+	for _, t := range c.failover.Targets {
+		if _, ok := seen[t.hostname]; !ok {
+			result = append(result, t.hostname)
+			seen[t.hostname] = struct{}{}
+		}
+	}
+	for hostname := range c.ids {
+		if _, ok := seen[hostname]; !ok {
+			others = append(others, hostname)
+		}
+	}
+	sort.Strings(others)
+	return append(result, others...)
+}
+
+// publishFailover publishes to the apps of the first available target.
+// With a buffer, the primary is published to from the buffer, and the
+// next target is used while that fails. Called with c.mtx held.
+func (c *multiClient) publishFailover(r io.Reader, shortcut bool) error {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	er, ok := r.(encodedReport)
+	if !ok {
+		er = encodedReport{contentType: "application/msgpack", contentEncoding: "gzip"}
+	}
+
+	primary := c.primary()
+	// Shortcut reports are only useful right away.
+	buffered := c.failover.Buffer != nil && !shortcut
+	if buffered {
+		if err := c.failover.Buffer.push(er, buf, mtime.Now()); err != nil {
+			log.Errorf("Error buffering report: %v", err)
+			buffered = false
+		}
+	}
+
+	for _, hostname := range c.byPriority() {
+		ids := c.ids[hostname]
+		if len(ids) == 0 {
+			continue
+		}
+		if hostname == primary && c.failover.Buffer != nil {
+			c.startReplay()
+			if c.primaryFailing {
+				continue
+			}
+			if buffered {
+				return nil
+			}
+		}
+		errs := []string{}
+		for _, id := range ids {
+			client, ok := c.clients[id]
+			if !ok {
+				continue
+			}
+			er.Reader = bytes.NewReader(buf)
+			if err := client.Publish(er, shortcut); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if len(errs) > 0 {
+			return errors.New(strings.Join(errs, "; "))
+		}
+		return nil
+	}
+	return nil
+}
+
+// startReplay starts replaying the buffered reports to the primary, unless
+// already doing so. Called with c.mtx held.
+func (c *multiClient) startReplay() {
+	if c.replaying || c.failover.Buffer == nil || c.failover.Buffer.Len() == 0 {
+		return
+	}
+	c.replaying = true
+	go c.replay()
+}
+
+// replay publishes the buffered reports to the apps of the primary, oldest
+// first, until done or failing. Reports are only dropped from the buffer
+// once all the apps have acked them.
+func (c *multiClient) replay() {
+	buffer := c.failover.Buffer
+	if n := buffer.Len(); n > 1 {
+		log.Infof("Replaying %d buffered reports", n)
+	}
+	for {
+		select {
+		case <-c.quit:
+			c.stopReplay(false)
+			return
+		default:
+		}
+
+		// Checked with c.mtx held, so reports buffered as the replay
+		// stops start another one.
+		c.mtx.Lock()
+		er, ok, err := buffer.oldest()
+		if !ok && err == nil {
+			c.replaying = false
+			c.mtx.Unlock()
+			return
+		}
+		c.mtx.Unlock()
+		var body []byte
+		if err == nil {
+			body, err = ioutil.ReadAll(er)
+		}
+		if err != nil {
+			log.Errorf("Dropping unreadable buffered report: %v", err)
+			if err := buffer.pop(); err != nil {
+				c.stopReplay(false)
+				return
+			}
+			continue
+		}
+
+		c.mtx.Lock()
+		var clients []AppClient
+		for _, id := range c.ids[c.primary()] {
+			if client, ok := c.clients[id]; ok {
+				clients = append(clients, client)
+			}
+		}
+		c.mtx.Unlock()
+		if len(clients) == 0 {
+			c.stopReplay(false)
+			return
+		}
+		for _, client := range clients {
+			er.Reader = bytes.NewReader(body)
+			if err := client.Replay(er); err != nil {
+				log.Warnf("Error replaying buffered report: %v", err)
+				c.stopReplay(true)
+				return
+			}
+		}
+		c.mtx.Lock()
+		c.primaryFailing = false
+		c.mtx.Unlock()
+		if err := buffer.pop(); err != nil {
+			log.Errorf("Error dropping replayed report: %v", err)
+			c.stopReplay(false)
+			return
+		}
+	}
+}
+
+// stopReplay records the end of a replay, and if the primary failed it.
+func (c *multiClient) stopReplay(failed bool) {
+	c.mtx.Lock()
+	c.replaying = false
+	if failed {
+		c.primaryFailing = true
+	}
+	c.mtx.Unlock()
+}
@@ -27,9 +27,14 @@ type multiClient struct {
 	mtx        sync.Mutex
 	sema       semaphore
 	clients    map[string]AppClient     // holds map from app id -> client
+	details    map[string]xfer.Details  // holds map from app id -> details
 	ids        map[string]report.IDList // holds map from hostname -> app ids
 	quit       chan struct{}
 	noControls bool
+
+	failover       *FailoverConfig // nil publishes to all apps
+	replaying      bool
+	primaryFailing bool // since the last replay to it failed
 }
 
 type clientTuple struct {
@@ -52,6 +57,8 @@ type MultiAppClient interface {
 	PipeClose(appID, pipeID string) error
 	Stop()
 	Publish(io.Reader, bool) error
+	LookupDNS([]string) (report.DNSRecords, error)
+	PublishDNS(report.DNSRecords) error
 }
 
 // NewMultiAppClient creates a new MultiAppClient.
@@ -61,6 +68,7 @@ func NewMultiAppClient(clientFactory ClientFactory, noControls bool) MultiAppCli
 
 		sema:       newSemaphore(maxConcurrentGET),
 		clients:    map[string]AppClient{},
+		details:    map[string]xfer.Details{},
 		ids:        map[string]report.IDList{},
 		quit:       make(chan struct{}),
 		noControls: noControls,
@@ -103,6 +111,7 @@ func (c *multiClient) Set(hostname string, urls []url.URL) {
 	hostIDs := report.MakeIDList()
 	for tuple := range clients {
 		hostIDs = hostIDs.Add(tuple.ID)
+		c.details[tuple.ID] = tuple.Details
 		if client, ok := c.clients[tuple.ID]; ok {
 			client.ReTarget(tuple.AppClient.Target())
 		} else {
@@ -123,6 +132,7 @@ func (c *multiClient) Set(hostname string, urls []url.URL) {
 		if !allReferencedIDs.Contains(id) {
 			client.Stop()
 			delete(c.clients, id)
+			delete(c.details, id)
 		}
 	}
 }
@@ -169,6 +179,10 @@ func (c *multiClient) Publish(r io.Reader, shortcut bool) error {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
+	if c.failover != nil {
+		return c.publishFailover(r, shortcut)
+	}
+
 	if len(c.clients) <= 1 { // optimisation
 		for _, c := range c.clients {
 			return c.Publish(r, shortcut)
@@ -181,9 +195,108 @@ func (c *multiClient) Publish(r io.Reader, shortcut bool) error {
 		return err
 	}
 
+	er, encoded := r.(encodedReport)
 	errs := []string{}
 	for _, c := range c.clients {
-		if err := c.Publish(bytes.NewReader(buf), shortcut); err != nil {
+		var r io.Reader = bytes.NewReader(buf)
+		if encoded {
+			er.Reader = r
+			r = er
+		}
+		if err := c.Publish(r, shortcut); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// acceptsDeltas implements reportEncoder. Deltas are only published when
+// all the apps accept them.
+//
+// With failover, reports published while the primary is unavailable are
+// full reports, so the buffered ones don't need their baselines, and the
+// other apps needn't wait for one. With a buffer, all reports are buffered
+// until the primary acks them, so they are all full reports.
+func (c *multiClient) acceptsDeltas() bool {
+	if c.failover != nil {
+		if c.failover.Buffer != nil {
+			return false
+		}
+		c.mtx.Lock()
+		primaryUp := len(c.ids[c.primary()]) > 0
+		c.mtx.Unlock()
+		if !primaryUp {
+			return false
+		}
+	}
+	return c.allApps(func(details xfer.Details) bool {
+		return details.Capabilities[xfer.ReportDeltasCapability]
+	})
+}
+
+// acceptsEncoding implements reportEncoder.
+func (c *multiClient) acceptsEncoding(coding string) bool {
+	return c.allApps(func(details xfer.Details) bool {
+		for _, c := range details.Encodings {
+			if c == coding {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (c *multiClient) allApps(f func(xfer.Details) bool) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for id := range c.clients {
+		if !f(c.details[id]) {
+			return false
+		}
+	}
+	return len(c.clients) > 0
+}
+
+// appClients returns the clients of the apps, so they can be called
+// without holding the lock while they make their requests.
+func (c *multiClient) appClients() []AppClient {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	clients := make([]AppClient, 0, len(c.clients))
+	for _, client := range c.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// LookupDNS merges the DNS resolutions known to all the apps for the
+// given addresses. It only fails if all the apps fail.
+func (c *multiClient) LookupDNS(addresses []string) (report.DNSRecords, error) {
+	clients := c.appClients()
+	records := report.DNSRecords{}
+	errs := []string{}
+	for _, c := range clients {
+		r, err := c.LookupDNS(addresses)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		records = records.Merge(r)
+	}
+	if len(errs) > 0 && len(errs) == len(clients) {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+	return records, nil
+}
+
+// PublishDNS shares DNS resolutions with all the apps.
+func (c *multiClient) PublishDNS(records report.DNSRecords) error {
+	errs := []string{}
+	for _, c := range c.appClients() {
+		if err := c.PublishDNS(records); err != nil {
 			errs = append(errs, err.Error())
 		}
 	}
@@ -2,13 +2,19 @@ package appclient_test
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"io/ioutil"
 	"net/url"
+	"os"
 	"runtime"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/probe/appclient"
+	"github.com/weaveworks/scope/report"
 )
 
 type mockClient struct {
@@ -16,6 +22,10 @@ type mockClient struct {
 	count   int
 	stopped int
 	publish int
+
+	mtx       sync.Mutex
+	replay    int
+	replayErr error
 }
 
 func (c *mockClient) Details() (xfer.Details, error) {
@@ -42,8 +52,34 @@ func (c *mockClient) Publish(io.Reader, bool) error {
 	return nil
 }
 
+func (c *mockClient) Replay(io.Reader) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.replayErr != nil {
+		return c.replayErr
+	}
+	c.replay++
+	return nil
+}
+
+func (c *mockClient) replays() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.replay
+}
+
+func (c *mockClient) failReplays(err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.replayErr = err
+}
+
 func (c *mockClient) PipeConnection(_ string, _ xfer.Pipe) {}
 func (c *mockClient) PipeClose(_ string) error             { return nil }
+func (c *mockClient) LookupDNS(_ []string) (report.DNSRecords, error) {
+	return report.DNSRecords{}, nil
+}
+func (c *mockClient) PublishDNS(_ report.DNSRecords) error { return nil }
 
 var (
 	a1      = &mockClient{id: "1"} // hostname a, app id 1
@@ -114,3 +150,100 @@ func TestMultiClientPublish(t *testing.T) {
 		}
 	}
 }
+
+func TestMultiClientFailover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-buffer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	buffer, err := appclient.NewDiskBuffer(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets, err := appclient.ParseTargets([]string{"primary", "secondary"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		primary   = &mockClient{id: "primary"}
+		secondary = &mockClient{id: "secondary"}
+		factory   = func(hostname string, url url.URL) (appclient.AppClient, error) {
+			if url.Host == "primary" {
+				return primary, nil
+			}
+			return secondary, nil
+		}
+		mp = appclient.NewFailoverAppClient(factory, false, appclient.FailoverConfig{
+			Targets: targets,
+			Buffer:  buffer,
+		})
+		publish = func() {
+			if err := mp.Publish(bytes.NewBufferString("report"), false); err != nil {
+				t.Fatal(err)
+			}
+		}
+		drained = func() {
+			deadline := time.Now().Add(5 * time.Second)
+			for buffer.Len() > 0 {
+				if time.Now().After(deadline) {
+					t.Fatalf("buffered reports not replayed")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	)
+	defer mp.Stop()
+
+	// Reports are buffered until the primary acks them.
+	mp.Set("primary", []url.URL{{Host: "primary"}})
+	mp.Set("secondary", []url.URL{{Host: "secondary"}})
+	publish()
+	drained()
+	if primary.replays() != 1 || primary.publish != 0 || secondary.publish != 0 {
+		t.Fatalf("want reports replayed to the primary only, have %d, %d and %d", primary.replays(), primary.publish, secondary.publish)
+	}
+
+	// The primary goes away
+	mp.Set("primary", []url.URL{})
+	publish()
+	publish()
+	if secondary.publish != 2 || buffer.Len() != 2 {
+		t.Fatalf("want reports published to the secondary and buffered, have %d published, %d buffered", secondary.publish, buffer.Len())
+	}
+
+	// The primary is back, and gets the buffered reports replayed
+	mp.Set("primary", []url.URL{{Host: "primary"}})
+	publish()
+	drained()
+	if primary.replays() != 4 || secondary.publish != 2 {
+		t.Errorf("want 3 more reports replayed, have %d, and %d published to the secondary", primary.replays()-1, secondary.publish)
+	}
+
+	// The primary resolves, but fails publishes: reports fail over to
+	// the secondary as soon as one does, and stay buffered.
+	primary.failReplays(errors.New("503 Service Unavailable"))
+	deadline := time.Now().Add(5 * time.Second)
+	for secondary.publish == 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("reports not published to the secondary")
+		}
+		publish()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if buffered := buffer.Len(); buffered < 2 {
+		t.Fatalf("want reports buffered, have %d", buffered)
+	}
+
+	// Once the primary acks again, it gets them all.
+	primary.failReplays(nil)
+	publish()
+	drained()
+	secondaryPublished := secondary.publish
+	publish()
+	drained()
+	if secondary.publish != secondaryPublished {
+		t.Errorf("want reports published to the primary only again")
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/certifi/gocertifi"
 	"github.com/hashicorp/go-cleanhttp"
 
+	"github.com/weaveworks/scope/common/mtls"
 	"github.com/weaveworks/scope/common/xfer"
 )
 
@@ -35,6 +36,7 @@ type ProbeConfig struct {
 	ProbeVersion string
 	ProbeID      string
 	Insecure     bool
+	TLS          *mtls.Config // optional; client certificate, CA and SPIFFE ID of the app
 }
 
 func (pc ProbeConfig) authorizeHeaders(headers http.Header) {
@@ -51,19 +53,30 @@ func (pc ProbeConfig) authorizedRequest(method string, urlStr string, body io.Re
 	return req, err
 }
 
-func (pc ProbeConfig) getHTTPTransport(hostname string) *http.Transport {
+func (pc ProbeConfig) getHTTPTransport(hostname string) (*http.Transport, error) {
 	transport := cleanhttp.DefaultTransport()
 	transport.DialContext = (&net.Dialer{
 		Timeout:   dialTimeout,
 		KeepAlive: 30 * time.Second,
 	}).DialContext
-	if pc.Insecure {
+	switch {
+	case pc.TLS != nil:
+		config, err := pc.TLS.Client(hostname, certPool)
+		if err != nil {
+			return nil, err
+		}
+		if pc.Insecure {
+			config.InsecureSkipVerify = true
+			config.VerifyPeerCertificate = nil
+		}
+		transport.TLSClientConfig = config
+	case pc.Insecure:
 		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-	} else {
+	default:
 		transport.TLSClientConfig = &tls.Config{
 			RootCAs:    certPool,
 			ServerName: hostname,
 		}
 	}
-	return transport
+	return transport, nil
 }
@@ -3,14 +3,46 @@ package appclient
 import (
 	"bytes"
 	"compress/gzip"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/report"
 )
 
+// fullReportInterval is how many reports are published as deltas against
+// a full report, before publishing the next full report. An app which
+// missed the full report rejects the deltas until then.
+const fullReportInterval = 10
+
 // A ReportPublisher uses a buffer pool to serialise reports, which it
 // then passes to a publisher
 type ReportPublisher struct {
 	publisher  Publisher
 	noControls bool
+	encoder    *zstd.Encoder
+
+	baseline      report.Report // last full report published as a delta
+	sinceBaseline int
+}
+
+// reportEncoder is a Publisher which can tell whether all the apps it
+// publishes to accept reports as deltas, or in content codings other than
+// gzip.
+type reportEncoder interface {
+	acceptsDeltas() bool
+	acceptsEncoding(coding string) bool
+}
+
+// encodedReport is a serialised report, along with how it was serialised.
+type encodedReport struct {
+	io.Reader
+	contentType     string
+	contentEncoding string
+	timestamp       time.Time // when published, if replayed from a buffer
 }
 
 // NewReportPublisher creates a new report publisher
@@ -28,7 +60,69 @@ func (p *ReportPublisher) Publish(r report.Report) error {
 			t.Controls = report.Controls{}
 		})
 	}
+	var deltas, zstded bool
+	if re, ok := p.publisher.(reportEncoder); ok {
+		deltas = re.acceptsDeltas()
+		zstded = re.acceptsEncoding(xfer.ZstdEncoding)
+	}
+	if !deltas {
+		p.baseline = report.Report{}
+	}
+
+	var (
+		v           interface{} = &r
+		contentType             = "application/msgpack"
+	)
+	// Shortcut reports are partial, so they can't be a baseline. A delta
+	// without a baseline is a full report, which the apps keep as the
+	// baseline of the next deltas.
+	if deltas && !r.Shortcut {
+		delta := report.Delta{Report: r}
+		if p.baseline.ID != "" && p.sinceBaseline < fullReportInterval {
+			delta = report.MakeDelta(p.baseline, r)
+			p.sinceBaseline++
+		} else {
+			p.baseline, p.sinceBaseline = r, 0
+		}
+		v, contentType = &delta, report.DeltaContentType
+	}
+
 	buf := &bytes.Buffer{}
-	r.WriteBinary(buf, gzip.DefaultCompression)
-	return p.publisher.Publish(buf, r.Shortcut)
+	contentEncoding := "gzip"
+	if zstded {
+		if err := p.writeZstd(buf, v); err != nil {
+			return err
+		}
+		contentEncoding = xfer.ZstdEncoding
+	} else if err := writeGzip(buf, v); err != nil {
+		return err
+	}
+	return p.publisher.Publish(encodedReport{Reader: buf, contentType: contentType, contentEncoding: contentEncoding}, r.Shortcut)
+}
+
+func writeGzip(w io.Writer, v interface{}) error {
+	gzwriter, err := gzip.NewWriterLevel(w, gzip.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	if err := codec.NewEncoder(gzwriter, &codec.MsgpackHandle{}).Encode(v); err != nil {
+		return err
+	}
+	return gzwriter.Close()
+}
+
+func (p *ReportPublisher) writeZstd(w io.Writer, v interface{}) error {
+	if p.encoder == nil {
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return err
+		}
+		p.encoder = encoder
+	}
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, &codec.MsgpackHandle{}).Encode(v); err != nil {
+		return err
+	}
+	_, err := w.Write(p.encoder.EncodeAll(buf.Bytes(), nil))
+	return err
 }
@@ -0,0 +1,110 @@
+package appclient
+
+import (
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+)
+
+type mockDeltaPublisher struct {
+	deltas    bool
+	zstd      bool
+	published []io.Reader
+}
+
+func (p *mockDeltaPublisher) Publish(r io.Reader, _ bool) error {
+	p.published = append(p.published, r)
+	return nil
+}
+
+func (p *mockDeltaPublisher) Stop() {}
+
+func (p *mockDeltaPublisher) acceptsDeltas() bool { return p.deltas }
+
+func (p *mockDeltaPublisher) acceptsEncoding(coding string) bool {
+	return p.zstd && coding == xfer.ZstdEncoding
+}
+
+func TestReportPublisherDeltas(t *testing.T) {
+	var (
+		publisher = &mockDeltaPublisher{}
+		rp        = NewReportPublisher(publisher, false)
+		rpt       = report.MakeReport()
+		shortcut  = report.MakeReport()
+	)
+	rpt.Host.AddNode(report.MakeNodeWith("host", map[string]string{"os": "linux"}))
+	shortcut.Shortcut = true
+
+	// baseline of the published delta, or "full" for full reports
+	last := func() string {
+		r := publisher.published[len(publisher.published)-1]
+		er := r.(encodedReport)
+		if er.contentType != report.DeltaContentType {
+			return "full"
+		}
+		var d report.Delta
+		if err := d.ReadBinary(er, true); err != nil {
+			t.Fatal(err)
+		}
+		return d.Baseline
+	}
+
+	rp.Publish(rpt)
+	if have := last(); have != "full" {
+		t.Fatalf("want a full report without delta support, have a delta against %q", have)
+	}
+
+	publisher.deltas = true
+	rp.Publish(rpt)
+	if have := last(); have != "" {
+		t.Fatalf("want a delta without baseline, have %q", have)
+	}
+	for i := 0; i < fullReportInterval; i++ {
+		rp.Publish(shortcut)
+		if have := last(); have != "full" {
+			t.Fatalf("want a full shortcut report, have a delta against %q", have)
+		}
+		rp.Publish(rpt)
+		if have := last(); have != rpt.ID {
+			t.Fatalf("want a delta against %q, have %q", rpt.ID, have)
+		}
+	}
+	rp.Publish(rpt)
+	if have := last(); have != "" {
+		t.Fatalf("want a new baseline after %d deltas, have a delta against %q", fullReportInterval, have)
+	}
+}
+
+func TestReportPublisherZstd(t *testing.T) {
+	var (
+		publisher = &mockDeltaPublisher{zstd: true}
+		rp        = NewReportPublisher(publisher, false)
+		rpt       = report.MakeReport()
+	)
+	rpt.Host.AddNode(report.MakeNodeWith("host", map[string]string{"os": "linux"}))
+	if err := rp.Publish(rpt); err != nil {
+		t.Fatal(err)
+	}
+
+	er := publisher.published[0].(encodedReport)
+	if er.contentEncoding != xfer.ZstdEncoding {
+		t.Fatalf("want %s encoding, have %s", xfer.ZstdEncoding, er.contentEncoding)
+	}
+	decoder, err := zstd.NewReader(er)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+	var have report.Report
+	if err := have.ReadBinary(decoder, false, &codec.MsgpackHandle{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := have.Host.Nodes["host"]; !ok {
+		t.Errorf("host missing from %v", have.Host.Nodes)
+	}
+}
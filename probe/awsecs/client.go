@@ -10,11 +10,18 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/bluele/gcache"
 )
 
-const servicePrefix = "ecs-svc" // Task StartedBy field begins with this if it was started by a service
+const (
+	servicePrefix = "ecs-svc" // Task StartedBy field begins with this if it was started by a service
+
+	// CloudWatch has the metrics of ECS services by the minute, so there's no
+	// point in asking more often.
+	metricsPeriod = time.Minute
+)
 
 // EcsClient is a wrapper around an AWS client that makes all the needed calls and just exposes the final results.
 // We create an interface so we can mock for testing.
@@ -23,14 +30,21 @@ type EcsClient interface {
 	GetInfo([]string) EcsInfo
 	// Scales a service up or down by amount
 	ScaleService(string, int) error
+	// Returns the ARNs of the running tasks of the cluster which aren't placed on
+	// a container instance, ie. those AWS Fargate runs.
+	ListFargateTasks() []string
+	// Returns the CPU and memory utilization of the given services, as CloudWatch has them.
+	GetServiceMetrics([]string) map[string]EcsServiceMetrics
 }
 
 // actual implementation
 type ecsClientImpl struct {
 	client       *ecs.ECS
+	metrics      *cloudwatch.CloudWatch
 	cluster      string
 	taskCache    gcache.Cache // Keys are task ARNs.
 	serviceCache gcache.Cache // Keys are service names.
+	metricsCache gcache.Cache // Keys are service names.
 }
 
 // EcsTask describes the parts of ECS tasks we care about.
@@ -46,6 +60,9 @@ type EcsTask struct {
 	// which we know it is because otherwise we wouldn't be looking at it.
 	StartedAt time.Time
 	StartedBy string // tag or deployment id
+
+	// Blank for tasks run by AWS Fargate, as they aren't placed on an instance of ours.
+	ContainerInstanceARN string
 }
 
 // EcsService describes the parts of ECS services we care about.
@@ -63,6 +80,15 @@ type EcsService struct {
 	TaskDefinitionARN string
 }
 
+// EcsServiceMetrics is the average utilization of the CPU and memory
+// reserved for the tasks of a service, in percent, over the last period
+// CloudWatch has. Exported for test.
+type EcsServiceMetrics struct {
+	Timestamp         time.Time
+	CPUUtilization    float64
+	MemoryUtilization float64
+}
+
 // EcsInfo is exported for test
 type EcsInfo struct {
 	Tasks          map[string]EcsTask
@@ -81,11 +107,14 @@ func newClient(cluster string, cacheSize int, cacheExpiry time.Duration, cluster
 		}
 	}
 
+	config := &aws.Config{Region: aws.String(clusterRegion)}
 	return &ecsClientImpl{
-		client:       ecs.New(sess, &aws.Config{Region: aws.String(clusterRegion)}),
+		client:       ecs.New(sess, config),
+		metrics:      cloudwatch.New(sess, config),
 		cluster:      cluster,
 		taskCache:    gcache.New(cacheSize).LRU().Expiration(cacheExpiry).Build(),
 		serviceCache: gcache.New(cacheSize).LRU().Expiration(cacheExpiry).Build(),
+		metricsCache: gcache.New(cacheSize).LRU().Expiration(metricsPeriod).Build(),
 	}, nil
 }
 
@@ -119,6 +148,8 @@ func newECSTask(task *ecs.Task) EcsTask {
 		TaskDefinitionARN: stringOrBlank(task.TaskDefinitionArn),
 		StartedAt:         timeOrZero(task.StartedAt),
 		StartedBy:         stringOrBlank(task.StartedBy),
+
+		ContainerInstanceARN: stringOrBlank(task.ContainerInstanceArn),
 	}
 }
 
@@ -418,6 +449,113 @@ func (c ecsClientImpl) GetInfo(taskARNs []string) EcsInfo {
 	return info
 }
 
+// Returns the ARNs of the running tasks of the cluster.
+// Cannot fail as it will attempt to deliver partial results, though that may end up being no results.
+func (c ecsClientImpl) listTasks() []string {
+	log.Debugf("Listing ECS tasks")
+	results := []string{}
+	err := c.client.ListTasksPages(
+		&ecs.ListTasksInput{Cluster: &c.cluster, DesiredStatus: aws.String(ecs.DesiredStatusRunning)},
+		func(page *ecs.ListTasksOutput, lastPage bool) bool {
+			if page == nil {
+				return true
+			}
+			for _, arn := range page.TaskArns {
+				if arn != nil {
+					results = append(results, *arn)
+				}
+			}
+			return true
+		},
+	)
+	if err != nil {
+		log.Warnf("Error listing ECS tasks, ECS task report may be incomplete: %v", err)
+	}
+	log.Debugf("Listed %d tasks", len(results))
+	return results
+}
+
+// Implements EcsClient.ListFargateTasks
+func (c ecsClientImpl) ListFargateTasks() []string {
+	const maxTasks = 100 // How many tasks we can put in one Describe command
+	taskARNs := c.listTasks()
+	for batch := taskARNs; len(batch) > 0; {
+		n := len(batch)
+		if n > maxTasks {
+			n = maxTasks
+		}
+		c.ensureTasksAreCached(batch[:n])
+		batch = batch[n:]
+	}
+
+	results := []string{}
+	for _, taskARN := range taskARNs {
+		if task, ok := c.getCachedTask(taskARN); ok && task.ContainerInstanceARN == "" {
+			results = append(results, taskARN)
+		}
+	}
+	log.Debugf("Found %d Fargate tasks from %d tasks", len(results), len(taskARNs))
+	return results
+}
+
+// Fetches the latest average of a metric of a service from CloudWatch, returning (value, timestamp, ok).
+func (c ecsClientImpl) getServiceMetric(serviceName, metricName string) (float64, time.Time, bool) {
+	now := time.Now()
+	resp, err := c.metrics.GetMetricStatistics(&cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/ECS"),
+		MetricName: aws.String(metricName),
+		Dimensions: []*cloudwatch.Dimension{
+			{Name: aws.String("ClusterName"), Value: aws.String(c.cluster)},
+			{Name: aws.String("ServiceName"), Value: aws.String(serviceName)},
+		},
+		// Metrics can take a few minutes to show up
+		StartTime:  aws.Time(now.Add(-5 * metricsPeriod)),
+		EndTime:    aws.Time(now),
+		Period:     aws.Int64(int64(metricsPeriod / time.Second)),
+		Statistics: []*string{aws.String(cloudwatch.StatisticAverage)},
+	})
+	if err != nil {
+		log.Warnf("Error getting %s of ECS service %s, ECS service report may be incomplete: %v", metricName, serviceName, err)
+		return 0, time.Time{}, false
+	}
+	var latest *cloudwatch.Datapoint
+	for _, point := range resp.Datapoints {
+		if point == nil || point.Average == nil || point.Timestamp == nil {
+			continue
+		}
+		if latest == nil || point.Timestamp.After(*latest.Timestamp) {
+			latest = point
+		}
+	}
+	if latest == nil {
+		return 0, time.Time{}, false
+	}
+	return *latest.Average, *latest.Timestamp, true
+}
+
+// Implements EcsClient.GetServiceMetrics
+func (c ecsClientImpl) GetServiceMetrics(serviceNames []string) map[string]EcsServiceMetrics {
+	results := map[string]EcsServiceMetrics{}
+	for _, serviceName := range serviceNames {
+		if metricsRaw, err := c.metricsCache.Get(serviceName); err == nil {
+			results[serviceName] = metricsRaw.(EcsServiceMetrics)
+			continue
+		}
+		cpu, timestamp, ok := c.getServiceMetric(serviceName, "CPUUtilization")
+		if !ok {
+			continue
+		}
+		memory, _, ok := c.getServiceMetric(serviceName, "MemoryUtilization")
+		if !ok {
+			continue
+		}
+		metrics := EcsServiceMetrics{Timestamp: timestamp, CPUUtilization: cpu, MemoryUtilization: memory}
+		c.metricsCache.Set(serviceName, metrics)
+		results[serviceName] = metrics
+	}
+	return results
+}
+
 // Implements EcsClient.ScaleService
 func (c ecsClientImpl) ScaleService(serviceName string, amount int) error {
 	// Note this is inherently racey, due to needing to get, modify, then update the DesiredCount.
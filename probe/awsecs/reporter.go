@@ -2,6 +2,7 @@ package awsecs
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -22,6 +23,12 @@ const (
 	ScaleDown           = report.ECSScaleDown
 )
 
+// Keys for use in Node.Metrics.
+const (
+	ServiceCPUUtilization    = "ecs_service_cpu_utilization"
+	ServiceMemoryUtilization = "ecs_service_memory_utilization"
+)
+
 var (
 	taskMetadata = report.MetadataTemplates{
 		Cluster:    {ID: Cluster, Label: "Cluster", From: report.FromLatest, Priority: 0},
@@ -34,6 +41,10 @@ var (
 		ServiceDesiredCount: {ID: ServiceDesiredCount, Label: "Desired Tasks", From: report.FromLatest, Priority: 2, Datatype: report.Number},
 		ServiceRunningCount: {ID: ServiceRunningCount, Label: "Running Tasks", From: report.FromLatest, Priority: 3, Datatype: report.Number},
 	}
+	serviceMetrics = report.MetricTemplates{
+		ServiceCPUUtilization:    {ID: ServiceCPUUtilization, Label: "CPU", Format: report.PercentFormat, Priority: 1},
+		ServiceMemoryUtilization: {ID: ServiceMemoryUtilization, Label: "Memory", Format: report.PercentFormat, Priority: 2},
+	}
 )
 
 // TaskLabelInfo is used in return value of GetLabelInfo. Exported for test.
@@ -82,9 +93,20 @@ func GetLabelInfo(rpt report.Report) map[string]map[string]*TaskLabelInfo {
 	return results
 }
 
+// taskFamily returns the family of a task from the ARN of its task definition,
+// e.g. "web" of "arn:aws:ecs:us-east-1:123456789012:task-definition/web:3".
+func taskFamily(taskDefinitionARN string) string {
+	family := taskDefinitionARN[strings.LastIndex(taskDefinitionARN, "/")+1:]
+	if i := strings.LastIndex(family, ":"); i >= 0 {
+		family = family[:i]
+	}
+	return family
+}
+
 // Reporter implements Tagger, Reporter
 type Reporter struct {
 	ClientsByCluster map[string]EcsClient // Exported for test
+	fargateClusters  []string
 	cacheSize        int
 	cacheExpiry      time.Duration
 	clusterRegion    string
@@ -92,10 +114,13 @@ type Reporter struct {
 	probeID          string
 }
 
-// Make creates a new Reporter
-func Make(cacheSize int, cacheExpiry time.Duration, clusterRegion string, handlerRegistry *controls.HandlerRegistry, probeID string) Reporter {
+// Make creates a new Reporter. The tasks AWS Fargate runs in the
+// fargateClusters, which no probe can see the containers of, are reported
+// from the ECS API alone.
+func Make(cacheSize int, cacheExpiry time.Duration, clusterRegion string, fargateClusters []string, handlerRegistry *controls.HandlerRegistry, probeID string) Reporter {
 	r := Reporter{
 		ClientsByCluster: map[string]EcsClient{},
+		fargateClusters:  fargateClusters,
 		cacheSize:        cacheSize,
 		cacheExpiry:      cacheExpiry,
 		clusterRegion:    clusterRegion,
@@ -149,18 +174,7 @@ func (r Reporter) Tag(rpt report.Report) (report.Report, error) {
 
 		// Create all the services first
 		for serviceName, service := range ecsInfo.Services {
-			serviceID := report.MakeECSServiceNodeID(cluster, serviceName)
-			rpt.ECSService = rpt.ECSService.AddNode(report.MakeNodeWith(serviceID, map[string]string{
-				Cluster:               cluster,
-				ServiceDesiredCount:   fmt.Sprintf("%d", service.DesiredCount),
-				ServiceRunningCount:   fmt.Sprintf("%d", service.RunningCount),
-				report.ControlProbeID: r.probeID,
-			}).WithLatestControls(map[string]report.NodeControlData{
-				ScaleUp: {Dead: false},
-				// We've decided for now to disable ScaleDown when only 1 task is desired,
-				// since scaling down to 0 would cause the service to disappear (#2085)
-				ScaleDown: {Dead: service.DesiredCount <= 1},
-			}))
+			rpt.ECSService = rpt.ECSService.AddNode(r.serviceNode(cluster, serviceName, service))
 		}
 		log.Debugf("Created %v ECS service nodes", len(ecsInfo.Services))
 
@@ -172,23 +186,11 @@ func (r Reporter) Tag(rpt report.Report) (report.Report, error) {
 			}
 
 			// new task node
-			taskID := report.MakeECSTaskNodeID(taskArn)
-			node := report.MakeNodeWith(taskID, map[string]string{
-				TaskFamily: info.Family,
-				Cluster:    cluster,
-				CreatedAt:  task.CreatedAt.Format(time.RFC3339Nano),
-			})
+			node := taskNode(cluster, info.Family, task, ecsInfo.TaskServiceMap)
 			rpt.ECSTask = rpt.ECSTask.AddNode(node)
 
 			// parents sets to merge into all matching container nodes
-			parentsSets := report.MakeSets()
-			parentsSets = parentsSets.Add(report.ECSTask, report.MakeStringSet(taskID))
-			if serviceName, ok := ecsInfo.TaskServiceMap[taskArn]; ok {
-				serviceID := report.MakeECSServiceNodeID(cluster, serviceName)
-				parentsSets = parentsSets.Add(report.ECSService, report.MakeStringSet(serviceID))
-				// in addition, make service parent of task
-				rpt.ECSTask.Nodes[taskID] = rpt.ECSTask.Nodes[taskID].WithParents(report.MakeSets().Add(report.ECSService, report.MakeStringSet(serviceID)))
-			}
+			parentsSets := node.Parents.Add(report.ECSTask, report.MakeStringSet(node.ID))
 			for _, containerID := range info.ContainerIDs {
 				if containerNode, ok := rpt.Container.Nodes[containerID]; ok {
 					rpt.Container.Nodes[containerID] = containerNode.WithParents(parentsSets)
@@ -203,12 +205,78 @@ func (r Reporter) Tag(rpt report.Report) (report.Report, error) {
 	return rpt, nil
 }
 
+func (r Reporter) serviceNode(cluster, serviceName string, service EcsService) report.Node {
+	return report.MakeNodeWith(report.MakeECSServiceNodeID(cluster, serviceName), map[string]string{
+		Cluster:               cluster,
+		ServiceDesiredCount:   fmt.Sprintf("%d", service.DesiredCount),
+		ServiceRunningCount:   fmt.Sprintf("%d", service.RunningCount),
+		report.ControlProbeID: r.probeID,
+	}).WithLatestControls(map[string]report.NodeControlData{
+		ScaleUp: {Dead: false},
+		// We've decided for now to disable ScaleDown when only 1 task is desired,
+		// since scaling down to 0 would cause the service to disappear (#2085)
+		ScaleDown: {Dead: service.DesiredCount <= 1},
+	})
+}
+
+// taskNode makes the node of a task, with the service it belongs to, if any, as its parent.
+func taskNode(cluster, family string, task EcsTask, taskServiceMap map[string]string) report.Node {
+	node := report.MakeNodeWith(report.MakeECSTaskNodeID(task.TaskARN), map[string]string{
+		TaskFamily: family,
+		Cluster:    cluster,
+		CreatedAt:  task.CreatedAt.Format(time.RFC3339Nano),
+	})
+	if serviceName, ok := taskServiceMap[task.TaskARN]; ok {
+		node = node.WithParents(report.MakeSets().Add(report.ECSService, report.MakeStringSet(report.MakeECSServiceNodeID(cluster, serviceName))))
+	}
+	return node
+}
+
+// reportFargate adds the tasks AWS Fargate runs in a cluster, and their
+// services, to the report. Those tasks have no containers in any report, as
+// we can't run a probe where they run, so we only know them from the ECS API,
+// and their services' utilization from CloudWatch.
+func (r Reporter) reportFargate(rpt *report.Report, cluster string) error {
+	client, err := r.getClient(cluster)
+	if err != nil {
+		return err
+	}
+
+	taskArns := client.ListFargateTasks()
+	ecsInfo := client.GetInfo(taskArns)
+	log.Debugf("Got info from ECS on Fargate tasks of cluster %v: %d tasks, %d services", cluster, len(ecsInfo.Tasks), len(ecsInfo.Services))
+
+	serviceNames := make([]string, 0, len(ecsInfo.Services))
+	for serviceName := range ecsInfo.Services {
+		serviceNames = append(serviceNames, serviceName)
+	}
+	serviceMetrics := client.GetServiceMetrics(serviceNames)
+	for serviceName, service := range ecsInfo.Services {
+		node := r.serviceNode(cluster, serviceName, service)
+		if metrics, ok := serviceMetrics[serviceName]; ok {
+			node = node.WithMetrics(report.Metrics{
+				ServiceCPUUtilization:    report.MakeSingletonMetric(metrics.Timestamp, metrics.CPUUtilization).WithMax(100),
+				ServiceMemoryUtilization: report.MakeSingletonMetric(metrics.Timestamp, metrics.MemoryUtilization).WithMax(100),
+			})
+		}
+		rpt.ECSService.AddNode(node)
+	}
+
+	for _, task := range ecsInfo.Tasks {
+		rpt.ECSTask.AddNode(taskNode(cluster, taskFamily(task.TaskDefinitionARN), task, ecsInfo.TaskServiceMap))
+	}
+	return nil
+}
+
 // Report needed for Reporter
-func (Reporter) Report() (report.Report, error) {
+func (r Reporter) Report() (report.Report, error) {
 	result := report.MakeReport()
 	taskTopology := report.MakeTopology().WithMetadataTemplates(taskMetadata)
 	result.ECSTask = result.ECSTask.Merge(taskTopology)
 	serviceTopology := report.MakeTopology().WithMetadataTemplates(serviceMetadata)
+	if len(r.fargateClusters) > 0 {
+		serviceTopology = serviceTopology.WithMetricTemplates(serviceMetrics)
+	}
 	serviceTopology.Controls.AddControls([]report.Control{
 		{
 			ID:    ScaleDown,
@@ -224,6 +292,12 @@ func (Reporter) Report() (report.Report, error) {
 		},
 	})
 	result.ECSService = result.ECSService.Merge(serviceTopology)
+
+	for _, cluster := range r.fargateClusters {
+		if err := r.reportFargate(&result, cluster); err != nil {
+			log.Warnf("Failed to report Fargate tasks of ECS cluster %v: %v", cluster, err)
+		}
+	}
 	return result, nil
 }
 
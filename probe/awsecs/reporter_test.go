@@ -38,7 +38,7 @@ func getTestContainerNode() report.Node {
 
 func TestGetLabelInfo(t *testing.T) {
 	hr := controls.NewDefaultHandlerRegistry()
-	r := awsecs.Make(1e6, time.Hour, "", hr, "test-probe-id")
+	r := awsecs.Make(1e6, time.Hour, "", nil, hr, "test-probe-id")
 	rpt, err := r.Report()
 	if err != nil {
 		t.Fatalf("Error making report: %v", err)
@@ -69,6 +69,7 @@ type mockEcsClient struct {
 	t            *testing.T
 	expectedARNs []string
 	info         awsecs.EcsInfo
+	metrics      map[string]awsecs.EcsServiceMetrics
 }
 
 func newMockEcsClient(t *testing.T, expectedARNs []string, info awsecs.EcsInfo) awsecs.EcsClient {
@@ -76,6 +77,7 @@ func newMockEcsClient(t *testing.T, expectedARNs []string, info awsecs.EcsInfo)
 		t,
 		expectedARNs,
 		info,
+		nil,
 	}
 }
 
@@ -90,9 +92,17 @@ func (c mockEcsClient) ScaleService(serviceName string, amount int) error {
 	return nil
 }
 
+func (c mockEcsClient) ListFargateTasks() []string {
+	return c.expectedARNs
+}
+
+func (c mockEcsClient) GetServiceMetrics(serviceNames []string) map[string]awsecs.EcsServiceMetrics {
+	return c.metrics
+}
+
 func TestTagReport(t *testing.T) {
 	hr := controls.NewDefaultHandlerRegistry()
-	r := awsecs.Make(1e6, time.Hour, "", hr, "test-probe-id")
+	r := awsecs.Make(1e6, time.Hour, "", nil, hr, "test-probe-id")
 
 	r.ClientsByCluster[testCluster] = newMockEcsClient(
 		t,
@@ -194,3 +204,76 @@ func TestTagReport(t *testing.T) {
 		}
 	}
 }
+
+func TestReportFargate(t *testing.T) {
+	hr := controls.NewDefaultHandlerRegistry()
+	r := awsecs.Make(1e6, time.Hour, "", []string{testCluster}, hr, "test-probe-id")
+	defer r.Stop()
+
+	r.ClientsByCluster[testCluster] = &mockEcsClient{
+		t:            t,
+		expectedARNs: []string{testTaskARN},
+		info: awsecs.EcsInfo{
+			Tasks: map[string]awsecs.EcsTask{
+				testTaskARN: {
+					TaskARN:           testTaskARN,
+					CreatedAt:         testTaskCreatedAt,
+					TaskDefinitionARN: "arn:aws:ecs:us-east-1:123456789012:task-definition/" + testFamily + ":3",
+					StartedAt:         testTaskStartedAt,
+					StartedBy:         testDeploymentID,
+				},
+			},
+			Services: map[string]awsecs.EcsService{
+				testServiceName: {
+					ServiceName:   testServiceName,
+					DeploymentIDs: []string{testDeploymentID},
+					DesiredCount:  2,
+					RunningCount:  1,
+				},
+			},
+			TaskServiceMap: map[string]string{
+				testTaskARN: testServiceName,
+			},
+		},
+		metrics: map[string]awsecs.EcsServiceMetrics{
+			testServiceName: {Timestamp: testTaskStartedAt, CPUUtilization: 12.5, MemoryUtilization: 40},
+		},
+	}
+
+	rpt, err := r.Report()
+	if err != nil {
+		t.Fatalf("Error making report: %v", err)
+	}
+
+	// The task is reported without any containers, with its family from its task definition
+	task, ok := rpt.ECSTask.Nodes[report.MakeECSTaskNodeID(testTaskARN)]
+	if !ok {
+		t.Fatalf("Result report did not contain task %v: %v", testTaskARN, rpt.ECSTask.Nodes)
+	}
+	if family, _ := task.Latest.Lookup(awsecs.TaskFamily); family != testFamily {
+		t.Errorf("Result task did not have expected family: %v != %v", family, testFamily)
+	}
+	serviceID := report.MakeECSServiceNodeID(testCluster, testServiceName)
+	if services, _ := task.Parents.Lookup(report.ECSService); !services.Contains(serviceID) {
+		t.Errorf("Result task did not have its service as a parent: %v", task.Parents)
+	}
+
+	service, ok := rpt.ECSService.Nodes[serviceID]
+	if !ok {
+		t.Fatalf("Result report did not contain service %v: %v", testServiceName, rpt.ECSService.Nodes)
+	}
+	if count, _ := service.Latest.Lookup(awsecs.ServiceDesiredCount); count != "2" {
+		t.Errorf("Result service did not have expected desired count: %v", count)
+	}
+	for key, expectedValue := range map[string]float64{
+		awsecs.ServiceCPUUtilization:    12.5,
+		awsecs.ServiceMemoryUtilization: 40,
+	} {
+		if sample, ok := service.Metrics[key].LastSample(); !ok || sample.Value != expectedValue {
+			t.Errorf("Result service did not have expected value for metric %v: %v != %v", key, sample, expectedValue)
+		}
+	}
+	if _, ok := rpt.ECSService.MetricTemplates[awsecs.ServiceCPUUtilization]; !ok {
+		t.Errorf("Result service topology did not have metric templates: %v", rpt.ECSService.MetricTemplates)
+	}
+}
@@ -0,0 +1,100 @@
+package cgroup
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/weaveworks/common/fs"
+)
+
+// Root is where the cgroup hierarchies are mounted: the unified (v2) one
+// itself, or those of the v1 controllers, beside the unified one as
+// "unified" on hybrid hosts.
+var Root = "/sys/fs/cgroup"
+
+// unifiedRoot returns where the unified hierarchy is mounted, if it is.
+func unifiedRoot() (string, bool) {
+	for _, root := range []string{Root, filepath.Join(Root, "unified")} {
+		if _, err := fs.ReadFile(filepath.Join(root, "cgroup.controllers")); err == nil {
+			return root, true
+		}
+	}
+	return "", false
+}
+
+// Dir returns the directory of the cgroup of a process in the hierarchy of
+// the controller, e.g. "memory", or in the unified hierarchy for "".
+func Dir(procRoot, pid, controller string) (string, error) {
+	buf, err := fs.ReadFile(filepath.Join(procRoot, pid, "cgroup"))
+	if err != nil {
+		return "", err
+	}
+	// Lines are "<hierarchy ID>:<controllers>:<path>", the unified
+	// hierarchy being "0::<path>"
+	for _, line := range strings.Split(string(buf), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		// Paths out of the cgroup namespace of the probe can't be found
+		if strings.HasPrefix(fields[2], "/..") {
+			return "", fmt.Errorf("cgroup of process %s is out of our cgroup namespace", pid)
+		}
+		if controller == "" && fields[0] == "0" && fields[1] == "" {
+			root, ok := unifiedRoot()
+			if !ok {
+				break
+			}
+			return filepath.Join(root, fields[2]), nil
+		}
+		for _, c := range strings.Split(fields[1], ",") {
+			if controller != "" && c == controller {
+				return filepath.Join(Root, fields[1], fields[2]), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no %q cgroup for process %s", controller, pid)
+}
+
+// Pressure is the share of time, in percent over the last 10 seconds, some
+// or all of the tasks were stalled on a resource, as the pressure stall
+// information (PSI) of the kernel tells.
+type Pressure struct {
+	Some float64
+	Full float64
+}
+
+// Resources the kernel tells the pressure on.
+var Resources = []string{"cpu", "memory", "io"}
+
+// ReadPressure reads a PSI file, /proc/pressure/<resource> or
+// <resource>.pressure of a cgroup (v2), e.g.
+//
+//	some avg10=1.53 avg60=0.87 avg300=0.40 total=1289392
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func ReadPressure(path string) (Pressure, error) {
+	var result Pressure
+	buf, err := fs.ReadFile(path)
+	if err != nil {
+		return result, err
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasPrefix(fields[1], "avg10=") {
+			continue
+		}
+		avg10, err := strconv.ParseFloat(strings.TrimPrefix(fields[1], "avg10="), 64)
+		if err != nil {
+			return result, fmt.Errorf("%s: %v", path, err)
+		}
+		switch fields[0] {
+		case "some":
+			result.Some = avg10
+		case "full":
+			result.Full = avg10
+		}
+	}
+	return result, nil
+}
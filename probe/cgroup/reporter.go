@@ -0,0 +1,144 @@
+package cgroup
+
+import (
+	"path/filepath"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys for use in Node.Metrics.
+const (
+	HostCPUPressureSome         = "host_cpu_pressure_some"
+	HostMemoryPressureSome      = "host_memory_pressure_some"
+	HostMemoryPressureFull      = "host_memory_pressure_full"
+	HostIOPressureSome          = "host_io_pressure_some"
+	HostIOPressureFull          = "host_io_pressure_full"
+	ContainerCPUPressureSome    = "container_cpu_pressure_some"
+	ContainerMemoryPressureSome = "container_memory_pressure_some"
+	ContainerMemoryPressureFull = "container_memory_pressure_full"
+	ContainerIOPressureSome     = "container_io_pressure_some"
+	ContainerIOPressureFull     = "container_io_pressure_full"
+)
+
+// Exposed for testing.
+var (
+	HostMetricTemplates = report.MetricTemplates{
+		HostCPUPressureSome:    {ID: HostCPUPressureSome, Label: "CPU Pressure", Format: report.PercentFormat, Group: "pressure", Priority: 10},
+		HostMemoryPressureSome: {ID: HostMemoryPressureSome, Label: "Memory Pressure", Format: report.PercentFormat, Group: "pressure", Priority: 11},
+		HostMemoryPressureFull: {ID: HostMemoryPressureFull, Label: "Memory Pressure (Full)", Format: report.PercentFormat, Group: "pressure", Priority: 12},
+		HostIOPressureSome:     {ID: HostIOPressureSome, Label: "IO Pressure", Format: report.PercentFormat, Group: "pressure", Priority: 13},
+		HostIOPressureFull:     {ID: HostIOPressureFull, Label: "IO Pressure (Full)", Format: report.PercentFormat, Group: "pressure", Priority: 14},
+	}
+
+	ContainerMetricTemplates = report.MetricTemplates{
+		ContainerCPUPressureSome:    {ID: ContainerCPUPressureSome, Label: "CPU Pressure", Format: report.PercentFormat, Group: "pressure", Priority: 10},
+		ContainerMemoryPressureSome: {ID: ContainerMemoryPressureSome, Label: "Memory Pressure", Format: report.PercentFormat, Group: "pressure", Priority: 11},
+		ContainerMemoryPressureFull: {ID: ContainerMemoryPressureFull, Label: "Memory Pressure (Full)", Format: report.PercentFormat, Group: "pressure", Priority: 12},
+		ContainerIOPressureSome:     {ID: ContainerIOPressureSome, Label: "IO Pressure", Format: report.PercentFormat, Group: "pressure", Priority: 13},
+		ContainerIOPressureFull:     {ID: ContainerIOPressureFull, Label: "IO Pressure (Full)", Format: report.PercentFormat, Group: "pressure", Priority: 14},
+	}
+
+	// The metrics of the pressure on each resource, some and full. Stalls
+	// of all the tasks on the CPU are left out: they can't happen to a whole
+	// host, and only newer kernels tell them of cgroups.
+	hostPressureKeys = map[string][2]string{
+		"cpu":    {HostCPUPressureSome, ""},
+		"memory": {HostMemoryPressureSome, HostMemoryPressureFull},
+		"io":     {HostIOPressureSome, HostIOPressureFull},
+	}
+	containerPressureKeys = map[string][2]string{
+		"cpu":    {ContainerCPUPressureSome, ""},
+		"memory": {ContainerMemoryPressureSome, ContainerMemoryPressureFull},
+		"io":     {ContainerIOPressureSome, ContainerIOPressureFull},
+	}
+)
+
+// Reporter generates Reports with the pressure stall information (PSI) of
+// the host, and tags containers with that of their cgroups, where the
+// unified (v2) hierarchy has it. Kernels without PSI, before 4.20 or
+// booted without it, leave the metrics out.
+type Reporter struct {
+	hostID   string
+	procRoot string
+}
+
+// NewReporter makes a new Reporter.
+func NewReporter(hostID, procRoot string) *Reporter {
+	return &Reporter{
+		hostID:   hostID,
+		procRoot: procRoot,
+	}
+}
+
+// Name of this reporter, for metrics gathering
+func (*Reporter) Name() string { return "Cgroup" }
+
+// Report implements Reporter.
+func (r *Reporter) Report() (report.Report, error) {
+	result := report.MakeReport()
+	metrics := pressureMetrics(filepath.Join(r.procRoot, "pressure"), "", hostPressureKeys)
+	if len(metrics) == 0 {
+		return result, nil
+	}
+	result.Host = result.Host.WithMetricTemplates(HostMetricTemplates)
+	result.Host.AddNode(report.MakeNode(report.MakeHostNodeID(r.hostID)).WithMetrics(metrics))
+	return result, nil
+}
+
+// Tag implements Tagger, finding the cgroups of containers through their
+// processes, so it must run after the taggers of the container runtimes.
+func (r *Reporter) Tag(rpt report.Report) (report.Report, error) {
+	pids := map[string]string{} // container node ID -> PID of one of its processes
+	for _, n := range rpt.Process.Nodes {
+		containers, ok := n.Parents.Lookup(report.Container)
+		if !ok || len(containers) == 0 {
+			continue
+		}
+		if pid, ok := n.Latest.Lookup(process.PID); ok {
+			pids[containers[0]] = pid
+		}
+	}
+
+	tagged := false
+	for id, pid := range pids {
+		n, ok := rpt.Container.Nodes[id]
+		if !ok {
+			continue
+		}
+		dir, err := Dir(r.procRoot, pid, "")
+		if err != nil {
+			continue
+		}
+		metrics := pressureMetrics(dir, ".pressure", containerPressureKeys)
+		if len(metrics) == 0 {
+			continue
+		}
+		rpt.Container.Nodes[id] = n.WithMetrics(metrics)
+		tagged = true
+	}
+	if tagged {
+		rpt.Container = rpt.Container.WithMetricTemplates(ContainerMetricTemplates)
+	}
+	return rpt, nil
+}
+
+// pressureMetrics reads the PSI files of the resources in dir, named after
+// them with the suffix.
+func pressureMetrics(dir, suffix string, keys map[string][2]string) report.Metrics {
+	now := mtime.Now()
+	metrics := report.Metrics{}
+	for _, resource := range Resources {
+		pressure, err := ReadPressure(filepath.Join(dir, resource+suffix))
+		if err != nil {
+			continue
+		}
+		some, full := keys[resource][0], keys[resource][1]
+		metrics[some] = report.MakeSingletonMetric(now, pressure.Some).WithMax(100)
+		if full != "" {
+			metrics[full] = report.MakeSingletonMetric(now, pressure.Full).WithMax(100)
+		}
+	}
+	return metrics
+}
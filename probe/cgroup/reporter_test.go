@@ -0,0 +1,112 @@
+package cgroup_test
+
+import (
+	"testing"
+	"time"
+
+	fs_hook "github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/common/test/fs"
+	"github.com/weaveworks/scope/probe/cgroup"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	hostCPUPressure = "some avg10=12.50 avg60=8.00 avg300=4.00 total=1289392\n"
+	pressure        = "some avg10=25.00 avg60=0.87 avg300=0.40 total=1289392\nfull avg10=5.00 avg60=0.00 avg300=0.00 total=0\n"
+)
+
+var mockFS = fs.Dir("",
+	fs.Dir("proc",
+		fs.Dir("pressure",
+			fs.File{FName: "cpu", FContents: hostCPUPressure},
+			fs.File{FName: "memory", FContents: pressure},
+			fs.File{FName: "io", FContents: pressure},
+		),
+		// A container on the unified hierarchy
+		fs.Dir("10", fs.File{FName: "cgroup", FContents: "0::/system.slice/docker-abc.scope\n"}),
+		// A container only on v1 hierarchies
+		fs.Dir("20", fs.File{FName: "cgroup", FContents: "4:memory:/docker/def\n3:cpu,cpuacct:/docker/def\n"}),
+		// Out of the cgroup namespace of the probe
+		fs.Dir("30", fs.File{FName: "cgroup", FContents: "0::/../docker-ghi.scope\n"}),
+	),
+	fs.Dir("sys", fs.Dir("fs", fs.Dir("cgroup",
+		fs.File{FName: "cgroup.controllers", FContents: "cpu io memory pids\n"},
+		fs.Dir("system.slice", fs.Dir("docker-abc.scope",
+			fs.File{FName: "cpu.pressure", FContents: pressure},
+			fs.File{FName: "memory.pressure", FContents: pressure},
+			fs.File{FName: "io.pressure", FContents: pressure},
+		)),
+	))),
+)
+
+func TestDir(t *testing.T) {
+	fs_hook.Mock(mockFS)
+	defer fs_hook.Restore()
+
+	for _, c := range []struct {
+		pid, controller, want string
+	}{
+		{"10", "", "/sys/fs/cgroup/system.slice/docker-abc.scope"},
+		{"20", "cpuacct", "/sys/fs/cgroup/cpu,cpuacct/docker/def"},
+		{"20", "memory", "/sys/fs/cgroup/memory/docker/def"},
+		{"20", "", ""},
+		{"30", "", ""},
+	} {
+		have, err := cgroup.Dir("/proc", c.pid, c.controller)
+		if have != c.want || (err == nil) != (c.want != "") {
+			t.Errorf("Expected the %q cgroup of %s at %q, got %q (%v)", c.controller, c.pid, c.want, have, err)
+		}
+	}
+}
+
+func TestReporter(t *testing.T) {
+	mtime.NowForce(time.Unix(1500000000, 0))
+	defer mtime.NowReset()
+	fs_hook.Mock(mockFS)
+	defer fs_hook.Restore()
+
+	reporter := cgroup.NewReporter("host1", "/proc")
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := rpt.Host.Nodes[report.MakeHostNodeID("host1")]
+	for key, want := range map[string]float64{
+		cgroup.HostCPUPressureSome:    12.5,
+		cgroup.HostMemoryPressureSome: 25,
+		cgroup.HostMemoryPressureFull: 5,
+		cgroup.HostIOPressureFull:     5,
+	} {
+		if s, ok := host.Metrics[key].LastSample(); !ok || s.Value != want {
+			t.Errorf("Expected %s of %v, got %v", key, want, s)
+		}
+	}
+
+	for pid, container := range map[string]string{"10": "abc", "20": "def", "30": "ghi"} {
+		containerID := report.MakeContainerNodeID(container)
+		rpt.Container.AddNode(report.MakeNode(containerID))
+		rpt.Process.AddNode(report.MakeNodeWith(report.MakeProcessNodeID("host1", pid), map[string]string{process.PID: pid}).
+			WithParents(report.MakeSets().Add(report.Container, report.MakeStringSet(containerID))))
+	}
+	rpt, err = reporter.Tag(rpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	abc := rpt.Container.Nodes[report.MakeContainerNodeID("abc")]
+	for key, want := range map[string]float64{
+		cgroup.ContainerCPUPressureSome:    25,
+		cgroup.ContainerMemoryPressureFull: 5,
+		cgroup.ContainerIOPressureSome:     25,
+	} {
+		if s, ok := abc.Metrics[key].LastSample(); !ok || s.Value != want {
+			t.Errorf("Expected %s of %v, got %v", key, want, s)
+		}
+	}
+	for _, container := range []string{"def", "ghi"} {
+		if metrics := rpt.Container.Nodes[report.MakeContainerNodeID(container)].Metrics; len(metrics) != 0 {
+			t.Errorf("Expected no pressure of container %s, got %v", container, metrics)
+		}
+	}
+}
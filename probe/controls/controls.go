@@ -3,6 +3,9 @@ package controls
 import (
 	"sync"
 
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
 	"github.com/weaveworks/scope/common/xfer"
 )
 
@@ -116,12 +119,23 @@ func (r *HandlerRegistry) Batch(toRemove []string, toAdd map[string]xfer.Control
 
 // HandleControlRequest performs a control request.
 func (r *HandlerRegistry) HandleControlRequest(req xfer.Request) xfer.Response {
+	tracer := opentracing.GlobalTracer()
+	parent, _ := tracer.Extract(opentracing.TextMap, opentracing.TextMapCarrier(req.Trace))
+	span := tracer.StartSpan("control "+req.Control, ext.RPCServerOption(parent))
+	defer span.Finish()
+	span.SetTag("node_id", req.NodeID)
+
 	h, ok := r.handler(req.Control)
 	if !ok {
+		ext.Error.Set(span, true)
 		return xfer.ResponseErrorf("Control %q not recognised", req.Control)
 	}
 
-	return h(req)
+	res := h(req)
+	if res.Error != "" {
+		ext.Error.Set(span, true)
+	}
+	return res
 }
 
 func (r *HandlerRegistry) handler(control string) (xfer.ControlHandlerFunc, bool) {
@@ -0,0 +1,140 @@
+package controls
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+)
+
+// Parameters of packet capture controls
+const (
+	PacketCaptureDuration = "duration"  // seconds
+	PacketCaptureFilter   = "filter"    // BPF, as tcpdump takes it
+	PacketCaptureMaxBytes = "max_bytes" // of the pcap
+
+	maxPacketCaptureDuration = 10 * time.Minute
+	maxPacketCaptureBytes    = 100 * 1024 * 1024
+	// packetCaptureGrace is how long after the end of a capture the pipe
+	// is kept for, if nobody reads it to the end.
+	packetCaptureGrace = time.Minute
+)
+
+// PacketCaptureParams are the parameters of packet capture controls. The
+// filter leaves out the traffic of the default port of the app, so the
+// probe does not capture the capture it sends.
+var PacketCaptureParams = []report.ControlParam{
+	{ID: PacketCaptureDuration, Human: "Duration (seconds)", Type: report.ControlParamInt, Default: "30"},
+	{ID: PacketCaptureFilter, Human: "Filter", Type: report.ControlParamString, Default: "not port 4040"},
+	{ID: PacketCaptureMaxBytes, Human: "Maximum bytes", Type: report.ControlParamInt, Default: "10485760"},
+}
+
+// packetCapture is what to capture, from the arguments of a request.
+type packetCapture struct {
+	duration time.Duration
+	filter   string
+	maxBytes int64
+}
+
+func parsePacketCapture(args map[string]string) (packetCapture, error) {
+	args, err := report.Control{Params: PacketCaptureParams}.Args(args)
+	if err != nil {
+		return packetCapture{}, err
+	}
+	seconds, _ := strconv.Atoi(args[PacketCaptureDuration])
+	maxBytes, _ := strconv.ParseInt(args[PacketCaptureMaxBytes], 10, 64)
+	c := packetCapture{
+		duration: time.Duration(seconds) * time.Second,
+		filter:   strings.TrimSpace(args[PacketCaptureFilter]),
+		maxBytes: maxBytes,
+	}
+	if c.duration <= 0 || c.duration > maxPacketCaptureDuration {
+		return c, fmt.Errorf("duration must be between 1 and %d seconds", int(maxPacketCaptureDuration.Seconds()))
+	}
+	// tcpdump runs as root; filters are no place for its options.
+	if strings.HasPrefix(c.filter, "-") {
+		return c, fmt.Errorf("invalid filter: %q", c.filter)
+	}
+	if c.maxBytes <= 0 || c.maxBytes > maxPacketCaptureBytes {
+		return c, fmt.Errorf("maximum bytes must be between 1 and %d", maxPacketCaptureBytes)
+	}
+	return c, nil
+}
+
+// TcpdumpCommand is the command capturing packets, as pcap on its
+// output. Tests fake it.
+var TcpdumpCommand = []string{"tcpdump"}
+
+// CapturePackets captures packets with tcpdump, in the network namespace of
+// the process pid (0 for that of the probe), for the duration of the
+// arguments of a request or until it has their maximum bytes, streaming the
+// pcap over a new pipe, to download as name.pcap. The pipe closes when the
+// capture ends; closing it stops the capture.
+func CapturePackets(c PipeClient, req xfer.Request, pid int, name string) xfer.Response {
+	capture, err := parsePacketCapture(req.ControlArgs)
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+	args := append([]string{}, TcpdumpCommand...)
+	if pid != 0 {
+		args = append([]string{"nsenter", "-t", strconv.Itoa(pid), "-n", "--"}, args...)
+	}
+	// Unbuffered, so packets are streamed as they are captured, of any
+	// size.
+	args = append(args, "-i", "any", "-U", "-s", "0", "-w", "-")
+	if capture.filter != "" {
+		args = append(args, "--", capture.filter)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), capture.duration)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return xfer.ResponseError(err)
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return xfer.ResponseError(err)
+	}
+
+	id, pipe, err := NewPipe(c, req.AppID)
+	if err != nil {
+		cancel()
+		cmd.Wait()
+		return xfer.ResponseError(err)
+	}
+	pipe.OnClose(cancel)
+	stop := time.AfterFunc(capture.duration+packetCaptureGrace, func() { pipe.Close() })
+	local, _ := pipe.Ends()
+	go func() {
+		// At the maximum bytes, the last packet may be cut short.
+		_, copyErr := io.CopyN(local, stdout, capture.maxBytes)
+		// Unless tcpdump exited by itself, it is killed.
+		exited := copyErr == io.EOF && ctx.Err() == nil
+		if copyErr != nil && copyErr != io.EOF {
+			log.Debugf("Error streaming packet capture of pipe %s: %v", id, copyErr)
+		}
+		cancel()
+		if err := cmd.Wait(); err != nil && exited {
+			log.Errorf("Error capturing packets (%s): %v", strings.TrimSpace(stderr.String()), err)
+		}
+		stop.Stop()
+		pipe.Close()
+	}()
+	return xfer.Response{
+		Pipe:     id,
+		Download: name + ".pcap",
+	}
+}
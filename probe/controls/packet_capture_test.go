@@ -0,0 +1,59 @@
+package controls_test
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+)
+
+func TestCapturePackets(t *testing.T) {
+	oldTcpdump := controls.TcpdumpCommand
+	defer func() { controls.TcpdumpCommand = oldTcpdump }()
+
+	capture := func(script string, args map[string]string) string {
+		controls.TcpdumpCommand = []string{"sh", "-c", script, "tcpdump"}
+		pipes := mockPipeClient{}
+		resp := controls.CapturePackets(pipes, xfer.Request{ControlArgs: args}, 0, "web")
+		if resp.Error != "" || resp.Pipe == "" || resp.Download != "web.pcap" {
+			t.Fatalf("Expected a pipe to download, got %v", resp)
+		}
+		_, remote := pipes[resp.Pipe].Ends()
+		// Until the pipe closes
+		pcap, _ := ioutil.ReadAll(remote)
+		return string(pcap)
+	}
+
+	// The pcap is tcpdump's output, here its arguments.
+	if have, want := capture(`printf '%s ' "$@"`, nil), "-i any -U -s 0 -w - -- not port 4040 "; have != want {
+		t.Errorf("Expected %q, got %q", want, have)
+	}
+
+	// Captures stop at their maximum bytes, and after their duration.
+	start := time.Now()
+	if have, want := capture("printf 0123456789; exec sleep 10", map[string]string{controls.PacketCaptureMaxBytes: "4"}), "0123"; have != want {
+		t.Errorf("Expected %q, got %q", want, have)
+	}
+	if have, want := capture("printf 0123456789; exec sleep 10", map[string]string{controls.PacketCaptureDuration: "1"}), "0123456789"; have != want {
+		t.Errorf("Expected %q, got %q", want, have)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected the captures to be stopped")
+	}
+
+	for _, args := range []map[string]string{
+		{controls.PacketCaptureDuration: "0"},
+		{controls.PacketCaptureDuration: "601"},
+		{controls.PacketCaptureDuration: "a minute"},
+		{controls.PacketCaptureMaxBytes: "0"},
+		{controls.PacketCaptureMaxBytes: "1000000000"},
+		{controls.PacketCaptureFilter: "-w/etc/cron.d/x"},
+		{controls.PacketCaptureFilter: " -Z root"},
+	} {
+		if resp := controls.CapturePackets(mockPipeClient{}, xfer.Request{ControlArgs: args}, 0, "web"); resp.Error == "" {
+			t.Errorf("Expected an error capturing packets with %v", args)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package controls
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+const (
+	// PortForwardPort is the parameter of port-forward controls: the port
+	// of the container or pod to forward to
+	PortForwardPort = "port"
+
+	portForwardDialTimeout = 5 * time.Second
+)
+
+// ForwardPort connects a new pipe, of the app of a request, to a TCP
+// connection to the port of its arguments at host. Each connection to the
+// forwarded port is a pipe.
+func ForwardPort(c PipeClient, req xfer.Request, host string) xfer.Response {
+	port, err := strconv.Atoi(req.ControlArgs[PortForwardPort])
+	if err != nil || port <= 0 || port > 65535 {
+		return xfer.ResponseErrorf("Invalid port: %q", req.ControlArgs[PortForwardPort])
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), portForwardDialTimeout)
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+	id, pipe, err := NewPipeFromEnds(nil, conn, c, req.AppID)
+	if err != nil {
+		conn.Close()
+		return xfer.ResponseError(err)
+	}
+	pipe.OnClose(func() {
+		conn.Close()
+	})
+	return xfer.Response{
+		Pipe: id,
+	}
+}
@@ -0,0 +1,64 @@
+package controls_test
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+)
+
+type mockPipeClient map[string]xfer.Pipe
+
+func (c mockPipeClient) PipeConnection(_, id string, pipe xfer.Pipe) error {
+	c[id] = pipe
+	return nil
+}
+
+func (c mockPipeClient) PipeClose(_, id string) error {
+	delete(c, id)
+	return nil
+}
+
+func TestForwardPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		conn.Write([]byte("echo " + line))
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	pipes := mockPipeClient{}
+	resp := controls.ForwardPort(pipes, xfer.Request{ControlArgs: map[string]string{controls.PortForwardPort: port}}, "127.0.0.1")
+	if resp.Error != "" || resp.Pipe == "" {
+		t.Fatalf("Expected a pipe, got %v", resp)
+	}
+	pipe := pipes[resp.Pipe]
+	_, remote := pipe.Ends()
+	if _, err := remote.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := bufio.NewReader(remote).ReadString('\n'); err != nil || line != "echo hello\n" {
+		t.Errorf("Expected the forwarded port to answer, got %q (%v)", line, err)
+	}
+	pipe.Close()
+	if _, err := remote.Write([]byte("hello again\n")); err == nil {
+		t.Errorf("Expected closing the pipe to close the connection")
+	}
+
+	for _, port := range []string{"", "http", "0", "65536"} {
+		if resp := controls.ForwardPort(pipes, xfer.Request{ControlArgs: map[string]string{controls.PortForwardPort: port}}, "127.0.0.1"); resp.Error == "" {
+			t.Errorf("Expected an error forwarding port %q", port)
+		}
+	}
+}
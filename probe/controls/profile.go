@@ -0,0 +1,56 @@
+package controls
+
+import (
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/common/profile"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+)
+
+// ProfileDuration is the parameter of CPU profiling controls: how many
+// seconds the profile is of
+const ProfileDuration = "duration"
+
+// ProfileCPUParams are the parameters of CPU profiling controls.
+var ProfileCPUParams = []report.ControlParam{
+	{ID: ProfileDuration, Human: "Duration (seconds)", Type: report.ControlParamInt, Default: strconv.Itoa(int(profile.DefaultDuration.Seconds()))},
+}
+
+// Profile takes a profile (profile.CPU or profile.Heap) of the probe,
+// streaming it over a new pipe, to download as a file of the component, e.g.
+// "probe-<host>". Closing the pipe stops CPU profiles early.
+func Profile(c PipeClient, req xfer.Request, kind, component string) xfer.Response {
+	var duration time.Duration
+	if kind == profile.CPU {
+		args, err := report.Control{Params: ProfileCPUParams}.Args(req.ControlArgs)
+		if err != nil {
+			return xfer.ResponseError(err)
+		}
+		seconds, _ := strconv.Atoi(args[ProfileDuration])
+		if duration = time.Duration(seconds) * time.Second; duration <= 0 || duration > profile.MaxDuration {
+			return xfer.ResponseErrorf("duration must be between 1 and %d seconds", int(profile.MaxDuration.Seconds()))
+		}
+	}
+	id, pipe, err := NewPipe(c, req.AppID)
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	pipe.OnClose(cancel)
+	local, _ := pipe.Ends()
+	go func() {
+		if err := profile.Write(ctx, local, kind, duration); err != nil {
+			log.Errorf("Error taking %s profile for pipe %s: %v", kind, id, err)
+		}
+		pipe.Close()
+	}()
+	return xfer.Response{
+		Pipe:     id,
+		Download: profile.Filename(component, kind, time.Now()),
+	}
+}
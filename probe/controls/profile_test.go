@@ -0,0 +1,32 @@
+package controls_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/scope/common/profile"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+)
+
+func TestProfile(t *testing.T) {
+	pipes := mockPipeClient{}
+	resp := controls.Profile(pipes, xfer.Request{}, profile.Heap, "probe-host1")
+	if resp.Error != "" || resp.Pipe == "" || !strings.HasPrefix(resp.Download, "scope-probe-host1-heap-") {
+		t.Fatalf("Expected a pipe to download, got %v", resp)
+	}
+	_, remote := pipes[resp.Pipe].Ends()
+	// Until the pipe closes
+	if buf, _ := ioutil.ReadAll(remote); !bytes.HasPrefix(buf, []byte{0x1f, 0x8b}) {
+		t.Errorf("Expected a profile, got %q", buf)
+	}
+
+	for _, duration := range []string{"0", "3600", "a minute"} {
+		args := map[string]string{controls.ProfileDuration: duration}
+		if resp := controls.Profile(pipes, xfer.Request{ControlArgs: args}, profile.CPU, "probe-host1"); resp.Error == "" {
+			t.Errorf("Expected an error profiling for %q", duration)
+		}
+	}
+}
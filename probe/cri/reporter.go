@@ -0,0 +1,320 @@
+package cri
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/ugorji/go/codec"
+	context "golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+// The container states, as the docker probe reports them, of the states of
+// CRI containers.
+var states = map[ContainerState]string{
+	ContainerCreated: docker.StateCreated,
+	ContainerRunning: docker.StateRunning,
+	ContainerExited:  docker.StateExited,
+	ContainerUnknown: "unknown",
+}
+
+// timeout is how long the runtime is given to answer all the calls of a
+// report.
+const timeout = 10 * time.Second
+
+type cpuSample struct {
+	timestamp time.Time
+	usage     uint64
+}
+
+// Reporter generates Reports containing the Container and ContainerImage
+// topologies of a container runtime speaking the Container Runtime
+// Interface, such as containerd or CRI-O, the same as the docker probe does
+// of the Docker Engine, but for the controls, which CRI runtimes leave to
+// the kubelet.
+type Reporter struct {
+	client  Client
+	hostID  string
+	cluster string // scoping the IPs of pods, if any
+	probeID string
+
+	mtx  sync.Mutex
+	cpu  map[string]cpuSample // container ID -> last CPU usage
+	pids map[int]string       // PID -> container ID, as of the last report
+}
+
+// NewReporter makes a new Reporter
+func NewReporter(client Client, hostID, cluster, probeID string) *Reporter {
+	return &Reporter{
+		client:  client,
+		hostID:  hostID,
+		cluster: cluster,
+		probeID: probeID,
+		cpu:     map[string]cpuSample{},
+		pids:    map[int]string{},
+	}
+}
+
+// Name of this reporter, for metrics gathering
+func (*Reporter) Name() string { return "CRI" }
+
+// Stop closes the connection to the runtime.
+func (r *Reporter) Stop() {
+	r.client.Close()
+}
+
+// sandbox is the network of the containers of a pod sandbox.
+type sandbox struct {
+	ip            string
+	inHostNetwork bool
+}
+
+// Report generates a Report containing Container and ContainerImage topologies
+func (r *Reporter) Report() (report.Report, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	result := report.MakeReport()
+
+	sandboxes, err := r.sandboxes(ctx)
+	if err != nil {
+		return result, err
+	}
+	images, err := r.client.ListImages(ctx)
+	if err != nil {
+		return result, err
+	}
+	imageIDs := map[string]string{} // image ID or digest -> image ID
+	for _, image := range images.Images {
+		id := trimImageID(image.Id)
+		imageIDs[image.Id], imageIDs[id] = id, id
+		for _, digest := range image.RepoDigests {
+			imageIDs[digest] = id
+		}
+	}
+	result.ContainerImage = result.ContainerImage.Merge(containerImageTopology(images.Images))
+
+	containerTopology, err := r.containerTopology(ctx, sandboxes, imageIDs)
+	if err != nil {
+		return result, err
+	}
+	result.Container = result.Container.Merge(containerTopology)
+	return result, nil
+}
+
+func (r *Reporter) sandboxes(ctx context.Context) (map[string]sandbox, error) {
+	list, err := r.client.ListPodSandbox(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]sandbox, len(list.Items))
+	for _, s := range list.Items {
+		resp, err := r.client.PodSandboxStatus(ctx, s.Id)
+		if err != nil || resp.Status == nil {
+			continue
+		}
+		var sb sandbox
+		if resp.Status.Network != nil {
+			sb.ip = resp.Status.Network.Ip
+		}
+		if l := resp.Status.Linux; l != nil && l.Namespaces != nil && l.Namespaces.Options != nil {
+			sb.inHostNetwork = l.Namespaces.Options.Network == NamespaceNode
+		}
+		result[s.Id] = sb
+	}
+	return result, nil
+}
+
+func (r *Reporter) containerTopology(ctx context.Context, sandboxes map[string]sandbox, imageIDs map[string]string) (report.Topology, error) {
+	result := report.MakeTopology().
+		WithMetadataTemplates(docker.ContainerMetadataTemplates).
+		WithMetricTemplates(docker.ContainerMetricTemplates).
+		WithTableTemplates(docker.ContainerTableTemplates)
+
+	containers, err := r.client.ListContainers(ctx)
+	if err != nil {
+		return result, err
+	}
+	stats, err := r.client.ListContainerStats(ctx)
+	if err != nil {
+		return result, err
+	}
+	metrics := r.metrics(stats.Stats)
+
+	pids := map[int]string{}
+	for _, c := range containers.Containers {
+		imageID, ok := imageIDs[c.ImageRef]
+		if !ok {
+			imageID = trimImageID(c.ImageRef)
+		}
+		latests := map[string]string{
+			docker.ContainerID:         c.Id,
+			docker.ContainerCreated:    time.Unix(0, c.CreatedAt).Format(time.RFC3339Nano),
+			docker.ContainerState:      states[c.State],
+			docker.ContainerStateHuman: states[c.State],
+			docker.ImageID:             imageID,
+			report.ControlProbeID:      r.probeID,
+		}
+		if c.Metadata != nil {
+			latests[docker.ContainerName] = c.Metadata.Name
+		}
+		if c.State == ContainerRunning {
+			if status, err := r.client.ContainerStatus(ctx, c.Id); err == nil {
+				if s := status.Status; s != nil && s.StartedAt > 0 {
+					uptime := mtime.Now().Sub(time.Unix(0, s.StartedAt)) / time.Second
+					latests[docker.ContainerUptime] = strconv.Itoa(int(uptime))
+					latests[docker.ContainerStateHuman] = "Up " + strings.TrimSpace(humanize.RelTime(time.Unix(0, s.StartedAt), mtime.Now(), "", ""))
+				}
+				if pid, ok := containerPID(status.Info); ok {
+					pids[pid] = c.Id
+				}
+			}
+			if c.Metadata != nil {
+				latests[docker.ContainerRestartCount] = strconv.Itoa(int(c.Metadata.Attempt))
+			}
+		}
+
+		node := report.MakeNodeWith(report.MakeContainerNodeID(c.Id), latests).
+			WithParents(report.MakeSets().
+				Add(report.ContainerImage, report.MakeStringSet(report.MakeContainerImageNodeID(imageID)))).
+			AddPrefixPropertyList(docker.LabelPrefix, c.Labels)
+		if m, ok := metrics[c.Id]; ok {
+			node = node.WithMetrics(m)
+		}
+		if sb, ok := sandboxes[c.PodSandboxId]; ok {
+			if sb.inHostNetwork {
+				node = node.WithLatests(map[string]string{docker.IsInHostNetwork: "true"})
+			} else if sb.ip != "" {
+				node = node.WithSets(report.MakeSets().
+					Add(docker.ContainerIPs, report.MakeStringSet(sb.ip)).
+					Add(docker.ContainerIPsWithScopes, report.MakeStringSet(report.MakeClusterAddressNodeID(r.cluster, r.hostID, sb.ip))))
+			}
+		}
+		result.AddNode(node)
+	}
+
+	r.mtx.Lock()
+	r.pids = pids
+	r.mtx.Unlock()
+	return result, nil
+}
+
+// metrics returns the memory and CPU usage of containers. Since the runtime
+// reports the CPU time used cumulatively, the usage is as a percentage of
+// the host since the last time it was reported, and so only from the second
+// report on.
+func (r *Reporter) metrics(stats []*ContainerStats) map[string]report.Metrics {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	result := map[string]report.Metrics{}
+	cpu := map[string]cpuSample{}
+	for _, s := range stats {
+		if s.Attributes == nil {
+			continue
+		}
+		id, metrics := s.Attributes.Id, report.Metrics{}
+		if m := s.Memory; m != nil && m.WorkingSetBytes != nil {
+			metrics[docker.MemoryUsage] = report.MakeSingletonMetric(time.Unix(0, m.Timestamp), float64(m.WorkingSetBytes.Value))
+		}
+		if c := s.Cpu; c != nil && c.UsageCoreNanoSeconds != nil {
+			sample := cpuSample{timestamp: time.Unix(0, c.Timestamp), usage: c.UsageCoreNanoSeconds.Value}
+			cpu[id] = sample
+			if previous, ok := r.cpu[id]; ok && sample.timestamp.After(previous.timestamp) && sample.usage >= previous.usage {
+				elapsed := float64(sample.timestamp.Sub(previous.timestamp)) * float64(runtime.NumCPU())
+				percent := float64(sample.usage-previous.usage) / elapsed * 100
+				metrics[docker.CPUTotalUsage] = report.MakeSingletonMetric(sample.timestamp, percent).WithMax(100)
+			}
+		}
+		result[id] = metrics
+	}
+	r.cpu = cpu
+	return result
+}
+
+// Tag implements Tagger, tagging the processes of the containers, and their
+// children, with them.
+func (r *Reporter) Tag(rpt report.Report) (report.Report, error) {
+	r.mtx.Lock()
+	pids := r.pids
+	r.mtx.Unlock()
+
+	for id, n := range rpt.Process.Nodes {
+		var (
+			containerID string
+			found       bool
+			seen        = map[string]struct{}{}
+		)
+		for p := n; !found; {
+			pid, ok := p.Latest.Lookup(process.PID)
+			if !ok {
+				break
+			}
+			if _, ok := seen[pid]; ok {
+				break
+			}
+			seen[pid] = struct{}{}
+			if i, err := strconv.Atoi(pid); err == nil {
+				containerID, found = pids[i]
+			}
+			ppid, ok := p.Latest.Lookup(process.PPID)
+			if !ok {
+				break
+			}
+			p = rpt.Process.Nodes[report.MakeProcessNodeID(r.hostID, ppid)]
+		}
+		if !found {
+			continue
+		}
+		rpt.Process.Nodes[id] = n.WithLatests(map[string]string{
+			docker.ContainerID: containerID,
+		}).WithParents(n.Parents.
+			Add(report.Container, report.MakeStringSet(report.MakeContainerNodeID(containerID))))
+	}
+	return rpt, nil
+}
+
+func containerImageTopology(images []*Image) report.Topology {
+	result := report.MakeTopology().
+		WithMetadataTemplates(docker.ContainerImageMetadataTemplates).
+		WithTableTemplates(docker.ContainerImageTableTemplates)
+	for _, image := range images {
+		imageID := trimImageID(image.Id)
+		latests := map[string]string{
+			docker.ImageID:   imageID,
+			docker.ImageSize: humanize.Bytes(image.Size_),
+		}
+		if len(image.RepoTags) > 0 {
+			latests[docker.ImageName] = image.RepoTags[0]
+		}
+		result.AddNode(report.MakeNodeWith(report.MakeContainerImageNodeID(imageID), latests))
+	}
+	return result
+}
+
+// containerPID returns the PID of the init process of a container, from the
+// verbose information containerd and CRI-O give about it.
+func containerPID(info map[string]string) (int, bool) {
+	encoded, ok := info["info"]
+	if !ok {
+		return 0, false
+	}
+	var decoded struct {
+		Pid int `json:"pid"`
+	}
+	if err := codec.NewDecoderBytes([]byte(encoded), &codec.JsonHandle{}).Decode(&decoded); err != nil || decoded.Pid <= 0 {
+		return 0, false
+	}
+	return decoded.Pid, true
+}
+
+// The runtimes prefix image IDs with their digest algorithm, as docker does.
+func trimImageID(id string) string {
+	return strings.TrimPrefix(id, "sha256:")
+}
@@ -0,0 +1,197 @@
+package cri_test
+
+import (
+	"testing"
+	"time"
+
+	proto "github.com/golang/protobuf/proto"
+	context "golang.org/x/net/context"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/probe/cri"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+var (
+	now     = time.Unix(1500000000, 0)
+	started = now.Add(-time.Hour)
+)
+
+type mockClient struct {
+	cpu uint64
+}
+
+func (c *mockClient) Version(context.Context) (*cri.VersionResponse, error) {
+	return &cri.VersionResponse{RuntimeName: "containerd"}, nil
+}
+
+func (c *mockClient) ListPodSandbox(context.Context) (*cri.ListPodSandboxResponse, error) {
+	return &cri.ListPodSandboxResponse{Items: []*cri.PodSandbox{{Id: "sandbox1"}, {Id: "sandbox2"}}}, nil
+}
+
+func (c *mockClient) PodSandboxStatus(_ context.Context, id string) (*cri.PodSandboxStatusResponse, error) {
+	if id == "sandbox2" {
+		return &cri.PodSandboxStatusResponse{Status: &cri.PodSandboxStatus{Id: id, Linux: &cri.LinuxPodSandboxStatus{
+			Namespaces: &cri.Namespace{Options: &cri.NamespaceOption{Network: cri.NamespaceNode}},
+		}}}, nil
+	}
+	return &cri.PodSandboxStatusResponse{Status: &cri.PodSandboxStatus{Id: id, Network: &cri.PodSandboxNetworkStatus{Ip: "10.0.0.1"}}}, nil
+}
+
+func (c *mockClient) ListContainers(context.Context) (*cri.ListContainersResponse, error) {
+	return &cri.ListContainersResponse{Containers: []*cri.Container{
+		{
+			Id:           "app",
+			PodSandboxId: "sandbox1",
+			Metadata:     &cri.ContainerMetadata{Name: "nginx", Attempt: 2},
+			ImageRef:     "sha256:abcdef",
+			State:        cri.ContainerRunning,
+			CreatedAt:    started.UnixNano(),
+			Labels:       map[string]string{"io.kubernetes.pod.uid": "pod1"},
+		},
+		{
+			Id:           "agent",
+			PodSandboxId: "sandbox2",
+			Metadata:     &cri.ContainerMetadata{Name: "agent"},
+			ImageRef:     "docker.io/library/agent@sha256:123456",
+			State:        cri.ContainerExited,
+			CreatedAt:    started.UnixNano(),
+		},
+	}}, nil
+}
+
+func (c *mockClient) ContainerStatus(_ context.Context, id string) (*cri.ContainerStatusResponse, error) {
+	return &cri.ContainerStatusResponse{
+		Status: &cri.ContainerStatus{Id: id, StartedAt: started.UnixNano()},
+		Info:   map[string]string{"info": `{"pid": 100, "sandboxID": "sandbox1"}`},
+	}, nil
+}
+
+func (c *mockClient) ListContainerStats(context.Context) (*cri.ListContainerStatsResponse, error) {
+	c.cpu += uint64(10 * time.Second)
+	return &cri.ListContainerStatsResponse{Stats: []*cri.ContainerStats{{
+		Attributes: &cri.ContainerAttributes{Id: "app"},
+		Cpu:        &cri.CpuUsage{Timestamp: mtime.Now().UnixNano(), UsageCoreNanoSeconds: &cri.UInt64Value{Value: c.cpu}},
+		Memory:     &cri.MemoryUsage{Timestamp: mtime.Now().UnixNano(), WorkingSetBytes: &cri.UInt64Value{Value: 1024}},
+	}}}, nil
+}
+
+func (c *mockClient) ListImages(context.Context) (*cri.ListImagesResponse, error) {
+	return &cri.ListImagesResponse{Images: []*cri.Image{
+		{Id: "sha256:abcdef", RepoTags: []string{"nginx:1.15"}, Size_: 1000},
+		{Id: "sha256:123456", RepoTags: []string{"agent:latest"}, RepoDigests: []string{"docker.io/library/agent@sha256:123456"}},
+	}}, nil
+}
+
+func (c *mockClient) Close() error { return nil }
+
+func TestReporter(t *testing.T) {
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	reporter := cri.NewReporter(&mockClient{}, "host1", "", "probe1")
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	app, ok := rpt.Container.Nodes[report.MakeContainerNodeID("app")]
+	if !ok {
+		t.Fatalf("Expected the running container to be reported, got %v", rpt.Container.Nodes)
+	}
+	for key, want := range map[string]string{
+		docker.ContainerID:                           "app",
+		docker.ContainerName:                         "nginx",
+		docker.ContainerState:                        docker.StateRunning,
+		docker.ContainerUptime:                       "3600",
+		docker.ContainerRestartCount:                 "2",
+		docker.ImageID:                               "abcdef",
+		docker.LabelPrefix + "io.kubernetes.pod.uid": "pod1",
+		report.ControlProbeID:                        "probe1",
+	} {
+		if have, ok := app.Latest.Lookup(key); !ok || have != want {
+			t.Errorf("Expected %s %q, got %q", key, want, have)
+		}
+	}
+	if have, _ := app.Sets.Lookup(docker.ContainerIPs); !reflect.DeepEqual(report.MakeStringSet("10.0.0.1"), have) {
+		t.Errorf("Expected the IP of the sandbox, got %v", have)
+	}
+	if have, _ := app.Parents.Lookup(report.ContainerImage); !reflect.DeepEqual(report.MakeStringSet(report.MakeContainerImageNodeID("abcdef")), have) {
+		t.Errorf("Expected the image as parent, got %v", have)
+	}
+	if _, ok := app.Metrics.Lookup(docker.CPUTotalUsage); ok {
+		t.Errorf("Expected no CPU usage from the first report")
+	}
+	if s, ok := app.Metrics[docker.MemoryUsage].LastSample(); !ok || s.Value != 1024 {
+		t.Errorf("Expected the memory usage, got %v", s)
+	}
+
+	agent := rpt.Container.Nodes[report.MakeContainerNodeID("agent")]
+	if have, _ := agent.Latest.Lookup(docker.ImageID); have != "123456" {
+		t.Errorf("Expected the image of the digest, got %q", have)
+	}
+	if _, ok := agent.Latest.Lookup(docker.IsInHostNetwork); !ok {
+		t.Errorf("Expected the container to be in the host network")
+	}
+
+	if have, _ := rpt.ContainerImage.Nodes[report.MakeContainerImageNodeID("abcdef")].Latest.Lookup(docker.ImageName); have != "nginx:1.15" {
+		t.Errorf("Expected the name of the image, got %q", have)
+	}
+
+	mtime.NowForce(now.Add(20 * time.Second))
+	rpt, err = reporter.Report()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := rpt.Container.Nodes[report.MakeContainerNodeID("app")].Metrics.Lookup(docker.CPUTotalUsage); !ok {
+		t.Errorf("Expected the CPU usage from the second report")
+	}
+}
+
+func TestTagger(t *testing.T) {
+	reporter := cri.NewReporter(&mockClient{}, "host1", "", "probe1")
+	if _, err := reporter.Report(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	rpt := report.MakeReport()
+	for pid, ppid := range map[string]string{"100": "1", "101": "100", "200": "1"} {
+		rpt.Process.AddNode(report.MakeNodeWith(report.MakeProcessNodeID("host1", pid), map[string]string{
+			process.PID:  pid,
+			process.PPID: ppid,
+		}))
+	}
+	rpt, err := reporter.Tag(rpt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for pid, want := range map[string]bool{"100": true, "101": true, "200": false} {
+		have, _ := rpt.Process.Nodes[report.MakeProcessNodeID("host1", pid)].Parents.Lookup(report.Container)
+		if want != reflect.DeepEqual(report.MakeStringSet(report.MakeContainerNodeID("app")), have) {
+			t.Errorf("Expected process %s in the container: %v, got %v", pid, want, have)
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	// As encoded by k8s.io/cri-api
+	want := []byte{
+		0x0a, 0x01, 'a', // id
+		0x1a, 0x05, 0x0a, 0x01, 'n', 0x10, 0x02, // metadata
+		0x30, 0x02, // state
+	}
+	have, err := proto.Marshal(&cri.Container{
+		Id:       "a",
+		Metadata: &cri.ContainerMetadata{Name: "n", Attempt: 2},
+		State:    cri.ContainerExited,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("Expected %x, got %x", want, have)
+	}
+}
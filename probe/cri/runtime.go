@@ -0,0 +1,415 @@
+package cri
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	proto "github.com/golang/protobuf/proto"
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// The messages below are the subset of the Container Runtime Interface
+// (k8s.io/cri-api, api.proto) the probe uses, with the same field numbers,
+// so as not to depend on the kubelet for them. The messages of the v1 and
+// v1alpha2 versions of the API are the same; only the service names differ.
+
+// Services of the versions of the API, most recent first.
+var apiVersions = []string{"runtime.v1", "runtime.v1alpha2"}
+
+// ContainerState is the state of a container.
+type ContainerState int32
+
+// The states of containers.
+const (
+	ContainerCreated ContainerState = 0
+	ContainerRunning ContainerState = 1
+	ContainerExited  ContainerState = 2
+	ContainerUnknown ContainerState = 3
+)
+
+// NamespaceMode is whose Linux namespace a pod or container is in.
+type NamespaceMode int32
+
+// NamespaceNode is the mode of the pods in the namespaces of the node.
+const NamespaceNode NamespaceMode = 2
+
+// VersionRequest asks for the version of the runtime.
+type VersionRequest struct {
+	Version string `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+}
+
+func (m *VersionRequest) Reset()         { *m = VersionRequest{} }
+func (m *VersionRequest) String() string { return proto.CompactTextString(m) }
+func (*VersionRequest) ProtoMessage()    {}
+
+// VersionResponse is the version of the runtime.
+type VersionResponse struct {
+	Version           string `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	RuntimeName       string `protobuf:"bytes,2,opt,name=runtime_name,json=runtimeName,proto3" json:"runtime_name,omitempty"`
+	RuntimeVersion    string `protobuf:"bytes,3,opt,name=runtime_version,json=runtimeVersion,proto3" json:"runtime_version,omitempty"`
+	RuntimeApiVersion string `protobuf:"bytes,4,opt,name=runtime_api_version,json=runtimeApiVersion,proto3" json:"runtime_api_version,omitempty"`
+}
+
+func (m *VersionResponse) Reset()         { *m = VersionResponse{} }
+func (m *VersionResponse) String() string { return proto.CompactTextString(m) }
+func (*VersionResponse) ProtoMessage()    {}
+
+// ListPodSandboxRequest lists the pod sandboxes.
+type ListPodSandboxRequest struct{}
+
+func (m *ListPodSandboxRequest) Reset()         { *m = ListPodSandboxRequest{} }
+func (m *ListPodSandboxRequest) String() string { return proto.CompactTextString(m) }
+func (*ListPodSandboxRequest) ProtoMessage()    {}
+
+// ListPodSandboxResponse is the pod sandboxes.
+type ListPodSandboxResponse struct {
+	Items []*PodSandbox `protobuf:"bytes,1,rep,name=items" json:"items,omitempty"`
+}
+
+func (m *ListPodSandboxResponse) Reset()         { *m = ListPodSandboxResponse{} }
+func (m *ListPodSandboxResponse) String() string { return proto.CompactTextString(m) }
+func (*ListPodSandboxResponse) ProtoMessage()    {}
+
+// PodSandbox is a pod sandbox, the environment the containers of a pod share.
+type PodSandbox struct {
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (m *PodSandbox) Reset()         { *m = PodSandbox{} }
+func (m *PodSandbox) String() string { return proto.CompactTextString(m) }
+func (*PodSandbox) ProtoMessage()    {}
+
+// PodSandboxStatusRequest asks for the status of a pod sandbox.
+type PodSandboxStatusRequest struct {
+	PodSandboxId string `protobuf:"bytes,1,opt,name=pod_sandbox_id,json=podSandboxId,proto3" json:"pod_sandbox_id,omitempty"`
+}
+
+func (m *PodSandboxStatusRequest) Reset()         { *m = PodSandboxStatusRequest{} }
+func (m *PodSandboxStatusRequest) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxStatusRequest) ProtoMessage()    {}
+
+// PodSandboxStatusResponse is the status of a pod sandbox.
+type PodSandboxStatusResponse struct {
+	Status *PodSandboxStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+}
+
+func (m *PodSandboxStatusResponse) Reset()         { *m = PodSandboxStatusResponse{} }
+func (m *PodSandboxStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxStatusResponse) ProtoMessage()    {}
+
+// PodSandboxStatus is the status of a pod sandbox.
+type PodSandboxStatus struct {
+	Id      string                   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Network *PodSandboxNetworkStatus `protobuf:"bytes,5,opt,name=network" json:"network,omitempty"`
+	Linux   *LinuxPodSandboxStatus   `protobuf:"bytes,6,opt,name=linux" json:"linux,omitempty"`
+}
+
+func (m *PodSandboxStatus) Reset()         { *m = PodSandboxStatus{} }
+func (m *PodSandboxStatus) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxStatus) ProtoMessage()    {}
+
+// PodSandboxNetworkStatus is the network of a pod sandbox.
+type PodSandboxNetworkStatus struct {
+	Ip string `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+}
+
+func (m *PodSandboxNetworkStatus) Reset()         { *m = PodSandboxNetworkStatus{} }
+func (m *PodSandboxNetworkStatus) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxNetworkStatus) ProtoMessage()    {}
+
+// LinuxPodSandboxStatus is the Linux specific status of a pod sandbox.
+type LinuxPodSandboxStatus struct {
+	Namespaces *Namespace `protobuf:"bytes,1,opt,name=namespaces" json:"namespaces,omitempty"`
+}
+
+func (m *LinuxPodSandboxStatus) Reset()         { *m = LinuxPodSandboxStatus{} }
+func (m *LinuxPodSandboxStatus) String() string { return proto.CompactTextString(m) }
+func (*LinuxPodSandboxStatus) ProtoMessage()    {}
+
+// Namespace is the Linux namespaces of a pod sandbox.
+type Namespace struct {
+	Options *NamespaceOption `protobuf:"bytes,2,opt,name=options" json:"options,omitempty"`
+}
+
+func (m *Namespace) Reset()         { *m = Namespace{} }
+func (m *Namespace) String() string { return proto.CompactTextString(m) }
+func (*Namespace) ProtoMessage()    {}
+
+// NamespaceOption is whose Linux namespaces a pod sandbox is in.
+type NamespaceOption struct {
+	Network NamespaceMode `protobuf:"varint,1,opt,name=network,proto3,enum=runtime.v1alpha2.NamespaceMode" json:"network,omitempty"`
+}
+
+func (m *NamespaceOption) Reset()         { *m = NamespaceOption{} }
+func (m *NamespaceOption) String() string { return proto.CompactTextString(m) }
+func (*NamespaceOption) ProtoMessage()    {}
+
+// ListContainersRequest lists the containers.
+type ListContainersRequest struct{}
+
+func (m *ListContainersRequest) Reset()         { *m = ListContainersRequest{} }
+func (m *ListContainersRequest) String() string { return proto.CompactTextString(m) }
+func (*ListContainersRequest) ProtoMessage()    {}
+
+// ListContainersResponse is the containers.
+type ListContainersResponse struct {
+	Containers []*Container `protobuf:"bytes,1,rep,name=containers" json:"containers,omitempty"`
+}
+
+func (m *ListContainersResponse) Reset()         { *m = ListContainersResponse{} }
+func (m *ListContainersResponse) String() string { return proto.CompactTextString(m) }
+func (*ListContainersResponse) ProtoMessage()    {}
+
+// Container is a container.
+type Container struct {
+	Id           string             `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	PodSandboxId string             `protobuf:"bytes,2,opt,name=pod_sandbox_id,json=podSandboxId,proto3" json:"pod_sandbox_id,omitempty"`
+	Metadata     *ContainerMetadata `protobuf:"bytes,3,opt,name=metadata" json:"metadata,omitempty"`
+	Image        *ImageSpec         `protobuf:"bytes,4,opt,name=image" json:"image,omitempty"`
+	ImageRef     string             `protobuf:"bytes,5,opt,name=image_ref,json=imageRef,proto3" json:"image_ref,omitempty"`
+	State        ContainerState     `protobuf:"varint,6,opt,name=state,proto3,enum=runtime.v1alpha2.ContainerState" json:"state,omitempty"`
+	CreatedAt    int64              `protobuf:"varint,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Labels       map[string]string  `protobuf:"bytes,8,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Annotations  map[string]string  `protobuf:"bytes,9,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *Container) Reset()         { *m = Container{} }
+func (m *Container) String() string { return proto.CompactTextString(m) }
+func (*Container) ProtoMessage()    {}
+
+// ContainerMetadata is the name of a container in its pod.
+type ContainerMetadata struct {
+	Name    string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Attempt uint32 `protobuf:"varint,2,opt,name=attempt,proto3" json:"attempt,omitempty"`
+}
+
+func (m *ContainerMetadata) Reset()         { *m = ContainerMetadata{} }
+func (m *ContainerMetadata) String() string { return proto.CompactTextString(m) }
+func (*ContainerMetadata) ProtoMessage()    {}
+
+// ImageSpec is an image.
+type ImageSpec struct {
+	Image string `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`
+}
+
+func (m *ImageSpec) Reset()         { *m = ImageSpec{} }
+func (m *ImageSpec) String() string { return proto.CompactTextString(m) }
+func (*ImageSpec) ProtoMessage()    {}
+
+// ContainerStatusRequest asks for the status of a container.
+type ContainerStatusRequest struct {
+	ContainerId string `protobuf:"bytes,1,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	Verbose     bool   `protobuf:"varint,2,opt,name=verbose,proto3" json:"verbose,omitempty"`
+}
+
+func (m *ContainerStatusRequest) Reset()         { *m = ContainerStatusRequest{} }
+func (m *ContainerStatusRequest) String() string { return proto.CompactTextString(m) }
+func (*ContainerStatusRequest) ProtoMessage()    {}
+
+// ContainerStatusResponse is the status of a container and, when asked to be
+// verbose, runtime specific information about it.
+type ContainerStatusResponse struct {
+	Status *ContainerStatus  `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Info   map[string]string `protobuf:"bytes,2,rep,name=info" json:"info,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *ContainerStatusResponse) Reset()         { *m = ContainerStatusResponse{} }
+func (m *ContainerStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*ContainerStatusResponse) ProtoMessage()    {}
+
+// ContainerStatus is the status of a container.
+type ContainerStatus struct {
+	Id         string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	StartedAt  int64  `protobuf:"varint,5,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	FinishedAt int64  `protobuf:"varint,6,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
+	ExitCode   int32  `protobuf:"varint,7,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+}
+
+func (m *ContainerStatus) Reset()         { *m = ContainerStatus{} }
+func (m *ContainerStatus) String() string { return proto.CompactTextString(m) }
+func (*ContainerStatus) ProtoMessage()    {}
+
+// ListContainerStatsRequest lists the stats of the containers.
+type ListContainerStatsRequest struct{}
+
+func (m *ListContainerStatsRequest) Reset()         { *m = ListContainerStatsRequest{} }
+func (m *ListContainerStatsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListContainerStatsRequest) ProtoMessage()    {}
+
+// ListContainerStatsResponse is the stats of the containers.
+type ListContainerStatsResponse struct {
+	Stats []*ContainerStats `protobuf:"bytes,1,rep,name=stats" json:"stats,omitempty"`
+}
+
+func (m *ListContainerStatsResponse) Reset()         { *m = ListContainerStatsResponse{} }
+func (m *ListContainerStatsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListContainerStatsResponse) ProtoMessage()    {}
+
+// ContainerStats is the usage of resources by a container.
+type ContainerStats struct {
+	Attributes *ContainerAttributes `protobuf:"bytes,1,opt,name=attributes" json:"attributes,omitempty"`
+	Cpu        *CpuUsage            `protobuf:"bytes,2,opt,name=cpu" json:"cpu,omitempty"`
+	Memory     *MemoryUsage         `protobuf:"bytes,3,opt,name=memory" json:"memory,omitempty"`
+}
+
+func (m *ContainerStats) Reset()         { *m = ContainerStats{} }
+func (m *ContainerStats) String() string { return proto.CompactTextString(m) }
+func (*ContainerStats) ProtoMessage()    {}
+
+// ContainerAttributes is which container stats are of.
+type ContainerAttributes struct {
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (m *ContainerAttributes) Reset()         { *m = ContainerAttributes{} }
+func (m *ContainerAttributes) String() string { return proto.CompactTextString(m) }
+func (*ContainerAttributes) ProtoMessage()    {}
+
+// CpuUsage is the CPU time used by a container, cumulatively.
+type CpuUsage struct {
+	Timestamp            int64        `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	UsageCoreNanoSeconds *UInt64Value `protobuf:"bytes,2,opt,name=usage_core_nano_seconds,json=usageCoreNanoSeconds" json:"usage_core_nano_seconds,omitempty"`
+}
+
+func (m *CpuUsage) Reset()         { *m = CpuUsage{} }
+func (m *CpuUsage) String() string { return proto.CompactTextString(m) }
+func (*CpuUsage) ProtoMessage()    {}
+
+// MemoryUsage is the memory used by a container.
+type MemoryUsage struct {
+	Timestamp       int64        `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	WorkingSetBytes *UInt64Value `protobuf:"bytes,2,opt,name=working_set_bytes,json=workingSetBytes" json:"working_set_bytes,omitempty"`
+}
+
+func (m *MemoryUsage) Reset()         { *m = MemoryUsage{} }
+func (m *MemoryUsage) String() string { return proto.CompactTextString(m) }
+func (*MemoryUsage) ProtoMessage()    {}
+
+// UInt64Value is an optional uint64.
+type UInt64Value struct {
+	Value uint64 `protobuf:"varint,1,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *UInt64Value) Reset()         { *m = UInt64Value{} }
+func (m *UInt64Value) String() string { return proto.CompactTextString(m) }
+func (*UInt64Value) ProtoMessage()    {}
+
+// ListImagesRequest lists the images.
+type ListImagesRequest struct{}
+
+func (m *ListImagesRequest) Reset()         { *m = ListImagesRequest{} }
+func (m *ListImagesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListImagesRequest) ProtoMessage()    {}
+
+// ListImagesResponse is the images.
+type ListImagesResponse struct {
+	Images []*Image `protobuf:"bytes,1,rep,name=images" json:"images,omitempty"`
+}
+
+func (m *ListImagesResponse) Reset()         { *m = ListImagesResponse{} }
+func (m *ListImagesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListImagesResponse) ProtoMessage()    {}
+
+// Image is an image.
+type Image struct {
+	Id          string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	RepoTags    []string `protobuf:"bytes,2,rep,name=repo_tags,json=repoTags" json:"repo_tags,omitempty"`
+	RepoDigests []string `protobuf:"bytes,3,rep,name=repo_digests,json=repoDigests" json:"repo_digests,omitempty"`
+	Size_       uint64   `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+}
+
+func (m *Image) Reset()         { *m = Image{} }
+func (m *Image) String() string { return proto.CompactTextString(m) }
+func (*Image) ProtoMessage()    {}
+
+// Client is a client of the runtime and image services of a container
+// runtime.
+type Client interface {
+	Version(ctx context.Context) (*VersionResponse, error)
+	ListPodSandbox(ctx context.Context) (*ListPodSandboxResponse, error)
+	PodSandboxStatus(ctx context.Context, id string) (*PodSandboxStatusResponse, error)
+	ListContainers(ctx context.Context) (*ListContainersResponse, error)
+	ContainerStatus(ctx context.Context, id string) (*ContainerStatusResponse, error)
+	ListContainerStats(ctx context.Context) (*ListContainerStatsResponse, error)
+	ListImages(ctx context.Context) (*ListImagesResponse, error)
+	Close() error
+}
+
+type client struct {
+	cc      *grpc.ClientConn
+	version string
+}
+
+// NewClient connects to the CRI socket of a container runtime, e.g.
+// unix:///run/containerd/containerd.sock or unix:///var/run/crio/crio.sock,
+// with the most recent version of the API the runtime serves.
+func NewClient(endpoint string) (Client, error) {
+	cc, err := grpc.Dial(
+		strings.TrimPrefix(endpoint, "unix://"),
+		grpc.WithInsecure(),
+		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", addr, timeout)
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	c := &client{cc: cc}
+	for _, version := range apiVersions {
+		c.version = version
+		if _, err = c.Version(context.Background()); grpc.Code(err) != codes.Unimplemented {
+			break
+		}
+	}
+	if err != nil {
+		cc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *client) invoke(ctx context.Context, service, method string, in, out interface{}) error {
+	return grpc.Invoke(ctx, "/"+c.version+"."+service+"/"+method, in, out, c.cc)
+}
+
+func (c *client) Version(ctx context.Context) (*VersionResponse, error) {
+	out := new(VersionResponse)
+	return out, c.invoke(ctx, "RuntimeService", "Version", &VersionRequest{Version: "v1"}, out)
+}
+
+func (c *client) ListPodSandbox(ctx context.Context) (*ListPodSandboxResponse, error) {
+	out := new(ListPodSandboxResponse)
+	return out, c.invoke(ctx, "RuntimeService", "ListPodSandbox", &ListPodSandboxRequest{}, out)
+}
+
+func (c *client) PodSandboxStatus(ctx context.Context, id string) (*PodSandboxStatusResponse, error) {
+	out := new(PodSandboxStatusResponse)
+	return out, c.invoke(ctx, "RuntimeService", "PodSandboxStatus", &PodSandboxStatusRequest{PodSandboxId: id}, out)
+}
+
+func (c *client) ListContainers(ctx context.Context) (*ListContainersResponse, error) {
+	out := new(ListContainersResponse)
+	return out, c.invoke(ctx, "RuntimeService", "ListContainers", &ListContainersRequest{}, out)
+}
+
+func (c *client) ContainerStatus(ctx context.Context, id string) (*ContainerStatusResponse, error) {
+	out := new(ContainerStatusResponse)
+	return out, c.invoke(ctx, "RuntimeService", "ContainerStatus", &ContainerStatusRequest{ContainerId: id, Verbose: true}, out)
+}
+
+func (c *client) ListContainerStats(ctx context.Context) (*ListContainerStatsResponse, error) {
+	out := new(ListContainerStatsResponse)
+	return out, c.invoke(ctx, "RuntimeService", "ListContainerStats", &ListContainerStatsRequest{}, out)
+}
+
+func (c *client) ListImages(ctx context.Context) (*ListImagesResponse, error) {
+	out := new(ListImagesResponse)
+	return out, c.invoke(ctx, "ImageService", "ListImages", &ListImagesRequest{}, out)
+}
+
+func (c *client) Close() error {
+	return c.cc.Close()
+}
@@ -0,0 +1,280 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	docker_client "github.com/fsouza/go-dockerclient"
+	"golang.org/x/net/context"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+)
+
+// Controls to browse the filesystem of a container, over a pipe, and to
+// download a file of it, over a pipe of its own
+const (
+	BrowseContainer = "docker_browse_container"
+	DownloadFile    = "docker_download_file"
+)
+
+// DownloadFilePath is the parameter of DownloadFile: the absolute path of
+// the file to download
+const DownloadFilePath = "path"
+
+const (
+	// maxBrowseFileSize is the size of the largest file which can be
+	// downloaded
+	maxBrowseFileSize = 1024 * 1024
+	// maxBrowseEntries is how many entries, of the whole tree below a
+	// directory, are read to list it
+	maxBrowseEntries = 10000
+
+	browseHelp = "Commands: ls [dir], cd <dir>, pwd, get <file> (to download, at most 1MiB), help, exit\n"
+)
+
+// browser serves the commands of someone browsing the filesystem of a
+// container, a line at a time, from the tar archives of its paths. Unlike
+// exec, it needs no shell or tools in the container, and works if it is
+// stopped. Files are downloaded over pipes of their own, so they aren't
+// mixed up with the terminal.
+type browser struct {
+	client      Client
+	pipes       controls.PipeClient
+	appID       string
+	containerID string
+	cwd         string
+}
+
+func (r *registry) browseContainer(containerID string, req xfer.Request) xfer.Response {
+	if _, ok := r.GetContainer(containerID); !ok {
+		return xfer.ResponseErrorf("Not found: %s", containerID)
+	}
+	id, pipe, err := controls.NewPipe(r.pipes, req.AppID)
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+	local, _ := pipe.Ends()
+	b := &browser{client: r.client, pipes: r.pipes, appID: req.AppID, containerID: containerID, cwd: "/"}
+	go func() {
+		if err := b.serve(local); err != nil && err != io.EOF {
+			log.Errorf("Error browsing container %s: %v", containerID, err)
+		}
+		pipe.Close()
+	}()
+	return xfer.Response{
+		Pipe: id,
+	}
+}
+
+// serve reads commands from rw, writing their results to it, until the
+// end of the input or exit.
+func (b *browser) serve(rw io.ReadWriter) error {
+	if _, err := io.WriteString(rw, browseHelp); err != nil {
+		return err
+	}
+	lines := bufio.NewScanner(rw)
+	for {
+		if _, err := fmt.Fprintf(rw, "%s> ", b.cwd); err != nil {
+			return err
+		}
+		if !lines.Scan() {
+			return lines.Err()
+		}
+		fields := strings.Fields(lines.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		command, arg := fields[0], ""
+		if len(fields) > 1 {
+			arg = strings.Join(fields[1:], " ")
+		}
+		var err error
+		switch command {
+		case "ls":
+			err = b.list(rw, b.resolve(arg))
+		case "cd":
+			err = b.cd(b.resolve(arg))
+		case "pwd":
+			_, err = fmt.Fprintln(rw, b.cwd)
+		case "get":
+			if arg == "" {
+				err = fmt.Errorf("get needs a file")
+			} else {
+				err = b.get(rw, b.resolve(arg))
+			}
+		case "exit":
+			return nil
+		case "help":
+			_, err = io.WriteString(rw, browseHelp)
+		default:
+			err = fmt.Errorf("unknown command %q", command)
+		}
+		if err != nil {
+			if _, err := fmt.Fprintf(rw, "Error: %v\n", err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// resolve returns the absolute path of p, relative to the current
+// directory.
+func (b *browser) resolve(p string) string {
+	if !path.IsAbs(p) {
+		p = path.Join(b.cwd, p)
+	}
+	return path.Clean(p)
+}
+
+// archive calls f with a tar archive of p, stopping the download when f
+// returns.
+func (b *browser) archive(p string, f func(*tar.Reader) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(b.client.DownloadFromContainer(b.containerID, docker_client.DownloadFromContainerOptions{
+			OutputStream: writer,
+			Path:         p,
+			Context:      ctx,
+		}))
+	}()
+	defer reader.Close()
+	return f(tar.NewReader(reader))
+}
+
+func (b *browser) cd(p string) error {
+	return b.archive(p, func(archive *tar.Reader) error {
+		header, err := archive.Next()
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeDir {
+			return fmt.Errorf("not a directory: %s", p)
+		}
+		b.cwd = p
+		return nil
+	})
+}
+
+// list writes the entries of the directory p, like ls -l. Archives of
+// directories have their whole trees, so we give up on those with too
+// many entries.
+func (b *browser) list(w io.Writer, p string) error {
+	return b.archive(p, func(archive *tar.Reader) error {
+		root, err := archive.Next()
+		if err != nil {
+			return err
+		}
+		if root.Typeflag != tar.TypeDir {
+			return writeEntry(w, root)
+		}
+		for i := 0; ; i++ {
+			if i == maxBrowseEntries {
+				_, err := fmt.Fprintf(w, "... stopped after %d entries\n", maxBrowseEntries)
+				return err
+			}
+			header, err := archive.Next()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			// Only the children of the directory
+			if depth(header.Name) != depth(root.Name)+1 {
+				continue
+			}
+			if err := writeEntry(w, header); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// depth is how many directories deep a name of an archive is, e.g. 1 for
+// "etc/" and 2 for "etc/hosts".
+func depth(name string) int {
+	if name = path.Clean(name); name == "." || name == "/" {
+		return 0
+	}
+	return strings.Count(strings.TrimPrefix(name, "/"), "/") + 1
+}
+
+func writeEntry(w io.Writer, header *tar.Header) error {
+	name := path.Base(header.Name)
+	switch header.Typeflag {
+	case tar.TypeDir:
+		name += "/"
+	case tar.TypeSymlink:
+		name += " -> " + header.Linkname
+	}
+	_, err := fmt.Fprintf(w, "%s %10d %s %s\n", header.FileInfo().Mode(), header.Size, header.ModTime.UTC().Format("2006-01-02 15:04"), name)
+	return err
+}
+
+// get writes where to download the file p from, if it is not too large.
+func (b *browser) get(w io.Writer, p string) error {
+	resp := b.download(p)
+	if resp.Error != "" {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	_, err := fmt.Fprintf(w, "Download %s from /api/pipe/%s/download?name=%s\n", p, resp.Pipe, url.QueryEscape(resp.Download))
+	return err
+}
+
+// download streams the file p over a new pipe, to download, if it is not
+// too large.
+func (b *browser) download(p string) xfer.Response {
+	started := make(chan xfer.Response, 1)
+	go func() {
+		sent := false
+		err := b.archive(p, func(archive *tar.Reader) error {
+			header, err := archive.Next()
+			if err != nil {
+				return err
+			}
+			if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+				return fmt.Errorf("not a file: %s", p)
+			}
+			if header.Size > maxBrowseFileSize {
+				return fmt.Errorf("%s is %d bytes, more than the %d which can be downloaded", p, header.Size, maxBrowseFileSize)
+			}
+			id, pipe, err := controls.NewPipe(b.pipes, b.appID)
+			if err != nil {
+				return err
+			}
+			started <- xfer.Response{Pipe: id, Download: path.Base(p)}
+			sent = true
+			local, _ := pipe.Ends()
+			_, err = io.Copy(local, archive)
+			pipe.Close()
+			return err
+		})
+		// Before the download starts, errors are the response.
+		if !sent {
+			started <- xfer.ResponseError(err)
+		} else if err != nil {
+			log.Debugf("Error downloading %s from container %s: %v", p, b.containerID, err)
+		}
+	}()
+	return <-started
+}
+
+func (r *registry) downloadFile(containerID string, req xfer.Request) xfer.Response {
+	if _, ok := r.GetContainer(containerID); !ok {
+		return xfer.ResponseErrorf("Not found: %s", containerID)
+	}
+	p := req.ControlArgs[DownloadFilePath]
+	if !path.IsAbs(p) {
+		return xfer.ResponseErrorf("Not an absolute path: %q", p)
+	}
+	b := &browser{client: r.client, pipes: r.pipes, appID: req.AppID, containerID: containerID}
+	return b.download(path.Clean(p))
+}
@@ -0,0 +1,119 @@
+package docker_test
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+)
+
+func TestBrowseContainer(t *testing.T) {
+	// The first pipe is the browser's, the others those of downloads.
+	var (
+		mtx   sync.Mutex
+		pipes = map[string]xfer.Pipe{}
+	)
+	oldNewPipe := controls.NewPipe
+	defer func() { controls.NewPipe = oldNewPipe }()
+	controls.NewPipe = func(_ controls.PipeClient, _ string) (string, xfer.Pipe, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		id := "pipeid"
+		if len(pipes) > 0 {
+			id = fmt.Sprintf("download%d", len(pipes))
+		}
+		pipes[id] = xfer.NewPipe()
+		return id, pipes[id], nil
+	}
+	downloaded := func(id string) string {
+		mtx.Lock()
+		pipe := pipes[id]
+		mtx.Unlock()
+		if pipe == nil {
+			t.Fatalf("Expected a pipe %s", id)
+		}
+		_, remote := pipe.Ends()
+		contents, _ := ioutil.ReadAll(remote)
+		return string(contents)
+	}
+
+	mdc := newMockClient()
+	setupStubs(mdc, func() {
+		hr := controls.NewDefaultHandlerRegistry()
+		registry, _ := docker.NewRegistry(docker.RegistryOptions{
+			Interval:        10 * time.Second,
+			HandlerRegistry: hr,
+		})
+		defer registry.Stop()
+
+		test.Poll(t, 100*time.Millisecond, true, func() interface{} {
+			_, ok := registry.GetContainer("ping")
+			return ok
+		})
+
+		result := hr.HandleControlRequest(xfer.Request{
+			Control: docker.BrowseContainer,
+			NodeID:  report.MakeContainerNodeID("ping"),
+		})
+		if result.Pipe != "pipeid" || result.Error != "" {
+			t.Fatalf("Expected a pipe, got %v", result)
+		}
+
+		_, remote := pipes["pipeid"].Ends()
+		go io.WriteString(remote, "ls /etc\ncd etc/ssl\npwd\nget cert.pem\ncd ../nothere\nget /var/big.log\nls\nexit\n")
+		output, _ := ioutil.ReadAll(remote)
+		for _, want := range []string{
+			"/> ",
+			"-rw-r--r--         20 2009-11-10 23:00 hosts\n",
+			"drwxr-xr-x          0 2009-11-10 23:00 ssl/\n",
+			"/etc/ssl> /etc/ssl\n",
+			"Download /etc/ssl/cert.pem from /api/pipe/download1/download?name=cert.pem\n",
+			"Error: Could not find the file /etc/nothere in container\n",
+			"Error: /var/big.log is 2097152 bytes, more than the 1048576 which can be downloaded\n",
+			"-rw-r--r--         28 2009-11-10 23:00 cert.pem\n",
+		} {
+			if !strings.Contains(string(output), want) {
+				t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+			}
+		}
+		// Only listed in /etc/ssl, not with the children of /etc
+		if strings.Count(string(output), " cert.pem\n") != 1 {
+			t.Errorf("Expected only the children of /etc, got:\n%s", output)
+		}
+		// Files are downloaded over pipes of their own.
+		if have, want := downloaded("download1"), "-----BEGIN CERTIFICATE-----\n"; have != want {
+			t.Errorf("Expected to download %q, got %q", want, have)
+		}
+
+		result = hr.HandleControlRequest(xfer.Request{
+			Control:     docker.DownloadFile,
+			NodeID:      report.MakeContainerNodeID("ping"),
+			ControlArgs: map[string]string{docker.DownloadFilePath: "/etc/hosts"},
+		})
+		if result.Pipe != "download2" || result.Download != "hosts" || result.Error != "" {
+			t.Fatalf("Expected a pipe to download, got %v", result)
+		}
+		if have, want := downloaded("download2"), "127.0.0.1 localhost\n"; have != want {
+			t.Errorf("Expected to download %q, got %q", want, have)
+		}
+		for _, p := range []string{"etc/hosts", "/etc", "/var/big.log"} {
+			result := hr.HandleControlRequest(xfer.Request{
+				Control:     docker.DownloadFile,
+				NodeID:      report.MakeContainerNodeID("ping"),
+				ControlArgs: map[string]string{docker.DownloadFilePath: p},
+			})
+			if result.Error == "" {
+				t.Errorf("Expected an error downloading %s, got %v", p, result)
+			}
+		}
+	})
+}
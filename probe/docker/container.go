@@ -104,16 +104,19 @@ type container struct {
 	pendingStats           [60]docker.Stats
 	numPending             int
 	hostID                 string
+	clusterScope           string
 	baseNode               report.Node
 	noCommandLineArguments bool
 	noEnvironmentVariables bool
 }
 
-// NewContainer creates a new Container
-func NewContainer(c *docker.Container, hostID string, noCommandLineArguments bool, noEnvironmentVariables bool) Container {
+// NewContainer creates a new Container. Its IPs are scoped by the cluster,
+// if any, unless they are scoped by host.
+func NewContainer(c *docker.Container, hostID, clusterScope string, noCommandLineArguments bool, noEnvironmentVariables bool) Container {
 	result := &container{
 		container:              c,
 		hostID:                 hostID,
+		clusterScope:           clusterScope,
 		noCommandLineArguments: noCommandLineArguments,
 		noEnvironmentVariables: noEnvironmentVariables,
 	}
@@ -259,10 +262,10 @@ func (c *container) NetworkMode() (string, bool) {
 	return "", false
 }
 
-func addScopeToIPs(hostID string, ips []string) []string {
+func addScopeToIPs(hostID, clusterScope string, ips []string) []string {
 	ipsWithScopes := []string{}
 	for _, ip := range ips {
-		ipsWithScopes = append(ipsWithScopes, report.MakeAddressNodeID(hostID, ip))
+		ipsWithScopes = append(ipsWithScopes, report.MakeClusterAddressNodeID(clusterScope, hostID, ip))
 	}
 	return ipsWithScopes
 }
@@ -301,7 +304,7 @@ func (c *container) NetworkInfo(localAddrs []net.IP) report.Sets {
 		}
 	}
 	// Treat all Docker IPs as local scoped.
-	ipsWithScopes := addScopeToIPs(c.hostID, ipv4s)
+	ipsWithScopes := addScopeToIPs(c.hostID, c.clusterScope, ipv4s)
 
 	return report.MakeSets().
 		Add(ContainerNetworks, report.MakeStringSet(networks...)).
@@ -391,6 +394,13 @@ func (c *container) getBaseNode() report.Node {
 	}).WithParents(report.MakeSets().
 		Add(report.ContainerImage, report.MakeStringSet(report.MakeContainerImageNodeID(c.Image()))),
 	)
+	if c.container.HostConfig != nil {
+		result = result.WithLatests(securityLatests(c.container)).
+			WithSet(ContainerCapabilities, report.MakeStringSet(capabilities(c.container.HostConfig)...))
+		if namespaces := hostNamespaces(c.container.HostConfig); len(namespaces) > 0 {
+			result = result.WithSet(ContainerHostNamespaces, report.MakeStringSet(namespaces...))
+		}
+	}
 	result = result.AddPrefixPropertyList(LabelPrefix, c.container.Config.Labels)
 	if !c.noEnvironmentVariables {
 		result = result.AddPrefixPropertyList(EnvPrefix, c.env())
@@ -409,6 +419,10 @@ func (c *container) controlsMap() map[string]report.NodeControlData {
 		PauseContainer:   {Dead: !running},
 		AttachContainer:  {Dead: !running},
 		ExecContainer:    {Dead: !running},
+		BrowseContainer:  {Dead: false},
+		DownloadFile:     {Dead: false},
+		ForwardPort:      {Dead: !running},
+		CapturePackets:   {Dead: !running},
 		StartContainer:   {Dead: !stopped},
 		RemoveContainer:  {Dead: !stopped},
 	}
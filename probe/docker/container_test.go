@@ -42,7 +42,7 @@ func TestContainer(t *testing.T) {
 	defer mtime.NowReset()
 
 	const hostID = "scope"
-	c := docker.NewContainer(container1, hostID, false, false)
+	c := docker.NewContainer(container1, hostID, "", false, false)
 	s := newMockStatsGatherer()
 	err := c.StartGatheringStats(s)
 	if err != nil {
@@ -67,6 +67,10 @@ func TestContainer(t *testing.T) {
 			docker.PauseContainer:   {Dead: false},
 			docker.AttachContainer:  {Dead: false},
 			docker.ExecContainer:    {Dead: false},
+			docker.BrowseContainer:  {Dead: false},
+			docker.DownloadFile:     {Dead: false},
+			docker.ForwardPort:      {Dead: false},
+			docker.CapturePackets:   {Dead: false},
 			docker.StartContainer:   {Dead: true},
 			docker.RemoveContainer:  {Dead: true},
 		}
@@ -133,7 +137,7 @@ func TestContainer(t *testing.T) {
 
 func TestContainerHidingArgs(t *testing.T) {
 	const hostID = "scope"
-	c := docker.NewContainer(container1, hostID, true, false)
+	c := docker.NewContainer(container1, hostID, "", true, false)
 	node := c.GetNode()
 	node.Latest.ForEach(func(k string, _ time.Time, v string) {
 		if strings.Contains(v, "foo.bar.local") {
@@ -144,7 +148,7 @@ func TestContainerHidingArgs(t *testing.T) {
 
 func TestContainerHidingEnv(t *testing.T) {
 	const hostID = "scope"
-	c := docker.NewContainer(container1, hostID, false, true)
+	c := docker.NewContainer(container1, hostID, "", false, true)
 	node := c.GetNode()
 	node.Latest.ForEach(func(k string, _ time.Time, v string) {
 		if strings.Contains(v, "secret-bar") {
@@ -155,7 +159,7 @@ func TestContainerHidingEnv(t *testing.T) {
 
 func TestContainerHidingBoth(t *testing.T) {
 	const hostID = "scope"
-	c := docker.NewContainer(container1, hostID, true, true)
+	c := docker.NewContainer(container1, hostID, "", true, true)
 	node := c.GetNode()
 	node.Latest.ForEach(func(k string, _ time.Time, v string) {
 		if strings.Contains(v, "foo.bar.local") {
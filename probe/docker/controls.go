@@ -1,6 +1,10 @@
 package docker
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	docker_client "github.com/fsouza/go-dockerclient"
 
 	log "github.com/Sirupsen/logrus"
@@ -21,6 +25,13 @@ const (
 	AttachContainer  = report.DockerAttachContainer
 	ExecContainer    = report.DockerExecContainer
 	ResizeExecTTY    = "docker_resize_exec_tty"
+	ForwardPort      = "docker_port_forward"
+	CapturePackets   = "docker_capture_packets"
+
+	// Dry runs of StopContainer and RemoveContainer, describing what
+	// they would do.
+	StopContainerDryRun   = "docker_stop_container_dry_run"
+	RemoveContainerDryRun = "docker_remove_container_dry_run"
 
 	waitTime = 10
 )
@@ -30,6 +41,19 @@ func (r *registry) stopContainer(containerID string, _ xfer.Request) xfer.Respon
 	return xfer.ResponseError(r.client.StopContainer(containerID, waitTime))
 }
 
+func (r *registry) stopContainerDryRun(containerID string, _ xfer.Request) xfer.Response {
+	c, ok := r.GetContainer(containerID)
+	if !ok {
+		return xfer.ResponseErrorf("Not found: %s", containerID)
+	}
+	if ContainerIsStopped(c) {
+		return xfer.ResponseErrorf("Container %s is not running", containerName(c))
+	}
+	return xfer.Response{
+		Value: fmt.Sprintf("Would stop container %s (%s), killing it if it has not stopped after %d seconds", containerName(c), c.Image(), waitTime),
+	}
+}
+
 func (r *registry) startContainer(containerID string, _ xfer.Request) xfer.Response {
 	log.Infof("Starting container %s", containerID)
 	return xfer.ResponseError(r.client.StartContainer(containerID, nil))
@@ -62,6 +86,21 @@ func (r *registry) removeContainer(containerID string, req xfer.Request) xfer.Re
 	}
 }
 
+func (r *registry) removeContainerDryRun(containerID string, _ xfer.Request) xfer.Response {
+	c, ok := r.GetContainer(containerID)
+	if !ok {
+		return xfer.ResponseErrorf("Not found: %s", containerID)
+	}
+	// Docker does not remove running containers unless forced, which we
+	// do not
+	if !ContainerIsStopped(c) {
+		return xfer.ResponseErrorf("Container %s is running, stop it first", containerName(c))
+	}
+	return xfer.Response{
+		Value: fmt.Sprintf("Would remove container %s (%s), and its writable layer", containerName(c), c.Image()),
+	}
+}
+
 func (r *registry) attachContainer(containerID string, req xfer.Request) xfer.Response {
 	c, ok := r.GetContainer(containerID)
 	if !ok {
@@ -164,6 +203,55 @@ func (r *registry) execContainer(containerID string, req xfer.Request) xfer.Resp
 	}
 }
 
+func (r *registry) forwardPort(containerID string, req xfer.Request) xfer.Response {
+	c, ok := r.GetContainer(containerID)
+	if !ok {
+		return xfer.ResponseErrorf("Not found: %s", containerID)
+	}
+	host, ok := containerAddress(c)
+	if !ok {
+		return xfer.ResponseErrorf("Container %s has no IP address", containerName(c))
+	}
+	return controls.ForwardPort(r.pipes, req, host)
+}
+
+func (r *registry) capturePackets(containerID string, req xfer.Request) xfer.Response {
+	c, ok := r.GetContainer(containerID)
+	if !ok {
+		return xfer.ResponseErrorf("Not found: %s", containerID)
+	}
+	if c.PID() == 0 {
+		return xfer.ResponseErrorf("Container %s is not running", containerName(c))
+	}
+	return controls.CapturePackets(r.pipes, req, c.PID(), containerName(c))
+}
+
+// containerAddress is the address to connect to the ports of a container
+// at, from the host.
+func containerAddress(c Container) (string, bool) {
+	if mode, ok := c.NetworkMode(); ok && mode == "host" {
+		return "127.0.0.1", true
+	}
+	settings := c.Container().NetworkSettings
+	if settings == nil {
+		return "", false
+	}
+	if settings.IPAddress != "" {
+		return settings.IPAddress, true
+	}
+	names := make([]string, 0, len(settings.Networks))
+	for name := range settings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if ip := settings.Networks[name].IPAddress; ip != "" {
+			return ip, true
+		}
+	}
+	return "", false
+}
+
 func (r *registry) resizeExecTTY(pipeID string, height, width uint) xfer.Response {
 	r.Lock()
 	execID, ok := r.pipeIDToexecID[pipeID]
@@ -182,6 +270,10 @@ func (r *registry) resizeExecTTY(pipeID string, height, width uint) xfer.Respons
 	return xfer.Response{}
 }
 
+func containerName(c Container) string {
+	return strings.TrimPrefix(c.Container().Name, "/")
+}
+
 func captureContainerID(f func(string, xfer.Request) xfer.Response) func(xfer.Request) xfer.Response {
 	return func(req xfer.Request) xfer.Response {
 		containerID, ok := report.ParseContainerNodeID(req.NodeID)
@@ -202,7 +294,14 @@ func (r *registry) registerControls() {
 		RemoveContainer:  captureContainerID(r.removeContainer),
 		AttachContainer:  captureContainerID(r.attachContainer),
 		ExecContainer:    captureContainerID(r.execContainer),
+		BrowseContainer:  captureContainerID(r.browseContainer),
+		DownloadFile:     captureContainerID(r.downloadFile),
+		ForwardPort:      captureContainerID(r.forwardPort),
+		CapturePackets:   captureContainerID(r.capturePackets),
 		ResizeExecTTY:    xfer.ResizeTTYControlWrapper(r.resizeExecTTY),
+
+		StopContainerDryRun:   captureContainerID(r.stopContainerDryRun),
+		RemoveContainerDryRun: captureContainerID(r.removeContainerDryRun),
 	}
 	r.handlerRegistry.Batch(nil, controls)
 }
@@ -217,7 +316,13 @@ func (r *registry) deregisterControls() {
 		RemoveContainer,
 		AttachContainer,
 		ExecContainer,
+		BrowseContainer,
+		DownloadFile,
+		ForwardPort,
+		CapturePackets,
 		ResizeExecTTY,
+		StopContainerDryRun,
+		RemoveContainerDryRun,
 	}
 	r.handlerRegistry.Batch(controls, nil)
 }
@@ -44,6 +44,52 @@ func TestControls(t *testing.T) {
 	})
 }
 
+func TestDryRunControls(t *testing.T) {
+	mdc := newMockClient()
+	setupStubs(mdc, func() {
+		hr := controls.NewDefaultHandlerRegistry()
+		registry, _ := docker.NewRegistry(docker.RegistryOptions{
+			Interval:        10 * time.Second,
+			HandlerRegistry: hr,
+		})
+		defer registry.Stop()
+
+		test.Poll(t, 100*time.Millisecond, true, func() interface{} {
+			_, ok := registry.GetContainer("ping")
+			return ok
+		})
+
+		for _, want := range []struct {
+			control, containerID string
+			response             xfer.Response
+		}{
+			{
+				control:     docker.StopContainerDryRun,
+				containerID: "ping",
+				response:    xfer.Response{Value: "Would stop container pong (baz), killing it if it has not stopped after 10 seconds"},
+			},
+			{
+				control:     docker.RemoveContainerDryRun,
+				containerID: "ping",
+				response:    xfer.Response{Error: "Container pong is running, stop it first"},
+			},
+			{
+				control:     docker.StopContainerDryRun,
+				containerID: "nothere",
+				response:    xfer.Response{Error: "Not found: nothere"},
+			},
+		} {
+			result := hr.HandleControlRequest(xfer.Request{
+				Control: want.control,
+				NodeID:  report.MakeContainerNodeID(want.containerID),
+			})
+			if !reflect.DeepEqual(result, want.response) {
+				t.Errorf("diff %s: %s", want.control, commonTest.Diff(want.response, result))
+			}
+		}
+	})
+}
+
 type mockPipe struct{}
 
 func (mockPipe) Ends() (io.ReadWriter, io.ReadWriter)                { return nil, nil }
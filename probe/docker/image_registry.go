@@ -0,0 +1,263 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	docker_client "github.com/fsouza/go-dockerclient"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys for use in Node
+const (
+	ImageTagPushed = report.DockerImageTagPushed
+	ImageOutdated  = report.DockerImageOutdated
+)
+
+const dockerHubRegistry = "registry-1.docker.io"
+
+var (
+	manifestMediaTypes = []string{
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+		"application/vnd.oci.image.index.v1+json",
+		"application/vnd.oci.image.manifest.v1+json",
+	}
+	challengeParams = regexp.MustCompile(`(\w+)="([^"]*)"`)
+)
+
+// imageName is a tag of a repository in a registry, as docker resolves the
+// names of images.
+type imageName struct {
+	registry, repository, tag string
+}
+
+func parseImageName(name string) (imageName, bool) {
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	result := imageName{tag: "latest"}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, result.tag = name[:i], name[i+1:]
+	}
+	if name == "" || name == "<none>" {
+		return result, false
+	}
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		result.registry, result.repository = parts[0], parts[1]
+	} else {
+		result.registry, result.repository = dockerHubRegistry, name
+	}
+	if result.registry == "docker.io" || result.registry == "index.docker.io" {
+		result.registry = dockerHubRegistry
+	}
+	if result.registry == dockerHubRegistry && !strings.Contains(result.repository, "/") {
+		result.repository = "library/" + result.repository
+	}
+	return result, true
+}
+
+// registryClient asks registries, anonymously, about the tags of images
+// through their HTTP API (v2).
+type registryClient struct {
+	client   *http.Client
+	insecure map[string]struct{} // registries to talk plain HTTP to
+}
+
+// NewRegistryTagger returns an ImageTagger tagging images pulled from a
+// registry, and their containers, with when their tag was last pushed, and
+// whether the tag has since been pushed another image, polling the registry
+// every interval. Insecure are the registries to talk plain HTTP to. Don't
+// forget to Stop it.
+func NewRegistryTagger(registry Registry, insecure []string, interval time.Duration) *ImageTagger {
+	c := registryClient{
+		client:   &http.Client{Timeout: 30 * time.Second},
+		insecure: map[string]struct{}{},
+	}
+	for _, host := range insecure {
+		c.insecure[host] = struct{}{}
+	}
+	return NewImageTagger("Registry", registry, interval, c.lookup)
+}
+
+// lookup compares the image with the one its (first) tag now points at in
+// its registry. Images not pulled from a registry are left alone.
+func (c registryClient) lookup(image docker_client.APIImages) (map[string]string, error) {
+	if len(image.RepoTags) == 0 {
+		return nil, nil
+	}
+	name, ok := parseImageName(image.RepoTags[0])
+	if !ok {
+		return nil, nil
+	}
+	local := repoDigest(name, image.RepoDigests)
+	if local == "" {
+		return nil, nil
+	}
+	digest, pushed, err := c.tag(name)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		ImageTagPushed: pushed.Format(time.RFC3339Nano),
+		ImageOutdated:  strconv.FormatBool(digest != local),
+	}, nil
+}
+
+// repoDigest returns the digest of the image in the repository of the
+// name, if it was pulled from or pushed to there.
+func repoDigest(name imageName, repoDigests []string) string {
+	for _, repoDigest := range repoDigests {
+		i := strings.LastIndex(repoDigest, "@")
+		if i < 0 {
+			continue
+		}
+		if n, ok := parseImageName(repoDigest[:i]); ok && n.registry == name.registry && n.repository == name.repository {
+			return repoDigest[i+1:]
+		}
+	}
+	return ""
+}
+
+type manifest struct {
+	Config struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// tag returns the digest of the manifest a tag points at, and when the
+// image of the manifest (for this platform, if it's a list) was created,
+// which, unlike when it was pushed, the registry API tells.
+func (c registryClient) tag(name imageName) (string, time.Time, error) {
+	digest, m, err := c.manifest(name, name.tag)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if len(m.Manifests) > 0 {
+		platform := m.Manifests[0].Digest
+		for _, entry := range m.Manifests {
+			if entry.Platform.OS == "linux" && entry.Platform.Architecture == runtime.GOARCH {
+				platform = entry.Digest
+				break
+			}
+		}
+		if _, m, err = c.manifest(name, platform); err != nil {
+			return "", time.Time{}, err
+		}
+	}
+	body, _, err := c.get(name, "blobs/"+m.Config.Digest, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	var config struct {
+		Created string `json:"created"`
+	}
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&config); err != nil {
+		return "", time.Time{}, err
+	}
+	created, err := time.Parse(time.RFC3339Nano, config.Created)
+	return digest, created, err
+}
+
+func (c registryClient) manifest(name imageName, reference string) (string, manifest, error) {
+	var m manifest
+	body, header, err := c.get(name, "manifests/"+reference, manifestMediaTypes)
+	if err != nil {
+		return "", m, err
+	}
+	digest := header.Get("Docker-Content-Digest")
+	if digest == "" {
+		digest = fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+	}
+	err = codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&m)
+	return digest, m, err
+}
+
+// get fetches a resource of the repository of the image, getting an
+// anonymous token first when the registry wants one.
+func (c registryClient) get(name imageName, path string, accept []string) ([]byte, http.Header, error) {
+	scheme := "https"
+	if _, ok := c.insecure[name.registry]; ok {
+		scheme = "http"
+	}
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s://%s/v2/%s/%s", scheme, name.registry, name.repository, path), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, mediaType := range accept {
+		req.Header.Add("Accept", mediaType)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge := resp.Header.Get("WWW-Authenticate"); resp.StatusCode == http.StatusUnauthorized && strings.HasPrefix(challenge, "Bearer ") {
+		resp.Body.Close()
+		token, err := c.token(challenge)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		if resp, err = c.client.Do(req); err != nil {
+			return nil, nil, err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s: %s", req.URL, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	return body, resp.Header, err
+}
+
+func (c registryClient) token(challenge string) (string, error) {
+	params := url.Values{}
+	var realm string
+	for _, match := range challengeParams.FindAllStringSubmatch(challenge, -1) {
+		if match[1] == "realm" {
+			realm = match[2]
+		} else {
+			params.Set(match[1], match[2])
+		}
+	}
+	if realm == "" {
+		return "", fmt.Errorf("no realm to get a token from in %q", challenge)
+	}
+	resp, err := c.client.Get(realm + "?" + params.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", realm, resp.Status)
+	}
+	var decoded struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(&decoded); err != nil {
+		return "", err
+	}
+	if decoded.Token == "" {
+		return decoded.AccessToken, nil
+	}
+	return decoded.Token, nil
+}
@@ -0,0 +1,75 @@
+package docker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/fsouza/go-dockerclient"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+)
+
+func TestRegistryTagger(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			w.Write([]byte(`{"token": "secret"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry",scope="repository:app:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/app/manifests/1.0":
+			w.Header().Set("Docker-Content-Digest", "sha256:list")
+			w.Write([]byte(`{"manifests": [
+				{"digest": "sha256:windows", "platform": {"architecture": "amd64", "os": "windows"}},
+				{"digest": "sha256:linux", "platform": {"architecture": "` + runtime.GOARCH + `", "os": "linux"}}
+			]}`))
+		case "/v2/app/manifests/sha256:linux":
+			w.Write([]byte(`{"config": {"digest": "sha256:config"}}`))
+		case "/v2/app/blobs/sha256:config":
+			w.Write([]byte(`{"created": "2018-01-02T03:04:05Z"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	registry := &mockRegistry{images: map[string]client.APIImages{
+		"current":  {ID: "sha256:current", RepoTags: []string{host + "/app:1.0"}, RepoDigests: []string{host + "/app@sha256:list"}},
+		"outdated": {ID: "sha256:outdated", RepoTags: []string{host + "/app:1.0"}, RepoDigests: []string{host + "/app@sha256:old"}},
+		"local":    {ID: "sha256:local", RepoTags: []string{"local:latest"}},
+	}}
+	tagger := docker.NewRegistryTagger(registry, []string{host}, time.Hour)
+	defer tagger.Stop()
+
+	rpt := report.MakeReport()
+	for _, id := range []string{"current", "outdated", "local"} {
+		rpt.ContainerImage.AddNode(report.MakeNodeWith(report.MakeContainerImageNodeID(id), map[string]string{docker.ImageID: id}))
+	}
+	test.Poll(t, time.Second, map[string]string{
+		"current":  "2018-01-02T03:04:05Z false",
+		"outdated": "2018-01-02T03:04:05Z true",
+		"local":    " ",
+	}, func() interface{} {
+		tagged, _ := tagger.Tag(rpt.Copy())
+		result := map[string]string{}
+		for _, id := range []string{"current", "outdated", "local"} {
+			n := tagged.ContainerImage.Nodes[report.MakeContainerImageNodeID(id)]
+			pushed, _ := n.Latest.Lookup(docker.ImageTagPushed)
+			outdated, _ := n.Latest.Lookup(docker.ImageOutdated)
+			result[id] = pushed + " " + outdated
+		}
+		return result
+	})
+}
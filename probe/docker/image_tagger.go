@@ -0,0 +1,115 @@
+package docker
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	docker_client "github.com/fsouza/go-dockerclient"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+// ImageLookup looks up metadata of an image elsewhere than in docker, e.g.
+// in a vulnerability scanner or the registry it was pulled from, returning
+// it as latests of the image nodes, or none if there's nothing to find.
+type ImageLookup func(docker_client.APIImages) (map[string]string, error)
+
+type lookup struct {
+	latests       map[string]string
+	looked        time.Time
+	done, pending bool
+}
+
+// ImageTagger tags the images in the registry, and their containers, with
+// the metadata an ImageLookup finds. Lookups take a while, so they happen
+// in the background, one image at a time, and are repeated every interval.
+type ImageTagger struct {
+	name     string
+	registry Registry
+	lookup   ImageLookup
+	interval time.Duration
+	queue    chan docker_client.APIImages
+	quit     chan struct{}
+
+	mtx     sync.Mutex
+	lookups map[string]lookup // image ID -> last lookup
+}
+
+// NewImageTagger returns a usable ImageTagger. Don't forget to Stop it.
+func NewImageTagger(name string, registry Registry, interval time.Duration, f ImageLookup) *ImageTagger {
+	t := &ImageTagger{
+		name:     name,
+		registry: registry,
+		lookup:   f,
+		interval: interval,
+		queue:    make(chan docker_client.APIImages, 100),
+		quit:     make(chan struct{}),
+		lookups:  map[string]lookup{},
+	}
+	go t.loop()
+	return t
+}
+
+// Name of this tagger, for metrics gathering
+func (t *ImageTagger) Name() string { return t.name }
+
+// Stop stops looking up images.
+func (t *ImageTagger) Stop() {
+	close(t.quit)
+}
+
+func (t *ImageTagger) loop() {
+	for {
+		select {
+		case <-t.quit:
+			return
+		case image := <-t.queue:
+			latests, err := t.lookup(image)
+			if err != nil {
+				log.Warnf("docker %s: failed to look up %s: %v", t.name, image.ID, err)
+			}
+			id := trimImageID(image.ID)
+			t.mtx.Lock()
+			l := t.lookups[id]
+			l.looked, l.pending = mtime.Now(), false
+			if err == nil {
+				l.latests, l.done = latests, true
+			}
+			t.lookups[id] = l
+			t.mtx.Unlock()
+		}
+	}
+}
+
+// Tag implements Tagger.
+func (t *ImageTagger) Tag(rpt report.Report) (report.Report, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	lookups := map[string]lookup{}
+	t.registry.WalkImages(func(image docker_client.APIImages) {
+		id := trimImageID(image.ID)
+		l := t.lookups[id]
+		if !l.pending && mtime.Now().Sub(l.looked) >= t.interval {
+			select {
+			case t.queue <- image:
+				l.pending = true
+			default: // try again next time
+			}
+		}
+		lookups[id] = l
+	})
+	t.lookups = lookups // forget the images which are gone
+
+	for _, topology := range []*report.Topology{&rpt.ContainerImage, &rpt.Container} {
+		for id, n := range topology.Nodes {
+			imageID, _ := n.Latest.Lookup(ImageID)
+			if l := lookups[imageID]; l.done && len(l.latests) > 0 {
+				topology.Nodes[id] = n.WithLatests(l.latests)
+			}
+		}
+	}
+	return rpt, nil
+}
@@ -0,0 +1,31 @@
+package docker
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	docker_client "github.com/fsouza/go-dockerclient"
+)
+
+// npipeScheme is that of the endpoints of Docker on Windows, its named
+// pipes, e.g. npipe:////./pipe/docker_engine.
+const npipeScheme = "npipe://"
+
+// newNamedPipeClient makes a client of Docker over the named pipe of an
+// npipe:// endpoint. The Docker client doesn't know of named pipes, so it
+// sends its requests over HTTP to the pipe. Attaching to containers and
+// execing in them take connections of their own, so aren't supported.
+func newNamedPipeClient(endpoint string) (Client, error) {
+	path := strings.TrimPrefix(endpoint, npipeScheme)
+	client, err := docker_client.NewClient("http://docker")
+	if err != nil {
+		return nil, err
+	}
+	client.HTTPClient = &http.Client{Transport: &http.Transport{
+		Dial: func(_, _ string) (net.Conn, error) {
+			return dialNamedPipe(path)
+		},
+	}}
+	return client, nil
+}
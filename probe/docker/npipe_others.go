@@ -0,0 +1,16 @@
+// +build !windows
+
+package docker
+
+import (
+	"fmt"
+	"net"
+)
+
+// defaultEndpoint is the endpoint of Docker if none is given and
+// $DOCKER_HOST isn't set, other than the default of the client.
+const defaultEndpoint = ""
+
+func dialNamedPipe(path string) (net.Conn, error) {
+	return nil, fmt.Errorf("named pipes are only supported on Windows: %s", path)
+}
@@ -0,0 +1,119 @@
+package docker
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// defaultEndpoint is the endpoint of Docker if none is given and
+// $DOCKER_HOST isn't set: its named pipe.
+const defaultEndpoint = "npipe:////./pipe/docker_engine"
+
+const (
+	errorPipeBusy  syscall.Errno = 231 // ERROR_PIPE_BUSY
+	pipeBusyWaitMs               = 2000
+)
+
+var (
+	kernel32                = syscall.NewLazyDLL("kernel32.dll")
+	procCreateEvent         = kernel32.NewProc("CreateEventW")
+	procGetOverlappedResult = kernel32.NewProc("GetOverlappedResult")
+	procWaitNamedPipe       = kernel32.NewProc("WaitNamedPipeW")
+
+	errPipeDeadline = errors.New("deadlines are not supported on named pipes")
+)
+
+// dialNamedPipe connects to the named pipe at path, e.g.
+// //./pipe/docker_engine. Its I/O is overlapped, so that reads don't block
+// writes, as synchronous I/O of a handle is serialised.
+func dialNamedPipe(path string) (net.Conn, error) {
+	name, err := syscall.UTF16PtrFromString(strings.Replace(path, "/", `\`, -1))
+	if err != nil {
+		return nil, err
+	}
+	for retried := false; ; retried = true {
+		handle, err := syscall.CreateFile(name,
+			syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil,
+			syscall.OPEN_EXISTING, syscall.FILE_FLAG_OVERLAPPED, 0)
+		if err == nil {
+			return &pipeConn{handle: handle, path: path}, nil
+		}
+		if err != errorPipeBusy || retried {
+			return nil, &net.OpError{Op: "dial", Net: "pipe", Addr: pipeAddr(path), Err: err}
+		}
+		// All the instances of the pipe are in use.
+		procWaitNamedPipe.Call(uintptr(unsafe.Pointer(name)), pipeBusyWaitMs)
+	}
+}
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// pipeConn is a connection to a named pipe.
+type pipeConn struct {
+	handle    syscall.Handle
+	path      string
+	closeOnce sync.Once
+}
+
+func (c *pipeConn) Read(b []byte) (int, error) {
+	n, err := c.overlapped(b, syscall.ReadFile)
+	if err == syscall.ERROR_BROKEN_PIPE || (n == 0 && err == nil && len(b) > 0) {
+		return 0, io.EOF
+	}
+	return n, err
+}
+
+func (c *pipeConn) Write(b []byte) (int, error) {
+	return c.overlapped(b, syscall.WriteFile)
+}
+
+// overlapped does an operation on the pipe, waiting for it to complete.
+func (c *pipeConn) overlapped(b []byte, op func(syscall.Handle, []byte, *uint32, *syscall.Overlapped) error) (int, error) {
+	event, _, err := procCreateEvent.Call(0, 1, 0, 0)
+	if event == 0 {
+		return 0, err
+	}
+	defer syscall.CloseHandle(syscall.Handle(event))
+
+	o := syscall.Overlapped{HEvent: syscall.Handle(event)}
+	var n uint32
+	err = op(c.handle, b, &n, &o)
+	if err == syscall.ERROR_IO_PENDING {
+		err = nil
+		if ok, _, e := procGetOverlappedResult.Call(
+			uintptr(c.handle),
+			uintptr(unsafe.Pointer(&o)),
+			uintptr(unsafe.Pointer(&n)),
+			1, // wait
+		); ok == 0 {
+			err = e
+		}
+	}
+	return int(n), err
+}
+
+// Close cancels the pending operations of the pipe, and closes it.
+func (c *pipeConn) Close() error {
+	err := error(nil)
+	c.closeOnce.Do(func() {
+		syscall.CancelIoEx(c.handle, nil)
+		err = syscall.CloseHandle(c.handle)
+	})
+	return err
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return pipeAddr(c.path) }
+func (c *pipeConn) RemoteAddr() net.Addr { return pipeAddr(c.path) }
+
+func (c *pipeConn) SetDeadline(time.Time) error      { return errPipeDeadline }
+func (c *pipeConn) SetReadDeadline(time.Time) error  { return errPipeDeadline }
+func (c *pipeConn) SetWriteDeadline(time.Time) error { return errPipeDeadline }
@@ -0,0 +1,30 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// PodmanRootfulSocket is where the API service of Podman listens when run
+// as root (it's just exported for testing)
+var PodmanRootfulSocket = "/run/podman/podman.sock"
+
+// PodmanEndpoint returns the endpoint of the API service of Podman, which
+// is compatible with that of the Docker Engine, and so lets the registry
+// report the containers Podman runs, with their stats and controls. When
+// Podman is rootless, the service listens in the runtime directory of the
+// user instead.
+func PodmanEndpoint() string {
+	candidates := []string{PodmanRootfulSocket}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "podman", "podman.sock"))
+	}
+	candidates = append(candidates, fmt.Sprintf("/run/user/%d/podman/podman.sock", os.Getuid()))
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return "unix://" + path
+		}
+	}
+	return "unix://" + PodmanRootfulSocket
+}
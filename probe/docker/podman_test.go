@@ -0,0 +1,46 @@
+package docker_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/docker"
+)
+
+func TestPodmanEndpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "podman")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldRootfulSocket, oldRuntimeDir := docker.PodmanRootfulSocket, os.Getenv("XDG_RUNTIME_DIR")
+	defer func() {
+		docker.PodmanRootfulSocket = oldRootfulSocket
+		os.Setenv("XDG_RUNTIME_DIR", oldRuntimeDir)
+	}()
+	docker.PodmanRootfulSocket = filepath.Join(dir, "rootful.sock")
+	os.Setenv("XDG_RUNTIME_DIR", dir)
+
+	// Without any service listening, the rootful socket is the default
+	if have, want := docker.PodmanEndpoint(), "unix://"+docker.PodmanRootfulSocket; have != want {
+		t.Errorf("Expected %q, got %q", want, have)
+	}
+
+	// A rootless service in the runtime directory of the user
+	rootless := filepath.Join(dir, "podman", "podman.sock")
+	if err := os.MkdirAll(filepath.Dir(rootless), 0700); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", rootless)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if have, want := docker.PodmanEndpoint(), "unix://"+rootless; have != want {
+		t.Errorf("Expected %q, got %q", want, have)
+	}
+}
@@ -2,6 +2,7 @@ package docker
 
 import (
 	"fmt"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -57,6 +58,7 @@ type registry struct {
 	client                 Client
 	pipes                  controls.PipeClient
 	hostID                 string
+	clusterScope           string
 	handlerRegistry        *controls.HandlerRegistry
 	noCommandLineArguments bool
 	noEnvironmentVariables bool
@@ -89,12 +91,24 @@ type Client interface {
 	StartExecNonBlocking(string, docker_client.StartExecOptions) (docker_client.CloseWaiter, error)
 	Stats(docker_client.StatsOptions) error
 	ResizeExecTTY(id string, height, width int) error
+	DownloadFromContainer(string, docker_client.DownloadFromContainerOptions) error
 }
 
 func newDockerClient(endpoint string) (Client, error) {
+	if endpoint == "" {
+		// NewClientFromEnv doesn't know named pipes
+		if host := os.Getenv("DOCKER_HOST"); host == "" {
+			endpoint = defaultEndpoint
+		} else if strings.HasPrefix(host, npipeScheme) {
+			endpoint = host
+		}
+	}
 	if endpoint == "" {
 		return docker_client.NewClientFromEnv()
 	}
+	if strings.HasPrefix(endpoint, npipeScheme) {
+		return newNamedPipeClient(endpoint)
+	}
 	return docker_client.NewClient(endpoint)
 }
 
@@ -104,6 +118,7 @@ type RegistryOptions struct {
 	Pipes                  controls.PipeClient
 	CollectStats           bool
 	HostID                 string
+	ClusterScope           string // of the IPs of containers not scoped by host, if any
 	HandlerRegistry        *controls.HandlerRegistry
 	DockerEndpoint         string
 	NoCommandLineArguments bool
@@ -128,6 +143,7 @@ func NewRegistry(options RegistryOptions) (Registry, error) {
 		interval:        options.Interval,
 		collectStats:    options.CollectStats,
 		hostID:          options.HostID,
+		clusterScope:    options.ClusterScope,
 		handlerRegistry: options.HandlerRegistry,
 		quit:            make(chan chan struct{}),
 		noCommandLineArguments: options.NoCommandLineArguments,
@@ -358,7 +374,7 @@ func (r *registry) updateContainerState(containerID string, intendedState *strin
 	o, ok := r.containers.Get(containerID)
 	var c Container
 	if !ok {
-		c = NewContainerStub(dockerContainer, r.hostID, r.noCommandLineArguments, r.noEnvironmentVariables)
+		c = NewContainerStub(dockerContainer, r.hostID, r.clusterScope, r.noCommandLineArguments, r.noEnvironmentVariables)
 		r.containers.Insert(containerID, c)
 	} else {
 		c = o.(Container)
@@ -1,10 +1,13 @@
 package docker_test
 
 import (
+	"archive/tar"
 	"fmt"
+	"io"
 	"net"
 	"runtime"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -193,6 +196,45 @@ func (m *mockDockerClient) StartExecNonBlocking(string, client.StartExecOptions)
 	return mockCloseWaiter{}, nil
 }
 
+// mockFiles are the files of all the containers, by their names in
+// archives; those of directories end with /
+var mockFiles = map[string]string{
+	"etc/":             "",
+	"etc/hosts":        "127.0.0.1 localhost\n",
+	"etc/ssl/":         "",
+	"etc/ssl/cert.pem": "-----BEGIN CERTIFICATE-----\n",
+	"var/":             "",
+	"var/big.log":      strings.Repeat("x", 2*1024*1024),
+}
+
+func (m *mockDockerClient) DownloadFromContainer(_ string, opts client.DownloadFromContainerOptions) error {
+	name := strings.Trim(opts.Path, "/")
+	names := []string{}
+	for n := range mockFiles {
+		if trimmed := strings.TrimSuffix(n, "/"); trimmed == name || strings.HasPrefix(trimmed, name+"/") {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("Could not find the file %s in container", opts.Path)
+	}
+	sort.Strings(names)
+	archive := tar.NewWriter(opts.OutputStream)
+	for _, n := range names {
+		header := &tar.Header{Name: n, Mode: 0644, Size: int64(len(mockFiles[n])), Typeflag: tar.TypeReg, ModTime: startTime}
+		if strings.HasSuffix(n, "/") {
+			header.Mode, header.Typeflag = 0755, tar.TypeDir
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(archive, mockFiles[n]); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
 func (m *mockDockerClient) send(event *client.APIEvents) {
 	m.RLock()
 	defer m.RUnlock()
@@ -216,6 +258,10 @@ var (
 			Running:   true,
 			StartedAt: startTime,
 		},
+		Mounts: []client.Mount{
+			{Name: "data", Source: "/var/lib/docker/volumes/data/_data", Destination: "/data", Driver: "local", RW: true},
+			{Source: "/etc/ping", Destination: "/etc/ping"},
+		},
 		NetworkSettings: &client.NetworkSettings{
 			IPAddress: "1.2.3.4",
 			Ports: map[client.Port][]client.PortBinding{
@@ -305,7 +351,7 @@ func setupStubs(mdc *mockDockerClient, f func()) {
 		return mdc, nil
 	}
 
-	docker.NewContainerStub = func(c *client.Container, _ string, _ bool, _ bool) docker.Container {
+	docker.NewContainerStub = func(c *client.Container, _, _ string, _ bool, _ bool) docker.Container {
 		return &mockContainer{c}
 	}
 
@@ -2,12 +2,17 @@ package docker
 
 import (
 	"net"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	humanize "github.com/dustin/go-humanize"
 	docker_client "github.com/fsouza/go-dockerclient"
 
+	"github.com/weaveworks/common/mtime"
 	"github.com/weaveworks/scope/probe"
+	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/report"
 )
@@ -18,12 +23,30 @@ const (
 	ImageName        = report.DockerImageName
 	ImageSize        = report.DockerImageSize
 	ImageVirtualSize = report.DockerImageVirtualSize
+	ImageDigest      = report.DockerImageDigest
+	ImageCreated     = report.DockerImageCreated
 	IsInHostNetwork  = report.DockerIsInHostNetwork
 	ImageLabelPrefix = "docker_image_label_"
 	ImageTableID     = "image_table"
 	ServiceName      = report.DockerServiceName
 	StackNamespace   = report.DockerStackNamespace
 	DefaultNamespace = "No Stack"
+	ComposeProject   = report.DockerComposeProject
+	ComposeService   = report.DockerComposeService
+	NetworkName      = report.DockerNetworkName
+	NetworkDriver    = report.DockerNetworkDriver
+	NetworkScope     = report.DockerNetworkScope
+	NetworkInternal  = report.DockerNetworkInternal
+	NetworkSubnets   = report.DockerNetworkSubnets
+	VolumeName       = report.DockerVolumeName
+	VolumeType       = report.DockerVolumeType
+	VolumeDriver     = report.DockerVolumeDriver
+	VolumeSource     = report.DockerVolumeSource
+	VolumeUsage      = report.DockerVolumeUsage
+
+	// The types of volumes
+	NamedVolume = "volume"
+	BindMount   = "bind"
 )
 
 // Exposed for testing
@@ -39,6 +62,17 @@ var (
 		ContainerPorts:        {ID: ContainerPorts, Label: "Ports", From: report.FromSets, Priority: 8},
 		ContainerCreated:      {ID: ContainerCreated, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 9},
 		ContainerID:           {ID: ContainerID, Label: "ID", From: report.FromLatest, Truncate: 12, Priority: 10},
+		ImageCVEsCritical:     {ID: ImageCVEsCritical, Label: "Critical CVEs", From: report.FromLatest, Datatype: report.Number, Priority: 11},
+		ImageCVEsHigh:         {ID: ImageCVEsHigh, Label: "High CVEs", From: report.FromLatest, Datatype: report.Number, Priority: 12},
+		ImageCVEsMedium:       {ID: ImageCVEsMedium, Label: "Medium CVEs", From: report.FromLatest, Datatype: report.Number, Priority: 13},
+		ImageCVEsLow:          {ID: ImageCVEsLow, Label: "Low CVEs", From: report.FromLatest, Datatype: report.Number, Priority: 14},
+		ImageOutdated:         {ID: ImageOutdated, Label: "Newer Image For Tag", From: report.FromLatest, Priority: 15},
+
+		ContainerPrivileged:      {ID: ContainerPrivileged, Label: "Privileged", From: report.FromLatest, Priority: 16},
+		ContainerCapabilities:    {ID: ContainerCapabilities, Label: "Capabilities", From: report.FromSets, Priority: 17},
+		ContainerHostNamespaces:  {ID: ContainerHostNamespaces, Label: "Host Namespaces", From: report.FromSets, Priority: 18},
+		ContainerSeccompProfile:  {ID: ContainerSeccompProfile, Label: "Seccomp", From: report.FromLatest, Priority: 19},
+		ContainerAppArmorProfile: {ID: ContainerAppArmorProfile, Label: "AppArmor", From: report.FromLatest, Priority: 20},
 	}
 
 	ContainerMetricTemplates = report.MetricTemplates{
@@ -47,7 +81,15 @@ var (
 	}
 
 	ContainerImageMetadataTemplates = report.MetadataTemplates{
-		report.Container: {ID: report.Container, Label: "# Containers", From: report.FromCounters, Datatype: report.Number, Priority: 2},
+		report.Container:  {ID: report.Container, Label: "# Containers", From: report.FromCounters, Datatype: report.Number, Priority: 2},
+		ImageCVEsCritical: {ID: ImageCVEsCritical, Label: "Critical CVEs", From: report.FromLatest, Datatype: report.Number, Priority: 3},
+		ImageCVEsHigh:     {ID: ImageCVEsHigh, Label: "High CVEs", From: report.FromLatest, Datatype: report.Number, Priority: 4},
+		ImageCVEsMedium:   {ID: ImageCVEsMedium, Label: "Medium CVEs", From: report.FromLatest, Datatype: report.Number, Priority: 5},
+		ImageCVEsLow:      {ID: ImageCVEsLow, Label: "Low CVEs", From: report.FromLatest, Datatype: report.Number, Priority: 6},
+		ImageDigest:       {ID: ImageDigest, Label: "Digest", From: report.FromLatest, Truncate: 19, Priority: 7},
+		ImageCreated:      {ID: ImageCreated, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 8},
+		ImageTagPushed:    {ID: ImageTagPushed, Label: "Tag Last Pushed", From: report.FromLatest, Datatype: report.DateTime, Priority: 9},
+		ImageOutdated:     {ID: ImageOutdated, Label: "Newer Image For Tag", From: report.FromLatest, Priority: 10},
 	}
 
 	ContainerTableTemplates = report.TableTemplates{
@@ -98,6 +140,37 @@ var (
 			Icon:  "fa-terminal",
 			Rank:  2,
 		},
+		{
+			ID:    BrowseContainer,
+			Human: "Browse files",
+			Icon:  "fa-folder-open",
+			Rank:  9,
+		},
+		{
+			ID:    DownloadFile,
+			Human: "Download file",
+			Icon:  "fa-file",
+			Rank:  12,
+			Params: []report.ControlParam{
+				{ID: DownloadFilePath, Human: "Path", Type: report.ControlParamString},
+			},
+		},
+		{
+			ID:    ForwardPort,
+			Human: "Forward port",
+			Icon:  "fa-exchange",
+			Rank:  10,
+			Params: []report.ControlParam{
+				{ID: controls.PortForwardPort, Human: "Port", Type: report.ControlParamInt},
+			},
+		},
+		{
+			ID:     CapturePackets,
+			Human:  "Capture packets",
+			Icon:   "fa-download",
+			Rank:   11,
+			Params: controls.PacketCaptureParams,
+		},
 		{
 			ID:    StartContainer,
 			Human: "Start",
@@ -123,40 +196,93 @@ var (
 			Rank:  6,
 		},
 		{
-			ID:    StopContainer,
-			Human: "Stop",
-			Icon:  "fa-stop",
-			Rank:  7,
+			ID:      StopContainer,
+			Human:   "Stop",
+			Icon:    "fa-stop",
+			Rank:    7,
+			Confirm: "Stop this container?",
+			DryRun:  StopContainerDryRun,
 		},
 		{
-			ID:    RemoveContainer,
-			Human: "Remove",
-			Icon:  "fa-trash-o",
-			Rank:  8,
+			ID:      RemoveContainer,
+			Human:   "Remove",
+			Icon:    "fa-trash-o",
+			Rank:    8,
+			Confirm: "Remove this container? This cannot be undone.",
+			DryRun:  RemoveContainerDryRun,
 		},
 	}
 
 	SwarmServiceMetadataTemplates = report.MetadataTemplates{
-		ServiceName:    {ID: ServiceName, Label: "Service Name", From: report.FromLatest, Priority: 0},
-		StackNamespace: {ID: StackNamespace, Label: "Stack Namespace", From: report.FromLatest, Priority: 1},
+		ServiceName:          {ID: ServiceName, Label: "Service Name", From: report.FromLatest, Priority: 0},
+		StackNamespace:       {ID: StackNamespace, Label: "Stack Namespace", From: report.FromLatest, Priority: 1},
+		SwarmServiceMode:     {ID: SwarmServiceMode, Label: "Mode", From: report.FromLatest, Priority: 2},
+		SwarmServiceImage:    {ID: SwarmServiceImage, Label: "Image", From: report.FromLatest, Priority: 3},
+		SwarmServiceReplicas: {ID: SwarmServiceReplicas, Label: "Desired Tasks", From: report.FromLatest, Datatype: report.Number, Priority: 4},
+		SwarmServiceRunning:  {ID: SwarmServiceRunning, Label: "Running Tasks", From: report.FromLatest, Datatype: report.Number, Priority: 5},
+		SwarmServiceUpdate:   {ID: SwarmServiceUpdate, Label: "Update", From: report.FromLatest, Priority: 6},
+	}
+
+	SwarmTaskMetadataTemplates = report.MetadataTemplates{
+		SwarmTaskName:    {ID: SwarmTaskName, Label: "Name", From: report.FromLatest, Priority: 0},
+		SwarmTaskState:   {ID: SwarmTaskState, Label: "State", From: report.FromLatest, Priority: 1},
+		SwarmTaskDesired: {ID: SwarmTaskDesired, Label: "Desired State", From: report.FromLatest, Priority: 2},
+		SwarmTaskNode:    {ID: SwarmTaskNode, Label: "Node", From: report.FromLatest, Priority: 3},
+		SwarmTaskCreated: {ID: SwarmTaskCreated, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 4},
+	}
+
+	ComposeServiceMetadataTemplates = report.MetadataTemplates{
+		ComposeService:   {ID: ComposeService, Label: "Service", From: report.FromLatest, Priority: 0},
+		ComposeProject:   {ID: ComposeProject, Label: "Project", From: report.FromLatest, Priority: 1},
+		report.Container: {ID: report.Container, Label: "# Containers", From: report.FromCounters, Datatype: report.Number, Priority: 2},
+	}
+
+	NetworkMetadataTemplates = report.MetadataTemplates{
+		NetworkDriver:    {ID: NetworkDriver, Label: "Driver", From: report.FromLatest, Priority: 1},
+		NetworkScope:     {ID: NetworkScope, Label: "Scope", From: report.FromLatest, Priority: 2},
+		NetworkInternal:  {ID: NetworkInternal, Label: "Internal", From: report.FromLatest, Priority: 3},
+		NetworkSubnets:   {ID: NetworkSubnets, Label: "Subnets", From: report.FromSets, Priority: 4},
+		report.Container: {ID: report.Container, Label: "# Containers", From: report.FromCounters, Datatype: report.Number, Priority: 5},
+	}
+
+	VolumeMetadataTemplates = report.MetadataTemplates{
+		VolumeType:       {ID: VolumeType, Label: "Type", From: report.FromLatest, Priority: 1},
+		VolumeDriver:     {ID: VolumeDriver, Label: "Driver", From: report.FromLatest, Priority: 2},
+		VolumeSource:     {ID: VolumeSource, Label: "Source", From: report.FromLatest, Priority: 3},
+		report.Container: {ID: report.Container, Label: "# Containers", From: report.FromCounters, Datatype: report.Number, Priority: 4},
 	}
+
+	VolumeMetricTemplates = report.MetricTemplates{
+		VolumeUsage: {ID: VolumeUsage, Label: "Filesystem", Format: report.FilesizeFormat, Priority: 1},
+	}
+
+	// HostRoot is where the probe sees the filesystem of the host, for the
+	// usage of the filesystems of volumes.
+	HostRoot = "/proc/1/root"
+
+	// FilesystemUsage returns the bytes used and in total of the filesystem
+	// of a path.
+	FilesystemUsage = filesystemUsage
 )
 
 // Reporter generate Reports containing Container and ContainerImage topologies
 type Reporter struct {
-	registry Registry
-	hostID   string
-	probeID  string
-	probe    *probe.Probe
+	registry     Registry
+	hostID       string
+	clusterScope string
+	probeID      string
+	probe        *probe.Probe
 }
 
-// NewReporter makes a new Reporter
-func NewReporter(registry Registry, hostID string, probeID string, probe *probe.Probe) *Reporter {
+// NewReporter makes a new Reporter. The IPs of the host are scoped by the
+// cluster, if any, like those of containers.
+func NewReporter(registry Registry, hostID, clusterScope string, probeID string, probe *probe.Probe) *Reporter {
 	reporter := &Reporter{
-		registry: registry,
-		hostID:   hostID,
-		probeID:  probeID,
-		probe:    probe,
+		registry:     registry,
+		hostID:       hostID,
+		clusterScope: clusterScope,
+		probeID:      probeID,
+		probe:        probe,
 	}
 	registry.WatchContainerUpdates(reporter.ContainerUpdated)
 	return reporter
@@ -186,6 +312,10 @@ func (r *Reporter) Report() (report.Report, error) {
 	result.ContainerImage = result.ContainerImage.Merge(r.containerImageTopology())
 	result.Overlay = result.Overlay.Merge(r.overlayTopology())
 	result.SwarmService = result.SwarmService.Merge(r.swarmServiceTopology())
+	result.SwarmTask = result.SwarmTask.Merge(r.swarmTaskTopology())
+	result.ComposeService = result.ComposeService.Merge(r.composeServiceTopology())
+	result.DockerNetwork = result.DockerNetwork.Merge(r.networkTopology())
+	result.DockerVolume = result.DockerVolume.Merge(r.volumeTopology())
 	return result, nil
 }
 
@@ -208,10 +338,22 @@ func (r *Reporter) containerTopology(localAddrs []net.IP) report.Topology {
 		WithTableTemplates(ContainerTableTemplates)
 	result.Controls.AddControls(ContainerControls)
 
+	networkIDs := map[string]string{} // name -> ID
+	r.registry.WalkNetworks(func(network docker_client.Network) {
+		networkIDs[network.Name] = network.ID
+	})
+
 	metadata := map[string]string{report.ControlProbeID: r.probeID}
 	nodes := []report.Node{}
 	r.registry.WalkContainers(func(c Container) {
-		nodes = append(nodes, c.GetNode().WithLatests(metadata))
+		node := c.GetNode().WithLatests(metadata)
+		if networks := containerNetworks(c, networkIDs); len(networks) > 0 {
+			node = node.WithParents(node.Parents.Add(report.DockerNetwork, report.MakeStringSet(networks...)))
+		}
+		if volumes := r.containerVolumes(c); len(volumes) > 0 {
+			node = node.WithParents(node.Parents.Add(report.DockerVolume, report.MakeStringSet(volumes...)))
+		}
+		nodes = append(nodes, node)
 	})
 
 	// Copy the IP addresses from other containers where they share network
@@ -220,7 +362,7 @@ func (r *Reporter) containerTopology(localAddrs []net.IP) report.Topology {
 	{
 		hostNetworkInfo := report.MakeSets()
 		if hostIPs, err := getLocalIPs(); err == nil {
-			hostIPsWithScopes := addScopeToIPs(r.hostID, hostIPs)
+			hostIPsWithScopes := addScopeToIPs(r.hostID, r.clusterScope, hostIPs)
 			hostNetworkInfo = hostNetworkInfo.
 				Add(ContainerIPs, report.MakeStringSet(hostIPs...)).
 				Add(ContainerIPsWithScopes, report.MakeStringSet(hostIPsWithScopes...))
@@ -280,6 +422,12 @@ func (r *Reporter) containerImageTopology() report.Topology {
 		if len(image.RepoTags) > 0 {
 			latests[ImageName] = image.RepoTags[0]
 		}
+		if digest := imageDigest(image); digest != "" {
+			latests[ImageDigest] = digest
+		}
+		if image.Created > 0 {
+			latests[ImageCreated] = time.Unix(image.Created, 0).Format(time.RFC3339Nano)
+		}
 		nodeID := report.MakeContainerImageNodeID(imageID)
 		node := report.MakeNodeWith(nodeID, latests)
 		node = node.AddPrefixPropertyList(ImageLabelPrefix, image.Labels)
@@ -304,10 +452,135 @@ func (r *Reporter) overlayTopology() report.Topology {
 	return report.MakeTopology().AddNode(node)
 }
 
+func (r *Reporter) networkTopology() report.Topology {
+	result := report.MakeTopology().WithMetadataTemplates(NetworkMetadataTemplates)
+	r.registry.WalkNetworks(func(network docker_client.Network) {
+		subnets := []string{}
+		for _, config := range network.IPAM.Config {
+			subnets = append(subnets, config.Subnet)
+		}
+		node := report.MakeNodeWith(report.MakeDockerNetworkNodeID(network.ID), map[string]string{
+			NetworkName:     network.Name,
+			NetworkDriver:   network.Driver,
+			NetworkScope:    network.Scope,
+			NetworkInternal: strconv.FormatBool(network.Internal),
+		}).WithSets(report.MakeSets().Add(NetworkSubnets, report.MakeStringSet(subnets...)))
+		result.AddNode(node)
+	})
+	return result
+}
+
+// containerNetworks returns the IDs of the nodes of the networks a container
+// is attached to, looking them up by name when docker doesn't tell their IDs.
+func containerNetworks(c Container, networkIDs map[string]string) []string {
+	dc := c.Container()
+	if dc == nil || dc.NetworkSettings == nil {
+		return nil
+	}
+	result := []string{}
+	for name, settings := range dc.NetworkSettings.Networks {
+		id := settings.NetworkID
+		if id == "" {
+			id = networkIDs[name]
+		}
+		if id != "" {
+			result = append(result, report.MakeDockerNetworkNodeID(id))
+		}
+	}
+	return result
+}
+
+func (r *Reporter) volumeTopology() report.Topology {
+	result := report.MakeTopology().
+		WithMetadataTemplates(VolumeMetadataTemplates).
+		WithMetricTemplates(VolumeMetricTemplates)
+	now := mtime.Now()
+	r.registry.WalkContainers(func(c Container) {
+		dc := c.Container()
+		if dc == nil {
+			return
+		}
+		for _, mount := range dc.Mounts {
+			id := report.MakeDockerVolumeNodeID(r.hostID, volumeSource(mount))
+			if _, ok := result.Nodes[id]; ok {
+				continue
+			}
+			latests := map[string]string{
+				VolumeName:   volumeSource(mount),
+				VolumeType:   BindMount,
+				VolumeSource: mount.Source,
+			}
+			if mount.Name != "" {
+				latests[VolumeType], latests[VolumeDriver] = NamedVolume, mount.Driver
+			}
+			node := report.MakeNodeWith(id, latests).WithParents(report.MakeSets().
+				Add(report.Host, report.MakeStringSet(report.MakeHostNodeID(r.hostID))))
+			if mount.Source != "" {
+				if used, total, err := FilesystemUsage(filepath.Join(HostRoot, mount.Source)); err == nil {
+					node = node.WithMetrics(report.Metrics{
+						VolumeUsage: report.MakeSingletonMetric(now, float64(used)).WithMax(float64(total)),
+					})
+				}
+			}
+			result.AddNode(node)
+		}
+	})
+	return result
+}
+
+// containerVolumes returns the IDs of the nodes of the volumes and bind
+// mounts of a container.
+func (r *Reporter) containerVolumes(c Container) []string {
+	dc := c.Container()
+	if dc == nil {
+		return nil
+	}
+	result := []string{}
+	for _, mount := range dc.Mounts {
+		result = append(result, report.MakeDockerVolumeNodeID(r.hostID, volumeSource(mount)))
+	}
+	return result
+}
+
+// volumeSource identifies a mount on its host: by the name of its volume,
+// else, for bind mounts, by the path mounted.
+func volumeSource(mount docker_client.Mount) string {
+	if mount.Name != "" {
+		return mount.Name
+	}
+	return mount.Source
+}
+
 func (r *Reporter) swarmServiceTopology() report.Topology {
 	return report.MakeTopology().WithMetadataTemplates(SwarmServiceMetadataTemplates)
 }
 
+func (r *Reporter) swarmTaskTopology() report.Topology {
+	return report.MakeTopology().WithMetadataTemplates(SwarmTaskMetadataTemplates)
+}
+
+func (r *Reporter) composeServiceTopology() report.Topology {
+	return report.MakeTopology().WithMetadataTemplates(ComposeServiceMetadataTemplates)
+}
+
+// imageDigest returns the digest of the manifest of the image in the
+// repository of its (first) tag, else in any repository.
+func imageDigest(image docker_client.APIImages) string {
+	if len(image.RepoTags) > 0 {
+		if name, ok := parseImageName(image.RepoTags[0]); ok {
+			if digest := repoDigest(name, image.RepoDigests); digest != "" {
+				return digest
+			}
+		}
+	}
+	for _, repoDigest := range image.RepoDigests {
+		if i := strings.LastIndex(repoDigest, "@"); i >= 0 {
+			return repoDigest[i+1:]
+		}
+	}
+	return ""
+}
+
 // Docker sometimes prefixes ids with a "type" annotation, but it renders a bit
 // ugly and isn't necessary, so we should strip it off
 func trimImageID(id string) string {
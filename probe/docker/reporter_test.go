@@ -1,6 +1,7 @@
 package docker_test
 
 import (
+	"fmt"
 	"testing"
 
 	client "github.com/fsouza/go-dockerclient"
@@ -8,6 +9,7 @@ import (
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
 )
 
 type mockRegistry struct {
@@ -72,8 +74,17 @@ func TestReporter(t *testing.T) {
 		hostID         = "host1"
 	)
 
+	oldFilesystemUsage := docker.FilesystemUsage
+	defer func() { docker.FilesystemUsage = oldFilesystemUsage }()
+	docker.FilesystemUsage = func(path string) (uint64, uint64, error) {
+		if path != docker.HostRoot+"/var/lib/docker/volumes/data/_data" {
+			return 0, 0, fmt.Errorf("no filesystem at %s", path)
+		}
+		return 1024, 4096, nil
+	}
+
 	containerImageNodeID := report.MakeContainerImageNodeID(imageID)
-	rpt, err := docker.NewReporter(mockRegistryInstance, "host1", controlProbeID, nil).Report()
+	rpt, err := docker.NewReporter(mockRegistryInstance, "host1", "", controlProbeID, nil).Report()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -146,4 +157,65 @@ func TestReporter(t *testing.T) {
 		}
 
 	}
+
+	// Reporter should add a docker network, with the container as a child
+	{
+		networkNodeID := report.MakeDockerNetworkNodeID("deadbeef")
+		node, ok := rpt.DockerNetwork.Nodes[networkNodeID]
+		if !ok {
+			t.Fatalf("Expected report to have docker network %q, but not found", networkNodeID)
+		}
+
+		for k, want := range map[string]string{
+			docker.NetworkName:     "network1",
+			docker.NetworkScope:    "local",
+			docker.NetworkInternal: "false",
+		} {
+			if have, ok := node.Latest.Lookup(k); !ok || have != want {
+				t.Errorf("Expected docker network %s latest %q: %q, got %q", networkNodeID, k, want, have)
+			}
+		}
+		if have, ok := node.Sets.Lookup(docker.NetworkSubnets); !ok || len(have) != 1 || have[0] != "5.6.7.8/24" {
+			t.Errorf("Expected docker network %s to have subnet 5.6.7.8/24, got %v", networkNodeID, have)
+		}
+
+		containerNodeID := report.MakeContainerNodeID("ping")
+		if parents, ok := rpt.Container.Nodes[containerNodeID].Parents.Lookup(report.DockerNetwork); !ok || !parents.Contains(networkNodeID) {
+			t.Errorf("Expected container %s to have parent docker network %q, got %q", containerNodeID, networkNodeID, parents)
+		}
+	}
+
+	// Reporter should add the volume and the bind mount, with the container as a child
+	{
+		volumeNodeID := report.MakeDockerVolumeNodeID(hostID, "data")
+		bindNodeID := report.MakeDockerVolumeNodeID(hostID, "/etc/ping")
+		for id, want := range map[string]map[string]string{
+			volumeNodeID: {docker.VolumeName: "data", docker.VolumeType: docker.NamedVolume, docker.VolumeDriver: "local"},
+			bindNodeID:   {docker.VolumeName: "/etc/ping", docker.VolumeType: docker.BindMount, docker.VolumeSource: "/etc/ping"},
+		} {
+			node, ok := rpt.DockerVolume.Nodes[id]
+			if !ok {
+				t.Fatalf("Expected report to have docker volume %q, but not found", id)
+			}
+			for k, v := range want {
+				if have, ok := node.Latest.Lookup(k); !ok || have != v {
+					t.Errorf("Expected docker volume %s latest %q: %q, got %q", id, k, v, have)
+				}
+			}
+			if parents, ok := node.Parents.Lookup(report.Host); !ok || !parents.Contains(report.MakeHostNodeID(hostID)) {
+				t.Errorf("Expected docker volume %s to have parent host %q, got %q", id, hostID, parents)
+			}
+		}
+		if s, ok := rpt.DockerVolume.Nodes[volumeNodeID].Metrics[docker.VolumeUsage].LastSample(); !ok || s.Value != 1024 {
+			t.Errorf("Expected docker volume %s to use 1024 bytes, got %v", volumeNodeID, s)
+		}
+		if _, ok := rpt.DockerVolume.Nodes[bindNodeID].Metrics.Lookup(docker.VolumeUsage); ok {
+			t.Errorf("Expected no usage of docker volume %s", bindNodeID)
+		}
+
+		containerNodeID := report.MakeContainerNodeID("ping")
+		if parents, ok := rpt.Container.Nodes[containerNodeID].Parents.Lookup(report.DockerVolume); !ok || !reflect.DeepEqual(report.MakeStringSet(volumeNodeID, bindNodeID), parents) {
+			t.Errorf("Expected container %s to have parent docker volumes, got %q", containerNodeID, parents)
+		}
+	}
 }
@@ -0,0 +1,120 @@
+package docker
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// Keys for use in Node
+const (
+	ContainerPrivileged      = "docker_container_privileged" // "true" or "false"
+	ContainerCapabilities    = "docker_container_capabilities"
+	ContainerHostNamespaces  = "docker_container_host_namespaces"
+	ContainerSeccompProfile  = "docker_container_seccomp_profile"
+	ContainerAppArmorProfile = "docker_container_apparmor_profile"
+
+	// AllCapabilities is the capability of containers with all of them,
+	// e.g. privileged ones.
+	AllCapabilities = "ALL"
+
+	seccompUnconfined = "unconfined"
+	seccompDefault    = "default"
+	seccompCustom     = "custom"
+)
+
+// defaultCapabilities are the capabilities docker grants containers unless
+// told otherwise.
+var defaultCapabilities = []string{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+	"NET_BIND_SERVICE", "NET_RAW", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+}
+
+// normaliseCapability makes "cap_sys_admin" and "SYS_ADMIN" the same.
+func normaliseCapability(c string) string {
+	return strings.TrimPrefix(strings.ToUpper(c), "CAP_")
+}
+
+// capabilities are those a container has, from the defaults, those
+// dropped and added.
+func capabilities(hc *docker.HostConfig) []string {
+	if hc.Privileged {
+		return []string{AllCapabilities}
+	}
+	caps := map[string]struct{}{}
+	for _, c := range defaultCapabilities {
+		caps[c] = struct{}{}
+	}
+	for _, c := range hc.CapDrop {
+		if c = normaliseCapability(c); c == AllCapabilities {
+			caps = map[string]struct{}{}
+		} else {
+			delete(caps, c)
+		}
+	}
+	for _, c := range hc.CapAdd {
+		if c = normaliseCapability(c); c == AllCapabilities {
+			return []string{AllCapabilities}
+		}
+		caps[c] = struct{}{}
+	}
+	result := make([]string, 0, len(caps))
+	for c := range caps {
+		result = append(result, c)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// hostNamespaces are the namespaces of the host a container shares, e.g.
+// "pid" with --pid=host.
+func hostNamespaces(hc *docker.HostConfig) []string {
+	result := []string{}
+	for _, ns := range []struct{ name, mode string }{
+		{"ipc", hc.IpcMode},
+		{"network", hc.NetworkMode},
+		{"pid", hc.PidMode},
+		{"user", hc.UsernsMode},
+		{"uts", hc.UTSMode},
+	} {
+		if ns.mode == "host" {
+			result = append(result, ns.name)
+		}
+	}
+	return result
+}
+
+// seccompProfile is the seccomp profile of a container: the default one,
+// none ("unconfined"), or one it was given.
+func seccompProfile(hc *docker.HostConfig) string {
+	if hc.Privileged {
+		return seccompUnconfined
+	}
+	for _, opt := range hc.SecurityOpt {
+		// Both seccomp=... and the older seccomp:...
+		if !strings.HasPrefix(opt, "seccomp=") && !strings.HasPrefix(opt, "seccomp:") {
+			continue
+		}
+		if profile := opt[len("seccomp="):]; profile == seccompUnconfined {
+			return seccompUnconfined
+		}
+		return seccompCustom
+	}
+	return seccompDefault
+}
+
+// securityLatests are what a container may do to the host: whether it is
+// privileged, its seccomp and AppArmor profiles.
+func securityLatests(c *docker.Container) map[string]string {
+	result := map[string]string{
+		ContainerPrivileged:     strconv.FormatBool(c.HostConfig.Privileged),
+		ContainerSeccompProfile: seccompProfile(c.HostConfig),
+	}
+	// Only where AppArmor is enabled
+	if c.AppArmorProfile != "" {
+		result[ContainerAppArmorProfile] = c.AppArmorProfile
+	}
+	return result
+}
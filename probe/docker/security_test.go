@@ -0,0 +1,74 @@
+package docker_test
+
+import (
+	"reflect"
+	"testing"
+
+	client "github.com/fsouza/go-dockerclient"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestContainerSecurity(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		hostConfig *client.HostConfig
+		apparmor   string
+		want       map[string]string
+		caps       []string
+		namespaces []string
+	}{
+		{
+			name:       "defaults",
+			hostConfig: &client.HostConfig{NetworkMode: "bridge"},
+			apparmor:   "docker-default",
+			want:       map[string]string{docker.ContainerPrivileged: "false", docker.ContainerSeccompProfile: "default", docker.ContainerAppArmorProfile: "docker-default"},
+			caps:       []string{"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD", "NET_BIND_SERVICE", "NET_RAW", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT"},
+		},
+		{
+			name:       "hardened",
+			hostConfig: &client.HostConfig{CapDrop: []string{"ALL"}, CapAdd: []string{"cap_net_bind_service"}, SecurityOpt: []string{"seccomp=/etc/profile.json"}},
+			want:       map[string]string{docker.ContainerPrivileged: "false", docker.ContainerSeccompProfile: "custom"},
+			caps:       []string{"NET_BIND_SERVICE"},
+		},
+		{
+			name:       "host",
+			hostConfig: &client.HostConfig{NetworkMode: "host", PidMode: "host", CapAdd: []string{"SYS_PTRACE"}, SecurityOpt: []string{"seccomp:unconfined"}},
+			want:       map[string]string{docker.ContainerPrivileged: "false", docker.ContainerSeccompProfile: "unconfined"},
+			caps:       []string{"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD", "NET_BIND_SERVICE", "NET_RAW", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT", "SYS_PTRACE"},
+			namespaces: []string{"network", "pid"},
+		},
+		{
+			name:       "privileged",
+			hostConfig: &client.HostConfig{Privileged: true},
+			apparmor:   "unconfined",
+			want:       map[string]string{docker.ContainerPrivileged: "true", docker.ContainerSeccompProfile: "unconfined", docker.ContainerAppArmorProfile: "unconfined"},
+			caps:       []string{"ALL"},
+		},
+	} {
+		container := &client.Container{
+			ID:              c.name,
+			Config:          &client.Config{},
+			HostConfig:      c.hostConfig,
+			AppArmorProfile: c.apparmor,
+		}
+		node := docker.NewContainer(container, "scope", "", false, false).GetNode()
+		for key, want := range c.want {
+			if have, _ := node.Latest.Lookup(key); have != want {
+				t.Errorf("%s: want %s %q, have %q", c.name, key, want, have)
+			}
+		}
+		if _, ok := c.want[docker.ContainerAppArmorProfile]; !ok {
+			if have, ok := node.Latest.Lookup(docker.ContainerAppArmorProfile); ok {
+				t.Errorf("%s: want no AppArmor profile, have %q", c.name, have)
+			}
+		}
+		if have, _ := node.Sets.Lookup(docker.ContainerCapabilities); !reflect.DeepEqual(report.MakeStringSet(c.caps...), have) {
+			t.Errorf("%s: want capabilities %v, have %v", c.name, c.caps, have)
+		}
+		if have, _ := node.Sets.Lookup(docker.ContainerHostNamespaces); !reflect.DeepEqual(report.MakeStringSet(c.namespaces...), have) {
+			t.Errorf("%s: want host namespaces %v, have %v", c.name, c.namespaces, have)
+		}
+	}
+}
@@ -0,0 +1,541 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys for use in the Node.Latest of Swarm services and tasks.
+const (
+	SwarmServiceMode     = report.DockerSwarmServiceMode
+	SwarmServiceImage    = report.DockerSwarmServiceImage
+	SwarmServiceReplicas = report.DockerSwarmServiceReplicas
+	SwarmServiceRunning  = report.DockerSwarmServiceRunning
+	SwarmServiceUpdate   = report.DockerSwarmServiceUpdate
+	SwarmTaskName        = report.DockerSwarmTaskName
+	SwarmTaskState       = report.DockerSwarmTaskState
+	SwarmTaskDesired     = report.DockerSwarmTaskDesiredState
+	SwarmTaskNode        = report.DockerSwarmTaskNode
+	SwarmTaskCreated     = report.DockerSwarmTaskCreated
+
+	// The modes of services
+	ReplicatedMode = "replicated"
+	GlobalMode     = "global"
+)
+
+// Control IDs used by the Swarm integration.
+const (
+	SwarmScaleUp        = report.DockerSwarmScaleUp
+	SwarmScaleDown      = report.DockerSwarmScaleDown
+	SwarmRollingRestart = report.DockerSwarmRollingRestart
+)
+
+// SwarmServiceControls are the controls of Swarm services. Exposed for
+// testing.
+var SwarmServiceControls = []report.Control{
+	{
+		ID:    SwarmScaleDown,
+		Human: "Scale Down",
+		Icon:  "fa-minus",
+		Rank:  0,
+	},
+	{
+		ID:    SwarmScaleUp,
+		Human: "Scale Up",
+		Icon:  "fa-plus",
+		Rank:  1,
+	},
+	{
+		ID:    SwarmRollingRestart,
+		Human: "Rolling Restart",
+		Icon:  "fa-repeat",
+		Rank:  2,
+	},
+}
+
+// SwarmInfo is what the Docker Engine tells of the swarm it's part of.
+type SwarmInfo struct {
+	NodeID           string
+	LocalNodeState   string // e.g. "inactive" or "active"
+	ControlAvailable bool   // if the node is a manager
+}
+
+// SwarmService is the part of a Swarm service we care about.
+type SwarmService struct {
+	ID        string
+	CreatedAt time.Time
+	Spec      struct {
+		Name         string
+		Labels       map[string]string
+		TaskTemplate struct {
+			ContainerSpec struct {
+				Image string
+			}
+			Networks []struct {
+				Target string
+			}
+		}
+		Mode struct {
+			Replicated *struct {
+				Replicas uint64
+			}
+			Global *struct{}
+		}
+	}
+	Endpoint struct {
+		VirtualIPs []struct {
+			NetworkID string
+		}
+	}
+	UpdateStatus *struct {
+		State string // e.g. "updating" or "completed"
+	}
+}
+
+// SwarmTask is the part of a Swarm task we care about.
+type SwarmTask struct {
+	ID           string
+	ServiceID    string
+	NodeID       string
+	Slot         int
+	CreatedAt    time.Time
+	DesiredState string
+	Status       struct {
+		State string // e.g. "preparing" or "running"
+	}
+}
+
+// SwarmNode is the part of a node of the swarm we care about.
+type SwarmNode struct {
+	ID          string
+	Description struct {
+		Hostname string
+	}
+}
+
+// SwarmClient is the part of the Swarm API of the Docker Engine we use. The
+// client of the registry predates it, so we talk to the API ourselves. It's
+// an interface so we can mock it for testing.
+type SwarmClient interface {
+	Info() (SwarmInfo, error)
+	ListServices() ([]SwarmService, error)
+	// Returns the tasks meant to be running.
+	ListTasks() ([]SwarmTask, error)
+	ListNodes() ([]SwarmNode, error)
+	// Updates the spec of a service with the given function, which gets
+	// all of it, so what we don't know of it is kept.
+	UpdateService(id string, update func(spec map[string]interface{}) error) error
+}
+
+// swarmRequestTimeout is how long requests to the swarm API of the Docker
+// Engine may take, so a stuck engine doesn't hold up reports.
+const swarmRequestTimeout = 10 * time.Second
+
+type swarmClient struct {
+	client *http.Client
+	url    string
+}
+
+// NewSwarmClient makes a new SwarmClient, talking to the Docker Engine at the
+// endpoint, e.g. unix:///var/run/docker.sock, tcp://127.0.0.1:2375 or
+// npipe:////./pipe/docker_engine, or at $DOCKER_HOST if it's "". TLS isn't
+// supported.
+func NewSwarmClient(endpoint string) (SwarmClient, error) {
+	if endpoint == "" {
+		endpoint = os.Getenv("DOCKER_HOST")
+	}
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	if endpoint == "" {
+		endpoint = "unix:///var/run/docker.sock"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "unix":
+		return &swarmClient{
+			client: &http.Client{
+				Transport: &http.Transport{
+					Dial: func(_, _ string) (net.Conn, error) {
+						return net.Dial("unix", u.Path)
+					},
+				},
+				Timeout: swarmRequestTimeout,
+			},
+			url: "http://docker",
+		}, nil
+	case "npipe":
+		return &swarmClient{
+			client: &http.Client{
+				Transport: &http.Transport{
+					Dial: func(_, _ string) (net.Conn, error) {
+						return dialNamedPipe(u.Path)
+					},
+				},
+				Timeout: swarmRequestTimeout,
+			},
+			url: "http://docker",
+		}, nil
+	case "tcp", "http":
+		return &swarmClient{
+			client: &http.Client{Timeout: swarmRequestTimeout},
+			url:    "http://" + u.Host,
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported Docker endpoint: %s", endpoint)
+}
+
+func (c *swarmClient) do(method, path string, body interface{}, out interface{}) error {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, c.url+path, &buf)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct{ Message string }
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("%s %s: got %d: %s", method, path, resp.StatusCode, apiErr.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	return decoder.Decode(out)
+}
+
+func (c *swarmClient) Info() (SwarmInfo, error) {
+	var info struct {
+		Swarm SwarmInfo
+	}
+	err := c.do("GET", "/info", nil, &info)
+	return info.Swarm, err
+}
+
+func (c *swarmClient) ListServices() ([]SwarmService, error) {
+	var services []SwarmService
+	err := c.do("GET", "/services", nil, &services)
+	return services, err
+}
+
+func (c *swarmClient) ListTasks() ([]SwarmTask, error) {
+	var tasks []SwarmTask
+	filters := url.QueryEscape(`{"desired-state":["running"]}`)
+	err := c.do("GET", "/tasks?filters="+filters, nil, &tasks)
+	return tasks, err
+}
+
+func (c *swarmClient) ListNodes() ([]SwarmNode, error) {
+	var nodes []SwarmNode
+	err := c.do("GET", "/nodes", nil, &nodes)
+	return nodes, err
+}
+
+func (c *swarmClient) UpdateService(id string, update func(spec map[string]interface{}) error) error {
+	var service struct {
+		Version struct {
+			Index json.Number
+		}
+		Spec map[string]interface{}
+	}
+	if err := c.do("GET", "/services/"+url.PathEscape(id), nil, &service); err != nil {
+		return err
+	}
+	if err := update(service.Spec); err != nil {
+		return err
+	}
+	// The version makes the update fail if the service changed since we got
+	// it, instead of undoing that change.
+	return c.do("POST", "/services/"+url.PathEscape(id)+"/update?version="+service.Version.Index.String(), service.Spec, nil)
+}
+
+// SwarmReporter generates Reports containing the SwarmService and SwarmTask
+// topologies of the services of the swarm, with their controls, when the
+// host is a manager of it. Tasks are parented to their services, and
+// services to the overlay networks they're attached to.
+type SwarmReporter struct {
+	client          SwarmClient
+	probeID         string
+	interval        time.Duration
+	handlerRegistry *controls.HandlerRegistry
+
+	mtx      sync.Mutex
+	lastRpt  report.Report
+	lastTime time.Time
+}
+
+// NewSwarmReporter makes a new SwarmReporter, asking the manager about the
+// swarm at most once per interval. Don't forget to Stop it.
+func NewSwarmReporter(client SwarmClient, probeID string, interval time.Duration, handlerRegistry *controls.HandlerRegistry) *SwarmReporter {
+	r := &SwarmReporter{
+		client:          client,
+		probeID:         probeID,
+		interval:        interval,
+		handlerRegistry: handlerRegistry,
+	}
+	r.handlerRegistry.Batch(nil, map[string]xfer.ControlHandlerFunc{
+		SwarmScaleUp:        r.scaleUp,
+		SwarmScaleDown:      r.scaleDown,
+		SwarmRollingRestart: r.rollingRestart,
+	})
+	return r
+}
+
+// Stop deregisters the controls of the reporter.
+func (r *SwarmReporter) Stop() {
+	r.handlerRegistry.Batch([]string{
+		SwarmScaleUp,
+		SwarmScaleDown,
+		SwarmRollingRestart,
+	}, nil)
+}
+
+// Name of this reporter, for metrics gathering
+func (*SwarmReporter) Name() string { return "Swarm" }
+
+// Report implements Reporter.
+func (r *SwarmReporter) Report() (report.Report, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if !r.lastTime.IsZero() && time.Since(r.lastTime) < r.interval {
+		return r.lastRpt, nil
+	}
+	rpt, err := r.report()
+	if err != nil {
+		return rpt, err
+	}
+	r.lastRpt, r.lastTime = rpt, time.Now()
+	return rpt, nil
+}
+
+func (r *SwarmReporter) report() (report.Report, error) {
+	result := report.MakeReport()
+	result.SwarmService = result.SwarmService.WithMetadataTemplates(SwarmServiceMetadataTemplates)
+	result.SwarmService.Controls.AddControls(SwarmServiceControls)
+	result.SwarmTask = result.SwarmTask.WithMetadataTemplates(SwarmTaskMetadataTemplates)
+
+	info, err := r.client.Info()
+	if err != nil {
+		return result, err
+	}
+	// Only managers know about the whole swarm
+	if info.LocalNodeState != "active" || !info.ControlAvailable {
+		return result, nil
+	}
+	services, err := r.client.ListServices()
+	if err != nil {
+		return result, err
+	}
+	tasks, err := r.client.ListTasks()
+	if err != nil {
+		return result, err
+	}
+	nodes, err := r.client.ListNodes()
+	if err != nil {
+		return result, err
+	}
+	hostnames := map[string]string{}
+	for _, node := range nodes {
+		hostnames[node.ID] = node.Description.Hostname
+	}
+
+	names := map[string]string{}      // service ID -> name
+	namespaces := map[string]string{} // service ID -> stack namespace
+	running := map[string]int{}       // service ID -> running tasks
+	desired := map[string]int{}       // service ID -> tasks meant to be running
+	for _, service := range services {
+		names[service.ID] = service.Spec.Name
+		_, namespaces[service.ID] = swarmServiceName(service.Spec.Name, service.Spec.Labels[swarmNamespaceLabel])
+	}
+	for _, task := range tasks {
+		desired[task.ServiceID]++
+		if task.Status.State == "running" {
+			running[task.ServiceID]++
+		}
+		// Tasks are named like the docker CLI does
+		name := fmt.Sprintf("%s.%d", names[task.ServiceID], task.Slot)
+		if task.Slot == 0 {
+			name = names[task.ServiceID] + "." + task.NodeID
+		}
+		hostname := hostnames[task.NodeID]
+		if hostname == "" {
+			hostname = task.NodeID
+		}
+		result.SwarmTask.AddNode(report.MakeNodeWith(report.MakeSwarmTaskNodeID(task.ID), map[string]string{
+			SwarmTaskName:    name,
+			SwarmTaskState:   task.Status.State,
+			SwarmTaskDesired: task.DesiredState,
+			SwarmTaskNode:    hostname,
+			SwarmTaskCreated: task.CreatedAt.Format(time.RFC3339Nano),
+			StackNamespace:   namespaces[task.ServiceID],
+		}).WithParents(report.MakeSets().Add(report.SwarmService, report.MakeStringSet(report.MakeSwarmServiceNodeID(task.ServiceID)))))
+	}
+
+	for _, service := range services {
+		name, namespace := swarmServiceName(service.Spec.Name, service.Spec.Labels[swarmNamespaceLabel])
+		latests := map[string]string{
+			ServiceName:           name,
+			StackNamespace:        namespace,
+			SwarmServiceImage:     service.Spec.TaskTemplate.ContainerSpec.Image,
+			SwarmServiceRunning:   strconv.Itoa(running[service.ID]),
+			report.ControlProbeID: r.probeID,
+		}
+		if service.UpdateStatus != nil && service.UpdateStatus.State != "" {
+			latests[SwarmServiceUpdate] = service.UpdateStatus.State
+		}
+		controls := map[string]report.NodeControlData{
+			SwarmRollingRestart: {Dead: false},
+		}
+		if replicated := service.Spec.Mode.Replicated; replicated != nil {
+			latests[SwarmServiceMode] = ReplicatedMode
+			latests[SwarmServiceReplicas] = strconv.FormatUint(replicated.Replicas, 10)
+			controls[SwarmScaleUp] = report.NodeControlData{Dead: false}
+			controls[SwarmScaleDown] = report.NodeControlData{Dead: replicated.Replicas == 0}
+		} else {
+			// Global services run a task on every node, so can't be scaled
+			latests[SwarmServiceMode] = GlobalMode
+			latests[SwarmServiceReplicas] = strconv.Itoa(desired[service.ID])
+			controls[SwarmScaleUp] = report.NodeControlData{Dead: true}
+			controls[SwarmScaleDown] = report.NodeControlData{Dead: true}
+		}
+
+		networks := []string{}
+		for _, network := range service.Spec.TaskTemplate.Networks {
+			networks = append(networks, report.MakeDockerNetworkNodeID(network.Target))
+		}
+		for _, vip := range service.Endpoint.VirtualIPs {
+			networks = append(networks, report.MakeDockerNetworkNodeID(vip.NetworkID))
+		}
+		node := report.MakeNodeWith(report.MakeSwarmServiceNodeID(service.ID), latests).
+			WithLatestControls(controls)
+		if len(networks) > 0 {
+			node = node.WithParents(report.MakeSets().Add(report.DockerNetwork, report.MakeStringSet(networks...)))
+		}
+		result.SwarmService.AddNode(node)
+	}
+	return result, nil
+}
+
+// The label of services and containers deployed as part of a stack.
+const swarmNamespaceLabel = "com.docker.stack.namespace"
+
+// swarmServiceName returns the name of a service within its stack, if it's
+// in one, and the namespace of the stack, e.g. "web" and "shop" of
+// "shop_web".
+func swarmServiceName(name, namespace string) (string, string) {
+	if namespace == "" {
+		return name, DefaultNamespace
+	}
+	return strings.TrimPrefix(name, namespace+"_"), namespace
+}
+
+// invalidate makes the next report ask the manager again, so the effects of
+// controls show up as soon as they can.
+func (r *SwarmReporter) invalidate() {
+	r.mtx.Lock()
+	r.lastTime = time.Time{}
+	r.mtx.Unlock()
+}
+
+func (r *SwarmReporter) scaleUp(req xfer.Request) xfer.Response {
+	return xfer.ResponseError(r.scale(req, 1))
+}
+
+func (r *SwarmReporter) scaleDown(req xfer.Request) xfer.Response {
+	return xfer.ResponseError(r.scale(req, -1))
+}
+
+func (r *SwarmReporter) scale(req xfer.Request, amount int64) error {
+	serviceID, ok := report.ParseSwarmServiceNodeID(req.NodeID)
+	if !ok {
+		return fmt.Errorf("Invalid ID: %s", req.NodeID)
+	}
+	defer r.invalidate()
+	return r.client.UpdateService(serviceID, func(spec map[string]interface{}) error {
+		mode, _ := spec["Mode"].(map[string]interface{})
+		replicated, ok := mode["Replicated"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("service %s isn't replicated", serviceID)
+		}
+		replicas, err := jsonInt(replicated["Replicas"])
+		if err != nil {
+			return err
+		}
+		if replicas+amount < 0 {
+			return fmt.Errorf("service %s has no replicas", serviceID)
+		}
+		replicated["Replicas"] = replicas + amount
+		log.Infof("Scaling Swarm service %s to %d replicas", serviceID, replicas+amount)
+		return nil
+	})
+}
+
+// rollingRestart replaces the tasks of a service, as its update config says,
+// like `docker service update --force` does.
+func (r *SwarmReporter) rollingRestart(req xfer.Request) xfer.Response {
+	serviceID, ok := report.ParseSwarmServiceNodeID(req.NodeID)
+	if !ok {
+		return xfer.ResponseErrorf("Invalid ID: %s", req.NodeID)
+	}
+	defer r.invalidate()
+	return xfer.ResponseError(r.client.UpdateService(serviceID, func(spec map[string]interface{}) error {
+		template, ok := spec["TaskTemplate"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("service %s has no task template", serviceID)
+		}
+		forceUpdate, err := jsonInt(template["ForceUpdate"])
+		if err != nil {
+			return err
+		}
+		template["ForceUpdate"] = forceUpdate + 1
+		log.Infof("Restarting the tasks of Swarm service %s", serviceID)
+		return nil
+	}))
+}
+
+// jsonInt returns the integer of a field of a spec, 0 if it's absent.
+func jsonInt(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		return v.Int64()
+	case int64:
+		return v, nil
+	case float64:
+		return int64(v), nil
+	}
+	return 0, fmt.Errorf("not a number: %v", v)
+}
@@ -0,0 +1,230 @@
+package docker_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+const swarmServices = `[
+	{
+		"ID": "svc1",
+		"Spec": {
+			"Name": "shop_web",
+			"Labels": {"com.docker.stack.namespace": "shop"},
+			"TaskTemplate": {"ContainerSpec": {"Image": "nginx:1.25"}, "Networks": [{"Target": "net1"}]},
+			"Mode": {"Replicated": {"Replicas": 2}}
+		},
+		"Endpoint": {"VirtualIPs": [{"NetworkID": "ingress"}]},
+		"UpdateStatus": {"State": "updating"}
+	},
+	{
+		"ID": "svc2",
+		"Spec": {
+			"Name": "agent",
+			"TaskTemplate": {"ContainerSpec": {"Image": "agent:latest"}},
+			"Mode": {"Global": {}}
+		}
+	}
+]`
+
+type mockSwarmClient struct {
+	info     docker.SwarmInfo
+	services []docker.SwarmService
+	tasks    []docker.SwarmTask
+	specs    map[string]map[string]interface{}
+}
+
+func newMockSwarmClient(t *testing.T) *mockSwarmClient {
+	client := &mockSwarmClient{
+		info: docker.SwarmInfo{NodeID: "node1", LocalNodeState: "active", ControlAvailable: true},
+		tasks: []docker.SwarmTask{
+			{ID: "task1", ServiceID: "svc1", NodeID: "node1", Slot: 1, DesiredState: "running"},
+			{ID: "task2", ServiceID: "svc1", NodeID: "node2", Slot: 2, DesiredState: "running"},
+			{ID: "task3", ServiceID: "svc2", NodeID: "node2", DesiredState: "running"},
+		},
+		specs: map[string]map[string]interface{}{
+			"svc1": {"Mode": map[string]interface{}{"Replicated": map[string]interface{}{"Replicas": json.Number("2")}}, "TaskTemplate": map[string]interface{}{}},
+			"svc2": {"Mode": map[string]interface{}{"Global": map[string]interface{}{}}, "TaskTemplate": map[string]interface{}{"ForceUpdate": json.Number("1")}},
+		},
+	}
+	client.tasks[0].Status.State = "running"
+	client.tasks[1].Status.State = "preparing"
+	client.tasks[2].Status.State = "running"
+	if err := json.Unmarshal([]byte(swarmServices), &client.services); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func (c *mockSwarmClient) Info() (docker.SwarmInfo, error) {
+	return c.info, nil
+}
+
+func (c *mockSwarmClient) ListServices() ([]docker.SwarmService, error) {
+	return c.services, nil
+}
+
+func (c *mockSwarmClient) ListTasks() ([]docker.SwarmTask, error) {
+	return c.tasks, nil
+}
+
+func (c *mockSwarmClient) ListNodes() ([]docker.SwarmNode, error) {
+	nodes := make([]docker.SwarmNode, 2)
+	nodes[0].ID, nodes[0].Description.Hostname = "node1", "manager1"
+	nodes[1].ID, nodes[1].Description.Hostname = "node2", "worker1"
+	return nodes, nil
+}
+
+func (c *mockSwarmClient) UpdateService(id string, update func(spec map[string]interface{}) error) error {
+	spec, ok := c.specs[id]
+	if !ok {
+		return fmt.Errorf("no such service: %s", id)
+	}
+	return update(spec)
+}
+
+func TestSwarmReporter(t *testing.T) {
+	client := newMockSwarmClient(t)
+	hr := controls.NewDefaultHandlerRegistry()
+	reporter := docker.NewSwarmReporter(client, "probe1", 0, hr)
+	defer reporter.Stop()
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webID := report.MakeSwarmServiceNodeID("svc1")
+	agentID := report.MakeSwarmServiceNodeID("svc2")
+	if len(rpt.SwarmService.Nodes) != 2 || len(rpt.SwarmTask.Nodes) != 3 {
+		t.Fatalf("Expected 2 services and 3 tasks, got %d and %d", len(rpt.SwarmService.Nodes), len(rpt.SwarmTask.Nodes))
+	}
+	web := rpt.SwarmService.Nodes[webID]
+	for key, want := range map[string]string{
+		docker.ServiceName:          "web",
+		docker.StackNamespace:       "shop",
+		docker.SwarmServiceMode:     docker.ReplicatedMode,
+		docker.SwarmServiceImage:    "nginx:1.25",
+		docker.SwarmServiceReplicas: "2",
+		docker.SwarmServiceRunning:  "1",
+		docker.SwarmServiceUpdate:   "updating",
+	} {
+		if have, _ := web.Latest.Lookup(key); have != want {
+			t.Errorf("Expected %s %q, got %q", key, want, have)
+		}
+	}
+	if have, _ := web.Parents.Lookup(report.DockerNetwork); !reflect.DeepEqual(report.MakeStringSet(report.MakeDockerNetworkNodeID("net1"), report.MakeDockerNetworkNodeID("ingress")), have) {
+		t.Errorf("Expected the service on its networks, got %v", have)
+	}
+	agent := rpt.SwarmService.Nodes[agentID]
+	if have, _ := agent.Latest.Lookup(docker.SwarmServiceReplicas); have != "1" {
+		t.Errorf("Expected a task of the global service, got %q", have)
+	}
+	if have, _ := agent.LatestControls.Lookup(docker.SwarmScaleUp); !have.Dead {
+		t.Errorf("Expected scaling a global service disabled")
+	}
+
+	task := rpt.SwarmTask.Nodes[report.MakeSwarmTaskNodeID("task2")]
+	for key, want := range map[string]string{
+		docker.SwarmTaskName:  "shop_web.2",
+		docker.SwarmTaskState: "preparing",
+		docker.SwarmTaskNode:  "worker1",
+	} {
+		if have, _ := task.Latest.Lookup(key); have != want {
+			t.Errorf("Expected %s %q, got %q", key, want, have)
+		}
+	}
+	if have, _ := rpt.SwarmTask.Nodes[report.MakeSwarmTaskNodeID("task3")].Latest.Lookup(docker.SwarmTaskName); have != "agent.node2" {
+		t.Errorf("Expected the task of the global service named after its node, got %q", have)
+	}
+	if have, _ := task.Parents.Lookup(report.SwarmService); !reflect.DeepEqual(report.MakeStringSet(webID), have) {
+		t.Errorf("Expected the task in its service, got %v", have)
+	}
+
+	for _, req := range []xfer.Request{
+		{Control: docker.SwarmScaleUp, NodeID: webID},
+		{Control: docker.SwarmScaleUp, NodeID: webID},
+		{Control: docker.SwarmScaleDown, NodeID: webID},
+		{Control: docker.SwarmRollingRestart, NodeID: agentID},
+	} {
+		if resp := hr.HandleControlRequest(req); resp.Error != "" {
+			t.Errorf("Expected %s of %s to succeed, got %v", req.Control, req.NodeID, resp.Error)
+		}
+	}
+	if resp := hr.HandleControlRequest(xfer.Request{Control: docker.SwarmScaleUp, NodeID: agentID}); resp.Error == "" {
+		t.Errorf("Expected an error scaling a global service")
+	}
+	if have := client.specs["svc1"]["Mode"].(map[string]interface{})["Replicated"].(map[string]interface{})["Replicas"]; have != int64(3) {
+		t.Errorf("Expected 3 replicas, got %v", have)
+	}
+	if have := client.specs["svc2"]["TaskTemplate"].(map[string]interface{})["ForceUpdate"]; have != int64(2) {
+		t.Errorf("Expected the tasks forced to update, got %v", have)
+	}
+}
+
+func TestSwarmReporterOnWorkers(t *testing.T) {
+	client := newMockSwarmClient(t)
+	client.info.ControlAvailable = false
+	reporter := docker.NewSwarmReporter(client, "probe1", 0, controls.NewDefaultHandlerRegistry())
+	defer reporter.Stop()
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rpt.SwarmService.Nodes) != 0 || len(rpt.SwarmTask.Nodes) != 0 {
+		t.Errorf("Expected nothing reported from a worker, got %d services and %d tasks", len(rpt.SwarmService.Nodes), len(rpt.SwarmTask.Nodes))
+	}
+}
+
+func TestSwarmClientUpdateService(t *testing.T) {
+	var posted map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/services/svc1":
+			fmt.Fprint(w, `{"ID": "svc1", "Version": {"Index": 42}, "Spec": {"Name": "web", "Mode": {"Replicated": {"Replicas": 2}}, "TaskTemplate": {"ContainerSpec": {"Image": "nginx"}}, "EndpointSpec": {"Mode": "vip"}}}`)
+		case r.Method == "POST" && r.URL.Path == "/services/svc1/update":
+			if have := r.URL.Query().Get("version"); have != "42" {
+				http.Error(w, `{"message": "bad version `+have+`"}`, http.StatusBadRequest)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &posted)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, err := docker.NewSwarmClient(strings.Replace(server.URL, "http://", "tcp://", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hr := controls.NewDefaultHandlerRegistry()
+	reporter := docker.NewSwarmReporter(client, "probe1", 0, hr)
+	defer reporter.Stop()
+	if resp := hr.HandleControlRequest(xfer.Request{Control: docker.SwarmScaleUp, NodeID: report.MakeSwarmServiceNodeID("svc1")}); resp.Error != "" {
+		t.Fatalf("Expected the service scaled, got %v", resp.Error)
+	}
+	want := map[string]interface{}{
+		"Name":         "web",
+		"Mode":         map[string]interface{}{"Replicated": map[string]interface{}{"Replicas": float64(3)}},
+		"TaskTemplate": map[string]interface{}{"ContainerSpec": map[string]interface{}{"Image": "nginx"}},
+		"EndpointSpec": map[string]interface{}{"Mode": "vip"},
+	}
+	if !reflect.DeepEqual(want, posted) {
+		t.Errorf("Expected the spec posted whole, with one more replica, got %v", posted)
+	}
+	if resp := hr.HandleControlRequest(xfer.Request{Control: docker.SwarmScaleUp, NodeID: report.MakeSwarmServiceNodeID("svc2")}); resp.Error == "" {
+		t.Errorf("Expected an error scaling an unknown service")
+	}
+}
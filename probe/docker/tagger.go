@@ -21,7 +21,7 @@ var (
 
 // Tagger is a tagger that tags Docker container information to process
 // nodes that have a PID.
-// It also populates the SwarmService topology if any of the associated docker labels are present.
+// It also populates the SwarmService, SwarmTask and ComposeService topologies if any of the associated docker labels are present.
 type Tagger struct {
 	registry   Registry
 	procWalker process.Walker
@@ -56,15 +56,8 @@ func (t *Tagger) Tag(r report.Report) (report.Report, error) {
 		if !ok {
 			continue
 		}
-		stackNamespace, ok := container.Latest.Lookup(LabelPrefix + "com.docker.stack.namespace")
-		if !ok {
-			stackNamespace = DefaultNamespace
-		} else {
-			prefix := stackNamespace + "_"
-			if strings.HasPrefix(serviceName, prefix) {
-				serviceName = serviceName[len(prefix):]
-			}
-		}
+		stackNamespace, _ := container.Latest.Lookup(LabelPrefix + swarmNamespaceLabel)
+		serviceName, stackNamespace = swarmServiceName(serviceName, stackNamespace)
 
 		nodeID := report.MakeSwarmServiceNodeID(serviceID)
 		node := report.MakeNodeWith(nodeID, map[string]string{
@@ -72,8 +65,42 @@ func (t *Tagger) Tag(r report.Report) (report.Report, error) {
 			StackNamespace: stackNamespace,
 		})
 		r.SwarmService = r.SwarmService.AddNode(node)
+		parents := container.Parents.Add(report.SwarmService, report.MakeStringSet(nodeID))
+
+		// Containers are named after their tasks, with the IDs of the tasks
+		// appended, e.g. "web.1.<task ID>"
+		if taskID, ok := container.Latest.Lookup(LabelPrefix + "com.docker.swarm.task.id"); ok {
+			taskName, _ := container.Latest.Lookup(LabelPrefix + "com.docker.swarm.task.name")
+			taskNodeID := report.MakeSwarmTaskNodeID(taskID)
+			r.SwarmTask = r.SwarmTask.AddNode(report.MakeNodeWith(taskNodeID, map[string]string{
+				SwarmTaskName:  strings.TrimSuffix(taskName, "."+taskID),
+				StackNamespace: stackNamespace,
+			}).WithParents(report.MakeSets().Add(report.SwarmService, report.MakeStringSet(nodeID))))
+			parents = parents.Add(report.SwarmTask, report.MakeStringSet(taskNodeID))
+		}
+
+		r.Container.Nodes[containerID] = container.WithParents(parents)
+	}
+
+	// Scan for Compose service info
+	for containerID, container := range r.Container.Nodes {
+		project, ok := container.Latest.Lookup(LabelPrefix + "com.docker.compose.project")
+		if !ok {
+			continue
+		}
+		service, ok := container.Latest.Lookup(LabelPrefix + "com.docker.compose.service")
+		if !ok {
+			continue
+		}
+
+		nodeID := report.MakeComposeServiceNodeID(project + ":" + service)
+		node := report.MakeNodeWith(nodeID, map[string]string{
+			ComposeProject: project,
+			ComposeService: service,
+		})
+		r.ComposeService = r.ComposeService.AddNode(node)
 
-		r.Container.Nodes[containerID] = container.WithParents(container.Parents.Add(report.SwarmService, report.MakeStringSet(nodeID)))
+		r.Container.Nodes[containerID] = container.WithParents(container.Parents.Add(report.ComposeService, report.MakeStringSet(nodeID)))
 	}
 
 	return r, nil
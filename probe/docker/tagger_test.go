@@ -75,3 +75,94 @@ func TestTagger(t *testing.T) {
 		}
 	}
 }
+
+func TestTaggerComposeServices(t *testing.T) {
+	oldProcessTree := docker.NewProcessTreeStub
+	defer func() { docker.NewProcessTreeStub = oldProcessTree }()
+
+	docker.NewProcessTreeStub = func(_ process.Walker) (process.Tree, error) {
+		return &mockProcessTree{}, nil
+	}
+
+	var (
+		webID     = report.MakeContainerNodeID("web")
+		loneID    = report.MakeContainerNodeID("lone")
+		serviceID = report.MakeComposeServiceNodeID("shop:web")
+	)
+	input := report.MakeReport()
+	input.Container.AddNode(report.MakeNodeWith(webID, map[string]string{
+		docker.LabelPrefix + "com.docker.compose.project": "shop",
+		docker.LabelPrefix + "com.docker.compose.service": "web",
+	}))
+	input.Container.AddNode(report.MakeNodeWith(loneID, map[string]string{}))
+
+	have, err := docker.NewTagger(mockRegistryInstance, nil).Tag(input)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	service, ok := have.ComposeService.Nodes[serviceID]
+	if !ok {
+		t.Fatalf("Expected compose service node %s, got %v", serviceID, have.ComposeService.Nodes)
+	}
+	for key, want := range map[string]string{docker.ComposeProject: "shop", docker.ComposeService: "web"} {
+		if value, ok := service.Latest.Lookup(key); !ok || value != want {
+			t.Errorf("Expected compose service %s %q, got %q", key, want, value)
+		}
+	}
+	if parents, ok := have.Container.Nodes[webID].Parents.Lookup(report.ComposeService); !ok || !parents.Contains(serviceID) {
+		t.Errorf("Expected container to have compose service %q as a parent, got %q", serviceID, parents)
+	}
+	if _, ok := have.Container.Nodes[loneID].Parents.Lookup(report.ComposeService); ok {
+		t.Errorf("Expected container without compose labels not to have a compose service")
+	}
+}
+
+func TestTaggerSwarmTasks(t *testing.T) {
+	oldProcessTree := docker.NewProcessTreeStub
+	defer func() { docker.NewProcessTreeStub = oldProcessTree }()
+
+	docker.NewProcessTreeStub = func(_ process.Walker) (process.Tree, error) {
+		return &mockProcessTree{}, nil
+	}
+
+	var (
+		webID     = report.MakeContainerNodeID("web")
+		serviceID = report.MakeSwarmServiceNodeID("svc1")
+		taskID    = report.MakeSwarmTaskNodeID("task1")
+	)
+	input := report.MakeReport()
+	input.Container.AddNode(report.MakeNodeWith(webID, map[string]string{
+		docker.LabelPrefix + "com.docker.swarm.service.id":   "svc1",
+		docker.LabelPrefix + "com.docker.swarm.service.name": "shop_web",
+		docker.LabelPrefix + "com.docker.stack.namespace":    "shop",
+		docker.LabelPrefix + "com.docker.swarm.task.id":      "task1",
+		docker.LabelPrefix + "com.docker.swarm.task.name":    "shop_web.2.task1",
+	}))
+
+	have, err := docker.NewTagger(mockRegistryInstance, nil).Tag(input)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if name, _ := have.SwarmService.Nodes[serviceID].Latest.Lookup(docker.ServiceName); name != "web" {
+		t.Errorf("Expected swarm service web, got %q", name)
+	}
+	task, ok := have.SwarmTask.Nodes[taskID]
+	if !ok {
+		t.Fatalf("Expected swarm task node %s, got %v", taskID, have.SwarmTask.Nodes)
+	}
+	for key, want := range map[string]string{docker.SwarmTaskName: "shop_web.2", docker.StackNamespace: "shop"} {
+		if value, ok := task.Latest.Lookup(key); !ok || value != want {
+			t.Errorf("Expected swarm task %s %q, got %q", key, want, value)
+		}
+	}
+	if parents, ok := task.Parents.Lookup(report.SwarmService); !ok || !parents.Contains(serviceID) {
+		t.Errorf("Expected task to have swarm service %q as a parent, got %q", serviceID, parents)
+	}
+	for _, topology := range []string{report.SwarmService, report.SwarmTask} {
+		if parents, ok := have.Container.Nodes[webID].Parents.Lookup(topology); !ok || len(parents) != 1 {
+			t.Errorf("Expected container to have a %s parent, got %q", topology, parents)
+		}
+	}
+}
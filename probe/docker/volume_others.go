@@ -0,0 +1,15 @@
+// +build !windows
+
+package docker
+
+import (
+	"syscall"
+)
+
+func filesystemUsage(path string) (used, total uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return (stat.Blocks - stat.Bfree) * uint64(stat.Bsize), stat.Blocks * uint64(stat.Bsize), nil
+}
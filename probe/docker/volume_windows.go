@@ -0,0 +1,25 @@
+package docker
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+func filesystemUsage(path string) (used, total uint64, err error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var free uint64
+	if ok, _, err := procGetDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(name)),
+		0,
+		uintptr(unsafe.Pointer(&total)),
+		uintptr(unsafe.Pointer(&free)),
+	); ok == 0 {
+		return 0, 0, err
+	}
+	return total - free, total, nil
+}
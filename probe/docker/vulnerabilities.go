@@ -0,0 +1,178 @@
+package docker
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	docker_client "github.com/fsouza/go-dockerclient"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/common/exec"
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys for use in Node
+const (
+	ImageCVEsCritical = report.DockerImageCVEsCritical
+	ImageCVEsHigh     = report.DockerImageCVEsHigh
+	ImageCVEsMedium   = report.DockerImageCVEsMedium
+	ImageCVEsLow      = report.DockerImageCVEsLow
+)
+
+// Vulnerabilities counts the known vulnerabilities of an image, by severity.
+type Vulnerabilities struct {
+	Critical, High, Medium, Low int
+}
+
+func (v *Vulnerabilities) add(severity string) {
+	switch strings.ToLower(severity) {
+	case "critical", "defcon1":
+		v.Critical++
+	case "high":
+		v.High++
+	case "medium":
+		v.Medium++
+	case "low":
+		v.Low++
+	}
+}
+
+func (v Vulnerabilities) latests() map[string]string {
+	return map[string]string{
+		ImageCVEsCritical: strconv.Itoa(v.Critical),
+		ImageCVEsHigh:     strconv.Itoa(v.High),
+		ImageCVEsMedium:   strconv.Itoa(v.Medium),
+		ImageCVEsLow:      strconv.Itoa(v.Low),
+	}
+}
+
+// ImageRef is what scanners need to know of an image to find its
+// vulnerabilities.
+type ImageRef struct {
+	ID     string
+	Name   string // the first tag, if any
+	Digest string // the manifest digest, if the image was pulled from a registry
+}
+
+// Scanner finds the known vulnerabilities of images.
+type Scanner interface {
+	Scan(ImageRef) (Vulnerabilities, error)
+}
+
+// NewScanner makes the Scanner of the given kind, "trivy" or "clair".
+// For Trivy, url is that of a Trivy server, if any, to scan in client/server
+// mode; for Clair, that of its (combo or matcher) API.
+func NewScanner(kind, url string) (Scanner, error) {
+	switch kind {
+	case "trivy":
+		return trivyScanner{server: url}, nil
+	case "clair":
+		if url == "" {
+			return nil, fmt.Errorf("clair scanner needs the url of the clair API")
+		}
+		return clairScanner{url: strings.TrimSuffix(url, "/"), client: &http.Client{Timeout: time.Minute}}, nil
+	}
+	return nil, fmt.Errorf("unknown vulnerability scanner %q", kind)
+}
+
+// trivyScanner runs the trivy CLI, which also scans images only present
+// locally.
+type trivyScanner struct {
+	server string
+}
+
+func (s trivyScanner) Scan(image ImageRef) (Vulnerabilities, error) {
+	args := []string{"image", "--quiet", "--format", "json"}
+	if s.server != "" {
+		args = append(args, "--server", s.server)
+	}
+	target := image.Name
+	if target == "" || strings.HasSuffix(target, "<none>") {
+		target = image.ID
+	}
+	output, err := exec.Command("trivy", append(args, target)...).Output()
+	if err != nil {
+		return Vulnerabilities{}, err
+	}
+	var decoded struct {
+		Results []struct {
+			Vulnerabilities []struct {
+				Severity string
+			}
+		}
+	}
+	if err := codec.NewDecoderBytes(output, &codec.JsonHandle{}).Decode(&decoded); err != nil {
+		return Vulnerabilities{}, err
+	}
+	var result Vulnerabilities
+	for _, r := range decoded.Results {
+		for _, v := range r.Vulnerabilities {
+			result.add(v.Severity)
+		}
+	}
+	return result, nil
+}
+
+// clairScanner asks Clair (v4) for the vulnerability report of the manifest
+// of an image, which Clair must have indexed already, as registries
+// integrating it, e.g. Quay or Harbor, do on push.
+type clairScanner struct {
+	url    string
+	client *http.Client
+}
+
+func (s clairScanner) Scan(image ImageRef) (Vulnerabilities, error) {
+	if image.Digest == "" {
+		return Vulnerabilities{}, fmt.Errorf("image %s has no manifest digest", image.ID)
+	}
+	resp, err := s.client.Get(s.url + "/matcher/api/v1/vulnerability_report/" + image.Digest)
+	if err != nil {
+		return Vulnerabilities{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Vulnerabilities{}, fmt.Errorf("clair: %s: %s", image.Digest, resp.Status)
+	}
+	var decoded struct {
+		Vulnerabilities map[string]struct {
+			NormalizedSeverity string `json:"normalized_severity"`
+		} `json:"vulnerabilities"`
+	}
+	if err := codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(&decoded); err != nil {
+		return Vulnerabilities{}, err
+	}
+	var result Vulnerabilities
+	for _, v := range decoded.Vulnerabilities {
+		result.add(v.NormalizedSeverity)
+	}
+	return result, nil
+}
+
+// NewVulnerabilityTagger returns an ImageTagger tagging images, and their
+// containers, with the counts of their known vulnerabilities, as the scanner
+// finds them every interval. Don't forget to Stop it.
+func NewVulnerabilityTagger(registry Registry, scanner Scanner, interval time.Duration) *ImageTagger {
+	return NewImageTagger("Vulnerabilities", registry, interval, func(image docker_client.APIImages) (map[string]string, error) {
+		vulnerabilities, err := scanner.Scan(imageRef(trimImageID(image.ID), image))
+		if err != nil {
+			return nil, err
+		}
+		return vulnerabilities.latests(), nil
+	})
+}
+
+func imageRef(id string, image docker_client.APIImages) ImageRef {
+	ref := ImageRef{ID: id}
+	if len(image.RepoTags) > 0 {
+		ref.Name = image.RepoTags[0]
+	}
+	if len(image.RepoDigests) > 0 {
+		if i := strings.LastIndex(image.RepoDigests[0], "@"); i >= 0 {
+			ref.Digest = image.RepoDigests[0][i+1:]
+		}
+	}
+	return ref
+}
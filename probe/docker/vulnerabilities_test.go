@@ -0,0 +1,130 @@
+package docker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/exec"
+	testexec "github.com/weaveworks/common/test/exec"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+type mockScanner struct {
+	images chan docker.ImageRef
+}
+
+func (s mockScanner) Scan(image docker.ImageRef) (docker.Vulnerabilities, error) {
+	s.images <- image
+	return docker.Vulnerabilities{Critical: 1, High: 2}, nil
+}
+
+func TestVulnerabilityTagger(t *testing.T) {
+	scanner := mockScanner{images: make(chan docker.ImageRef, 10)}
+	tagger := docker.NewVulnerabilityTagger(mockRegistryInstance, scanner, time.Hour)
+	defer tagger.Stop()
+
+	rpt := report.MakeReport()
+	rpt.ContainerImage.AddNode(report.MakeNodeWith(report.MakeContainerImageNodeID(imageID), map[string]string{docker.ImageID: imageID}))
+	rpt.Container.AddNode(report.MakeNodeWith(report.MakeContainerNodeID("ping"), map[string]string{docker.ImageID: imageID}))
+
+	// The first report only queues the scan
+	tagged, err := tagger.Tag(rpt.Copy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tagged.Container.Nodes[report.MakeContainerNodeID("ping")].Latest.Lookup(docker.ImageCVEsCritical); ok {
+		t.Errorf("Expected no vulnerabilities before the image is scanned")
+	}
+	select {
+	case image := <-scanner.images:
+		if want := (docker.ImageRef{ID: imageID, Name: "bang"}); want != image {
+			t.Errorf("Expected to scan %v, got %v", want, image)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the image to be scanned")
+	}
+
+	for _, topology := range []string{report.Container, report.ContainerImage} {
+		test.Poll(t, 100*time.Millisecond, map[string]string{docker.ImageCVEsCritical: "1", docker.ImageCVEsHigh: "2"}, func() interface{} {
+			tagged, _ := tagger.Tag(rpt.Copy())
+			result := map[string]string{}
+			nodes, _ := tagged.Topology(topology)
+			for _, n := range nodes.Nodes {
+				for _, key := range []string{docker.ImageCVEsCritical, docker.ImageCVEsHigh} {
+					result[key], _ = n.Latest.Lookup(key)
+				}
+			}
+			return result
+		})
+	}
+
+	// The image is not scanned again within the interval
+	select {
+	case image := <-scanner.images:
+		t.Errorf("Expected no other scan, got %v", image)
+	default:
+	}
+}
+
+func TestTrivyScanner(t *testing.T) {
+	oldExecCmd := exec.Command
+	defer func() { exec.Command = oldExecCmd }()
+	var args []string
+	exec.Command = func(name string, a ...string) exec.Cmd {
+		args = append([]string{name}, a...)
+		return testexec.NewMockCmdString(`{"SchemaVersion": 2, "Results": [
+			{"Target": "bang (debian 10.4)", "Vulnerabilities": [{"Severity": "CRITICAL"}, {"Severity": "HIGH"}, {"Severity": "LOW"}]},
+			{"Target": "Python", "Vulnerabilities": [{"Severity": "CRITICAL"}, {"Severity": "UNKNOWN"}]}
+		]}`)
+	}
+
+	scanner, err := docker.NewScanner("trivy", "http://trivy:4954")
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := scanner.Scan(docker.ImageRef{ID: imageID, Name: "bang"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (docker.Vulnerabilities{Critical: 2, High: 1, Low: 1}); want != have {
+		t.Errorf("Expected %v, got %v", want, have)
+	}
+	if want := []string{"trivy", "image", "--quiet", "--format", "json", "--server", "http://trivy:4954", "bang"}; !reflect.DeepEqual(want, args) {
+		t.Errorf("Expected to run %v, got %v", want, args)
+	}
+}
+
+func TestClairScanner(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/matcher/api/v1/vulnerability_report/sha256:1234" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"manifest_hash": "sha256:1234", "vulnerabilities": {
+			"1": {"normalized_severity": "Critical"},
+			"2": {"normalized_severity": "Medium"},
+			"3": {"normalized_severity": "Medium"}
+		}}`))
+	}))
+	defer server.Close()
+
+	scanner, err := docker.NewScanner("clair", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := scanner.Scan(docker.ImageRef{ID: imageID, Digest: "sha256:1234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (docker.Vulnerabilities{Critical: 1, Medium: 2}); want != have {
+		t.Errorf("Expected %v, got %v", want, have)
+	}
+	if _, err := scanner.Scan(docker.ImageRef{ID: imageID, Digest: "sha256:5678"}); err == nil {
+		t.Errorf("Expected an error for an image Clair has not indexed")
+	}
+}
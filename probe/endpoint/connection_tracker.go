@@ -12,24 +12,45 @@ import (
 
 // connectionTrackerConfig are the config options for the endpoint tracker.
 type connectionTrackerConfig struct {
-	HostID       string
-	HostName     string
-	SpyProcs     bool
-	UseConntrack bool
-	WalkProc     bool
-	UseEbpfConn  bool
-	ProcRoot     string
-	BufferSize   int
-	ProcessCache *process.CachingWalker
-	Scanner      procspy.ConnectionScanner
-	DNSSnooper   *DNSSnooper
+	HostID             string
+	HostName           string
+	ClusterScope       string
+	SpyProcs           bool
+	UseConntrack       bool
+	WalkProc           bool
+	UseEbpfConn        bool
+	SampleRTT          bool
+	SampleBytes        bool
+	TrackUDP           bool
+	ShortLivedSampling int
+	ProcRoot           string
+	BufferSize         int
+	ProcessCache       *process.CachingWalker
+	Scanner            procspy.ConnectionScanner
+	DNSSnooper         *DNSSnooper
+	SNISnooper         *SNISnooper
+	SharedDNS          SharedDNSCache
 }
 
 type connectionTracker struct {
 	conf            connectionTrackerConfig
 	flowWalker      flowWalker // Interface
+	udpFlowWalker   flowWalker
 	ebpfTracker     *EbpfTracker
 	reverseResolver *reverseResolver
+	tcpInfoSampler  tcpInfoSampler
+
+	// tcp_info sampled for the current report, by fourTuple.key(), with
+	// the bytes exchanged since the previous report
+	tcpInfos map[string]tcpInfo
+	// tcp_info of the previous report, with total byte counts
+	previousTCPInfos map[string]tcpInfo
+
+	// keys of the connections reported open in the current and the
+	// previous report, to tell short-lived connections apart
+	openTuples, previousOpenTuples map[string]struct{}
+	// number of short-lived connections seen so far, for sampling
+	shortLivedSeen int
 
 	// time of the previous ebpf failure, or zero if it didn't fail
 	ebpfLastFailureTime time.Time
@@ -38,7 +59,14 @@ type connectionTracker struct {
 func newConnectionTracker(conf connectionTrackerConfig) connectionTracker {
 	ct := connectionTracker{
 		conf:            conf,
-		reverseResolver: newReverseResolver(),
+		reverseResolver: newReverseResolver(conf.SharedDNS),
+	}
+	if conf.SampleRTT || conf.SampleBytes {
+		ct.tcpInfoSampler = newTCPInfoSampler()
+	}
+	// The eBPF tracker only sees TCP, so UDP flows always come from conntrack
+	if conf.TrackUDP {
+		ct.udpFlowWalker = newConntrackFlowWalker(conf.UseConntrack, conf.ProcRoot, conf.BufferSize, udpProto)
 	}
 	if conf.UseEbpfConn {
 		et, err := newEbpfTracker()
@@ -78,7 +106,7 @@ func (t *connectionTracker) useProcfs() {
 		t.conf.Scanner = procspy.NewConnectionScanner(t.conf.ProcessCache, t.conf.SpyProcs)
 	}
 	if t.flowWalker == nil {
-		t.flowWalker = newConntrackFlowWalker(t.conf.UseConntrack, t.conf.ProcRoot, t.conf.BufferSize)
+		t.flowWalker = newConntrackFlowWalker(t.conf.UseConntrack, t.conf.ProcRoot, t.conf.BufferSize, tcpProto)
 	}
 }
 
@@ -86,6 +114,20 @@ func (t *connectionTracker) useProcfs() {
 func (t *connectionTracker) ReportConnections(rpt *report.Report) {
 	hostNodeID := report.MakeHostNodeID(t.conf.HostID)
 
+	t.previousOpenTuples, t.openTuples = t.openTuples, map[string]struct{}{}
+	t.tcpInfos = nil
+	if t.tcpInfoSampler != nil {
+		infos, err := t.tcpInfoSampler.sampleTCPInfo()
+		if err != nil {
+			log.Debugf("Error sampling TCP connections: %v", err)
+		}
+		t.tcpInfos = t.countsSincePreviousSample(infos)
+	}
+
+	if t.udpFlowWalker != nil {
+		t.walkFlows(rpt, t.udpFlowWalker, report.UDP, map[string]fourTuple{})
+	}
+
 	if t.ebpfTracker != nil {
 		if !t.ebpfTracker.isDead() {
 			t.performEbpfTrack(rpt, hostNodeID)
@@ -118,15 +160,40 @@ func (t *connectionTracker) ReportConnections(rpt *report.Report) {
 
 	// consult the flowWalker for short-lived (conntracked) connections
 	seenTuples := map[string]fourTuple{}
-	t.flowWalker.walkFlows(func(f flow, alive bool) {
+	t.walkFlows(rpt, t.flowWalker, report.TCP, seenTuples)
+
+	if t.conf.WalkProc && t.conf.Scanner != nil {
+		t.performWalkProc(rpt, hostNodeID, seenTuples)
+		if t.conf.SpyProcs {
+			if err := t.reportListeners(rpt); err != nil {
+				log.Debugf("Error reporting listening sockets: %v", err)
+			}
+		}
+	}
+}
+
+func (t *connectionTracker) walkFlows(rpt *report.Report, walker flowWalker, protocol string, seenTuples map[string]fourTuple) {
+	walker.walkFlows(func(f flow, alive bool) {
 		tuple := flowToTuple(f)
 		seenTuples[tuple.key()] = tuple
-		t.addConnection(rpt, false, tuple, "", nil, nil)
+		if ok, weight := t.sampleConnection(tuple, protocol, alive); ok {
+			t.addConnection(rpt, false, tuple, protocol, "", nil, nil, weight)
+			if protocol == report.TCP && !alive && f.Independent.State == tcpClose {
+				t.countReset(rpt, tuple, weight)
+			}
+		}
 	})
+}
 
-	if t.conf.WalkProc && t.conf.Scanner != nil {
-		t.performWalkProc(rpt, hostNodeID, seenTuples)
+// countReset counts a connection that was reset on its source endpoint.
+// Conntrack moves TCP flows to the CLOSE state when it sees a RST, while
+// orderly shutdowns go through TIME_WAIT.
+func (t *connectionTracker) countReset(rpt *report.Report, ft fourTuple, weight int) {
+	if weight == 0 {
+		weight = 1
 	}
+	nodeID := report.MakeClusterEndpointNodeID(t.conf.ClusterScope, t.conf.HostID, "", ft.fromAddr, strconv.Itoa(int(ft.fromPort)), "")
+	rpt.Endpoint.AddNode(report.MakeNode(nodeID).WithCounters(map[string]int{TCPResets: weight}))
 }
 
 func (t *connectionTracker) existingFlows() map[string]fourTuple {
@@ -135,7 +202,7 @@ func (t *connectionTracker) existingFlows() map[string]fourTuple {
 		// log.Warnf("Not using conntrack: disabled")
 	} else if err := IsConntrackSupported(t.conf.ProcRoot); err != nil {
 		log.Warnf("Not using conntrack: not supported by the kernel: %s", err)
-	} else if existingFlows, err := existingConnections([]string{"--any-nat"}); err != nil {
+	} else if existingFlows, err := existingConnections([]string{"-p", tcpProto, "--any-nat"}); err != nil {
 		log.Errorf("conntrack existingConnections error: %v", err)
 	} else {
 		for _, f := range existingFlows {
@@ -160,7 +227,7 @@ func (t *connectionTracker) performWalkProc(rpt *report.Report, hostNodeID strin
 				report.HostNodeID: hostNodeID,
 			}
 		}
-		t.addConnection(rpt, incoming, tuple, namespaceID, fromNodeInfo, toNodeInfo)
+		t.addConnection(rpt, incoming, tuple, report.TCP, namespaceID, fromNodeInfo, toNodeInfo, 0)
 	}
 	return nil
 }
@@ -169,7 +236,7 @@ func (t *connectionTracker) performWalkProc(rpt *report.Report, hostNodeID strin
 // once to initialize ebpfTracker
 func (t *connectionTracker) getInitialState() {
 	var processCache *process.CachingWalker
-	walker := process.NewWalker(t.conf.ProcRoot, true)
+	walker := process.NewWalker(t.conf.ProcRoot, true, false, false)
 	processCache = process.NewCachingWalker(walker)
 	processCache.Tick()
 
@@ -203,27 +270,116 @@ func (t *connectionTracker) performEbpfTrack(rpt *report.Report, hostNodeID stri
 				report.HostNodeID: hostNodeID,
 			}
 		}
-		t.addConnection(rpt, e.incoming, e.tuple, e.networkNamespace, fromNodeInfo, toNodeInfo)
+		if ok, weight := t.sampleConnection(e.tuple, report.TCP, !e.closed); ok {
+			t.addConnection(rpt, e.incoming, e.tuple, report.TCP, e.networkNamespace, fromNodeInfo, toNodeInfo, weight)
+		}
 	})
 	return nil
 }
 
-func (t *connectionTracker) addConnection(rpt *report.Report, incoming bool, ft fourTuple, namespaceID string, extraFromNode, extraToNode map[string]string) {
+// sampleConnection tells whether a connection reported by an event-driven
+// tracker (conntrack or eBPF) should be added to the report, and how many
+// connections it stands for, or zero if it is not sampled. Connections
+// which opened and closed between two reports are sampled, one in
+// ShortLivedSampling.
+func (t *connectionTracker) sampleConnection(ft fourTuple, protocol string, alive bool) (bool, int) {
+	key := ft.key() + " " + protocol
+	if alive {
+		t.openTuples[key] = struct{}{}
+		return true, 0
+	}
+	if _, ok := t.previousOpenTuples[key]; ok || t.conf.ShortLivedSampling <= 1 {
+		return true, 0
+	}
+	t.shortLivedSeen++
+	if t.shortLivedSeen%t.conf.ShortLivedSampling != 0 {
+		return false, 0
+	}
+	return true, t.conf.ShortLivedSampling
+}
+
+// addConnection adds a connection, or UDP flow, to the report. A non-zero
+// weight is the estimated number of connections a sampled one stands for.
+func (t *connectionTracker) addConnection(rpt *report.Report, incoming bool, ft fourTuple, protocol, namespaceID string, extraFromNode, extraToNode map[string]string, weight int) {
 	if incoming {
 		ft = reverse(ft)
 		extraFromNode, extraToNode = extraToNode, extraFromNode
 	}
 	var (
-		fromNode = t.makeEndpointNode(namespaceID, ft.fromAddr, ft.fromPort, extraFromNode)
-		toNode   = t.makeEndpointNode(namespaceID, ft.toAddr, ft.toPort, extraToNode)
+		fromNode = t.makeEndpointNode(namespaceID, ft.fromAddr, ft.fromPort, protocol, extraFromNode)
+		toNode   = t.makeEndpointNode(namespaceID, ft.toAddr, ft.toPort, protocol, extraToNode)
 	)
+	if protocol != report.TCP {
+		t.addEndpointNodes(rpt, fromNode, toNode, weight)
+		return
+	}
+	// The server name found in the TLS handshake belongs to the server
+	// endpoint of the connection.
+	if sni, ok := t.conf.SNISnooper.serverNameFor(ft); ok {
+		if sni.serverAddr == ft.toAddr && sni.serverPort == ft.toPort {
+			toNode = toNode.WithSet(SNINames, report.MakeStringSet(sni.name))
+		} else if sni.serverAddr == ft.fromAddr && sni.serverPort == ft.fromPort {
+			fromNode = fromNode.WithSet(SNINames, report.MakeStringSet(sni.name))
+		}
+	}
+	// Byte and retransmit counters and the round-trip time are carried by
+	// the source endpoint of the connection. Counters add up when nodes are
+	// merged, so only count each connection once per report.
+	if info, ok := t.tcpInfos[ft.key()]; ok {
+		delete(t.tcpInfos, ft.key())
+		if t.conf.SampleRTT {
+			fromNode = fromNode.WithLatests(map[string]string{
+				RTT: strconv.FormatInt(int64(info.rtt/time.Microsecond), 10),
+			})
+		}
+		if t.conf.SampleBytes && info.hasBytes {
+			sent, received := info.bytesFrom(ft.fromAddr, ft.fromPort)
+			fromNode = fromNode.WithCounters(map[string]int{
+				EgressBytes:  int(sent),
+				IngressBytes: int(received),
+			})
+		}
+		if t.conf.SampleBytes {
+			// Only the local end's retransmits are known
+			fromNode = fromNode.WithCounters(map[string]int{
+				TCPRetransmits: int(info.retransmits),
+			})
+		}
+	}
+	t.addEndpointNodes(rpt, fromNode, toNode, weight)
+}
+
+func (t *connectionTracker) addEndpointNodes(rpt *report.Report, fromNode, toNode report.Node, weight int) {
+	if weight > 0 {
+		fromNode = fromNode.WithCounters(map[string]int{SampledConnections: weight})
+	}
 	rpt.Endpoint = rpt.Endpoint.AddNode(fromNode.WithAdjacent(toNode.ID))
 	rpt.Endpoint = rpt.Endpoint.AddNode(toNode)
 }
 
-func (t *connectionTracker) makeEndpointNode(namespaceID string, addr string, port uint16, extra map[string]string) report.Node {
+// countsSincePreviousSample turns the byte and retransmit counts of
+// infos, which are totals since the connections started, into the counts
+// since the previous sample, so they can be added up over reports.
+func (t *connectionTracker) countsSincePreviousSample(infos map[string]tcpInfo) map[string]tcpInfo {
+	result := make(map[string]tcpInfo, len(infos))
+	for key, info := range infos {
+		// A connection reusing the tuple starts counting from zero again
+		if previous, ok := t.previousTCPInfos[key]; ok && previous.tuple == info.tuple &&
+			previous.bytesSent <= info.bytesSent && previous.bytesReceived <= info.bytesReceived &&
+			previous.retransmits <= info.retransmits {
+			info.bytesSent -= previous.bytesSent
+			info.bytesReceived -= previous.bytesReceived
+			info.retransmits -= previous.retransmits
+		}
+		result[key] = info
+	}
+	t.previousTCPInfos = infos
+	return result
+}
+
+func (t *connectionTracker) makeEndpointNode(namespaceID string, addr string, port uint16, protocol string, extra map[string]string) report.Node {
 	portStr := strconv.Itoa(int(port))
-	node := report.MakeNodeWith(report.MakeEndpointNodeID(t.conf.HostID, namespaceID, addr, portStr), nil)
+	node := report.MakeNodeWith(report.MakeClusterEndpointNodeID(t.conf.ClusterScope, t.conf.HostID, namespaceID, addr, portStr, protocol), nil)
 	if names := t.conf.DNSSnooper.CachedNamesForIP(addr); len(names) > 0 {
 		node = node.WithSet(SnoopedDNSNames, report.MakeStringSet(names...))
 	}
@@ -243,6 +399,9 @@ func (t *connectionTracker) Stop() error {
 	if t.flowWalker != nil {
 		t.flowWalker.stop()
 	}
+	if t.udpFlowWalker != nil {
+		t.udpFlowWalker.stop()
+	}
 	t.reverseResolver.stop()
 	return nil
 }
@@ -0,0 +1,104 @@
+package endpoint
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestSampleShortLivedConnections(t *testing.T) {
+	var (
+		tracker = connectionTracker{conf: connectionTrackerConfig{ShortLivedSampling: 3}}
+		long    = fourTuple{"10.0.0.1", "10.0.0.2", 12345, 80}
+		sampled int
+		weight  int
+	)
+
+	// A connection seen open in the previous report is always reported
+	tracker.previousOpenTuples, tracker.openTuples = nil, map[string]struct{}{}
+	if ok, w := tracker.sampleConnection(long, report.TCP, true); !ok || w != 0 {
+		t.Fatalf("open connection not reported: %v, %d", ok, w)
+	}
+	tracker.previousOpenTuples, tracker.openTuples = tracker.openTuples, map[string]struct{}{}
+	if ok, w := tracker.sampleConnection(long, report.TCP, false); !ok || w != 0 {
+		t.Fatalf("closed long-lived connection not reported: %v, %d", ok, w)
+	}
+
+	for port := uint16(40000); port < 40009; port++ {
+		if ok, w := tracker.sampleConnection(fourTuple{"10.0.0.1", "10.0.0.2", port, 80}, report.TCP, false); ok {
+			sampled++
+			weight += w
+		}
+	}
+	if sampled != 3 || weight != 9 {
+		t.Errorf("want 3 sampled connections standing for 9, have %d standing for %d", sampled, weight)
+	}
+}
+
+func TestReportUDPFlows(t *testing.T) {
+	dns := flow{
+		Original: meta{
+			Layer3: layer3{SrcIP: "10.0.0.1", DstIP: "10.0.0.53"},
+			Layer4: layer4{SrcPort: 41234, DstPort: 53, Proto: udpProto},
+		},
+		Reply: meta{
+			Layer3: layer3{SrcIP: "10.0.0.53", DstIP: "10.0.0.1"},
+			Layer4: layer4{SrcPort: 53, DstPort: 41234, Proto: udpProto},
+		},
+	}
+	tracker := connectionTracker{
+		conf:            connectionTrackerConfig{HostID: "host"},
+		flowWalker:      &mockFlowWalker{},
+		udpFlowWalker:   &mockFlowWalker{flows: []flow{dns}},
+		reverseResolver: newReverseResolver(nil),
+	}
+	defer tracker.Stop()
+
+	rpt := report.MakeReport()
+	tracker.ReportConnections(&rpt)
+	var (
+		client = report.MakeEndpointNodeIDWithProtocol("host", "", "10.0.0.1", "41234", report.UDP)
+		server = report.MakeEndpointNodeIDWithProtocol("host", "", "10.0.0.53", "53", report.UDP)
+	)
+	if node, ok := rpt.Endpoint.Nodes[client]; !ok || !node.Adjacency.Contains(server) {
+		t.Errorf("UDP flow not reported: %v", rpt.Endpoint.Nodes)
+	}
+}
+
+func TestReportResets(t *testing.T) {
+	reset := flow{
+		Type: updateType,
+		Original: meta{
+			Layer3: layer3{SrcIP: "10.0.0.1", DstIP: "10.0.0.2"},
+			Layer4: layer4{SrcPort: 41234, DstPort: 80, Proto: tcpProto},
+		},
+		Reply: meta{
+			Layer3: layer3{SrcIP: "10.0.0.2", DstIP: "10.0.0.1"},
+			Layer4: layer4{SrcPort: 80, DstPort: 41234, Proto: tcpProto},
+		},
+		Independent: meta{ID: 1, State: tcpClose},
+	}
+	walker := &conntrackWalker{activeFlows: map[int64]flow{}, protocol: tcpProto}
+	tracker := connectionTracker{
+		conf:            connectionTrackerConfig{HostID: "host"},
+		flowWalker:      walker,
+		reverseResolver: newReverseResolver(nil),
+	}
+	defer tracker.reverseResolver.stop()
+
+	// Reset connections are only counted once they are gone
+	walker.handleFlow(reset, false)
+	client := report.MakeEndpointNodeID("host", "", "10.0.0.1", "41234")
+	for _, gone := range []bool{false, true} {
+		if gone {
+			reset.Type = destroyType
+			walker.handleFlow(reset, false)
+		}
+		rpt := report.MakeReport()
+		tracker.ReportConnections(&rpt)
+		resets, _ := rpt.Endpoint.Nodes[client].Counters.Lookup(TCPResets)
+		if want := map[bool]int{false: 0, true: 1}[gone]; resets != want {
+			t.Errorf("want %d resets, have %d", want, resets)
+		}
+	}
+}
@@ -23,7 +23,9 @@ const (
 	eventsPath = "sys/net/netfilter/nf_conntrack_events"
 
 	timeWait    = "TIME_WAIT"
+	tcpClose    = "CLOSE"
 	tcpProto    = "tcp"
+	udpProto    = "udp"
 	newType     = "[NEW]"
 	updateType  = "[UPDATE]"
 	destroyType = "[DESTROY]"
@@ -82,12 +84,14 @@ type conntrackWalker struct {
 	activeFlows   map[int64]flow // active flows in state != TIME_WAIT
 	bufferedFlows []flow         // flows coming out of activeFlows spend 1 walk cycle here
 	bufferSize    int
+	protocol      string
 	args          []string
 	quit          chan struct{}
 }
 
-// newConntracker creates and starts a new conntracker.
-func newConntrackFlowWalker(useConntrack bool, procRoot string, bufferSize int, args ...string) flowWalker {
+// newConntracker creates and starts a new conntracker, tracking the flows
+// of a single protocol, tcp or udp.
+func newConntrackFlowWalker(useConntrack bool, procRoot string, bufferSize int, protocol string, args ...string) flowWalker {
 	if !useConntrack {
 		return nilFlowWalker{}
 	} else if err := IsConntrackSupported(procRoot); err != nil {
@@ -97,6 +101,7 @@ func newConntrackFlowWalker(useConntrack bool, procRoot string, bufferSize int,
 	result := &conntrackWalker{
 		activeFlows: map[int64]flow{},
 		bufferSize:  bufferSize,
+		protocol:    protocol,
 		args:        args,
 		quit:        make(chan struct{}),
 	}
@@ -160,7 +165,7 @@ func logPipe(prefix string, reader io.Reader) {
 func (c *conntrackWalker) run() {
 	// Fork another conntrack, just to capture existing connections
 	// for which we don't get events
-	existingFlows, err := existingConnections(c.args)
+	existingFlows, err := existingConnections(append([]string{"-p", c.protocol}, c.args...))
 	if err != nil {
 		log.Errorf("conntrack existingConnections error: %v", err)
 		return
@@ -171,7 +176,7 @@ func (c *conntrackWalker) run() {
 
 	args := append([]string{
 		"--buffer-size", strconv.Itoa(c.bufferSize), "-E",
-		"-o", "id", "-p", "tcp"}, c.args...,
+		"-o", "id", "-p", c.protocol}, c.args...,
 	)
 	cmd := exec.Command("conntrack", args...)
 	stdout, err := cmd.StdoutPipe()
@@ -351,11 +356,12 @@ func decodeStreamedFlow(scanner *bufio.Scanner) (flow, error) {
 	}
 
 	f.Reply.Layer4.Proto = f.Original.Layer4.Proto
+	clearStatelessState(&f)
 	return f, nil
 }
 
 func existingConnections(conntrackWalkerArgs []string) ([]flow, error) {
-	args := append([]string{"-L", "-o", "id", "-p", "tcp"}, conntrackWalkerArgs...)
+	args := append([]string{"-L", "-o", "id"}, conntrackWalkerArgs...)
 	cmd := exec.Command("conntrack", args...)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -420,9 +426,18 @@ func decodeDumpedFlow(scanner *bufio.Scanner) (flow, error) {
 	}
 
 	f.Reply.Layer4.Proto = f.Original.Layer4.Proto
+	clearStatelessState(&f)
 	return f, nil
 }
 
+// clearStatelessState forgets the state parsed for protocols without one,
+// like UDP, where the field holds the first key-value instead.
+func clearStatelessState(f *flow) {
+	if f.Original.Layer4.Proto != tcpProto {
+		f.Independent.State = ""
+	}
+}
+
 func (c *conntrackWalker) stop() {
 	c.Lock()
 	defer c.Unlock()
@@ -436,17 +451,16 @@ func (c *conntrackWalker) handleFlow(f flow, forceAdd bool) {
 	c.Lock()
 	defer c.Unlock()
 
-	// For not, I'm only interested in tcp connections - there is too much udp
-	// traffic going on (every container talking to weave dns, for example) to
-	// render nicely. TODO: revisit this.
-	if f.Original.Layer4.Proto != tcpProto {
+	if f.Original.Layer4.Proto != c.protocol {
 		return
 	}
 
 	// Ignore flows for which we never saw an update; they are likely
-	// incomplete or wrong.  See #1462.
+	// incomplete or wrong.  See #1462. UDP flows only get an update once
+	// there is a reply, which one-way traffic like syslog or statsd never
+	// gets, so we take them from the start.
 	switch {
-	case forceAdd || f.Type == updateType:
+	case forceAdd || f.Type == updateType || (f.Type == newType && f.Original.Layer4.Proto == udpProto):
 		if f.Independent.State != timeWait {
 			c.activeFlows[f.Independent.ID] = f
 		} else if _, ok := c.activeFlows[f.Independent.ID]; ok {
@@ -251,3 +251,45 @@ var wantDumpedFlows = []flow{
 func TestDumpedFlowDecoding(t *testing.T) {
 	testFlowDecoding(t, dumpedFlowsSource, wantDumpedFlows, decodeDumpedFlow)
 }
+
+// Obtained through conntrack -E -p udp -o id
+const streamedUDPFlowsSource = `    [NEW] udp      17 30 src=10.0.0.1 dst=10.0.0.53 sport=41234 dport=53 [UNREPLIED] src=10.0.0.53 dst=10.0.0.1 sport=53 dport=41234 id=1195269568
+    [NEW] udp      17 30 src=10.0.0.1 dst=10.0.0.2 sport=43122 dport=514 [UNREPLIED] src=10.0.0.2 dst=10.0.0.1 sport=514 dport=43122 id=1195269632
+ [UPDATE] udp      17 29 src=10.0.0.1 dst=10.0.0.53 sport=41234 dport=53 src=10.0.0.53 dst=10.0.0.1 sport=53 dport=41234 id=1195269568
+[DESTROY] udp      17 src=10.0.0.1 dst=10.0.0.53 sport=41234 dport=53 src=10.0.0.53 dst=10.0.0.1 sport=53 dport=41234 id=1195269568`
+
+func TestUDPFlows(t *testing.T) {
+	walker := &conntrackWalker{activeFlows: map[int64]flow{}, protocol: udpProto}
+	scanner := bufio.NewScanner(strings.NewReader(streamedUDPFlowsSource))
+	for {
+		f, err := decodeStreamedFlow(scanner)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Unexpected decoding error: %v", err)
+		}
+		if f.Independent.State != "" {
+			t.Errorf("Unexpected state for a UDP flow: %q", f.Independent.State)
+		}
+		walker.handleFlow(f, false)
+	}
+
+	// The unreplied syslog flow is still active, the DNS one came and went
+	var active, gone []int
+	walker.walkFlows(func(f flow, alive bool) {
+		if alive {
+			active = append(active, f.Original.Layer4.DstPort)
+		} else {
+			gone = append(gone, f.Original.Layer4.DstPort)
+		}
+	})
+	if len(active) != 1 || active[0] != 514 || len(gone) != 1 || gone[0] != 53 {
+		t.Errorf("Unexpected UDP flows: active %v, gone %v", active, gone)
+	}
+
+	// TCP flows are left to the TCP walker
+	walker.handleFlow(wantStreamedFlows[3], true)
+	if len(walker.activeFlows) != 1 {
+		t.Errorf("Unexpected TCP flow tracked: %v", walker.activeFlows)
+	}
+}
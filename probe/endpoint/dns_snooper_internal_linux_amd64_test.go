@@ -0,0 +1,100 @@
+package endpoint
+
+import (
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+func dnsResponse(name string, answers ...layers.DNSResourceRecord) *layers.DNS {
+	return &layers.DNS{
+		QR:        true,
+		Questions: []layers.DNSQuestion{{Name: []byte(name), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+		Answers:   answers,
+	}
+}
+
+func aRecord(name, ip string, ttl uint32) layers.DNSResourceRecord {
+	return layers.DNSResourceRecord{Name: []byte(name), Type: layers.DNSTypeA, Class: layers.DNSClassIN, IP: net.ParseIP(ip), TTL: ttl}
+}
+
+func TestDNSSnooperExpiry(t *testing.T) {
+	var (
+		start = time.Unix(1000, 0)
+		now   = start
+		s     = newDNSSnooper(time.Minute)
+	)
+	s.now = func() time.Time { return now }
+	names := func() []string {
+		result := s.CachedNamesForIP("1.2.3.4")
+		sort.Strings(result)
+		return result
+	}
+
+	s.processDNSMessage(dnsResponse("short.example.com", aRecord("short.example.com", "1.2.3.4", 30)))
+	s.processDNSMessage(dnsResponse("cdn.example.com",
+		cnameRecord("cdn.example.com", "edge.example.net", 600),
+		aRecord("edge.example.net", "1.2.3.4", 300),
+	))
+
+	for _, c := range []struct {
+		after time.Duration
+		want  []string
+	}{
+		{0, []string{"cdn.example.com", "short.example.com"}},
+		{90 * time.Second, []string{"cdn.example.com", "short.example.com"}},
+		{91 * time.Second, []string{"cdn.example.com"}},
+		{361 * time.Second, []string{}},
+	} {
+		now = start.Add(c.after)
+		if have := names(); !reflect.DeepEqual(c.want, have) {
+			t.Errorf("after %s: want %v, have %v", c.after, c.want, have)
+		}
+	}
+
+	// Seeing the name again refreshes it
+	s.processDNSMessage(dnsResponse("short.example.com", aRecord("short.example.com", "1.2.3.4", 30)))
+	if want, have := []string{"short.example.com"}, names(); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func cnameRecord(name, cname string, ttl uint32) layers.DNSResourceRecord {
+	return layers.DNSResourceRecord{Name: []byte(name), Type: layers.DNSTypeCNAME, Class: layers.DNSClassIN, CNAME: []byte(cname), TTL: ttl}
+}
+
+func TestDNSSnooperCNAMEChain(t *testing.T) {
+	s := newDNSSnooper(0)
+	now := time.Unix(1000, 0)
+	s.now = func() time.Time { return now }
+
+	// Out of order and with mixed case, as servers are allowed to answer
+	s.processDNSMessage(dnsResponse("www.example.com",
+		aRecord("EDGE.cdn.example.net", "1.2.3.4", 300),
+		cnameRecord("shop.example.com.cdn.example.net", "edge.cdn.example.net", 60),
+		cnameRecord("www.example.com", "shop.example.com.cdn.example.net", 3600),
+		aRecord("unrelated.example.org", "5.6.7.8", 300),
+	))
+	// Loops don't hang the snooper
+	s.processDNSMessage(dnsResponse("loop.example.com",
+		cnameRecord("loop.example.com", "loop.example.net", 60),
+		cnameRecord("loop.example.net", "loop.example.com", 60),
+	))
+
+	if want, have := []string{"www.example.com"}, s.CachedNamesForIP("1.2.3.4"); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if want, have := []string{}, s.CachedNamesForIP("5.6.7.8"); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	// The shortest TTL of the chain applies
+	now = now.Add(61 * time.Second)
+	if want, have := []string{}, s.CachedNamesForIP("1.2.3.4"); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
@@ -1,10 +1,10 @@
 package endpoint
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"math"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,6 +20,7 @@ const (
 	maxReverseDNSrecords        = 10000
 	maxLogsPerDecodingError     = 4
 	maxDecodingErrorCardinality = 1000
+	maxCNAMEChainLength         = 16
 )
 
 // DNSSnooper is a snopper of DNS queries
@@ -28,28 +29,38 @@ type DNSSnooper struct {
 	pcapHandle *pcap.Handle
 	// gcache is goroutine-safe, but the cached values aren't
 	reverseDNSMutex     sync.RWMutex
-	reverseDNSCache     gcache.Cache
+	reverseDNSCache     gcache.Cache      // ip -> map[domain]expiry
 	decodingErrorCounts map[string]uint64 // for limiting
+	// how long names are kept after their TTL runs out, since
+	// connections usually outlive the lookups which started them
+	maxAge time.Duration
+	now    func() time.Time
 }
 
-// NewDNSSnooper creates a new snooper of DNS queries
-func NewDNSSnooper() (*DNSSnooper, error) {
+// NewDNSSnooper creates a new snooper of DNS queries. Snooped names
+// are forgotten maxAge after the TTL of their records expired.
+func NewDNSSnooper(maxAge time.Duration) (*DNSSnooper, error) {
 	pcapHandle, err := newPcapHandle()
 	if err != nil {
 		return nil, err
 	}
-	reverseDNSCache := gcache.New(maxReverseDNSrecords).LRU().Build()
 
-	s := &DNSSnooper{
-		stop:                make(chan struct{}),
-		pcapHandle:          pcapHandle,
-		reverseDNSCache:     reverseDNSCache,
-		decodingErrorCounts: map[string]uint64{},
-	}
+	s := newDNSSnooper(maxAge)
+	s.stop = make(chan struct{})
+	s.pcapHandle = pcapHandle
 	go s.run()
 	return s, nil
 }
 
+func newDNSSnooper(maxAge time.Duration) *DNSSnooper {
+	return &DNSSnooper{
+		reverseDNSCache:     gcache.New(maxReverseDNSrecords).LRU().Build(),
+		decodingErrorCounts: map[string]uint64{},
+		maxAge:              maxAge,
+		now:                 time.Now,
+	}
+}
+
 func newPcapHandle() (*pcap.Handle, error) {
 	inactive, err := pcap.NewInactiveHandle("any")
 	if err != nil {
@@ -94,7 +105,8 @@ func newPcapHandle() (*pcap.Handle, error) {
 }
 
 // CachedNamesForIP obtains the domains associated to an IP,
-// obtained while snooping A-record queries
+// obtained while snooping A-record queries. Expired domains are
+// dropped along the way.
 func (s *DNSSnooper) CachedNamesForIP(ip string) []string {
 	result := []string{}
 	if s == nil {
@@ -104,11 +116,16 @@ func (s *DNSSnooper) CachedNamesForIP(ip string) []string {
 	if err != nil {
 		return result
 	}
-	s.reverseDNSMutex.RLock()
-	for domain := range domains.(map[string]struct{}) {
+	now := s.now()
+	s.reverseDNSMutex.Lock()
+	for domain, expiry := range domains.(map[string]time.Time) {
+		if now.After(expiry) {
+			delete(domains.(map[string]time.Time), domain)
+			continue
+		}
 		result = append(result, domain)
 	}
-	s.reverseDNSMutex.RUnlock()
+	s.reverseDNSMutex.Unlock()
 
 	return result
 }
@@ -245,17 +262,37 @@ func (s *DNSSnooper) processDNSMessage(dns *layers.DNS) {
 	var (
 		domainQueried = question.Name
 		records       = append(dns.Answers, dns.Additionals...)
-		ips           = map[string]struct{}{}
-		alias         []byte
+		ips           = map[string]uint32{} // ip -> TTL
+		aliases       = map[string]layers.DNSResourceRecord{}
+		chain         = map[string]uint32{} // name -> TTL of the chain up to it
 	)
 
-	// Traverse records for a CNAME first since the DNS RFCs don't seem to guarantee it
-	// appearing before its A-records
+	// Collect the CNAMEs first since the DNS RFCs don't seem to guarantee them
+	// appearing before their A-records, or in chain order
 	for _, record := range records {
-		if record.Type == layers.DNSTypeCNAME && record.Class == layers.DNSClassIN && bytes.Equal(domainQueried, record.Name) {
-			alias = record.CNAME
+		if record.Type == layers.DNSTypeCNAME && record.Class == layers.DNSClassIN {
+			aliases[strings.ToLower(string(record.Name))] = record
+		}
+	}
+
+	// Follow the chain from the name queried (which is what the
+	// application asked for, rather than the name of e.g. a CDN edge).
+	// The name is only valid for as long as all the records of the chain
+	// are, and names are case-insensitive.
+	name, ttl := strings.ToLower(string(domainQueried)), uint32(math.MaxUint32)
+	for len(chain) <= maxCNAMEChainLength {
+		if _, ok := chain[name]; ok {
+			break // loop
+		}
+		chain[name] = ttl
+		alias, ok := aliases[name]
+		if !ok {
 			break
 		}
+		if alias.TTL < ttl {
+			ttl = alias.TTL
+		}
+		name = strings.ToLower(string(alias.CNAME))
 	}
 
 	// Finally, get the answer
@@ -263,21 +300,25 @@ func (s *DNSSnooper) processDNSMessage(dns *layers.DNS) {
 		if record.Type != layers.DNSTypeA || record.Class != layers.DNSClassIN {
 			continue
 		}
-		if bytes.Equal(domainQueried, record.Name) || (alias != nil && bytes.Equal(alias, record.Name)) {
-			ips[record.IP.String()] = struct{}{}
+		if ttl, ok := chain[strings.ToLower(string(record.Name))]; ok {
+			if record.TTL < ttl {
+				ttl = record.TTL
+			}
+			ips[record.IP.String()] = ttl
 		}
 	}
 
 	// Update cache
 	newDomain := string(domainQueried)
 	log.Debugf("DNSSnooper: caught DNS lookup: %s -> %v", newDomain, ips)
-	for ip := range ips {
+	now := s.now()
+	for ip, ttl := range ips {
+		expiry := now.Add(time.Duration(ttl)*time.Second + s.maxAge)
 		if existingDomains, err := s.reverseDNSCache.Get(ip); err != nil {
-			s.reverseDNSCache.Set(ip, map[string]struct{}{newDomain: {}})
+			s.reverseDNSCache.Set(ip, map[string]time.Time{newDomain: expiry})
 		} else {
-			// TODO: Be smarter about the expiration of entries with pre-existing associated domains
 			s.reverseDNSMutex.Lock()
-			existingDomains.(map[string]struct{})[newDomain] = struct{}{}
+			existingDomains.(map[string]time.Time)[newDomain] = expiry
 			s.reverseDNSMutex.Unlock()
 		}
 	}
@@ -6,11 +6,13 @@
 
 package endpoint
 
+import "time"
+
 // DNSSnooper is a snopper of DNS queries
 type DNSSnooper struct{}
 
 // NewDNSSnooper creates a new snooper of DNS queries
-func NewDNSSnooper() (*DNSSnooper, error) {
+func NewDNSSnooper(maxAge time.Duration) (*DNSSnooper, error) {
 	return nil, nil
 }
 
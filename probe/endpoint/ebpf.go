@@ -25,6 +25,7 @@ type ebpfConnection struct {
 	networkNamespace string
 	incoming         bool
 	pid              int
+	closed           bool
 }
 
 // EbpfTracker contains the sets of open and closed TCP connections.
@@ -36,6 +37,7 @@ type EbpfTracker struct {
 	stopping        bool
 	dead            bool
 	lastTimestampV4 uint64
+	lastTimestampV6 uint64
 
 	// debugBPF specifies if EbpfTracker must be started in debug mode. This
 	// allows to easily debug issues like:
@@ -110,6 +112,19 @@ func newEbpfTracker() (*EbpfTracker, error) {
 
 // TCPEventV4 handles IPv4 TCP events from the eBPF tracer
 func (t *EbpfTracker) TCPEventV4(e tracer.TcpV4) {
+	tuple := fourTuple{e.SAddr.String(), e.DAddr.String(), e.SPort, e.DPort}
+	t.tcpEvent(&t.lastTimestampV4, e.Timestamp, e.Type, e.Pid, e.Fd, e.NetNS, tuple)
+}
+
+// TCPEventV6 handles IPv6 TCP events from the eBPF tracer
+func (t *EbpfTracker) TCPEventV6(e tracer.TcpV6) {
+	tuple := fourTuple{e.SAddr.String(), e.DAddr.String(), e.SPort, e.DPort}
+	t.tcpEvent(&t.lastTimestampV6, e.Timestamp, e.Type, e.Pid, e.Fd, e.NetNS, tuple)
+}
+
+// tcpEvent handles the TCP events of either family. Events of each
+// family come in order of their own, so they are checked separately.
+func (t *EbpfTracker) tcpEvent(lastTimestamp *uint64, timestamp uint64, eventType tracer.EventType, pid, fd, netNS uint32, tuple fourTuple) {
 	if t.debugBPF {
 		debugBPFFile := "/var/run/scope/debug-bpf"
 		b, err := ioutil.ReadFile("/var/run/scope/debug-bpf")
@@ -121,42 +136,35 @@ func (t *EbpfTracker) TCPEventV4(e tracer.TcpV4) {
 		}
 	}
 
-	if t.lastTimestampV4 > e.Timestamp {
+	if *lastTimestamp > timestamp {
 		// A kernel bug can cause the timestamps to be wrong (e.g. on Ubuntu with Linux 4.4.0-47.68)
 		// Upgrading the kernel will fix the problem. For further info see:
 		// https://github.com/iovisor/bcc/issues/790#issuecomment-263704235
 		// https://github.com/weaveworks/scope/issues/2334
-		log.Errorf("tcp tracer received event with timestamp %v even though the last timestamp was %v. Stopping the eBPF tracker.", e.Timestamp, t.lastTimestampV4)
+		log.Errorf("tcp tracer received event with timestamp %v even though the last timestamp was %v. Stopping the eBPF tracker.", timestamp, *lastTimestamp)
 		t.stop()
 		return
 	}
 
-	t.lastTimestampV4 = e.Timestamp
+	*lastTimestamp = timestamp
 
-	if e.Type == tracer.EventFdInstall {
-		t.handleFdInstall(e.Type, int(e.Pid), int(e.Fd))
+	if eventType == tracer.EventFdInstall {
+		t.handleFdInstall(eventType, int(pid), int(fd))
 	} else {
-		tuple := fourTuple{e.SAddr.String(), e.DAddr.String(), e.SPort, e.DPort}
-		t.handleConnection(e.Type, tuple, int(e.Pid), strconv.Itoa(int(e.NetNS)))
+		t.handleConnection(eventType, tuple, int(pid), strconv.Itoa(int(netNS)))
 	}
 }
 
-// TCPEventV6 handles IPv6 TCP events from the eBPF tracer. This is
-// currently a no-op.
-func (t *EbpfTracker) TCPEventV6(e tracer.TcpV6) {
-	// TODO: IPv6 not supported in Scope
-}
-
 // LostV4 handles IPv4 TCP event misses from the eBPF tracer.
 func (t *EbpfTracker) LostV4(count uint64) {
 	log.Errorf("tcp tracer lost %d events. Stopping the eBPF tracker", count)
 	t.stop()
 }
 
-// LostV6 handles IPv4 TCP event misses from the eBPF tracer. This is
-// currently a no-op.
+// LostV6 handles IPv6 TCP event misses from the eBPF tracer.
 func (t *EbpfTracker) LostV6(count uint64) {
-	// TODO: IPv6 not supported in Scope
+	log.Errorf("tcp tracer lost %d IPv6 events. Stopping the eBPF tracker", count)
+	t.stop()
 }
 
 func tupleFromPidFd(pid int, fd int) (tuple fourTuple, netns string, ok bool) {
@@ -257,6 +265,7 @@ func (t *EbpfTracker) handleConnection(ev tracer.EventType, tuple fourTuple, pid
 		}
 		if deadConn, ok := t.openConnections[tuple]; ok {
 			delete(t.openConnections, tuple)
+			deadConn.closed = true
 			t.closedConnections = append(t.closedConnections, deadConn)
 		} else {
 			log.Debugf("EbpfTracker: unmatched close event: %s pid=%d netns=%s", tuple, pid, networkNamespace)
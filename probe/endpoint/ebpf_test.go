@@ -233,3 +233,34 @@ func TestInvalidTimeStampDead(t *testing.T) {
 		t.Errorf("expected ebpfTracker to be set to dead after events with wrong order")
 	}
 }
+
+func TestTCPEventV6(t *testing.T) {
+	var (
+		mockEbpfTracker = newMockEbpfTracker()
+		event           = tracer.TcpV6{
+			Timestamp: 5,
+			Type:      tracer.EventConnect,
+			Pid:       43,
+			SAddr:     net.ParseIP("fd00::2"),
+			DAddr:     net.ParseIP("2001:db8::1"),
+			SPort:     6789,
+			DPort:     443,
+			NetNS:     123456789,
+		}
+		want = fourTuple{"fd00::2", "2001:db8::1", 6789, 443}
+	)
+	// IPv6 events are ordered independently from IPv4 ones
+	mockEbpfTracker.lastTimestampV4 = 10
+	mockEbpfTracker.TCPEventV6(event)
+
+	var have []fourTuple
+	mockEbpfTracker.walkConnections(func(e ebpfConnection) {
+		have = append(have, e.tuple)
+	})
+	if !reflect.DeepEqual([]fourTuple{want}, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if mockEbpfTracker.isDead() {
+		t.Errorf("expected ebpfTracker to be alive")
+	}
+}
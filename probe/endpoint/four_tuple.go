@@ -2,7 +2,9 @@ package endpoint
 
 import (
 	"fmt"
+	"net"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -15,15 +17,20 @@ type fourTuple struct {
 }
 
 func (t fourTuple) String() string {
-	return fmt.Sprintf("%s:%d-%s:%d", t.fromAddr, t.fromPort, t.toAddr, t.toPort)
+	return fmt.Sprintf("%s-%s", hostPort(t.fromAddr, t.fromPort), hostPort(t.toAddr, t.toPort))
+}
+
+// hostPort brackets IPv6 addresses, so they can't be mistaken for ports.
+func hostPort(addr string, port uint16) string {
+	return net.JoinHostPort(addr, strconv.Itoa(int(port)))
 }
 
 // key is a sortable direction-independent key for tuples, used to look up a
 // fourTuple when you are unsure of its direction.
 func (t fourTuple) key() string {
 	key := []string{
-		fmt.Sprintf("%s:%d", t.fromAddr, t.fromPort),
-		fmt.Sprintf("%s:%d", t.toAddr, t.toPort),
+		hostPort(t.fromAddr, t.fromPort),
+		hostPort(t.toAddr, t.toPort),
 	}
 	sort.Strings(key)
 	return strings.Join(key, " ")
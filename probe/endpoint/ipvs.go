@@ -0,0 +1,97 @@
+package endpoint
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/weaveworks/common/fs"
+	"github.com/weaveworks/scope/report"
+)
+
+// The IPVS connection table, only there once the ip_vs module is loaded.
+const ipvsConnectionsPath = "net/ip_vs_conn"
+
+// ipvsConnection is a connection forwarded by IPVS from a virtual service
+// to one of its real servers.
+type ipvsConnection struct {
+	protocol    string
+	clientIP    string
+	clientPort  int
+	virtualIP   string
+	virtualPort int
+	realIP      string
+	realPort    int
+}
+
+// readIPVSConnections reads the IPVS connection table, e.g.
+//
+//	Pro FromIP   FPrt ToIP     TPrt DestIP   DPrt State       Expires PEName PEData
+//	TCP 0A2001C8 B7E4 0A600001 01BB 0A2001C7 1F90 ESTABLISHED     895
+//
+// IPv4 addresses are in hex, IPv6 addresses in their long form.
+func readIPVSConnections(path string) ([]ipvsConnection, error) {
+	contents, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		result  []ipvsConnection
+		scanner = bufio.NewScanner(bytes.NewReader(contents))
+	)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 7 || fields[0] == "Pro" {
+			continue
+		}
+		var protocol string
+		switch fields[0] {
+		case "TCP":
+			protocol = report.TCP
+		case "UDP":
+			protocol = report.UDP
+		default:
+			continue
+		}
+		var (
+			c      = ipvsConnection{protocol: protocol}
+			ips    = []*string{&c.clientIP, &c.virtualIP, &c.realIP}
+			ports  = []*int{&c.clientPort, &c.virtualPort, &c.realPort}
+			failed error
+		)
+		for i := range ips {
+			if *ips[i], failed = parseIPVSAddress(fields[2*i+1]); failed != nil {
+				break
+			}
+			var port uint64
+			if port, failed = strconv.ParseUint(fields[2*i+2], 16, 16); failed != nil {
+				break
+			}
+			*ports[i] = int(port)
+		}
+		if failed != nil {
+			return result, fmt.Errorf("Error parsing IPVS connection %q: %v", scanner.Text(), failed)
+		}
+		result = append(result, c)
+	}
+	return result, scanner.Err()
+}
+
+func parseIPVSAddress(s string) (string, error) {
+	if strings.Contains(s, ":") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return "", fmt.Errorf("invalid address: %s", s)
+		}
+		return ip.String(), nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != net.IPv4len {
+		return "", fmt.Errorf("invalid address: %s", s)
+	}
+	return net.IP(b).String(), nil
+}
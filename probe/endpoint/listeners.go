@@ -0,0 +1,70 @@
+package endpoint
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Columns of the listening ports table
+const (
+	ListeningPortsTablePrefix = report.ListeningPortsTablePrefix
+	ListeningPortPort         = "port"
+	ListeningPortProtocol     = "protocol"
+	ListeningPortAddress      = "address"
+)
+
+// ListeningPortsTableTemplates describes the table of listening ports on
+// process and container nodes.
+var ListeningPortsTableTemplates = report.TableTemplates{
+	ListeningPortsTablePrefix: {
+		ID:     ListeningPortsTablePrefix,
+		Label:  "Listening ports",
+		Type:   report.MulticolumnTableType,
+		Prefix: ListeningPortsTablePrefix,
+		Columns: []report.Column{
+			{ID: ListeningPortPort, Label: "Port"},
+			{ID: ListeningPortProtocol, Label: "Protocol"},
+			{ID: ListeningPortAddress, Label: "Bind address"},
+		},
+	},
+}
+
+// reportListeners adds the listening sockets found by the scanner to the
+// nodes of the processes owning them.
+func (t *connectionTracker) reportListeners(rpt *report.Report) error {
+	listeners, err := t.conf.Scanner.Listeners()
+	if err != nil {
+		return err
+	}
+	rows := map[uint][]report.Row{}
+	for l := listeners.Next(); l != nil; l = listeners.Next() {
+		if l.Proc.PID == 0 {
+			continue
+		}
+		rows[l.Proc.PID] = append(rows[l.Proc.PID], listenerRow(report.TCP, l.LocalAddress, l.LocalPort))
+	}
+	for pid, pidRows := range rows {
+		nodeID := report.MakeProcessNodeID(t.conf.HostID, strconv.FormatUint(uint64(pid), 10))
+		rpt.Process.AddNode(report.MakeNode(nodeID).AddPrefixMulticolumnTable(ListeningPortsTablePrefix, pidRows))
+	}
+	rpt.Process = rpt.Process.WithTableTemplates(ListeningPortsTableTemplates)
+	rpt.Container = rpt.Container.WithTableTemplates(ListeningPortsTableTemplates)
+	return nil
+}
+
+func listenerRow(protocol string, address net.IP, port uint16) report.Row {
+	var (
+		addr    = address.String()
+		portStr = strconv.Itoa(int(port))
+	)
+	return report.Row{
+		ID: net.JoinHostPort(addr, portStr) + "/" + protocol,
+		Entries: map[string]string{
+			ListeningPortPort:     portStr,
+			ListeningPortProtocol: protocol,
+			ListeningPortAddress:  addr,
+		},
+	}
+}
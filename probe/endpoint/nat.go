@@ -1,8 +1,10 @@
 package endpoint
 
 import (
+	"net"
 	"strconv"
 
+	log "github.com/Sirupsen/logrus"
 	"github.com/weaveworks/scope/report"
 )
 
@@ -19,10 +21,12 @@ type endpointMapping struct {
 // natMapper rewrites a report to deal with NAT'd connections.
 type natMapper struct {
 	flowWalker
+	// path of the IPVS connection table, if IPVS connections are mapped
+	ipvsConnections string
 }
 
 func makeNATMapper(fw flowWalker) natMapper {
-	return natMapper{fw}
+	return natMapper{flowWalker: fw}
 }
 
 func toMapping(f flow) *endpointMapping {
@@ -48,14 +52,14 @@ func toMapping(f flow) *endpointMapping {
 
 // applyNAT duplicates Nodes in the endpoint topology of a report, based on
 // the NAT table.
-func (n natMapper) applyNAT(rpt report.Report, scope string) {
+func (n natMapper) applyNAT(rpt report.Report, hostID, cluster string) {
 	n.flowWalker.walkFlows(func(f flow, active bool) {
 		mapping := toMapping(f)
 
 		realEndpointPort := strconv.Itoa(mapping.originalPort)
 		copyEndpointPort := strconv.Itoa(mapping.rewrittenPort)
-		realEndpointID := report.MakeEndpointNodeID(scope, "", mapping.originalIP, realEndpointPort)
-		copyEndpointID := report.MakeEndpointNodeID(scope, "", mapping.rewrittenIP, copyEndpointPort)
+		realEndpointID := report.MakeClusterEndpointNodeID(cluster, hostID, "", mapping.originalIP, realEndpointPort, "")
+		copyEndpointID := report.MakeClusterEndpointNodeID(cluster, hostID, "", mapping.rewrittenIP, copyEndpointPort, "")
 
 		node, ok := rpt.Endpoint.Nodes[realEndpointID]
 		if !ok {
@@ -67,3 +71,40 @@ func (n natMapper) applyNAT(rpt report.Report, scope string) {
 		}))
 	})
 }
+
+// applyIPVS redirects the connections of the endpoint topology of a report
+// going to an IPVS virtual service, e.g. a kubernetes service with
+// kube-proxy in IPVS mode, to the real server handling them. IPVS doesn't
+// go through conntrack, so applyNAT doesn't see them.
+func (n natMapper) applyIPVS(rpt report.Report, hostID, cluster string) {
+	if n.ipvsConnections == "" {
+		return
+	}
+	connections, err := readIPVSConnections(n.ipvsConnections)
+	if err != nil {
+		log.Debugf("Error reading IPVS connections: %v", err)
+	}
+	for _, c := range connections {
+		var (
+			clientID  = report.MakeClusterEndpointNodeID(cluster, hostID, "", c.clientIP, strconv.Itoa(c.clientPort), c.protocol)
+			virtualID = report.MakeClusterEndpointNodeID(cluster, hostID, "", c.virtualIP, strconv.Itoa(c.virtualPort), c.protocol)
+			realID    = report.MakeClusterEndpointNodeID(cluster, hostID, "", c.realIP, strconv.Itoa(c.realPort), c.protocol)
+		)
+		node, ok := rpt.Endpoint.Nodes[clientID]
+		if !ok || !node.Adjacency.Contains(virtualID) {
+			continue
+		}
+		adjacency := report.MakeIDList(realID)
+		for _, id := range node.Adjacency {
+			if id != virtualID {
+				adjacency = adjacency.Add(id)
+			}
+		}
+		node.Adjacency = adjacency
+		rpt.Endpoint.Nodes[clientID] = node.WithLatests(map[string]string{
+			IPVSVirtualDestination: net.JoinHostPort(c.virtualIP, strconv.Itoa(c.virtualPort)),
+			IPVSRealDestination:    net.JoinHostPort(c.realIP, strconv.Itoa(c.realPort)),
+		})
+		rpt.Endpoint.AddNode(report.MakeNode(realID))
+	}
+}
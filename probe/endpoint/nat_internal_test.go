@@ -3,8 +3,10 @@ package endpoint
 import (
 	"testing"
 
+	fs_hook "github.com/weaveworks/common/fs"
 	"github.com/weaveworks/common/mtime"
 	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/common/test/fs"
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/reflect"
 )
@@ -80,7 +82,7 @@ func TestNat(t *testing.T) {
 			"foo":  "bar",
 		}))
 
-		makeNATMapper(ct).applyNAT(have, "host1")
+		makeNATMapper(ct).applyNAT(have, "host1", "")
 		if !reflect.DeepEqual(want, have) {
 			t.Fatal(test.Diff(want, have))
 		}
@@ -132,9 +134,53 @@ func TestNat(t *testing.T) {
 			"foo":  "baz",
 		}))
 
-		makeNATMapper(ct).applyNAT(have, "host1")
+		makeNATMapper(ct).applyNAT(have, "host1", "")
 		if !reflect.DeepEqual(want, have) {
 			t.Fatal(test.Diff(want, have))
 		}
 	}
 }
+
+func TestIPVS(t *testing.T) {
+	mtime.NowForce(mtime.Now())
+	defer mtime.NowReset()
+
+	// 10.32.1.200:47076 -> service 10.96.0.1:443, served by 10.32.1.199:8080
+	fs_hook.Mock(fs.Dir("",
+		fs.Dir("proc",
+			fs.Dir("net",
+				fs.File{
+					FName: "ip_vs_conn",
+					FContents: `Pro FromIP   FPrt ToIP     TPrt DestIP   DPrt State       Expires PEName PEData
+TCP 0A2001C8 B7E4 0A600001 01BB 0A2001C7 1F90 ESTABLISHED     895
+UDP 0A2001C8 D431 0A60000A 0035 0A2001C6 0035 UDP             290
+`,
+				},
+			),
+		),
+	))
+	defer fs_hook.Restore()
+
+	var (
+		clientID  = report.MakeEndpointNodeID("host1", "", "10.32.1.200", "47076")
+		virtualID = report.MakeEndpointNodeID("host1", "", "10.96.0.1", "443")
+		realID    = report.MakeEndpointNodeID("host1", "", "10.32.1.199", "8080")
+		have      = report.MakeReport()
+	)
+	have.Endpoint.AddNode(report.MakeNode(clientID).WithAdjacent(virtualID))
+	have.Endpoint.AddNode(report.MakeNode(virtualID))
+
+	want := have.Copy()
+	want.Endpoint.Nodes[clientID] = report.MakeNodeWith(clientID, map[string]string{
+		IPVSVirtualDestination: "10.96.0.1:443",
+		IPVSRealDestination:    "10.32.1.199:8080",
+	}).WithAdjacent(realID)
+	want.Endpoint.AddNode(report.MakeNode(realID))
+
+	natMapper := makeNATMapper(&mockFlowWalker{})
+	natMapper.ipvsConnections = "/proc/net/ip_vs_conn"
+	natMapper.applyIPVS(have, "host1", "")
+	if !reflect.DeepEqual(want, have) {
+		t.Fatal(test.Diff(want, have))
+	}
+}
@@ -14,13 +14,27 @@ func (f *fixedConnIter) Next() *Connection {
 }
 
 // FixedScanner implements ConnectionScanner and uses constant Connection and
-// ConnectionProcs.
+// ConnectionProcs. Connections without a RemoteAddress are listening sockets.
 type FixedScanner []Connection
 
 // Connections implements ConnectionsScanner.Connections
 func (s FixedScanner) Connections() (ConnIter, error) {
-	iter := fixedConnIter(s)
-	return &iter, nil
+	return s.filter(true), nil
+}
+
+// Listeners implements ConnectionsScanner.Listeners
+func (s FixedScanner) Listeners() (ConnIter, error) {
+	return s.filter(false), nil
+}
+
+func (s FixedScanner) filter(connected bool) ConnIter {
+	var iter fixedConnIter
+	for _, c := range s {
+		if (c.RemoteAddress != nil) == connected {
+			iter = append(iter, c)
+		}
+	}
+	return &iter
 }
 
 // Stop implements ConnectionsScanner.Stop (dummy since there is no background work)
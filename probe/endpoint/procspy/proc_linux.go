@@ -22,6 +22,7 @@ import (
 var (
 	procRoot               = "/proc"
 	namespaceKey           = []string{"procspy", "namespaces"}
+	cachedSocketsKey       = []string{"procspy", "cached_sockets"}
 	netNamespacePathSuffix = ""
 	ipv6IsSupported        = tcp6FileExists()
 )
@@ -41,6 +42,110 @@ type pidWalker struct {
 	tickc       <-chan time.Time // Rate-limit clock. Sets the pace when traversing namespaces and /proc/PID/fd/* files.
 	stopc       chan struct{}    // Abort walk
 	fdBlockSize uint64           // Maximum number of /proc/PID/fd/* files to stat() per tick
+	cache       *socketCache     // Socket owners found in the previous walk
+}
+
+// socketCache remembers, per network namespace, which process owned each
+// socket of the namespace's connection tables in the previous walk. Only
+// the sockets missing from it send us through /proc/PID/fd/* again, so
+// namespaces whose connections didn't change are not walked at all.
+//
+// Sockets nobody could be found for, e.g. those of processes we have no
+// access to, are remembered too (with a nil owner). They are looked for
+// again whenever their namespace is walked, but only cause a walk of
+// their own every maxSkippedWalks walks.
+type socketCache struct {
+	namespaces map[uint64]map[uint64]*Proc // map network namespace id -> socket inode -> process
+	skipped    map[uint64]int              // map network namespace id -> walks skipped in a row
+}
+
+const maxSkippedWalks = 6
+
+func newSocketCache() *socketCache {
+	return &socketCache{
+		namespaces: map[uint64]map[uint64]*Proc{},
+		skipped:    map[uint64]int{},
+	}
+}
+
+// reuse adds the cached owners of the sockets in table to sockets, as long
+// as the owning process is still in the namespace. It returns the sockets
+// whose owner has to be looked up, and those nobody owned last time.
+func (c *socketCache) reuse(namespaceID uint64, table []byte, namespaceProcs []*process.Process, sockets map[uint64]*Proc) (missing, unowned map[uint64]struct{}) {
+	var (
+		cached = c.namespaces[namespaceID]
+		alive  = make(map[uint]struct{}, len(namespaceProcs))
+	)
+	missing, unowned = map[uint64]struct{}{}, map[uint64]struct{}{}
+	for _, p := range namespaceProcs {
+		alive[uint(p.PID)] = struct{}{}
+	}
+	forEachSocket(table, func(inode uint64) {
+		proc, ok := cached[inode]
+		switch {
+		case !ok:
+			missing[inode] = struct{}{}
+		case proc == nil:
+			unowned[inode] = struct{}{}
+		default:
+			if _, ok := alive[proc.PID]; ok {
+				sockets[inode] = proc
+			} else {
+				missing[inode] = struct{}{}
+			}
+		}
+	})
+	return missing, unowned
+}
+
+// skip tells whether the walk of a namespace without any missing sockets
+// can be skipped.
+func (c *socketCache) skip(namespaceID uint64, unowned map[uint64]struct{}) bool {
+	if len(unowned) > 0 && c.skipped[namespaceID] >= maxSkippedWalks {
+		return false
+	}
+	c.skipped[namespaceID]++
+	return true
+}
+
+func (c *socketCache) store(namespaceID uint64, table []byte, sockets map[uint64]*Proc) {
+	owners := map[uint64]*Proc{}
+	forEachSocket(table, func(inode uint64) {
+		owners[inode] = sockets[inode]
+	})
+	c.namespaces[namespaceID] = owners
+	c.skipped[namespaceID] = 0
+}
+
+// retain forgets the namespaces which are gone.
+func (c *socketCache) retain(namespaces map[uint64][]*process.Process) {
+	for namespaceID := range c.namespaces {
+		if _, ok := namespaces[namespaceID]; !ok {
+			delete(c.namespaces, namespaceID)
+			delete(c.skipped, namespaceID)
+		}
+	}
+}
+
+// forEachSocket calls f with the inode of each connection and listening
+// socket in table.
+func forEachSocket(table []byte, f func(inode uint64)) {
+	for _, pn := range []*ProcNet{NewProcNet(table), NewListeningProcNet(table)} {
+		for conn := pn.Next(); conn != nil; conn = pn.Next() {
+			// Orphaned sockets (e.g. in TIME_WAIT) have no owner
+			if conn.Inode != 0 {
+				f(conn.Inode)
+			}
+		}
+	}
+}
+
+func (c *socketCache) size() int {
+	size := 0
+	for _, owners := range c.namespaces {
+		size += len(owners)
+	}
+	return size
 }
 
 func newPidWalker(walker process.Walker, tickc <-chan time.Time, fdBlockSize uint64) pidWalker {
@@ -49,6 +154,7 @@ func newPidWalker(walker process.Walker, tickc <-chan time.Time, fdBlockSize uin
 		tickc:       tickc,
 		fdBlockSize: fdBlockSize,
 		stopc:       make(chan struct{}),
+		cache:       newSocketCache(),
 	}
 	return w
 }
@@ -147,11 +253,22 @@ func readProcessConnections(buf *bytes.Buffer, namespaceProcs []*process.Process
 
 // walkNamespace does the work of walk for a single namespace
 func (w pidWalker) walkNamespace(namespaceID uint64, buf *bytes.Buffer, sockets map[uint64]*Proc, namespaceProcs []*process.Process) error {
-
+	start := buf.Len()
 	if found, err := readProcessConnections(buf, namespaceProcs); err != nil || !found {
 		return err
 	}
 
+	missing, unowned := w.cache.reuse(namespaceID, buf.Bytes()[start:], namespaceProcs, sockets)
+	if len(missing) == 0 && w.cache.skip(namespaceID, unowned) {
+		return nil
+	}
+	for inode := range unowned {
+		missing[inode] = struct{}{}
+	}
+	defer func() {
+		w.cache.store(namespaceID, buf.Bytes()[start:], sockets)
+	}()
+
 	var statT syscall.Stat_t
 	var fdBlockCount uint64
 	for i, p := range namespaceProcs {
@@ -171,9 +288,21 @@ func (w pidWalker) walkNamespace(namespaceID uint64, buf *bytes.Buffer, sockets
 			fdBlockCount = 0
 			// read the connections again to
 			// avoid the race between between /net/tcp{,6} and /proc/PID/fd/*
+			reread := buf.Len()
 			if found, err := readProcessConnections(buf, namespaceProcs[i:]); err != nil || !found {
 				return err
 			}
+			newMissing, _ := w.cache.reuse(namespaceID, buf.Bytes()[reread:], namespaceProcs, sockets)
+			for inode := range newMissing {
+				if _, ok := sockets[inode]; !ok {
+					missing[inode] = struct{}{}
+				}
+			}
+		}
+
+		if len(missing) == 0 {
+			// All the sockets we were looking for are found
+			break
 		}
 
 		fds, err := fs.ReadDirNames(fdBase)
@@ -208,6 +337,7 @@ func (w pidWalker) walkNamespace(namespaceID uint64, buf *bytes.Buffer, sockets
 			}
 
 			sockets[statT.Ino] = proc
+			delete(missing, statT.Ino)
 		}
 
 	}
@@ -230,8 +360,9 @@ func ReadNetnsFromPID(pid int) (uint64, error) {
 
 // walk walks over all numerical (PID) /proc entries. It reads
 // /proc/PID/net/tcp{,6} for each namespace and sees if the ./fd/* files of each
-// process in that namespace are symlinks to sockets. Returns a map from socket
-// ID (inode) to PID.
+// process in that namespace are symlinks to sockets, unless the owners of all
+// the namespace's sockets are known from the previous walk. Returns a map from
+// socket ID (inode) to PID.
 func (w pidWalker) walk(buf *bytes.Buffer) (map[uint64]*Proc, error) {
 	var (
 		sockets    = map[uint64]*Proc{}              // map socket inode -> process
@@ -264,7 +395,10 @@ func (w pidWalker) walk(buf *bytes.Buffer) (map[uint64]*Proc, error) {
 		}
 	}
 
+	w.cache.retain(namespaces)
+
 	metrics.SetGauge(namespaceKey, float32(len(namespaces)))
+	metrics.SetGauge(cachedSocketsKey, float32(w.cache.size()))
 	return sockets, nil
 }
 
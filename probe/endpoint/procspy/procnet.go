@@ -14,6 +14,7 @@ type ProcNet struct {
 	c                       Connection
 	bytesLocal, bytesRemote [16]byte
 	seen                    map[uint64]struct{}
+	listening               bool
 }
 
 // NewProcNet gives a new ProcNet parser.
@@ -25,6 +26,14 @@ func NewProcNet(b []byte) *ProcNet {
 	}
 }
 
+// NewListeningProcNet gives a new ProcNet parser, returning the listening
+// sockets instead of the connections.
+func NewListeningProcNet(b []byte) *ProcNet {
+	p := NewProcNet(b)
+	p.listening = true
+	return p
+}
+
 // Next returns the next connection. All buffers are re-used, so if you want
 // to keep the IPs you have to copy them.
 func (p *ProcNet) Next() *Connection {
@@ -47,10 +56,7 @@ again:
 	local, b = nextField(b)
 	remote, b = nextField(b)
 	state, b = nextField(b)
-	switch parseHex(state) {
-	// Only process established or half-closed connections
-	case tcpEstablished, tcpFinWait1, tcpFinWait2, tcpCloseWait:
-	default:
+	if !p.wanted(parseHex(state)) {
 		p.b = nextLine(b)
 		goto again
 	}
@@ -72,6 +78,18 @@ again:
 	return &p.c
 }
 
+func (p *ProcNet) wanted(state uint) bool {
+	if p.listening {
+		return state == tcpListen
+	}
+	switch state {
+	// Only process established or half-closed connections
+	case tcpEstablished, tcpFinWait1, tcpFinWait2, tcpCloseWait:
+		return true
+	}
+	return false
+}
+
 // scanAddressNA parses 'A12CF62E:00AA' to the address/port. Handles IPv4 and
 // IPv6 addresses. The address is a big endian 32 bit ints, hex encoded. We
 // just decode the hex and flip the bytes in every group of 4.
@@ -57,6 +57,35 @@ func TestProcNet(t *testing.T) {
 
 }
 
+func TestProcNetListening(t *testing.T) {
+	testString := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0050 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 5107 1 ffff8800a6aaf040 100 0 0 10 0
+   1: A12CF62E:E4D7 57FC1EC0:01BB 01 00000000:00000000 02:000006FA 00000000  1000        0 639474 2 ffff88007e75a740 48 4 26 10 -1
+`
+	p := NewListeningProcNet([]byte(testString))
+	want := Connection{
+		LocalAddress:  net.IP([]byte{0, 0, 0, 0}),
+		LocalPort:     80,
+		RemoteAddress: net.IP([]byte{0, 0, 0, 0}),
+		Inode:         5107,
+	}
+	if have := p.Next(); have == nil || !reflect.DeepEqual(*have, want) {
+		t.Errorf("Got\n%+v\nExpected\n%+v\n", have, want)
+	}
+	if got := p.Next(); got != nil {
+		t.Errorf("p.Next() wasn't empty")
+	}
+
+	// Listening sockets aren't connections
+	p = NewProcNet([]byte(testString))
+	if have := p.Next(); have == nil || have.Inode != 639474 {
+		t.Errorf("Got %+v, expected inode 639474", have)
+	}
+	if got := p.Next(); got != nil {
+		t.Errorf("p.Next() wasn't empty")
+	}
+}
+
 func TestTransport6(t *testing.T) {
 	// Abridged copy of my /proc/net/tcp6
 	testString := `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout Inode
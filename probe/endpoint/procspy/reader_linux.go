@@ -7,6 +7,7 @@ import (
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/weaveworks/scope/probe/process"
 )
@@ -20,6 +21,21 @@ const (
 	targetWalkTime = 10 * time.Second // Aim at walking all files in 10 seconds
 )
 
+// WalkDuration is an exported prometheus metric
+var WalkDuration = prometheus.NewSummary(
+	prometheus.SummaryOpts{
+		Namespace: "scope",
+		Subsystem: "probe",
+		Name:      "proc_walk_duration_seconds",
+		Help:      "Time in seconds spent walking /proc for the owners of sockets.",
+		MaxAge:    targetWalkTime,
+	},
+)
+
+func init() {
+	prometheus.MustRegister(WalkDuration)
+}
+
 type reader interface {
 	getWalkedProcPid(buf *bytes.Buffer) (map[uint64]*Proc, error)
 	stop()
@@ -159,7 +175,11 @@ func performWalk(w pidWalker, c chan<- walkResult) {
 		result = walkResult{
 			buf: bytes.NewBuffer(make([]byte, 0, 5000)),
 		}
+		begin = time.Now()
 	)
+	defer func() {
+		WalkDuration.Observe(time.Since(begin).Seconds())
+	}()
 
 	result.sockets, err = w.walk(result.buf)
 	if err != nil {
@@ -13,9 +13,11 @@ const (
 	tcpFinWait1    = 4
 	tcpFinWait2    = 5
 	tcpCloseWait   = 8
+	tcpListen      = 10
 )
 
-// Connection is a (TCP) connection. The Proc struct might not be filled in.
+// Connection is a (TCP) connection, or a listening socket, which has no
+// remote end. The Proc struct might not be filled in.
 type Connection struct {
 	Transport     string
 	LocalAddress  net.IP
@@ -42,6 +44,8 @@ type ConnIter interface {
 type ConnectionScanner interface {
 	// Connections returns all established (TCP) connections.
 	Connections() (ConnIter, error)
+	// Listeners returns all listening (TCP) sockets.
+	Listeners() (ConnIter, error)
 	// Stops the scanning
 	Stop()
 }
@@ -75,5 +75,11 @@ func (s *darwinScanner) Connections() (ConnIter, error) {
 	return &f, nil
 }
 
+// Listeners isn't implemented on Darwin.
+func (s *darwinScanner) Listeners() (ConnIter, error) {
+	var f fixedConnIter
+	return &f, nil
+}
+
 // Nothing to stop since there's nothing running in the background
 func (s *darwinScanner) Stop() {}
@@ -60,6 +60,14 @@ type linuxScanner struct {
 }
 
 func (s *linuxScanner) Connections() (ConnIter, error) {
+	return s.scan(NewProcNet)
+}
+
+func (s *linuxScanner) Listeners() (ConnIter, error) {
+	return s.scan(NewListeningProcNet)
+}
+
+func (s *linuxScanner) scan(newProcNet func([]byte) *ProcNet) (ConnIter, error) {
 	// buffer for contents of /proc/<pid>/net/tcp
 	buf := bufPool.Get().(*bytes.Buffer)
 	buf.Reset()
@@ -80,7 +88,7 @@ func (s *linuxScanner) Connections() (ConnIter, error) {
 	}
 
 	return &pnConnIter{
-		pn:    NewProcNet(buf.Bytes()),
+		pn:    newProcNet(buf.Bytes()),
 		buf:   buf,
 		procs: procs,
 	}, nil
@@ -14,7 +14,7 @@ import (
 func TestLinuxConnections(t *testing.T) {
 	fs_hook.Mock(mockFS)
 	defer fs_hook.Restore()
-	scanner := NewConnectionScanner(process.NewWalker("/proc", false), true)
+	scanner := NewConnectionScanner(process.NewWalker("/proc", false, false, false), true)
 	defer scanner.Stop()
 
 	// let the background scanner finish its first pass
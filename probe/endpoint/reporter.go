@@ -1,6 +1,7 @@
 package endpoint
 
 import (
+	"path/filepath"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -14,21 +15,39 @@ const (
 	ReverseDNSNames = report.ReverseDNSNames
 	SnoopedDNSNames = report.SnoopedDNSNames
 	CopyOf          = report.CopyOf
+	EgressBytes     = report.EgressBytes
+	IngressBytes    = report.IngressBytes
+	TCPRetransmits  = report.TCPRetransmits
+	TCPResets       = report.TCPResets
+	RTT             = report.RTT
+	SNINames        = report.SNINames
+
+	SampledConnections     = report.SampledConnections
+	IPVSVirtualDestination = report.IPVSVirtualDestination
+	IPVSRealDestination    = report.IPVSRealDestination
 )
 
 // ReporterConfig are the config options for the endpoint reporter.
 type ReporterConfig struct {
-	HostID       string
-	HostName     string
-	SpyProcs     bool
-	UseConntrack bool
-	WalkProc     bool
-	UseEbpfConn  bool
-	ProcRoot     string
-	BufferSize   int
-	ProcessCache *process.CachingWalker
-	Scanner      procspy.ConnectionScanner
-	DNSSnooper   *DNSSnooper
+	HostID             string
+	HostName           string
+	ClusterScope       string // of addresses not scoped by host, if any
+	SpyProcs           bool
+	UseConntrack       bool
+	WalkProc           bool
+	UseEbpfConn        bool
+	SampleRTT          bool
+	SampleBytes        bool
+	TrackUDP           bool
+	MapIPVS            bool
+	ShortLivedSampling int
+	ProcRoot           string
+	BufferSize         int
+	ProcessCache       *process.CachingWalker
+	Scanner            procspy.ConnectionScanner
+	DNSSnooper         *DNSSnooper
+	SNISnooper         *SNISnooper
+	SharedDNS          SharedDNSCache
 }
 
 // Reporter generates Reports containing the Endpoint topology.
@@ -50,28 +69,43 @@ var SpyDuration = prometheus.NewSummaryVec(
 	[]string{},
 )
 
+func init() {
+	prometheus.MustRegister(SpyDuration)
+}
+
 // NewReporter creates a new Reporter that invokes procspy.Connections to
 // generate a report.Report that contains every discovered (spied) connection
 // on the host machine, at the granularity of host and port. That information
 // is stored in the Endpoint topology. It optionally enriches that topology
 // with process (PID) information.
 func NewReporter(conf ReporterConfig) *Reporter {
+	natMapper := makeNATMapper(newConntrackFlowWalker(conf.UseConntrack, conf.ProcRoot, conf.BufferSize, tcpProto, "--any-nat"))
+	if conf.MapIPVS {
+		natMapper.ipvsConnections = filepath.Join(conf.ProcRoot, ipvsConnectionsPath)
+	}
 	return &Reporter{
 		conf: conf,
 		connectionTracker: newConnectionTracker(connectionTrackerConfig{
-			HostID:       conf.HostID,
-			HostName:     conf.HostName,
-			SpyProcs:     conf.SpyProcs,
-			UseConntrack: conf.UseConntrack,
-			WalkProc:     conf.WalkProc,
-			UseEbpfConn:  conf.UseEbpfConn,
-			ProcRoot:     conf.ProcRoot,
-			BufferSize:   conf.BufferSize,
-			ProcessCache: conf.ProcessCache,
-			Scanner:      conf.Scanner,
-			DNSSnooper:   conf.DNSSnooper,
+			HostID:             conf.HostID,
+			HostName:           conf.HostName,
+			ClusterScope:       conf.ClusterScope,
+			SpyProcs:           conf.SpyProcs,
+			UseConntrack:       conf.UseConntrack,
+			WalkProc:           conf.WalkProc,
+			UseEbpfConn:        conf.UseEbpfConn,
+			SampleRTT:          conf.SampleRTT,
+			SampleBytes:        conf.SampleBytes,
+			TrackUDP:           conf.TrackUDP,
+			ShortLivedSampling: conf.ShortLivedSampling,
+			ProcRoot:           conf.ProcRoot,
+			BufferSize:         conf.BufferSize,
+			ProcessCache:       conf.ProcessCache,
+			Scanner:            conf.Scanner,
+			DNSSnooper:         conf.DNSSnooper,
+			SNISnooper:         conf.SNISnooper,
+			SharedDNS:          conf.SharedDNS,
 		}),
-		natMapper: makeNATMapper(newConntrackFlowWalker(conf.UseConntrack, conf.ProcRoot, conf.BufferSize, "--any-nat")),
+		natMapper: natMapper,
 	}
 }
 
@@ -96,6 +130,7 @@ func (r *Reporter) Report() (report.Report, error) {
 	rpt := report.MakeReport()
 
 	r.connectionTracker.ReportConnections(&rpt)
-	r.natMapper.applyNAT(rpt, r.conf.HostID)
+	r.natMapper.applyNAT(rpt, r.conf.HostID, r.conf.ClusterScope)
+	r.natMapper.applyIPVS(rpt, r.conf.HostID, r.conf.ClusterScope)
 	return rpt, nil
 }
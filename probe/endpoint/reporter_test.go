@@ -2,6 +2,7 @@ package endpoint_test
 
 import (
 	"net"
+	"reflect"
 	"strconv"
 	"testing"
 
@@ -126,3 +127,49 @@ func TestSpyWithProcesses(t *testing.T) {
 		}
 	}
 }
+
+func TestSpyListeners(t *testing.T) {
+	const nodeID = "nikon"
+
+	listener := procspy.Connection{
+		Transport:    "tcp",
+		LocalAddress: net.ParseIP("0.0.0.0"),
+		LocalPort:    fixLocalPort,
+		Proc: procspy.Proc{
+			PID:  fixProcessPID,
+			Name: fixProcessName,
+		},
+	}
+	scanner := procspy.FixedScanner(append([]procspy.Connection{listener}, fixConnectionsWithProcesses...))
+	reporter := endpoint.NewReporter(endpoint.ReporterConfig{
+		HostID:     nodeID,
+		SpyProcs:   true,
+		WalkProc:   true,
+		BufferSize: bufferSize,
+		Scanner:    scanner,
+	})
+	r, _ := reporter.Report()
+
+	processNodeID := report.MakeProcessNodeID(nodeID, strconv.FormatUint(uint64(fixProcessPID), 10))
+	node, ok := r.Process.Nodes[processNodeID]
+	if !ok {
+		t.Fatalf("no process node %q", processNodeID)
+	}
+	rows := node.ExtractMulticolumnTable(endpoint.ListeningPortsTableTemplates[endpoint.ListeningPortsTablePrefix])
+	want := []report.Row{{
+		ID: "0.0.0.0:80/tcp",
+		Entries: map[string]string{
+			endpoint.ListeningPortPort:     "80",
+			endpoint.ListeningPortProtocol: "tcp",
+			endpoint.ListeningPortAddress:  "0.0.0.0",
+		},
+	}}
+	if !reflect.DeepEqual(want, rows) {
+		t.Errorf("want %v, have %v", want, rows)
+	}
+
+	// The listener isn't a connection
+	if want, have := 2, len(r.Endpoint.Nodes); want != have {
+		t.Errorf("want %d endpoints, have %d", want, have)
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"strings"
 	"time"
 
+	log "github.com/Sirupsen/logrus"
 	"github.com/bluele/gcache"
+
+	"github.com/weaveworks/scope/report"
 )
 
 const (
@@ -19,20 +22,30 @@ var errNotFound = fmt.Errorf("not found")
 
 type revResFunc func(addr string) (names []string, err error)
 
+// SharedDNSCache is a cache of DNS resolutions shared with other
+// probes, through the app.
+type SharedDNSCache interface {
+	LookupDNS(addresses []string) (report.DNSRecords, error)
+	PublishDNS(records report.DNSRecords) error
+}
+
 // A caching, reverse resolver.
 type reverseResolver struct {
 	addresses chan string
 	cache     gcache.Cache
+	shared    SharedDNSCache
 	Throttle  <-chan time.Time // Made public for mocking
 	Resolver  revResFunc
 }
 
 // newReverseResolver starts a new reverse resolver that performs reverse
-// resolutions and caches the result.
-func newReverseResolver() *reverseResolver {
+// resolutions and caches the result. If shared isn't nil, it is asked
+// first, and told about the resolutions made.
+func newReverseResolver(shared SharedDNSCache) *reverseResolver {
 	r := reverseResolver{
 		addresses: make(chan string, rAddrBacklog),
 		cache:     gcache.New(rAddrCacheLen).LRU().Expiration(rAddrCacheExpiration).Build(),
+		shared:    shared,
 		Throttle:  time.Tick(time.Second / 10),
 		Resolver:  net.LookupAddr,
 	}
@@ -67,6 +80,11 @@ func (r *reverseResolver) loop() {
 		if _, err := r.cache.Get(request); err == nil {
 			continue
 		}
+		// maybe another probe resolved it already
+		if names, ok := r.lookupShared(request); ok {
+			r.cache.Set(request, names)
+			continue
+		}
 		<-r.Throttle // rate limit our DNS resolutions
 		names, err := r.Resolver(request)
 		if err == nil && len(names) > 0 {
@@ -74,12 +92,36 @@ func (r *reverseResolver) loop() {
 				names[idx] = strings.TrimRight(name, ".")
 			}
 			r.cache.Set(request, names)
+			r.publishShared(request, names)
 		} else {
 			r.cache.Set(request, struct{}{})
 		}
 	}
 }
 
+func (r *reverseResolver) lookupShared(address string) ([]string, bool) {
+	if r.shared == nil {
+		return nil, false
+	}
+	records, err := r.shared.LookupDNS([]string{address})
+	if err != nil {
+		log.Debugf("Error looking up %s in the shared DNS cache: %v", address, err)
+		return nil, false
+	}
+	names := records[address].Reverse
+	return []string(names), len(names) > 0
+}
+
+func (r *reverseResolver) publishShared(address string, names []string) {
+	if r.shared == nil {
+		return
+	}
+	records := report.DNSRecords{address: {Reverse: report.MakeStringSet(names...)}}
+	if err := r.shared.PublishDNS(records); err != nil {
+		log.Debugf("Error publishing %s to the shared DNS cache: %v", address, err)
+	}
+}
+
 func (r *reverseResolver) stop() {
 	close(r.addresses)
 }
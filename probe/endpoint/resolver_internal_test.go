@@ -2,9 +2,11 @@ package endpoint
 
 import (
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test"
 )
 
@@ -14,7 +16,7 @@ func TestReverseResolver(t *testing.T) {
 		"4.3.2.1": {"im.a.little.tea.pot"},
 	}
 
-	revRes := newReverseResolver()
+	revRes := newReverseResolver(nil)
 	defer revRes.stop()
 
 	// Use a mocked resolver function.
@@ -35,3 +37,71 @@ func TestReverseResolver(t *testing.T) {
 		})
 	}
 }
+
+type mockSharedDNSCache struct {
+	sync.Mutex
+	records report.DNSRecords
+}
+
+func (m *mockSharedDNSCache) LookupDNS(addresses []string) (report.DNSRecords, error) {
+	m.Lock()
+	defer m.Unlock()
+	result := report.DNSRecords{}
+	for _, address := range addresses {
+		if record, ok := m.records[address]; ok {
+			result[address] = record
+		}
+	}
+	return result, nil
+}
+
+func (m *mockSharedDNSCache) PublishDNS(records report.DNSRecords) error {
+	m.Lock()
+	defer m.Unlock()
+	m.records = m.records.Merge(records)
+	return nil
+}
+
+func (m *mockSharedDNSCache) reverse(address string) report.StringSet {
+	m.Lock()
+	defer m.Unlock()
+	return m.records[address].Reverse
+}
+
+func TestReverseResolverShared(t *testing.T) {
+	shared := &mockSharedDNSCache{records: report.DNSRecords{
+		"1.2.3.4": {Reverse: report.MakeStringSet("shared.domain.name")},
+	}}
+	revRes := newReverseResolver(shared)
+	defer revRes.stop()
+
+	resolved := map[string]int{}
+	var mtx sync.Mutex
+	revRes.Resolver = func(addr string) (names []string, err error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		resolved[addr]++
+		return []string{"resolved.domain.name."}, nil
+	}
+	revRes.Throttle = time.Tick(time.Millisecond)
+
+	// Known to the shared cache: no need to resolve it
+	test.Poll(t, 100*time.Millisecond, []string{"shared.domain.name"}, func() interface{} {
+		ns, _ := revRes.get("1.2.3.4")
+		return ns
+	})
+	// Unknown: resolved, and then shared
+	test.Poll(t, 100*time.Millisecond, []string{"resolved.domain.name"}, func() interface{} {
+		ns, _ := revRes.get("4.3.2.1")
+		return ns
+	})
+	test.Poll(t, 100*time.Millisecond, report.MakeStringSet("resolved.domain.name"), func() interface{} {
+		return shared.reverse("4.3.2.1")
+	})
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if resolved["1.2.3.4"] != 0 || resolved["4.3.2.1"] != 1 {
+		t.Errorf("unexpected resolutions: %v", resolved)
+	}
+}
@@ -0,0 +1,152 @@
+package endpoint
+
+import (
+	"math"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/bluele/gcache"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+const (
+	maxSNIRecords = 10000
+	// Only capture outbound TCP segments starting with a TLS handshake record
+	sniBPFFilter = "outbound and tcp and tcp[((tcp[12:1] & 0xf0) >> 2):1] = 0x16"
+)
+
+// SNISnooper is a snooper of the server names sent in TLS ClientHellos
+type SNISnooper struct {
+	stop       chan struct{}
+	pcapHandle *pcap.Handle
+	serverName gcache.Cache // fourTuple.key() -> sniRecord
+}
+
+// NewSNISnooper creates a new snooper of TLS server names
+func NewSNISnooper() (*SNISnooper, error) {
+	pcapHandle, err := newSNIPcapHandle()
+	if err != nil {
+		return nil, err
+	}
+	s := &SNISnooper{
+		stop:       make(chan struct{}),
+		pcapHandle: pcapHandle,
+		serverName: gcache.New(maxSNIRecords).LRU().Build(),
+	}
+	go s.run()
+	return s, nil
+}
+
+func newSNIPcapHandle() (*pcap.Handle, error) {
+	inactive, err := pcap.NewInactiveHandle("any")
+	if err != nil {
+		return nil, err
+	}
+	defer inactive.CleanUp()
+	// See newPcapHandle() for the timeout blackmagic
+	if err = inactive.SetTimeout(time.Duration(math.MaxInt64)); err != nil {
+		return nil, err
+	}
+	if err = inactive.SetImmediateMode(true); err != nil {
+		return nil, err
+	}
+	if err = inactive.SetBufferSize(bufSize); err != nil {
+		return nil, err
+	}
+	pcapHandle, err := inactive.Activate()
+	if err != nil {
+		return nil, err
+	}
+	if err := pcapHandle.SetBPFFilter(sniBPFFilter); err != nil {
+		pcapHandle.Close()
+		return nil, err
+	}
+	return pcapHandle, nil
+}
+
+// serverNameFor returns the server name sent by the client of a
+// connection, if it was seen.
+func (s *SNISnooper) serverNameFor(ft fourTuple) (sniRecord, bool) {
+	if s == nil {
+		return sniRecord{}, false
+	}
+	v, err := s.serverName.Get(ft.key())
+	if err != nil {
+		return sniRecord{}, false
+	}
+	return v.(sniRecord), true
+}
+
+// Stop makes the snooper stop inspecting TLS handshakes
+func (s *SNISnooper) Stop() {
+	if s != nil {
+		close(s.stop)
+	}
+}
+
+func (s *SNISnooper) run() {
+	var (
+		decodedLayers []gopacket.LayerType
+		tcp           layers.TCP
+		ip4           layers.IPv4
+		ip6           layers.IPv6
+		eth           layers.Ethernet
+		dot1q         layers.Dot1Q
+		sll           layers.LinuxSLL
+	)
+
+	// assumes that the "any" interface is being used (see https://wiki.wireshark.org/SLL)
+	packetParser := gopacket.NewDecodingLayerParser(layers.LayerTypeLinuxSLL, &sll, &dot1q, &eth, &ip4, &ip6, &tcp)
+
+	for {
+		select {
+		case <-s.stop:
+			s.pcapHandle.Close()
+			return
+		default:
+		}
+
+		packet, _, err := s.pcapHandle.ZeroCopyReadPacketData()
+		if err != nil {
+			if err != pcap.NextErrorTimeoutExpired {
+				log.Errorf("SNISnooper: error reading packet data: %s", err)
+			}
+			continue
+		}
+
+		// The TLS payload can't be decoded by the parser, so we expect
+		// it to stop there.
+		if err := packetParser.DecodeLayers(packet, &decodedLayers); err != nil {
+			if layer, ok := err.(gopacket.UnsupportedLayerType); !ok || gopacket.LayerType(layer) != gopacket.LayerTypePayload {
+				continue
+			}
+		}
+
+		var (
+			srcAddr, dstAddr string
+			sawTCP           bool
+		)
+		for _, layerType := range decodedLayers {
+			switch layerType {
+			case layers.LayerTypeIPv4:
+				srcAddr, dstAddr = ip4.SrcIP.String(), ip4.DstIP.String()
+			case layers.LayerTypeIPv6:
+				srcAddr, dstAddr = ip6.SrcIP.String(), ip6.DstIP.String()
+			case layers.LayerTypeTCP:
+				sawTCP = true
+			}
+		}
+		if !sawTCP || srcAddr == "" {
+			continue
+		}
+		name, err := parseClientHelloSNI(tcp.LayerPayload())
+		if err != nil {
+			continue
+		}
+		ft := fourTuple{srcAddr, dstAddr, uint16(tcp.SrcPort), uint16(tcp.DstPort)}
+		log.Debugf("SNISnooper: caught TLS server name: %s -> %s", ft, name)
+		s.serverName.Set(ft.key(), sniRecord{serverAddr: dstAddr, serverPort: uint16(tcp.DstPort), name: name})
+	}
+}
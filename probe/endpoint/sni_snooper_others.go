@@ -0,0 +1,24 @@
+// +build darwin arm
+
+// Cross-compiling the snooper requires having pcap binaries,
+// let's disable it for now.
+
+package endpoint
+
+import "fmt"
+
+// SNISnooper is a snooper of the server names sent in TLS ClientHellos
+type SNISnooper struct{}
+
+// NewSNISnooper creates a new snooper of TLS server names
+func NewSNISnooper() (*SNISnooper, error) {
+	return nil, fmt.Errorf("TLS server name snooping is not supported on this platform")
+}
+
+func (s *SNISnooper) serverNameFor(ft fourTuple) (sniRecord, bool) {
+	return sniRecord{}, false
+}
+
+// Stop makes the snooper stop inspecting TLS handshakes
+func (s *SNISnooper) Stop() {
+}
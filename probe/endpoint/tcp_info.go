@@ -0,0 +1,28 @@
+package endpoint
+
+import "time"
+
+// tcpInfo is what the kernel knows about a TCP socket.
+type tcpInfo struct {
+	tuple         fourTuple // from the local end of the socket
+	rtt           time.Duration
+	bytesSent     uint64 // acknowledged by the peer
+	bytesReceived uint64
+	hasBytes      bool // not counted by kernels older than 4.1
+	retransmits   uint32
+}
+
+// bytesFrom returns the bytes sent and received by the given end of the
+// connection.
+func (i tcpInfo) bytesFrom(addr string, port uint16) (sent, received uint64) {
+	if i.tuple.fromAddr == addr && i.tuple.fromPort == port {
+		return i.bytesSent, i.bytesReceived
+	}
+	return i.bytesReceived, i.bytesSent
+}
+
+// tcpInfoSampler samples the tcp_info of the TCP connections on this
+// host, keyed by fourTuple.key().
+type tcpInfoSampler interface {
+	sampleTCPInfo() (map[string]tcpInfo, error)
+}
@@ -0,0 +1,118 @@
+package endpoint
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// inetDiagResponse makes an inet_diag response carrying a tcp_info of
+// infoLen bytes.
+func inetDiagResponse(local, remote *net.TCPAddr, infoLen int, rtt uint32, sent, received uint64) []byte {
+	msg := make([]byte, sizeofInetDiagMsg)
+	msg[0] = syscall.AF_INET
+	msg[1] = tcpEstablished
+	binary.BigEndian.PutUint16(msg[4:6], uint16(local.Port))
+	binary.BigEndian.PutUint16(msg[6:8], uint16(remote.Port))
+	copy(msg[8:12], local.IP.To4())
+	copy(msg[24:28], remote.IP.To4())
+
+	info := make([]byte, infoLen)
+	nativeEndian.PutUint32(info[tcpInfoRTTOffset:], rtt)
+	if infoLen >= tcpInfoTotalRetransOffset+4 {
+		nativeEndian.PutUint32(info[tcpInfoTotalRetransOffset:], uint32(rtt/100))
+	}
+	if infoLen >= tcpInfoBytesReceivedOffset+8 {
+		nativeEndian.PutUint64(info[tcpInfoBytesAckedOffset:], sent)
+		nativeEndian.PutUint64(info[tcpInfoBytesReceivedOffset:], received)
+	}
+	attr := make([]byte, sizeofRtAttr)
+	nativeEndian.PutUint16(attr[0:2], uint16(sizeofRtAttr+len(info)))
+	nativeEndian.PutUint16(attr[2:4], inetDiagInfo)
+
+	data := append(append(msg, attr...), info...)
+	hdr := make([]byte, syscall.SizeofNlMsghdr)
+	nativeEndian.PutUint32(hdr[0:4], uint32(len(hdr)+len(data)))
+	nativeEndian.PutUint16(hdr[4:6], sockDiagByFamily)
+	return append(hdr, data...)
+}
+
+func TestParseInetDiagTCPInfo(t *testing.T) {
+	var (
+		local  = &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 80}
+		remote = &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 12345}
+		old    = &net.TCPAddr{IP: net.ParseIP("10.0.0.3"), Port: 12345}
+		done   = make([]byte, syscall.SizeofNlMsghdr+4)
+		infos  = map[string]tcpInfo{}
+	)
+	nativeEndian.PutUint32(done[0:4], uint32(len(done)))
+	nativeEndian.PutUint16(done[4:6], syscall.NLMSG_DONE)
+
+	batch := append(
+		inetDiagResponse(local, remote, 232, 1500, 1000, 2000),
+		// as sent by kernels older than 4.1
+		inetDiagResponse(local, old, tcpInfoRTTOffset+8, 2500, 0, 0)...,
+	)
+	finished, err := parseInetDiagTCPInfo(batch, infos)
+	if err != nil || finished {
+		t.Fatalf("unexpected result: %v, %v", finished, err)
+	}
+	if finished, err = parseInetDiagTCPInfo(done, infos); err != nil || !finished {
+		t.Fatalf("expected the dump to be done: %v, %v", finished, err)
+	}
+
+	for tuple, want := range map[fourTuple]tcpInfo{
+		{"10.0.0.2", "10.0.0.1", 12345, 80}: {
+			tuple:         fourTuple{"10.0.0.1", "10.0.0.2", 80, 12345},
+			rtt:           1500 * time.Microsecond,
+			bytesSent:     1000,
+			bytesReceived: 2000,
+			hasBytes:      true,
+			retransmits:   15,
+		},
+		{"10.0.0.3", "10.0.0.1", 12345, 80}: {
+			tuple: fourTuple{"10.0.0.1", "10.0.0.3", 80, 12345},
+			rtt:   2500 * time.Microsecond,
+		},
+	} {
+		if have := infos[tuple.key()]; !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: want %+v, have %+v", tuple, want, have)
+		}
+	}
+
+	// Byte counts are seen from the end asking for them
+	sent, received := infos[fourTuple{"10.0.0.2", "10.0.0.1", 12345, 80}.key()].bytesFrom("10.0.0.2", 12345)
+	if sent != 2000 || received != 1000 {
+		t.Errorf("unexpected byte counts from the client: %d sent, %d received", sent, received)
+	}
+}
+
+func TestBytesSincePreviousSample(t *testing.T) {
+	var (
+		tracker = connectionTracker{}
+		tuple   = fourTuple{"10.0.0.1", "10.0.0.2", 80, 12345}
+		sample  = func(sent, received uint64) map[string]tcpInfo {
+			return map[string]tcpInfo{tuple.key(): {tuple: tuple, bytesSent: sent, bytesReceived: received, hasBytes: true, retransmits: uint32(sent / 10)}}
+		}
+		counts = func(infos map[string]tcpInfo) [3]uint64 {
+			info := infos[tuple.key()]
+			return [3]uint64{info.bytesSent, info.bytesReceived, uint64(info.retransmits)}
+		}
+	)
+	for _, c := range []struct {
+		sent, received uint64
+		want           [3]uint64
+	}{
+		{100, 200, [3]uint64{100, 200, 10}},
+		{150, 200, [3]uint64{50, 0, 5}},
+		// the tuple got reused by a new connection
+		{10, 20, [3]uint64{10, 20, 1}},
+	} {
+		if have := counts(tracker.countsSincePreviousSample(sample(c.sent, c.received))); c.want != have {
+			t.Errorf("want %v, have %v", c.want, have)
+		}
+	}
+}
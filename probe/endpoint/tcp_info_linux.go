@@ -0,0 +1,191 @@
+package endpoint
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// sock_diag(7) definitions missing from the syscall package
+const (
+	sockDiagByFamily    = 20
+	inetDiagInfo        = 2
+	tcpEstablished      = 1
+	sizeofInetDiagReqV2 = 56
+	sizeofInetDiagMsg   = 72
+	sizeofRtAttr        = 4
+	// offsets of the fields we read in struct tcp_info
+	tcpInfoRTTOffset           = 68 // microseconds
+	tcpInfoTotalRetransOffset  = 100
+	tcpInfoBytesAckedOffset    = 120
+	tcpInfoBytesReceivedOffset = 128
+)
+
+var nativeEndian binary.ByteOrder
+
+func init() {
+	var x uint16 = 1
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		nativeEndian = binary.LittleEndian
+	} else {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// sockDiagTCPInfoSampler asks the kernel for the tcp_info of all
+// established TCP sockets, through a NETLINK_INET_DIAG socket. It
+// only sees the sockets of the probe's network namespace.
+type sockDiagTCPInfoSampler struct{}
+
+func newTCPInfoSampler() tcpInfoSampler {
+	return sockDiagTCPInfoSampler{}
+}
+
+func (sockDiagTCPInfoSampler) sampleTCPInfo() (map[string]tcpInfo, error) {
+	infos := map[string]tcpInfo{}
+	for _, family := range []uint8{syscall.AF_INET, syscall.AF_INET6} {
+		if err := dumpTCPInfo(family, infos); err != nil {
+			return infos, err
+		}
+	}
+	return infos, nil
+}
+
+func dumpTCPInfo(family uint8, infos map[string]tcpInfo) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.NETLINK_INET_DIAG)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	if err := syscall.Sendto(fd, inetDiagRequest(family), 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return err
+	}
+	buf := make([]byte, 32*1024)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return err
+		}
+		done, err := parseInetDiagTCPInfo(buf[:n], infos)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// inetDiagRequest builds a dump request for the established TCP sockets
+// of the given family, asking for their tcp_info.
+func inetDiagRequest(family uint8) []byte {
+	b := make([]byte, syscall.SizeofNlMsghdr+sizeofInetDiagReqV2)
+	nativeEndian.PutUint32(b[0:4], uint32(len(b)))
+	nativeEndian.PutUint16(b[4:6], sockDiagByFamily)
+	nativeEndian.PutUint16(b[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	req := b[syscall.SizeofNlMsghdr:]
+	req[0] = family
+	req[1] = syscall.IPPROTO_TCP
+	req[2] = 1 << (inetDiagInfo - 1)
+	nativeEndian.PutUint32(req[4:8], 1<<tcpEstablished)
+	return b
+}
+
+// parseInetDiagTCPInfo adds the tcp_info found in a batch of inet_diag
+// responses to infos, and reports whether the dump is done.
+func parseInetDiagTCPInfo(buf []byte, infos map[string]tcpInfo) (bool, error) {
+	msgs, err := syscall.ParseNetlinkMessage(buf)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range msgs {
+		switch m.Header.Type {
+		case syscall.NLMSG_DONE:
+			return true, nil
+		case syscall.NLMSG_ERROR:
+			if len(m.Data) >= 4 {
+				if errno := int32(nativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+					return true, syscall.Errno(-errno)
+				}
+			}
+			return true, nil
+		case sockDiagByFamily:
+		default:
+			continue
+		}
+		if len(m.Data) < sizeofInetDiagMsg {
+			return false, fmt.Errorf("short inet_diag message: %d bytes", len(m.Data))
+		}
+		tuple, ok := inetDiagTuple(m.Data[:sizeofInetDiagMsg])
+		if !ok {
+			continue
+		}
+		if info, ok := parseTCPInfo(m.Data[sizeofInetDiagMsg:]); ok {
+			info.tuple = tuple
+			infos[tuple.key()] = info
+		}
+	}
+	return false, nil
+}
+
+// inetDiagTuple extracts the local and remote addresses of a socket
+// from an inet_diag_msg.
+func inetDiagTuple(msg []byte) (fourTuple, bool) {
+	var (
+		family = msg[0]
+		id     = msg[4:52] // struct inet_diag_sockid
+		src    = id[4:20]
+		dst    = id[20:36]
+	)
+	switch family {
+	case syscall.AF_INET:
+		src, dst = src[:net.IPv4len], dst[:net.IPv4len]
+	case syscall.AF_INET6:
+	default:
+		return fourTuple{}, false
+	}
+	return fourTuple{
+		fromAddr: net.IP(src).String(),
+		toAddr:   net.IP(dst).String(),
+		fromPort: binary.BigEndian.Uint16(id[0:2]),
+		toPort:   binary.BigEndian.Uint16(id[2:4]),
+	}, true
+}
+
+// parseTCPInfo finds the INET_DIAG_INFO attribute and reads the fields
+// of tcp_info we need from it. Older kernels send a shorter tcp_info.
+func parseTCPInfo(attrs []byte) (tcpInfo, bool) {
+	for len(attrs) >= sizeofRtAttr {
+		l := int(nativeEndian.Uint16(attrs[0:2]))
+		if l < sizeofRtAttr || l > len(attrs) {
+			return tcpInfo{}, false
+		}
+		if nativeEndian.Uint16(attrs[2:4]) == inetDiagInfo {
+			var (
+				raw  = attrs[sizeofRtAttr:l]
+				info tcpInfo
+			)
+			if len(raw) < tcpInfoRTTOffset+4 {
+				return tcpInfo{}, false
+			}
+			info.rtt = time.Duration(nativeEndian.Uint32(raw[tcpInfoRTTOffset:])) * time.Microsecond
+			if len(raw) >= tcpInfoTotalRetransOffset+4 {
+				info.retransmits = nativeEndian.Uint32(raw[tcpInfoTotalRetransOffset:])
+			}
+			if len(raw) >= tcpInfoBytesReceivedOffset+8 {
+				info.bytesSent = nativeEndian.Uint64(raw[tcpInfoBytesAckedOffset:])
+				info.bytesReceived = nativeEndian.Uint64(raw[tcpInfoBytesReceivedOffset:])
+				info.hasBytes = true
+			}
+			return info, true
+		}
+		// attributes are 4-byte aligned
+		l = (l + 3) &^ 3
+		if l > len(attrs) {
+			break
+		}
+		attrs = attrs[l:]
+	}
+	return tcpInfo{}, false
+}
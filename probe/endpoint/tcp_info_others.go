@@ -0,0 +1,17 @@
+// +build !linux
+
+package endpoint
+
+import (
+	"fmt"
+)
+
+type unsupportedTCPInfoSampler struct{}
+
+func newTCPInfoSampler() tcpInfoSampler {
+	return unsupportedTCPInfoSampler{}
+}
+
+func (unsupportedTCPInfoSampler) sampleTCPInfo() (map[string]tcpInfo, error) {
+	return nil, fmt.Errorf("sampling TCP connections is only supported on Linux")
+}
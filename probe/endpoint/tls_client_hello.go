@@ -0,0 +1,118 @@
+package endpoint
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	tlsRecordTypeHandshake    = 0x16
+	tlsHandshakeTypeHello     = 0x01
+	tlsExtensionServerName    = 0x0000
+	tlsServerNameTypeHostName = 0x00
+	tlsRecordHeaderLen        = 5
+	tlsHandshakeHeaderLen     = 4
+)
+
+// isTLSHandshake tells whether a TCP payload looks like the start of a
+// TLS handshake record.
+func isTLSHandshake(payload []byte) bool {
+	return len(payload) > tlsRecordHeaderLen && payload[0] == tlsRecordTypeHandshake && payload[1] == 0x03
+}
+
+// parseClientHelloSNI extracts the server name indication from a TLS
+// ClientHello, which is expected to start the payload. ClientHellos
+// spanning more than one TCP segment aren't supported.
+func parseClientHelloSNI(payload []byte) (string, error) {
+	if !isTLSHandshake(payload) {
+		return "", fmt.Errorf("not a TLS handshake")
+	}
+	b := payload[tlsRecordHeaderLen:]
+	if len(b) < tlsHandshakeHeaderLen || b[0] != tlsHandshakeTypeHello {
+		return "", fmt.Errorf("not a ClientHello")
+	}
+	b = b[tlsHandshakeHeaderLen:]
+
+	// client_version (2) and random (32)
+	if b = skip(b, 34); b == nil {
+		return "", fmt.Errorf("truncated ClientHello")
+	}
+	// session_id, cipher_suites and compression_methods
+	for _, lengthBytes := range []int{1, 2, 1} {
+		var length int
+		if length, b = readLength(b, lengthBytes); b == nil {
+			return "", fmt.Errorf("truncated ClientHello")
+		}
+		if b = skip(b, length); b == nil {
+			return "", fmt.Errorf("truncated ClientHello")
+		}
+	}
+
+	var extensionsLength int
+	if extensionsLength, b = readLength(b, 2); b == nil || extensionsLength > len(b) {
+		return "", fmt.Errorf("no extensions in ClientHello")
+	}
+	b = b[:extensionsLength]
+	for len(b) >= 4 {
+		var (
+			extensionType = binary.BigEndian.Uint16(b[0:2])
+			length        = int(binary.BigEndian.Uint16(b[2:4]))
+		)
+		b = b[4:]
+		if length > len(b) {
+			break
+		}
+		if extensionType == tlsExtensionServerName {
+			return parseServerNameExtension(b[:length])
+		}
+		b = b[length:]
+	}
+	return "", fmt.Errorf("no server name in ClientHello")
+}
+
+func parseServerNameExtension(b []byte) (string, error) {
+	listLength, b := readLength(b, 2)
+	if b == nil || listLength > len(b) {
+		return "", fmt.Errorf("malformed server name extension")
+	}
+	b = b[:listLength]
+	for len(b) >= 3 {
+		nameType := b[0]
+		length, rest := readLength(b[1:], 2)
+		if rest == nil || length > len(rest) {
+			break
+		}
+		if nameType == tlsServerNameTypeHostName {
+			return string(rest[:length]), nil
+		}
+		b = rest[length:]
+	}
+	return "", fmt.Errorf("no host name in server name extension")
+}
+
+// readLength reads a big-endian length of n bytes, returning the rest,
+// or nil if b is too short.
+func readLength(b []byte, n int) (int, []byte) {
+	if len(b) < n {
+		return 0, nil
+	}
+	length := 0
+	for _, c := range b[:n] {
+		length = length<<8 | int(c)
+	}
+	return length, b[n:]
+}
+
+func skip(b []byte, n int) []byte {
+	if len(b) < n {
+		return nil
+	}
+	return b[n:]
+}
+
+// sniRecord is the server name a client asked for on a connection.
+type sniRecord struct {
+	serverAddr string
+	serverPort uint16
+	name       string
+}
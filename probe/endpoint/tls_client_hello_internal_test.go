@@ -0,0 +1,46 @@
+package endpoint
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+)
+
+// clientHello captures the ClientHello written by a TLS client.
+func clientHello(t *testing.T, serverName string) []byte {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		tls.Client(client, &tls.Config{ServerName: serverName, InsecureSkipVerify: true}).Handshake()
+		client.Close()
+	}()
+	buf := make([]byte, 64*1024)
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
+func TestParseClientHelloSNI(t *testing.T) {
+	hello := clientHello(t, "www.example.com")
+	if have, err := parseClientHelloSNI(hello); err != nil || have != "www.example.com" {
+		t.Errorf("want www.example.com, have %q (%v)", have, err)
+	}
+
+	// Clients connecting to an IP address don't send a server name
+	if _, err := parseClientHelloSNI(clientHello(t, "10.0.0.1")); err == nil {
+		t.Errorf("expected an error for a ClientHello without server name")
+	}
+
+	for _, payload := range [][]byte{
+		nil,
+		[]byte("GET / HTTP/1.1\r\n\r\n"),
+		hello[:tlsRecordHeaderLen+tlsHandshakeHeaderLen+10],
+		hello[:len(hello)/2],
+	} {
+		if _, err := parseClientHelloSNI(payload); err == nil {
+			t.Errorf("expected an error for %q", payload)
+		}
+	}
+}
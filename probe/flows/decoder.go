@@ -0,0 +1,453 @@
+package flows
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Flow is traffic between two endpoints, as a router or switch exported it.
+type Flow struct {
+	Exporter string // address of the router or switch
+	Protocol string // report.TCP or report.UDP
+	SrcAddr  net.IP
+	SrcPort  uint16
+	DstAddr  net.IP
+	DstPort  uint16
+	// Estimated from the sampling rate, for sampled flows
+	Bytes   uint64
+	Packets uint64
+}
+
+// IANA numbers of the protocols we make edges of.
+var protocols = map[uint8]string{
+	6:  report.TCP,
+	17: report.UDP,
+}
+
+var errShort = errors.New("packet too short")
+
+// reader reads big-endian fields of packets, remembering if it ran out.
+type reader struct {
+	buf   []byte
+	short bool
+}
+
+// zeros are what readers which ran out read for integers. Other fields
+// read as nil, rather than as buffers of lengths packets give.
+var zeros [8]byte
+
+func (r *reader) bytes(n int) []byte {
+	if n < 0 || len(r.buf) < n {
+		r.short = true
+		r.buf = nil
+		if n >= 0 && n <= len(zeros) {
+			return zeros[:n]
+		}
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) u8() uint8   { return r.bytes(1)[0] }
+func (r *reader) u16() uint16 { return binary.BigEndian.Uint16(r.bytes(2)) }
+func (r *reader) u32() uint32 { return binary.BigEndian.Uint32(r.bytes(4)) }
+
+// ip copies an address out of a packet, as the buffers of packets are
+// reused.
+func ip(b []byte) net.IP {
+	return append(net.IP(nil), b...)
+}
+
+// readUint reads big-endian unsigned integers of any length, as NetFlow v9
+// and IPFIX may send them in fewer bytes than their type has.
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// Decoder decodes the packets of NetFlow v5, NetFlow v9, IPFIX and sFlow
+// v5 exporters, keeping the templates NetFlow v9 and IPFIX exporters send
+// to decode their records with.
+type Decoder struct {
+	mtx       sync.Mutex
+	templates map[templateKey][]templateField
+}
+
+type templateKey struct {
+	exporter string
+	domain   uint32 // source ID (v9) or observation domain ID (IPFIX)
+	id       uint16
+}
+
+type templateField struct {
+	id     uint16
+	length uint16 // variableLength for variable-length IPFIX fields
+}
+
+const variableLength = 65535
+
+// NewDecoder makes a new Decoder.
+func NewDecoder() *Decoder {
+	return &Decoder{templates: map[templateKey][]templateField{}}
+}
+
+// Decode decodes a packet from an exporter, returning the flows of TCP and
+// UDP traffic it has. Records of flows of data templates which the
+// exporter hasn't sent yet are skipped.
+func (d *Decoder) Decode(exporter string, packet []byte) ([]Flow, error) {
+	if len(packet) < 4 {
+		return nil, errShort
+	}
+	// sFlow versions are 32 bits, NetFlow ones 16
+	if binary.BigEndian.Uint32(packet) == 5 {
+		return decodeSFlow(exporter, packet)
+	}
+	switch version := binary.BigEndian.Uint16(packet); version {
+	case 5:
+		return decodeNetFlowV5(exporter, packet)
+	case 9, 10:
+		return d.decodeTemplated(exporter, version, packet)
+	default:
+		return nil, fmt.Errorf("unknown version %d", version)
+	}
+}
+
+func decodeNetFlowV5(exporter string, packet []byte) ([]Flow, error) {
+	const headerLen, recordLen = 24, 48
+	r := reader{buf: packet}
+	r.u16() // version
+	count := int(r.u16())
+	r.bytes(18) // uptime, time, sequence, engine
+	// The top 2 bits are the sampling mode
+	samplingInterval := uint64(r.u16() & 0x3fff)
+	if samplingInterval == 0 {
+		samplingInterval = 1
+	}
+	if len(packet) < headerLen+count*recordLen {
+		return nil, errShort
+	}
+	result := make([]Flow, 0, count)
+	for i := 0; i < count; i++ {
+		record := packet[headerLen+i*recordLen : headerLen+(i+1)*recordLen]
+		protocol, ok := protocols[record[38]]
+		if !ok {
+			continue
+		}
+		result = append(result, Flow{
+			Exporter: exporter,
+			Protocol: protocol,
+			SrcAddr:  ip(record[0:4]),
+			DstAddr:  ip(record[4:8]),
+			Packets:  readUint(record[16:20]) * samplingInterval,
+			Bytes:    readUint(record[20:24]) * samplingInterval,
+			SrcPort:  binary.BigEndian.Uint16(record[32:34]),
+			DstPort:  binary.BigEndian.Uint16(record[34:36]),
+		})
+	}
+	return result, nil
+}
+
+// Information elements of NetFlow v9 and IPFIX, which share their numbers.
+const (
+	fieldBytes            = 1
+	fieldPackets          = 2
+	fieldProtocol         = 4
+	fieldSrcPort          = 7
+	fieldSrcIPv4          = 8
+	fieldDstPort          = 11
+	fieldDstIPv4          = 12
+	fieldSrcIPv6          = 27
+	fieldDstIPv6          = 28
+	fieldSamplingInterval = 34
+)
+
+// decodeTemplated decodes NetFlow v9 and IPFIX (v10) packets, which are
+// made of sets of templates, and of records of data in the templates.
+func (d *Decoder) decodeTemplated(exporter string, version uint16, packet []byte) ([]Flow, error) {
+	r := reader{buf: packet}
+	var domain uint32
+	templateSetID, optionsSetID := uint16(0), uint16(1)
+	if version == 9 {
+		r.bytes(16) // version, count, uptime, time, sequence
+		domain = r.u32()
+	} else {
+		templateSetID, optionsSetID = 2, 3
+		r.u16() // version
+		if length := int(r.u16()); length >= 16 && length < len(packet) {
+			r.buf = packet[4:length]
+		}
+		r.bytes(8) // time, sequence
+		domain = r.u32()
+	}
+	if r.short {
+		return nil, errShort
+	}
+
+	var result []Flow
+	for len(r.buf) >= 4 {
+		setID, length := r.u16(), int(r.u16())
+		if length < 4 {
+			return result, fmt.Errorf("set of length %d", length)
+		}
+		set := reader{buf: r.bytes(length - 4)}
+		if r.short {
+			return result, errShort
+		}
+		switch {
+		case setID == templateSetID:
+			d.decodeTemplates(exporter, domain, version, &set)
+		case setID == optionsSetID:
+			// Options, e.g. of sampling, aren't flows
+		case setID >= 256:
+			d.mtx.Lock()
+			fields, ok := d.templates[templateKey{exporter, domain, setID}]
+			d.mtx.Unlock()
+			if !ok {
+				continue
+			}
+			result = append(result, decodeRecords(exporter, fields, &set)...)
+		}
+	}
+	return result, nil
+}
+
+func (d *Decoder) decodeTemplates(exporter string, domain uint32, version uint16, set *reader) {
+	for len(set.buf) >= 4 {
+		id, count := set.u16(), int(set.u16())
+		fields := make([]templateField, 0, count)
+		for i := 0; i < count && !set.short; i++ {
+			field := templateField{id: set.u16(), length: set.u16()}
+			// IPFIX enterprise fields have their enterprise number next
+			if version == 10 && field.id&0x8000 != 0 {
+				set.u32()
+				field.id = 0
+			}
+			fields = append(fields, field)
+		}
+		if set.short {
+			return
+		}
+		d.mtx.Lock()
+		if count == 0 {
+			// Withdrawn
+			delete(d.templates, templateKey{exporter, domain, id})
+		} else {
+			d.templates[templateKey{exporter, domain, id}] = fields
+		}
+		d.mtx.Unlock()
+	}
+}
+
+func decodeRecords(exporter string, fields []templateField, set *reader) []Flow {
+	var result []Flow
+	// Sets are padded to 4 bytes, which is shorter than any record
+	for len(set.buf) >= 4 {
+		before := len(set.buf)
+		flow := Flow{Exporter: exporter}
+		var protocol uint8
+		samplingInterval := uint64(1)
+		for _, field := range fields {
+			length := int(field.length)
+			if length == variableLength {
+				if length = int(set.u8()); length == 255 {
+					length = int(set.u16())
+				}
+			}
+			value := set.bytes(length)
+			if set.short {
+				break
+			}
+			switch field.id {
+			case fieldBytes:
+				flow.Bytes = readUint(value)
+			case fieldPackets:
+				flow.Packets = readUint(value)
+			case fieldProtocol:
+				protocol = uint8(readUint(value))
+			case fieldSrcPort:
+				flow.SrcPort = uint16(readUint(value))
+			case fieldDstPort:
+				flow.DstPort = uint16(readUint(value))
+			case fieldSrcIPv4, fieldSrcIPv6:
+				flow.SrcAddr = ip(value)
+			case fieldDstIPv4, fieldDstIPv6:
+				flow.DstAddr = ip(value)
+			case fieldSamplingInterval:
+				if v := readUint(value); v > 0 {
+					samplingInterval = v
+				}
+			}
+		}
+		if set.short || len(set.buf) == before {
+			break
+		}
+		var ok bool
+		if flow.Protocol, ok = protocols[protocol]; !ok || flow.SrcAddr == nil || flow.DstAddr == nil {
+			continue
+		}
+		flow.Bytes *= samplingInterval
+		flow.Packets *= samplingInterval
+		result = append(result, flow)
+	}
+	return result
+}
+
+// Formats of sFlow samples and records, of the standard (0) enterprise.
+const (
+	sFlowFlowSample         = 1
+	sFlowExpandedFlowSample = 3
+	sFlowRawPacketHeader    = 1
+	sFlowSampledIPv4        = 3
+	sFlowSampledIPv6        = 4
+	sFlowEthernet           = 1 // protocol of raw packet headers
+)
+
+// decodeSFlow decodes sFlow v5 datagrams, of samples of packets, the flow
+// samples of which we make flows of.
+func decodeSFlow(exporter string, packet []byte) ([]Flow, error) {
+	r := reader{buf: packet}
+	r.u32() // version
+	switch r.u32() {
+	case 1:
+		r.bytes(4)
+	case 2:
+		r.bytes(16)
+	default:
+		return nil, fmt.Errorf("unknown sFlow agent address type")
+	}
+	r.bytes(12) // sub-agent, sequence, uptime
+	samples := int(r.u32())
+
+	var result []Flow
+	for i := 0; i < samples && !r.short; i++ {
+		format, length := r.u32(), int(r.u32())
+		sample := reader{buf: r.bytes(length)}
+		if r.short {
+			break
+		}
+		var samplingRate uint32
+		switch format {
+		case sFlowFlowSample:
+			sample.bytes(8) // sequence, source
+			samplingRate = sample.u32()
+			sample.bytes(16) // pool, drops, input, output
+		case sFlowExpandedFlowSample:
+			sample.bytes(12) // sequence, source
+			samplingRate = sample.u32()
+			sample.bytes(24) // pool, drops, input, output
+		default:
+			// Counter samples, and those of other enterprises
+			continue
+		}
+		records := int(sample.u32())
+		for j := 0; j < records && !sample.short; j++ {
+			recordFormat, recordLength := sample.u32(), int(sample.u32())
+			record := reader{buf: sample.bytes((recordLength + 3) &^ 3)}
+			if sample.short {
+				break
+			}
+			flow, ok := decodeSFlowRecord(recordFormat, &record)
+			if !ok || record.short {
+				continue
+			}
+			flow.Exporter = exporter
+			flow.Packets = uint64(samplingRate)
+			flow.Bytes *= uint64(samplingRate)
+			result = append(result, flow)
+		}
+	}
+	if r.short {
+		return result, errShort
+	}
+	return result, nil
+}
+
+func decodeSFlowRecord(format uint32, record *reader) (Flow, bool) {
+	var flow Flow
+	var protocol uint32
+	switch format {
+	case sFlowRawPacketHeader:
+		headerProtocol := record.u32()
+		flow.Bytes = uint64(record.u32()) // of the frame
+		record.u32()                      // stripped
+		header := record.bytes(int(record.u32()))
+		if record.short || headerProtocol != sFlowEthernet {
+			return flow, false
+		}
+		frameLength := flow.Bytes
+		flow, ok := decodeEthernet(header)
+		flow.Bytes = frameLength
+		return flow, ok
+	case sFlowSampledIPv4, sFlowSampledIPv6:
+		flow.Bytes = uint64(record.u32())
+		protocol = record.u32()
+		addrLen := net.IPv4len
+		if format == sFlowSampledIPv6 {
+			addrLen = net.IPv6len
+		}
+		flow.SrcAddr = ip(record.bytes(addrLen))
+		flow.DstAddr = ip(record.bytes(addrLen))
+		flow.SrcPort = uint16(record.u32())
+		flow.DstPort = uint16(record.u32())
+	default:
+		return flow, false
+	}
+	var ok bool
+	flow.Protocol, ok = protocols[uint8(protocol)]
+	return flow, ok
+}
+
+// decodeEthernet decodes the addresses and ports of the IP packet of the
+// (truncated) header of an Ethernet frame.
+func decodeEthernet(header []byte) (Flow, bool) {
+	const (
+		etherTypeIPv4 = 0x0800
+		etherTypeIPv6 = 0x86dd
+		etherTypeVLAN = 0x8100
+	)
+	var flow Flow
+	r := reader{buf: header}
+	r.bytes(12) // MAC addresses
+	etherType := r.u16()
+	if etherType == etherTypeVLAN {
+		r.u16()
+		etherType = r.u16()
+	}
+	var protocol uint8
+	switch etherType {
+	case etherTypeIPv4:
+		packet := reader{buf: r.buf}
+		headerLen := int(packet.u8()&0x0f) * 4
+		packet.bytes(8)
+		protocol = packet.u8()
+		packet.bytes(2) // checksum
+		flow.SrcAddr = ip(packet.bytes(4))
+		flow.DstAddr = ip(packet.bytes(4))
+		r.bytes(headerLen)
+		if packet.short {
+			return flow, false
+		}
+	case etherTypeIPv6:
+		r.bytes(6)
+		protocol = r.u8()
+		r.u8() // hop limit
+		flow.SrcAddr = ip(r.bytes(16))
+		flow.DstAddr = ip(r.bytes(16))
+	default:
+		return flow, false
+	}
+	flow.SrcPort, flow.DstPort = r.u16(), r.u16()
+	var ok bool
+	flow.Protocol, ok = protocols[protocol]
+	return flow, ok && !r.short
+}
@@ -0,0 +1,190 @@
+package flows_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/flows"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+// packet writes the fields, of fixed size, of a packet in network order.
+func packet(fields ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, field := range fields {
+		if ip, ok := field.(net.IP); ok {
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			field = []byte(ip)
+		}
+		if err := binary.Write(&buf, binary.BigEndian, field); err != nil {
+			panic(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+var (
+	src = net.ParseIP("10.0.0.1").To4()
+	dst = net.ParseIP("10.0.0.2").To4()
+)
+
+var wantFlow = flows.Flow{
+	Exporter: "192.168.1.1",
+	Protocol: report.TCP,
+	SrcAddr:  src,
+	SrcPort:  40000,
+	DstAddr:  dst,
+	DstPort:  80,
+	Bytes:    8400,
+	Packets:  100,
+}
+
+func TestNetFlowV5(t *testing.T) {
+	p := packet(
+		// header: version, count, uptime, time, nsecs, sequence, engine, sampling
+		uint16(5), uint16(2), uint32(0), uint32(1500000000), uint32(0), uint32(1), uint16(0), uint16(0x4000|10),
+		// TCP record
+		src, dst, uint32(0), uint16(1), uint16(2), uint32(10), uint32(840), uint32(0), uint32(0),
+		uint16(40000), uint16(80), uint8(0), uint8(0x18), uint8(6), uint8(0), uint16(0), uint16(0), uint8(24), uint8(24), uint16(0),
+		// ICMP record
+		src, dst, uint32(0), uint16(1), uint16(2), uint32(1), uint32(84), uint32(0), uint32(0),
+		uint16(0), uint16(0), uint8(0), uint8(0), uint8(1), uint8(0), uint16(0), uint16(0), uint8(24), uint8(24), uint16(0),
+	)
+	have, err := flows.NewDecoder().Decode("192.168.1.1", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []flows.Flow{wantFlow}; !reflect.DeepEqual(want, have) {
+		t.Errorf("Expected %v, got %v", want, have)
+	}
+}
+
+func TestNetFlowV9(t *testing.T) {
+	header := []interface{}{
+		// version, count, uptime, time, sequence, source ID
+		uint16(9), uint16(1), uint32(0), uint32(1500000000), uint32(1), uint32(7),
+	}
+	data := packet(append(header,
+		// data set of template 256: src, dst, ports, protocol, bytes (in 2 bytes), packets
+		uint16(256), uint16(4+19+1),
+		src, dst, uint16(40000), uint16(80), uint8(6), uint16(8400), uint32(100),
+		uint8(0), // padding
+	)...)
+
+	decoder := flows.NewDecoder()
+	if have, err := decoder.Decode("192.168.1.1", data); err != nil || len(have) != 0 {
+		t.Fatalf("Expected no flows before the template, got %v, %v", have, err)
+	}
+	template := packet(append(header,
+		uint16(0), uint16(4+4+7*4),
+		uint16(256), uint16(7),
+		uint16(8), uint16(4), uint16(12), uint16(4), uint16(7), uint16(2), uint16(11), uint16(2),
+		uint16(4), uint16(1), uint16(1), uint16(2), uint16(2), uint16(4),
+	)...)
+	if _, err := decoder.Decode("192.168.1.1", template); err != nil {
+		t.Fatal(err)
+	}
+	have, err := decoder.Decode("192.168.1.1", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []flows.Flow{wantFlow}; !reflect.DeepEqual(want, have) {
+		t.Errorf("Expected %v, got %v", want, have)
+	}
+	// Templates are those of each exporter
+	if have, _ := decoder.Decode("192.168.1.2", data); len(have) != 0 {
+		t.Errorf("Expected no flows of another exporter, got %v", have)
+	}
+}
+
+func TestIPFIX(t *testing.T) {
+	srcIPv6, dstIPv6 := net.ParseIP("fd00::1"), net.ParseIP("fd00::2")
+	sets := packet(
+		// template set: IPv6 addresses, ports, protocol, an enterprise field, a variable-length one, bytes, packets
+		uint16(2), uint16(4+4+9*4+4),
+		uint16(300), uint16(9),
+		uint16(27), uint16(16), uint16(28), uint16(16), uint16(7), uint16(2), uint16(11), uint16(2),
+		uint16(4), uint16(1), uint16(0x8000|1), uint16(4), uint32(9), uint16(82), uint16(65535),
+		uint16(1), uint16(8), uint16(2), uint16(8),
+		// data set
+		uint16(300), uint16(4+16+16+2+2+1+4+1+3+8+8),
+		[]byte(srcIPv6), []byte(dstIPv6), uint16(40000), uint16(80), uint8(6), uint32(0xdeadbeef),
+		uint8(3), []byte("eth"), uint64(8400), uint64(100),
+	)
+	p := packet(
+		// version, length, time, sequence, observation domain
+		uint16(10), uint16(16+len(sets)), uint32(1500000000), uint32(1), uint32(7),
+		sets,
+	)
+	have, err := flows.NewDecoder().Decode("192.168.1.1", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := wantFlow
+	want.SrcAddr, want.DstAddr = srcIPv6, dstIPv6
+	if !reflect.DeepEqual([]flows.Flow{want}, have) {
+		t.Errorf("Expected %v, got %v", []flows.Flow{want}, have)
+	}
+}
+
+func TestSFlow(t *testing.T) {
+	// A sampled Ethernet frame of a TCP segment, in a VLAN
+	frame := packet(
+		[]byte{0, 1, 2, 3, 4, 5}, []byte{6, 7, 8, 9, 10, 11}, uint16(0x8100), uint16(42), uint16(0x0800),
+		// IPv4 header
+		uint8(0x45), uint8(0), uint16(84), uint32(0), uint8(64), uint8(6), uint16(0), src, dst,
+		// TCP header, truncated
+		uint16(40000), uint16(80), uint32(0),
+	)
+	record := packet(
+		// raw packet header: protocol, frame length, stripped, header length, header (padded)
+		uint32(1), uint32(84), uint32(4), uint32(len(frame)), frame, make([]byte, 4-len(frame)%4),
+	)
+	sample := packet(
+		// flow sample: sequence, source, sampling rate, pool, drops, input, output, records
+		uint32(1), uint32(3), uint32(100), uint32(100), uint32(0), uint32(3), uint32(4), uint32(1),
+		uint32(1), uint32(len(frame)+16), record,
+	)
+	p := packet(
+		// version, agent address, sub-agent, sequence, uptime, samples
+		uint32(5), uint32(1), net.ParseIP("192.168.1.1"), uint32(0), uint32(1), uint32(0), uint32(2),
+		// a counter sample, then the flow sample
+		uint32(2), uint32(4), uint32(0),
+		uint32(1), uint32(len(sample)), sample,
+	)
+	have, err := flows.NewDecoder().Decode("192.168.1.1", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []flows.Flow{wantFlow}; !reflect.DeepEqual(want, have) {
+		t.Errorf("Expected %v, got %v", want, have)
+	}
+}
+
+func TestShortPackets(t *testing.T) {
+	header := packet(uint32(5), uint32(1), net.ParseIP("192.168.1.1"), uint32(0), uint32(1), uint32(0))
+	for _, p := range [][]byte{
+		// 4 GiB samples, of many
+		packet(header, uint32(1<<31), uint32(1), uint32(0xffffffff)),
+		// a flow sample of a record of a 4 GiB header
+		packet(header, uint32(1), uint32(1), uint32(48),
+			uint32(1), uint32(3), uint32(100), uint32(100), uint32(0), uint32(3), uint32(4), uint32(1),
+			uint32(1), uint32(16), uint32(1), uint32(84), uint32(4), uint32(0xffffffff)),
+	} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		if have, _ := flows.NewDecoder().Decode("192.168.1.1", p); len(have) != 0 {
+			t.Errorf("Expected no flows, got %v", have)
+		}
+		runtime.ReadMemStats(&after)
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+			t.Errorf("Expected short packets to allocate little, but %d bytes were", allocated)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package flows
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys for use in Node.Latest and Node.Counters.
+const (
+	Exporter    = report.FlowExporter
+	EgressBytes = report.EgressBytes
+)
+
+// The size of the largest UDP payload, which exporters fill at most.
+const maxPacketSize = 65535
+
+type flowKey struct {
+	exporter, protocol, srcAddr, srcPort, dstAddr, dstPort string
+}
+
+type flowState struct {
+	lastSeen time.Time
+	bytes    uint64 // since the last report
+}
+
+// Reporter collects the flows routers and switches export over NetFlow
+// (v5 and v9), IPFIX and sFlow (v5), and generates Reports with their
+// endpoints, and edges between them. Endpoints get the same IDs as those
+// of the probes, so traffic we see on hosts where probes run is merged
+// with that of exporters, and traffic of the others shows up too.
+type Reporter struct {
+	conns   []net.PacketConn
+	decoder *Decoder
+	expiry  time.Duration
+	cluster string
+
+	mtx   sync.Mutex
+	flows map[flowKey]*flowState
+}
+
+// NewReporter makes a new Reporter, receiving exported flows on the given
+// UDP addresses, e.g. ":2055" for NetFlow and IPFIX and ":6343" for
+// sFlow. Any of the protocols can be sent to any of the addresses. Flows
+// are reported until they haven't been exported for the expiry, which
+// should be longer than the active timeouts of the exporters. The
+// addresses of flows are scoped by the cluster, if any, like those of
+// connections the endpoint reporter sees. Don't forget to Stop it.
+func NewReporter(addrs []string, expiry time.Duration, cluster string) (*Reporter, error) {
+	r := &Reporter{
+		decoder: NewDecoder(),
+		expiry:  expiry,
+		cluster: cluster,
+		flows:   map[flowKey]*flowState{},
+	}
+	for _, addr := range addrs {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			r.Stop()
+			return nil, err
+		}
+		r.conns = append(r.conns, conn)
+		go r.loop(conn)
+	}
+	return r, nil
+}
+
+// Addrs returns the addresses the reporter receives flows on. Exposed for
+// testing.
+func (r *Reporter) Addrs() []net.Addr {
+	result := make([]net.Addr, 0, len(r.conns))
+	for _, conn := range r.conns {
+		result = append(result, conn.LocalAddr())
+	}
+	return result
+}
+
+// Stop stops receiving flows.
+func (r *Reporter) Stop() {
+	for _, conn := range r.conns {
+		conn.Close()
+	}
+}
+
+// Name of this reporter, for metrics gathering
+func (*Reporter) Name() string { return "Flows" }
+
+func (r *Reporter) loop(conn net.PacketConn) {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			// Closed when stopped
+			return
+		}
+		exporter := addr.String()
+		if udpAddr, ok := addr.(*net.UDPAddr); ok {
+			exporter = udpAddr.IP.String()
+		}
+		flows, err := r.decoder.Decode(exporter, buf[:n])
+		if err != nil {
+			log.Debugf("Flows: error decoding packet from %s: %v", exporter, err)
+		}
+		r.add(flows)
+	}
+}
+
+func (r *Reporter) add(flows []Flow) {
+	now := mtime.Now()
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, flow := range flows {
+		key := flowKey{
+			exporter: flow.Exporter,
+			protocol: flow.Protocol,
+			srcAddr:  flow.SrcAddr.String(),
+			srcPort:  strconv.Itoa(int(flow.SrcPort)),
+			dstAddr:  flow.DstAddr.String(),
+			dstPort:  strconv.Itoa(int(flow.DstPort)),
+		}
+		state, ok := r.flows[key]
+		if !ok {
+			state = &flowState{}
+			r.flows[key] = state
+		}
+		state.lastSeen = now
+		state.bytes += flow.Bytes
+	}
+}
+
+// Report implements Reporter. The bytes of flows are counted once, in the
+// first report after they were exported.
+func (r *Reporter) Report() (report.Report, error) {
+	result := report.MakeReport()
+	now := mtime.Now()
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for key, state := range r.flows {
+		if now.Sub(state.lastSeen) > r.expiry {
+			delete(r.flows, key)
+			continue
+		}
+		srcID := report.MakeClusterEndpointNodeID(r.cluster, "", "", key.srcAddr, key.srcPort, key.protocol)
+		dstID := report.MakeClusterEndpointNodeID(r.cluster, "", "", key.dstAddr, key.dstPort, key.protocol)
+		src := report.MakeNodeWith(srcID, map[string]string{Exporter: key.exporter}).
+			WithTopology(report.Endpoint).
+			WithAdjacent(dstID)
+		if state.bytes > 0 {
+			src = src.WithCounters(map[string]int{EgressBytes: int(state.bytes)})
+			state.bytes = 0
+		}
+		result.Endpoint.AddNode(src)
+		result.Endpoint.AddNode(report.MakeNode(dstID).WithTopology(report.Endpoint))
+	}
+	return result, nil
+}
@@ -0,0 +1,64 @@
+package flows_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/probe/flows"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+)
+
+func TestReporter(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	reporter, err := flows.NewReporter([]string{"127.0.0.1:0"}, time.Minute, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reporter.Stop()
+	conn, err := net.Dial("udp", reporter.Addrs()[0].String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(packet(
+		uint16(5), uint16(1), uint32(0), uint32(1500000000), uint32(0), uint32(1), uint16(0), uint16(0),
+		src, dst, uint32(0), uint16(1), uint16(2), uint32(10), uint32(840), uint32(0), uint32(0),
+		uint16(40000), uint16(80), uint8(0), uint8(0x18), uint8(6), uint8(0), uint16(0), uint16(0), uint8(24), uint8(24), uint16(0),
+	)); err != nil {
+		t.Fatal(err)
+	}
+
+	srcID := report.MakeEndpointNodeID("", "", "10.0.0.1", "40000")
+	dstID := report.MakeEndpointNodeID("", "", "10.0.0.2", "80")
+	var rpt report.Report
+	test.Poll(t, time.Second, 2, func() interface{} {
+		rpt, _ = reporter.Report()
+		return len(rpt.Endpoint.Nodes)
+	})
+	node := rpt.Endpoint.Nodes[srcID]
+	if !node.Adjacency.Contains(dstID) {
+		t.Errorf("Expected an edge to %s, got %v", dstID, node.Adjacency)
+	}
+	if exporter, _ := node.Latest.Lookup(flows.Exporter); exporter != "127.0.0.1" {
+		t.Errorf("Expected the flow exported by 127.0.0.1, got %q", exporter)
+	}
+	if bytes, _ := node.Counters.Lookup(flows.EgressBytes); bytes != 840 {
+		t.Errorf("Expected 840 bytes sent, got %d", bytes)
+	}
+
+	// Bytes are counted once, and flows expire
+	rpt, _ = reporter.Report()
+	if bytes, ok := rpt.Endpoint.Nodes[srcID].Counters.Lookup(flows.EgressBytes); ok {
+		t.Errorf("Expected the bytes to be counted once, got %d", bytes)
+	}
+	mtime.NowForce(now.Add(2 * time.Minute))
+	if rpt, _ = reporter.Report(); len(rpt.Endpoint.Nodes) != 0 {
+		t.Errorf("Expected the flow to expire, got %v", rpt.Endpoint.Nodes)
+	}
+}
@@ -0,0 +1,261 @@
+package gpu
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/weaveworks/common/exec"
+	"github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/probe/cgroup"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys for use in Node.Latest and Node.Metrics.
+const (
+	HostGPUs                = "host_gpus"
+	HostGPUUsage            = "host_gpu_usage_percent"
+	HostGPUMemoryUsage      = "host_gpu_mem_usage_bytes"
+	ContainerGPUs           = "container_gpus"
+	ContainerGPUUsage       = "container_gpu_usage_percent"
+	ContainerGPUMemoryUsage = "container_gpu_mem_usage_bytes"
+)
+
+// The major number of the character devices of NVIDIA GPUs, /dev/nvidia<minor>.
+const nvidiaMajor = "195"
+
+// Exposed for testing.
+var (
+	HostMetadataTemplates = report.MetadataTemplates{
+		HostGPUs: {ID: HostGPUs, Label: "# GPUs", From: report.FromLatest, Datatype: report.Number, Priority: 4},
+	}
+
+	HostMetricTemplates = report.MetricTemplates{
+		HostGPUUsage:       {ID: HostGPUUsage, Label: "GPU", Format: report.PercentFormat, Priority: 3},
+		HostGPUMemoryUsage: {ID: HostGPUMemoryUsage, Label: "GPU Memory", Format: report.FilesizeFormat, Priority: 4},
+	}
+
+	ContainerMetadataTemplates = report.MetadataTemplates{
+		ContainerGPUs: {ID: ContainerGPUs, Label: "GPUs", From: report.FromLatest, Priority: 16},
+	}
+
+	ContainerMetricTemplates = report.MetricTemplates{
+		ContainerGPUUsage:       {ID: ContainerGPUUsage, Label: "GPU", Format: report.PercentFormat, Priority: 3},
+		ContainerGPUMemoryUsage: {ID: ContainerGPUMemoryUsage, Label: "GPU Memory", Format: report.FilesizeFormat, Priority: 4},
+	}
+)
+
+// GPU is a sample of the usage of a GPU.
+type GPU struct {
+	Minor       int // of its device, /dev/nvidia<minor>
+	Name        string
+	Utilization float64 // percent
+	MemoryUsed  float64 // bytes
+	MemoryTotal float64 // bytes
+}
+
+// QueryGPUs samples the usage of the GPUs of the host through nvidia-smi,
+// the command-line interface of NVML which comes with the driver. It's
+// exposed for testing.
+var QueryGPUs = func() ([]GPU, error) {
+	output, err := exec.Command("nvidia-smi", "--query-gpu=index,name,utilization.gpu,memory.used,memory.total", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseGPUs(output)
+}
+
+func parseGPUs(output []byte) ([]GPU, error) {
+	gpus := []GPU{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) != 5 {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		// The index of a GPU is the minor number of its device, on all but
+		// the hosts numbering their devices unusually
+		minor, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid GPU index %q", fields[0])
+		}
+		gpu := GPU{Minor: minor, Name: fields[1]}
+		// nvidia-smi tells "[N/A]" of what a GPU doesn't support
+		gpu.Utilization, _ = strconv.ParseFloat(fields[2], 64)
+		used, _ := strconv.ParseFloat(fields[3], 64)
+		total, _ := strconv.ParseFloat(fields[4], 64)
+		gpu.MemoryUsed, gpu.MemoryTotal = used*1024*1024, total*1024*1024 // MiB
+		gpus = append(gpus, gpu)
+	}
+	return gpus, scanner.Err()
+}
+
+// Reporter generates Reports with the usage of the NVIDIA GPUs of the host
+// as metrics of its node, and tags the containers allowed to use GPUs with
+// their usage, since GPUs are handed to containers whole.
+type Reporter struct {
+	hostID   string
+	procRoot string
+
+	mtx  sync.Mutex
+	gpus []GPU // as of the last report
+}
+
+// NewReporter makes a new Reporter
+func NewReporter(hostID, procRoot string) *Reporter {
+	return &Reporter{
+		hostID:   hostID,
+		procRoot: procRoot,
+	}
+}
+
+// Name of this reporter, for metrics gathering
+func (*Reporter) Name() string { return "GPU" }
+
+// Report implements Reporter.
+func (r *Reporter) Report() (report.Report, error) {
+	result := report.MakeReport()
+	gpus, err := QueryGPUs()
+	if err != nil {
+		return result, err
+	}
+	r.mtx.Lock()
+	r.gpus = gpus
+	r.mtx.Unlock()
+
+	result.Host = result.Host.WithMetadataTemplates(HostMetadataTemplates).WithMetricTemplates(HostMetricTemplates)
+	node := report.MakeNodeWith(report.MakeHostNodeID(r.hostID), map[string]string{
+		HostGPUs: strconv.Itoa(len(gpus)),
+	})
+	if len(gpus) > 0 {
+		node = node.WithMetrics(usage(gpus, HostGPUUsage, HostGPUMemoryUsage))
+	}
+	result.Host.AddNode(node)
+	return result, nil
+}
+
+// usage returns the utilization of the GPUs, on average, and the memory
+// used on them, in total.
+func usage(gpus []GPU, usageKey, memoryKey string) report.Metrics {
+	now := mtime.Now()
+	var utilization, used, total float64
+	for _, gpu := range gpus {
+		utilization += gpu.Utilization
+		used += gpu.MemoryUsed
+		total += gpu.MemoryTotal
+	}
+	return report.Metrics{
+		usageKey:  report.MakeSingletonMetric(now, utilization/float64(len(gpus))).WithMax(100),
+		memoryKey: report.MakeSingletonMetric(now, used).WithMax(total),
+	}
+}
+
+// Tag implements Tagger, attributing the usage of the GPUs to the containers
+// their devices are allowed to. Containers are found through their
+// processes, so it must run after the taggers of the container runtimes.
+func (r *Reporter) Tag(rpt report.Report) (report.Report, error) {
+	r.mtx.Lock()
+	gpus := r.gpus
+	r.mtx.Unlock()
+	if len(gpus) == 0 {
+		return rpt, nil
+	}
+	byMinor := map[int]GPU{}
+	for _, gpu := range gpus {
+		byMinor[gpu.Minor] = gpu
+	}
+
+	pids := map[string]string{} // container node ID -> PID of one of its processes
+	for _, n := range rpt.Process.Nodes {
+		containers, ok := n.Parents.Lookup(report.Container)
+		if !ok || len(containers) == 0 {
+			continue
+		}
+		if pid, ok := n.Latest.Lookup(process.PID); ok {
+			pids[containers[0]] = pid
+		}
+	}
+
+	tagged := false
+	for id, pid := range pids {
+		n, ok := rpt.Container.Nodes[id]
+		if !ok {
+			continue
+		}
+		allowed, minors := []GPU{}, []string{}
+		for _, minor := range r.allowedMinors(pid) {
+			if gpu, ok := byMinor[minor]; ok {
+				allowed = append(allowed, gpu)
+				minors = append(minors, strconv.Itoa(minor))
+			}
+		}
+		if len(allowed) == 0 {
+			continue
+		}
+		rpt.Container.Nodes[id] = n.
+			WithLatests(map[string]string{ContainerGPUs: strings.Join(minors, ", ")}).
+			WithMetrics(usage(allowed, ContainerGPUUsage, ContainerGPUMemoryUsage))
+		tagged = true
+	}
+	if tagged {
+		rpt.Container = rpt.Container.WithMetadataTemplates(ContainerMetadataTemplates).WithMetricTemplates(ContainerMetricTemplates)
+	}
+	return rpt, nil
+}
+
+// allowedMinors returns the minor numbers of the GPU devices the cgroup of
+// a process allows it to use. The devices controller of cgroup v2 has no
+// list of allowances to read, so there it's the GPU devices the container
+// runtime created in the /dev of the process instead.
+func (r *Reporter) allowedMinors(pid string) []int {
+	minors := map[int]struct{}{}
+	if dir, err := cgroup.Dir(r.procRoot, pid, "devices"); err == nil {
+		list, err := fs.ReadFile(filepath.Join(dir, "devices.list"))
+		if err != nil {
+			return nil
+		}
+		// Entries are "<type> <major>:<minor> <access>", e.g. "c 195:0 rwm";
+		// "a *:* rwm" allows all devices, as to privileged containers, which
+		// doesn't tell anything about GPUs.
+		for _, entry := range strings.Split(string(list), "\n") {
+			fields := strings.Fields(entry)
+			if len(fields) != 3 || fields[0] != "c" || !strings.HasPrefix(fields[1], nvidiaMajor+":") {
+				continue
+			}
+			if minor, err := strconv.Atoi(strings.TrimPrefix(fields[1], nvidiaMajor+":")); err == nil {
+				minors[minor] = struct{}{}
+			}
+		}
+		return sortedMinors(minors)
+	}
+
+	devices, err := fs.ReadDirNames(filepath.Join(r.procRoot, pid, "root", "dev"))
+	if err != nil {
+		return nil
+	}
+	for _, device := range devices {
+		if minor, err := strconv.Atoi(strings.TrimPrefix(device, "nvidia")); err == nil && strings.HasPrefix(device, "nvidia") {
+			minors[minor] = struct{}{}
+		}
+	}
+	return sortedMinors(minors)
+}
+
+func sortedMinors(minors map[int]struct{}) []int {
+	result := make([]int, 0, len(minors))
+	for minor := range minors {
+		result = append(result, minor)
+	}
+	sort.Ints(result)
+	return result
+}
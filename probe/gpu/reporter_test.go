@@ -0,0 +1,96 @@
+package gpu_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/exec"
+	fs_hook "github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/mtime"
+	testexec "github.com/weaveworks/common/test/exec"
+	"github.com/weaveworks/common/test/fs"
+	"github.com/weaveworks/scope/probe/gpu"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+const nvidiaSMIOutput = `0, Tesla T4, 30, 1024, 15360
+1, Tesla T4, 90, 3072, 15360
+`
+
+var mockFS = fs.Dir("",
+	fs.Dir("proc",
+		// A container allowed GPU 1 by its cgroup (v1)
+		fs.Dir("10", fs.File{FName: "cgroup", FContents: "12:devices:/docker/abc\n4:memory:/docker/abc\n"}),
+		// A container given both GPUs on cgroup v2
+		fs.Dir("20",
+			fs.File{FName: "cgroup", FContents: "0::/system.slice/docker-def.scope\n"},
+			fs.Dir("root", fs.Dir("dev", fs.File{FName: "null"}, fs.File{FName: "nvidia0"}, fs.File{FName: "nvidia1"}, fs.File{FName: "nvidiactl"})),
+		),
+		// A privileged container, allowed all devices
+		fs.Dir("30", fs.File{FName: "cgroup", FContents: "12:devices:/docker/ghi\n"}),
+	),
+	fs.Dir("sys", fs.Dir("fs", fs.Dir("cgroup", fs.Dir("devices", fs.Dir("docker",
+		fs.Dir("abc", fs.File{FName: "devices.list", FContents: "c 1:3 rwm\nc 195:1 rw\nc 195:255 rw\n"}),
+		fs.Dir("ghi", fs.File{FName: "devices.list", FContents: "a *:* rwm\n"}),
+	))))),
+)
+
+func TestReporter(t *testing.T) {
+	mtime.NowForce(time.Unix(1500000000, 0))
+	defer mtime.NowReset()
+	fs_hook.Mock(mockFS)
+	defer fs_hook.Restore()
+	oldExecCmd := exec.Command
+	defer func() { exec.Command = oldExecCmd }()
+	exec.Command = func(name string, args ...string) exec.Cmd {
+		return testexec.NewMockCmdString(nvidiaSMIOutput)
+	}
+
+	reporter := gpu.NewReporter("host1", "/proc")
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := rpt.Host.Nodes[report.MakeHostNodeID("host1")]
+	if have, _ := host.Latest.Lookup(gpu.HostGPUs); have != "2" {
+		t.Errorf("Expected 2 GPUs, got %q", have)
+	}
+	if s, ok := host.Metrics[gpu.HostGPUUsage].LastSample(); !ok || s.Value != 60 {
+		t.Errorf("Expected an average usage of 60%%, got %v", s)
+	}
+	if s, ok := host.Metrics[gpu.HostGPUMemoryUsage].LastSample(); !ok || s.Value != 4096*1024*1024 {
+		t.Errorf("Expected 4GiB of GPU memory used, got %v", s)
+	}
+
+	for pid, container := range map[string]string{"10": "abc", "20": "def", "30": "ghi"} {
+		containerID := report.MakeContainerNodeID(container)
+		rpt.Container.AddNode(report.MakeNode(containerID))
+		rpt.Process.AddNode(report.MakeNodeWith(report.MakeProcessNodeID("host1", pid), map[string]string{process.PID: pid}).
+			WithParents(report.MakeSets().Add(report.Container, report.MakeStringSet(containerID))))
+	}
+	rpt, err = reporter.Tag(rpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for container, want := range map[string]struct {
+		gpus  string
+		usage float64
+	}{
+		"abc": {"1", 90},
+		"def": {"0, 1", 60},
+		"ghi": {"", 0},
+	} {
+		n := rpt.Container.Nodes[report.MakeContainerNodeID(container)]
+		if have, _ := n.Latest.Lookup(gpu.ContainerGPUs); have != want.gpus {
+			t.Errorf("Expected container %s to be allowed GPUs %q, got %q", container, want.gpus, have)
+		}
+		var usage float64
+		if s, ok := n.Metrics[gpu.ContainerGPUUsage].LastSample(); ok {
+			usage = s.Value
+		}
+		if usage != want.usage {
+			t.Errorf("Expected container %s to use %v%% of its GPUs, got %v", container, want.usage, usage)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package host
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys for use in the Node.Latest of hosts running in clouds.
+const (
+	CloudProvider  = "host_cloud_provider"
+	InstanceID     = "host_instance_id"
+	InstanceType   = "host_instance_type"
+	Region         = "host_region"
+	Zone           = "host_availability_zone"
+	CloudTagPrefix = "host_cloud_tag_"
+)
+
+// The clouds we know the metadata services of.
+const (
+	AWS   = "aws"
+	GCP   = "gcp"
+	Azure = "azure"
+)
+
+// Where the metadata services of the clouds are. Exposed for testing.
+var (
+	AWSMetadataURL   = "http://169.254.169.254"
+	GCPMetadataURL   = "http://metadata.google.internal"
+	AzureMetadataURL = "http://169.254.169.254"
+)
+
+// Exposed for testing.
+var (
+	CloudMetadataTemplates = report.MetadataTemplates{
+		CloudProvider: {ID: CloudProvider, Label: "Cloud", From: report.FromLatest, Priority: 4},
+		InstanceType:  {ID: InstanceType, Label: "Instance Type", From: report.FromLatest, Priority: 5},
+		Region:        {ID: Region, Label: "Region", From: report.FromLatest, Priority: 6},
+		Zone:          {ID: Zone, Label: "Availability Zone", From: report.FromLatest, Priority: 7},
+		InstanceID:    {ID: InstanceID, Label: "Instance ID", From: report.FromLatest, Priority: 15},
+	}
+
+	CloudTableTemplates = report.TableTemplates{
+		CloudTagPrefix: {
+			ID:     CloudTagPrefix,
+			Label:  "Cloud Tags",
+			Type:   report.PropertyListType,
+			Prefix: CloudTagPrefix,
+		},
+	}
+)
+
+// CloudMetadata is what the metadata service of a cloud tells of the
+// instance a host is.
+type CloudMetadata struct {
+	Provider     string
+	InstanceID   string
+	InstanceType string
+	Region       string
+	Zone         string
+	Tags         map[string]string
+}
+
+func (m CloudMetadata) latests() map[string]string {
+	result := map[string]string{
+		CloudProvider: m.Provider,
+		InstanceID:    m.InstanceID,
+		InstanceType:  m.InstanceType,
+		Region:        m.Region,
+		Zone:          m.Zone,
+	}
+	for key, value := range m.Tags {
+		result[CloudTagPrefix+key] = value
+	}
+	return result
+}
+
+// The metadata services answer right away, if they're there at all.
+var metadataClient = &http.Client{Timeout: 2 * time.Second}
+
+func metadataGet(url string, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Got %d from %s", resp.StatusCode, url)
+	}
+	switch out := out.(type) {
+	case *string:
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, resp.Body); err != nil {
+			return err
+		}
+		*out = buf.String()
+		return nil
+	default:
+		return codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(out)
+	}
+}
+
+// awsMetadata asks the instance metadata service of EC2, with a token of
+// IMDSv2 if we can get one. Tags are only there if the instance allows
+// them in its metadata options.
+func awsMetadata() (CloudMetadata, error) {
+	headers := map[string]string{}
+	req, err := http.NewRequest("PUT", AWSMetadataURL+"/latest/api/token", nil)
+	if err != nil {
+		return CloudMetadata{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	if resp, err := metadataClient.Do(req); err == nil {
+		var token string
+		if resp.StatusCode == http.StatusOK {
+			var buf bytes.Buffer
+			io.Copy(&buf, resp.Body)
+			token = buf.String()
+		}
+		resp.Body.Close()
+		if token != "" {
+			headers["X-aws-ec2-metadata-token"] = token
+		}
+	}
+
+	var document struct {
+		InstanceID       string `codec:"instanceId"`
+		InstanceType     string `codec:"instanceType"`
+		Region           string `codec:"region"`
+		AvailabilityZone string `codec:"availabilityZone"`
+	}
+	if err := metadataGet(AWSMetadataURL+"/latest/dynamic/instance-identity/document", headers, &document); err != nil {
+		return CloudMetadata{}, err
+	}
+	if document.InstanceID == "" {
+		return CloudMetadata{}, fmt.Errorf("no instance ID in the identity document")
+	}
+	result := CloudMetadata{
+		Provider:     AWS,
+		InstanceID:   document.InstanceID,
+		InstanceType: document.InstanceType,
+		Region:       document.Region,
+		Zone:         document.AvailabilityZone,
+		Tags:         map[string]string{},
+	}
+	var keys string
+	if err := metadataGet(AWSMetadataURL+"/latest/meta-data/tags/instance", headers, &keys); err != nil {
+		return result, nil
+	}
+	scanner := bufio.NewScanner(strings.NewReader(keys))
+	for scanner.Scan() {
+		key := scanner.Text()
+		var value string
+		if key == "" || metadataGet(AWSMetadataURL+"/latest/meta-data/tags/instance/"+key, headers, &value) != nil {
+			continue
+		}
+		result.Tags[key] = value
+	}
+	return result, nil
+}
+
+// gcpMetadata asks the metadata server of Compute Engine. Labels are its
+// tags; its network tags are left out, as they have no values.
+func gcpMetadata() (CloudMetadata, error) {
+	var instance struct {
+		ID          uint64            `codec:"id"`
+		MachineType string            `codec:"machineType"` // e.g. "projects/123/machineTypes/n1-standard-1"
+		Zone        string            `codec:"zone"`        // e.g. "projects/123/zones/us-central1-a"
+		Labels      map[string]string `codec:"labels"`
+	}
+	err := metadataGet(GCPMetadataURL+"/computeMetadata/v1/instance/?recursive=true", map[string]string{"Metadata-Flavor": "Google"}, &instance)
+	if err != nil {
+		return CloudMetadata{}, err
+	}
+	zone := instance.Zone[strings.LastIndex(instance.Zone, "/")+1:]
+	region := zone
+	if i := strings.LastIndex(zone, "-"); i >= 0 {
+		region = zone[:i]
+	}
+	return CloudMetadata{
+		Provider:     GCP,
+		InstanceID:   strconv.FormatUint(instance.ID, 10),
+		InstanceType: instance.MachineType[strings.LastIndex(instance.MachineType, "/")+1:],
+		Region:       region,
+		Zone:         zone,
+		Tags:         instance.Labels,
+	}, nil
+}
+
+// azureMetadata asks the instance metadata service of Azure. Zones are
+// numbered within their region, so we prefix them with it, e.g. "eastus-1".
+func azureMetadata() (CloudMetadata, error) {
+	var compute struct {
+		VMID     string `codec:"vmId"`
+		VMSize   string `codec:"vmSize"`
+		Location string `codec:"location"`
+		Zone     string `codec:"zone"`
+		TagsList []struct {
+			Name  string `codec:"name"`
+			Value string `codec:"value"`
+		} `codec:"tagsList"`
+	}
+	err := metadataGet(AzureMetadataURL+"/metadata/instance/compute?api-version=2021-02-01", map[string]string{"Metadata": "true"}, &compute)
+	if err != nil {
+		return CloudMetadata{}, err
+	}
+	if compute.VMID == "" {
+		return CloudMetadata{}, fmt.Errorf("no VM ID in the compute metadata")
+	}
+	result := CloudMetadata{
+		Provider:     Azure,
+		InstanceID:   compute.VMID,
+		InstanceType: compute.VMSize,
+		Region:       compute.Location,
+		Zone:         compute.Location,
+		Tags:         map[string]string{},
+	}
+	if compute.Zone != "" {
+		result.Zone = compute.Location + "-" + compute.Zone
+	}
+	for _, tag := range compute.TagsList {
+		result.Tags[tag.Name] = tag.Value
+	}
+	return result, nil
+}
+
+var cloudProviders = []struct {
+	name  string
+	fetch func() (CloudMetadata, error)
+}{
+	{AWS, awsMetadata},
+	{GCP, gcpMetadata},
+	{Azure, azureMetadata},
+}
+
+// CloudTagger tags the host node with the metadata of the instance it is in
+// the cloud it runs in, if any: its type, region, availability zone and
+// tags. The metadata is fetched in the background, and again every refresh
+// interval, as tags change.
+type CloudTagger struct {
+	hostNodeID string
+	quit       chan struct{}
+
+	mtx      sync.Mutex
+	provider string // once we found out
+	metadata *CloudMetadata
+}
+
+// NewCloudTagger makes a new CloudTagger. Don't forget to Stop it.
+func NewCloudTagger(hostID string, refresh time.Duration) *CloudTagger {
+	t := &CloudTagger{
+		hostNodeID: report.MakeHostNodeID(hostID),
+		quit:       make(chan struct{}),
+	}
+	go t.loop(refresh)
+	return t
+}
+
+// Name of this tagger, for metrics gathering
+func (*CloudTagger) Name() string { return "Cloud" }
+
+// Stop stops refreshing the metadata.
+func (t *CloudTagger) Stop() {
+	close(t.quit)
+}
+
+func (t *CloudTagger) loop(refresh time.Duration) {
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	for {
+		t.refresh()
+		select {
+		case <-ticker.C:
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// refresh fetches the metadata from the service of the cloud we run in,
+// trying them all until one answers.
+func (t *CloudTagger) refresh() {
+	t.mtx.Lock()
+	provider := t.provider
+	t.mtx.Unlock()
+	for _, p := range cloudProviders {
+		if provider != "" && p.name != provider {
+			continue
+		}
+		metadata, err := p.fetch()
+		if err != nil {
+			if provider != "" {
+				log.Warnf("Cloud: failed to refresh %s instance metadata: %v", provider, err)
+			}
+			continue
+		}
+		if provider == "" {
+			log.Infof("Cloud: running in %s, as instance %s in %s", metadata.Provider, metadata.InstanceID, metadata.Zone)
+		}
+		t.mtx.Lock()
+		t.provider, t.metadata = p.name, &metadata
+		t.mtx.Unlock()
+		return
+	}
+}
+
+// Tag implements Tagger.
+func (t *CloudTagger) Tag(rpt report.Report) (report.Report, error) {
+	t.mtx.Lock()
+	metadata := t.metadata
+	t.mtx.Unlock()
+	if metadata == nil {
+		return rpt, nil
+	}
+	node, ok := rpt.Host.Nodes[t.hostNodeID]
+	if !ok {
+		return rpt, nil
+	}
+	rpt.Host = rpt.Host.
+		WithMetadataTemplates(CloudMetadataTemplates).
+		WithTableTemplates(CloudTableTemplates)
+	rpt.Host.AddNode(node.WithLatests(metadata.latests()))
+	return rpt, nil
+}
@@ -0,0 +1,127 @@
+package host_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+)
+
+func awsMetadataService() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" && r.URL.Path == "/latest/api/token" {
+			fmt.Fprint(w, "token1")
+			return
+		}
+		if r.Header.Get("X-aws-ec2-metadata-token") != "token1" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/latest/dynamic/instance-identity/document":
+			fmt.Fprint(w, `{"instanceId": "i-0123456789abcdef0", "instanceType": "m5.large", "region": "eu-west-1", "availabilityZone": "eu-west-1b"}`)
+		case "/latest/meta-data/tags/instance":
+			fmt.Fprint(w, "Name\nteam")
+		case "/latest/meta-data/tags/instance/Name":
+			fmt.Fprint(w, "web-1")
+		case "/latest/meta-data/tags/instance/team":
+			fmt.Fprint(w, "shop")
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func gcpMetadataService() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Path != "/computeMetadata/v1/instance/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"id": 4520031799277581759, "machineType": "projects/123/machineTypes/n1-standard-2", "zone": "projects/123/zones/us-central1-a", "labels": {"team": "shop"}}`)
+	}
+}
+
+func azureMetadataService() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" || r.URL.Path != "/metadata/instance/compute" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"vmId": "02aab8a4-74ef-476e-8182-f6d2ba4166a6", "vmSize": "Standard_D2s_v3", "location": "eastus", "zone": "2", "tagsList": [{"name": "team", "value": "shop"}]}`)
+	}
+}
+
+func TestCloudTagger(t *testing.T) {
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	oldAWS, oldGCP, oldAzure := host.AWSMetadataURL, host.GCPMetadataURL, host.AzureMetadataURL
+	defer func() {
+		host.AWSMetadataURL, host.GCPMetadataURL, host.AzureMetadataURL = oldAWS, oldGCP, oldAzure
+	}()
+
+	for _, tc := range []struct {
+		provider string
+		service  http.HandlerFunc
+		want     map[string]string
+	}{
+		{host.AWS, awsMetadataService(), map[string]string{
+			host.InstanceID:              "i-0123456789abcdef0",
+			host.InstanceType:            "m5.large",
+			host.Region:                  "eu-west-1",
+			host.Zone:                    "eu-west-1b",
+			host.CloudTagPrefix + "Name": "web-1",
+			host.CloudTagPrefix + "team": "shop",
+			host.CloudProvider:           host.AWS,
+		}},
+		{host.GCP, gcpMetadataService(), map[string]string{
+			host.InstanceID:              "4520031799277581759",
+			host.InstanceType:            "n1-standard-2",
+			host.Region:                  "us-central1",
+			host.Zone:                    "us-central1-a",
+			host.CloudTagPrefix + "team": "shop",
+			host.CloudProvider:           host.GCP,
+		}},
+		{host.Azure, azureMetadataService(), map[string]string{
+			host.InstanceID:              "02aab8a4-74ef-476e-8182-f6d2ba4166a6",
+			host.InstanceType:            "Standard_D2s_v3",
+			host.Region:                  "eastus",
+			host.Zone:                    "eastus-2",
+			host.CloudTagPrefix + "team": "shop",
+			host.CloudProvider:           host.Azure,
+		}},
+	} {
+		service := httptest.NewServer(tc.service)
+		host.AWSMetadataURL, host.GCPMetadataURL, host.AzureMetadataURL = notFound.URL, notFound.URL, notFound.URL
+		switch tc.provider {
+		case host.AWS:
+			host.AWSMetadataURL = service.URL
+		case host.GCP:
+			host.GCPMetadataURL = service.URL
+		case host.Azure:
+			host.AzureMetadataURL = service.URL
+		}
+
+		tagger := host.NewCloudTagger("host1", time.Hour)
+		hostNodeID := report.MakeHostNodeID("host1")
+		test.Poll(t, time.Second, tc.want, func() interface{} {
+			rpt := report.MakeReport()
+			rpt.Host.AddNode(report.MakeNode(hostNodeID))
+			rpt, _ = tagger.Tag(rpt)
+			have := map[string]string{}
+			for key := range tc.want {
+				if value, ok := rpt.Host.Nodes[hostNodeID].Latest.Lookup(key); ok {
+					have[key] = value
+				}
+			}
+			return have
+		})
+		tagger.Stop()
+		service.Close()
+	}
+}
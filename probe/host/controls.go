@@ -4,33 +4,43 @@ import (
 	"os/exec"
 
 	log "github.com/Sirupsen/logrus"
-	"github.com/docker/docker/pkg/term"
-	"github.com/kr/pty"
 
+	"github.com/weaveworks/scope/common/profile"
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/probe/controls"
 )
 
 // Control IDs used by the host integration.
 const (
-	ExecHost      = "host_exec"
-	ResizeExecTTY = "host_resize_exec_tty"
+	ExecHost       = "host_exec"
+	ResizeExecTTY  = "host_resize_exec_tty"
+	CapturePackets = "host_capture_packets"
+
+	// Profiles of the probe itself, of its host
+	ProfileProbeCPU  = "host_profile_probe_cpu"
+	ProfileProbeHeap = "host_profile_probe_heap"
 )
 
 func (r *Reporter) registerControls() {
 	r.handlerRegistry.Register(ExecHost, r.execHost)
 	r.handlerRegistry.Register(ResizeExecTTY, xfer.ResizeTTYControlWrapper(r.resizeExecTTY))
+	r.handlerRegistry.Register(CapturePackets, r.capturePackets)
+	r.handlerRegistry.Register(ProfileProbeCPU, r.profileProbe(profile.CPU))
+	r.handlerRegistry.Register(ProfileProbeHeap, r.profileProbe(profile.Heap))
 }
 
 func (r *Reporter) deregisterControls() {
 	r.handlerRegistry.Rm(ExecHost)
 	r.handlerRegistry.Rm(ResizeExecTTY)
+	r.handlerRegistry.Rm(CapturePackets)
+	r.handlerRegistry.Rm(ProfileProbeCPU)
+	r.handlerRegistry.Rm(ProfileProbeHeap)
 }
 
 func (r *Reporter) execHost(req xfer.Request) xfer.Response {
 	cmd := exec.Command(r.hostShellCmd[0], r.hostShellCmd[1:]...)
 	cmd.Env = []string{"TERM=xterm"}
-	ptyPipe, err := pty.Start(cmd)
+	ptyPipe, err := startPty(cmd)
 	if err != nil {
 		return xfer.ResponseError(err)
 	}
@@ -70,6 +80,18 @@ func (r *Reporter) execHost(req xfer.Request) xfer.Response {
 	}
 }
 
+// capturePackets captures the packets of the host, in the network namespace
+// of the probe, which is that of the host.
+func (r *Reporter) capturePackets(req xfer.Request) xfer.Response {
+	return controls.CapturePackets(r.pipes, req, 0, r.hostName)
+}
+
+func (r *Reporter) profileProbe(kind string) xfer.ControlHandlerFunc {
+	return func(req xfer.Request) xfer.Response {
+		return controls.Profile(r.pipes, req, kind, "probe-"+r.hostName)
+	}
+}
+
 func (r *Reporter) resizeExecTTY(pipeID string, height, width uint) xfer.Response {
 	r.Lock()
 	fd, ok := r.pipeIDToTTY[pipeID]
@@ -79,12 +101,7 @@ func (r *Reporter) resizeExecTTY(pipeID string, height, width uint) xfer.Respons
 		return xfer.ResponseErrorf("Unknown pipeID (%q)", pipeID)
 	}
 
-	size := term.Winsize{
-		Height: uint16(height),
-		Width:  uint16(width),
-	}
-
-	if err := term.SetWinsize(fd, &size); err != nil {
+	if err := setPtySize(fd, height, width); err != nil {
 		return xfer.ResponseErrorf(
 			"Error setting terminal size (%d, %d) of pipe %s: %v",
 			height, width, pipeID, err)
@@ -0,0 +1,100 @@
+package host
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// DiskStats are the I/O counters of a block device, since the host booted.
+type DiskStats struct {
+	Device       string
+	Reads        uint64
+	Writes       uint64
+	ReadBytes    uint64
+	WrittenBytes uint64
+}
+
+// Filesystem is the capacity and usage of a mounted filesystem.
+type Filesystem struct {
+	Mountpoint string
+	Device     string
+	Type       string
+	Size       uint64
+	Used       uint64
+}
+
+// diskIO returns the rates of I/O of the disks, per device and in total,
+// since the previous sample.
+func diskIO(previous, current []DiskStats, elapsed time.Duration) ([]report.Row, map[string]float64) {
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		return nil, nil
+	}
+	totals := map[string]float64{DiskReadIOPS: 0, DiskWriteIOPS: 0, DiskReadThroughput: 0, DiskWriteThroughput: 0}
+	before := map[string]DiskStats{}
+	for _, disk := range previous {
+		before[disk.Device] = disk
+	}
+	rows := []report.Row{}
+	for _, disk := range current {
+		prev, ok := before[disk.Device]
+		// Counters only go down when they wrap, or the device was replaced.
+		if !ok || disk.Reads < prev.Reads || disk.Writes < prev.Writes || disk.ReadBytes < prev.ReadBytes || disk.WrittenBytes < prev.WrittenBytes {
+			continue
+		}
+		rates := map[string]float64{
+			DiskReadIOPS:        float64(disk.Reads-prev.Reads) / seconds,
+			DiskWriteIOPS:       float64(disk.Writes-prev.Writes) / seconds,
+			DiskReadThroughput:  float64(disk.ReadBytes-prev.ReadBytes) / seconds,
+			DiskWriteThroughput: float64(disk.WrittenBytes-prev.WrittenBytes) / seconds,
+		}
+		for key, rate := range rates {
+			totals[key] += rate
+		}
+		rows = append(rows, report.Row{
+			ID: disk.Device,
+			Entries: map[string]string{
+				DiskDevice:  disk.Device,
+				DiskReads:   strconv.FormatFloat(rates[DiskReadIOPS], 'f', 1, 64),
+				DiskWrites:  strconv.FormatFloat(rates[DiskWriteIOPS], 'f', 1, 64),
+				DiskRead:    humanize.IBytes(uint64(rates[DiskReadThroughput])) + "/s",
+				DiskWritten: humanize.IBytes(uint64(rates[DiskWriteThroughput])) + "/s",
+			},
+		})
+	}
+	return rows, totals
+}
+
+// filesystemRows returns the rows of the table of filesystems, and the
+// usage of the fullest one.
+func filesystemRows(filesystems []Filesystem) ([]report.Row, float64) {
+	sort.Slice(filesystems, func(i, j int) bool { return filesystems[i].Mountpoint < filesystems[j].Mountpoint })
+	rows := []report.Row{}
+	fullest := 0.0
+	for _, fs := range filesystems {
+		if fs.Size == 0 {
+			continue
+		}
+		usage := float64(fs.Used) * 100 / float64(fs.Size)
+		if usage > fullest {
+			fullest = usage
+		}
+		rows = append(rows, report.Row{
+			ID: fs.Mountpoint,
+			Entries: map[string]string{
+				FilesystemMountpoint: fs.Mountpoint,
+				FilesystemDevice:     fs.Device,
+				FilesystemType:       fs.Type,
+				FilesystemSize:       humanize.IBytes(fs.Size),
+				FilesystemUsed:       humanize.IBytes(fs.Used),
+				FilesystemUsage:      strconv.FormatFloat(usage, 'f', 1, 64),
+			},
+		})
+	}
+	return rows, fullest
+}
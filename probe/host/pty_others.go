@@ -0,0 +1,24 @@
+// +build !windows
+
+package host
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/docker/docker/pkg/term"
+	"github.com/kr/pty"
+)
+
+// startPty starts cmd with a new pty, returning its master.
+func startPty(cmd *exec.Cmd) (*os.File, error) {
+	return pty.Start(cmd)
+}
+
+// setPtySize resizes the pty with the master fd.
+func setPtySize(fd uintptr, height, width uint) error {
+	return term.SetWinsize(fd, &term.Winsize{
+		Height: uint16(height),
+		Width:  uint16(width),
+	})
+}
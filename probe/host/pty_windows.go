@@ -0,0 +1,18 @@
+package host
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errNoPty = errors.New("host shells are not supported on Windows")
+
+// startPty fails: Windows has no ptys, and its console API isn't vendored.
+func startPty(*exec.Cmd) (*os.File, error) {
+	return nil, errNoPty
+}
+
+func setPtySize(uintptr, uint, uint) error {
+	return errNoPty
+}
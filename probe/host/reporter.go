@@ -21,17 +21,48 @@ const (
 	KernelVersion = "kernel_version"
 	Uptime        = "uptime"
 	Load1         = "load1"
+	CPUs          = "host_cpus"
 	CPUUsage      = "host_cpu_usage_percent"
 	MemoryUsage   = "host_mem_usage_bytes"
 	ScopeVersion  = "host_scope_version"
+
+	DiskReadIOPS           = "host_disk_read_iops"
+	DiskWriteIOPS          = "host_disk_write_iops"
+	DiskReadThroughput     = "host_disk_read_bytes_per_second"
+	DiskWriteThroughput    = "host_disk_write_bytes_per_second"
+	FullestFilesystemUsage = "host_fs_fullest_usage_percent"
+
+	DisksTablePrefix       = "host_disk_table_"
+	FilesystemsTablePrefix = "host_fs_table_"
+)
+
+// Columns of the tables of disks and filesystems.
+const (
+	DiskDevice  = "device"
+	DiskReads   = "reads"
+	DiskWrites  = "writes"
+	DiskRead    = "read"
+	DiskWritten = "written"
+
+	FilesystemMountpoint = "mountpoint"
+	FilesystemDevice     = "device"
+	FilesystemType       = "type"
+	FilesystemSize       = "size"
+	FilesystemUsed       = "used"
+	FilesystemUsage      = "usage"
 )
 
 // Exposed for testing.
 const (
-	ProcUptime  = "/proc/uptime"
-	ProcLoad    = "/proc/loadavg"
-	ProcStat    = "/proc/stat"
-	ProcMemInfo = "/proc/meminfo"
+	ProcUptime    = "/proc/uptime"
+	ProcLoad      = "/proc/loadavg"
+	ProcStat      = "/proc/stat"
+	ProcMemInfo   = "/proc/meminfo"
+	ProcDiskStats = "/proc/diskstats"
+	// The mounts of the host, as seen by its init process, and where the
+	// probe finds their filesystems.
+	ProcMounts = "/proc/1/mounts"
+	HostRoot   = "/proc/1/root"
 )
 
 // Exposed for testing.
@@ -39,6 +70,7 @@ var (
 	MetadataTemplates = report.MetadataTemplates{
 		KernelVersion: {ID: KernelVersion, Label: "Kernel Version", From: report.FromLatest, Priority: 1},
 		Uptime:        {ID: Uptime, Label: "Uptime", From: report.FromLatest, Priority: 2, Datatype: report.Duration},
+		CPUs:          {ID: CPUs, Label: "# CPUs", From: report.FromLatest, Priority: 3, Datatype: report.Number},
 		HostName:      {ID: HostName, Label: "Hostname", From: report.FromLatest, Priority: 11},
 		OS:            {ID: OS, Label: "OS", From: report.FromLatest, Priority: 12},
 		LocalNetworks: {ID: LocalNetworks, Label: "Local Networks", From: report.FromSets, Priority: 13},
@@ -49,6 +81,42 @@ var (
 		CPUUsage:    {ID: CPUUsage, Label: "CPU", Format: report.PercentFormat, Priority: 1},
 		MemoryUsage: {ID: MemoryUsage, Label: "Memory", Format: report.FilesizeFormat, Priority: 2},
 		Load1:       {ID: Load1, Label: "Load (1m)", Format: report.DefaultFormat, Group: "load", Priority: 11},
+
+		DiskReadIOPS:           {ID: DiskReadIOPS, Label: "Disk Reads/s", Format: report.DefaultFormat, Group: "disk", Priority: 5},
+		DiskWriteIOPS:          {ID: DiskWriteIOPS, Label: "Disk Writes/s", Format: report.DefaultFormat, Group: "disk", Priority: 6},
+		DiskReadThroughput:     {ID: DiskReadThroughput, Label: "Disk Read/s", Format: report.FilesizeFormat, Priority: 7},
+		DiskWriteThroughput:    {ID: DiskWriteThroughput, Label: "Disk Written/s", Format: report.FilesizeFormat, Priority: 8},
+		FullestFilesystemUsage: {ID: FullestFilesystemUsage, Label: "Fullest Filesystem", Format: report.PercentFormat, Priority: 9},
+	}
+
+	TableTemplates = report.TableTemplates{
+		DisksTablePrefix: {
+			ID:     DisksTablePrefix,
+			Label:  "Disks",
+			Type:   report.MulticolumnTableType,
+			Prefix: DisksTablePrefix,
+			Columns: []report.Column{
+				{ID: DiskDevice, Label: "Device"},
+				{ID: DiskReads, Label: "Reads/s", DataType: report.Number},
+				{ID: DiskWrites, Label: "Writes/s", DataType: report.Number},
+				{ID: DiskRead, Label: "Read"},
+				{ID: DiskWritten, Label: "Written"},
+			},
+		},
+		FilesystemsTablePrefix: {
+			ID:     FilesystemsTablePrefix,
+			Label:  "Filesystems",
+			Type:   report.MulticolumnTableType,
+			Prefix: FilesystemsTablePrefix,
+			Columns: []report.Column{
+				{ID: FilesystemMountpoint, Label: "Mountpoint"},
+				{ID: FilesystemDevice, Label: "Device"},
+				{ID: FilesystemType, Label: "Type"},
+				{ID: FilesystemSize, Label: "Size"},
+				{ID: FilesystemUsed, Label: "Used"},
+				{ID: FilesystemUsage, Label: "Use %", DataType: report.Number},
+			},
+		},
 	}
 )
 
@@ -63,6 +131,9 @@ type Reporter struct {
 	hostShellCmd    []string
 	handlerRegistry *controls.HandlerRegistry
 	pipeIDToTTY     map[string]uintptr
+
+	disks        []DiskStats // as of the last report
+	disksSampled time.Time
 }
 
 // NewReporter returns a Reporter which produces a report containing host
@@ -116,6 +187,7 @@ func (r *Reporter) Report() (report.Report, error) {
 
 	rep.Host = rep.Host.WithMetadataTemplates(MetadataTemplates)
 	rep.Host = rep.Host.WithMetricTemplates(MetricTemplates)
+	rep.Host = rep.Host.WithTableTemplates(TableTemplates)
 
 	now := mtime.Now()
 	metrics := GetLoad(now)
@@ -124,6 +196,12 @@ func (r *Reporter) Report() (report.Report, error) {
 	memoryUsage, max := GetMemoryUsageBytes()
 	metrics[MemoryUsage] = report.MakeSingletonMetric(now, memoryUsage).WithMax(max)
 
+	diskRows := r.diskRows(now, metrics)
+	filesystems, fullest := filesystemRows(GetFilesystems())
+	if len(filesystems) > 0 {
+		metrics[FullestFilesystemUsage] = report.MakeSingletonMetric(now, fullest).WithMax(100)
+	}
+
 	rep.Host.AddNode(
 		report.MakeNodeWith(report.MakeHostNodeID(r.hostID), map[string]string{
 			report.ControlProbeID: r.probeID,
@@ -133,12 +211,15 @@ func (r *Reporter) Report() (report.Report, error) {
 			KernelVersion:         kernel,
 			Uptime:                strconv.Itoa(int(uptime / time.Second)), // uptime in seconds
 			ScopeVersion:          r.version,
+			CPUs:                  strconv.Itoa(runtime.NumCPU()),
 		}).
 			WithSets(report.MakeSets().
 				Add(LocalNetworks, report.MakeStringSet(localCIDRs...)),
 			).
 			WithMetrics(metrics).
-			WithLatestActiveControls(ExecHost),
+			AddPrefixMulticolumnTable(DisksTablePrefix, diskRows).
+			AddPrefixMulticolumnTable(FilesystemsTablePrefix, filesystems).
+			WithLatestActiveControls(ExecHost, CapturePackets, ProfileProbeCPU, ProfileProbeHeap),
 	)
 
 	rep.Host.Controls.AddControl(report.Control{
@@ -146,10 +227,48 @@ func (r *Reporter) Report() (report.Report, error) {
 		Human: "Exec shell",
 		Icon:  "fa-terminal",
 	})
+	rep.Host.Controls.AddControl(report.Control{
+		ID:     CapturePackets,
+		Human:  "Capture packets",
+		Icon:   "fa-download",
+		Rank:   1,
+		Params: controls.PacketCaptureParams,
+	})
+	rep.Host.Controls.AddControl(report.Control{
+		ID:     ProfileProbeCPU,
+		Human:  "Profile probe CPU",
+		Icon:   "fa-tachometer",
+		Rank:   2,
+		Params: controls.ProfileCPUParams,
+	})
+	rep.Host.Controls.AddControl(report.Control{
+		ID:    ProfileProbeHeap,
+		Human: "Profile probe heap",
+		Icon:  "fa-pie-chart",
+		Rank:  3,
+	})
 
 	return rep, nil
 }
 
+// diskRows samples the I/O of the disks, adding the totals since the last
+// report to the metrics.
+func (r *Reporter) diskRows(now time.Time, metrics report.Metrics) []report.Row {
+	disks := GetDiskStats()
+	r.Lock()
+	previous, sampled := r.disks, r.disksSampled
+	r.disks, r.disksSampled = disks, now
+	r.Unlock()
+	if sampled.IsZero() {
+		return nil
+	}
+	rows, totals := diskIO(previous, disks, now.Sub(sampled))
+	for key, total := range totals {
+		metrics[key] = report.MakeSingletonMetric(now, total)
+	}
+	return rows
+}
+
 // Stop stops the reporter.
 func (r *Reporter) Stop() {
 	r.deregisterControls()
@@ -3,6 +3,7 @@ package host_test
 import (
 	"net"
 	"runtime"
+	"strconv"
 	"testing"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
 )
 
 func TestReporter(t *testing.T) {
@@ -77,6 +79,7 @@ func TestReporter(t *testing.T) {
 		{host.OS, runtime.GOOS},
 		{host.Uptime, uptime},
 		{host.KernelVersion, kernel},
+		{host.CPUs, strconv.Itoa(runtime.NumCPU())},
 	} {
 		if have, ok := node.Latest.Lookup(tuple.key); !ok || have != tuple.want {
 			t.Errorf("Expected %s %q, got %q", tuple.key, tuple.want, have)
@@ -100,3 +103,69 @@ func TestReporter(t *testing.T) {
 		}
 	}
 }
+
+func TestReporterDisks(t *testing.T) {
+	var (
+		start = time.Now()
+		disks = []host.DiskStats{{Device: "sda", Reads: 100, Writes: 1000, ReadBytes: 1 << 20, WrittenBytes: 1 << 30}}
+	)
+	mtime.NowForce(start)
+	defer mtime.NowReset()
+
+	oldGetDiskStats, oldGetFilesystems := host.GetDiskStats, host.GetFilesystems
+	defer func() { host.GetDiskStats, host.GetFilesystems = oldGetDiskStats, oldGetFilesystems }()
+	host.GetDiskStats = func() []host.DiskStats { return disks }
+	host.GetFilesystems = func() []host.Filesystem {
+		return []host.Filesystem{
+			{Mountpoint: "/", Device: "/dev/sda1", Type: "ext4", Size: 100 << 30, Used: 25 << 30},
+			{Mountpoint: "/var/lib/docker", Device: "/dev/sdb1", Type: "xfs", Size: 100 << 30, Used: 90 << 30},
+		}
+	}
+
+	reporter := host.NewReporter("hostid", "hostname", "", "", nil, controls.NewDefaultHandlerRegistry())
+	defer reporter.Stop()
+	nodeID := report.MakeHostNodeID("hostid")
+
+	// Rates of I/O need two samples
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rpt.Host.Nodes[nodeID].Metrics[host.DiskReadIOPS]; ok {
+		t.Errorf("Expected no rates of I/O from the first report")
+	}
+
+	mtime.NowForce(start.Add(10 * time.Second))
+	disks = []host.DiskStats{{Device: "sda", Reads: 200, Writes: 1500, ReadBytes: 11 << 20, WrittenBytes: 1 << 30}}
+	rpt, err = reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := rpt.Host.Nodes[nodeID]
+	for key, want := range map[string]float64{
+		host.DiskReadIOPS:           10,
+		host.DiskWriteIOPS:          50,
+		host.DiskReadThroughput:     1 << 20,
+		host.DiskWriteThroughput:    0,
+		host.FullestFilesystemUsage: 90,
+	} {
+		if sample, ok := node.Metrics[key].LastSample(); !ok || sample.Value != want {
+			t.Errorf("Expected %s metric sample %f, got %v", key, want, sample)
+		}
+	}
+
+	tables := node.ExtractMulticolumnTable(host.TableTemplates[host.DisksTablePrefix])
+	if want := []report.Row{{ID: "sda", Entries: map[string]string{
+		host.DiskDevice:  "sda",
+		host.DiskReads:   "10.0",
+		host.DiskWrites:  "50.0",
+		host.DiskRead:    "1.0 MiB/s",
+		host.DiskWritten: "0 B/s",
+	}}}; !reflect.DeepEqual(want, tables) {
+		t.Errorf("Expected disks %v, got %v", want, tables)
+	}
+	tables = node.ExtractMulticolumnTable(host.TableTemplates[host.FilesystemsTablePrefix])
+	if len(tables) != 2 || tables[1].Entries[host.FilesystemUsage] != "90.0" || tables[1].Entries[host.FilesystemSize] != "100 GiB" {
+		t.Errorf("Expected the filesystems, got %v", tables)
+	}
+}
@@ -84,3 +84,13 @@ var GetCPUUsagePercent = func() (float64, float64) {
 var GetMemoryUsageBytes = func() (float64, float64) {
 	return 0.0, 0.0
 }
+
+// GetDiskStats returns the I/O counters of the disks of the host
+var GetDiskStats = func() []DiskStats {
+	return nil
+}
+
+// GetFilesystems returns the capacity and usage of the filesystems of the host
+var GetFilesystems = func() []Filesystem {
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -15,7 +17,10 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-const kb = 1024
+const (
+	kb         = 1024
+	sectorSize = 512 // of the counters of /proc/diskstats, whatever the device's
+)
 
 // Uname is swappable for mocking in tests.
 var Uname = unix.Uname
@@ -108,3 +113,73 @@ var GetMemoryUsageBytes = func() (float64, float64) {
 	used := meminfo.MemTotal - meminfo.MemFree - meminfo.Buffers - meminfo.Cached
 	return float64(used * kb), float64(meminfo.MemTotal * kb)
 }
+
+// GetDiskStats returns the I/O counters of the disks of the host, leaving
+// out their partitions, as /sys/block lists only whole devices.
+var GetDiskStats = func() []DiskStats {
+	buf, err := ioutil.ReadFile(ProcDiskStats)
+	if err != nil {
+		return nil
+	}
+	disks := []DiskStats{}
+	for _, line := range strings.Split(string(buf), "\n") {
+		// major minor name reads merged sectors ms writes merged sectors ...
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			continue
+		}
+		name := fields[2]
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join("/sys/block", name)); err != nil {
+			continue
+		}
+		var counters [4]uint64
+		for i, field := range []int{3, 5, 7, 9} {
+			counters[i], _ = strconv.ParseUint(fields[field], 10, 64)
+		}
+		disks = append(disks, DiskStats{
+			Device:       name,
+			Reads:        counters[0],
+			ReadBytes:    counters[1] * sectorSize,
+			Writes:       counters[2],
+			WrittenBytes: counters[3] * sectorSize,
+		})
+	}
+	return disks
+}
+
+// GetFilesystems returns the capacity and usage of the filesystems of the
+// block devices mounted on the host, once per device.
+var GetFilesystems = func() []Filesystem {
+	buf, err := ioutil.ReadFile(ProcMounts)
+	if err != nil {
+		return nil
+	}
+	filesystems := []Filesystem{}
+	devices := map[string]struct{}{}
+	for _, line := range strings.Split(string(buf), "\n") {
+		// device mountpoint type options dump pass
+		fields := strings.Fields(line)
+		if len(fields) < 3 || !strings.HasPrefix(fields[0], "/dev/") {
+			continue
+		}
+		if _, ok := devices[fields[0]]; ok {
+			continue // bind-mounted again
+		}
+		var stat unix.Statfs_t
+		if err := unix.Statfs(filepath.Join(HostRoot, fields[1]), &stat); err != nil {
+			continue
+		}
+		devices[fields[0]] = struct{}{}
+		filesystems = append(filesystems, Filesystem{
+			Mountpoint: fields[1],
+			Device:     fields[0],
+			Type:       fields[2],
+			Size:       stat.Blocks * uint64(stat.Bsize),
+			Used:       (stat.Blocks - stat.Bfree) * uint64(stat.Bsize),
+		})
+	}
+	return filesystems
+}
@@ -0,0 +1,166 @@
+package host
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/weaveworks/scope/report"
+)
+
+var (
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+	ntdll    = syscall.NewLazyDLL("ntdll.dll")
+
+	procGetTickCount64         = kernel32.NewProc("GetTickCount64")
+	procGetSystemTimes         = kernel32.NewProc("GetSystemTimes")
+	procGlobalMemoryStatusEx   = kernel32.NewProc("GlobalMemoryStatusEx")
+	procGetLogicalDriveStrings = kernel32.NewProc("GetLogicalDriveStringsW")
+	procGetDriveType           = kernel32.NewProc("GetDriveTypeW")
+	procGetDiskFreeSpaceEx     = kernel32.NewProc("GetDiskFreeSpaceExW")
+	procGetVolumeInformation   = kernel32.NewProc("GetVolumeInformationW")
+	procRtlGetVersion          = ntdll.NewProc("RtlGetVersion")
+)
+
+const driveFixed = 3 // DRIVE_FIXED, of GetDriveType
+
+// osVersionInfo is RTL_OSVERSIONINFOW.
+type osVersionInfo struct {
+	size         uint32
+	majorVersion uint32
+	minorVersion uint32
+	buildNumber  uint32
+	platformID   uint32
+	csdVersion   [128]uint16
+}
+
+// memoryStatus is MEMORYSTATUSEX.
+type memoryStatus struct {
+	length               uint32
+	memoryLoad           uint32
+	totalPhys            uint64
+	availPhys            uint64
+	totalPageFile        uint64
+	availPageFile        uint64
+	totalVirtual         uint64
+	availVirtual         uint64
+	availExtendedVirtual uint64
+}
+
+// GetKernelReleaseAndVersion returns the version of Windows, as
+// major.minor.build, which is what it has for a kernel release.
+var GetKernelReleaseAndVersion = func() (string, string, error) {
+	info := osVersionInfo{size: uint32(unsafe.Sizeof(osVersionInfo{}))}
+	if status, _, _ := procRtlGetVersion.Call(uintptr(unsafe.Pointer(&info))); status != 0 {
+		return "unknown", "unknown", fmt.Errorf("RtlGetVersion: status %#x", status)
+	}
+	release := fmt.Sprintf("%d.%d.%d", info.majorVersion, info.minorVersion, info.buildNumber)
+	// e.g. "Windows Service Pack 1", on old versions
+	return release, strings.TrimSpace("Windows " + syscall.UTF16ToString(info.csdVersion[:])), nil
+}
+
+// GetLoad returns no metrics: Windows has no load averages.
+var GetLoad = func(now time.Time) report.Metrics {
+	return nil
+}
+
+// GetUptime returns the uptime of the host.
+var GetUptime = func() (time.Duration, error) {
+	ms, _, _ := procGetTickCount64.Call()
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+var previousIdle, previousTotal uint64
+
+// GetCPUUsagePercent returns the percent cpu usage and max (i.e. 100% or 0 if unavailable)
+var GetCPUUsagePercent = func() (float64, float64) {
+	var idle, kernel, user syscall.Filetime
+	if ok, _, _ := procGetSystemTimes.Call(
+		uintptr(unsafe.Pointer(&idle)),
+		uintptr(unsafe.Pointer(&kernel)),
+		uintptr(unsafe.Pointer(&user)),
+	); ok == 0 {
+		return 0.0, 0.0
+	}
+
+	// The kernel time includes the idle time.
+	var (
+		currentIdle  = filetime(idle)
+		currentTotal = filetime(kernel) + filetime(user)
+		totald       = currentTotal - previousTotal
+		idled        = currentIdle - previousIdle
+	)
+	previousIdle, previousTotal = currentIdle, currentTotal
+	if totald == 0 {
+		return 0.0, 100.
+	}
+	return float64(totald-idled) * 100. / float64(totald), 100.
+}
+
+func filetime(t syscall.Filetime) uint64 {
+	return uint64(t.HighDateTime)<<32 | uint64(t.LowDateTime)
+}
+
+// GetMemoryUsageBytes returns the bytes memory usage and max
+var GetMemoryUsageBytes = func() (float64, float64) {
+	status := memoryStatus{length: uint32(unsafe.Sizeof(memoryStatus{}))}
+	if ok, _, _ := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&status))); ok == 0 {
+		return 0.0, 0.0
+	}
+	return float64(status.totalPhys - status.availPhys), float64(status.totalPhys)
+}
+
+// GetDiskStats returns no I/O counters: on Windows, they are performance
+// counters, which aren't read yet.
+var GetDiskStats = func() []DiskStats {
+	return nil
+}
+
+// GetFilesystems returns the capacity and usage of the filesystems of the
+// fixed drives of the host.
+var GetFilesystems = func() []Filesystem {
+	var buf [256]uint16
+	n, _, _ := procGetLogicalDriveStrings.Call(uintptr(len(buf)), uintptr(unsafe.Pointer(&buf[0])))
+	if n == 0 || n > uintptr(len(buf)) {
+		return nil
+	}
+	filesystems := []Filesystem{}
+	// NUL separated root paths, e.g. C:\
+	for start, i := 0, 0; i < int(n); i++ {
+		if buf[i] != 0 {
+			continue
+		}
+		root := buf[start : i+1]
+		start = i + 1
+		if kind, _, _ := procGetDriveType.Call(uintptr(unsafe.Pointer(&root[0]))); kind != driveFixed {
+			continue
+		}
+		var size, free uint64
+		if ok, _, _ := procGetDiskFreeSpaceEx.Call(
+			uintptr(unsafe.Pointer(&root[0])),
+			0,
+			uintptr(unsafe.Pointer(&size)),
+			uintptr(unsafe.Pointer(&free)),
+		); ok == 0 {
+			continue
+		}
+		var fsType [32]uint16
+		procGetVolumeInformation.Call(
+			uintptr(unsafe.Pointer(&root[0])),
+			0, 0, 0, 0, 0,
+			uintptr(unsafe.Pointer(&fsType[0])),
+			uintptr(len(fsType)),
+		)
+		mountpoint := syscall.UTF16ToString(root)
+		filesystems = append(filesystems, Filesystem{
+			Mountpoint: mountpoint,
+			Device:     mountpoint[:len(mountpoint)-1],
+			Type:       syscall.UTF16ToString(fsType[:]),
+			Size:       size,
+			Used:       size - free,
+		})
+	}
+	return filesystems
+}
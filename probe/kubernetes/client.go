@@ -3,6 +3,7 @@ package kubernetes
 import (
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,16 +11,20 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	apiappsv1beta1 "k8s.io/api/apps/v1beta1"
+	apiautoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
 	apibatchv1 "k8s.io/api/batch/v1"
 	apibatchv1beta1 "k8s.io/api/batch/v1beta1"
 	apibatchv2alpha1 "k8s.io/api/batch/v2alpha1"
 	apiv1 "k8s.io/api/core/v1"
 	apiextensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	apinetworkingv1 "k8s.io/api/networking/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -36,14 +41,22 @@ type Client interface {
 	WalkDaemonSets(f func(DaemonSet) error) error
 	WalkStatefulSets(f func(StatefulSet) error) error
 	WalkCronJobs(f func(CronJob) error) error
+	WalkJobs(f func(Job) error) error
 	WalkNamespaces(f func(NamespaceResource) error) error
+	WalkNetworkPolicies(f func(NetworkPolicy) error) error
+	WalkCustomResources(f func(CustomResource) error) error
+	WalkIngresses(f func(Ingress) error) error
+	WalkEvents(f func(EventResource) error) error
+	WalkHorizontalPodAutoscalers(f func(HorizontalPodAutoscaler) error) error
 
 	WatchPods(f func(Event, Pod))
 
-	GetLogs(namespaceID, podID string, containerNames []string) (io.ReadCloser, error)
+	GetLogs(namespaceID, podID string, containerNames []string, opts LogOptions) (io.ReadCloser, error)
 	DeletePod(namespaceID, podID string) error
 	ScaleUp(resource, namespaceID, id string) error
 	ScaleDown(resource, namespaceID, id string) error
+	ScaleTo(resource, namespaceID, id string, replicas int32) error
+	AdjustAutoscaler(namespaceID, id string, minDelta, maxDelta int32) error
 }
 
 type client struct {
@@ -59,6 +72,12 @@ type client struct {
 	cronJobStore     cache.Store
 	nodeStore        cache.Store
 	namespaceStore   cache.Store
+	policyStore      cache.Store
+	ingressStore     cache.Store
+	eventStore       cache.Store
+	autoscalerStore  cache.Store
+
+	customResourceStores []cache.Store
 
 	podWatchesMutex sync.Mutex
 	podWatches      []func(Event, Pod)
@@ -79,10 +98,18 @@ type ClientConfig struct {
 	Token                string
 	User                 string
 	Username             string
+	// CustomResources is a comma-separated list of the custom resource
+	// kinds to report, as <group>/<version>/<kind>.
+	CustomResources string
 }
 
 // NewClient returns a usable Client. Don't forget to Stop it.
 func NewClient(config ClientConfig) (Client, error) {
+	customResourceKinds, err := ParseCustomResourceKinds(config.CustomResources)
+	if err != nil {
+		return nil, err
+	}
+
 	var restConfig *rest.Config
 	if config.Server == "" && config.Kubeconfig == "" {
 		// If no API server address or kubeconfig was provided, assume we are running
@@ -145,6 +172,17 @@ func NewClient(config ClientConfig) (Client, error) {
 	result.jobStore = result.setupStore("jobs")
 	result.statefulSetStore = result.setupStore("statefulsets")
 	result.cronJobStore = result.setupStore("cronjobs")
+	result.policyStore = result.setupStore("networkpolicies")
+	result.ingressStore = result.setupStore("ingresses")
+	result.eventStore = result.setupStore("events")
+	result.autoscalerStore = result.setupStore("horizontalpodautoscalers")
+
+	if len(customResourceKinds) > 0 {
+		pool := dynamic.NewDynamicClientPool(restConfig)
+		for _, gvk := range customResourceKinds {
+			result.customResourceStores = append(result.customResourceStores, result.setupCustomResourceStore(pool, gvk))
+		}
+	}
 
 	return result, nil
 }
@@ -202,29 +240,81 @@ func (c *client) clientAndType(resource string) (rest.Interface, interface{}, er
 		}
 		// kubernetes < 1.8
 		return c.client.BatchV2alpha1().RESTClient(), &apibatchv2alpha1.CronJob{}, nil
+	case "events":
+		return c.client.CoreV1().RESTClient(), &apiv1.Event{}, nil
+	case "horizontalpodautoscalers":
+		return c.client.AutoscalingV2beta1().RESTClient(), &apiautoscalingv2beta1.HorizontalPodAutoscaler{}, nil
+	case "ingresses":
+		return c.client.ExtensionsV1beta1().RESTClient(), &apiextensionsv1beta1.Ingress{}, nil
+	case "networkpolicies":
+		return c.client.NetworkingV1().RESTClient(), &apinetworkingv1.NetworkPolicy{}, nil
 	}
 	return nil, nil, fmt.Errorf("Invalid resource: %v", resource)
 }
 
+// setupCustomResourceStore watches the custom resources of a kind, once
+// the server knows of it.
+func (c *client) setupCustomResourceStore(pool dynamic.ClientPool, gvk schema.GroupVersionKind) cache.Store {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	c.runListWatchUntil(gvk.String(), store, func() (cache.ListerWatcher, interface{}, error) {
+		resourceList, err := c.client.Discovery().ServerResourcesForGroupVersion(gvk.GroupVersion().String())
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, nil, err
+		}
+		if err == nil {
+			for _, resource := range resourceList.APIResources {
+				// Skip subresources, such as <resource>/status
+				if resource.Kind != gvk.Kind || strings.Contains(resource.Name, "/") {
+					continue
+				}
+				dclient, err := pool.ClientForGroupVersionKind(gvk)
+				if err != nil {
+					return nil, nil, err
+				}
+				rclient := dclient.Resource(&resource, metav1.NamespaceAll)
+				return &cache.ListWatch{ListFunc: rclient.List, WatchFunc: rclient.Watch}, &unstructured.Unstructured{}, nil
+			}
+		}
+		log.Infof("%v are not known to this Kubernetes cluster", gvk)
+		return nil, nil, nil
+	})
+	return store
+}
+
 // runReflectorUntil runs cache.Reflector#ListAndWatch in an endless loop, after checking that the resource is supported by kubernetes.
 // Errors are logged and retried with exponential backoff.
 func (c *client) runReflectorUntil(resource string, store cache.Store) {
+	c.runListWatchUntil(resource, store, func() (cache.ListerWatcher, interface{}, error) {
+		kclient, itemType, err := c.clientAndType(resource)
+		if err != nil {
+			return nil, nil, err
+		}
+		ok, err := c.isResourceSupported(kclient.APIVersion(), resource)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			log.Infof("%v are not supported by this Kubernetes version", resource)
+			return nil, nil, nil
+		}
+		return cache.NewListWatchFromClient(kclient, resource, metav1.NamespaceAll, fields.Everything()), itemType, nil
+	})
+}
+
+// runListWatchUntil runs cache.Reflector#ListAndWatch in an endless loop,
+// with the ListerWatcher newListWatch returns, none meaning there is nothing
+// to watch. Errors are logged and retried with exponential backoff.
+func (c *client) runListWatchUntil(name string, store cache.Store, newListWatch func() (cache.ListerWatcher, interface{}, error)) {
 	var r *cache.Reflector
 	listAndWatch := func() (bool, error) {
 		if r == nil {
-			kclient, itemType, err := c.clientAndType(resource)
+			lw, itemType, err := newListWatch()
 			if err != nil {
 				return false, err
 			}
-			ok, err := c.isResourceSupported(kclient.APIVersion(), resource)
-			if err != nil {
-				return false, err
-			}
-			if !ok {
-				log.Infof("%v are not supported by this Kubernetes version", resource)
+			if lw == nil {
 				return true, nil
 			}
-			lw := cache.NewListWatchFromClient(kclient, resource, metav1.NamespaceAll, fields.Everything())
 			r = cache.NewReflector(lw, itemType, store, c.resyncPeriod)
 		}
 
@@ -236,7 +326,7 @@ func (c *client) runReflectorUntil(resource string, store cache.Store) {
 			return false, err
 		}
 	}
-	bo := backoff.New(listAndWatch, fmt.Sprintf("Kubernetes reflector (%s)", resource))
+	bo := backoff.New(listAndWatch, fmt.Sprintf("Kubernetes reflector (%s)", name))
 	bo.SetMaxBackoff(5 * time.Minute)
 	go bo.Start()
 }
@@ -335,6 +425,17 @@ func (c *client) WalkCronJobs(f func(CronJob) error) error {
 	return nil
 }
 
+// WalkJobs calls f for each job
+func (c *client) WalkJobs(f func(Job) error) error {
+	for _, m := range c.jobStore.List() {
+		j := m.(*apibatchv1.Job)
+		if err := f(NewJob(j)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *client) WalkNamespaces(f func(NamespaceResource) error) error {
 	for _, m := range c.namespaceStore.List() {
 		namespace := m.(*apiv1.Namespace)
@@ -345,17 +446,85 @@ func (c *client) WalkNamespaces(f func(NamespaceResource) error) error {
 	return nil
 }
 
-func (c *client) GetLogs(namespaceID, podID string, containerNames []string) (io.ReadCloser, error) {
+// WalkNetworkPolicies calls f for each network policy
+func (c *client) WalkNetworkPolicies(f func(NetworkPolicy) error) error {
+	for _, m := range c.policyStore.List() {
+		policy := m.(*apinetworkingv1.NetworkPolicy)
+		if err := f(NewNetworkPolicy(policy)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkIngresses calls f for each ingress
+func (c *client) WalkIngresses(f func(Ingress) error) error {
+	for _, m := range c.ingressStore.List() {
+		i := m.(*apiextensionsv1beta1.Ingress)
+		if err := f(NewIngress(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkEvents calls f for each event
+func (c *client) WalkEvents(f func(EventResource) error) error {
+	for _, m := range c.eventStore.List() {
+		e := m.(*apiv1.Event)
+		if err := f(NewEvent(e)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkHorizontalPodAutoscalers calls f for each horizontal pod autoscaler
+func (c *client) WalkHorizontalPodAutoscalers(f func(HorizontalPodAutoscaler) error) error {
+	for _, m := range c.autoscalerStore.List() {
+		h := m.(*apiautoscalingv2beta1.HorizontalPodAutoscaler)
+		if err := f(NewHorizontalPodAutoscaler(h)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkCustomResources calls f for each custom resource of the kinds watched
+func (c *client) WalkCustomResources(f func(CustomResource) error) error {
+	for _, store := range c.customResourceStores {
+		for _, m := range store.List() {
+			u := m.(*unstructured.Unstructured)
+			if err := f(NewCustomResource(u)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LogOptions are which logs of the containers of a pod to stream
+type LogOptions struct {
+	TailLines    int64 // of each container, all if 0
+	SinceSeconds int64 // all if 0
+	Timestamps   bool
+}
+
+func (c *client) GetLogs(namespaceID, podID string, containerNames []string, opts LogOptions) (io.ReadCloser, error) {
 	readClosersWithLabel := map[io.ReadCloser]string{}
 	for _, container := range containerNames {
-		req := c.client.CoreV1().Pods(namespaceID).GetLogs(
-			podID,
-			&apiv1.PodLogOptions{
-				Follow:     true,
-				Timestamps: true,
-				Container:  container,
-			},
-		)
+		logOptions := &apiv1.PodLogOptions{
+			Follow:     true,
+			Timestamps: opts.Timestamps,
+			Container:  container,
+		}
+		if opts.TailLines > 0 {
+			logOptions.TailLines = &opts.TailLines
+		}
+		if opts.SinceSeconds > 0 {
+			logOptions.SinceSeconds = &opts.SinceSeconds
+		}
+		req := c.client.CoreV1().Pods(namespaceID).GetLogs(podID, logOptions)
 		readCloser, err := req.Stream()
 		if err != nil {
 			for rc := range readClosersWithLabel {
@@ -385,6 +554,30 @@ func (c *client) ScaleDown(resource, namespaceID, id string) error {
 	})
 }
 
+func (c *client) ScaleTo(resource, namespaceID, id string, replicas int32) error {
+	return c.modifyScale(resource, namespaceID, id, func(scale *apiextensionsv1beta1.Scale) {
+		scale.Spec.Replicas = replicas
+	})
+}
+
+// AdjustAutoscaler changes the minimum and maximum replicas of a horizontal
+// pod autoscaler, keeping the minimum at least 1 and at most the maximum.
+func (c *client) AdjustAutoscaler(namespaceID, id string, minDelta, maxDelta int32) error {
+	autoscalers := c.client.AutoscalingV2beta1().HorizontalPodAutoscalers(namespaceID)
+	autoscaler, err := autoscalers.Get(id, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	min := NewHorizontalPodAutoscaler(autoscaler).MinReplicas() + minDelta
+	max := autoscaler.Spec.MaxReplicas + maxDelta
+	if min < 1 || min > max {
+		return fmt.Errorf("Invalid replicas: min %d, max %d", min, max)
+	}
+	autoscaler.Spec.MinReplicas, autoscaler.Spec.MaxReplicas = &min, max
+	_, err = autoscalers.Update(autoscaler)
+	return err
+}
+
 func (c *client) modifyScale(resource, namespace, id string, f func(*apiextensionsv1beta1.Scale)) error {
 	scaler := c.client.Extensions().Scales(namespace)
 	scale, err := scaler.Get(resource, id)
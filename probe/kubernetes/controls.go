@@ -1,8 +1,12 @@
 package kubernetes
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/probe/controls"
@@ -13,16 +17,72 @@ import (
 const (
 	GetLogs   = report.KubernetesGetLogs
 	DeletePod = report.KubernetesDeletePod
-	ScaleUp   = report.KubernetesScaleUp
-	ScaleDown = report.KubernetesScaleDown
+	// DeletePodDryRun describes what DeletePod would do
+	DeletePodDryRun = "kubernetes_delete_pod_dry_run"
+	ForwardPort     = "kubernetes_port_forward"
+	ScaleUp         = report.KubernetesScaleUp
+	ScaleDown       = report.KubernetesScaleDown
+	ScaleTo         = "kubernetes_scale_to"
+
+	// Parameters of GetLogs
+	GetLogsContainers = "containers"
+	GetLogsTail       = "tail"
+	GetLogsSince      = "since"
+	GetLogsTimestamps = "timestamps"
+	GetLogsFilter     = "filter"
+
+	// GetLogsAllContainers and GetLogsNoFilter are the defaults of the
+	// containers and filter of GetLogs
+	GetLogsAllContainers = "all"
+	GetLogsNoFilter      = ".*"
+
+	// ScaleToReplicas is the parameter of ScaleTo
+	ScaleToReplicas = "replicas"
+
+	AutoscalerMinUp   = report.KubernetesAutoscalerMinUp
+	AutoscalerMinDown = report.KubernetesAutoscalerMinDown
+	AutoscalerMaxUp   = report.KubernetesAutoscalerMaxUp
+	AutoscalerMaxDown = report.KubernetesAutoscalerMaxDown
 )
 
-// GetLogs is the control to get the logs for a kubernetes pod
+// GetLogs is the control to get the logs for a kubernetes pod, of the
+// containers, lines, and since the time of its arguments, filtered by its
+// regular expression. Lines of pods of many containers are filtered with
+// the labels of their containers.
 func (r *Reporter) GetLogs(req xfer.Request, namespaceID, podID string, containerNames []string) xfer.Response {
-	readCloser, err := r.client.GetLogs(namespaceID, podID, containerNames)
+	args, err := GetLogsControl.Args(req.ControlArgs)
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+	if containers := args[GetLogsContainers]; containers != GetLogsAllContainers {
+		containerNames, err = selectContainers(containerNames, strings.Split(containers, ","))
+		if err != nil {
+			return xfer.ResponseError(err)
+		}
+	}
+	tail, _ := strconv.ParseInt(args[GetLogsTail], 10, 64)
+	since, _ := strconv.ParseInt(args[GetLogsSince], 10, 64)
+	if tail < 0 || since < 0 {
+		return xfer.ResponseErrorf("Invalid tail or since: %d, %d", tail, since)
+	}
+	var filter *regexp.Regexp
+	if f := args[GetLogsFilter]; f != GetLogsNoFilter && f != "" {
+		if filter, err = regexp.Compile(f); err != nil {
+			return xfer.ResponseErrorf("Invalid filter: %v", err)
+		}
+	}
+
+	readCloser, err := r.client.GetLogs(namespaceID, podID, containerNames, LogOptions{
+		TailLines:    tail,
+		SinceSeconds: since,
+		Timestamps:   args[GetLogsTimestamps] == "true",
+	})
 	if err != nil {
 		return xfer.ResponseError(err)
 	}
+	if filter != nil {
+		readCloser = NewFilteredReadCloser(readCloser, filter)
+	}
 
 	readWriter := struct {
 		io.Reader
@@ -43,6 +103,23 @@ func (r *Reporter) GetLogs(req xfer.Request, namespaceID, podID string, containe
 	}
 }
 
+// selectContainers returns the containers of a pod selected by name.
+func selectContainers(containerNames, selected []string) ([]string, error) {
+	result := []string{}
+	for _, name := range selected {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, c := range containerNames {
+			found = found || c == name
+		}
+		if !found {
+			return nil, fmt.Errorf("Container not in pod: %q", name)
+		}
+		result = append(result, name)
+	}
+	return result, nil
+}
+
 func (r *Reporter) deletePod(req xfer.Request, namespaceID, podID string, _ []string) xfer.Response {
 	if err := r.client.DeletePod(namespaceID, podID); err != nil {
 		return xfer.ResponseError(err)
@@ -52,6 +129,26 @@ func (r *Reporter) deletePod(req xfer.Request, namespaceID, podID string, _ []st
 	}
 }
 
+func (r *Reporter) deletePodDryRun(req xfer.Request, namespaceID, podID string, containerNames []string) xfer.Response {
+	return xfer.Response{
+		Value: fmt.Sprintf("Would delete pod %s/%s, stopping its containers %s", namespaceID, podID, strings.Join(containerNames, ", ")),
+	}
+}
+
+func (r *Reporter) forwardPort(req xfer.Request, namespaceID, podID string, _ []string) xfer.Response {
+	var ip string
+	r.client.WalkPods(func(p Pod) error {
+		if p.Namespace() == namespaceID && p.Name() == podID {
+			ip = p.IP()
+		}
+		return nil
+	})
+	if ip == "" {
+		return xfer.ResponseErrorf("Pod %s/%s has no IP address", namespaceID, podID)
+	}
+	return controls.ForwardPort(r.pipes, req, ip)
+}
+
 // CapturePod is exported for testing
 func (r *Reporter) CapturePod(f func(xfer.Request, string, string, []string) xfer.Response) func(xfer.Request) xfer.Response {
 	return func(req xfer.Request) xfer.Response {
@@ -105,12 +202,53 @@ func (r *Reporter) ScaleDown(req xfer.Request, namespace, id string) xfer.Respon
 	return xfer.ResponseError(r.client.ScaleDown(report.Deployment, namespace, id))
 }
 
+// ScaleTo is the control to scale a deployment to the replicas it is
+// given
+func (r *Reporter) ScaleTo(req xfer.Request, namespace, id string) xfer.Response {
+	args, err := ScaleToControl.Args(req.ControlArgs)
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+	replicas, _ := strconv.Atoi(args[ScaleToReplicas])
+	if replicas < 0 {
+		return xfer.ResponseErrorf("Invalid replicas: %d", replicas)
+	}
+	return xfer.ResponseError(r.client.ScaleTo(report.Deployment, namespace, id, int32(replicas)))
+}
+
+// AdjustAutoscaler returns the control to change the minimum and maximum
+// replicas of the horizontal pod autoscaler of a deployment by the given
+// amounts
+func (r *Reporter) AdjustAutoscaler(minDelta, maxDelta int32) func(xfer.Request, string, string) xfer.Response {
+	return func(req xfer.Request, namespace, id string) xfer.Response {
+		var autoscaler HorizontalPodAutoscaler
+		r.client.WalkHorizontalPodAutoscalers(func(h HorizontalPodAutoscaler) error {
+			if h.Namespace() == namespace && h.Scales("Deployment", id) {
+				autoscaler = h
+			}
+			return nil
+		})
+		if autoscaler == nil {
+			return xfer.ResponseErrorf("Horizontal pod autoscaler not found for deployment: %s", id)
+		}
+		return xfer.ResponseError(r.client.AdjustAutoscaler(namespace, autoscaler.Name(), minDelta, maxDelta))
+	}
+}
+
 func (r *Reporter) registerControls() {
 	controls := map[string]xfer.ControlHandlerFunc{
-		GetLogs:   r.CapturePod(r.GetLogs),
-		DeletePod: r.CapturePod(r.deletePod),
-		ScaleUp:   r.CaptureDeployment(r.ScaleUp),
-		ScaleDown: r.CaptureDeployment(r.ScaleDown),
+		GetLogs:         r.CapturePod(r.GetLogs),
+		DeletePod:       r.CapturePod(r.deletePod),
+		DeletePodDryRun: r.CapturePod(r.deletePodDryRun),
+		ForwardPort:     r.CapturePod(r.forwardPort),
+		ScaleUp:         r.CaptureDeployment(r.ScaleUp),
+		ScaleDown:       r.CaptureDeployment(r.ScaleDown),
+		ScaleTo:         r.CaptureDeployment(r.ScaleTo),
+
+		AutoscalerMinUp:   r.CaptureDeployment(r.AdjustAutoscaler(1, 0)),
+		AutoscalerMinDown: r.CaptureDeployment(r.AdjustAutoscaler(-1, 0)),
+		AutoscalerMaxUp:   r.CaptureDeployment(r.AdjustAutoscaler(0, 1)),
+		AutoscalerMaxDown: r.CaptureDeployment(r.AdjustAutoscaler(0, -1)),
 	}
 	r.handlerRegistry.Batch(nil, controls)
 }
@@ -119,8 +257,15 @@ func (r *Reporter) deregisterControls() {
 	controls := []string{
 		GetLogs,
 		DeletePod,
+		DeletePodDryRun,
+		ForwardPort,
 		ScaleUp,
 		ScaleDown,
+		ScaleTo,
+		AutoscalerMinUp,
+		AutoscalerMinDown,
+		AutoscalerMaxUp,
+		AutoscalerMaxDown,
 	}
 	r.handlerRegistry.Batch(controls, nil)
 }
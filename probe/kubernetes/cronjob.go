@@ -36,7 +36,8 @@ type cronJob struct {
 }
 
 // NewCronJob creates a new cron job. jobs should be all jobs, which will be filtered
-// for those matching this cron job.
+// for those matching this cron job: the active ones, and the finished ones it
+// still owns, so that their pods aren't left without a controller.
 func NewCronJob(cji interface{}, jobs map[types.UID]*batchv1.Job) CronJob {
 	switch cj := cji.(type) {
 	case *batchv2alpha1.CronJob:
@@ -55,6 +56,11 @@ func newCronJob(cj *batchv1beta1.CronJob, jobs map[types.UID]*batchv1.Job) CronJ
 			myJobs = append(myJobs, j)
 		}
 	}
+	for _, j := range jobs {
+		if j.Status.Active == 0 && ownedBy(j.OwnerReferences, cj.UID) {
+			myJobs = append(myJobs, j)
+		}
+	}
 	return &cronJob{
 		CronJob: cj,
 		Meta:    meta{cj.ObjectMeta},
@@ -79,7 +85,7 @@ func (cj *cronJob) GetNode() report.Node {
 		NodeType:   "CronJob",
 		Schedule:   cj.Spec.Schedule,
 		Suspended:  fmt.Sprint(cj.Spec.Suspend != nil && *cj.Spec.Suspend), // nil -> false
-		ActiveJobs: fmt.Sprint(len(cj.jobs)),
+		ActiveJobs: fmt.Sprint(len(cj.Status.Active)),
 	}
 	if cj.Status.LastScheduleTime != nil {
 		latest[LastScheduled] = cj.Status.LastScheduleTime.Format(time.RFC3339Nano)
@@ -87,6 +93,15 @@ func (cj *cronJob) GetNode() report.Node {
 	return cj.MetaNode(report.MakeCronJobNodeID(cj.UID())).WithLatests(latest)
 }
 
+func ownedBy(owners []metav1.OwnerReference, uid types.UID) bool {
+	for _, owner := range owners {
+		if owner.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
 func upgradeCronJob(legacy *batchv2alpha1.CronJob) *batchv1beta1.CronJob {
 	jobTemplate := batchv1beta1.JobTemplateSpec{
 		ObjectMeta: legacy.Spec.JobTemplate.ObjectMeta,
@@ -0,0 +1,104 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// These constants are keys used in node metadata
+const (
+	APIVersion = report.KubernetesAPIVersion
+)
+
+// CustomResource represents a Kubernetes custom resource
+type CustomResource interface {
+	Meta
+	Kind() string
+	APIVersion() string
+	GetNode(customResources map[string]struct{}) report.Node
+}
+
+type customResource struct {
+	kind, apiVersion string
+	Meta
+}
+
+// NewCustomResource creates a new CustomResource
+func NewCustomResource(u *unstructured.Unstructured) CustomResource {
+	return &customResource{
+		kind:       u.GetKind(),
+		apiVersion: u.GetAPIVersion(),
+		Meta: meta{metav1.ObjectMeta{
+			Name:              u.GetName(),
+			Namespace:         u.GetNamespace(),
+			UID:               u.GetUID(),
+			CreationTimestamp: u.GetCreationTimestamp(),
+			Labels:            u.GetLabels(),
+			OwnerReferences:   u.GetOwnerReferences(),
+		}},
+	}
+}
+
+func (c *customResource) Kind() string {
+	return c.kind
+}
+
+func (c *customResource) APIVersion() string {
+	return c.apiVersion
+}
+
+// GetNode gets the node of a custom resource, with the built-in objects and
+// the custom resources, by UID, owning it as parents.
+func (c *customResource) GetNode(customResources map[string]struct{}) report.Node {
+	parents := customResourceParents(c, customResources)
+	for _, owner := range c.OwnerReferences() {
+		if t, ok := kindTopologies[owner.Kind]; ok {
+			parents = parents.Add(t.topology, report.MakeStringSet(t.makeID(string(owner.UID))))
+		}
+	}
+	return c.MetaNode(report.MakeCustomResourceNodeID(c.UID())).WithLatests(map[string]string{
+		NodeType:   c.Kind(),
+		APIVersion: c.APIVersion(),
+	}).WithParents(parents)
+}
+
+// ParseCustomResourceKinds parses a comma-separated list of custom resource
+// kinds, each given as its API version and kind, e.g.
+// kafka.strimzi.io/v1beta1/Kafka.
+func ParseCustomResourceKinds(s string) ([]schema.GroupVersionKind, error) {
+	result := []schema.GroupVersionKind{}
+	for _, kind := range strings.Split(s, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		i := strings.LastIndex(kind, "/")
+		if i <= 0 || i == len(kind)-1 {
+			return nil, fmt.Errorf("invalid custom resource kind %q, expected <group>/<version>/<kind>", kind)
+		}
+		gv, err := schema.ParseGroupVersion(kind[:i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid custom resource kind %q: %v", kind, err)
+		}
+		result = append(result, gv.WithKind(kind[i+1:]))
+	}
+	return result, nil
+}
+
+// customResourceParents returns the custom resources amongst the owners of
+// an object, as parents.
+func customResourceParents(m Meta, customResources map[string]struct{}) report.Sets {
+	parents := report.MakeSets()
+	for _, owner := range m.OwnerReferences() {
+		if _, ok := customResources[string(owner.UID)]; ok {
+			parents = parents.Add(report.CustomResource, report.MakeStringSet(report.MakeCustomResourceNodeID(string(owner.UID))))
+		}
+	}
+	return parents
+}
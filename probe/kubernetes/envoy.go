@@ -0,0 +1,169 @@
+package kubernetes
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// These constants are keys used in node metadata
+const (
+	Sidecar = report.KubernetesSidecar
+
+	MeshPrefix = "kubernetes_mesh_"
+
+	MeshDirection   = "direction"
+	MeshService     = "service"
+	MeshPort        = "port"
+	MeshRequests    = "requests"
+	MeshConnections = "connections"
+	MeshMTLS        = "mtls"
+
+	MeshInbound  = "inbound"
+	MeshOutbound = "outbound"
+)
+
+// EnvoySidecar is the name of the container Istio injects its Envoy proxy
+// into pods as.
+const EnvoySidecar = "istio-proxy"
+
+// envoyStatsPort is the port the Envoy sidecars serve their stats, in the
+// Prometheus format, on.
+const envoyStatsPort = 15090
+
+// GetEnvoyStats obtains the stats of the Envoy sidecar of the pod with an IP
+// (it's just exported for testing)
+var GetEnvoyStats = func(podIP string) (io.ReadCloser, error) {
+	client := http.Client{Timeout: time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://%s:%d/stats/prometheus", podIP, envoyStatsPort))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("envoy stats: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// envoyMeshRows returns the rows of the mesh traffic table of a pod, from
+// the stats of its Envoy sidecar, none if they can't be obtained.
+func envoyMeshRows(p Pod) []report.Row {
+	stats, err := GetEnvoyStats(p.IP())
+	if err != nil {
+		log.Debugf("Cannot obtain the Envoy stats of pod %s: %v", p.Name(), err)
+		return nil
+	}
+	defer stats.Close()
+	rows, err := meshRows(stats)
+	if err != nil {
+		log.Warnf("Invalid Envoy stats of pod %s: %v", p.Name(), err)
+	}
+	return rows
+}
+
+// envoyCluster is the stats of an Envoy cluster, the traffic of a pod to or
+// from a port of a service.
+type envoyCluster struct {
+	direction, port, service string
+	requests, connections    float64
+	handshakes               float64
+}
+
+// meshRows returns the rows of the mesh traffic table of a pod, one for each
+// service it talks to, or is talked to on, through its Envoy sidecar. The
+// traffic to a service is mutually authenticated when the sidecar has made
+// TLS handshakes with it, and the traffic from services when the sidecar has
+// made any on its listeners.
+func meshRows(stats io.Reader) ([]report.Row, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(stats)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := map[string]*envoyCluster{}
+	cluster := func(m *dto.Metric) *envoyCluster {
+		name := metricLabel(m, "cluster_name")
+		// Istio names the clusters of services
+		// <direction>|<port>|<subset>|<host>
+		parts := strings.Split(name, "|")
+		if len(parts) != 4 || (parts[0] != MeshInbound && parts[0] != MeshOutbound) {
+			return nil
+		}
+		c, ok := clusters[name]
+		if !ok {
+			c = &envoyCluster{direction: parts[0], port: parts[1], service: parts[3]}
+			clusters[name] = c
+		}
+		return c
+	}
+	for metric, add := range map[string]func(*envoyCluster, float64){
+		"envoy_cluster_upstream_rq_total": func(c *envoyCluster, v float64) { c.requests += v },
+		"envoy_cluster_upstream_cx_total": func(c *envoyCluster, v float64) { c.connections += v },
+		"envoy_cluster_ssl_handshake":     func(c *envoyCluster, v float64) { c.handshakes += v },
+	} {
+		family, ok := families[metric]
+		if !ok {
+			continue
+		}
+		for _, m := range family.Metric {
+			if c := cluster(m); c != nil {
+				add(c, metricValue(m))
+			}
+		}
+	}
+	var inboundHandshakes float64
+	if family, ok := families["envoy_listener_ssl_handshake"]; ok {
+		for _, m := range family.Metric {
+			inboundHandshakes += metricValue(m)
+		}
+	}
+
+	rows := make([]report.Row, 0, len(clusters))
+	for name, c := range clusters {
+		mtls := c.handshakes > 0
+		if c.direction == MeshInbound {
+			mtls = inboundHandshakes > 0
+		}
+		rows = append(rows, report.Row{ID: name, Entries: map[string]string{
+			MeshDirection:   c.direction,
+			MeshService:     c.service,
+			MeshPort:        c.port,
+			MeshRequests:    strconv.FormatFloat(c.requests, 'f', 0, 64),
+			MeshConnections: strconv.FormatFloat(c.connections, 'f', 0, 64),
+			MeshMTLS:        strconv.FormatBool(mtls),
+		}})
+	}
+	return rows, nil
+}
+
+func metricLabel(m *dto.Metric, name string) string {
+	for _, l := range m.Label {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+func metricValue(m *dto.Metric) float64 {
+	switch {
+	case m.Counter != nil:
+		return m.Counter.GetValue()
+	case m.Gauge != nil:
+		return m.Gauge.GetValue()
+	case m.Untyped != nil:
+		return m.Untyped.GetValue()
+	}
+	return 0
+}
@@ -0,0 +1,84 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// These constants are keys used in node metadata
+const (
+	EventsPrefix = "kubernetes_events_"
+
+	EventType     = "type"
+	EventReason   = "reason"
+	EventMessage  = "message"
+	EventCount    = "count"
+	EventLastSeen = "last_seen"
+)
+
+// EventMaxAge is how long ago an event may have last happened for it to
+// still be reported.
+const EventMaxAge = time.Hour
+
+// EventResource represents a Kubernetes event
+// `Event` is already taken in store.go
+type EventResource interface {
+	Meta
+	InvolvedObject() apiv1.ObjectReference
+	LastSeen() time.Time
+	Row() report.Row
+}
+
+type event struct {
+	*apiv1.Event
+	Meta
+}
+
+// NewEvent creates a new EventResource
+func NewEvent(e *apiv1.Event) EventResource {
+	return &event{Event: e, Meta: meta{e.ObjectMeta}}
+}
+
+func (e *event) InvolvedObject() apiv1.ObjectReference {
+	return e.Event.InvolvedObject
+}
+
+func (e *event) LastSeen() time.Time {
+	if e.LastTimestamp.IsZero() {
+		return e.FirstTimestamp.Time
+	}
+	return e.LastTimestamp.Time
+}
+
+// Row returns the event as a row of the events table, the rows of which sort
+// by when they were last seen.
+func (e *event) Row() report.Row {
+	lastSeen := e.LastSeen().UTC().Format(time.RFC3339Nano)
+	return report.Row{
+		ID: lastSeen + "/" + e.Name(),
+		Entries: map[string]string{
+			EventType:     e.Type,
+			EventReason:   e.Reason,
+			EventMessage:  e.Message,
+			EventCount:    fmt.Sprint(e.Count),
+			EventLastSeen: lastSeen,
+		},
+	}
+}
+
+// eventRows returns the rows of the events table, most recent first.
+func eventRows(events []EventResource) []report.Row {
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].LastSeen().After(events[j].LastSeen())
+	})
+	rows := make([]report.Row, 0, len(events))
+	for _, e := range events {
+		rows = append(rows, e.Row())
+	}
+	return rows
+}
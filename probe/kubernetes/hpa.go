@@ -0,0 +1,115 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// These constants are keys used in node metadata
+const (
+	Autoscaler  = report.KubernetesAutoscaler
+	MinReplicas = report.KubernetesMinReplicas
+	MaxReplicas = report.KubernetesMaxReplicas
+
+	AutoscalerMetricsPrefix = "kubernetes_autoscaler_metrics_"
+
+	AutoscalerMetric        = "metric"
+	AutoscalerMetricCurrent = "current"
+	AutoscalerMetricTarget  = "target"
+)
+
+// HorizontalPodAutoscaler represents a Kubernetes horizontal pod autoscaler
+type HorizontalPodAutoscaler interface {
+	Meta
+	Scales(kind, name string) bool
+	MinReplicas() int32
+	MaxReplicas() int32
+	AddToNode(n report.Node) report.Node
+}
+
+type horizontalPodAutoscaler struct {
+	*autoscalingv2beta1.HorizontalPodAutoscaler
+	Meta
+}
+
+// NewHorizontalPodAutoscaler creates a new HorizontalPodAutoscaler
+func NewHorizontalPodAutoscaler(h *autoscalingv2beta1.HorizontalPodAutoscaler) HorizontalPodAutoscaler {
+	return &horizontalPodAutoscaler{HorizontalPodAutoscaler: h, Meta: meta{h.ObjectMeta}}
+}
+
+// Scales tells whether the autoscaler scales the object of the kind and name,
+// in its namespace.
+func (h *horizontalPodAutoscaler) Scales(kind, name string) bool {
+	return h.Spec.ScaleTargetRef.Kind == kind && h.Spec.ScaleTargetRef.Name == name
+}
+
+func (h *horizontalPodAutoscaler) MinReplicas() int32 {
+	// Spec.MinReplicas can be omitted, and the pointer will be nil. It defaults to 1.
+	if h.Spec.MinReplicas == nil {
+		return 1
+	}
+	return *h.Spec.MinReplicas
+}
+
+func (h *horizontalPodAutoscaler) MaxReplicas() int32 {
+	return h.Spec.MaxReplicas
+}
+
+// AddToNode adds the autoscaler, its bounds on the replicas and its metrics,
+// to the node of the object it scales, along with the controls to adjust
+// the bounds.
+func (h *horizontalPodAutoscaler) AddToNode(n report.Node) report.Node {
+	return n.WithLatests(map[string]string{
+		Autoscaler:  h.Name(),
+		MinReplicas: fmt.Sprint(h.MinReplicas()),
+		MaxReplicas: fmt.Sprint(h.MaxReplicas()),
+	}).AddPrefixMulticolumnTable(AutoscalerMetricsPrefix, h.metricRows()).
+		WithLatestActiveControls(AutoscalerMinDown, AutoscalerMinUp, AutoscalerMaxDown, AutoscalerMaxUp)
+}
+
+// metricRows returns a row for each metric the autoscaler targets, with its
+// current value as last observed.
+func (h *horizontalPodAutoscaler) metricRows() []report.Row {
+	current := map[string]string{}
+	for _, m := range h.Status.CurrentMetrics {
+		switch {
+		case m.Resource != nil && m.Resource.CurrentAverageUtilization != nil:
+			current[string(m.Resource.Name)] = fmt.Sprintf("%d%%", *m.Resource.CurrentAverageUtilization)
+		case m.Resource != nil:
+			current[string(m.Resource.Name)] = m.Resource.CurrentAverageValue.String()
+		case m.Pods != nil:
+			current[m.Pods.MetricName] = m.Pods.CurrentAverageValue.String()
+		case m.Object != nil:
+			current[m.Object.MetricName] = m.Object.CurrentValue.String()
+		}
+	}
+
+	rows := make([]report.Row, 0, len(h.Spec.Metrics))
+	for _, m := range h.Spec.Metrics {
+		var name, target string
+		switch {
+		case m.Resource != nil && m.Resource.TargetAverageUtilization != nil:
+			name, target = string(m.Resource.Name), fmt.Sprintf("%d%%", *m.Resource.TargetAverageUtilization)
+		case m.Resource != nil && m.Resource.TargetAverageValue != nil:
+			name, target = string(m.Resource.Name), m.Resource.TargetAverageValue.String()
+		case m.Pods != nil:
+			name, target = m.Pods.MetricName, m.Pods.TargetAverageValue.String()
+		case m.Object != nil:
+			name, target = m.Object.MetricName, m.Object.TargetValue.String()
+		default:
+			continue
+		}
+		entries := map[string]string{
+			AutoscalerMetric:       name,
+			AutoscalerMetricTarget: target,
+		}
+		if value, ok := current[name]; ok {
+			entries[AutoscalerMetricCurrent] = value
+		}
+		rows = append(rows, report.Row{ID: name, Entries: entries})
+	}
+	return rows
+}
@@ -0,0 +1,88 @@
+package kubernetes
+
+import (
+	"github.com/weaveworks/scope/report"
+
+	apiv1 "k8s.io/api/core/v1"
+	apiv1beta1 "k8s.io/api/extensions/v1beta1"
+)
+
+// These constants are keys used in node metadata
+const (
+	ExternalIPs  = report.KubernetesExternalIPs
+	IngressHosts = report.KubernetesIngressHosts
+)
+
+// Ingress represents a Kubernetes ingress
+type Ingress interface {
+	Meta
+	BackendServices() []string
+	GetNode(services map[string]string) report.Node
+}
+
+type ingress struct {
+	*apiv1beta1.Ingress
+	Meta
+}
+
+// NewIngress creates a new Ingress
+func NewIngress(i *apiv1beta1.Ingress) Ingress {
+	return &ingress{Ingress: i, Meta: meta{i.ObjectMeta}}
+}
+
+// BackendServices returns the names of the services the ingress routes to,
+// in its namespace.
+func (i *ingress) BackendServices() []string {
+	result := []string{}
+	if i.Spec.Backend != nil {
+		result = append(result, i.Spec.Backend.ServiceName)
+	}
+	for _, rule := range i.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			result = append(result, path.Backend.ServiceName)
+		}
+	}
+	return result
+}
+
+// GetNode gets the node of an ingress, adjacent to the nodes of the services
+// it routes to, given by name.
+func (i *ingress) GetNode(services map[string]string) report.Node {
+	hosts := report.MakeStringSet()
+	for _, rule := range i.Spec.Rules {
+		if rule.Host != "" {
+			hosts = hosts.Add(rule.Host)
+		}
+	}
+	sets := report.MakeSets()
+	if len(hosts) > 0 {
+		sets = sets.Add(IngressHosts, hosts)
+	}
+	if ips := loadBalancerAddresses(i.Status.LoadBalancer); len(ips) > 0 {
+		sets = sets.Add(ExternalIPs, ips)
+	}
+	node := i.MetaNode(report.MakeIngressNodeID(i.UID())).WithSets(sets)
+	for _, name := range i.BackendServices() {
+		if id, ok := services[name]; ok {
+			node = node.WithAdjacent(id)
+		}
+	}
+	return node
+}
+
+// loadBalancerAddresses returns the addresses, IPs or hostnames, at which a
+// load balancer takes traffic in.
+func loadBalancerAddresses(status apiv1.LoadBalancerStatus) report.StringSet {
+	result := report.MakeStringSet()
+	for _, ingress := range status.Ingress {
+		if ingress.IP != "" {
+			result = result.Add(ingress.IP)
+		} else if ingress.Hostname != "" {
+			result = result.Add(ingress.Hostname)
+		}
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package kubernetes
+
+import (
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// These constants are keys used in node metadata
+const (
+	Completions    = report.KubernetesCompletions
+	Parallelism    = report.KubernetesParallelism
+	Succeeded      = report.KubernetesSucceeded
+	Failed         = report.KubernetesFailed
+	Active         = report.KubernetesActive
+	StartTime      = report.KubernetesStartTime
+	CompletionTime = report.KubernetesCompletionTime
+)
+
+// Job represents a Kubernetes job
+type Job interface {
+	Meta
+	Selector() (labels.Selector, error)
+	GetNode() report.Node
+}
+
+type job struct {
+	*batchv1.Job
+	Meta
+}
+
+// NewJob creates a new Job
+func NewJob(j *batchv1.Job) Job {
+	return &job{Job: j, Meta: meta{j.ObjectMeta}}
+}
+
+func (j *job) Selector() (labels.Selector, error) {
+	selector, err := metav1.LabelSelectorAsSelector(j.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	return selector, nil
+}
+
+func (j *job) GetNode() report.Node {
+	// Spec.Completions and Spec.Parallelism can be omitted, and default to 1.
+	completions, parallelism := 1, 1
+	if j.Spec.Completions != nil {
+		completions = int(*j.Spec.Completions)
+	}
+	if j.Spec.Parallelism != nil {
+		parallelism = int(*j.Spec.Parallelism)
+	}
+	latest := map[string]string{
+		NodeType:    "Job",
+		Completions: fmt.Sprint(completions),
+		Parallelism: fmt.Sprint(parallelism),
+		Succeeded:   fmt.Sprint(j.Status.Succeeded),
+		Failed:      fmt.Sprint(j.Status.Failed),
+		Active:      fmt.Sprint(j.Status.Active),
+	}
+	if j.Status.StartTime != nil {
+		latest[StartTime] = j.Status.StartTime.Format(time.RFC3339Nano)
+	}
+	if j.Status.CompletionTime != nil {
+		latest[CompletionTime] = j.Status.CompletionTime.Format(time.RFC3339Nano)
+	}
+	return j.MetaNode(report.MakeJobNodeID(j.UID())).WithLatests(latest)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"regexp"
 	"sync"
 )
 
@@ -150,6 +151,40 @@ func (l *logReadCloser) annotateLine(idx int, line []byte) []byte {
 	return []byte(fmt.Sprintf("[%-*s] %v", l.labelLength, l.labels[idx], string(line)))
 }
 
+// filteredReadCloser reads the lines of an io.ReadCloser matching a
+// regular expression
+type filteredReadCloser struct {
+	io.ReadCloser
+	reader *bufio.Reader
+	filter *regexp.Regexp
+	buffer bytes.Buffer
+}
+
+// NewFilteredReadCloser reads the lines of readCloser matching filter
+func NewFilteredReadCloser(readCloser io.ReadCloser, filter *regexp.Regexp) io.ReadCloser {
+	return &filteredReadCloser{
+		ReadCloser: readCloser,
+		reader:     bufio.NewReader(readCloser),
+		filter:     filter,
+	}
+}
+
+func (f *filteredReadCloser) Read(p []byte) (int, error) {
+	for f.buffer.Len() == 0 {
+		line, err := f.reader.ReadBytes('\n')
+		if len(line) > 0 && f.filter.Match(line) {
+			f.buffer.Write(line)
+		}
+		if err != nil {
+			if f.buffer.Len() > 0 {
+				break
+			}
+			return 0, err
+		}
+	}
+	return f.buffer.Read(p)
+}
+
 func (l *logReadCloser) isEOF() bool {
 	for _, e := range l.eof {
 		if !e {
@@ -16,6 +16,20 @@ const (
 	LabelPrefix = "kubernetes_labels_"
 )
 
+// Topologies of the built-in kinds, with their node IDs.
+var kindTopologies = map[string]struct {
+	topology string
+	makeID   func(string) string
+}{
+	"Pod":         {report.Pod, report.MakePodNodeID},
+	"Service":     {report.Service, report.MakeServiceNodeID},
+	"Deployment":  {report.Deployment, report.MakeDeploymentNodeID},
+	"DaemonSet":   {report.DaemonSet, report.MakeDaemonSetNodeID},
+	"StatefulSet": {report.StatefulSet, report.MakeStatefulSetNodeID},
+	"CronJob":     {report.CronJob, report.MakeCronJobNodeID},
+	"Job":         {report.Job, report.MakeJobNodeID},
+}
+
 // Meta represents a metadata information about a Kubernetes object
 type Meta interface {
 	UID() string
@@ -23,9 +37,21 @@ type Meta interface {
 	Namespace() string
 	Created() string
 	Labels() map[string]string
+	OwnerReferences() []metav1.OwnerReference
 	MetaNode(id string) report.Node
 }
 
+// NodeLabels returns the Kubernetes labels of a node, as reported.
+func NodeLabels(n report.Node) map[string]string {
+	result := map[string]string{}
+	n.Latest.ForEach(func(key string, _ time.Time, value string) {
+		if label, ok := report.WithoutPrefix(key, LabelPrefix); ok {
+			result[label] = value
+		}
+	})
+	return result
+}
+
 type meta struct {
 	ObjectMeta metav1.ObjectMeta
 }
@@ -50,6 +76,10 @@ func (m meta) Labels() map[string]string {
 	return m.ObjectMeta.Labels
 }
 
+func (m meta) OwnerReferences() []metav1.OwnerReference {
+	return m.ObjectMeta.OwnerReferences
+}
+
 // MetaNode gets the node metadata
 func (m meta) MetaNode(id string) report.Node {
 	return report.MakeNodeWith(id, map[string]string{
@@ -83,6 +113,10 @@ func (m namespaceMeta) Labels() map[string]string {
 	return m.ObjectMeta.Labels
 }
 
+func (m namespaceMeta) OwnerReferences() []metav1.OwnerReference {
+	return m.ObjectMeta.OwnerReferences
+}
+
 // MetaNode gets the node metadata
 // For namespaces, ObjectMeta.Namespace is not set
 func (m namespaceMeta) MetaNode(id string) report.Node {
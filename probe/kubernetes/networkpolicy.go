@@ -0,0 +1,171 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"sort"
+
+	apinetworkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// These constants are keys used in node metadata
+const (
+	NetworkPolicies = report.KubernetesNetworkPolicies
+)
+
+// Verdicts of network policies on the traffic between two peers
+const (
+	PolicyAllowed     = "allowed"
+	PolicyDenied      = "denied"
+	PolicyUnspecified = "unspecified"
+)
+
+// NetworkPolicy represents a Kubernetes network policy
+type NetworkPolicy interface {
+	Meta
+	Policy() Policy
+}
+
+type networkPolicy struct {
+	policy *apinetworkingv1.NetworkPolicy
+	Meta
+}
+
+// NewNetworkPolicy creates a new NetworkPolicy
+func NewNetworkPolicy(p *apinetworkingv1.NetworkPolicy) NetworkPolicy {
+	return &networkPolicy{policy: p, Meta: meta{p.ObjectMeta}}
+}
+
+func (p *networkPolicy) Policy() Policy {
+	return Policy{Name: p.Name(), Spec: p.policy.Spec}
+}
+
+// Policy is a network policy, as reported in the node of its namespace.
+type Policy struct {
+	Name string                            `json:"name"`
+	Spec apinetworkingv1.NetworkPolicySpec `json:"spec"`
+}
+
+// Policies are the network policies of a namespace.
+type Policies []Policy
+
+// String encodes the policies as reported, sorted by name.
+func (ps Policies) String() string {
+	sorted := append(Policies{}, ps...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	buf, err := json.Marshal(sorted)
+	if err != nil {
+		return "[]"
+	}
+	return string(buf)
+}
+
+// ParsePolicies decodes the policies reported in a namespace node.
+func ParsePolicies(s string) (Policies, error) {
+	var ps Policies
+	err := json.Unmarshal([]byte(s), &ps)
+	return ps, err
+}
+
+// PolicyPeer is either end of some traffic, as network policies see it: a
+// pod, by its labels and the namespace it is in, or, without a namespace,
+// anything else, like hosts and the internet.
+type PolicyPeer struct {
+	Namespace       string
+	Labels          map[string]string
+	NamespaceLabels map[string]string
+}
+
+// PolicyVerdict tells whether network policies, by namespace, allow the
+// traffic from src to dst, deny it, or whether none covers it: neither
+// end is selected by a policy of its namespace. Ports are not taken into
+// account, traffic being allowed as soon as it is on some port.
+func PolicyVerdict(policies map[string]Policies, src, dst PolicyPeer) string {
+	ingressAllowed, ingressCovered := policies[dst.Namespace].allow(apinetworkingv1.PolicyTypeIngress, dst, src)
+	egressAllowed, egressCovered := policies[src.Namespace].allow(apinetworkingv1.PolicyTypeEgress, src, dst)
+	switch {
+	case (ingressCovered && !ingressAllowed) || (egressCovered && !egressAllowed):
+		return PolicyDenied
+	case ingressCovered || egressCovered:
+		return PolicyAllowed
+	}
+	return PolicyUnspecified
+}
+
+// allow tells whether the policies selecting pod for the traffic of the
+// given type allow it with peer, and whether any does select it.
+func (ps Policies) allow(policyType apinetworkingv1.PolicyType, pod, peer PolicyPeer) (allowed, covered bool) {
+	for _, p := range ps {
+		if !p.hasType(policyType) || !selects(&p.Spec.PodSelector, pod.Labels) {
+			continue
+		}
+		covered = true
+		for _, peers := range p.peers(policyType) {
+			// Rules without peers allow all traffic
+			if len(peers) == 0 {
+				return true, true
+			}
+			for _, np := range peers {
+				if peerMatches(np, pod.Namespace, peer) {
+					return true, true
+				}
+			}
+		}
+	}
+	return false, covered
+}
+
+// hasType tells whether the policy is for traffic of the given type. Policies
+// not saying are for ingress, and egress too if they've egress rules.
+func (p Policy) hasType(policyType apinetworkingv1.PolicyType) bool {
+	if len(p.Spec.PolicyTypes) == 0 {
+		return policyType == apinetworkingv1.PolicyTypeIngress || len(p.Spec.Egress) > 0
+	}
+	for _, t := range p.Spec.PolicyTypes {
+		if t == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+// peers returns the peers of each rule of the policy for traffic of the
+// given type.
+func (p Policy) peers(policyType apinetworkingv1.PolicyType) [][]apinetworkingv1.NetworkPolicyPeer {
+	var result [][]apinetworkingv1.NetworkPolicyPeer
+	if policyType == apinetworkingv1.PolicyTypeIngress {
+		for _, rule := range p.Spec.Ingress {
+			result = append(result, rule.From)
+		}
+	} else {
+		for _, rule := range p.Spec.Egress {
+			result = append(result, rule.To)
+		}
+	}
+	return result
+}
+
+// peerMatches tells whether a peer of the rule of a policy in namespace
+// matches peer. IP blocks are taken to match everything but pods, which
+// they're not meant for.
+func peerMatches(np apinetworkingv1.NetworkPolicyPeer, namespace string, peer PolicyPeer) bool {
+	if peer.Namespace == "" || np.IPBlock != nil {
+		return peer.Namespace == "" && np.IPBlock != nil
+	}
+	if np.NamespaceSelector == nil {
+		return peer.Namespace == namespace && selects(np.PodSelector, peer.Labels)
+	}
+	return selects(np.NamespaceSelector, peer.NamespaceLabels) &&
+		(np.PodSelector == nil || selects(np.PodSelector, peer.Labels))
+}
+
+func selects(selector *metav1.LabelSelector, set map[string]string) bool {
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return s.Matches(labels.Set(set))
+}
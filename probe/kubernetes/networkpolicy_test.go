@@ -0,0 +1,77 @@
+package kubernetes_test
+
+import (
+	"testing"
+
+	apinetworkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/weaveworks/scope/probe/kubernetes"
+)
+
+func TestPolicyVerdict(t *testing.T) {
+	var (
+		app = metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}
+		db  = metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}
+		ops = metav1.LabelSelector{MatchLabels: map[string]string{"team": "ops"}}
+
+		api      = kubernetes.PolicyPeer{Namespace: "prod", Labels: map[string]string{"app": "api"}}
+		database = kubernetes.PolicyPeer{Namespace: "prod", Labels: map[string]string{"app": "db"}}
+		cache    = kubernetes.PolicyPeer{Namespace: "prod", Labels: map[string]string{"app": "cache"}}
+		monitor  = kubernetes.PolicyPeer{Namespace: "monitoring", Labels: map[string]string{"app": "prometheus"}, NamespaceLabels: map[string]string{"team": "ops"}}
+		other    = kubernetes.PolicyPeer{Namespace: "dev", Labels: map[string]string{"app": "api"}}
+		internet = kubernetes.PolicyPeer{}
+	)
+	policies := map[string]kubernetes.Policies{
+		"prod": {
+			// only the api and monitoring get to the database
+			{Name: "db", Spec: apinetworkingv1.NetworkPolicySpec{
+				PodSelector: db,
+				Ingress: []apinetworkingv1.NetworkPolicyIngressRule{
+					{From: []apinetworkingv1.NetworkPolicyPeer{{PodSelector: &app}}},
+					{From: []apinetworkingv1.NetworkPolicyPeer{{NamespaceSelector: &ops}}},
+				},
+			}},
+			// the api only talks to the database and to the outside
+			{Name: "api", Spec: apinetworkingv1.NetworkPolicySpec{
+				PodSelector: app,
+				PolicyTypes: []apinetworkingv1.PolicyType{apinetworkingv1.PolicyTypeEgress},
+				Egress: []apinetworkingv1.NetworkPolicyEgressRule{
+					{To: []apinetworkingv1.NetworkPolicyPeer{{PodSelector: &db}}},
+					{To: []apinetworkingv1.NetworkPolicyPeer{{IPBlock: &apinetworkingv1.IPBlock{CIDR: "0.0.0.0/0"}}}},
+				},
+			}},
+		},
+	}
+
+	for _, c := range []struct {
+		name     string
+		src, dst kubernetes.PolicyPeer
+		want     string
+	}{
+		{"selected peer", api, database, kubernetes.PolicyAllowed},
+		{"selected namespace", monitor, database, kubernetes.PolicyAllowed},
+		{"ingress not allowed", cache, database, kubernetes.PolicyDenied},
+		{"pod selector of another namespace", other, database, kubernetes.PolicyDenied},
+		{"egress not allowed", api, cache, kubernetes.PolicyDenied},
+		{"egress to an IP block", api, internet, kubernetes.PolicyAllowed},
+		{"ingress from outside", internet, database, kubernetes.PolicyDenied},
+		{"not covered", cache, monitor, kubernetes.PolicyUnspecified},
+		{"ingress only covered", monitor, api, kubernetes.PolicyUnspecified},
+		{"outside", internet, cache, kubernetes.PolicyUnspecified},
+	} {
+		if have := kubernetes.PolicyVerdict(policies, c.src, c.dst); have != c.want {
+			t.Errorf("%s: want %q, have %q", c.name, c.want, have)
+		}
+	}
+
+	// Policies are reported as JSON
+	encoded := policies["prod"].String()
+	decoded, err := kubernetes.ParsePolicies(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := kubernetes.PolicyVerdict(map[string]kubernetes.Policies{"prod": decoded}, cache, database); have != kubernetes.PolicyDenied {
+		t.Errorf("decoded policies: want %q, have %q", kubernetes.PolicyDenied, have)
+	}
+}
@@ -25,6 +25,8 @@ type Pod interface {
 	GetNode(probeID string) report.Node
 	RestartCount() uint
 	ContainerNames() []string
+	Sidecar() string
+	IP() string
 }
 
 type pod struct {
@@ -83,9 +85,18 @@ func (p *pod) GetNode(probeID string) report.Node {
 		latests[IsInHostNetwork] = "true"
 	}
 
+	if sidecar := p.Sidecar(); sidecar != "" {
+		latests[Sidecar] = sidecar
+	}
+
+	for k, v := range resourcesLatests(p.Pod.Spec) {
+		latests[k] = v
+	}
+
 	return p.MetaNode(report.MakePodNodeID(p.UID())).WithLatests(latests).
+		AddPrefixMulticolumnTable(ResourcesPrefix, resourcesRows(p.Pod.Spec)).
 		WithParents(p.parents).
-		WithLatestActiveControls(GetLogs, DeletePod)
+		WithLatestActiveControls(GetLogs, DeletePod, ForwardPort)
 }
 
 func (p *pod) ContainerNames() []string {
@@ -95,3 +106,18 @@ func (p *pod) ContainerNames() []string {
 	}
 	return containerNames
 }
+
+// Sidecar returns the name of the container the Envoy proxy of a service
+// mesh runs as in the pod, if it has one.
+func (p *pod) Sidecar() string {
+	for _, c := range p.Pod.Spec.Containers {
+		if c.Name == EnvoySidecar {
+			return c.Name
+		}
+	}
+	return ""
+}
+
+func (p *pod) IP() string {
+	return p.Status.PodIP
+}
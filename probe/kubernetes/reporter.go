@@ -23,6 +23,7 @@ const (
 	Replicas           = report.KubernetesReplicas
 	DesiredReplicas    = report.KubernetesDesiredReplicas
 	NodeType           = report.KubernetesNodeType
+	Cluster            = report.KubernetesCluster
 )
 
 // Exposed for testing
@@ -34,20 +35,33 @@ var (
 		Namespace:        {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 5},
 		Created:          {ID: Created, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 6},
 		RestartCount:     {ID: RestartCount, Label: "Restart #", From: report.FromLatest, Priority: 7},
+		Cluster:          {ID: Cluster, Label: "Cluster", From: report.FromLatest, Priority: 8},
 	}
 
-	PodMetricTemplates = docker.ContainerMetricTemplates
+	PodMetricTemplates = docker.ContainerMetricTemplates.Merge(report.MetricTemplates{
+		CPULimitUsage:    {ID: CPULimitUsage, Label: "CPU (% of limit)", Format: report.PercentFormat, Priority: 3},
+		MemoryLimitUsage: {ID: MemoryLimitUsage, Label: "Memory (% of limit)", Format: report.PercentFormat, Priority: 4},
+	})
 
 	ServiceMetadataTemplates = report.MetadataTemplates{
-		Namespace:  {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 2},
-		Created:    {ID: Created, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 3},
-		PublicIP:   {ID: PublicIP, Label: "Public IP", From: report.FromLatest, Datatype: report.IP, Priority: 4},
-		IP:         {ID: IP, Label: "Internal IP", From: report.FromLatest, Datatype: report.IP, Priority: 5},
-		report.Pod: {ID: report.Pod, Label: "# Pods", From: report.FromCounters, Datatype: report.Number, Priority: 6},
+		Namespace:   {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 2},
+		Created:     {ID: Created, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 3},
+		PublicIP:    {ID: PublicIP, Label: "Public IP", From: report.FromLatest, Datatype: report.IP, Priority: 4},
+		IP:          {ID: IP, Label: "Internal IP", From: report.FromLatest, Datatype: report.IP, Priority: 5},
+		report.Pod:  {ID: report.Pod, Label: "# Pods", From: report.FromCounters, Datatype: report.Number, Priority: 6},
+		ExternalIPs: {ID: ExternalIPs, Label: "External IPs", From: report.FromSets, Priority: 7},
+		Cluster:     {ID: Cluster, Label: "Cluster", From: report.FromLatest, Priority: 8},
 	}
 
 	ServiceMetricTemplates = PodMetricTemplates
 
+	IngressMetadataTemplates = report.MetadataTemplates{
+		Namespace:    {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 2},
+		Created:      {ID: Created, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 3},
+		IngressHosts: {ID: IngressHosts, Label: "Hosts", From: report.FromSets, Priority: 4},
+		ExternalIPs:  {ID: ExternalIPs, Label: "External IPs", From: report.FromSets, Priority: 5},
+	}
+
 	DeploymentMetadataTemplates = report.MetadataTemplates{
 		NodeType:           {ID: NodeType, Label: "Type", From: report.FromLatest, Priority: 1},
 		Namespace:          {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 2},
@@ -56,6 +70,9 @@ var (
 		DesiredReplicas:    {ID: DesiredReplicas, Label: "Desired Replicas", From: report.FromLatest, Datatype: report.Number, Priority: 5},
 		report.Pod:         {ID: report.Pod, Label: "# Pods", From: report.FromCounters, Datatype: report.Number, Priority: 6},
 		Strategy:           {ID: Strategy, Label: "Strategy", From: report.FromLatest, Priority: 7},
+		Autoscaler:         {ID: Autoscaler, Label: "Autoscaler", From: report.FromLatest, Priority: 8},
+		MinReplicas:        {ID: MinReplicas, Label: "Min Replicas", From: report.FromLatest, Datatype: report.Number, Priority: 9},
+		MaxReplicas:        {ID: MaxReplicas, Label: "Max Replicas", From: report.FromLatest, Datatype: report.Number, Priority: 10},
 	}
 
 	DeploymentMetricTemplates = PodMetricTemplates
@@ -94,6 +111,32 @@ var (
 
 	CronJobMetricTemplates = PodMetricTemplates
 
+	JobMetadataTemplates = report.MetadataTemplates{
+		NodeType:       {ID: NodeType, Label: "Type", From: report.FromLatest, Priority: 1},
+		Namespace:      {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 2},
+		Created:        {ID: Created, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 3},
+		Completions:    {ID: Completions, Label: "Completions", From: report.FromLatest, Datatype: report.Number, Priority: 4},
+		Parallelism:    {ID: Parallelism, Label: "Parallelism", From: report.FromLatest, Datatype: report.Number, Priority: 5},
+		Succeeded:      {ID: Succeeded, Label: "Succeeded", From: report.FromLatest, Datatype: report.Number, Priority: 6},
+		Failed:         {ID: Failed, Label: "Failed", From: report.FromLatest, Datatype: report.Number, Priority: 7},
+		Active:         {ID: Active, Label: "Active", From: report.FromLatest, Datatype: report.Number, Priority: 8},
+		StartTime:      {ID: StartTime, Label: "Started", From: report.FromLatest, Datatype: report.DateTime, Priority: 9},
+		CompletionTime: {ID: CompletionTime, Label: "Completed", From: report.FromLatest, Datatype: report.DateTime, Priority: 10},
+		report.Pod:     {ID: report.Pod, Label: "# Pods", From: report.FromCounters, Datatype: report.Number, Priority: 11},
+	}
+
+	JobMetricTemplates = PodMetricTemplates
+
+	CustomResourceMetadataTemplates = report.MetadataTemplates{
+		NodeType:   {ID: NodeType, Label: "Kind", From: report.FromLatest, Priority: 1},
+		APIVersion: {ID: APIVersion, Label: "API Version", From: report.FromLatest, Priority: 2},
+		Namespace:  {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 3},
+		Created:    {ID: Created, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 4},
+		report.Pod: {ID: report.Pod, Label: "# Pods", From: report.FromCounters, Datatype: report.Number, Priority: 5},
+	}
+
+	CustomResourceMetricTemplates = PodMetricTemplates
+
 	TableTemplates = report.TableTemplates{
 		LabelPrefix: {
 			ID:     LabelPrefix,
@@ -101,6 +144,59 @@ var (
 			Type:   report.PropertyListType,
 			Prefix: LabelPrefix,
 		},
+		EventsPrefix: {
+			ID:     EventsPrefix,
+			Label:  "Kubernetes Events",
+			Type:   report.MulticolumnTableType,
+			Prefix: EventsPrefix,
+			Columns: []report.Column{
+				{ID: EventLastSeen, Label: "Last Seen", DataType: report.DateTime},
+				{ID: EventType, Label: "Type"},
+				{ID: EventReason, Label: "Reason"},
+				{ID: EventMessage, Label: "Message"},
+				{ID: EventCount, Label: "Count", DataType: report.Number},
+			},
+		},
+		ResourcesPrefix: {
+			ID:     ResourcesPrefix,
+			Label:  "Resources",
+			Type:   report.MulticolumnTableType,
+			Prefix: ResourcesPrefix,
+			Columns: []report.Column{
+				{ID: ResourceContainer, Label: "Container"},
+				{ID: ResourceCPURequest, Label: "CPU Request", DataType: report.Number},
+				{ID: ResourceCPULimit, Label: "CPU Limit", DataType: report.Number},
+				{ID: ResourceCPUUsage, Label: "CPU Usage", DataType: report.Number},
+				{ID: ResourceMemoryRequest, Label: "Memory Request", DataType: report.Number},
+				{ID: ResourceMemoryLimit, Label: "Memory Limit", DataType: report.Number},
+				{ID: ResourceMemoryUsage, Label: "Memory Usage", DataType: report.Number},
+			},
+		},
+		MeshPrefix: {
+			ID:     MeshPrefix,
+			Label:  "Mesh Traffic",
+			Type:   report.MulticolumnTableType,
+			Prefix: MeshPrefix,
+			Columns: []report.Column{
+				{ID: MeshDirection, Label: "Direction"},
+				{ID: MeshService, Label: "Service"},
+				{ID: MeshPort, Label: "Port", DataType: report.Number},
+				{ID: MeshRequests, Label: "Requests", DataType: report.Number},
+				{ID: MeshConnections, Label: "Connections", DataType: report.Number},
+				{ID: MeshMTLS, Label: "mTLS"},
+			},
+		},
+		AutoscalerMetricsPrefix: {
+			ID:     AutoscalerMetricsPrefix,
+			Label:  "Autoscaler Metrics",
+			Type:   report.MulticolumnTableType,
+			Prefix: AutoscalerMetricsPrefix,
+			Columns: []report.Column{
+				{ID: AutoscalerMetric, Label: "Metric"},
+				{ID: AutoscalerMetricCurrent, Label: "Current"},
+				{ID: AutoscalerMetricTarget, Label: "Target"},
+			},
+		},
 	}
 
 	ScalingControls = []report.Control{
@@ -116,6 +212,58 @@ var (
 			Icon:  "fa-plus",
 			Rank:  1,
 		},
+		ScaleToControl,
+	}
+
+	ScaleToControl = report.Control{
+		ID:    ScaleTo,
+		Human: "Scale To",
+		Icon:  "fa-arrows-v",
+		Rank:  2,
+		Params: []report.ControlParam{
+			{ID: ScaleToReplicas, Human: "Replicas", Type: report.ControlParamInt},
+		},
+	}
+
+	GetLogsControl = report.Control{
+		ID:    GetLogs,
+		Human: "Get logs",
+		Icon:  "fa-desktop",
+		Rank:  0,
+		Params: []report.ControlParam{
+			{ID: GetLogsContainers, Human: "Containers (comma separated)", Type: report.ControlParamString, Default: GetLogsAllContainers},
+			{ID: GetLogsTail, Human: "Last lines (0 for all)", Type: report.ControlParamInt, Default: "0"},
+			{ID: GetLogsSince, Human: "Since seconds ago (0 for all)", Type: report.ControlParamInt, Default: "0"},
+			{ID: GetLogsTimestamps, Human: "Timestamps", Type: report.ControlParamEnum, Options: []string{"true", "false"}, Default: "true"},
+			{ID: GetLogsFilter, Human: "Filter (regular expression)", Type: report.ControlParamString, Default: GetLogsNoFilter},
+		},
+	}
+
+	AutoscalerControls = []report.Control{
+		{
+			ID:    AutoscalerMinDown,
+			Human: "Decrease Min Replicas",
+			Icon:  "fa-angle-down",
+			Rank:  3,
+		},
+		{
+			ID:    AutoscalerMinUp,
+			Human: "Increase Min Replicas",
+			Icon:  "fa-angle-up",
+			Rank:  4,
+		},
+		{
+			ID:    AutoscalerMaxDown,
+			Human: "Decrease Max Replicas",
+			Icon:  "fa-angle-double-down",
+			Rank:  5,
+		},
+		{
+			ID:    AutoscalerMaxUp,
+			Human: "Increase Max Replicas",
+			Icon:  "fa-angle-double-up",
+			Rank:  6,
+		},
 	}
 )
 
@@ -129,10 +277,12 @@ type Reporter struct {
 	handlerRegistry *controls.HandlerRegistry
 	nodeName        string
 	kubeletPort     uint
+	cluster         string
 }
 
-// NewReporter makes a new Reporter
-func NewReporter(client Client, pipes controls.PipeClient, probeID string, hostID string, probe *probe.Probe, handlerRegistry *controls.HandlerRegistry, nodeName string, kubeletPort uint) *Reporter {
+// NewReporter makes a new Reporter. The name of the cluster, if any, is added
+// to all the nodes reported, so that one app can show several clusters.
+func NewReporter(client Client, pipes controls.PipeClient, probeID string, hostID string, probe *probe.Probe, handlerRegistry *controls.HandlerRegistry, nodeName string, kubeletPort uint, cluster string) *Reporter {
 	reporter := &Reporter{
 		client:          client,
 		pipes:           pipes,
@@ -142,6 +292,7 @@ func NewReporter(client Client, pipes controls.PipeClient, probeID string, hostI
 		handlerRegistry: handlerRegistry,
 		nodeName:        nodeName,
 		kubeletPort:     kubeletPort,
+		cluster:         cluster,
 	}
 	reporter.registerControls()
 	client.WatchPods(reporter.podEvent)
@@ -205,6 +356,13 @@ func isPauseContainer(n report.Node, rpt report.Report) bool {
 // Tag adds pod parents to container nodes.
 func (r *Reporter) Tag(rpt report.Report) (report.Report, error) {
 	for id, n := range rpt.Container.Nodes {
+		// All the containers are in the cluster, and their port mappings
+		// scoped by it.
+		if r.cluster != "" {
+			n = n.WithLatest(Cluster, mtime.Now(), r.cluster)
+			rpt.Container.Nodes[id] = n
+		}
+
 		uid, ok := n.Latest.Lookup(docker.LabelPrefix + "io.kubernetes.pod.uid")
 		if !ok {
 			continue
@@ -234,23 +392,35 @@ func (r *Reporter) Report() (report.Report, error) {
 	if err != nil {
 		return result, err
 	}
-	daemonSetTopology, daemonSets, err := r.daemonSetTopology()
+	ingressTopology, err := r.ingressTopology(services)
+	if err != nil {
+		return result, err
+	}
+	customResourceTopology, customResources, err := r.customResourceTopology()
 	if err != nil {
 		return result, err
 	}
-	statefulSetTopology, statefulSets, err := r.statefulSetTopology()
+	daemonSetTopology, daemonSets, err := r.daemonSetTopology(customResources)
 	if err != nil {
 		return result, err
 	}
-	cronJobTopology, cronJobs, err := r.cronJobTopology()
+	statefulSetTopology, statefulSets, err := r.statefulSetTopology(customResources)
 	if err != nil {
 		return result, err
 	}
-	deploymentTopology, deployments, err := r.deploymentTopology(r.probeID)
+	cronJobTopology, cronJobs, err := r.cronJobTopology(customResources)
 	if err != nil {
 		return result, err
 	}
-	podTopology, err := r.podTopology(services, deployments, daemonSets, statefulSets, cronJobs)
+	jobTopology, jobs, err := r.jobTopology(customResources)
+	if err != nil {
+		return result, err
+	}
+	deploymentTopology, deployments, err := r.deploymentTopology(r.probeID, customResources)
+	if err != nil {
+		return result, err
+	}
+	podTopology, err := r.podTopology(services, deployments, daemonSets, statefulSets, cronJobs, jobs, customResources)
 	if err != nil {
 		return result, err
 	}
@@ -261,14 +431,68 @@ func (r *Reporter) Report() (report.Report, error) {
 	result.Pod = result.Pod.Merge(podTopology)
 	result.Service = result.Service.Merge(serviceTopology)
 	result.Host = result.Host.Merge(hostTopology)
+	result.Ingress = result.Ingress.Merge(ingressTopology)
 	result.DaemonSet = result.DaemonSet.Merge(daemonSetTopology)
 	result.StatefulSet = result.StatefulSet.Merge(statefulSetTopology)
 	result.CronJob = result.CronJob.Merge(cronJobTopology)
+	result.Job = result.Job.Merge(jobTopology)
 	result.Deployment = result.Deployment.Merge(deploymentTopology)
+	result.CustomResource = result.CustomResource.Merge(customResourceTopology)
 	result.Namespace = result.Namespace.Merge(namespaceTopology)
+	if err := r.attachEvents(&result); err != nil {
+		return result, err
+	}
+	r.attachCluster(&result)
 	return result, nil
 }
 
+// attachCluster adds the name of the cluster to the reported nodes, and to
+// the node of the host. The IDs of the nodes are made from the UIDs of the
+// objects, which are unique across clusters, and so need no cluster in them,
+// unlike those of addresses (see report.MakeClusterAddressNodeID).
+func (r *Reporter) attachCluster(rpt *report.Report) {
+	if r.cluster == "" {
+		return
+	}
+	now := mtime.Now()
+	rpt.Host.AddNode(report.MakeNode(report.MakeHostNodeID(r.hostID)))
+	rpt.WalkTopologies(func(t *report.Topology) {
+		for id, n := range t.Nodes {
+			t.Nodes[id] = n.WithLatest(Cluster, now, r.cluster)
+		}
+	})
+}
+
+// attachEvents adds the recent events of the reported objects to their
+// nodes, as a table.
+func (r *Reporter) attachEvents(rpt *report.Report) error {
+	var (
+		cutoff = mtime.Now().Add(-EventMaxAge)
+		events = map[string]map[string][]EventResource{} // by topology and node ID
+	)
+	err := r.client.WalkEvents(func(e EventResource) error {
+		involved := e.InvolvedObject()
+		t, ok := kindTopologies[involved.Kind]
+		if !ok || e.LastSeen().Before(cutoff) {
+			return nil
+		}
+		if events[t.topology] == nil {
+			events[t.topology] = map[string][]EventResource{}
+		}
+		id := t.makeID(string(involved.UID))
+		events[t.topology][id] = append(events[t.topology][id], e)
+		return nil
+	})
+	rpt.WalkNamedTopologies(func(name string, t *report.Topology) {
+		for id, es := range events[name] {
+			if n, ok := t.Nodes[id]; ok {
+				t.Nodes[id] = n.AddPrefixMulticolumnTable(EventsPrefix, eventRows(es))
+			}
+		}
+	})
+	return err
+}
+
 func (r *Reporter) serviceTopology() (report.Topology, []Service, error) {
 	var (
 		result = report.MakeTopology().
@@ -285,6 +509,26 @@ func (r *Reporter) serviceTopology() (report.Topology, []Service, error) {
 	return result, services, err
 }
 
+// ingressTopology reports the ingresses, adjacent to the services they route
+// to.
+func (r *Reporter) ingressTopology(services []Service) (report.Topology, error) {
+	result := report.MakeTopology().
+		WithMetadataTemplates(IngressMetadataTemplates).
+		WithTableTemplates(TableTemplates)
+	serviceIDs := map[string]map[string]string{}
+	for _, s := range services {
+		if serviceIDs[s.Namespace()] == nil {
+			serviceIDs[s.Namespace()] = map[string]string{}
+		}
+		serviceIDs[s.Namespace()][s.Name()] = report.MakeServiceNodeID(s.UID())
+	}
+	err := r.client.WalkIngresses(func(i Ingress) error {
+		result = result.AddNode(i.GetNode(serviceIDs[i.Namespace()]))
+		return nil
+	})
+	return result, err
+}
+
 // FIXME: Hideous hack to remove persistent-connection edges to
 // virtual service IPs attributed to the internet. The global
 // service-cluster-ip-range is not exposed by the API server (see
@@ -313,7 +557,7 @@ func (r *Reporter) hostTopology(services []Service) report.Topology {
 			WithSets(report.MakeSets().Add(host.LocalNetworks, report.MakeStringSet(serviceNetwork.String()))))
 }
 
-func (r *Reporter) deploymentTopology(probeID string) (report.Topology, []Deployment, error) {
+func (r *Reporter) deploymentTopology(probeID string, customResources map[string]struct{}) (report.Topology, []Deployment, error) {
 	var (
 		result = report.MakeTopology().
 			WithMetadataTemplates(DeploymentMetadataTemplates).
@@ -322,57 +566,113 @@ func (r *Reporter) deploymentTopology(probeID string) (report.Topology, []Deploy
 		deployments = []Deployment{}
 	)
 	result.Controls.AddControls(ScalingControls)
+	result.Controls.AddControls(AutoscalerControls)
+
+	autoscalers := []HorizontalPodAutoscaler{}
+	if err := r.client.WalkHorizontalPodAutoscalers(func(h HorizontalPodAutoscaler) error {
+		autoscalers = append(autoscalers, h)
+		return nil
+	}); err != nil {
+		return result, deployments, err
+	}
 
 	err := r.client.WalkDeployments(func(d Deployment) error {
-		result = result.AddNode(d.GetNode(probeID))
+		node := d.GetNode(probeID).WithParents(customResourceParents(d, customResources))
+		for _, h := range autoscalers {
+			if h.Namespace() == d.Namespace() && h.Scales("Deployment", d.Name()) {
+				node = h.AddToNode(node)
+			}
+		}
+		result = result.AddNode(node)
 		deployments = append(deployments, d)
 		return nil
 	})
 	return result, deployments, err
 }
 
-func (r *Reporter) daemonSetTopology() (report.Topology, []DaemonSet, error) {
+func (r *Reporter) daemonSetTopology(customResources map[string]struct{}) (report.Topology, []DaemonSet, error) {
 	daemonSets := []DaemonSet{}
 	result := report.MakeTopology().
 		WithMetadataTemplates(DaemonSetMetadataTemplates).
 		WithMetricTemplates(DaemonSetMetricTemplates).
 		WithTableTemplates(TableTemplates)
 	err := r.client.WalkDaemonSets(func(d DaemonSet) error {
-		result = result.AddNode(d.GetNode())
+		result = result.AddNode(d.GetNode().WithParents(customResourceParents(d, customResources)))
 		daemonSets = append(daemonSets, d)
 		return nil
 	})
 	return result, daemonSets, err
 }
 
-func (r *Reporter) statefulSetTopology() (report.Topology, []StatefulSet, error) {
+func (r *Reporter) statefulSetTopology(customResources map[string]struct{}) (report.Topology, []StatefulSet, error) {
 	statefulSets := []StatefulSet{}
 	result := report.MakeTopology().
 		WithMetadataTemplates(StatefulSetMetadataTemplates).
 		WithMetricTemplates(StatefulSetMetricTemplates).
 		WithTableTemplates(TableTemplates)
 	err := r.client.WalkStatefulSets(func(s StatefulSet) error {
-		result = result.AddNode(s.GetNode())
+		result = result.AddNode(s.GetNode().WithParents(customResourceParents(s, customResources)))
 		statefulSets = append(statefulSets, s)
 		return nil
 	})
 	return result, statefulSets, err
 }
 
-func (r *Reporter) cronJobTopology() (report.Topology, []CronJob, error) {
+func (r *Reporter) cronJobTopology(customResources map[string]struct{}) (report.Topology, []CronJob, error) {
 	cronJobs := []CronJob{}
 	result := report.MakeTopology().
 		WithMetadataTemplates(CronJobMetadataTemplates).
 		WithMetricTemplates(CronJobMetricTemplates).
 		WithTableTemplates(TableTemplates)
 	err := r.client.WalkCronJobs(func(c CronJob) error {
-		result = result.AddNode(c.GetNode())
+		result = result.AddNode(c.GetNode().WithParents(customResourceParents(c, customResources)))
 		cronJobs = append(cronJobs, c)
 		return nil
 	})
 	return result, cronJobs, err
 }
 
+// jobTopology reports the jobs no cron job started, those which one did
+// being reported as part of it.
+func (r *Reporter) jobTopology(customResources map[string]struct{}) (report.Topology, []Job, error) {
+	jobs := []Job{}
+	result := report.MakeTopology().
+		WithMetadataTemplates(JobMetadataTemplates).
+		WithMetricTemplates(JobMetricTemplates).
+		WithTableTemplates(TableTemplates)
+	err := r.client.WalkJobs(func(j Job) error {
+		for _, owner := range j.OwnerReferences() {
+			if owner.Kind == "CronJob" {
+				return nil
+			}
+		}
+		result = result.AddNode(j.GetNode().WithParents(customResourceParents(j, customResources)))
+		jobs = append(jobs, j)
+		return nil
+	})
+	return result, jobs, err
+}
+
+// customResourceTopology reports the custom resources, returning their UIDs
+// so as to tell the objects they own.
+func (r *Reporter) customResourceTopology() (report.Topology, map[string]struct{}, error) {
+	result := report.MakeTopology().
+		WithMetadataTemplates(CustomResourceMetadataTemplates).
+		WithMetricTemplates(CustomResourceMetricTemplates).
+		WithTableTemplates(TableTemplates)
+	customResources := map[string]struct{}{}
+	walked := []CustomResource{}
+	err := r.client.WalkCustomResources(func(c CustomResource) error {
+		customResources[c.UID()] = struct{}{}
+		walked = append(walked, c)
+		return nil
+	})
+	for _, c := range walked {
+		result = result.AddNode(c.GetNode(customResources))
+	}
+	return result, customResources, err
+}
+
 type labelledChild interface {
 	Labels() map[string]string
 	AddParent(string, string)
@@ -388,7 +688,18 @@ func match(namespace string, selector labels.Selector, topology, id string) func
 	}
 }
 
-func (r *Reporter) podTopology(services []Service, deployments []Deployment, daemonSets []DaemonSet, statefulSets []StatefulSet, cronJobs []CronJob) (report.Topology, error) {
+// matchOwners adds the custom resources owning a controller as parents of
+// the children its selector matches.
+func matchOwners(controller Meta, selector labels.Selector, customResources map[string]struct{}) []func(labelledChild) {
+	owners, _ := customResourceParents(controller, customResources).Lookup(report.CustomResource)
+	result := make([]func(labelledChild), 0, len(owners))
+	for _, id := range owners {
+		result = append(result, match(controller.Namespace(), selector, report.CustomResource, id))
+	}
+	return result
+}
+
+func (r *Reporter) podTopology(services []Service, deployments []Deployment, daemonSets []DaemonSet, statefulSets []StatefulSet, cronJobs []CronJob, jobs []Job, customResources map[string]struct{}) (report.Topology, error) {
 	var (
 		pods = report.MakeTopology().
 			WithMetadataTemplates(PodMetadataTemplates).
@@ -396,17 +707,23 @@ func (r *Reporter) podTopology(services []Service, deployments []Deployment, dae
 			WithTableTemplates(TableTemplates)
 		selectors = []func(labelledChild){}
 	)
+	pods.Controls.AddControl(GetLogsControl)
 	pods.Controls.AddControl(report.Control{
-		ID:    GetLogs,
-		Human: "Get logs",
-		Icon:  "fa-desktop",
-		Rank:  0,
+		ID:    ForwardPort,
+		Human: "Forward port",
+		Icon:  "fa-exchange",
+		Rank:  2,
+		Params: []report.ControlParam{
+			{ID: controls.PortForwardPort, Human: "Port", Type: report.ControlParamInt},
+		},
 	})
 	pods.Controls.AddControl(report.Control{
-		ID:    DeletePod,
-		Human: "Delete",
-		Icon:  "fa-trash-o",
-		Rank:  1,
+		ID:      DeletePod,
+		Human:   "Delete",
+		Icon:    "fa-trash-o",
+		Rank:    1,
+		Confirm: "Delete this pod?",
+		DryRun:  DeletePodDryRun,
 	})
 	for _, service := range services {
 		selectors = append(selectors, match(
@@ -427,6 +744,7 @@ func (r *Reporter) podTopology(services []Service, deployments []Deployment, dae
 			report.Deployment,
 			report.MakeDeploymentNodeID(deployment.UID()),
 		))
+		selectors = append(selectors, matchOwners(deployment, selector, customResources)...)
 	}
 	for _, daemonSet := range daemonSets {
 		selector, err := daemonSet.Selector()
@@ -439,6 +757,7 @@ func (r *Reporter) podTopology(services []Service, deployments []Deployment, dae
 			report.DaemonSet,
 			report.MakeDaemonSetNodeID(daemonSet.UID()),
 		))
+		selectors = append(selectors, matchOwners(daemonSet, selector, customResources)...)
 	}
 	for _, statefulSet := range statefulSets {
 		selector, err := statefulSet.Selector()
@@ -451,6 +770,7 @@ func (r *Reporter) podTopology(services []Service, deployments []Deployment, dae
 			report.StatefulSet,
 			report.MakeStatefulSetNodeID(statefulSet.UID()),
 		))
+		selectors = append(selectors, matchOwners(statefulSet, selector, customResources)...)
 	}
 	for _, cronJob := range cronJobs {
 		cronJobSelectors, err := cronJob.Selectors()
@@ -464,9 +784,24 @@ func (r *Reporter) podTopology(services []Service, deployments []Deployment, dae
 				report.CronJob,
 				report.MakeCronJobNodeID(cronJob.UID()),
 			))
+			selectors = append(selectors, matchOwners(cronJob, selector, customResources)...)
 		}
 	}
 
+	for _, job := range jobs {
+		selector, err := job.Selector()
+		if err != nil {
+			return pods, err
+		}
+		selectors = append(selectors, match(
+			job.Namespace(),
+			selector,
+			report.Job,
+			report.MakeJobNodeID(job.UID()),
+		))
+		selectors = append(selectors, matchOwners(job, selector, customResources)...)
+	}
+
 	var localPodUIDs map[string]struct{}
 	if r.nodeName == "" {
 		// We don't know the node name: fall back to obtaining the local pods from kubelet
@@ -490,7 +825,15 @@ func (r *Reporter) podTopology(services []Service, deployments []Deployment, dae
 		for _, selector := range selectors {
 			selector(p)
 		}
-		pods = pods.AddNode(p.GetNode(r.probeID))
+		owners, _ := customResourceParents(p, customResources).Lookup(report.CustomResource)
+		for _, id := range owners {
+			p.AddParent(report.CustomResource, id)
+		}
+		node := p.GetNode(r.probeID)
+		if p.Sidecar() != "" && p.IP() != "" {
+			node = node.AddPrefixMulticolumnTable(MeshPrefix, envoyMeshRows(p))
+		}
+		pods = pods.AddNode(node)
 		return nil
 	})
 	return pods, err
@@ -498,8 +841,20 @@ func (r *Reporter) podTopology(services []Service, deployments []Deployment, dae
 
 func (r *Reporter) namespaceTopology() (report.Topology, error) {
 	result := report.MakeTopology()
-	err := r.client.WalkNamespaces(func(ns NamespaceResource) error {
-		result = result.AddNode(ns.GetNode())
+	policies := map[string]Policies{}
+	err := r.client.WalkNetworkPolicies(func(p NetworkPolicy) error {
+		policies[p.Namespace()] = append(policies[p.Namespace()], p.Policy())
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	err = r.client.WalkNamespaces(func(ns NamespaceResource) error {
+		node := ns.GetNode()
+		if ps, ok := policies[ns.Name()]; ok {
+			node = node.WithLatests(map[string]string{NetworkPolicies: ps.String()})
+		}
+		result = result.AddNode(node)
 		return nil
 	})
 	return result, err
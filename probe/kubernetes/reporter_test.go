@@ -6,9 +6,16 @@ import (
 	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 
+	apiautoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	apibatchv1 "k8s.io/api/batch/v1"
 	apiv1 "k8s.io/api/core/v1"
+	apiextensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	apinetworkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/weaveworks/scope/common/xfer"
@@ -20,11 +27,17 @@ import (
 )
 
 var (
-	nodeName    = "nodename"
-	pod1UID     = "a1b2c3d4e5"
-	pod2UID     = "f6g7h8i9j0"
-	serviceUID  = "service1234"
-	podTypeMeta = metav1.TypeMeta{
+	nodeName      = "nodename"
+	pod1UID       = "a1b2c3d4e5"
+	pod2UID       = "f6g7h8i9j0"
+	serviceUID    = "service1234"
+	pingUID       = "ping1234"
+	kafkaUID      = "kafka1234"
+	topicUID      = "topic1234"
+	ingressUID    = "ingress1234"
+	jobUID        = "job1234"
+	deploymentUID = "deployment1234"
+	podTypeMeta   = metav1.TypeMeta{
 		Kind:       "Pod",
 		APIVersion: "v1",
 	}
@@ -35,7 +48,7 @@ var (
 			UID:               types.UID(pod1UID),
 			Namespace:         "ping",
 			CreationTimestamp: metav1.Now(),
-			Labels:            map[string]string{"ponger": "true"},
+			Labels:            map[string]string{"ponger": "true", "job-name": "pong-job"},
 		},
 		Status: apiv1.PodStatus{
 			HostIP: "1.2.3.4",
@@ -47,6 +60,29 @@ var (
 		Spec: apiv1.PodSpec{
 			NodeName:    nodeName,
 			HostNetwork: true,
+			Containers: []apiv1.Container{
+				{
+					Name: "pong",
+					Resources: apiv1.ResourceRequirements{
+						Requests: apiv1.ResourceList{
+							apiv1.ResourceCPU:    resource.MustParse("250m"),
+							apiv1.ResourceMemory: resource.MustParse("64Mi"),
+						},
+						Limits: apiv1.ResourceList{
+							apiv1.ResourceCPU:    resource.MustParse("500m"),
+							apiv1.ResourceMemory: resource.MustParse("128Mi"),
+						},
+					},
+				},
+				{
+					Name: "sidecar",
+					Resources: apiv1.ResourceRequirements{
+						Limits: apiv1.ResourceList{
+							apiv1.ResourceCPU: resource.MustParse("100m"),
+						},
+					},
+				},
+			},
 		},
 	}
 	apiPod2 = apiv1.Pod{
@@ -57,6 +93,7 @@ var (
 			Namespace:         "ping",
 			CreationTimestamp: metav1.Now(),
 			Labels:            map[string]string{"ponger": "true"},
+			OwnerReferences:   []metav1.OwnerReference{{Kind: "Kafka", Name: "pongs", UID: types.UID(kafkaUID)}},
 		},
 		Status: apiv1.PodStatus{
 			HostIP: "1.2.3.4",
@@ -97,23 +134,168 @@ var (
 			},
 		},
 	}
-	pod1     = kubernetes.NewPod(&apiPod1)
-	pod2     = kubernetes.NewPod(&apiPod2)
-	service1 = kubernetes.NewService(&apiService1)
+	apiNamespace = apiv1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "ping",
+			UID:               types.UID(pingUID),
+			CreationTimestamp: metav1.Now(),
+		},
+	}
+	apiPolicy = apinetworkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pongers-only",
+			Namespace: "ping",
+		},
+		Spec: apinetworkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"ponger": "true"}},
+		},
+	}
+	apiIngress = apiextensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pong-ingress",
+			UID:       types.UID(ingressUID),
+			Namespace: "ping",
+		},
+		Spec: apiextensionsv1beta1.IngressSpec{
+			Rules: []apiextensionsv1beta1.IngressRule{{
+				Host: "pong.example.com",
+				IngressRuleValue: apiextensionsv1beta1.IngressRuleValue{HTTP: &apiextensionsv1beta1.HTTPIngressRuleValue{
+					Paths: []apiextensionsv1beta1.HTTPIngressPath{
+						{Path: "/", Backend: apiextensionsv1beta1.IngressBackend{ServiceName: "pongservice"}},
+						{Path: "/old", Backend: apiextensionsv1beta1.IngressBackend{ServiceName: "gone"}},
+					},
+				}},
+			}},
+		},
+		Status: apiextensionsv1beta1.IngressStatus{
+			LoadBalancer: apiv1.LoadBalancerStatus{Ingress: []apiv1.LoadBalancerIngress{{Hostname: "lb.example.com"}}},
+		},
+	}
+	completions = int32(3)
+	apiJob      = apibatchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pong-job",
+			UID:       types.UID(jobUID),
+			Namespace: "ping",
+		},
+		Spec: apibatchv1.JobSpec{
+			Completions: &completions,
+			Selector:    &metav1.LabelSelector{MatchLabels: map[string]string{"job-name": "pong-job"}},
+		},
+		Status: apibatchv1.JobStatus{Active: 1, Succeeded: 2},
+	}
+	apiDeployment = apiextensionsv1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pong-deployment",
+			UID:       types.UID(deploymentUID),
+			Namespace: "ping",
+		},
+		Spec: apiextensionsv1beta1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "pong-deployment"}},
+		},
+	}
+	minReplicas, targetUtilization, currentUtilization = int32(2), int32(80), int32(85)
+	apiAutoscaler                                      = apiautoscalingv2beta1.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pong-autoscaler",
+			Namespace: "ping",
+		},
+		Spec: apiautoscalingv2beta1.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: apiautoscalingv2beta1.CrossVersionObjectReference{Kind: "Deployment", Name: "pong-deployment"},
+			MinReplicas:    &minReplicas,
+			MaxReplicas:    5,
+			Metrics: []apiautoscalingv2beta1.MetricSpec{{
+				Type:     apiautoscalingv2beta1.ResourceMetricSourceType,
+				Resource: &apiautoscalingv2beta1.ResourceMetricSource{Name: apiv1.ResourceCPU, TargetAverageUtilization: &targetUtilization},
+			}},
+		},
+		Status: apiautoscalingv2beta1.HorizontalPodAutoscalerStatus{
+			CurrentMetrics: []apiautoscalingv2beta1.MetricStatus{{
+				Type:     apiautoscalingv2beta1.ResourceMetricSourceType,
+				Resource: &apiautoscalingv2beta1.ResourceMetricStatus{Name: apiv1.ResourceCPU, CurrentAverageUtilization: &currentUtilization},
+			}},
+		},
+	}
+	kafka = kubernetes.NewCustomResource(&unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kafka.strimzi.io/v1beta1",
+		"kind":       "Kafka",
+		"metadata": map[string]interface{}{
+			"name":      "pongs",
+			"namespace": "ping",
+			"uid":       kafkaUID,
+		},
+	}})
+	topic = kubernetes.NewCustomResource(&unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kafka.strimzi.io/v1beta1",
+		"kind":       "KafkaTopic",
+		"metadata": map[string]interface{}{
+			"name":      "pongs-topic",
+			"namespace": "ping",
+			"uid":       topicUID,
+			"ownerReferences": []interface{}{
+				map[string]interface{}{"apiVersion": "kafka.strimzi.io/v1beta1", "kind": "Kafka", "name": "pongs", "uid": kafkaUID},
+			},
+		},
+	}})
+	pod1      = kubernetes.NewPod(&apiPod1)
+	pod2      = kubernetes.NewPod(&apiPod2)
+	service1  = kubernetes.NewService(&apiService1)
+	namespace = kubernetes.NewNamespace(&apiNamespace)
+	policy    = kubernetes.NewNetworkPolicy(&apiPolicy)
+	ingress   = kubernetes.NewIngress(&apiIngress)
+	job       = kubernetes.NewJob(&apiJob)
+	events    = []kubernetes.EventResource{
+		kubernetes.NewEvent(&apiv1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "pong-a.1", Namespace: "ping"},
+			InvolvedObject: apiv1.ObjectReference{Kind: "Pod", Name: "pong-a", UID: types.UID(pod1UID)},
+			Type:           apiv1.EventTypeWarning,
+			Reason:         "Unhealthy",
+			Message:        "Liveness probe failed",
+			Count:          3,
+			LastTimestamp:  metav1.Now(),
+		}),
+		kubernetes.NewEvent(&apiv1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "pong-a.0", Namespace: "ping"},
+			InvolvedObject: apiv1.ObjectReference{Kind: "Pod", Name: "pong-a", UID: types.UID(pod1UID)},
+			Type:           apiv1.EventTypeNormal,
+			Reason:         "Scheduled",
+			LastTimestamp:  metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+		}),
+	}
 )
 
 func newMockClient() *mockClient {
 	return &mockClient{
-		pods:     []kubernetes.Pod{pod1, pod2},
-		services: []kubernetes.Service{service1},
-		logs:     map[string]io.ReadCloser{},
+		pods:        []kubernetes.Pod{pod1, pod2},
+		services:    []kubernetes.Service{service1},
+		namespaces:  []kubernetes.NamespaceResource{namespace},
+		policies:    []kubernetes.NetworkPolicy{policy},
+		resources:   []kubernetes.CustomResource{kafka, topic},
+		ingresses:   []kubernetes.Ingress{ingress},
+		jobs:        []kubernetes.Job{job},
+		deployments: []kubernetes.Deployment{kubernetes.NewDeployment(&apiDeployment)},
+		autoscalers: []kubernetes.HorizontalPodAutoscaler{kubernetes.NewHorizontalPodAutoscaler(&apiAutoscaler)},
+		events:      events,
+		logs:        map[string]io.ReadCloser{},
 	}
 }
 
 type mockClient struct {
-	pods     []kubernetes.Pod
-	services []kubernetes.Service
-	logs     map[string]io.ReadCloser
+	pods        []kubernetes.Pod
+	services    []kubernetes.Service
+	namespaces  []kubernetes.NamespaceResource
+	policies    []kubernetes.NetworkPolicy
+	resources   []kubernetes.CustomResource
+	ingresses   []kubernetes.Ingress
+	jobs        []kubernetes.Job
+	events      []kubernetes.EventResource
+	deployments []kubernetes.Deployment
+	autoscalers []kubernetes.HorizontalPodAutoscaler
+	logs        map[string]io.ReadCloser
+	adjusted    []string
+	scaled      []string
+	deleted     []string
+	logOptions  []string
 }
 
 func (c *mockClient) Stop() {}
@@ -142,14 +324,73 @@ func (c *mockClient) WalkStatefulSets(f func(kubernetes.StatefulSet) error) erro
 func (c *mockClient) WalkCronJobs(f func(kubernetes.CronJob) error) error {
 	return nil
 }
+func (c *mockClient) WalkJobs(f func(kubernetes.Job) error) error {
+	for _, job := range c.jobs {
+		if err := f(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (c *mockClient) WalkEvents(f func(kubernetes.EventResource) error) error {
+	for _, event := range c.events {
+		if err := f(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 func (c *mockClient) WalkDeployments(f func(kubernetes.Deployment) error) error {
+	for _, deployment := range c.deployments {
+		if err := f(deployment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (c *mockClient) WalkHorizontalPodAutoscalers(f func(kubernetes.HorizontalPodAutoscaler) error) error {
+	for _, autoscaler := range c.autoscalers {
+		if err := f(autoscaler); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 func (c *mockClient) WalkNamespaces(f func(kubernetes.NamespaceResource) error) error {
+	for _, namespace := range c.namespaces {
+		if err := f(namespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (c *mockClient) WalkNetworkPolicies(f func(kubernetes.NetworkPolicy) error) error {
+	for _, policy := range c.policies {
+		if err := f(policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (c *mockClient) WalkCustomResources(f func(kubernetes.CustomResource) error) error {
+	for _, resource := range c.resources {
+		if err := f(resource); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (c *mockClient) WalkIngresses(f func(kubernetes.Ingress) error) error {
+	for _, ingress := range c.ingresses {
+		if err := f(ingress); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 func (*mockClient) WatchPods(func(kubernetes.Event, kubernetes.Pod)) {}
-func (c *mockClient) GetLogs(namespaceID, podName string, _ []string) (io.ReadCloser, error) {
+func (c *mockClient) GetLogs(namespaceID, podName string, containerNames []string, opts kubernetes.LogOptions) (io.ReadCloser, error) {
+	c.logOptions = append(c.logOptions, fmt.Sprintf("%v %d %d %t", containerNames, opts.TailLines, opts.SinceSeconds, opts.Timestamps))
 	r, ok := c.logs[namespaceID+";"+podName]
 	if !ok {
 		return nil, fmt.Errorf("Not found")
@@ -157,6 +398,7 @@ func (c *mockClient) GetLogs(namespaceID, podName string, _ []string) (io.ReadCl
 	return r, nil
 }
 func (c *mockClient) DeletePod(namespaceID, podID string) error {
+	c.deleted = append(c.deleted, namespaceID+"/"+podID)
 	return nil
 }
 func (c *mockClient) ScaleUp(resource, namespaceID, id string) error {
@@ -165,6 +407,14 @@ func (c *mockClient) ScaleUp(resource, namespaceID, id string) error {
 func (c *mockClient) ScaleDown(resource, namespaceID, id string) error {
 	return nil
 }
+func (c *mockClient) ScaleTo(resource, namespaceID, id string, replicas int32) error {
+	c.scaled = append(c.scaled, fmt.Sprintf("%s/%s %d", namespaceID, id, replicas))
+	return nil
+}
+func (c *mockClient) AdjustAutoscaler(namespaceID, id string, minDelta, maxDelta int32) error {
+	c.adjusted = append(c.adjusted, fmt.Sprintf("%s/%s %+d %+d", namespaceID, id, minDelta, maxDelta))
+	return nil
+}
 
 type mockPipeClient map[string]xfer.Pipe
 
@@ -194,7 +444,7 @@ func TestReporter(t *testing.T) {
 	pod2ID := report.MakePodNodeID(pod2UID)
 	serviceID := report.MakeServiceNodeID(serviceUID)
 	hr := controls.NewDefaultHandlerRegistry()
-	rpt, _ := kubernetes.NewReporter(newMockClient(), nil, "", "foo", nil, hr, "", 0).Report()
+	rpt, _ := kubernetes.NewReporter(newMockClient(), nil, "", "foo", nil, hr, "", 0, "").Report()
 
 	// Reporter should have added the following pods
 	for _, pod := range []struct {
@@ -203,9 +453,12 @@ func TestReporter(t *testing.T) {
 		latest        map[string]string
 	}{
 		{pod1ID, serviceID, map[string]string{
-			kubernetes.Name:      "pong-a",
-			kubernetes.Namespace: "ping",
-			kubernetes.Created:   pod1.Created(),
+			kubernetes.Name:          "pong-a",
+			kubernetes.Namespace:     "ping",
+			kubernetes.Created:       pod1.Created(),
+			kubernetes.CPURequest:    "0.25",
+			kubernetes.CPULimit:      "0.6",
+			kubernetes.MemoryRequest: "67108864",
 		}},
 		{pod2ID, serviceID, map[string]string{
 			kubernetes.Name:      "pong-b",
@@ -229,6 +482,83 @@ func TestReporter(t *testing.T) {
 		}
 	}
 
+	// Reporter should have added the requests and limits of the containers
+	// of the pods to them, the pods only having the limits all their
+	// containers have
+	{
+		if v, ok := rpt.Pod.Nodes[pod1ID].Latest.Lookup(kubernetes.MemoryLimit); ok {
+			t.Errorf("Expected pod %s to have no memory limit, got %q", pod1ID, v)
+		}
+		want := []report.Row{
+			{ID: "pong", Entries: map[string]string{
+				kubernetes.ResourceContainer:     "pong",
+				kubernetes.ResourceCPURequest:    "0.25",
+				kubernetes.ResourceCPULimit:      "0.5",
+				kubernetes.ResourceMemoryRequest: "67108864",
+				kubernetes.ResourceMemoryLimit:   "134217728",
+			}},
+			{ID: "sidecar", Entries: map[string]string{
+				kubernetes.ResourceContainer: "sidecar",
+				kubernetes.ResourceCPULimit:  "0.1",
+			}},
+		}
+		have := rpt.Pod.Nodes[pod1ID].ExtractMulticolumnTable(kubernetes.TableTemplates[kubernetes.ResourcesPrefix])
+		if !reflect.DeepEqual(want, have) {
+			t.Errorf("Expected pod %s resources %v, got %v", pod1ID, want, have)
+		}
+	}
+
+	// Reporter should have added the autoscaler of the deployment to it
+	{
+		deploymentID := report.MakeDeploymentNodeID(deploymentUID)
+		node, ok := rpt.Deployment.Nodes[deploymentID]
+		if !ok {
+			t.Fatalf("Expected report to have deployment %q, but not found", deploymentID)
+		}
+		for k, want := range map[string]string{
+			kubernetes.Autoscaler:  "pong-autoscaler",
+			kubernetes.MinReplicas: "2",
+			kubernetes.MaxReplicas: "5",
+		} {
+			if have, ok := node.Latest.Lookup(k); !ok || have != want {
+				t.Errorf("Expected deployment %s latest %q: %q, got %q", deploymentID, k, want, have)
+			}
+		}
+		want := []report.Row{{ID: "cpu", Entries: map[string]string{
+			kubernetes.AutoscalerMetric:        "cpu",
+			kubernetes.AutoscalerMetricCurrent: "85%",
+			kubernetes.AutoscalerMetricTarget:  "80%",
+		}}}
+		have := node.ExtractMulticolumnTable(kubernetes.TableTemplates[kubernetes.AutoscalerMetricsPrefix])
+		if !reflect.DeepEqual(want, have) {
+			t.Errorf("Expected deployment %s autoscaler metrics %v, got %v", deploymentID, want, have)
+		}
+		if _, ok := node.LatestControls.Lookup(kubernetes.AutoscalerMaxUp); !ok {
+			t.Errorf("Expected deployment %s to have control %q", deploymentID, kubernetes.AutoscalerMaxUp)
+		}
+	}
+
+	// Reporter should have added the recent events of the pods to them
+	{
+		rows := rpt.Pod.Nodes[pod1ID].ExtractMulticolumnTable(kubernetes.TableTemplates[kubernetes.EventsPrefix])
+		if len(rows) != 1 {
+			t.Fatalf("Expected pod %s to have 1 recent event, got %v", pod1ID, rows)
+		}
+		for k, want := range map[string]string{
+			kubernetes.EventType:    "Warning",
+			kubernetes.EventReason:  "Unhealthy",
+			kubernetes.EventMessage: "Liveness probe failed",
+			kubernetes.EventCount:   "3",
+		} {
+			if have := rows[0].Entries[k]; have != want {
+				t.Errorf("Expected pod %s event %q: %q, got %q", pod1ID, k, want, have)
+			}
+		}
+		if rows := rpt.Pod.Nodes[pod2ID].ExtractMulticolumnTable(kubernetes.TableTemplates[kubernetes.EventsPrefix]); len(rows) != 0 {
+			t.Errorf("Expected pod %s to have no events, got %v", pod2ID, rows)
+		}
+	}
+
 	// Reporter should have added a service
 	{
 		node, ok := rpt.Service.Nodes[serviceID]
@@ -245,6 +575,120 @@ func TestReporter(t *testing.T) {
 				t.Errorf("Expected service %s latest %q: %q, got %q", serviceID, k, want, have)
 			}
 		}
+		if have, _ := node.Sets.Lookup(kubernetes.ExternalIPs); !reflect.DeepEqual(report.MakeStringSet("10.0.1.2"), have) {
+			t.Errorf("Expected service %s external IPs [10.0.1.2], got %v", serviceID, have)
+		}
+	}
+
+	// Reporter should have added the job, as a parent of its pods
+	{
+		jobID := report.MakeJobNodeID(jobUID)
+		node, ok := rpt.Job.Nodes[jobID]
+		if !ok {
+			t.Fatalf("Expected report to have job %q, but not found", jobID)
+		}
+		for k, want := range map[string]string{
+			kubernetes.NodeType:    "Job",
+			kubernetes.Completions: "3",
+			kubernetes.Parallelism: "1",
+			kubernetes.Succeeded:   "2",
+			kubernetes.Active:      "1",
+		} {
+			if have, ok := node.Latest.Lookup(k); !ok || have != want {
+				t.Errorf("Expected job %s latest %q: %q, got %q", jobID, k, want, have)
+			}
+		}
+		if parents, ok := rpt.Pod.Nodes[pod1ID].Parents.Lookup(report.Job); !ok || !parents.Contains(jobID) {
+			t.Errorf("Expected pod %s to have parent job %q, got %q", pod1ID, jobID, parents)
+		}
+		if parents, ok := rpt.Pod.Nodes[pod2ID].Parents.Lookup(report.Job); ok {
+			t.Errorf("Expected pod %s to have no parent job, got %q", pod2ID, parents)
+		}
+	}
+
+	// Reporter should have added the ingress, adjacent to the service it
+	// routes to
+	{
+		ingressID := report.MakeIngressNodeID(ingressUID)
+		node, ok := rpt.Ingress.Nodes[ingressID]
+		if !ok {
+			t.Fatalf("Expected report to have ingress %q, but not found", ingressID)
+		}
+		if want := report.MakeIDList(serviceID); !reflect.DeepEqual(want, node.Adjacency) {
+			t.Errorf("Expected ingress %s adjacency %v, got %v", ingressID, want, node.Adjacency)
+		}
+		for k, want := range map[string]report.StringSet{
+			kubernetes.IngressHosts: report.MakeStringSet("pong.example.com"),
+			kubernetes.ExternalIPs:  report.MakeStringSet("lb.example.com"),
+		} {
+			if have, _ := node.Sets.Lookup(k); !reflect.DeepEqual(want, have) {
+				t.Errorf("Expected ingress %s %s %v, got %v", ingressID, k, want, have)
+			}
+		}
+	}
+
+	// Reporter should have added the namespace, with its network policies
+	{
+		namespaceID := report.MakeNamespaceNodeID(pingUID)
+		node, ok := rpt.Namespace.Nodes[namespaceID]
+		if !ok {
+			t.Fatalf("Expected report to have namespace %q, but not found", namespaceID)
+		}
+		encoded, _ := node.Latest.Lookup(kubernetes.NetworkPolicies)
+		policies, err := kubernetes.ParsePolicies(encoded)
+		if err != nil {
+			t.Fatalf("Expected namespace %s to have network policies, got %q: %v", namespaceID, encoded, err)
+		}
+		if want := (kubernetes.Policies{policy.Policy()}); !reflect.DeepEqual(want, policies) {
+			t.Errorf("Expected namespace %s network policies %v, got %v", namespaceID, want, policies)
+		}
+	}
+
+	// Reporter should have added the custom resources, with their owners as
+	// parents, and as parents of the pods they own
+	{
+		kafkaID, topicID := report.MakeCustomResourceNodeID(kafkaUID), report.MakeCustomResourceNodeID(topicUID)
+		node, ok := rpt.CustomResource.Nodes[kafkaID]
+		if !ok {
+			t.Fatalf("Expected report to have custom resource %q, but not found", kafkaID)
+		}
+		for k, want := range map[string]string{
+			kubernetes.Name:       "pongs",
+			kubernetes.Namespace:  "ping",
+			kubernetes.NodeType:   "Kafka",
+			kubernetes.APIVersion: "kafka.strimzi.io/v1beta1",
+		} {
+			if have, ok := node.Latest.Lookup(k); !ok || have != want {
+				t.Errorf("Expected custom resource %s latest %q: %q, got %q", kafkaID, k, want, have)
+			}
+		}
+		if parents, ok := rpt.CustomResource.Nodes[topicID].Parents.Lookup(report.CustomResource); !ok || !parents.Contains(kafkaID) {
+			t.Errorf("Expected custom resource %s to have parent %q, got %q", topicID, kafkaID, parents)
+		}
+		if parents, ok := rpt.Pod.Nodes[pod2ID].Parents.Lookup(report.CustomResource); !ok || !parents.Contains(kafkaID) {
+			t.Errorf("Expected pod %s to have parent custom resource %q, got %q", pod2ID, kafkaID, parents)
+		}
+		if parents, ok := rpt.Pod.Nodes[pod1ID].Parents.Lookup(report.CustomResource); ok {
+			t.Errorf("Expected pod %s to have no parent custom resource, got %q", pod1ID, parents)
+		}
+	}
+}
+
+func TestParseCustomResourceKinds(t *testing.T) {
+	kinds, err := kubernetes.ParseCustomResourceKinds("kafka.strimzi.io/v1beta1/Kafka, acid.zalan.do/v1/postgresql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"kafka.strimzi.io/v1beta1, Kind=Kafka", "acid.zalan.do/v1, Kind=postgresql"}
+	have := []string{}
+	for _, kind := range kinds {
+		have = append(have, kind.String())
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("Expected kinds %v, got %v", want, have)
+	}
+	if _, err := kubernetes.ParseCustomResourceKinds("Kafka"); err == nil {
+		t.Errorf("Expected an error parsing a kind without its API version")
 	}
 }
 
@@ -255,7 +699,7 @@ func TestTagger(t *testing.T) {
 	}))
 
 	hr := controls.NewDefaultHandlerRegistry()
-	rpt, err := kubernetes.NewReporter(newMockClient(), nil, "", "", nil, hr, "", 0).Tag(rpt)
+	rpt, err := kubernetes.NewReporter(newMockClient(), nil, "", "", nil, hr, "", 0, "").Tag(rpt)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -267,6 +711,46 @@ func TestTagger(t *testing.T) {
 	}
 }
 
+func TestReporterCluster(t *testing.T) {
+	oldGetNodeName := kubernetes.GetLocalPodUIDs
+	defer func() { kubernetes.GetLocalPodUIDs = oldGetNodeName }()
+	kubernetes.GetLocalPodUIDs = func(string) (map[string]struct{}, error) {
+		return map[string]struct{}{pod1UID: {}}, nil
+	}
+
+	hr := controls.NewDefaultHandlerRegistry()
+	reporter := kubernetes.NewReporter(newMockClient(), nil, "", "foo", nil, hr, "", 0, "east")
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, n := range []report.Node{
+		rpt.Pod.Nodes[report.MakePodNodeID(pod1UID)],
+		rpt.Service.Nodes[report.MakeServiceNodeID(serviceUID)],
+		rpt.Namespace.Nodes[report.MakeNamespaceNodeID(pingUID)],
+		rpt.Host.Nodes[report.MakeHostNodeID("foo")],
+	} {
+		if have, ok := n.Latest.Lookup(kubernetes.Cluster); !ok || have != "east" {
+			t.Errorf("Expected node %q to be in cluster %q, got %q", n.ID, "east", have)
+		}
+	}
+
+	containers := report.MakeReport()
+	containers.Container.AddNode(report.MakeNodeWith("container1", map[string]string{
+		docker.LabelPrefix + "io.kubernetes.pod.uid": pod1UID,
+	}))
+	containers.Container.AddNode(report.MakeNode("container2"))
+	containers, err = reporter.Tag(containers)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, id := range []string{"container1", "container2"} {
+		if have, ok := containers.Container.Nodes[id].Latest.Lookup(kubernetes.Cluster); !ok || have != "east" {
+			t.Errorf("Expected container %q to be in cluster %q, got %q", id, "east", have)
+		}
+	}
+}
+
 type callbackReadCloser struct {
 	io.Reader
 	close func() error
@@ -274,6 +758,141 @@ type callbackReadCloser struct {
 
 func (c *callbackReadCloser) Close() error { return c.close() }
 
+func TestReporterMesh(t *testing.T) {
+	oldGetNodeName := kubernetes.GetLocalPodUIDs
+	defer func() { kubernetes.GetLocalPodUIDs = oldGetNodeName }()
+	kubernetes.GetLocalPodUIDs = func(string) (map[string]struct{}, error) {
+		return map[string]struct{}{pod1UID: {}}, nil
+	}
+	oldGetEnvoyStats := kubernetes.GetEnvoyStats
+	defer func() { kubernetes.GetEnvoyStats = oldGetEnvoyStats }()
+	var scraped string
+	kubernetes.GetEnvoyStats = func(podIP string) (io.ReadCloser, error) {
+		scraped = podIP
+		return ioutil.NopCloser(strings.NewReader(`# TYPE envoy_cluster_upstream_rq_total counter
+envoy_cluster_upstream_rq_total{cluster_name="outbound|9080||reviews.ping.svc.cluster.local"} 42
+envoy_cluster_upstream_rq_total{cluster_name="inbound|80||pong.ping.svc.cluster.local"} 7
+envoy_cluster_upstream_rq_total{cluster_name="xds-grpc"} 3
+# TYPE envoy_cluster_upstream_cx_total counter
+envoy_cluster_upstream_cx_total{cluster_name="outbound|9080||reviews.ping.svc.cluster.local"} 4
+# TYPE envoy_cluster_ssl_handshake counter
+envoy_cluster_ssl_handshake{cluster_name="outbound|9080||reviews.ping.svc.cluster.local"} 4
+`)), nil
+	}
+
+	meshed := apiPod1
+	meshed.Status.PodIP = "10.0.0.1"
+	meshed.Spec.Containers = append([]apiv1.Container{{Name: kubernetes.EnvoySidecar}}, apiPod1.Spec.Containers...)
+	client := newMockClient()
+	client.pods = []kubernetes.Pod{kubernetes.NewPod(&meshed)}
+
+	hr := controls.NewDefaultHandlerRegistry()
+	rpt, err := kubernetes.NewReporter(client, nil, "", "foo", nil, hr, "", 0, "").Report()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if scraped != "10.0.0.1" {
+		t.Errorf("Expected the stats of the sidecar of the pod to be scraped, got %q", scraped)
+	}
+	pod := rpt.Pod.Nodes[report.MakePodNodeID(pod1UID)]
+	if have, ok := pod.Latest.Lookup(kubernetes.Sidecar); !ok || have != kubernetes.EnvoySidecar {
+		t.Errorf("Expected sidecar %q, got %q", kubernetes.EnvoySidecar, have)
+	}
+	rows := pod.ExtractMulticolumnTable(kubernetes.TableTemplates[kubernetes.MeshPrefix])
+	want := []report.Row{
+		{ID: "inbound|80||pong.ping.svc.cluster.local", Entries: map[string]string{
+			kubernetes.MeshDirection:   kubernetes.MeshInbound,
+			kubernetes.MeshService:     "pong.ping.svc.cluster.local",
+			kubernetes.MeshPort:        "80",
+			kubernetes.MeshRequests:    "7",
+			kubernetes.MeshConnections: "0",
+			kubernetes.MeshMTLS:        "false",
+		}},
+		{ID: "outbound|9080||reviews.ping.svc.cluster.local", Entries: map[string]string{
+			kubernetes.MeshDirection:   kubernetes.MeshOutbound,
+			kubernetes.MeshService:     "reviews.ping.svc.cluster.local",
+			kubernetes.MeshPort:        "9080",
+			kubernetes.MeshRequests:    "42",
+			kubernetes.MeshConnections: "4",
+			kubernetes.MeshMTLS:        "true",
+		}},
+	}
+	if !reflect.DeepEqual(want, rows) {
+		t.Errorf("Expected mesh traffic %v, got %v", want, rows)
+	}
+}
+
+func TestReporterAdjustAutoscaler(t *testing.T) {
+	client := newMockClient()
+	hr := controls.NewDefaultHandlerRegistry()
+	reporter := kubernetes.NewReporter(client, nil, "", "", nil, hr, "", 0, "")
+
+	resp := reporter.CaptureDeployment(reporter.AdjustAutoscaler(0, 1))(xfer.Request{
+		NodeID:  report.MakeDeploymentNodeID(deploymentUID),
+		Control: kubernetes.AutoscalerMaxUp,
+	})
+	if resp.Error != "" {
+		t.Fatalf("Expected no error, got %q", resp.Error)
+	}
+	if want := []string{"ping/pong-autoscaler +0 +1"}; !reflect.DeepEqual(want, client.adjusted) {
+		t.Errorf("Expected autoscaler to be adjusted %v, got %v", want, client.adjusted)
+	}
+
+	// Should error on deployments without an autoscaler
+	client.autoscalers = nil
+	resp = reporter.CaptureDeployment(reporter.AdjustAutoscaler(0, 1))(xfer.Request{
+		NodeID:  report.MakeDeploymentNodeID(deploymentUID),
+		Control: kubernetes.AutoscalerMaxUp,
+	})
+	if want := "Horizontal pod autoscaler not found for deployment: pong-deployment"; resp.Error != want {
+		t.Errorf("Expected error %q, got %q", want, resp.Error)
+	}
+}
+
+func TestReporterScaleTo(t *testing.T) {
+	client := newMockClient()
+	hr := controls.NewDefaultHandlerRegistry()
+	reporter := kubernetes.NewReporter(client, nil, "", "", nil, hr, "", 0, "")
+
+	scaleTo := func(args map[string]string) xfer.Response {
+		return reporter.CaptureDeployment(reporter.ScaleTo)(xfer.Request{
+			NodeID:      report.MakeDeploymentNodeID(deploymentUID),
+			Control:     kubernetes.ScaleTo,
+			ControlArgs: args,
+		})
+	}
+	if resp := scaleTo(map[string]string{kubernetes.ScaleToReplicas: "5"}); resp.Error != "" {
+		t.Fatalf("Expected no error, got %q", resp.Error)
+	}
+	if want := []string{"ping/pong-deployment 5"}; !reflect.DeepEqual(want, client.scaled) {
+		t.Errorf("Expected deployment to be scaled %v, got %v", want, client.scaled)
+	}
+
+	for _, args := range []map[string]string{nil, {kubernetes.ScaleToReplicas: "five"}, {kubernetes.ScaleToReplicas: "-1"}} {
+		if resp := scaleTo(args); resp.Error == "" {
+			t.Errorf("Expected an error scaling with %v", args)
+		}
+	}
+}
+
+func TestReporterDeletePodDryRun(t *testing.T) {
+	client := newMockClient()
+	hr := controls.NewDefaultHandlerRegistry()
+	reporter := kubernetes.NewReporter(client, nil, "", "", nil, hr, "", 0, "")
+	defer reporter.Stop()
+
+	resp := hr.HandleControlRequest(xfer.Request{
+		NodeID:  report.MakePodNodeID(pod1UID),
+		Control: kubernetes.DeletePodDryRun,
+	})
+	if want := "Would delete pod ping/pong-a, stopping its containers "; resp.Error != "" || !strings.HasPrefix(fmt.Sprint(resp.Value), want) {
+		t.Errorf("Expected %q, got %#v", want, resp)
+	}
+	if len(client.deleted) != 0 {
+		t.Errorf("Expected no pods to be deleted, got %v", client.deleted)
+	}
+}
+
 func TestReporterGetLogs(t *testing.T) {
 	oldGetNodeName := kubernetes.GetLocalPodUIDs
 	defer func() { kubernetes.GetLocalPodUIDs = oldGetNodeName }()
@@ -284,7 +903,7 @@ func TestReporterGetLogs(t *testing.T) {
 	client := newMockClient()
 	pipes := mockPipeClient{}
 	hr := controls.NewDefaultHandlerRegistry()
-	reporter := kubernetes.NewReporter(client, pipes, "", "", nil, hr, "", 0)
+	reporter := kubernetes.NewReporter(client, pipes, "", "", nil, hr, "", 0, "")
 
 	// Should error on invalid IDs
 	{
@@ -352,3 +971,53 @@ func TestReporterGetLogs(t *testing.T) {
 		t.Errorf("Expected pipe to close the underlying log stream")
 	}
 }
+
+func TestReporterGetLogsOptions(t *testing.T) {
+	client := newMockClient()
+	pipes := mockPipeClient{}
+	hr := controls.NewDefaultHandlerRegistry()
+	reporter := kubernetes.NewReporter(client, pipes, "", "", nil, hr, "", 0, "")
+
+	getLogs := func(args map[string]string) xfer.Response {
+		client.logs["ping;pong-a"] = ioutil.NopCloser(strings.NewReader("starting\nerror: no database\nretrying\nerror: still no database\n"))
+		return reporter.CapturePod(reporter.GetLogs)(xfer.Request{
+			AppID:       "appID",
+			NodeID:      report.MakePodNodeID(pod1UID),
+			Control:     kubernetes.GetLogs,
+			ControlArgs: args,
+		})
+	}
+
+	resp := getLogs(map[string]string{
+		kubernetes.GetLogsContainers: "pong",
+		kubernetes.GetLogsTail:       "100",
+		kubernetes.GetLogsSince:      "60",
+		kubernetes.GetLogsTimestamps: "false",
+		kubernetes.GetLogsFilter:     "^error",
+	})
+	if resp.Error != "" {
+		t.Fatalf("Expected no error, got %q", resp.Error)
+	}
+	if want := []string{"[pong] 100 60 false"}; !reflect.DeepEqual(want, client.logOptions) {
+		t.Errorf("Expected logs with %v, got %v", want, client.logOptions)
+	}
+	_, readWriter := pipes[resp.Pipe].Ends()
+	contents, err := ioutil.ReadAll(readWriter)
+	if err != nil {
+		t.Error(err)
+	}
+	if want := "error: no database\nerror: still no database\n"; string(contents) != want {
+		t.Errorf("Expected pipe to contain %q, but got %q", want, string(contents))
+	}
+
+	for _, args := range []map[string]string{
+		{kubernetes.GetLogsContainers: "ping"},
+		{kubernetes.GetLogsTail: "-1"},
+		{kubernetes.GetLogsTimestamps: "yes"},
+		{kubernetes.GetLogsFilter: "(unclosed"},
+	} {
+		if resp := getLogs(args); resp.Error == "" {
+			t.Errorf("Expected an error getting logs with %v", args)
+		}
+	}
+}
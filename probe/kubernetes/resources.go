@@ -0,0 +1,102 @@
+package kubernetes
+
+import (
+	"strconv"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// These constants are keys used in node metadata
+const (
+	CPURequest    = report.KubernetesCPURequest
+	CPULimit      = report.KubernetesCPULimit
+	MemoryRequest = report.KubernetesMemoryRequest
+	MemoryLimit   = report.KubernetesMemoryLimit
+
+	ResourcesPrefix = "kubernetes_resources_"
+
+	ResourceContainer     = "container"
+	ResourceCPURequest    = "cpu_request"
+	ResourceCPULimit      = "cpu_limit"
+	ResourceCPUUsage      = "cpu_usage"
+	ResourceMemoryRequest = "memory_request"
+	ResourceMemoryLimit   = "memory_limit"
+	ResourceMemoryUsage   = "memory_usage"
+)
+
+// These constants are the IDs of the metrics of the usage by pods, as a
+// percentage, of their limits. They are derived by the app from the usage of
+// their containers.
+const (
+	CPULimitUsage    = "kubernetes_cpu_limit_usage"
+	MemoryLimitUsage = "kubernetes_memory_limit_usage"
+)
+
+// resources are the resources of pods reported, with their keys in node
+// metadata and columns in the resources table.
+var resources = []struct {
+	name                       apiv1.ResourceName
+	request, limit             string
+	requestColumn, limitColumn string
+}{
+	{apiv1.ResourceCPU, CPURequest, CPULimit, ResourceCPURequest, ResourceCPULimit},
+	{apiv1.ResourceMemory, MemoryRequest, MemoryLimit, ResourceMemoryRequest, ResourceMemoryLimit},
+}
+
+// resourcesLatests returns the resource requests and limits of the
+// containers of a pod, summed over them, with the CPU in cores and the
+// memory in bytes. A pod only has a limit when all its containers have one.
+func resourcesLatests(spec apiv1.PodSpec) map[string]string {
+	latests := map[string]string{}
+	for _, r := range resources {
+		var request, limit resource.Quantity
+		requested, limited := 0, 0
+		for _, c := range spec.Containers {
+			if q, ok := c.Resources.Requests[r.name]; ok {
+				request.Add(q)
+				requested++
+			}
+			if q, ok := c.Resources.Limits[r.name]; ok {
+				limit.Add(q)
+				limited++
+			}
+		}
+		if requested > 0 {
+			latests[r.request] = formatQuantity(r.name, request)
+		}
+		if limited > 0 && limited == len(spec.Containers) {
+			latests[r.limit] = formatQuantity(r.name, limit)
+		}
+	}
+	return latests
+}
+
+// resourcesRows returns the rows of the resources table of a pod, with the
+// requests and limits of each of its containers.
+func resourcesRows(spec apiv1.PodSpec) []report.Row {
+	rows := make([]report.Row, 0, len(spec.Containers))
+	for _, c := range spec.Containers {
+		entries := map[string]string{ResourceContainer: c.Name}
+		for _, r := range resources {
+			if q, ok := c.Resources.Requests[r.name]; ok {
+				entries[r.requestColumn] = formatQuantity(r.name, q)
+			}
+			if q, ok := c.Resources.Limits[r.name]; ok {
+				entries[r.limitColumn] = formatQuantity(r.name, q)
+			}
+		}
+		rows = append(rows, report.Row{ID: c.Name, Entries: entries})
+	}
+	return rows
+}
+
+// formatQuantity formats CPU in cores and memory in bytes.
+func formatQuantity(name apiv1.ResourceName, q resource.Quantity) string {
+	if name == apiv1.ResourceCPU {
+		return strconv.FormatFloat(float64(q.MilliValue())/1000, 'f', -1, 64)
+	}
+	return strconv.FormatInt(q.Value(), 10)
+}
@@ -18,6 +18,7 @@ type Service interface {
 	GetNode() report.Node
 	Selector() labels.Selector
 	ClusterIP() string
+	ExternalIPs() report.StringSet
 }
 
 type service struct {
@@ -42,7 +43,24 @@ func (s *service) GetNode() report.Node {
 	if s.Spec.LoadBalancerIP != "" {
 		latest[PublicIP] = s.Spec.LoadBalancerIP
 	}
-	return s.MetaNode(report.MakeServiceNodeID(s.UID())).WithLatests(latest)
+	node := s.MetaNode(report.MakeServiceNodeID(s.UID())).WithLatests(latest)
+	if ips := s.ExternalIPs(); len(ips) > 0 {
+		node = node.WithSets(report.MakeSets().Add(ExternalIPs, ips))
+	}
+	return node
+}
+
+// ExternalIPs returns the addresses at which the service takes traffic in
+// from outside the cluster: its external IPs and those of its load balancer.
+func (s *service) ExternalIPs() report.StringSet {
+	result := report.MakeStringSet(s.Spec.ExternalIPs...)
+	if s.Spec.Type == apiv1.ServiceTypeLoadBalancer {
+		if s.Spec.LoadBalancerIP != "" {
+			result = result.Add(s.Spec.LoadBalancerIP)
+		}
+		result = result.Merge(loadBalancerAddresses(s.Status.LoadBalancer))
+	}
+	return result
 }
 
 func (s *service) ClusterIP() string {
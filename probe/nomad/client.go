@@ -0,0 +1,162 @@
+package nomad
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Client for the HTTP API of a Nomad agent. We create an interface so we
+// can mock for testing.
+type Client interface {
+	// Returns the ID of the node of the agent, since it's a client.
+	NodeID() (string, error)
+	// Returns the allocations of the node.
+	Allocations(nodeID string) ([]Allocation, error)
+	// Stops a job, and with it all its allocations.
+	StopJob(namespace, jobID string) error
+	// Sets the count of a task group of a job.
+	ScaleTaskGroup(namespace, jobID, group string, count int) error
+	// Restarts all the tasks of an allocation.
+	RestartAllocation(allocID string) error
+}
+
+// Allocation is the part of a Nomad allocation we care about.
+type Allocation struct {
+	ID            string
+	Name          string // e.g. "web.frontend[0]"
+	Namespace     string
+	JobID         string
+	TaskGroup     string
+	ClientStatus  string // e.g. "pending", "running" or "complete"
+	DesiredStatus string // e.g. "run" or "stop"
+	CreateTime    int64  // in nanoseconds since the epoch
+	Job           Job
+}
+
+// Job is the part of a Nomad job we care about.
+type Job struct {
+	ID         string
+	Name       string
+	Namespace  string
+	Type       string // e.g. "service", "batch" or "system"
+	Status     string // e.g. "running" or "dead"
+	TaskGroups []TaskGroup
+}
+
+// TaskGroup is the part of a task group of a Nomad job we care about.
+type TaskGroup struct {
+	Name  string
+	Count int
+}
+
+// Terminal returns true if the allocation has stopped running, or never
+// will.
+func (a Allocation) Terminal() bool {
+	switch a.ClientStatus {
+	case "complete", "failed", "lost":
+		return true
+	}
+	return a.DesiredStatus == "stop" || a.DesiredStatus == "evict"
+}
+
+// requestTimeout is how long requests to the agent may take, so a stuck
+// agent doesn't hold up reports.
+const requestTimeout = 10 * time.Second
+
+type client struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewClient makes a new Client, talking to the agent at the given URL,
+// e.g. http://127.0.0.1:4646, with the given ACL token, if any.
+func NewClient(url, token string) Client {
+	return &client{
+		url:    strings.TrimSuffix(url, "/"),
+		token:  token,
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (c *client) do(method, path string, query url.Values, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		var buf []byte
+		if err := codec.NewEncoderBytes(&buf, &codec.JsonHandle{}).Encode(in); err != nil {
+			return err
+		}
+		body = bytes.NewReader(buf)
+	}
+	u := c.url + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Add("X-Nomad-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: got %d", method, path, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(out)
+}
+
+func (c *client) NodeID() (string, error) {
+	var self struct {
+		Stats struct {
+			Client struct {
+				NodeID string `codec:"node_id"`
+			} `codec:"client"`
+		} `codec:"stats"`
+	}
+	if err := c.do("GET", "/v1/agent/self", nil, nil, &self); err != nil {
+		return "", err
+	}
+	if self.Stats.Client.NodeID == "" {
+		return "", fmt.Errorf("agent at %s isn't a client", c.url)
+	}
+	return self.Stats.Client.NodeID, nil
+}
+
+func (c *client) Allocations(nodeID string) ([]Allocation, error) {
+	var allocations []Allocation
+	err := c.do("GET", "/v1/node/"+url.PathEscape(nodeID)+"/allocations", nil, nil, &allocations)
+	return allocations, err
+}
+
+func (c *client) StopJob(namespace, jobID string) error {
+	return c.do("DELETE", "/v1/job/"+url.PathEscape(jobID), url.Values{"namespace": {namespace}}, nil, nil)
+}
+
+func (c *client) ScaleTaskGroup(namespace, jobID, group string, count int) error {
+	req := map[string]interface{}{
+		"Count":  count,
+		"Target": map[string]string{"Group": group},
+	}
+	return c.do("POST", "/v1/job/"+url.PathEscape(jobID)+"/scale", url.Values{"namespace": {namespace}}, req, nil)
+}
+
+func (c *client) RestartAllocation(allocID string) error {
+	return c.do("PUT", "/v1/client/allocation/"+url.PathEscape(allocID)+"/restart", nil, struct{}{}, nil)
+}
@@ -0,0 +1,369 @@
+package nomad
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/weaveworks/common/fs"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys for use in Node.Latest.
+const (
+	Namespace        = report.NomadNamespace
+	JobName          = report.NomadJobName
+	JobType          = report.NomadJobType
+	JobStatus        = report.NomadJobStatus
+	TaskGroupCount   = report.NomadTaskGroupCount
+	AllocationName   = report.NomadAllocationName
+	AllocationStatus = report.NomadAllocationStatus
+	CreatedAt        = report.NomadAllocationCreatedAt
+)
+
+// Control IDs used by the Nomad integration.
+const (
+	StopJob           = report.NomadStopJob
+	ScaleUp           = report.NomadScaleUp
+	ScaleDown         = report.NomadScaleDown
+	RestartAllocation = report.NomadRestartAllocation
+)
+
+// The label the Nomad docker driver puts the ID of the allocation of a task
+// in.
+const allocIDLabel = "com.hashicorp.nomad.alloc_id"
+
+// Exposed for testing.
+var (
+	JobMetadataTemplates = report.MetadataTemplates{
+		JobName:   {ID: JobName, Label: "Name", From: report.FromLatest, Priority: 1},
+		Namespace: {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 2},
+		JobType:   {ID: JobType, Label: "Type", From: report.FromLatest, Priority: 3},
+		JobStatus: {ID: JobStatus, Label: "Status", From: report.FromLatest, Priority: 4},
+	}
+	TaskGroupMetadataTemplates = report.MetadataTemplates{
+		JobName:        {ID: JobName, Label: "Job", From: report.FromLatest, Priority: 1},
+		Namespace:      {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 2},
+		TaskGroupCount: {ID: TaskGroupCount, Label: "Count", From: report.FromLatest, Datatype: report.Number, Priority: 3},
+	}
+	AllocationMetadataTemplates = report.MetadataTemplates{
+		AllocationName:   {ID: AllocationName, Label: "Name", From: report.FromLatest, Priority: 1},
+		Namespace:        {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 2},
+		AllocationStatus: {ID: AllocationStatus, Label: "Status", From: report.FromLatest, Priority: 3},
+		CreatedAt:        {ID: CreatedAt, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 4},
+	}
+
+	JobControls = []report.Control{
+		{
+			ID:    StopJob,
+			Human: "Stop",
+			Icon:  "fa-stop",
+			Rank:  0,
+		},
+	}
+	TaskGroupControls = []report.Control{
+		{
+			ID:    ScaleDown,
+			Human: "Scale Down",
+			Icon:  "fa-minus",
+			Rank:  0,
+		},
+		{
+			ID:    ScaleUp,
+			Human: "Scale Up",
+			Icon:  "fa-plus",
+			Rank:  1,
+		},
+	}
+	AllocationControls = []report.Control{
+		{
+			ID:    RestartAllocation,
+			Human: "Restart",
+			Icon:  "fa-repeat",
+			Rank:  0,
+		},
+	}
+)
+
+// Reporter generates Reports containing the NomadJob, NomadTaskGroup and
+// NomadAllocation topologies of the allocations running on the Nomad client
+// of the host, and tags the containers and processes of allocations with
+// them.
+type Reporter struct {
+	client          Client
+	hostID          string
+	probeID         string
+	procRoot        string
+	handlerRegistry *controls.HandlerRegistry
+
+	mtx    sync.Mutex
+	nodeID string         // of the Nomad client, once we know it
+	counts map[string]int // task group node ID -> count, as last reported
+}
+
+// NewReporter makes a new Reporter. Don't forget to Stop it.
+func NewReporter(client Client, hostID, probeID, procRoot string, handlerRegistry *controls.HandlerRegistry) *Reporter {
+	r := &Reporter{
+		client:          client,
+		hostID:          hostID,
+		probeID:         probeID,
+		procRoot:        procRoot,
+		handlerRegistry: handlerRegistry,
+		counts:          map[string]int{},
+	}
+	r.handlerRegistry.Batch(nil, map[string]xfer.ControlHandlerFunc{
+		StopJob:           r.stopJob,
+		ScaleUp:           r.scaleUp,
+		ScaleDown:         r.scaleDown,
+		RestartAllocation: r.restartAllocation,
+	})
+	return r
+}
+
+// Stop deregisters the controls of the reporter.
+func (r *Reporter) Stop() {
+	r.handlerRegistry.Batch([]string{
+		StopJob,
+		ScaleUp,
+		ScaleDown,
+		RestartAllocation,
+	}, nil)
+}
+
+// Name of this reporter, for metrics gathering
+func (*Reporter) Name() string { return "Nomad" }
+
+// Report implements Reporter. Allocations which stopped, or are being
+// stopped, are left out, and with them the jobs and task groups which have
+// no others here.
+func (r *Reporter) Report() (report.Report, error) {
+	result := report.MakeReport()
+	result.NomadJob = result.NomadJob.WithMetadataTemplates(JobMetadataTemplates)
+	result.NomadJob.Controls.AddControls(JobControls)
+	result.NomadTaskGroup = result.NomadTaskGroup.WithMetadataTemplates(TaskGroupMetadataTemplates)
+	result.NomadTaskGroup.Controls.AddControls(TaskGroupControls)
+	result.NomadAllocation = result.NomadAllocation.WithMetadataTemplates(AllocationMetadataTemplates)
+	result.NomadAllocation.Controls.AddControls(AllocationControls)
+
+	nodeID, err := r.getNodeID()
+	if err != nil {
+		return result, err
+	}
+	allocations, err := r.client.Allocations(nodeID)
+	if err != nil {
+		return result, err
+	}
+
+	hostNodeID := report.MakeHostNodeID(r.hostID)
+	counts := map[string]int{}
+	for _, alloc := range allocations {
+		if alloc.Terminal() {
+			continue
+		}
+		jobNodeID := report.MakeNomadJobNodeID(alloc.Namespace, alloc.JobID)
+		groupNodeID := report.MakeNomadTaskGroupNodeID(alloc.Namespace, alloc.JobID, alloc.TaskGroup)
+		jobName := alloc.Job.Name
+		if jobName == "" {
+			jobName = alloc.JobID
+		}
+
+		result.NomadJob.AddNode(report.MakeNodeWith(jobNodeID, map[string]string{
+			JobName:               jobName,
+			Namespace:             alloc.Namespace,
+			JobType:               alloc.Job.Type,
+			JobStatus:             alloc.Job.Status,
+			report.ControlProbeID: r.probeID,
+		}).WithLatestActiveControls(StopJob))
+
+		group := report.MakeNodeWith(groupNodeID, map[string]string{
+			JobName:               jobName,
+			Namespace:             alloc.Namespace,
+			report.ControlProbeID: r.probeID,
+		}).WithParents(report.MakeSets().Add(report.NomadJob, report.MakeStringSet(jobNodeID)))
+		for _, tg := range alloc.Job.TaskGroups {
+			if tg.Name != alloc.TaskGroup {
+				continue
+			}
+			counts[groupNodeID] = tg.Count
+			group = group.WithLatests(map[string]string{
+				TaskGroupCount: strconv.Itoa(tg.Count),
+			}).WithLatestControls(map[string]report.NodeControlData{
+				ScaleUp: {Dead: false},
+				// Like for ECS services, don't scale down to 0, which would
+				// make the task group disappear.
+				ScaleDown: {Dead: tg.Count <= 1},
+			})
+		}
+		result.NomadTaskGroup.AddNode(group)
+
+		result.NomadAllocation.AddNode(report.MakeNodeWith(report.MakeNomadAllocationNodeID(alloc.ID), map[string]string{
+			AllocationName:        alloc.Name,
+			Namespace:             alloc.Namespace,
+			AllocationStatus:      alloc.ClientStatus,
+			CreatedAt:             time.Unix(0, alloc.CreateTime).UTC().Format(time.RFC3339Nano),
+			report.HostNodeID:     hostNodeID,
+			report.ControlProbeID: r.probeID,
+		}).
+			WithParents(report.MakeSets().
+				Add(report.NomadJob, report.MakeStringSet(jobNodeID)).
+				Add(report.NomadTaskGroup, report.MakeStringSet(groupNodeID)).
+				Add(report.Host, report.MakeStringSet(hostNodeID))).
+			WithLatestActiveControls(RestartAllocation))
+	}
+
+	r.mtx.Lock()
+	r.counts = counts
+	r.mtx.Unlock()
+	return result, nil
+}
+
+// getNodeID returns the ID of the Nomad client of the host, asking the agent
+// for it until it answers.
+func (r *Reporter) getNodeID() (string, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.nodeID != "" {
+		return r.nodeID, nil
+	}
+	nodeID, err := r.client.NodeID()
+	if err != nil {
+		return "", err
+	}
+	r.nodeID = nodeID
+	return nodeID, nil
+}
+
+// Tag implements Tagger, making the allocations parents of the containers
+// the docker driver runs for them, and of the processes the other drivers
+// run in their cgroups.
+func (r *Reporter) Tag(rpt report.Report) (report.Report, error) {
+	for id, n := range rpt.Container.Nodes {
+		allocID, ok := n.Latest.Lookup(docker.LabelPrefix + allocIDLabel)
+		if !ok {
+			continue
+		}
+		if parents, ok := allocationParents(rpt, allocID); ok {
+			rpt.Container.Nodes[id] = n.WithParents(n.Parents.Merge(parents))
+		}
+	}
+
+	for id, n := range rpt.Process.Nodes {
+		hostID, pid, ok := report.ParseProcessNodeID(id)
+		if !ok || hostID != r.hostID {
+			continue
+		}
+		cgroup, err := fs.ReadFile(filepath.Join(r.procRoot, pid, "cgroup"))
+		if err != nil {
+			continue
+		}
+		allocID := allocationOf(cgroup)
+		if allocID == "" {
+			continue
+		}
+		if parents, ok := allocationParents(rpt, allocID); ok {
+			rpt.Process.Nodes[id] = n.WithParents(n.Parents.Merge(parents))
+		}
+	}
+	return rpt, nil
+}
+
+// allocationParents returns the parents of the containers and processes of
+// an allocation, if it was reported: the allocation, its task group and its
+// job.
+func allocationParents(rpt report.Report, allocID string) (report.Sets, bool) {
+	allocNodeID := report.MakeNomadAllocationNodeID(allocID)
+	alloc, ok := rpt.NomadAllocation.Nodes[allocNodeID]
+	if !ok {
+		return report.MakeSets(), false
+	}
+	parents := report.MakeSets().Add(report.NomadAllocation, report.MakeStringSet(allocNodeID))
+	for _, topology := range []string{report.NomadTaskGroup, report.NomadJob} {
+		if ids, ok := alloc.Parents.Lookup(topology); ok {
+			parents = parents.Add(topology, ids)
+		}
+	}
+	return parents, true
+}
+
+// The length of the IDs of allocations, which are UUIDs.
+const allocIDLength = 36
+
+// allocationOf returns the ID of the allocation a process runs for, from its
+// cgroups: that of the cgroup the exec and java drivers make for the task,
+// e.g. "<alloc ID>.<task>.scope" of "0::/nomad.slice/<alloc ID>.<task>.scope"
+// (v2) or "<alloc ID>-<task>" of "4:memory:/nomad/<alloc ID>-<task>" (v1).
+func allocationOf(cgroup []byte) string {
+	for _, line := range strings.Split(string(cgroup), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		parts := strings.Split(fields[2], "/")
+		for i, part := range parts {
+			if i == 0 || (parts[i-1] != "nomad" && parts[i-1] != "nomad.slice") {
+				continue
+			}
+			if len(part) > allocIDLength && (part[allocIDLength] == '.' || part[allocIDLength] == '-') {
+				return part[:allocIDLength]
+			}
+		}
+	}
+	return ""
+}
+
+func (r *Reporter) stopJob(req xfer.Request) xfer.Response {
+	namespace, jobID, ok := report.ParseNomadJobNodeID(req.NodeID)
+	if !ok {
+		return xfer.ResponseErrorf("Invalid ID: %s", req.NodeID)
+	}
+	log.Infof("Stopping Nomad job %s of namespace %s", jobID, namespace)
+	if err := r.client.StopJob(namespace, jobID); err != nil {
+		return xfer.ResponseError(fmt.Errorf("stopping %s: %v", jobID, err))
+	}
+	return xfer.Response{}
+}
+
+func (r *Reporter) scaleUp(req xfer.Request) xfer.Response {
+	return xfer.ResponseError(r.scale(req, 1))
+}
+
+func (r *Reporter) scaleDown(req xfer.Request) xfer.Response {
+	return xfer.ResponseError(r.scale(req, -1))
+}
+
+func (r *Reporter) scale(req xfer.Request, amount int) error {
+	namespace, jobID, group, ok := report.ParseNomadTaskGroupNodeID(req.NodeID)
+	if !ok {
+		return fmt.Errorf("Invalid ID: %s", req.NodeID)
+	}
+	r.mtx.Lock()
+	count, ok := r.counts[req.NodeID]
+	r.mtx.Unlock()
+	if !ok {
+		return fmt.Errorf("Unknown task group: %s", req.NodeID)
+	}
+	if count+amount < 1 {
+		return fmt.Errorf("Can't scale %s of %s down to 0", group, jobID)
+	}
+	return r.client.ScaleTaskGroup(namespace, jobID, group, count+amount)
+}
+
+func (r *Reporter) restartAllocation(req xfer.Request) xfer.Response {
+	allocID, ok := report.ParseNomadAllocationNodeID(req.NodeID)
+	if !ok {
+		return xfer.ResponseErrorf("Invalid ID: %s", req.NodeID)
+	}
+	log.Infof("Restarting Nomad allocation %s", allocID)
+	if err := r.client.RestartAllocation(allocID); err != nil {
+		return xfer.ResponseError(fmt.Errorf("restarting %s: %v", allocID, err))
+	}
+	return xfer.Response{}
+}
@@ -0,0 +1,180 @@
+package nomad_test
+
+import (
+	"fmt"
+	"testing"
+
+	fs_hook "github.com/weaveworks/common/fs"
+	"github.com/weaveworks/common/test/fs"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/nomad"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+const (
+	webAlloc   = "8b1f2a3c-4d5e-6f70-8192-a3b4c5d6e7f8"
+	cacheAlloc = "1a2b3c4d-5e6f-7081-92a3-b4c5d6e7f809"
+	oldAlloc   = "00000000-1111-2222-3333-444444444444"
+)
+
+var webJob = nomad.Job{
+	ID:        "web",
+	Name:      "web",
+	Namespace: "default",
+	Type:      "service",
+	Status:    "running",
+	TaskGroups: []nomad.TaskGroup{
+		{Name: "frontend", Count: 2},
+		{Name: "cache", Count: 1},
+	},
+}
+
+type mockClient struct {
+	allocations []nomad.Allocation
+	calls       []string
+}
+
+func (c *mockClient) NodeID() (string, error) {
+	return "node1", nil
+}
+
+func (c *mockClient) Allocations(nodeID string) ([]nomad.Allocation, error) {
+	if nodeID != "node1" {
+		return nil, fmt.Errorf("unknown node %s", nodeID)
+	}
+	return c.allocations, nil
+}
+
+func (c *mockClient) StopJob(namespace, jobID string) error {
+	c.calls = append(c.calls, fmt.Sprintf("stop %s/%s", namespace, jobID))
+	return nil
+}
+
+func (c *mockClient) ScaleTaskGroup(namespace, jobID, group string, count int) error {
+	c.calls = append(c.calls, fmt.Sprintf("scale %s/%s/%s %d", namespace, jobID, group, count))
+	return nil
+}
+
+func (c *mockClient) RestartAllocation(allocID string) error {
+	c.calls = append(c.calls, "restart "+allocID)
+	return nil
+}
+
+var mockFS = fs.Dir("",
+	fs.Dir("proc",
+		// a task of the exec driver, on cgroup v2
+		fs.Dir("10", fs.File{FName: "cgroup", FContents: "0::/nomad.slice/" + cacheAlloc + ".redis.scope\n"}),
+		// on cgroup v1
+		fs.Dir("20", fs.File{FName: "cgroup", FContents: "4:memory:/nomad/" + cacheAlloc + "-redis\n1:name=systemd:/system.slice/nomad.service\n"}),
+		// the Nomad agent itself
+		fs.Dir("30", fs.File{FName: "cgroup", FContents: "0::/system.slice/nomad.service\n"}),
+		// a task of an allocation which isn't running any more
+		fs.Dir("40", fs.File{FName: "cgroup", FContents: "0::/nomad.slice/" + oldAlloc + ".redis.scope\n"}),
+	),
+)
+
+func TestReporter(t *testing.T) {
+	fs_hook.Mock(mockFS)
+	defer fs_hook.Restore()
+
+	client := &mockClient{
+		allocations: []nomad.Allocation{
+			{ID: webAlloc, Name: "web.frontend[0]", Namespace: "default", JobID: "web", TaskGroup: "frontend", ClientStatus: "running", DesiredStatus: "run", Job: webJob},
+			{ID: cacheAlloc, Name: "web.cache[0]", Namespace: "default", JobID: "web", TaskGroup: "cache", ClientStatus: "pending", DesiredStatus: "run", Job: webJob},
+			{ID: oldAlloc, Name: "web.cache[0]", Namespace: "default", JobID: "web", TaskGroup: "cache", ClientStatus: "complete", DesiredStatus: "stop", Job: webJob},
+		},
+	}
+	hr := controls.NewDefaultHandlerRegistry()
+	reporter := nomad.NewReporter(client, "host1", "probe1", "/proc", hr)
+	defer reporter.Stop()
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobID := report.MakeNomadJobNodeID("default", "web")
+	frontendID := report.MakeNomadTaskGroupNodeID("default", "web", "frontend")
+	cacheID := report.MakeNomadTaskGroupNodeID("default", "web", "cache")
+	if len(rpt.NomadJob.Nodes) != 1 || len(rpt.NomadTaskGroup.Nodes) != 2 || len(rpt.NomadAllocation.Nodes) != 2 {
+		t.Fatalf("Expected 1 job, 2 task groups and 2 allocations, got %d, %d and %d", len(rpt.NomadJob.Nodes), len(rpt.NomadTaskGroup.Nodes), len(rpt.NomadAllocation.Nodes))
+	}
+	if have, _ := rpt.NomadJob.Nodes[jobID].Latest.Lookup(nomad.JobType); have != "service" {
+		t.Errorf("Expected a service job, got %q", have)
+	}
+	if have, _ := rpt.NomadTaskGroup.Nodes[frontendID].Latest.Lookup(nomad.TaskGroupCount); have != "2" {
+		t.Errorf("Expected a count of 2, got %q", have)
+	}
+	if have, _ := rpt.NomadTaskGroup.Nodes[cacheID].LatestControls.Lookup(nomad.ScaleDown); !have.Dead {
+		t.Errorf("Expected scaling down a task group of 1 disabled")
+	}
+	alloc := rpt.NomadAllocation.Nodes[report.MakeNomadAllocationNodeID(webAlloc)]
+	for topology, want := range map[string]report.StringSet{
+		report.NomadJob:       report.MakeStringSet(jobID),
+		report.NomadTaskGroup: report.MakeStringSet(frontendID),
+		report.Host:           report.MakeStringSet(report.MakeHostNodeID("host1")),
+	} {
+		if have, _ := alloc.Parents.Lookup(topology); !reflect.DeepEqual(want, have) {
+			t.Errorf("Expected %s parents %v, got %v", topology, want, have)
+		}
+	}
+
+	rpt.Container.AddNode(report.MakeNodeWith("c1", map[string]string{
+		docker.LabelPrefix + "com.hashicorp.nomad.alloc_id": webAlloc,
+	}))
+	rpt.Container.AddNode(report.MakeNode("c2"))
+	for _, pid := range []string{"10", "20", "30", "40"} {
+		rpt.Process.AddNode(report.MakeNode(report.MakeProcessNodeID("host1", pid)))
+	}
+	rpt, err = reporter.Tag(rpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]report.StringSet{
+		"c1": report.MakeStringSet(report.MakeNomadAllocationNodeID(webAlloc)),
+		"c2": nil,
+	} {
+		if have, _ := rpt.Container.Nodes[id].Parents.Lookup(report.NomadAllocation); !reflect.DeepEqual(want, have) {
+			t.Errorf("Expected container %s in allocations %v, got %v", id, want, have)
+		}
+	}
+	if have, _ := rpt.Container.Nodes["c1"].Parents.Lookup(report.NomadJob); !reflect.DeepEqual(report.MakeStringSet(jobID), have) {
+		t.Errorf("Expected container c1 in job %s, got %v", jobID, have)
+	}
+	for pid, want := range map[string]report.StringSet{
+		"10": report.MakeStringSet(report.MakeNomadAllocationNodeID(cacheAlloc)),
+		"20": report.MakeStringSet(report.MakeNomadAllocationNodeID(cacheAlloc)),
+		"30": nil,
+		"40": nil,
+	} {
+		have, _ := rpt.Process.Nodes[report.MakeProcessNodeID("host1", pid)].Parents.Lookup(report.NomadAllocation)
+		if !reflect.DeepEqual(want, have) {
+			t.Errorf("Expected process %s in allocations %v, got %v", pid, want, have)
+		}
+	}
+
+	for _, req := range []xfer.Request{
+		{Control: nomad.StopJob, NodeID: jobID},
+		{Control: nomad.ScaleUp, NodeID: frontendID},
+		{Control: nomad.ScaleDown, NodeID: frontendID},
+		{Control: nomad.RestartAllocation, NodeID: report.MakeNomadAllocationNodeID(webAlloc)},
+	} {
+		if resp := hr.HandleControlRequest(req); resp.Error != "" {
+			t.Errorf("Expected %s of %s to succeed, got %v", req.Control, req.NodeID, resp.Error)
+		}
+	}
+	if resp := hr.HandleControlRequest(xfer.Request{Control: nomad.ScaleDown, NodeID: cacheID}); resp.Error == "" {
+		t.Errorf("Expected an error scaling a task group down to 0")
+	}
+	want := []string{
+		"stop default/web",
+		"scale default/web/frontend 3",
+		"scale default/web/frontend 1",
+		"restart " + webAlloc,
+	}
+	if !reflect.DeepEqual(want, client.calls) {
+		t.Errorf("Expected calls %v, got %v", want, client.calls)
+	}
+}
@@ -145,8 +145,9 @@ var (
 // overlay -- though I'm not sure what that would look like in practice right
 // now.
 type Weave struct {
-	client weave.Client
-	hostID string
+	client  weave.Client
+	hostID  string
+	cluster string // scoping the IPs of containers, if any
 
 	mtx         sync.RWMutex
 	statusCache weave.Status
@@ -158,10 +159,11 @@ type Weave struct {
 
 // NewWeave returns a new Weave tagger based on the Weave router at
 // address. The address should be an IP or FQDN, no port.
-func NewWeave(hostID string, client weave.Client) (*Weave, error) {
+func NewWeave(hostID, cluster string, client weave.Client) (*Weave, error) {
 	w := &Weave{
 		client:  client,
 		hostID:  hostID,
+		cluster: cluster,
 		psCache: map[string]weave.PSEntry{},
 	}
 
@@ -253,7 +255,7 @@ func (w *Weave) Tag(r report.Report) (report.Report, error) {
 
 		ipsWithScope := report.MakeStringSet()
 		for _, ip := range entry.IPs {
-			ipsWithScope = ipsWithScope.Add(report.MakeAddressNodeID("", ip))
+			ipsWithScope = ipsWithScope.Add(report.MakeClusterAddressNodeID(w.cluster, "", ip))
 		}
 		node = node.WithSet(docker.ContainerIPs, report.MakeStringSet(entry.IPs...))
 		node = node.WithSet(docker.ContainerIPsWithScopes, ipsWithScope)
@@ -19,7 +19,7 @@ const (
 )
 
 func runTest(t *testing.T, f func(*overlay.Weave)) {
-	w, err := overlay.NewWeave(mockHostID, weave.MockClient{})
+	w, err := overlay.NewWeave(mockHostID, "", weave.MockClient{})
 	if err != nil {
 		t.Fatal(err)
 	}
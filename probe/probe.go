@@ -6,6 +6,8 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/armon/go-metrics"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 
 	"github.com/weaveworks/scope/probe/appclient"
 	"github.com/weaveworks/scope/report"
@@ -112,7 +114,9 @@ func (p *Probe) Stop() {
 // Publish will queue a report for immediate publication,
 // bypassing the spy tick
 func (p *Probe) Publish(rpt report.Report) {
-	rpt = p.tag(rpt)
+	span := opentracing.StartSpan("probe shortcut report")
+	rpt = p.tag(span, rpt)
+	span.Finish()
 	p.shortcutReports <- rpt
 }
 
@@ -124,9 +128,11 @@ func (p *Probe) spyLoop() {
 		select {
 		case <-spyTick:
 			t := time.Now()
-			p.tick()
-			rpt := p.report()
-			rpt = p.tag(rpt)
+			span := opentracing.StartSpan("probe report")
+			p.tick(span)
+			rpt := p.report(span)
+			rpt = p.tag(span, rpt)
+			span.Finish()
 			p.spiedReports <- rpt
 			metrics.MeasureSince([]string{"Report Generaton"}, t)
 		case <-p.quit:
@@ -135,10 +141,12 @@ func (p *Probe) spyLoop() {
 	}
 }
 
-func (p *Probe) tick() {
+func (p *Probe) tick(parent opentracing.Span) {
 	for _, ticker := range p.tickers {
 		t := time.Now()
+		span := opentracing.StartSpan(ticker.Name()+" ticker", opentracing.ChildOf(parent.Context()))
 		err := ticker.Tick()
+		span.Finish()
 		metrics.MeasureSince([]string{ticker.Name(), "ticker"}, t)
 		if err != nil {
 			log.Errorf("error doing ticker: %v", err)
@@ -146,13 +154,15 @@ func (p *Probe) tick() {
 	}
 }
 
-func (p *Probe) report() report.Report {
+func (p *Probe) report(parent opentracing.Span) report.Report {
 	reports := make(chan report.Report, len(p.reporters))
 	for _, rep := range p.reporters {
 		go func(rep Reporter) {
 			t := time.Now()
 			timer := time.AfterFunc(p.spyInterval, func() { log.Warningf("%v reporter took longer than %v", rep.Name(), p.spyInterval) })
+			span := opentracing.StartSpan(rep.Name()+" reporter", opentracing.ChildOf(parent.Context()))
 			newReport, err := rep.Report()
+			span.Finish()
 			if !timer.Stop() {
 				log.Warningf("%v reporter took %v (longer than %v)", rep.Name(), time.Now().Sub(t), p.spyInterval)
 			}
@@ -172,12 +182,14 @@ func (p *Probe) report() report.Report {
 	return result
 }
 
-func (p *Probe) tag(r report.Report) report.Report {
+func (p *Probe) tag(parent opentracing.Span, r report.Report) report.Report {
 	var err error
 	for _, tagger := range p.taggers {
 		t := time.Now()
 		timer := time.AfterFunc(p.spyInterval, func() { log.Warningf("%v tagger took longer than %v", tagger.Name(), p.spyInterval) })
+		span := opentracing.StartSpan(tagger.Name()+" tagger", opentracing.ChildOf(parent.Context()))
 		r, err = tagger.Tag(r)
+		span.Finish()
 		if !timer.Stop() {
 			log.Warningf("%v tagger took %v (longer than %v)", tagger.Name(), time.Now().Sub(t), p.spyInterval)
 		}
@@ -200,7 +212,11 @@ ForLoop:
 		}
 	}
 
+	span := opentracing.StartSpan("probe publish")
+	defer span.Finish()
+	span.SetTag("shortcut", rpt.Shortcut)
 	if err := p.publisher.Publish(rpt.BackwardCompatible()); err != nil {
+		ext.Error.Set(span, true)
 		log.Infof("publish: %v", err)
 	}
 }
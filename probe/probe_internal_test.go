@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/ugorji/go/codec"
 	"github.com/weaveworks/common/mtime"
 	"github.com/weaveworks/scope/report"
@@ -24,7 +25,7 @@ func TestApply(t *testing.T) {
 
 	r := report.MakeReport()
 	r.Endpoint.AddNode(endpointNode)
-	r = p.tag(r)
+	r = p.tag(opentracing.StartSpan("test"), r)
 
 	for _, tuple := range []struct {
 		want report.Node
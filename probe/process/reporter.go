@@ -15,9 +15,14 @@ const (
 	PPID           = report.PPID
 	Cmdline        = report.Cmdline
 	Threads        = report.Threads
+	ExeSHA256      = "exe_sha256"
 	CPUUsage       = "process_cpu_usage_percent"
 	MemoryUsage    = "process_memory_usage_bytes"
 	OpenFilesCount = "open_files_count"
+	ThreadsCount   = "threads_count"
+
+	VoluntaryCtxtSwitches   = "voluntary_ctxt_switches"
+	InvoluntaryCtxtSwitches = "involuntary_ctxt_switches"
 )
 
 // Exposed for testing
@@ -26,13 +31,18 @@ var (
 		PID:     {ID: PID, Label: "PID", From: report.FromLatest, Datatype: report.Number, Priority: 1},
 		Cmdline: {ID: Cmdline, Label: "Command", From: report.FromLatest, Priority: 2},
 		PPID:    {ID: PPID, Label: "Parent PID", From: report.FromLatest, Datatype: report.Number, Priority: 3},
-		Threads: {ID: Threads, Label: "# Threads", From: report.FromLatest, Datatype: report.Number, Priority: 4},
+
+		ExeSHA256: {ID: ExeSHA256, Label: "Executable SHA-256", From: report.FromLatest, Priority: 4},
 	}
 
 	MetricTemplates = report.MetricTemplates{
 		CPUUsage:       {ID: CPUUsage, Label: "CPU", Format: report.PercentFormat, Priority: 1},
 		MemoryUsage:    {ID: MemoryUsage, Label: "Memory", Format: report.FilesizeFormat, Priority: 2},
 		OpenFilesCount: {ID: OpenFilesCount, Label: "Open Files", Format: report.IntegerFormat, Priority: 3},
+		ThreadsCount:   {ID: ThreadsCount, Label: "Threads", Format: report.IntegerFormat, Priority: 4},
+
+		VoluntaryCtxtSwitches:   {ID: VoluntaryCtxtSwitches, Label: "Voluntary Context Switches", Format: report.IntegerFormat, Priority: 5},
+		InvoluntaryCtxtSwitches: {ID: InvoluntaryCtxtSwitches, Label: "Involuntary Context Switches", Format: report.IntegerFormat, Priority: 6},
 	}
 )
 
@@ -89,6 +99,7 @@ func (r *Reporter) processTopology() (report.Topology, error) {
 			{PID, pidstr},
 			{Name, p.Name},
 			{Threads, strconv.Itoa(p.Threads)},
+			{ExeSHA256, p.ExeSHA256},
 		} {
 			if tuple.value != "" {
 				node = node.WithLatests(map[string]string{tuple.key: tuple.value})
@@ -114,6 +125,14 @@ func (r *Reporter) processTopology() (report.Topology, error) {
 
 		node = node.WithMetric(MemoryUsage, report.MakeSingletonMetric(now, float64(p.RSSBytes)).WithMax(float64(p.RSSBytesLimit)))
 		node = node.WithMetric(OpenFilesCount, report.MakeSingletonMetric(now, float64(p.OpenFilesCount)).WithMax(float64(p.OpenFilesLimit)))
+		node = node.WithMetric(ThreadsCount, report.MakeSingletonMetric(now, float64(p.Threads)))
+
+		// Every process has switched context by the time it's walked, so
+		// none did only where they were not gathered.
+		if p.VoluntaryCtxtSwitches > 0 || p.InvoluntaryCtxtSwitches > 0 {
+			node = node.WithMetric(VoluntaryCtxtSwitches, report.MakeSingletonMetric(now, float64(p.VoluntaryCtxtSwitches)))
+			node = node.WithMetric(InvoluntaryCtxtSwitches, report.MakeSingletonMetric(now, float64(p.InvoluntaryCtxtSwitches)))
+		}
 
 		t.AddNode(node)
 	})
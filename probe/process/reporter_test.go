@@ -24,7 +24,7 @@ func (m *mockWalker) Walk(f func(process.Process, process.Process)) error {
 var processes = []process.Process{
 	{PID: 1, PPID: 0, Name: "init"},
 	{PID: 2, PPID: 1, Name: "bash"},
-	{PID: 3, PPID: 1, Name: "apache", Threads: 2},
+	{PID: 3, PPID: 1, Name: "apache", Threads: 2, VoluntaryCtxtSwitches: 10, InvoluntaryCtxtSwitches: 4, ExeSHA256: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
 	{PID: 4, PPID: 2, Name: "ping", Cmdline: "ping foo.bar.local"},
 	{PID: 5, PPID: 1, Cmdline: "tail -f /var/log/syslog"},
 }
@@ -89,6 +89,42 @@ func TestThreadCounts(t *testing.T) {
 		if threads, ok := node.Latest.Lookup(process.Threads); !ok || threads != fmt.Sprint(processes[2].Threads) {
 			t.Errorf("Expected %d got %q", processes[2].Threads, threads)
 		}
+		if sample, ok := node.Metrics[process.ThreadsCount].LastSample(); !ok || sample.Value != 2 {
+			t.Errorf("Expected threads count metric sample %d, got %v", 2, sample)
+		}
+	}
+	testReporter(t, false, test)
+}
+
+func TestCtxtSwitches(t *testing.T) {
+	test := func(rpt report.Report) {
+		node := rpt.Process.Nodes[report.MakeProcessNodeID("", "3")]
+		for key, want := range map[string]float64{
+			process.VoluntaryCtxtSwitches:   10,
+			process.InvoluntaryCtxtSwitches: 4,
+		} {
+			if sample, ok := node.Metrics[key].LastSample(); !ok || sample.Value != want {
+				t.Errorf("Expected %s metric sample %f, got %v", key, want, sample)
+			}
+		}
+		// Not gathered for the others
+		if _, ok := rpt.Process.Nodes[report.MakeProcessNodeID("", "2")].Metrics[process.VoluntaryCtxtSwitches]; ok {
+			t.Errorf("Expected no context switches of pid 2 bash")
+		}
+	}
+	testReporter(t, false, test)
+}
+
+func TestExeSHA256(t *testing.T) {
+	test := func(rpt report.Report) {
+		node := rpt.Process.Nodes[report.MakeProcessNodeID("", "3")]
+		if sum, ok := node.Latest.Lookup(process.ExeSHA256); !ok || sum != processes[2].ExeSHA256 {
+			t.Errorf("Expected %q got %q", processes[2].ExeSHA256, sum)
+		}
+		// Not gathered for the others
+		if _, ok := rpt.Process.Nodes[report.MakeProcessNodeID("", "2")].Latest.Lookup(process.ExeSHA256); ok {
+			t.Errorf("Expected no executable hash of pid 2 bash")
+		}
 	}
 	testReporter(t, false, test)
 }
@@ -14,6 +14,11 @@ type Process struct {
 	OpenFilesCount    int
 	OpenFilesLimit    uint64
 	IsWaitingInAccept bool
+
+	// Only gathered when the walker is asked to
+	VoluntaryCtxtSwitches   uint64
+	InvoluntaryCtxtSwitches uint64
+	ExeSHA256               string // hex
 }
 
 // Walker is something that walks the /proc directory
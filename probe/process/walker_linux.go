@@ -2,12 +2,16 @@ package process
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"strconv"
 	"strings"
+	"syscall"
 
 	linuxproc "github.com/c9s/goprocinfo/linux"
 	"github.com/coocood/freecache"
@@ -19,6 +23,8 @@ import (
 type walker struct {
 	procRoot                 string
 	gatheringWaitingInAccept bool
+	gatheringCtxtSwitches    bool
+	gatheringExeHashes       bool
 }
 
 var (
@@ -31,18 +37,28 @@ var (
 	// key: filename in /proc. Example: "42"
 	// value: two strings separated by a '\0'
 	cmdlineCache = freecache.NewCache(1024 * 16)
+
+	// exeHashCache caches the SHA-256 of executables
+	// key: device, inode, size and modification time of the executable
+	// value: hex SHA-256 of its contents
+	exeHashCache = freecache.NewCache(1024 * 64)
 )
 
 const (
 	limitsCacheTimeout  = 60
 	cmdlineCacheTimeout = 60
+	exeHashCacheTimeout = 3600
 )
 
-// NewWalker creates a new process Walker.
-func NewWalker(procRoot string, gatheringWaitingInAccept bool) Walker {
+// NewWalker creates a new process Walker. Gathering the context switches
+// of processes reads one more file of each. Gathering the hashes of their
+// executables reads each executable once, until it changes.
+func NewWalker(procRoot string, gatheringWaitingInAccept, gatheringCtxtSwitches, gatheringExeHashes bool) Walker {
 	return &walker{
 		procRoot:                 procRoot,
 		gatheringWaitingInAccept: gatheringWaitingInAccept,
+		gatheringCtxtSwitches:    gatheringCtxtSwitches,
+		gatheringExeHashes:       gatheringExeHashes,
 	}
 }
 
@@ -149,6 +165,28 @@ func readLimits(path string) (openFilesLimit uint64, err error) {
 	return softLimit, nil
 }
 
+// readCtxtSwitches reads the numbers of voluntary and involuntary context
+// switches of a process from '/proc/<pid>/status'
+func readCtxtSwitches(path string) (voluntary, involuntary uint64, err error) {
+	buf, err := fs.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "voluntary_ctxt_switches:":
+			voluntary, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "nonvoluntary_ctxt_switches:":
+			involuntary, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return voluntary, involuntary, nil
+}
+
 func (w *walker) readCmdline(filename string) (cmdline, name string) {
 	if cmdlineBuf, err := fs.ReadFile(path.Join(w.procRoot, filename, "cmdline")); err == nil {
 		// like proc, treat name as the first element of command line
@@ -170,6 +208,34 @@ func (w *walker) readCmdline(filename string) (cmdline, name string) {
 	return
 }
 
+// readExeHash returns the hex SHA-256 of the executable of a process, from
+// '/proc/<pid>/exe', cached by the identity of the file it links to, so
+// processes of the same executable share its hash, and one replaced in
+// place is hashed again.
+func (w *walker) readExeHash(filename string) (string, error) {
+	exe := path.Join(w.procRoot, filename, "exe")
+	var stat syscall.Stat_t
+	if err := fs.Stat(exe, &stat); err != nil {
+		return "", err
+	}
+	key := []byte(fmt.Sprintf("%d:%d:%d:%d.%d", stat.Dev, stat.Ino, stat.Size, stat.Mtim.Sec, stat.Mtim.Nsec))
+	if v, err := exeHashCache.Get(key); err == nil {
+		return string(v), nil
+	}
+	f, err := fs.Open(exe)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	exeHashCache.Set(key, []byte(sum), exeHashCacheTimeout)
+	return sum, nil
+}
+
 // IsProcInAccept returns true if the process has a at least one thread
 // blocked on the accept() system call
 func IsProcInAccept(procRoot, pid string) (ret bool) {
@@ -248,6 +314,21 @@ func (w *walker) Walk(f func(Process, Process)) error {
 			isWaitingInAccept = IsProcInAccept(w.procRoot, filename)
 		}
 
+		var voluntary, involuntary uint64
+		if w.gatheringCtxtSwitches {
+			voluntary, involuntary, err = readCtxtSwitches(path.Join(w.procRoot, filename, "status"))
+			if err != nil {
+				continue
+			}
+		}
+
+		// Kernel threads, and processes of other users when not root,
+		// have no executable to read
+		var exeHash string
+		if w.gatheringExeHashes {
+			exeHash, _ = w.readExeHash(filename)
+		}
+
 		f(Process{
 			PID:               pid,
 			PPID:              ppid,
@@ -260,6 +341,10 @@ func (w *walker) Walk(f func(Process, Process)) error {
 			OpenFilesCount:    openFilesCount,
 			OpenFilesLimit:    openFilesLimit,
 			IsWaitingInAccept: isWaitingInAccept,
+
+			VoluntaryCtxtSwitches:   voluntary,
+			InvoluntaryCtxtSwitches: involuntary,
+			ExeSHA256:               exeHash,
 		}, Process{})
 	}
 
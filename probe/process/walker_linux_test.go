@@ -2,6 +2,7 @@ package process_test
 
 import (
 	"reflect"
+	"syscall"
 	"testing"
 
 	fs_hook "github.com/weaveworks/common/fs"
@@ -25,6 +26,15 @@ var mockFS = fs.Dir("",
 				FName:     "limits",
 				FContents: "Limit Soft-Limit Hard-Limit Units\nMax open files 32768 65536 files",
 			},
+			fs.File{
+				FName:     "status",
+				FContents: "Name:\tcurl\nState:\tR (running)\nvoluntary_ctxt_switches:\t150\nnonvoluntary_ctxt_switches:\t8\n",
+			},
+			fs.File{
+				FName:     "exe",
+				FContents: "curl binary",
+				FStat:     syscall.Stat_t{Ino: 1234, Size: 11},
+			},
 			fs.Dir("fd", fs.File{FName: "0"}, fs.File{FName: "1"}, fs.File{FName: "2"}),
 		),
 		fs.Dir("2",
@@ -40,6 +50,10 @@ var mockFS = fs.Dir("",
 				FName:     "limits",
 				FContents: ``,
 			},
+			fs.File{
+				FName:     "status",
+				FContents: "Name:\tbash\nvoluntary_ctxt_switches:\t3\nnonvoluntary_ctxt_switches:\t0\n",
+			},
 			fs.Dir("fd", fs.File{FName: "1"}, fs.File{FName: "2"}),
 		),
 		fs.Dir("4",
@@ -88,7 +102,7 @@ func TestWalker(t *testing.T) {
 	}
 
 	have := map[int]process.Process{}
-	walker := process.NewWalker("/proc", false)
+	walker := process.NewWalker("/proc", false, false, false)
 	err := walker.Walk(func(p, _ process.Process) {
 		have[p.PID] = p
 	})
@@ -97,3 +111,47 @@ func TestWalker(t *testing.T) {
 		t.Errorf("%v (%v)", test.Diff(want, have), err)
 	}
 }
+
+func TestWalkerCtxtSwitches(t *testing.T) {
+	fs_hook.Mock(mockFS)
+	defer fs_hook.Restore()
+
+	// Processes without a status to read are skipped, as if they exited
+	want := map[int]process.Process{
+		3: {PID: 3, PPID: 2, Name: "curl", Cmdline: "curl google.com", Threads: 1, RSSBytes: 8192, RSSBytesLimit: 2048, OpenFilesCount: 3, OpenFilesLimit: 32768, VoluntaryCtxtSwitches: 150, InvoluntaryCtxtSwitches: 8},
+		2: {PID: 2, PPID: 1, Name: "bash", Cmdline: "bash", Threads: 1, OpenFilesCount: 2, VoluntaryCtxtSwitches: 3},
+	}
+
+	have := map[int]process.Process{}
+	walker := process.NewWalker("/proc", false, true, false)
+	err := walker.Walk(func(p, _ process.Process) {
+		have[p.PID] = p
+	})
+
+	if err != nil || !reflect.DeepEqual(want, have) {
+		t.Errorf("%v (%v)", test.Diff(want, have), err)
+	}
+}
+
+func TestWalkerExeHashes(t *testing.T) {
+	fs_hook.Mock(mockFS)
+	defer fs_hook.Restore()
+
+	// Processes without an executable to read have no hash
+	want := map[int]string{
+		3: "208f7dbe924b110e3467e32a52d26415dbaf786a149925c1cf9ad2a74f006660",
+		2: "",
+		4: "",
+		1: "",
+	}
+
+	have := map[int]string{}
+	walker := process.NewWalker("/proc", false, false, true)
+	err := walker.Walk(func(p, _ process.Process) {
+		have[p.PID] = p.ExeSHA256
+	})
+
+	if err != nil || !reflect.DeepEqual(want, have) {
+		t.Errorf("%v (%v)", test.Diff(want, have), err)
+	}
+}
@@ -0,0 +1,25 @@
+package process
+
+// NewWalker returns a Windows walker, which walks no processes yet: they
+// are listed by the Toolhelp or WMI APIs, which aren't read yet.
+func NewWalker(_ string, _, _, _ bool) Walker {
+	return &walker{}
+}
+
+type walker struct{}
+
+// IsProcInAccept returns true if the process has a at least one thread
+// blocked on the accept() system call
+func IsProcInAccept(procRoot, pid string) (ret bool) {
+	// Not implemented on windows
+	return false
+}
+
+func (walker) Walk(f func(Process, Process)) error {
+	return nil
+}
+
+// GetDeltaTotalJiffies returns 0 - windows doesn't have jiffies.
+func GetDeltaTotalJiffies() (uint64, float64, error) {
+	return 0, 0.0, nil
+}
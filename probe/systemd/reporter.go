@@ -0,0 +1,240 @@
+package systemd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/weaveworks/common/exec"
+	"github.com/weaveworks/common/fs"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys for use in Node.Latest.
+const (
+	UnitName    = report.SystemdUnitName
+	Description = report.SystemdUnitDescription
+	ActiveState = report.SystemdUnitActiveState
+	SubState    = report.SystemdUnitSubState
+	Restarts    = report.SystemdUnitRestarts
+)
+
+// Control IDs used by the systemd integration.
+const (
+	RestartUnit = report.SystemdRestartUnit
+)
+
+// The properties of units to report, as systemctl shows them.
+const properties = "Id,Description,ActiveState,SubState,NRestarts"
+
+// Exposed for testing.
+var (
+	MetadataTemplates = report.MetadataTemplates{
+		UnitName:    {ID: UnitName, Label: "Unit", From: report.FromLatest, Priority: 1},
+		Description: {ID: Description, Label: "Description", From: report.FromLatest, Priority: 2},
+		ActiveState: {ID: ActiveState, Label: "State", From: report.FromLatest, Priority: 3},
+		SubState:    {ID: SubState, Label: "Sub-State", From: report.FromLatest, Priority: 4},
+		Restarts:    {ID: Restarts, Label: "Restarts", From: report.FromLatest, Datatype: report.Number, Priority: 5},
+	}
+
+	Controls = []report.Control{
+		{
+			ID:    RestartUnit,
+			Human: "Restart",
+			Icon:  "fa-repeat",
+			Rank:  1,
+		},
+	}
+)
+
+// Unit is a service systemd manages.
+type Unit struct {
+	Name        string
+	Description string
+	ActiveState string // e.g. "active" or "failed"
+	SubState    string // e.g. "running" or "exited"
+	Restarts    string // since it was last started by hand
+}
+
+// ListUnits returns the services systemd is running, starting or stopping,
+// and those which failed. It's exposed for testing.
+var ListUnits = func() ([]Unit, error) {
+	output, err := exec.Command("systemctl", "list-units", "--type=service", "--state=active,activating,deactivating,reloading,failed", "--no-legend", "--plain", "--no-pager").Output()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			names = append(names, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil || len(names) == 0 {
+		return nil, err
+	}
+	output, err = exec.Command("systemctl", append([]string{"show", "--no-pager", "--property=" + properties}, names...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseUnits(output)
+}
+
+// parseUnits parses what systemctl shows of units: their properties, one
+// "Key=Value" per line, with a blank line between units.
+func parseUnits(output []byte) ([]Unit, error) {
+	units := []Unit{}
+	var unit Unit
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if unit.Name != "" {
+				units = append(units, unit)
+			}
+			unit = Unit{}
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "Id":
+			unit.Name = kv[1]
+		case "Description":
+			unit.Description = kv[1]
+		case "ActiveState":
+			unit.ActiveState = kv[1]
+		case "SubState":
+			unit.SubState = kv[1]
+		case "NRestarts":
+			unit.Restarts = kv[1]
+		}
+	}
+	if unit.Name != "" {
+		units = append(units, unit)
+	}
+	return units, scanner.Err()
+}
+
+// Reporter generates Reports containing the SystemdUnit topology, and tags
+// processes with the units they run in, as found in their cgroups.
+type Reporter struct {
+	hostID          string
+	probeID         string
+	procRoot        string
+	handlerRegistry *controls.HandlerRegistry
+}
+
+// NewReporter makes a new Reporter. Don't forget to Stop it.
+func NewReporter(hostID, probeID, procRoot string, handlerRegistry *controls.HandlerRegistry) *Reporter {
+	r := &Reporter{
+		hostID:          hostID,
+		probeID:         probeID,
+		procRoot:        procRoot,
+		handlerRegistry: handlerRegistry,
+	}
+	r.handlerRegistry.Register(RestartUnit, r.restartUnit)
+	return r
+}
+
+// Stop deregisters the controls of the reporter.
+func (r *Reporter) Stop() {
+	r.handlerRegistry.Rm(RestartUnit)
+}
+
+// Name of this reporter, for metrics gathering
+func (*Reporter) Name() string { return "Systemd" }
+
+// Report implements Reporter.
+func (r *Reporter) Report() (report.Report, error) {
+	result := report.MakeReport()
+	result.SystemdUnit = result.SystemdUnit.
+		WithMetadataTemplates(MetadataTemplates)
+	result.SystemdUnit.Controls.AddControls(Controls)
+
+	units, err := ListUnits()
+	if err != nil {
+		return result, err
+	}
+	hostNodeID := report.MakeHostNodeID(r.hostID)
+	for _, unit := range units {
+		result.SystemdUnit.AddNode(report.MakeNodeWith(report.MakeSystemdUnitNodeID(r.hostID, unit.Name), map[string]string{
+			UnitName:              unit.Name,
+			Description:           unit.Description,
+			ActiveState:           unit.ActiveState,
+			SubState:              unit.SubState,
+			Restarts:              unit.Restarts,
+			report.HostNodeID:     hostNodeID,
+			report.ControlProbeID: r.probeID,
+		}).
+			WithParents(report.MakeSets().Add(report.Host, report.MakeStringSet(hostNodeID))).
+			WithLatestActiveControls(RestartUnit))
+	}
+	return result, nil
+}
+
+// Tag implements Tagger, making the units parents of the processes running
+// in them.
+func (r *Reporter) Tag(rpt report.Report) (report.Report, error) {
+	units := map[string]string{} // cgroup -> unit node ID, as processes share cgroups
+	for id, n := range rpt.Process.Nodes {
+		hostID, pid, ok := report.ParseProcessNodeID(id)
+		if !ok || hostID != r.hostID {
+			continue
+		}
+		cgroup, err := fs.ReadFile(filepath.Join(r.procRoot, pid, "cgroup"))
+		if err != nil {
+			continue
+		}
+		unitID, ok := units[string(cgroup)]
+		if !ok {
+			if unit := unitOf(cgroup); unit != "" {
+				unitID = report.MakeSystemdUnitNodeID(r.hostID, unit)
+			}
+			units[string(cgroup)] = unitID
+		}
+		if _, ok := rpt.SystemdUnit.Nodes[unitID]; !ok {
+			continue
+		}
+		rpt.Process.Nodes[id] = n.WithParents(n.Parents.Add(report.SystemdUnit, report.MakeStringSet(unitID)))
+	}
+	return rpt, nil
+}
+
+// unitOf returns the service a process runs in, from its cgroups: the first
+// service in the path of the systemd hierarchy (v1) or of the unified one
+// (v2), e.g. "docker.service" of "0::/system.slice/docker.service".
+func unitOf(cgroup []byte) string {
+	for _, line := range strings.Split(string(cgroup), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 || (fields[1] != "name=systemd" && fields[0] != "0") {
+			continue
+		}
+		for _, part := range strings.Split(fields[2], "/") {
+			if strings.HasSuffix(part, ".service") {
+				return part
+			}
+		}
+	}
+	return ""
+}
+
+func (r *Reporter) restartUnit(req xfer.Request) xfer.Response {
+	hostID, unit, ok := report.ParseSystemdUnitNodeID(req.NodeID)
+	if !ok || hostID != r.hostID {
+		return xfer.ResponseErrorf("Invalid ID: %s", req.NodeID)
+	}
+	log.Infof("Restarting systemd unit %s", unit)
+	if err := exec.Command("systemctl", "restart", unit).Run(); err != nil {
+		return xfer.ResponseError(fmt.Errorf("restarting %s: %v", unit, err))
+	}
+	return xfer.Response{}
+}
@@ -0,0 +1,132 @@
+package systemd_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/common/exec"
+	fs_hook "github.com/weaveworks/common/fs"
+	testexec "github.com/weaveworks/common/test/exec"
+	"github.com/weaveworks/common/test/fs"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/systemd"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+const (
+	listUnitsOutput = `nginx.service loaded active running A high performance web server
+cron.service  loaded active running Regular background program processing daemon
+backup.service loaded failed failed Nightly backup
+`
+	showOutput = `Id=nginx.service
+Description=A high performance web server
+ActiveState=active
+SubState=running
+NRestarts=2
+
+Id=cron.service
+Description=Regular background program processing daemon
+ActiveState=active
+SubState=running
+NRestarts=0
+
+Id=backup.service
+Description=Nightly backup
+ActiveState=failed
+SubState=failed
+NRestarts=5
+`
+)
+
+var mockFS = fs.Dir("",
+	fs.Dir("proc",
+		// nginx, on cgroup v1
+		fs.Dir("10", fs.File{FName: "cgroup", FContents: "12:devices:/system.slice/nginx.service\n1:name=systemd:/system.slice/nginx.service\n"}),
+		// cron, on cgroup v2
+		fs.Dir("20", fs.File{FName: "cgroup", FContents: "0::/system.slice/cron.service\n"}),
+		// in a session, not a service
+		fs.Dir("30", fs.File{FName: "cgroup", FContents: "0::/user.slice/user-1000.slice/session-2.scope\n"}),
+	),
+)
+
+func TestReporter(t *testing.T) {
+	fs_hook.Mock(mockFS)
+	defer fs_hook.Restore()
+	oldExecCmd := exec.Command
+	defer func() { exec.Command = oldExecCmd }()
+	var commands []string
+	exec.Command = func(name string, args ...string) exec.Cmd {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		switch args[0] {
+		case "list-units":
+			return testexec.NewMockCmdString(listUnitsOutput)
+		case "show":
+			return testexec.NewMockCmdString(showOutput)
+		}
+		return testexec.NewMockCmdString("")
+	}
+
+	hr := controls.NewDefaultHandlerRegistry()
+	reporter := systemd.NewReporter("host1", "probe1", "/proc", hr)
+	defer reporter.Stop()
+	rpt, err := reporter.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "systemctl show --no-pager --property=Id,Description,ActiveState,SubState,NRestarts nginx.service cron.service backup.service"; len(commands) != 2 || commands[1] != want {
+		t.Errorf("Expected to run %q, got %v", want, commands)
+	}
+	if len(rpt.SystemdUnit.Nodes) != 3 {
+		t.Fatalf("Expected 3 units, got %d", len(rpt.SystemdUnit.Nodes))
+	}
+	backup := rpt.SystemdUnit.Nodes[report.MakeSystemdUnitNodeID("host1", "backup.service")]
+	for key, want := range map[string]string{
+		systemd.UnitName:    "backup.service",
+		systemd.ActiveState: "failed",
+		systemd.Restarts:    "5",
+	} {
+		if have, _ := backup.Latest.Lookup(key); have != want {
+			t.Errorf("Expected %s %q, got %q", key, want, have)
+		}
+	}
+	if hosts, _ := backup.Parents.Lookup(report.Host); !reflect.DeepEqual(hosts, report.MakeStringSet(report.MakeHostNodeID("host1"))) {
+		t.Errorf("Expected the unit on its host, got %v", hosts)
+	}
+
+	for _, pid := range []string{"10", "20", "30"} {
+		rpt.Process.AddNode(report.MakeNode(report.MakeProcessNodeID("host1", pid)))
+	}
+	rpt, err = reporter.Tag(rpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pid, want := range map[string]report.StringSet{
+		"10": report.MakeStringSet(report.MakeSystemdUnitNodeID("host1", "nginx.service")),
+		"20": report.MakeStringSet(report.MakeSystemdUnitNodeID("host1", "cron.service")),
+		"30": nil,
+	} {
+		have, _ := rpt.Process.Nodes[report.MakeProcessNodeID("host1", pid)].Parents.Lookup(report.SystemdUnit)
+		if !reflect.DeepEqual(want, have) {
+			t.Errorf("Expected process %s in units %v, got %v", pid, want, have)
+		}
+	}
+
+	commands = nil
+	if resp := hr.HandleControlRequest(xfer.Request{
+		Control: systemd.RestartUnit,
+		NodeID:  report.MakeSystemdUnitNodeID("host1", "backup.service"),
+	}); resp.Error != "" {
+		t.Errorf("Expected the unit restarted, got %v", resp.Error)
+	}
+	if want := []string{"systemctl restart backup.service"}; !reflect.DeepEqual(want, commands) {
+		t.Errorf("Expected to run %v, got %v", want, commands)
+	}
+	if resp := hr.HandleControlRequest(xfer.Request{
+		Control: systemd.RestartUnit,
+		NodeID:  report.MakeSystemdUnitNodeID("host2", "backup.service"),
+	}); resp.Error == "" {
+		t.Errorf("Expected an error restarting a unit of another host")
+	}
+}
@@ -0,0 +1,174 @@
+package unixsocket
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/weaveworks/common/exec"
+	"github.com/weaveworks/common/fs"
+	"github.com/weaveworks/scope/report"
+)
+
+// Node metadata keys.
+const (
+	Path = report.UnixSocketPath
+	PID  = report.PID
+)
+
+const (
+	established = "ESTAB"
+	unnamed     = "*"
+)
+
+// connection is a connected unix socket, as listed by ss.
+type connection struct {
+	path      string
+	inode     uint64
+	peerPath  string
+	peerInode uint64
+}
+
+// Reporter generates Reports containing the UnixSocket topology: the
+// connected unix sockets of the probe's network namespace, mapped to the
+// processes holding them.
+type Reporter struct {
+	hostID   string
+	procRoot string
+}
+
+// NewReporter makes a new Reporter.
+func NewReporter(hostID, procRoot string) *Reporter {
+	return &Reporter{
+		hostID:   hostID,
+		procRoot: procRoot,
+	}
+}
+
+// Name of this reporter, for metrics gathering
+func (Reporter) Name() string { return "UnixSocket" }
+
+// Report implements Reporter.
+func (r *Reporter) Report() (report.Report, error) {
+	result := report.MakeReport()
+	output, err := exec.Command("ss", "-x", "-n").Output()
+	if err != nil {
+		return result, err
+	}
+	connections := parseConnections(output)
+	if len(connections) == 0 {
+		return result, nil
+	}
+	r.addConnections(&result, connections, socketOwners(r.procRoot))
+	return result, nil
+}
+
+func (r *Reporter) addConnections(rpt *report.Report, connections []connection, owners map[uint64]int) {
+	hostNodeID := report.MakeHostNodeID(r.hostID)
+	for _, c := range connections {
+		latests := map[string]string{report.HostNodeID: hostNodeID}
+		if c.path != unnamed {
+			latests[Path] = c.path
+		}
+		if pid, ok := owners[c.inode]; ok {
+			latests[PID] = strconv.Itoa(pid)
+		}
+		node := report.MakeNodeWith(r.nodeID(c.inode), latests)
+		if c.peerInode != 0 && isClient(c) {
+			node = node.WithAdjacent(r.nodeID(c.peerInode))
+		}
+		rpt.UnixSocket = rpt.UnixSocket.AddNode(node)
+	}
+}
+
+func (r *Reporter) nodeID(inode uint64) string {
+	return report.MakeUnixSocketNodeID(r.hostID, strconv.FormatUint(inode, 10))
+}
+
+// isClient tells whether a socket is the client end of its connection.
+// The ends accepted on a named socket carry its name, while connecting
+// ends usually don't. Anything else, like socketpairs, is ordered by
+// inode, to only get one edge per connection.
+func isClient(c connection) bool {
+	if (c.path == unnamed) != (c.peerPath == unnamed) {
+		return c.path == unnamed
+	}
+	return c.inode < c.peerInode
+}
+
+// parseConnections parses the output of `ss -x -n`, e.g.
+//
+//	Netid State  Recv-Q Send-Q Local Address:Port        Peer Address:Port
+//	u_str ESTAB  0      0      /var/run/docker.sock 23456 * 23455
+//	u_str ESTAB  0      0      * 23455                    * 23456
+//
+// The Netid column is missing in some versions of ss.
+func parseConnections(output []byte) []connection {
+	var (
+		result  []connection
+		scanner = bufio.NewScanner(bytes.NewReader(output))
+	)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && strings.HasPrefix(fields[0], "u_") {
+			fields = fields[1:]
+		}
+		if len(fields) < 7 || fields[0] != established {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[4], 10, 64)
+		if err != nil {
+			continue
+		}
+		peerInode, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			continue
+		}
+		result = append(result, connection{
+			path:      fields[3],
+			inode:     inode,
+			peerPath:  fields[5],
+			peerInode: peerInode,
+		})
+	}
+	return result
+}
+
+// socketOwners walks the file descriptors of all processes, returning
+// the pid owning each socket inode. Sockets shared by several processes,
+// e.g. after a fork, are attributed to one of them.
+func socketOwners(procRoot string) map[uint64]int {
+	result := map[uint64]int{}
+	dirNames, err := fs.ReadDirNames(procRoot)
+	if err != nil {
+		return result
+	}
+	var statT syscall.Stat_t
+	for _, dirName := range dirNames {
+		pid, err := strconv.Atoi(dirName)
+		if err != nil {
+			continue
+		}
+		fdBase := filepath.Join(procRoot, dirName, "fd")
+		fds, err := fs.ReadDirNames(fdBase)
+		if err != nil {
+			// Process is gone by now, or we don't have access.
+			continue
+		}
+		for _, fd := range fds {
+			if err := fs.Stat(filepath.Join(fdBase, fd), &statT); err != nil {
+				continue
+			}
+			if statT.Mode&syscall.S_IFMT != syscall.S_IFSOCK {
+				continue
+			}
+			if _, ok := result[statT.Ino]; !ok {
+				result[statT.Ino] = pid
+			}
+		}
+	}
+	return result
+}
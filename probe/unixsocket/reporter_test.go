@@ -0,0 +1,77 @@
+package unixsocket_test
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/weaveworks/common/exec"
+	fs_hook "github.com/weaveworks/common/fs"
+	testexec "github.com/weaveworks/common/test/exec"
+	"github.com/weaveworks/common/test/fs"
+	"github.com/weaveworks/scope/probe/unixsocket"
+	"github.com/weaveworks/scope/report"
+)
+
+const ssOutput = `Netid State      Recv-Q Send-Q Local Address:Port               Peer Address:Port
+u_str ESTAB      0      0      /var/run/docker.sock 23456                 * 23455
+u_str ESTAB      0      0                    * 23455                * 23456
+u_str ESTAB      0      0                    * 31000                * 31001
+u_str ESTAB      0      0                    * 31001                * 31000
+u_dgr UNCONN     0      0      /run/systemd/notify 12000                 * 0
+`
+
+func socket(fd string, inode uint64) fs.File {
+	return fs.File{FName: fd, FStat: syscall.Stat_t{Mode: syscall.S_IFSOCK, Ino: inode}}
+}
+
+var mockFS = fs.Dir("",
+	fs.Dir("proc",
+		fs.Dir("1", fs.Dir("fd", socket("3", 23456))),
+		fs.Dir("42", fs.Dir("fd", fs.File{FName: "0"}, socket("5", 23455), socket("6", 31000), socket("7", 31001))),
+		fs.Dir("notapid"),
+	),
+)
+
+func TestReporter(t *testing.T) {
+	fs_hook.Mock(mockFS)
+	defer fs_hook.Restore()
+	oldExecCmd := exec.Command
+	defer func() { exec.Command = oldExecCmd }()
+	exec.Command = func(name string, args ...string) exec.Cmd {
+		return testexec.NewMockCmdString(ssOutput)
+	}
+
+	rpt, err := unixsocket.NewReporter("host", "/proc").Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 4, len(rpt.UnixSocket.Nodes); want != have {
+		t.Fatalf("want %d sockets, have %d", want, have)
+	}
+
+	var (
+		docker = rpt.UnixSocket.Nodes[report.MakeUnixSocketNodeID("host", "23456")]
+		client = rpt.UnixSocket.Nodes[report.MakeUnixSocketNodeID("host", "23455")]
+	)
+	if path, _ := docker.Latest.Lookup(unixsocket.Path); path != "/var/run/docker.sock" {
+		t.Errorf("unexpected path for the docker socket: %q", path)
+	}
+	if pid, _ := docker.Latest.Lookup(unixsocket.PID); pid != "1" {
+		t.Errorf("unexpected pid for the docker socket: %q", pid)
+	}
+	if pid, _ := client.Latest.Lookup(unixsocket.PID); pid != "42" {
+		t.Errorf("unexpected pid for the client socket: %q", pid)
+	}
+	if !client.Adjacency.Contains(docker.ID) || len(docker.Adjacency) != 0 {
+		t.Errorf("expected an edge from the client to the docker socket: %v, %v", client.Adjacency, docker.Adjacency)
+	}
+
+	// A single edge for socketpairs
+	edges := 0
+	for _, id := range []string{"31000", "31001"} {
+		edges += len(rpt.UnixSocket.Nodes[report.MakeUnixSocketNodeID("host", id)].Adjacency)
+	}
+	if edges != 1 {
+		t.Errorf("expected one edge between the ends of a socketpair, have %d", edges)
+	}
+}
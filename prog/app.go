@@ -1,8 +1,10 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"math/rand"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"net/url"
@@ -16,6 +18,9 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tylerb/graceful"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	billing "github.com/weaveworks/billing-client"
 	"github.com/weaveworks/common/aws"
@@ -24,6 +29,7 @@ import (
 	"github.com/weaveworks/go-checkpoint"
 	"github.com/weaveworks/scope/app"
 	"github.com/weaveworks/scope/app/multitenant"
+	"github.com/weaveworks/scope/common/geoip"
 	"github.com/weaveworks/scope/common/weave"
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/probe/docker"
@@ -50,17 +56,48 @@ func init() {
 }
 
 // Router creates the mux for all the various app components.
-func router(collector app.Collector, controlRouter app.ControlRouter, pipeRouter app.PipeRouter, externalUI bool, capabilities map[string]bool, metricsGraphURL string) http.Handler {
+func router(collector app.Collector, controlRouter app.ControlRouter, pipeRouter app.PipeRouter, alerter *app.Alerter, audit *app.AuditLog, externalUI bool, capabilities map[string]bool, metricsGraphURL string, geo geoip.Resolver, layouts *app.Layouts, annotations *app.Annotations, views *app.Views, anomalies *app.AnomalyDetector, dependencies *app.DependencyTracker, exeHashes *app.ExeHashes, recordings *app.Recordings) http.Handler {
 	router := mux.NewRouter().SkipClean(true)
 
 	// We pull in the http.DefaultServeMux to get the pprof routes
 	router.PathPrefix("/debug/pprof").Handler(http.DefaultServeMux)
 	router.Path("/metrics").Handler(prometheus.Handler())
 
-	app.RegisterReportPostHandler(collector, router)
+	if sharded, ok := collector.(*app.ShardedCollector); ok {
+		app.RegisterShardedReportPostHandler(sharded, router)
+	} else {
+		app.RegisterReportPostHandler(collector, router)
+	}
 	app.RegisterControlRoutes(router, controlRouter)
+	app.RegisterBatchControlRoutes(router, collector, controlRouter)
 	app.RegisterPipeRoutes(router, pipeRouter)
-	app.RegisterTopologyRoutes(router, app.WebReporter{Reporter: collector, MetricsGraphURL: metricsGraphURL}, capabilities)
+	app.RegisterDNSRoutes(router, app.NewLocalDNSCache())
+	app.RegisterProfileRoutes(router)
+	if alerter != nil {
+		app.RegisterAlertRoutes(router, alerter)
+	}
+	if audit != nil {
+		app.RegisterAuditRoutes(router, audit)
+	}
+	if recordings != nil {
+		app.RegisterRecordingRoutes(router, recordings)
+	}
+	if annotations != nil {
+		app.RegisterAnnotationRoutes(router, annotations)
+	}
+	if views != nil {
+		app.RegisterViewRoutes(router, views)
+	}
+	if anomalies != nil {
+		app.RegisterAnomalyRoutes(router, anomalies)
+	}
+	if dependencies != nil {
+		app.RegisterDependencyRoutes(router, dependencies)
+	}
+	if exeHashes != nil {
+		app.RegisterExeHashRoutes(router, collector, exeHashes)
+	}
+	app.RegisterTopologyRoutes(router, app.WebReporter{Reporter: collector, MetricsGraphURL: metricsGraphURL, GeoIP: geo, Layouts: layouts, Annotations: annotations, Anomalies: anomalies}, capabilities)
 
 	uiHandler := http.FileServer(GetFS(externalUI))
 	router.PathPrefix("/ui").Name("static").Handler(
@@ -75,10 +112,70 @@ func router(collector app.Collector, controlRouter app.ControlRouter, pipeRouter
 	return instrument.Wrap(router)
 }
 
-func collectorFactory(userIDer multitenant.UserIDer, collectorURL, s3URL, natsHostname string,
-	memcacheConfig multitenant.MemcacheConfig, window time.Duration, createTables bool) (app.Collector, error) {
+// policyFactory makes the authorization policy of the flags, if any.
+func policyFactory(flags appFlags) (app.Policy, error) {
+	switch {
+	case flags.authzFile != "" && flags.oidcIssuerURL != "":
+		return nil, fmt.Errorf("either a policy file or an OIDC issuer, not both")
+	case flags.authzFile != "":
+		return app.NewStaticPolicy(flags.authzFile)
+	case flags.oidcIssuerURL != "":
+		config := app.OIDCConfig{
+			IssuerURL: flags.oidcIssuerURL,
+			ClientID:  flags.oidcClientID,
+			Claim:     flags.oidcClaim,
+			Roles:     map[string]app.Role{},
+		}
+		var err error
+		if config.Default, err = app.ParseRole(flags.oidcDefaultRole); err != nil {
+			return nil, err
+		}
+		for _, mapping := range strings.Split(flags.oidcRoles, ",") {
+			if mapping == "" {
+				continue
+			}
+			parts := strings.SplitN(mapping, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid role mapping %q: not value=role", mapping)
+			}
+			if config.Roles[parts[0]], err = app.ParseRole(parts[1]); err != nil {
+				return nil, err
+			}
+		}
+		if flags.oidcRedirectURL != "" {
+			return app.NewOIDCLogin(config, app.OIDCLoginConfig{
+				ClientSecret: flags.oidcClientSecret,
+				RedirectURL:  flags.oidcRedirectURL,
+				Scopes:       strings.Fields(flags.oidcScopes),
+			})
+		}
+		return app.NewOIDCPolicy(config)
+	}
+	return nil, nil
+}
+
+const shardsPrefix = "shards://"
+
+func collectorFactory(userIDer multitenant.UserIDer, collectorURL, s3URL, reportStoreURL, natsHostname string,
+	memcacheConfig multitenant.MemcacheConfig, window, history time.Duration, createTables bool) (app.Collector, error) {
 	if collectorURL == "local" {
-		return app.NewCollector(window), nil
+		if reportStoreURL != "" {
+			store, err := reportStoreFactory(reportStoreURL)
+			if err != nil {
+				return nil, err
+			}
+			return app.NewStoringCollector(window, history, store), nil
+		}
+		return app.NewCollectorWithHistory(window, history), nil
+	}
+
+	// Not a valid URL, with its list of hosts.
+	if strings.HasPrefix(collectorURL, shardsPrefix) {
+		var shards []url.URL
+		for _, host := range strings.Split(strings.TrimPrefix(collectorURL, shardsPrefix), ",") {
+			shards = append(shards, url.URL{Scheme: "http", Host: host})
+		}
+		return app.NewShardedCollector(shards)
 	}
 
 	parsed, err := url.Parse(collectorURL)
@@ -134,6 +231,57 @@ func collectorFactory(userIDer multitenant.UserIDer, collectorURL, s3URL, natsHo
 	return nil, fmt.Errorf("Invalid collector '%s'", collectorURL)
 }
 
+func reportStoreFactory(reportStoreURL string) (app.ReportStore, error) {
+	parsed, err := url.Parse(reportStoreURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "s3" {
+		return nil, fmt.Errorf("Invalid report store '%s'", reportStoreURL)
+	}
+	s3Config, err := aws.ConfigFromURL(parsed)
+	if err != nil {
+		return nil, err
+	}
+	bucketName, prefix := strings.TrimPrefix(parsed.Path, "/"), ""
+	if i := strings.Index(bucketName, "/"); i >= 0 {
+		bucketName, prefix = bucketName[:i], bucketName[i+1:]
+	}
+	if bucketName == "" {
+		return nil, fmt.Errorf("No bucket in report store '%s'", reportStoreURL)
+	}
+	return multitenant.NewS3ReportStore(s3Config, bucketName, prefix), nil
+}
+
+func flowLogSourceFactory(flowLogsURL string, from time.Time) (app.FlowLogSource, error) {
+	parsed, err := url.Parse(flowLogsURL)
+	if err != nil {
+		return nil, err
+	}
+	config, err := aws.ConfigFromURL(parsed)
+	if err != nil {
+		return nil, err
+	}
+	path := strings.TrimPrefix(parsed.Path, "/")
+	switch parsed.Scheme {
+	case "s3":
+		bucketName, prefix := path, ""
+		if i := strings.Index(bucketName, "/"); i >= 0 {
+			bucketName, prefix = bucketName[:i], bucketName[i+1:]
+		}
+		if bucketName == "" {
+			return nil, fmt.Errorf("No bucket in flow logs '%s'", flowLogsURL)
+		}
+		return multitenant.NewS3FlowLogSource(config, bucketName, prefix, from), nil
+	case "kinesis":
+		if path == "" {
+			return nil, fmt.Errorf("No stream in flow logs '%s'", flowLogsURL)
+		}
+		return multitenant.NewKinesisFlowLogSource(config, path), nil
+	}
+	return nil, fmt.Errorf("Invalid flow logs '%s'", flowLogsURL)
+}
+
 func emitterFactory(collector app.Collector, clientCfg billing.Config, userIDer multitenant.UserIDer, emitterCfg multitenant.BillingEmitterConfig) (*multitenant.BillingEmitter, error) {
 	billingClient, err := billing.NewClient(clientCfg)
 	if err != nil {
@@ -200,6 +348,7 @@ func appMain(flags appFlags) {
 	setLogLevel(flags.logLevel)
 	setLogFormatter(flags.logPrefix)
 	runtime.SetBlockProfileRate(flags.blockProfileRate)
+	defer setupTracing(flags.otlpEndpoint, "scope-app")()
 
 	defer log.Info("app exiting")
 	rand.Seed(time.Now().UnixNano())
@@ -208,13 +357,20 @@ func appMain(flags appFlags) {
 	log.Infof("app starting, version %s, ID %s", app.Version, app.UniqueID)
 	logCensoredArgs()
 
+	if flags.customTopologies != "" {
+		if err := app.LoadCustomTopologies(flags.customTopologies); err != nil {
+			log.Fatalf("Error loading custom topologies: %v", err)
+			return
+		}
+	}
+
 	userIDer := multitenant.NoopUserIDer
 	if flags.userIDHeader != "" {
 		userIDer = multitenant.UserIDHeader(flags.userIDHeader)
 	}
 
 	collector, err := collectorFactory(
-		userIDer, flags.collectorURL, flags.s3URL, flags.natsHostname,
+		userIDer, flags.collectorURL, flags.s3URL, flags.reportStoreURL, flags.natsHostname,
 		multitenant.MemcacheConfig{
 			Host:             flags.memcachedHostname,
 			Timeout:          flags.memcachedTimeout,
@@ -223,7 +379,7 @@ func appMain(flags appFlags) {
 			Service:          flags.memcachedService,
 			CompressionLevel: flags.memcachedCompressionLevel,
 		},
-		flags.window, flags.awsCreateTables)
+		flags.window, flags.history, flags.awsCreateTables)
 	if err != nil {
 		log.Fatalf("Error creating collector: %v", err)
 		return
@@ -278,10 +434,178 @@ func appMain(flags appFlags) {
 		}
 	}
 
+	// Rendering on every scrape, or alerting, only makes sense for a
+	// single tenant.
+	singleTenant := !strings.HasPrefix(flags.collectorURL, "dynamodb")
+	if singleTenant {
+		prometheus.MustRegister(app.NewTopologyMetrics(collector))
+	}
+
 	capabilities := map[string]bool{
 		xfer.HistoricReportsCapability: collector.HasHistoricReports(),
+		// Deltas are reassembled in memory, against the last full report
+		// of the probe, which the app replicas of a multitenant
+		// deployment don't share.
+		xfer.ReportDeltasCapability: singleTenant,
+		xfer.LayoutsCapability:      flags.layouts,
+	}
+	var layouts *app.Layouts
+	if flags.layouts {
+		layouts = app.NewLayouts()
+	}
+	var geo geoip.Resolver
+	if flags.geoIPDatabase != "" {
+		db, err := geoip.LoadGeoLite2CSV(flags.geoIPDatabase)
+		if err != nil {
+			log.Fatalf("Error loading GeoIP database: %v", err)
+			return
+		}
+		geo = db
+	}
+	var alerter *app.Alerter
+	if singleTenant && flags.alertsInterval > 0 {
+		alerter = app.NewAlerter(app.WebReporter{Reporter: collector, MetricsGraphURL: flags.metricsGraphURL, GeoIP: geo})
+		alerter.Start(flags.alertsInterval)
+		defer alerter.Stop()
+	}
+	var anomalies *app.AnomalyDetector
+	if singleTenant && flags.anomaliesInterval > 0 {
+		config := app.DefaultAnomalyConfig
+		config.Topologies = strings.Split(flags.anomaliesTopologies, ",")
+		config.Threshold = flags.anomaliesThreshold
+		var err error
+		if anomalies, err = app.NewAnomalyDetector(app.WebReporter{Reporter: collector, MetricsGraphURL: flags.metricsGraphURL, GeoIP: geo}, config); err != nil {
+			log.Fatalf("Error creating anomaly detector: %v", err)
+			return
+		}
+		anomalies.Start(flags.anomaliesInterval)
+		defer anomalies.Stop()
+	}
+	if singleTenant && flags.catalogURL != "" {
+		sink, err := app.NewCatalogSink(flags.catalogURL)
+		if err != nil {
+			log.Fatalf("Error creating catalog sink: %v", err)
+			return
+		}
+		exporter, err := app.NewCatalogExporter(app.WebReporter{Reporter: collector, MetricsGraphURL: flags.metricsGraphURL, GeoIP: geo}, flags.catalogTopology, flags.catalogOwner, sink)
+		if err != nil {
+			log.Fatalf("Error creating catalog exporter: %v", err)
+			return
+		}
+		exporter.Start(flags.catalogInterval)
+		defer exporter.Stop()
+	}
+	// Annotations, saved views and the edges seen are kept with the
+	// reports, when they are stored, and like alerts only for a single
+	// tenant.
+	var (
+		annotations  *app.Annotations
+		views        *app.Views
+		dependencies *app.DependencyTracker
+		exeHashes    *app.ExeHashes
+		recordings   *app.Recordings
+	)
+	if singleTenant {
+		var store app.AnnotationStore
+		if flags.collectorURL == "local" && flags.reportStoreURL != "" {
+			reportStore, err := reportStoreFactory(flags.reportStoreURL)
+			if err != nil {
+				log.Fatalf("Error creating report store: %v", err)
+				return
+			}
+			store, _ = reportStore.(app.AnnotationStore)
+		}
+		annotations, err = app.NewAnnotations(context.Background(), store)
+		if err != nil {
+			log.Fatalf("Error loading annotations: %v", err)
+			return
+		}
+		viewStore, _ := store.(app.ViewStore)
+		views, err = app.NewViews(context.Background(), viewStore)
+		if err != nil {
+			log.Fatalf("Error loading saved views: %v", err)
+			return
+		}
+		if flags.dependenciesInterval > 0 {
+			dependencyStore, _ := store.(app.DependencyStore)
+			dependencies, err = app.NewDependencyTracker(context.Background(), app.WebReporter{Reporter: collector, MetricsGraphURL: flags.metricsGraphURL, GeoIP: geo},
+				strings.Split(flags.dependenciesTopologies, ","), flags.dependenciesLookback, dependencyStore, flags.dependenciesWebhook)
+			if err != nil {
+				log.Fatalf("Error loading dependencies: %v", err)
+				return
+			}
+			dependencies.Start(flags.dependenciesInterval)
+			defer dependencies.Stop()
+		}
+		exeHashes, err = app.NewExeHashes(flags.exeHashesFile)
+		if err != nil {
+			log.Fatalf("Error loading executable hashes: %v", err)
+			return
+		}
+		if flags.recordings {
+			recordingStore, _ := store.(app.RecordingStore)
+			recordings, err = app.NewRecordings(context.Background(), recordingStore, flags.recordingKeystrokes)
+			if err != nil {
+				log.Fatalf("Error loading recordings: %v", err)
+				return
+			}
+		}
+	}
+	if flags.collectorURL == "local" && flags.flowLogsURL != "" {
+		source, err := flowLogSourceFactory(flags.flowLogsURL, time.Now().Add(-flags.flowLogsRetention))
+		if err != nil {
+			log.Fatalf("Error creating flow log source: %v", err)
+			return
+		}
+		ingester := app.NewFlowLogIngester(source, collector, strings.Fields(flags.flowLogsFormat), flags.flowLogsRetention, flags.flowLogsCluster)
+		// Reports only count within the window, so add them more often
+		ingester.Start(flags.window / 2)
+		defer ingester.Stop()
+	}
+	var audit *app.AuditLog
+	if flags.auditFile != "" {
+		audit, err = app.NewAuditLog(flags.auditFile, flags.auditTranscripts)
+		if err != nil {
+			log.Fatalf("Error opening audit log: %v", err)
+			return
+		}
+		defer audit.Close()
+		controlRouter = app.NewAuditedControlRouter(controlRouter, audit)
+		pipeRouter = app.NewAuditedPipeRouter(pipeRouter, audit)
+	}
+	if recordings != nil {
+		controlRouter = app.NewRecordingControlRouter(controlRouter, recordings)
+		pipeRouter = app.NewRecordingPipeRouter(pipeRouter, recordings)
+	}
+	handler := router(collector, controlRouter, pipeRouter, alerter, audit, flags.externalUI, capabilities, flags.metricsGraphURL, geo, layouts, annotations, views, anomalies, dependencies, exeHashes, recordings)
+	var ingestLimiter *app.IngestLimiter
+	if l := flags.ingestLimits; l.ProbeReports > 0 || l.ProbeBytes > 0 || l.TenantReports > 0 || l.TenantBytes > 0 {
+		app.MustRegisterIngestMetrics()
+		ingestLimiter = app.NewIngestLimiter(l, userIDer)
+		handler = ingestLimiter.Wrap(handler)
+	}
+	policy, err := policyFactory(flags)
+	if err != nil {
+		log.Fatalf("Error creating authorization policy: %v", err)
+		return
+	}
+	if policy != nil {
+		handler = app.Authorizer{Policy: policy, ProbeToken: flags.authzProbeToken}.Wrap(handler)
+	}
+	if login, ok := policy.(*app.OIDCLogin); ok {
+		handler = login.Wrap(handler)
+	}
+	var tlsConfig *tls.Config
+	if flags.tls.CertFile != "" {
+		tlsConfig, err = flags.tls.Server(false)
+		if err != nil {
+			log.Fatalf("Error setting up TLS: %v", err)
+			return
+		}
+		if flags.tls.CAFile != "" {
+			handler = app.ProbeCertificates{}.Wrap(handler)
+		}
 	}
-	handler := router(collector, controlRouter, pipeRouter, flags.externalUI, capabilities, flags.metricsGraphURL)
 	if flags.logHTTP {
 		handler = middleware.Log{
 			LogRequestHeaders: flags.logHTTPHeaders,
@@ -301,11 +625,44 @@ func appMain(flags appFlags) {
 	}
 	go func() {
 		log.Infof("listening on %s", flags.listen)
-		if err := server.ListenAndServe(); err != nil {
+		var err error
+		if tlsConfig != nil {
+			err = server.ListenAndServeTLSConfig(tlsConfig)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil {
 			log.Error(err)
 		}
 	}()
 
+	if flags.grpcListen != "" {
+		var options []grpc.ServerOption
+		if tlsConfig != nil {
+			// Only probes stream reports.
+			grpcTLSConfig, err := flags.tls.Server(flags.tls.CAFile != "")
+			if err != nil {
+				log.Fatalf("Error setting up TLS: %v", err)
+				return
+			}
+			options = append(options, grpc.Creds(credentials.NewTLS(grpcTLSConfig)))
+		}
+		grpcServer := grpc.NewServer(options...)
+		app.RegisterIngester(grpcServer, collector, ingestLimiter)
+		lis, err := net.Listen("tcp", flags.grpcListen)
+		if err != nil {
+			log.Fatalf("Error listening on %s: %v", flags.grpcListen, err)
+			return
+		}
+		go func() {
+			log.Infof("listening for gRPC report streams on %s", flags.grpcListen)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Error(err)
+			}
+		}()
+		defer grpcServer.GracefulStop()
+	}
+
 	// block until INT/TERM
 	common.SignalHandlerLoop()
 	// stop listening, wait for any active connections to finish
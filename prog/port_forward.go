@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/kubernetes"
+)
+
+const portForwardUsage = "usage: scope --mode=port-forward [--port-forward.app=URL] <topology> <node ID> [<local port>:]<port>"
+
+// portForwardControls are the controls forwarding ports, of any node
+var portForwardControls = map[string]struct{}{
+	docker.ForwardPort:     {},
+	kubernetes.ForwardPort: {},
+}
+
+// portForwarder forwards the connections to a local port to a port of a
+// container or pod, each through a pipe of the probe reporting it.
+type portForwarder struct {
+	app     *url.URL
+	token   string
+	probeID string
+	nodeID  string
+	control string
+	port    string
+}
+
+// portForwardMain is like kubectl port-forward, but for any container or
+// pod scope reports.
+func portForwardMain(flags portForwardFlags, args []string) {
+	if len(args) != 3 {
+		log.Fatal(portForwardUsage)
+	}
+	localPort, port, err := parsePortForwardPorts(args[2])
+	if err != nil {
+		log.Fatalf("%v; %s", err, portForwardUsage)
+	}
+	app, err := url.Parse(flags.appURL)
+	if err != nil {
+		log.Fatalf("Invalid app URL %q: %v", flags.appURL, err)
+	}
+	f := &portForwarder{app: app, token: flags.token, nodeID: args[1], port: port}
+	if err := f.findControl(args[0]); err != nil {
+		log.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(flags.listenAddress, localPort))
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Forwarding %s to port %s of %s", listener.Addr(), port, f.nodeID)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			if err := f.forward(conn); err != nil {
+				log.Errorf("Error forwarding connection from %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// parsePortForwardPorts parses "8080:80" or "80", for the same local port.
+func parsePortForwardPorts(ports string) (string, string, error) {
+	localPort, port := ports, ports
+	if i := strings.Index(ports, ":"); i >= 0 {
+		localPort, port = ports[:i], ports[i+1:]
+	}
+	for _, p := range []string{localPort, port} {
+		if n, err := strconv.Atoi(p); err != nil || n <= 0 || n > 65535 {
+			return "", "", fmt.Errorf("invalid port: %q", p)
+		}
+	}
+	return localPort, port, nil
+}
+
+func (f *portForwarder) do(method, path string, body io.Reader, result interface{}) error {
+	req, err := http.NewRequest(method, f.app.String()+path, body)
+	if err != nil {
+		return err
+	}
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// findControl finds the port-forward control of the node, and the probe
+// to invoke it with.
+func (f *portForwarder) findControl(topology string) error {
+	var details struct {
+		Node struct {
+			Controls []struct {
+				ProbeID string `json:"probeId"`
+				NodeID  string `json:"nodeId"`
+				ID      string `json:"id"`
+			} `json:"controls"`
+		} `json:"node"`
+	}
+	if err := f.do("GET", "/api/topology/"+url.QueryEscape(topology)+"/"+url.QueryEscape(f.nodeID), nil, &details); err != nil {
+		return err
+	}
+	for _, c := range details.Node.Controls {
+		if _, ok := portForwardControls[c.ID]; ok && c.NodeID == f.nodeID {
+			f.probeID, f.control = c.ProbeID, c.ID
+			return nil
+		}
+	}
+	return fmt.Errorf("%s of %s cannot forward ports (is it running?)", f.nodeID, topology)
+}
+
+// forward forwards a connection through a new pipe, until either end
+// closes it.
+func (f *portForwarder) forward(conn net.Conn) error {
+	defer conn.Close()
+	args, err := json.Marshal(map[string]string{controls.PortForwardPort: f.port})
+	if err != nil {
+		return err
+	}
+	var resp xfer.Response
+	path := "/api/control/" + url.QueryEscape(f.probeID) + "/" + url.QueryEscape(f.nodeID) + "/" + f.control
+	if err := f.do("POST", path, bytes.NewReader(args), &resp); err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	defer f.do("DELETE", "/api/pipe/"+url.QueryEscape(resp.Pipe), nil, nil)
+
+	wsURL := *f.app
+	wsURL.Scheme = strings.Replace(wsURL.Scheme, "http", "ws", 1)
+	header := http.Header{}
+	if f.token != "" {
+		header.Set("Authorization", "Bearer "+f.token)
+	}
+	ws, _, err := xfer.DialWS(websocket.DefaultDialer, wsURL.String()+"/api/pipe/"+url.QueryEscape(resp.Pipe), header)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	errors := make(chan error, 2)
+	go func() {
+		for {
+			_, buf, err := ws.ReadMessage()
+			if err != nil {
+				errors <- err
+				return
+			}
+			if _, err := conn.Write(buf); err != nil {
+				errors <- err
+				return
+			}
+		}
+	}()
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				errors <- err
+				return
+			}
+			if err := ws.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
+				errors <- err
+				return
+			}
+		}
+	}()
+	if err := <-errors; err != io.EOF && !xfer.IsExpectedWSCloseError(err) {
+		return err
+	}
+	return nil
+}
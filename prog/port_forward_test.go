@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/weaveworks/scope/probe/docker"
+)
+
+func TestParsePortForwardPorts(t *testing.T) {
+	for ports, want := range map[string][2]string{
+		"8080:80": {"8080", "80"},
+		"5432":    {"5432", "5432"},
+	} {
+		localPort, port, err := parsePortForwardPorts(ports)
+		assert.NoError(t, err)
+		assert.Equal(t, want, [2]string{localPort, port})
+	}
+	for _, ports := range []string{"", "http", "8080:", ":80", "0:80", "80:65536"} {
+		_, _, err := parsePortForwardPorts(ports)
+		assert.Error(t, err, ports)
+	}
+}
+
+func TestPortForward(t *testing.T) {
+	const nodeID = "abcdef;<container>"
+	var (
+		mtx     sync.Mutex
+		deleted []string
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/topology/containers/", func(w http.ResponseWriter, r *http.Request) {
+		// The details of any node are those of nodeID
+		w.Write([]byte(`{"node": {"controls": [{"probeId": "probe1", "nodeId": "` + nodeID + `", "id": "` + docker.ForwardPort + `"}]}}`))
+	})
+	mux.HandleFunc("/api/control/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/control/probe1/"+url.QueryEscape(nodeID)+"/"+docker.ForwardPort, r.RequestURI)
+		assert.Equal(t, "Bearer s3cr3t", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"pipe": "pipe1"}`))
+	})
+	mux.HandleFunc("/api/pipe/pipe1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			mtx.Lock()
+			deleted = append(deleted, "pipe1")
+			mtx.Unlock()
+			return
+		}
+		// What the probe forwards to: an echo server
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, buf, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, append([]byte("echo "), buf...))
+		}
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	app, _ := url.Parse(ts.URL)
+	f := &portForwarder{app: app, token: "s3cr3t", nodeID: nodeID, port: "80"}
+	assert.NoError(t, f.findControl("containers"))
+	assert.Equal(t, "probe1", f.probeID)
+	assert.Equal(t, docker.ForwardPort, f.control)
+
+	local, remote := net.Pipe()
+	done := make(chan error)
+	go func() { done <- f.forward(remote) }()
+	local.Write([]byte("hello\n"))
+	line, err := bufio.NewReader(local).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "echo hello\n", line)
+	local.Close()
+	assert.NoError(t, <-done)
+	mtx.Lock()
+	assert.Equal(t, []string{"pipe1"}, deleted)
+	mtx.Unlock()
+
+	f = &portForwarder{app: app, nodeID: "other;<container>", port: "80"}
+	assert.Error(t, f.findControl("containers"))
+}
@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -18,19 +19,27 @@ import (
 	"github.com/weaveworks/common/sanitize"
 	"github.com/weaveworks/go-checkpoint"
 	"github.com/weaveworks/scope/common/hostname"
+	"github.com/weaveworks/scope/common/mtls"
 	"github.com/weaveworks/scope/common/weave"
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/probe"
 	"github.com/weaveworks/scope/probe/appclient"
 	"github.com/weaveworks/scope/probe/awsecs"
+	"github.com/weaveworks/scope/probe/cgroup"
 	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/cri"
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/probe/flows"
+	"github.com/weaveworks/scope/probe/gpu"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/probe/nomad"
 	"github.com/weaveworks/scope/probe/overlay"
 	"github.com/weaveworks/scope/probe/plugins"
 	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/probe/systemd"
+	"github.com/weaveworks/scope/probe/unixsocket"
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/weave/common"
 )
@@ -52,6 +61,9 @@ func checkNewScopeVersion(flags probeFlags) {
 	if flags.ecsEnabled {
 		checkpointFlags["ecs_enabled"] = "true"
 	}
+	if flags.nomadEnabled {
+		checkpointFlags["nomad_enabled"] = "true"
+	}
 
 	go func() {
 		handleResponse := func(r *checkpoint.CheckResponse, err error) {
@@ -90,6 +102,7 @@ func maybeExportProfileData(flags probeFlags) {
 func probeMain(flags probeFlags, targets []appclient.Target) {
 	setLogLevel(flags.logLevel)
 	setLogFormatter(flags.logPrefix)
+	defer setupTracing(flags.otlpEndpoint, "scope-probe")()
 
 	// Setup in memory metrics sink
 	inm := metrics.NewInmemSink(time.Minute, 2*time.Minute)
@@ -125,12 +138,28 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 			ProbeID:      probeID,
 			Insecure:     flags.insecure,
 		}
+		if flags.tls != (mtls.Config{}) {
+			probeConfig.TLS = &flags.tls
+		}
 		return appclient.NewAppClient(
 			probeConfig, hostname, url,
 			xfer.ControlHandlerFunc(handlerRegistry.HandleControlRequest),
 		)
 	}
-	clients := appclient.NewMultiAppClient(clientFactory, flags.noControls)
+	var clients appclient.MultiAppClient
+	if flags.publishFailover {
+		config := appclient.FailoverConfig{Targets: targets}
+		if flags.publishBufferDir != "" {
+			buffer, err := appclient.NewDiskBuffer(flags.publishBufferDir, flags.publishBufferSize)
+			if err != nil {
+				log.Fatalf("Failed to open report buffer: %v", err)
+			}
+			config.Buffer = buffer
+		}
+		clients = appclient.NewFailoverAppClient(clientFactory, flags.noControls, config)
+	} else {
+		clients = appclient.NewMultiAppClient(clientFactory, flags.noControls)
+	}
 	defer clients.Stop()
 
 	dnsLookupFn := net.LookupIP
@@ -154,49 +183,92 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 	defer hostReporter.Stop()
 	p.AddReporter(hostReporter)
 	p.AddTagger(probe.NewTopologyTagger(), host.NewTagger(hostID))
+	if flags.cloudMetadata {
+		cloudTagger := host.NewCloudTagger(hostID, flags.cloudMetadataRefresh)
+		defer cloudTagger.Stop()
+		p.AddTagger(cloudTagger)
+	}
 
 	var processCache *process.CachingWalker
 	if flags.procEnabled {
-		processCache = process.NewCachingWalker(process.NewWalker(flags.procRoot, false))
+		processCache = process.NewCachingWalker(process.NewWalker(flags.procRoot, false, flags.ctxSwitches, flags.exeHashes))
 		p.AddTicker(processCache)
 		p.AddReporter(process.NewReporter(processCache, hostID, process.GetDeltaTotalJiffies, flags.noCommandLineArguments))
+		if flags.unixSockets {
+			p.AddReporter(unixsocket.NewReporter(hostID, flags.procRoot))
+		}
 	}
 
-	dnsSnooper, err := endpoint.NewDNSSnooper()
+	dnsSnooper, err := endpoint.NewDNSSnooper(flags.dnsMaxAge)
 	if err != nil {
 		log.Errorf("Failed to start DNS snooper: nodes for external services will be less accurate: %s", err)
 	} else {
 		defer dnsSnooper.Stop()
 	}
 
+	var sniSnooper *endpoint.SNISnooper
+	if flags.snoopTLS {
+		if sniSnooper, err = endpoint.NewSNISnooper(); err != nil {
+			log.Errorf("Failed to start TLS server name snooper: %s", err)
+		} else {
+			defer sniSnooper.Stop()
+		}
+	}
+
+	var sharedDNS endpoint.SharedDNSCache
+	if flags.shareDNS {
+		sharedDNS = clients
+	}
+
+	// Pod IPs are only unique within a cluster.
+	clusterScope := flags.kubernetesClusterName
 	endpointReporter := endpoint.NewReporter(endpoint.ReporterConfig{
-		HostID:       hostID,
-		HostName:     hostName,
-		SpyProcs:     flags.spyProcs,
-		UseConntrack: flags.useConntrack,
-		WalkProc:     flags.procEnabled,
-		UseEbpfConn:  flags.useEbpfConn,
-		ProcRoot:     flags.procRoot,
-		BufferSize:   flags.conntrackBufferSize,
-		ProcessCache: processCache,
-		DNSSnooper:   dnsSnooper,
+		HostID:             hostID,
+		HostName:           hostName,
+		ClusterScope:       clusterScope,
+		SpyProcs:           flags.spyProcs,
+		UseConntrack:       flags.useConntrack,
+		WalkProc:           flags.procEnabled,
+		UseEbpfConn:        flags.useEbpfConn,
+		SampleRTT:          flags.sampleRTT,
+		SampleBytes:        flags.sampleBytes,
+		TrackUDP:           flags.trackUDP,
+		MapIPVS:            flags.mapIPVS,
+		ShortLivedSampling: flags.shortLivedSampling,
+		ProcRoot:           flags.procRoot,
+		BufferSize:         flags.conntrackBufferSize,
+		ProcessCache:       processCache,
+		DNSSnooper:         dnsSnooper,
+		SNISnooper:         sniSnooper,
+		SharedDNS:          sharedDNS,
 	})
 	defer endpointReporter.Stop()
 	p.AddReporter(endpointReporter)
 
-	if flags.dockerEnabled {
+	if flags.dockerEnabled || flags.podmanEnabled {
+		bridge, endpoint := flags.dockerBridge, ""
+		if flags.podmanEnabled {
+			// Podman serves the Docker API, so the same registry reports its
+			// containers, in place of Docker's.
+			bridge, endpoint = flags.podmanBridge, flags.podmanEndpoint
+			if endpoint == "" {
+				endpoint = docker.PodmanEndpoint()
+			}
+		}
 		// Don't add the bridge in Kubernetes since container IPs are global and
 		// shouldn't be scoped
 		if !flags.kubernetesEnabled {
-			if err := report.AddLocalBridge(flags.dockerBridge); err != nil {
-				log.Errorf("Docker: problem with bridge %s: %v", flags.dockerBridge, err)
+			if err := report.AddLocalBridge(bridge); err != nil {
+				log.Errorf("Docker: problem with bridge %s: %v", bridge, err)
 			}
 		}
 		options := docker.RegistryOptions{
 			Interval:               flags.dockerInterval,
+			DockerEndpoint:         endpoint,
 			Pipes:                  clients,
 			CollectStats:           true,
 			HostID:                 hostID,
+			ClusterScope:           clusterScope,
 			HandlerRegistry:        handlerRegistry,
 			NoCommandLineArguments: flags.noCommandLineArguments,
 			NoEnvironmentVariables: flags.noEnvironmentVariables,
@@ -206,16 +278,90 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 			if flags.procEnabled {
 				p.AddTagger(docker.NewTagger(registry, processCache))
 			}
-			p.AddReporter(docker.NewReporter(registry, hostID, probeID, p))
+			if flags.scanner != "" {
+				if scanner, err := docker.NewScanner(flags.scanner, flags.scannerURL); err == nil {
+					tagger := docker.NewVulnerabilityTagger(registry, scanner, flags.scannerInterval)
+					defer tagger.Stop()
+					p.AddTagger(tagger)
+				} else {
+					log.Errorf("Docker: failed to start vulnerability scanner: %v", err)
+				}
+			}
+			if flags.registryPoll {
+				var insecure []string
+				if flags.registryInsecure != "" {
+					insecure = strings.Split(flags.registryInsecure, ",")
+				}
+				tagger := docker.NewRegistryTagger(registry, insecure, flags.registryInterval)
+				defer tagger.Stop()
+				p.AddTagger(tagger)
+			}
+			p.AddReporter(docker.NewReporter(registry, hostID, clusterScope, probeID, p))
+			if flags.dockerSwarm && !flags.podmanEnabled {
+				if client, err := docker.NewSwarmClient(endpoint); err == nil {
+					reporter := docker.NewSwarmReporter(client, probeID, flags.dockerInterval, handlerRegistry)
+					defer reporter.Stop()
+					p.AddReporter(reporter)
+				} else {
+					log.Errorf("Docker: failed to start swarm client: %v", err)
+				}
+			}
 		} else {
 			log.Errorf("Docker: failed to start registry: %v", err)
 		}
 	}
 
+	if flags.criEnabled {
+		if client, err := cri.NewClient(flags.criEndpoint); err == nil {
+			reporter := cri.NewReporter(client, hostID, clusterScope, probeID)
+			defer reporter.Stop()
+			if flags.procEnabled {
+				p.AddTagger(reporter)
+			}
+			p.AddReporter(reporter)
+		} else {
+			log.Errorf("CRI: failed to connect to %s: %v", flags.criEndpoint, err)
+		}
+	}
+
+	if flags.gpuEnabled {
+		reporter := gpu.NewReporter(hostID, flags.procRoot)
+		p.AddReporter(reporter)
+		if flags.procEnabled {
+			p.AddTagger(reporter)
+		}
+	}
+
+	if flags.pressureEnabled {
+		reporter := cgroup.NewReporter(hostID, flags.procRoot)
+		p.AddReporter(reporter)
+		if flags.procEnabled {
+			p.AddTagger(reporter)
+		}
+	}
+
+	if flags.systemdEnabled {
+		reporter := systemd.NewReporter(hostID, probeID, flags.procRoot, handlerRegistry)
+		defer reporter.Stop()
+		p.AddReporter(reporter)
+		if flags.procEnabled {
+			p.AddTagger(reporter)
+		}
+	}
+
+	if flags.flowsListen != "" {
+		if reporter, err := flows.NewReporter(strings.Split(flags.flowsListen, ","), flags.flowsExpiry, clusterScope); err == nil {
+			defer reporter.Stop()
+			p.AddReporter(reporter)
+		} else {
+			log.Errorf("Flows: failed to listen: %v", err)
+		}
+	}
+
 	if flags.kubernetesEnabled {
 		if client, err := kubernetes.NewClient(flags.kubernetesClientConfig); err == nil {
 			defer client.Stop()
-			reporter := kubernetes.NewReporter(client, clients, probeID, hostID, p, handlerRegistry, flags.kubernetesNodeName, flags.kubernetesKubeletPort)
+			reporter := kubernetes.NewReporter(client, clients, probeID, hostID, p, handlerRegistry, flags.kubernetesNodeName, flags.kubernetesKubeletPort, flags.kubernetesClusterName)
 			defer reporter.Stop()
 			p.AddReporter(reporter)
 			p.AddTagger(reporter)
@@ -226,7 +372,18 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 	}
 
 	if flags.ecsEnabled {
-		reporter := awsecs.Make(flags.ecsCacheSize, flags.ecsCacheExpiry, flags.ecsClusterRegion, handlerRegistry, probeID)
+		var fargateClusters []string
+		if flags.ecsFargate != "" {
+			fargateClusters = strings.Split(flags.ecsFargate, ",")
+		}
+		reporter := awsecs.Make(flags.ecsCacheSize, flags.ecsCacheExpiry, flags.ecsClusterRegion, fargateClusters, handlerRegistry, probeID)
+		defer reporter.Stop()
+		p.AddReporter(reporter)
+		p.AddTagger(reporter)
+	}
+
+	if flags.nomadEnabled {
+		reporter := nomad.NewReporter(nomad.NewClient(flags.nomadAddress, flags.nomadToken), hostID, probeID, flags.procRoot, handlerRegistry)
 		defer reporter.Stop()
 		p.AddReporter(reporter)
 		p.AddTagger(reporter)
@@ -234,7 +391,7 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 
 	if flags.weaveEnabled {
 		client := weave.NewClient(sanitize.URL("http://", 6784, "")(flags.weaveAddr))
-		weave, err := overlay.NewWeave(hostID, client)
+		weave, err := overlay.NewWeave(hostID, clusterScope, client)
 		if err != nil {
 			log.Errorf("Weave: failed to start client: %v", err)
 		} else {
@@ -0,0 +1,23 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// ComposeServiceRenderer is a Renderer for the services of Docker Compose
+// projects
+//
+// not memoised
+var ComposeServiceRenderer = ConditionalRenderer(renderComposeTopologies,
+	renderParents(
+		report.Container, []string{report.ComposeService}, UnmanagedID,
+		MakeFilter(
+			IsRunning,
+			ContainerWithImageNameRenderer,
+		),
+	),
+)
+
+func renderComposeTopologies(rpt report.Report) bool {
+	return len(rpt.ComposeService.Nodes) >= 1
+}
@@ -230,12 +230,14 @@ func MapContainer2IP(m report.Node) []string {
 	}
 
 	// Also output all the host:port port mappings (see above comment).
-	// In this case we assume this doesn't need a scope, as they are for host IPs.
+	// In this case we assume this doesn't need a scope, as they are for host IPs,
+	// other than that of the cluster, if any.
+	cluster, _ := m.Latest.Lookup(report.KubernetesCluster)
 	ports, _ := m.Sets.Lookup(docker.ContainerPorts)
 	for _, portMapping := range ports {
 		if mapping := portMappingMatch.FindStringSubmatch(portMapping); mapping != nil {
 			ip, port := mapping[1], mapping[2]
-			id := report.MakeScopedEndpointNodeID("", ip, port)
+			id := report.MakeScopedEndpointNodeID(cluster, ip, port)
 			result = append(result, id)
 		}
 	}
@@ -270,6 +272,7 @@ func MapProcess2Container(n report.Node) report.Nodes {
 	if containerID, ok := n.Latest.Lookup(docker.ContainerID); ok {
 		id = report.MakeContainerNodeID(containerID)
 		node = NewDerivedNode(id, n).WithTopology(report.Container)
+		node = propagateLatestPrefix(report.ListeningPortsTablePrefix, n, node)
 	} else {
 		hostID, _, _ := report.ParseProcessNodeID(n.ID)
 		id = MakePseudoNodeID(UncontainedID, hostID)
@@ -0,0 +1,16 @@
+package detailed
+
+import (
+	"time"
+)
+
+// Annotation is what users have noted about a node: a note, tags and
+// whether it is pinned, e.g. to mark it as known good, or as suspicious.
+type Annotation struct {
+	NodeID  string    `json:"nodeId"`
+	Note    string    `json:"note,omitempty"`
+	Tags    []string  `json:"tags,omitempty"`
+	Pinned  bool      `json:"pinned,omitempty"`
+	User    string    `json:"user,omitempty"`
+	Updated time.Time `json:"updated"`
+}
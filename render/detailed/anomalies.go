@@ -0,0 +1,25 @@
+package detailed
+
+import (
+	"time"
+)
+
+// Kinds of anomalies
+const (
+	AnomalyNewEdge   = "new_edge"   // a node connects to one it never did
+	AnomalyEdgeSpike = "edge_spike" // a node connects to another far more than usual
+	AnomalyMetric    = "metric"     // a metric of a node is far from its usual level
+)
+
+// Anomaly is something unusual about a node, compared to the baseline
+// learnt of it: of one of its edges, to Peer, or of one of its metrics.
+type Anomaly struct {
+	Kind     string    `json:"kind"`
+	Topology string    `json:"topology"`
+	NodeID   string    `json:"nodeId"`
+	Peer     string    `json:"peer,omitempty"`
+	Metric   string    `json:"metric,omitempty"`
+	Value    float64   `json:"value"`
+	Baseline float64   `json:"baseline"`
+	Since    time.Time `json:"since"`
+}
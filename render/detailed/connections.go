@@ -2,34 +2,122 @@ package detailed
 
 import (
 	"fmt"
+	"net"
 	"sort"
 	"strconv"
+	"strings"
 
+	"github.com/weaveworks/scope/common/geoip"
 	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/report"
 )
 
 const (
-	portKey     = "port"
-	portLabel   = "Port"
-	countKey    = "count"
-	countLabel  = "Count"
-	remoteKey   = "remote"
-	remoteLabel = "Remote"
-	number      = "number"
+	portKey      = "port"
+	portLabel    = "Port"
+	protoKey     = "protocol"
+	protoLabel   = "Protocol"
+	countKey     = "count"
+	countLabel   = "Count"
+	rateKey      = "rate"
+	rateLabel    = "Conn/s"
+	sentKey      = "bytes_sent"
+	sentLabel    = "Sent"
+	recvKey      = "bytes_received"
+	recvLabel    = "Received"
+	retransKey   = "retransmits"
+	retransLabel = "Retransmits"
+	resetsKey    = "resets"
+	resetsLabel  = "Resets"
+	rttP50Key    = "rtt_p50"
+	rttP50Label  = "RTT p50 (ms)"
+	rttP99Key    = "rtt_p99"
+	rttP99Label  = "RTT p99 (ms)"
+	sniKey       = "sni"
+	sniLabel     = "SNI"
+	remoteKey    = "remote"
+	remoteLabel  = "Remote"
+	number       = "number"
+
+	directionKey   = "direction"
+	directionLabel = "Direction"
+	inCountKey     = "count_in"
+	inCountLabel   = "Inbound"
+	outCountKey    = "count_out"
+	outCountLabel  = "Outbound"
+	directionIn    = "inbound"
+	directionOut   = "outbound"
+	directionBoth  = "both"
+
+	countryKey   = "country"
+	countryLabel = "Country"
+	asnKey       = "asn"
+	asnLabel     = "ASN"
+
+	nameSourceKey   = "name_source"
+	nameSourceLabel = "Name source"
 )
 
 // Exported for testing
 var (
 	NormalColumns = []Column{
 		{ID: portKey, Label: portLabel, Datatype: report.Number},
+		{ID: protoKey, Label: protoLabel},
 		{ID: countKey, Label: countLabel, Datatype: report.Number, DefaultSort: true},
+		{ID: rateKey, Label: rateLabel, Datatype: report.Number},
+		{ID: sentKey, Label: sentLabel, Datatype: report.Number},
+		{ID: recvKey, Label: recvLabel, Datatype: report.Number},
+		{ID: retransKey, Label: retransLabel, Datatype: report.Number},
+		{ID: resetsKey, Label: resetsLabel, Datatype: report.Number},
+		{ID: rttP50Key, Label: rttP50Label, Datatype: report.Number},
+		{ID: rttP99Key, Label: rttP99Label, Datatype: report.Number},
+		{ID: sniKey, Label: sniLabel},
 	}
 	InternetColumns = []Column{
 		{ID: remoteKey, Label: remoteLabel},
 		{ID: portKey, Label: portLabel, Datatype: report.Number},
+		{ID: protoKey, Label: protoLabel},
 		{ID: countKey, Label: countLabel, Datatype: report.Number, DefaultSort: true},
+		{ID: rateKey, Label: rateLabel, Datatype: report.Number},
+		{ID: sentKey, Label: sentLabel, Datatype: report.Number},
+		{ID: recvKey, Label: recvLabel, Datatype: report.Number},
+		{ID: retransKey, Label: retransLabel, Datatype: report.Number},
+		{ID: resetsKey, Label: resetsLabel, Datatype: report.Number},
+		{ID: rttP50Key, Label: rttP50Label, Datatype: report.Number},
+		{ID: rttP99Key, Label: rttP99Label, Datatype: report.Number},
+		{ID: sniKey, Label: sniLabel},
+	}
+	MergedColumns = []Column{
+		{ID: directionKey, Label: directionLabel},
+		{ID: inCountKey, Label: inCountLabel, Datatype: report.Number, DefaultSort: true},
+		{ID: outCountKey, Label: outCountLabel, Datatype: report.Number},
+		{ID: sentKey, Label: sentLabel, Datatype: report.Number},
+		{ID: recvKey, Label: recvLabel, Datatype: report.Number},
+		{ID: retransKey, Label: retransLabel, Datatype: report.Number},
+		{ID: resetsKey, Label: resetsLabel, Datatype: report.Number},
+	}
+	MergedInternetColumns = []Column{
+		{ID: remoteKey, Label: remoteLabel},
+		{ID: directionKey, Label: directionLabel},
+		{ID: inCountKey, Label: inCountLabel, Datatype: report.Number, DefaultSort: true},
+		{ID: outCountKey, Label: outCountLabel, Datatype: report.Number},
+		{ID: sentKey, Label: sentLabel, Datatype: report.Number},
+		{ID: recvKey, Label: recvLabel, Datatype: report.Number},
+		{ID: retransKey, Label: retransLabel, Datatype: report.Number},
+		{ID: resetsKey, Label: resetsLabel, Datatype: report.Number},
+	}
+	// GeoColumns are added to tables with internet rows when a GeoIP
+	// database is configured.
+	GeoColumns = []Column{
+		{ID: countryKey, Label: countryLabel},
+		{ID: asnKey, Label: asnLabel},
+	}
+	// NameSourceColumns are added to tables with internet rows labelled
+	// by DNS names, telling where the names came from.
+	NameSourceColumns = []Column{
+		{ID: nameSourceKey, Label: nameSourceLabel},
 	}
 )
 
@@ -40,6 +128,27 @@ type ConnectionsSummary struct {
 	Label       string       `json:"label"`
 	Columns     []Column     `json:"columns"`
 	Connections []Connection `json:"connections"`
+	// Total is the number of connections in the table before paging.
+	// Only set by Page().
+	Total  int `json:"total,omitempty"`
+	Offset int `json:"offset,omitempty"`
+}
+
+// Page returns a copy of the summary with only the connections in
+// [offset, offset+limit). A limit <= 0 means no limit.
+func (s ConnectionsSummary) Page(offset, limit int) ConnectionsSummary {
+	s.Total = len(s.Connections)
+	s.Offset = offset
+	if offset > len(s.Connections) {
+		offset = len(s.Connections)
+	}
+	end := len(s.Connections)
+	// Not offset+limit < end, which overflows for huge limits
+	if limit > 0 && limit < end-offset {
+		end = offset + limit
+	}
+	s.Connections = s.Connections[offset:end]
+	return s
 }
 
 // Connection is a row in the connections table.
@@ -49,6 +158,10 @@ type Connection struct {
 	Label      string               `json:"label"`
 	LabelMinor string               `json:"labelMinor,omitempty"`
 	Metadata   []report.MetadataRow `json:"metadata,omitempty"`
+	// IDs of the processes owning the connections on either end, if
+	// known and the same for all connections in the row.
+	ProcessNodeID      string `json:"processNodeId,omitempty"`
+	LocalProcessNodeID string `json:"localProcessNodeId,omitempty"`
 }
 
 type connectionsByID []Connection
@@ -61,16 +174,127 @@ func (s connectionsByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
 type connection struct {
 	remoteNodeID          string
 	remoteAddr, localAddr string // for internet nodes only
+	internetIP            string // address of the internet side, if any
+	nameSource            string // where the name of the internet side came from
 	port                  string // destination port
+	protocol              string // transport protocol, e.g. tcp or udp
+}
+
+// Aggregated figures for a row in the connections table. Byte counts
+// are from the point of view of the local node.
+type connectionStats struct {
+	count         int
+	bytesSent     int
+	bytesReceived int
+	hasBytes      bool
+	retransmits   int
+	resets        int
+	rtts          []int // round-trip times of the connections, in microseconds
+	sniNames      report.StringSet
+	// process node IDs owning the connections on either end
+	localProcesses, remoteProcesses report.StringSet
+}
+
+func (s connectionStats) add(other connectionStats) connectionStats {
+	return connectionStats{
+		count:         s.count + other.count,
+		bytesSent:     s.bytesSent + other.bytesSent,
+		bytesReceived: s.bytesReceived + other.bytesReceived,
+		hasBytes:      s.hasBytes || other.hasBytes,
+		retransmits:   s.retransmits + other.retransmits,
+		resets:        s.resets + other.resets,
+		rtts:          append(append([]int{}, s.rtts...), other.rtts...),
+		sniNames:      s.sniNames.Merge(other.sniNames),
+
+		localProcesses:  s.localProcesses.Merge(other.localProcesses),
+		remoteProcesses: s.remoteProcesses.Merge(other.remoteProcesses),
+	}
+}
+
+// processNodeID returns the only process in ids, if there is just one.
+func processNodeID(ids report.StringSet) string {
+	if len(ids) != 1 {
+		return ""
+	}
+	return ids[0]
+}
+
+// percentile returns the p-th percentile (nearest rank) of sorted.
+func percentile(sorted []int, p int) int {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func formatRTT(us int) string {
+	return strconv.FormatFloat(float64(us)/1000, 'f', 2, 64)
+}
+
+// ConnectionsFilter restricts the rows of the connection tables to
+// those matching a destination port range and/or remote networks. The
+// zero value matches everything.
+type ConnectionsFilter struct {
+	MinPort, MaxPort int          // inclusive, ignored if MaxPort is 0
+	Networks         []*net.IPNet // remote address must be in one of them, if any
+}
+
+func (f ConnectionsFilter) matches(port string, remoteEndpoint report.Node) bool {
+	if f.MaxPort > 0 {
+		p, err := strconv.Atoi(port)
+		if err != nil || p < f.MinPort || p > f.MaxPort {
+			return false
+		}
+	}
+	if len(f.Networks) == 0 {
+		return true
+	}
+	_, addr, _, ok := report.ParseEndpointNodeID(remoteEndpoint.ID)
+	if !ok {
+		return false
+	}
+	ip := net.ParseIP(addr)
+	for _, n := range f.Networks {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
 }
 
 type connectionCounters struct {
-	counted map[string]struct{}
-	counts  map[connection]int
+	filter    ConnectionsFilter
+	processes report.Nodes
+	counted   map[string]struct{}
+	counts    map[connection]connectionStats
+}
+
+func newConnectionCounters(rc RenderContext) *connectionCounters {
+	return &connectionCounters{
+		filter:    rc.ConnectionsFilter,
+		processes: rc.Process.Nodes,
+		counted:   map[string]struct{}{},
+		counts:    map[connection]connectionStats{},
+	}
 }
 
-func newConnectionCounters() *connectionCounters {
-	return &connectionCounters{counted: map[string]struct{}{}, counts: map[connection]int{}}
+// processOf returns the ID of the process owning an endpoint, if it is
+// in the report.
+func (c *connectionCounters) processOf(ep report.Node) (string, bool) {
+	pid, ok := ep.Latest.Lookup(process.PID)
+	if !ok {
+		return "", false
+	}
+	hostID := report.ExtractHostID(ep)
+	if hostID == "" {
+		return "", false
+	}
+	id := report.MakeProcessNodeID(hostID, pid)
+	if _, ok := c.processes[id]; !ok {
+		return "", false
+	}
+	return id, true
 }
 
 func (c *connectionCounters) add(outgoing bool, localNode, remoteNode, localEndpoint, remoteEndpoint report.Node) {
@@ -90,47 +314,173 @@ func (c *connectionCounters) add(outgoing bool, localNode, remoteNode, localEndp
 
 	conn := connection{remoteNodeID: remoteNode.ID}
 	var ok bool
-	if _, _, conn.port, ok = report.ParseEndpointNodeID(dstEndpoint.ID); !ok {
+	if _, _, conn.port, conn.protocol, ok = report.ParseEndpointNodeIDWithProtocol(dstEndpoint.ID); !ok {
+		return
+	}
+	if !c.filter.matches(conn.port, remoteEndpoint) {
 		return
 	}
 	// For internet nodes we break out individual addresses
-	if conn.remoteAddr, ok = internetAddr(remoteNode, remoteEndpoint); !ok {
+	var remoteIP, localIP, remoteSource, localSource string
+	if conn.remoteAddr, remoteIP, remoteSource, ok = internetAddr(remoteNode, remoteEndpoint); !ok {
 		return
 	}
-	if conn.localAddr, ok = internetAddr(localNode, localEndpoint); !ok {
+	if conn.localAddr, localIP, localSource, ok = internetAddr(localNode, localEndpoint); !ok {
 		return
 	}
+	conn.internetIP, conn.nameSource = remoteIP, remoteSource
+	if localIP != "" {
+		conn.internetIP, conn.nameSource = localIP, localSource
+	}
 
 	c.counted[connectionID] = struct{}{}
-	c.counts[conn]++
+	stats := c.counts[conn]
+	// Sampled short-lived connections stand for several connections
+	if weight, ok := srcEndpoint.Counters.Lookup(endpoint.SampledConnections); ok && weight > 0 {
+		stats.count += weight
+	} else {
+		stats.count++
+	}
+	// Byte counters are carried by the source endpoint of the
+	// connection, so flip them around for incoming connections.
+	egress, hasEgress := srcEndpoint.Counters.Lookup(endpoint.EgressBytes)
+	ingress, hasIngress := srcEndpoint.Counters.Lookup(endpoint.IngressBytes)
+	if !outgoing {
+		egress, ingress = ingress, egress
+	}
+	if hasEgress || hasIngress {
+		stats.bytesSent += egress
+		stats.bytesReceived += ingress
+		stats.hasBytes = true
+	}
+	// So are retransmit and reset counts, which don't need flipping.
+	if retransmits, ok := srcEndpoint.Counters.Lookup(endpoint.TCPRetransmits); ok {
+		stats.retransmits += retransmits
+	}
+	if resets, ok := srcEndpoint.Counters.Lookup(endpoint.TCPResets); ok {
+		stats.resets += resets
+	}
+	if id, ok := c.processOf(localEndpoint); ok {
+		stats.localProcesses = stats.localProcesses.Add(id)
+	}
+	if id, ok := c.processOf(remoteEndpoint); ok {
+		stats.remoteProcesses = stats.remoteProcesses.Add(id)
+	}
+	// The round-trip time is sampled on whichever side the probe saw.
+	for _, ep := range []report.Node{srcEndpoint, dstEndpoint} {
+		if v, ok := ep.Latest.Lookup(endpoint.RTT); ok {
+			if rtt, err := strconv.Atoi(v); err == nil {
+				stats.rtts = append(stats.rtts, rtt)
+				break
+			}
+		}
+	}
+	// So is the TLS server name, attached to the server endpoint.
+	for _, ep := range []report.Node{srcEndpoint, dstEndpoint} {
+		if names, ok := ep.Sets.Lookup(endpoint.SNINames); ok {
+			stats.sniNames = stats.sniNames.Merge(names)
+		}
+	}
+	c.counts[conn] = stats
+}
+
+// failureRows returns the retransmits and resets of connections, if
+// there were any.
+func failureRows(stats connectionStats) []report.MetadataRow {
+	var rows []report.MetadataRow
+	if stats.retransmits > 0 {
+		rows = append(rows, report.MetadataRow{ID: retransKey, Value: strconv.Itoa(stats.retransmits)})
+	}
+	if stats.resets > 0 {
+		rows = append(rows, report.MetadataRow{ID: resetsKey, Value: strconv.Itoa(stats.resets)})
+	}
+	return rows
 }
 
-func internetAddr(node report.Node, ep report.Node) (string, bool) {
+// internetAddr returns the label and address of an internet endpoint,
+// and where the name in the label came from.
+func internetAddr(node report.Node, ep report.Node) (string, string, string, bool) {
 	if !render.IsInternetNode(node) {
-		return "", true
+		return "", "", "", true
 	}
-	_, addr, _, ok := report.ParseEndpointNodeID(ep.ID)
+	_, ip, _, ok := report.ParseEndpointNodeID(ep.ID)
 	if !ok {
-		return "", false
+		return "", "", "", false
 	}
-	if name, found := render.DNSFirstMatch(ep, func(string) bool { return true }); found {
+	addr := ip
+	name, source, found := render.DNSFirstMatchWithSource(ep, func(string) bool { return true })
+	if found {
 		// we show the "most important" name only, since we don't have
 		// space for more
 		addr = fmt.Sprintf("%s (%s)", name, addr)
 	}
-	return addr, true
+	return addr, ip, source, true
+}
+
+// nameSourceRows tells where the name of an internet address came from.
+func nameSourceRows(source string) []report.MetadataRow {
+	if source == "" {
+		return nil
+	}
+	return []report.MetadataRow{{ID: nameSourceKey, Value: source}}
+}
+
+// geoRows looks up where an internet address lives.
+func geoRows(geo geoip.Resolver, addr string) []report.MetadataRow {
+	if geo == nil || addr == "" {
+		return nil
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil
+	}
+	info, ok := geo.Lookup(ip)
+	if !ok {
+		return nil
+	}
+	var rows []report.MetadataRow
+	if info.Country != "" {
+		rows = append(rows, report.MetadataRow{ID: countryKey, Value: info.Country})
+	}
+	if info.ASN != "" {
+		rows = append(rows, report.MetadataRow{ID: asnKey, Value: info.ASN})
+	}
+	return rows
+}
+
+// withGeoColumns adds the GeoColumns to columns if any of the
+// connections carries geo metadata, and likewise for the
+// NameSourceColumns.
+func withGeoColumns(columns []Column, connections []Connection) []Column {
+	return withColumnsIfUsed(withColumnsIfUsed(columns, connections, GeoColumns), connections, NameSourceColumns)
 }
 
-func (c *connectionCounters) rows(r report.Report, ns report.Nodes, includeLocal bool) []Connection {
+func withColumnsIfUsed(columns []Column, connections []Connection, extra []Column) []Column {
+	for _, c := range connections {
+		for _, row := range c.Metadata {
+			for _, column := range extra {
+				if row.ID == column.ID {
+					return append(append([]Column{}, columns...), extra...)
+				}
+			}
+		}
+	}
+	return columns
+}
+
+func (c *connectionCounters) rows(rc RenderContext, ns report.Nodes, includeLocal bool) []Connection {
+	r := rc.Report
 	output := []Connection{}
-	for row, count := range c.counts {
+	for row, stats := range c.counts {
 		// Use MakeBasicNodeSummary to render the id and label of this node
 		summary, _ := MakeBasicNodeSummary(r, ns[row.remoteNodeID])
 		connection := Connection{
-			ID:         fmt.Sprintf("%s-%s-%s-%s", row.remoteNodeID, row.remoteAddr, row.localAddr, row.port),
-			NodeID:     summary.ID,
-			Label:      summary.Label,
-			LabelMinor: summary.LabelMinor,
+			ID:                 fmt.Sprintf("%s-%s-%s-%s-%s", row.remoteNodeID, row.remoteAddr, row.localAddr, row.port, row.protocol),
+			NodeID:             summary.ID,
+			Label:              summary.Label,
+			LabelMinor:         summary.LabelMinor,
+			ProcessNodeID:      processNodeID(stats.remoteProcesses),
+			LocalProcessNodeID: processNodeID(stats.localProcesses),
 		}
 		if row.remoteAddr != "" {
 			connection.Label = row.remoteAddr
@@ -148,20 +498,83 @@ func (c *connectionCounters) rows(r report.Report, ns report.Nodes, includeLocal
 				ID:    portKey,
 				Value: row.port,
 			},
+			report.MetadataRow{
+				ID:    protoKey,
+				Value: row.protocol,
+			},
 			report.MetadataRow{
 				ID:    countKey,
-				Value: strconv.Itoa(count),
+				Value: strconv.Itoa(stats.count),
 			},
 		)
+		if r.Window > 0 {
+			connection.Metadata = append(connection.Metadata,
+				report.MetadataRow{
+					ID:    rateKey,
+					Value: strconv.FormatFloat(float64(stats.count)/r.Window.Seconds(), 'f', 2, 64),
+				})
+		}
+		if stats.hasBytes {
+			connection.Metadata = append(connection.Metadata,
+				report.MetadataRow{
+					ID:    sentKey,
+					Value: strconv.Itoa(stats.bytesSent),
+				},
+				report.MetadataRow{
+					ID:    recvKey,
+					Value: strconv.Itoa(stats.bytesReceived),
+				},
+			)
+		}
+		connection.Metadata = append(connection.Metadata, failureRows(stats)...)
+		if len(stats.rtts) > 0 {
+			sort.Ints(stats.rtts)
+			connection.Metadata = append(connection.Metadata,
+				report.MetadataRow{
+					ID:    rttP50Key,
+					Value: formatRTT(percentile(stats.rtts, 50)),
+				},
+				report.MetadataRow{
+					ID:    rttP99Key,
+					Value: formatRTT(percentile(stats.rtts, 99)),
+				},
+			)
+		}
+		if len(stats.sniNames) > 0 {
+			connection.Metadata = append(connection.Metadata,
+				report.MetadataRow{
+					ID:    sniKey,
+					Value: strings.Join(stats.sniNames, ", "),
+				},
+			)
+		}
+		connection.Metadata = append(connection.Metadata, geoRows(rc.GeoIP, row.internetIP)...)
+		connection.Metadata = append(connection.Metadata, nameSourceRows(row.nameSource)...)
 		output = append(output, connection)
 	}
 	sort.Sort(connectionsByID(output))
 	return output
 }
 
-func incomingConnectionsSummary(topologyID string, r report.Report, n report.Node, ns report.Nodes) ConnectionsSummary {
+func incomingConnectionsSummary(topologyID string, rc RenderContext, n report.Node, ns report.Nodes) ConnectionsSummary {
+	columnHeaders := NormalColumns
+	if render.IsInternetNode(n) {
+		columnHeaders = InternetColumns
+	}
+	connections := incomingConnectionCounters(rc, n, ns).rows(rc, ns, render.IsInternetNode(n))
+	return ConnectionsSummary{
+		ID:          "incoming-connections",
+		TopologyID:  topologyID,
+		Label:       "Inbound",
+		Columns:     withGeoColumns(columnHeaders, connections),
+		Connections: connections,
+	}
+}
+
+func incomingConnectionCounters(rc RenderContext, n report.Node, ns report.Nodes) *connectionCounters {
+	r := rc.Report
 	localEndpointIDs, localEndpointIDCopies := endpointChildIDsAndCopyMapOf(n)
-	counts := newConnectionCounters()
+	counts := newConnectionCounters(rc)
 
 	// For each node which has an edge TO me
 	for _, node := range ns {
@@ -175,23 +588,28 @@ func incomingConnectionsSummary(topologyID string, r report.Report, n report.Nod
 			}
 		}
 	}
+	return counts
+}
 
+func outgoingConnectionsSummary(topologyID string, rc RenderContext, n report.Node, ns report.Nodes) ConnectionsSummary {
 	columnHeaders := NormalColumns
 	if render.IsInternetNode(n) {
 		columnHeaders = InternetColumns
 	}
+	connections := outgoingConnectionCounters(rc, n, ns).rows(rc, ns, render.IsInternetNode(n))
 	return ConnectionsSummary{
-		ID:          "incoming-connections",
+		ID:          "outgoing-connections",
 		TopologyID:  topologyID,
-		Label:       "Inbound",
-		Columns:     columnHeaders,
-		Connections: counts.rows(r, ns, render.IsInternetNode(n)),
+		Label:       "Outbound",
+		Columns:     withGeoColumns(columnHeaders, connections),
+		Connections: connections,
 	}
 }
 
-func outgoingConnectionsSummary(topologyID string, r report.Report, n report.Node, ns report.Nodes) ConnectionsSummary {
+func outgoingConnectionCounters(rc RenderContext, n report.Node, ns report.Nodes) *connectionCounters {
+	r := rc.Report
 	localEndpoints := endpointChildrenOf(n)
-	counts := newConnectionCounters()
+	counts := newConnectionCounters(rc)
 
 	// For each node which has an edge FROM me
 	for _, id := range n.Adjacency {
@@ -207,17 +625,113 @@ func outgoingConnectionsSummary(topologyID string, r report.Report, n report.Nod
 			}
 		}
 	}
+	return counts
+}
 
-	columnHeaders := NormalColumns
+// Intermediate type used as a key to dedupe rows in the merged table,
+// which has a row per peer rather than per destination port.
+type peer struct {
+	remoteNodeID          string
+	remoteAddr, localAddr string // for internet nodes only
+	internetIP            string // address of the internet side, if any
+	nameSource            string // where the name of the internet side came from
+}
+
+type peerStats struct {
+	incoming, outgoing connectionStats
+}
+
+// mergedConnectionsSummary produces a single table with one row per
+// peer, covering connections in both directions.
+func mergedConnectionsSummary(topologyID string, rc RenderContext, n report.Node, ns report.Nodes) ConnectionsSummary {
+	r := rc.Report
+	peers := map[peer]peerStats{}
+	for row, stats := range incomingConnectionCounters(rc, n, ns).counts {
+		key := peer{row.remoteNodeID, row.remoteAddr, row.localAddr, row.internetIP, row.nameSource}
+		p := peers[key]
+		p.incoming = p.incoming.add(stats)
+		peers[key] = p
+	}
+	for row, stats := range outgoingConnectionCounters(rc, n, ns).counts {
+		key := peer{row.remoteNodeID, row.remoteAddr, row.localAddr, row.internetIP, row.nameSource}
+		p := peers[key]
+		p.outgoing = p.outgoing.add(stats)
+		peers[key] = p
+	}
+
+	columnHeaders := MergedColumns
 	if render.IsInternetNode(n) {
-		columnHeaders = InternetColumns
+		columnHeaders = MergedInternetColumns
 	}
+	output := []Connection{}
+	for key, stats := range peers {
+		summary, _ := MakeBasicNodeSummary(r, ns[key.remoteNodeID])
+		total := stats.incoming.add(stats.outgoing)
+		connection := Connection{
+			ID:                 fmt.Sprintf("%s-%s-%s", key.remoteNodeID, key.remoteAddr, key.localAddr),
+			NodeID:             summary.ID,
+			Label:              summary.Label,
+			LabelMinor:         summary.LabelMinor,
+			ProcessNodeID:      processNodeID(total.remoteProcesses),
+			LocalProcessNodeID: processNodeID(total.localProcesses),
+		}
+		if key.remoteAddr != "" {
+			connection.Label = key.remoteAddr
+			connection.LabelMinor = ""
+		}
+		if render.IsInternetNode(n) {
+			connection.Metadata = append(connection.Metadata,
+				report.MetadataRow{
+					ID:    remoteKey,
+					Value: key.localAddr,
+				})
+		}
+		direction := directionBoth
+		switch {
+		case stats.outgoing.count == 0:
+			direction = directionIn
+		case stats.incoming.count == 0:
+			direction = directionOut
+		}
+		connection.Metadata = append(connection.Metadata,
+			report.MetadataRow{
+				ID:    directionKey,
+				Value: direction,
+			},
+			report.MetadataRow{
+				ID:    inCountKey,
+				Value: strconv.Itoa(stats.incoming.count),
+			},
+			report.MetadataRow{
+				ID:    outCountKey,
+				Value: strconv.Itoa(stats.outgoing.count),
+			},
+		)
+		if total.hasBytes {
+			connection.Metadata = append(connection.Metadata,
+				report.MetadataRow{
+					ID:    sentKey,
+					Value: strconv.Itoa(total.bytesSent),
+				},
+				report.MetadataRow{
+					ID:    recvKey,
+					Value: strconv.Itoa(total.bytesReceived),
+				},
+			)
+		}
+		connection.Metadata = append(connection.Metadata, failureRows(total)...)
+		connection.Metadata = append(connection.Metadata, geoRows(rc.GeoIP, key.internetIP)...)
+		connection.Metadata = append(connection.Metadata, nameSourceRows(key.nameSource)...)
+		output = append(output, connection)
+	}
+	sort.Sort(connectionsByID(output))
+
 	return ConnectionsSummary{
-		ID:          "outgoing-connections",
+		ID:          "connections",
 		TopologyID:  topologyID,
-		Label:       "Outbound",
-		Columns:     columnHeaders,
-		Connections: counts.rows(r, ns, render.IsInternetNode(n)),
+		Label:       "Connections",
+		Columns:     withGeoColumns(columnHeaders, output),
+		Connections: output,
 	}
 }
 
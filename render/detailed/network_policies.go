@@ -0,0 +1,283 @@
+package detailed
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	apinetworkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/report"
+)
+
+// Labels which differ between the pods of a deployment, revision to
+// revision, and so aren't fit to select them.
+var revisionLabels = map[string]struct{}{
+	"pod-template-hash":        {},
+	"controller-revision-hash": {},
+	"pod-template-generation":  {},
+}
+
+// SuggestedNetworkPolicy is a network policy allowing the traffic observed
+// to and from some pods, and no other.
+type SuggestedNetworkPolicy struct {
+	Namespace string
+	kubernetes.Policy
+}
+
+// podGroup is the pods of a deployment, or a pod on its own, as network
+// policies select them.
+type podGroup struct {
+	name, namespace string
+	selector        map[string]string
+	pods            []string
+}
+
+// SuggestNetworkPolicies suggests network policies for the rendered pods
+// in ns which are selected, allowing the traffic observed to and from them.
+// There is a policy for the pods of each deployment, or for each pod on its
+// own, which selects them by the labels they have in common, those without
+// any being left out. Peers which are pods are allowed likewise, the others
+// by their address.
+func SuggestNetworkPolicies(rc RenderContext, ns report.Nodes, selected func(pod report.Node) bool) []SuggestedNetworkPolicy {
+	groups, groupOf := podGroups(rc.Report, ns)
+	namespaceLabels := map[string]map[string]string{}
+	for _, n := range rc.Report.Namespace.Nodes {
+		if name, ok := n.Latest.Lookup(kubernetes.Name); ok {
+			namespaceLabels[name] = kubernetes.NodeLabels(n)
+		}
+	}
+
+	result := []SuggestedNetworkPolicy{}
+	for _, g := range groups {
+		if len(g.selector) == 0 || !anySelected(rc.Report, g.pods, selected) {
+			continue
+		}
+		peerOf := func(remote report.Node, addr string) (apinetworkingv1.NetworkPolicyPeer, bool) {
+			if rg, ok := groupOf[remote.ID]; ok && len(rg.selector) > 0 {
+				p := apinetworkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: rg.selector}}
+				if rg.namespace != g.namespace {
+					p.NamespaceSelector = &metav1.LabelSelector{MatchLabels: namespaceLabels[rg.namespace]}
+				}
+				return p, true
+			}
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				return apinetworkingv1.NetworkPolicyPeer{}, false
+			}
+			cidr := addr + "/32"
+			if ip.To4() == nil {
+				cidr = addr + "/128"
+			}
+			return apinetworkingv1.NetworkPolicyPeer{IPBlock: &apinetworkingv1.IPBlock{CIDR: cidr}}, true
+		}
+
+		ingress, egress := policyRules{}, policyRules{}
+		for _, id := range g.pods {
+			observeConnections(ns[id], ns, true, func(remote report.Node, addr, port, protocol string) {
+				if p, ok := peerOf(remote, addr); ok {
+					ingress.add(p, port, protocol)
+				}
+			})
+			observeConnections(ns[id], ns, false, func(remote report.Node, addr, port, protocol string) {
+				if p, ok := peerOf(remote, addr); ok {
+					egress.add(p, port, protocol)
+				}
+			})
+		}
+
+		spec := apinetworkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: g.selector},
+			PolicyTypes: []apinetworkingv1.PolicyType{apinetworkingv1.PolicyTypeIngress, apinetworkingv1.PolicyTypeEgress},
+		}
+		for _, rule := range ingress.rules() {
+			spec.Ingress = append(spec.Ingress, apinetworkingv1.NetworkPolicyIngressRule{From: rule.peers, Ports: rule.ports})
+		}
+		for _, rule := range egress.rules() {
+			spec.Egress = append(spec.Egress, apinetworkingv1.NetworkPolicyEgressRule{To: rule.peers, Ports: rule.ports})
+		}
+		result = append(result, SuggestedNetworkPolicy{
+			Namespace: g.namespace,
+			Policy:    kubernetes.Policy{Name: g.name, Spec: spec},
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// podGroups groups the rendered pods in ns by deployment, returning the
+// groups and the group of each pod.
+func podGroups(rpt report.Report, ns report.Nodes) (map[string]*podGroup, map[string]*podGroup) {
+	groups := map[string]*podGroup{}
+	groupOf := map[string]*podGroup{}
+	for id, n := range ns {
+		if n.Topology != report.Pod {
+			continue
+		}
+		pod, ok := rpt.Pod.Nodes[id]
+		if !ok {
+			pod = n
+		}
+		key, name := id, ""
+		if ids, ok := pod.Parents.Lookup(report.Deployment); ok && len(ids) > 0 {
+			key = ids[0]
+			name, _ = rpt.Deployment.Nodes[key].Latest.Lookup(kubernetes.Name)
+		}
+		if name == "" {
+			key = id
+			name, _ = pod.Latest.Lookup(kubernetes.Name)
+		}
+		labels := kubernetes.NodeLabels(pod)
+		for label := range revisionLabels {
+			delete(labels, label)
+		}
+
+		g, ok := groups[key]
+		if !ok {
+			namespace, _ := pod.Latest.Lookup(kubernetes.Namespace)
+			g = &podGroup{name: name, namespace: namespace, selector: labels}
+			groups[key] = g
+		} else {
+			for label, value := range g.selector {
+				if labels[label] != value {
+					delete(g.selector, label)
+				}
+			}
+		}
+		g.pods = append(g.pods, id)
+		groupOf[id] = g
+	}
+	return groups, groupOf
+}
+
+func anySelected(rpt report.Report, ids []string, selected func(report.Node) bool) bool {
+	for _, id := range ids {
+		if pod, ok := rpt.Pod.Nodes[id]; ok && selected(pod) {
+			return true
+		}
+	}
+	return false
+}
+
+// observeConnections calls f for each connection observed to, if incoming,
+// or from a rendered node, with the node at the other end and its address,
+// and the destination port and protocol.
+func observeConnections(n report.Node, ns report.Nodes, incoming bool, f func(remote report.Node, addr, port, protocol string)) {
+	if incoming {
+		localEndpointIDs, localEndpointIDCopies := endpointChildIDsAndCopyMapOf(n)
+		for _, node := range ns {
+			if !node.Adjacency.Contains(n.ID) {
+				continue
+			}
+			for _, remoteEndpoint := range endpointChildrenOf(node) {
+				for _, localEndpointID := range remoteEndpoint.Adjacency.Intersection(localEndpointIDs) {
+					localEndpointID = canonicalEndpointID(localEndpointIDCopies, localEndpointID)
+					_, _, port, protocol, ok := report.ParseEndpointNodeIDWithProtocol(localEndpointID)
+					_, addr, _, ok2 := report.ParseEndpointNodeID(remoteEndpoint.ID)
+					if ok && ok2 {
+						f(node, addr, port, protocol)
+					}
+				}
+			}
+		}
+		return
+	}
+	localEndpoints := endpointChildrenOf(n)
+	for _, id := range n.Adjacency {
+		node, ok := ns[id]
+		if !ok {
+			continue
+		}
+		remoteEndpointIDs, remoteEndpointIDCopies := endpointChildIDsAndCopyMapOf(node)
+		for _, localEndpoint := range localEndpoints {
+			for _, remoteEndpointID := range localEndpoint.Adjacency.Intersection(remoteEndpointIDs) {
+				remoteEndpointID = canonicalEndpointID(remoteEndpointIDCopies, remoteEndpointID)
+				if _, addr, port, protocol, ok := report.ParseEndpointNodeIDWithProtocol(remoteEndpointID); ok {
+					f(node, addr, port, protocol)
+				}
+			}
+		}
+	}
+}
+
+// policyRules collects the ports observed for each peer, so as to have a
+// rule per peer.
+type policyRules map[string]*policyRule
+
+type policyRule struct {
+	key   string
+	peers []apinetworkingv1.NetworkPolicyPeer
+	ports []apinetworkingv1.NetworkPolicyPort
+	seen  map[string]struct{}
+}
+
+func (rs policyRules) add(p apinetworkingv1.NetworkPolicyPeer, port, protocol string) {
+	key := peerKey(p)
+	r, ok := rs[key]
+	if !ok {
+		r = &policyRule{key: key, peers: []apinetworkingv1.NetworkPolicyPeer{p}, seen: map[string]struct{}{}}
+		rs[key] = r
+	}
+	number, err := strconv.Atoi(port)
+	if err != nil {
+		return
+	}
+	proto := apiv1.ProtocolTCP
+	if strings.ToLower(protocol) == "udp" {
+		proto = apiv1.ProtocolUDP
+	}
+	if _, ok := r.seen[string(proto)+port]; ok {
+		return
+	}
+	r.seen[string(proto)+port] = struct{}{}
+	n := intstr.FromInt(number)
+	r.ports = append(r.ports, apinetworkingv1.NetworkPolicyPort{Protocol: &proto, Port: &n})
+}
+
+// rules returns the rules, by peer, with their ports sorted.
+func (rs policyRules) rules() []*policyRule {
+	result := make([]*policyRule, 0, len(rs))
+	for _, r := range rs {
+		sort.Slice(r.ports, func(i, j int) bool {
+			if *r.ports[i].Protocol != *r.ports[j].Protocol {
+				return *r.ports[i].Protocol < *r.ports[j].Protocol
+			}
+			return r.ports[i].Port.IntValue() < r.ports[j].Port.IntValue()
+		})
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].key < result[j].key })
+	return result
+}
+
+// peerKey identifies a peer by how it's selected.
+func peerKey(p apinetworkingv1.NetworkPolicyPeer) string {
+	if p.IPBlock != nil {
+		return "ip:" + p.IPBlock.CIDR
+	}
+	key := "pod:" + selectorKey(p.PodSelector)
+	if p.NamespaceSelector != nil {
+		key = "namespace:" + selectorKey(p.NamespaceSelector) + "," + key
+	}
+	return key
+}
+
+func selectorKey(selector *metav1.LabelSelector) string {
+	labels := make([]string, 0, len(selector.MatchLabels))
+	for k, v := range selector.MatchLabels {
+		labels = append(labels, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(labels)
+	return strings.Join(labels, ",")
+}
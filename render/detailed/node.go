@@ -6,9 +6,11 @@ import (
 
 	"github.com/ugorji/go/codec"
 
+	"github.com/weaveworks/scope/common/geoip"
 	"github.com/weaveworks/scope/probe/awsecs"
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/probe/nomad"
 	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/report"
 )
@@ -47,6 +49,10 @@ type wiredControlInstance struct {
 	Human   string `json:"human"`
 	Icon    string `json:"icon"`
 	Rank    int    `json:"rank"`
+
+	Params  []report.ControlParam `json:"params,omitempty"`
+	Confirm string                `json:"confirm,omitempty"`
+	DryRun  string                `json:"dryRun,omitempty"`
 }
 
 // CodecEncodeSelf marshals this ControlInstance. It takes the basic Metric
@@ -59,6 +65,9 @@ func (c *ControlInstance) CodecEncodeSelf(encoder *codec.Encoder) {
 		Human:   c.Control.Human,
 		Icon:    c.Control.Icon,
 		Rank:    c.Control.Rank,
+		Params:  c.Control.Params,
+		Confirm: c.Control.Confirm,
+		DryRun:  c.Control.DryRun,
 	})
 }
 
@@ -70,10 +79,13 @@ func (c *ControlInstance) CodecDecodeSelf(decoder *codec.Decoder) {
 		ProbeID: in.ProbeID,
 		NodeID:  in.NodeID,
 		Control: report.Control{
-			ID:    in.ID,
-			Human: in.Human,
-			Icon:  in.Icon,
-			Rank:  in.Rank,
+			ID:      in.ID,
+			Human:   in.Human,
+			Icon:    in.Icon,
+			Rank:    in.Rank,
+			Params:  in.Params,
+			Confirm: in.Confirm,
+			DryRun:  in.DryRun,
 		},
 	}
 }
@@ -82,6 +94,18 @@ func (c *ControlInstance) CodecDecodeSelf(decoder *codec.Decoder) {
 type RenderContext struct {
 	report.Report
 	MetricsGraphURL string
+	// MergeConnections produces a single connections table with a row
+	// per peer, instead of separate inbound and outbound tables.
+	MergeConnections bool
+	// GeoIP, if set, is used to add location columns to connections
+	// with the internet.
+	GeoIP geoip.Resolver
+	// ConnectionsFilter restricts the rows of the connection tables.
+	ConnectionsFilter ConnectionsFilter
+	// Annotations, by node ID, are added to the summaries of the nodes.
+	Annotations map[string]Annotation
+	// Anomalies, by node ID, are added to the summaries of the nodes.
+	Anomalies map[string][]Anomaly
 }
 
 // MakeNode transforms a renderable node to a detailed node. It uses
@@ -92,10 +116,21 @@ func MakeNode(topologyID string, rc RenderContext, ns report.Nodes, n report.Nod
 		NodeSummary: summary,
 		Controls:    controls(rc.Report, n),
 		Children:    children(rc, n),
-		Connections: []ConnectionsSummary{
-			incomingConnectionsSummary(topologyID, rc.Report, n, ns),
-			outgoingConnectionsSummary(topologyID, rc.Report, n, ns),
-		},
+		Connections: ConnectionsSummaries(topologyID, rc, ns, n),
+	}
+}
+
+// ConnectionsSummaries produces the connection tables of a renderable
+// node, as included in its detailed node.
+func ConnectionsSummaries(topologyID string, rc RenderContext, ns report.Nodes, n report.Node) []ConnectionsSummary {
+	if rc.MergeConnections {
+		return []ConnectionsSummary{
+			mergedConnectionsSummary(topologyID, rc, n, ns),
+		}
+	}
+	return []ConnectionsSummary{
+		incomingConnectionsSummary(topologyID, rc, n, ns),
+		outgoingConnectionsSummary(topologyID, rc, n, ns),
 	}
 }
 
@@ -157,6 +192,26 @@ var nodeSummaryGroupSpecs = []struct {
 			},
 		},
 	},
+	{
+		topologyID: report.SwarmTask,
+		NodeSummaryGroup: NodeSummaryGroup{
+			Label: "Tasks",
+			Columns: []Column{
+				{ID: docker.SwarmTaskState, Label: "State"},
+				{ID: docker.SwarmTaskNode, Label: "Node"},
+			},
+		},
+	},
+	{
+		topologyID: report.NomadAllocation,
+		NodeSummaryGroup: NodeSummaryGroup{
+			Label: "Allocations",
+			Columns: []Column{
+				{ID: nomad.AllocationStatus, Label: "Status"},
+				{ID: nomad.CreatedAt, Label: "Created", Datatype: report.DateTime},
+			},
+		},
+	},
 	{
 		topologyID: report.Container,
 		NodeSummaryGroup: NodeSummaryGroup{
@@ -0,0 +1,235 @@
+package detailed
+
+import (
+	"reflect"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// NodeDiff is returned by NodeDetailsDiff. It represents the changes
+// between two renderings of a node's details. Metrics, tables and
+// connection tables are diffed one by one, keyed by ID, and the rows of
+// tables too; everything else is resent in Node whenever it changes, with
+// the metrics and tables left out. Metrics only carry the samples taken
+// since the last diff. A reset without a Node means the node has gone.
+type NodeDiff struct {
+	Node        *Node             `json:"node,omitempty"`
+	Metrics     []MetricDiff      `json:"metrics,omitempty"`
+	Tables      []TableDiff       `json:"tables,omitempty"`
+	Connections []ConnectionsDiff `json:"connections,omitempty"`
+	Reset       bool              `json:"reset,omitempty"`
+}
+
+// Empty tells whether there are no changes in the NodeDiff.
+func (d NodeDiff) Empty() bool {
+	return !d.Reset && d.Node == nil && len(d.Metrics) == 0 && len(d.Tables) == 0 && len(d.Connections) == 0
+}
+
+// MetricDiff represents the changes to a metric of a node. Row is set when
+// the metric is new or has changed, with only the samples taken since;
+// samples from before the First of Row are to be dropped.
+type MetricDiff struct {
+	ID      string            `json:"id"`
+	Row     *report.MetricRow `json:"row,omitempty"`
+	Deleted bool              `json:"deleted,omitempty"`
+}
+
+// TableDiff represents the changes to a table of a node. Table is set,
+// without rows, when the table is new or its header has changed.
+type TableDiff struct {
+	ID      string        `json:"id"`
+	Table   *report.Table `json:"table,omitempty"`
+	Add     []report.Row  `json:"add,omitempty"`
+	Update  []report.Row  `json:"update,omitempty"`
+	Remove  []string      `json:"remove,omitempty"`
+	Deleted bool          `json:"deleted,omitempty"`
+}
+
+// ConnectionsDiff represents the changes to a connections table. Summary
+// is set, without rows, when the table is new or its header, e.g. its
+// total, has changed.
+type ConnectionsDiff struct {
+	ID      string              `json:"id"`
+	Summary *ConnectionsSummary `json:"summary,omitempty"`
+	Add     []Connection        `json:"add,omitempty"`
+	Update  []Connection        `json:"update,omitempty"`
+	Remove  []string            `json:"remove,omitempty"`
+	Deleted bool                `json:"deleted,omitempty"`
+}
+
+func (d ConnectionsDiff) empty() bool {
+	return d.Summary == nil && d.Add == nil && d.Update == nil && d.Remove == nil && !d.Deleted
+}
+
+// NodeDetailsDiff gives you the diff to get from a to b. A nil a
+// produces a reset, carrying all of b.
+func NodeDetailsDiff(a *Node, b Node) NodeDiff {
+	diff := NodeDiff{Reset: a == nil}
+	if a == nil {
+		a = &Node{}
+	}
+
+	header := withoutDetails(b)
+	if diff.Reset || !reflect.DeepEqual(withoutDetails(*a), header) {
+		diff.Node = &header
+	}
+	diff.Metrics = metricsDiff(a.Metrics, b.Metrics)
+	diff.Tables = tablesDiff(a.Tables, b.Tables)
+	diff.Connections = connectionSummariesDiff(a.Connections, b.Connections)
+	return diff
+}
+
+func withoutDetails(n Node) Node {
+	n.Metrics = nil
+	n.Tables = nil
+	n.Connections = nil
+	return n
+}
+
+func metricsDiff(a, b []report.MetricRow) []MetricDiff {
+	var diffs []MetricDiff
+	previous := map[string]report.MetricRow{}
+	for _, m := range a {
+		previous[m.ID] = m
+	}
+	for _, m := range b {
+		old, ok := previous[m.ID]
+		delete(previous, m.ID)
+		if ok && reflect.DeepEqual(old, m) {
+			continue
+		}
+		row := m
+		if ok && old.Metric != nil && len(old.Metric.Samples) > 0 && m.Metric != nil {
+			since := newSamples(*old.Metric, *m.Metric)
+			row.Metric = &since
+		}
+		diffs = append(diffs, MetricDiff{ID: m.ID, Row: &row})
+	}
+	for _, m := range a {
+		if _, ok := previous[m.ID]; ok {
+			diffs = append(diffs, MetricDiff{ID: m.ID, Deleted: true})
+		}
+	}
+	return diffs
+}
+
+// newSamples returns b with only the samples taken after the last of a.
+func newSamples(a, b report.Metric) report.Metric {
+	last := a.Samples[len(a.Samples)-1].Timestamp
+	since := b
+	since.Samples = nil
+	for _, s := range b.Samples {
+		if s.Timestamp.After(last) {
+			since.Samples = append(since.Samples, s)
+		}
+	}
+	return since
+}
+
+func tablesDiff(a, b []report.Table) []TableDiff {
+	var diffs []TableDiff
+	previous := map[string]report.Table{}
+	for _, t := range a {
+		previous[t.ID] = t
+	}
+	for _, t := range b {
+		old, ok := previous[t.ID]
+		delete(previous, t.ID)
+		diff := TableDiff{ID: t.ID}
+		header := withoutRows(t)
+		if !ok || !reflect.DeepEqual(withoutRows(old), header) {
+			diff.Table = &header
+		}
+		diff.Add, diff.Update, diff.Remove = rowsDiff(old.Rows, t.Rows)
+		if diff.Table != nil || diff.Add != nil || diff.Update != nil || diff.Remove != nil {
+			diffs = append(diffs, diff)
+		}
+	}
+	for _, t := range a {
+		if _, ok := previous[t.ID]; ok {
+			diffs = append(diffs, TableDiff{ID: t.ID, Deleted: true})
+		}
+	}
+	return diffs
+}
+
+func withoutRows(t report.Table) report.Table {
+	t.Rows = nil
+	return t
+}
+
+func rowsDiff(a, b []report.Row) (add, update []report.Row, remove []string) {
+	notSeen := map[string]report.Row{}
+	for _, r := range a {
+		notSeen[r.ID] = r
+	}
+	for _, r := range b {
+		if old, ok := notSeen[r.ID]; !ok {
+			add = append(add, r)
+		} else if !reflect.DeepEqual(old, r) {
+			update = append(update, r)
+		}
+		delete(notSeen, r.ID)
+	}
+
+	// leftover rows, in their original order
+	for _, r := range a {
+		if _, ok := notSeen[r.ID]; ok {
+			remove = append(remove, r.ID)
+		}
+	}
+	return add, update, remove
+}
+
+func connectionSummariesDiff(a, b []ConnectionsSummary) []ConnectionsDiff {
+	var diffs []ConnectionsDiff
+	previous := map[string]ConnectionsSummary{}
+	for _, s := range a {
+		previous[s.ID] = s
+	}
+	for _, s := range b {
+		old, ok := previous[s.ID]
+		delete(previous, s.ID)
+		diff := connectionsDiff(s.ID, old.Connections, s.Connections)
+		header := s
+		header.Connections = nil
+		old.Connections = nil
+		if !ok || !reflect.DeepEqual(old, header) {
+			diff.Summary = &header
+		}
+		if !diff.empty() {
+			diffs = append(diffs, diff)
+		}
+	}
+	for _, s := range a {
+		if _, ok := previous[s.ID]; ok {
+			diffs = append(diffs, ConnectionsDiff{ID: s.ID, Deleted: true})
+		}
+	}
+	return diffs
+}
+
+func connectionsDiff(id string, a, b []Connection) ConnectionsDiff {
+	diff := ConnectionsDiff{ID: id}
+
+	notSeen := map[string]Connection{}
+	for _, c := range a {
+		notSeen[c.ID] = c
+	}
+	for _, c := range b {
+		if old, ok := notSeen[c.ID]; !ok {
+			diff.Add = append(diff.Add, c)
+		} else if !reflect.DeepEqual(old, c) {
+			diff.Update = append(diff.Update, c)
+		}
+		delete(notSeen, c.ID)
+	}
+
+	// leftover rows, in their original order
+	for _, c := range a {
+		if _, ok := notSeen[c.ID]; ok {
+			diff.Remove = append(diff.Remove, c.ID)
+		}
+	}
+	return diff
+}
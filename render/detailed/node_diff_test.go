@@ -0,0 +1,203 @@
+package detailed_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestNodeDetailsDiff(t *testing.T) {
+	var (
+		rowA  = detailed.Connection{ID: "a", Label: "A"}
+		rowAp = detailed.Connection{ID: "a", Label: "A", Metadata: []report.MetadataRow{{ID: "count", Value: "2"}}}
+		rowB  = detailed.Connection{ID: "b", Label: "B"}
+		rowC  = detailed.Connection{ID: "c", Label: "C"}
+	)
+	node := func(label string, rows ...detailed.Connection) detailed.Node {
+		return detailed.Node{
+			NodeSummary: detailed.NodeSummary{BasicNodeSummary: detailed.BasicNodeSummary{ID: "n", Label: label}},
+			Connections: []detailed.ConnectionsSummary{
+				{ID: "incoming-connections", Label: "Inbound", Connections: rows},
+			},
+		}
+	}
+	header := func(label string) *detailed.Node {
+		n := node(label)
+		n.Connections = nil
+		return &n
+	}
+	summary := &detailed.ConnectionsSummary{ID: "incoming-connections", Label: "Inbound"}
+	ptr := func(n detailed.Node) *detailed.Node { return &n }
+
+	for _, c := range []struct {
+		label      string
+		have, want detailed.NodeDiff
+	}{
+		{
+			label: "reset",
+			have:  detailed.NodeDetailsDiff(nil, node("n", rowA, rowB)),
+			want: detailed.NodeDiff{
+				Reset: true,
+				Node:  header("n"),
+				Connections: []detailed.ConnectionsDiff{
+					{ID: "incoming-connections", Summary: summary, Add: []detailed.Connection{rowA, rowB}},
+				},
+			},
+		},
+		{
+			label: "no changes",
+			have:  detailed.NodeDetailsDiff(ptr(node("n", rowA, rowB)), node("n", rowA, rowB)),
+			want:  detailed.NodeDiff{},
+		},
+		{
+			label: "rows added, updated and removed",
+			have:  detailed.NodeDetailsDiff(ptr(node("n", rowA, rowB)), node("n", rowAp, rowC)),
+			want: detailed.NodeDiff{
+				Connections: []detailed.ConnectionsDiff{
+					{
+						ID:     "incoming-connections",
+						Add:    []detailed.Connection{rowC},
+						Update: []detailed.Connection{rowAp},
+						Remove: []string{"b"},
+					},
+				},
+			},
+		},
+		{
+			label: "node changed",
+			have:  detailed.NodeDetailsDiff(ptr(node("n", rowA)), node("m", rowA)),
+			want:  detailed.NodeDiff{Node: header("m")},
+		},
+		{
+			label: "connections table header changed, and another added",
+			have: func() detailed.NodeDiff {
+				b := node("n", rowA)
+				b.Connections[0].Total = 10
+				b.Connections = append(b.Connections, detailed.ConnectionsSummary{ID: "outgoing-connections"})
+				return detailed.NodeDetailsDiff(ptr(node("n", rowA)), b)
+			}(),
+			want: detailed.NodeDiff{
+				Connections: []detailed.ConnectionsDiff{
+					{ID: "incoming-connections", Summary: &detailed.ConnectionsSummary{ID: "incoming-connections", Label: "Inbound", Total: 10}},
+					{ID: "outgoing-connections", Summary: &detailed.ConnectionsSummary{ID: "outgoing-connections"}},
+				},
+			},
+		},
+		{
+			label: "connections table gone",
+			have: func() detailed.NodeDiff {
+				b := node("n")
+				b.Connections = nil
+				return detailed.NodeDetailsDiff(ptr(node("n", rowA)), b)
+			}(),
+			want: detailed.NodeDiff{
+				Connections: []detailed.ConnectionsDiff{{ID: "incoming-connections", Deleted: true}},
+			},
+		},
+	} {
+		if !reflect.DeepEqual(c.want, c.have) {
+			t.Errorf("%s: %s", c.label, test.Diff(c.want, c.have))
+		}
+	}
+}
+
+func TestNodeDetailsDiffMetricsAndTables(t *testing.T) {
+	var (
+		t0, t1, t2 = time.Unix(0, 0), time.Unix(15, 0), time.Unix(30, 0)
+		s0, s1, s2 = report.Sample{Timestamp: t0, Value: 1}, report.Sample{Timestamp: t1, Value: 2}, report.Sample{Timestamp: t2, Value: 3}
+	)
+	metric := func(value float64, samples ...report.Sample) report.MetricRow {
+		m := report.Metric{Samples: samples, First: samples[0].Timestamp, Last: samples[len(samples)-1].Timestamp, Max: 100}
+		return report.MetricRow{ID: "cpu", Label: "CPU", Value: value, Metric: &m}
+	}
+	table := func(label string, rows ...report.Row) report.Table {
+		return report.Table{ID: "labels", Label: label, Type: report.PropertyListType, Rows: rows}
+	}
+	var (
+		rowA  = report.Row{ID: "a", Entries: map[string]string{"value": "1"}}
+		rowAp = report.Row{ID: "a", Entries: map[string]string{"value": "2"}}
+		rowB  = report.Row{ID: "b", Entries: map[string]string{"value": "1"}}
+	)
+	node := func(metrics []report.MetricRow, tables ...report.Table) detailed.Node {
+		return detailed.Node{NodeSummary: detailed.NodeSummary{
+			BasicNodeSummary: detailed.BasicNodeSummary{ID: "n"},
+			Metrics:          metrics,
+			Tables:           tables,
+		}}
+	}
+	diff := func(a, b detailed.Node) detailed.NodeDiff {
+		return detailed.NodeDetailsDiff(&a, b)
+	}
+	rowPtr := func(m report.MetricRow) *report.MetricRow { return &m }
+	tablePtr := func(t report.Table) *report.Table { return &t }
+
+	for _, c := range []struct {
+		label      string
+		have, want detailed.NodeDiff
+	}{
+		{
+			label: "reset",
+			have:  detailed.NodeDetailsDiff(nil, node([]report.MetricRow{metric(2, s0, s1)}, table("Labels", rowA))),
+			want: detailed.NodeDiff{
+				Reset:   true,
+				Node:    &detailed.Node{NodeSummary: detailed.NodeSummary{BasicNodeSummary: detailed.BasicNodeSummary{ID: "n"}}},
+				Metrics: []detailed.MetricDiff{{ID: "cpu", Row: rowPtr(metric(2, s0, s1))}},
+				Tables:  []detailed.TableDiff{{ID: "labels", Table: tablePtr(table("Labels")), Add: []report.Row{rowA}}},
+			},
+		},
+		{
+			label: "no changes",
+			have: diff(
+				node([]report.MetricRow{metric(2, s0, s1)}, table("Labels", rowA)),
+				node([]report.MetricRow{metric(2, s0, s1)}, table("Labels", rowA)),
+			),
+			want: detailed.NodeDiff{},
+		},
+		{
+			label: "new samples only",
+			have: diff(
+				node([]report.MetricRow{metric(2, s0, s1)}),
+				node([]report.MetricRow{metric(3, s1, s2)}),
+			),
+			want: detailed.NodeDiff{
+				Metrics: []detailed.MetricDiff{{ID: "cpu", Row: func() *report.MetricRow {
+					m := metric(3, s1, s2)
+					m.Metric.Samples = []report.Sample{s2}
+					return &m
+				}()}},
+			},
+		},
+		{
+			label: "metric gone",
+			have:  diff(node([]report.MetricRow{metric(2, s0, s1)}), node(nil)),
+			want:  detailed.NodeDiff{Metrics: []detailed.MetricDiff{{ID: "cpu", Deleted: true}}},
+		},
+		{
+			label: "table rows",
+			have:  diff(node(nil, table("Labels", rowA, rowB)), node(nil, table("Labels", rowAp))),
+			want: detailed.NodeDiff{
+				Tables: []detailed.TableDiff{{ID: "labels", Update: []report.Row{rowAp}, Remove: []string{"b"}}},
+			},
+		},
+		{
+			label: "table header changed",
+			have:  diff(node(nil, table("Labels", rowA)), node(nil, table("Kubernetes Labels", rowA))),
+			want: detailed.NodeDiff{
+				Tables: []detailed.TableDiff{{ID: "labels", Table: tablePtr(table("Kubernetes Labels"))}},
+			},
+		},
+		{
+			label: "table gone",
+			have:  diff(node(nil, table("Labels", rowA)), node(nil)),
+			want:  detailed.NodeDiff{Tables: []detailed.TableDiff{{ID: "labels", Deleted: true}}},
+		},
+	} {
+		if !reflect.DeepEqual(c.want, c.have) {
+			t.Errorf("%s: %s", c.label, test.Diff(c.want, c.have))
+		}
+	}
+}
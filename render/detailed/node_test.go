@@ -2,10 +2,13 @@ package detailed_test
 
 import (
 	"fmt"
+	"net"
 	"testing"
 
 	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/common/geoip"
 	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/endpoint"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/probe/kubernetes"
 	"github.com/weaveworks/scope/probe/process"
@@ -26,7 +29,7 @@ func child(t *testing.T, r render.Renderer, id string) detailed.NodeSummary {
 }
 
 func connectionID(nodeID string, addr string) string {
-	return fmt.Sprintf("%s-%s-%s-%d", nodeID, addr, "", 80)
+	return fmt.Sprintf("%s-%s-%s-%d-%s", nodeID, addr, "", 80, report.TCP)
 }
 
 func TestMakeDetailedHostNode(t *testing.T) {
@@ -150,19 +153,28 @@ func TestMakeDetailedHostNode(t *testing.T) {
 				Columns:    detailed.NormalColumns,
 				Connections: []detailed.Connection{
 					{
-						ID:         connectionID(fixture.ServerHostNodeID, ""),
-						NodeID:     fixture.ServerHostNodeID,
-						Label:      "server",
-						LabelMinor: "hostname.com",
+						ID:            connectionID(fixture.ServerHostNodeID, ""),
+						NodeID:        fixture.ServerHostNodeID,
+						Label:         "server",
+						LabelMinor:    "hostname.com",
+						ProcessNodeID: fixture.ServerProcessNodeID,
 						Metadata: []report.MetadataRow{
 							{
 								ID:    "port",
 								Value: "80",
 							},
+							{
+								ID:    "protocol",
+								Value: "tcp",
+							},
 							{
 								ID:    "count",
 								Value: "2",
 							},
+							{
+								ID:    "rate",
+								Value: "1.00",
+							},
 						},
 					},
 				},
@@ -256,34 +268,52 @@ func TestMakeDetailedContainerNode(t *testing.T) {
 				Columns:    detailed.NormalColumns,
 				Connections: []detailed.Connection{
 					{
-						ID:         connectionID(fixture.ClientContainerNodeID, ""),
-						NodeID:     fixture.ClientContainerNodeID,
-						Label:      "client",
-						LabelMinor: "client.hostname.com",
+						ID:                 connectionID(fixture.ClientContainerNodeID, ""),
+						NodeID:             fixture.ClientContainerNodeID,
+						Label:              "client",
+						LabelMinor:         "client.hostname.com",
+						LocalProcessNodeID: fixture.ServerProcessNodeID,
 						Metadata: []report.MetadataRow{
 							{
 								ID:    "port",
 								Value: "80",
 							},
+							{
+								ID:    "protocol",
+								Value: "tcp",
+							},
 							{
 								ID:    "count",
 								Value: "2",
 							},
+							{
+								ID:    "rate",
+								Value: "1.00",
+							},
 						},
 					},
 					{
-						ID:     connectionID(render.IncomingInternetID, fixture.RandomClientIP),
-						NodeID: render.IncomingInternetID,
-						Label:  fixture.RandomClientIP,
+						ID:                 connectionID(render.IncomingInternetID, fixture.RandomClientIP),
+						NodeID:             render.IncomingInternetID,
+						Label:              fixture.RandomClientIP,
+						LocalProcessNodeID: fixture.ServerProcessNodeID,
 						Metadata: []report.MetadataRow{
 							{
 								ID:    "port",
 								Value: "80",
 							},
+							{
+								ID:    "protocol",
+								Value: "tcp",
+							},
 							{
 								ID:    "count",
 								Value: "1",
 							},
+							{
+								ID:    "rate",
+								Value: "0.50",
+							},
 						},
 					},
 				},
@@ -371,34 +401,52 @@ func TestMakeDetailedPodNode(t *testing.T) {
 				Columns:    detailed.NormalColumns,
 				Connections: []detailed.Connection{
 					{
-						ID:         connectionID(fixture.ClientPodNodeID, ""),
-						NodeID:     fixture.ClientPodNodeID,
-						Label:      "pong-a",
-						LabelMinor: "1 container",
+						ID:                 connectionID(fixture.ClientPodNodeID, ""),
+						NodeID:             fixture.ClientPodNodeID,
+						Label:              "pong-a",
+						LabelMinor:         "1 container",
+						LocalProcessNodeID: fixture.ServerProcessNodeID,
 						Metadata: []report.MetadataRow{
 							{
 								ID:    "port",
 								Value: "80",
 							},
+							{
+								ID:    "protocol",
+								Value: "tcp",
+							},
 							{
 								ID:    "count",
 								Value: "2",
 							},
+							{
+								ID:    "rate",
+								Value: "1.00",
+							},
 						},
 					},
 					{
-						ID:     connectionID(render.IncomingInternetID, fixture.RandomClientIP),
-						NodeID: render.IncomingInternetID,
-						Label:  fixture.RandomClientIP,
+						ID:                 connectionID(render.IncomingInternetID, fixture.RandomClientIP),
+						NodeID:             render.IncomingInternetID,
+						Label:              fixture.RandomClientIP,
+						LocalProcessNodeID: fixture.ServerProcessNodeID,
 						Metadata: []report.MetadataRow{
 							{
 								ID:    "port",
 								Value: "80",
 							},
+							{
+								ID:    "protocol",
+								Value: "tcp",
+							},
 							{
 								ID:    "count",
 								Value: "1",
 							},
+							{
+								ID:    "rate",
+								Value: "0.50",
+							},
 						},
 					},
 				},
@@ -416,3 +464,302 @@ func TestMakeDetailedPodNode(t *testing.T) {
 		t.Errorf("%s", test.Diff(want, have))
 	}
 }
+
+func TestMakeDetailedConnectionBytes(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	for _, id := range []string{fixture.Client54001NodeID, fixture.Client54002NodeID} {
+		rpt.Endpoint.Nodes[id] = rpt.Endpoint.Nodes[id].WithCounters(map[string]int{
+			endpoint.EgressBytes:  100,
+			endpoint.IngressBytes: 1000,
+		})
+	}
+
+	nodes := render.HostRenderer.Render(rpt).Nodes
+	client := detailed.MakeNode("hosts", detailed.RenderContext{Report: rpt}, nodes, nodes[fixture.ClientHostNodeID])
+	want := []report.MetadataRow{
+		{ID: "port", Value: "80"},
+		{ID: "protocol", Value: "tcp"},
+		{ID: "count", Value: "2"},
+		{ID: "rate", Value: "1.00"},
+		{ID: "bytes_sent", Value: "200"},
+		{ID: "bytes_received", Value: "2000"},
+	}
+	if have := client.Connections[1].Connections[0].Metadata; !reflect.DeepEqual(want, have) {
+		t.Errorf("%s", test.Diff(want, have))
+	}
+
+	// From the server's point of view, the same connections are incoming,
+	// so sent and received are swapped.
+	server := detailed.MakeNode("hosts", detailed.RenderContext{Report: rpt}, nodes, nodes[fixture.ServerHostNodeID])
+	for _, c := range server.Connections[0].Connections {
+		if c.NodeID != fixture.ClientHostNodeID {
+			continue
+		}
+		want := []report.MetadataRow{
+			{ID: "port", Value: "80"},
+			{ID: "protocol", Value: "tcp"},
+			{ID: "count", Value: "2"},
+			{ID: "rate", Value: "1.00"},
+			{ID: "bytes_sent", Value: "2000"},
+			{ID: "bytes_received", Value: "200"},
+		}
+		if !reflect.DeepEqual(want, c.Metadata) {
+			t.Errorf("%s", test.Diff(want, c.Metadata))
+		}
+		return
+	}
+	t.Errorf("no incoming connection from %s", fixture.ClientHostNodeID)
+}
+
+func TestMakeDetailedConnectionProtocols(t *testing.T) {
+	var (
+		rpt         = fixture.Report.Copy()
+		clientUDPID = report.MakeEndpointNodeIDWithProtocol(fixture.ClientHostID, "", fixture.ClientIP, fixture.ClientPort54001, report.UDP)
+		serverUDPID = report.MakeEndpointNodeIDWithProtocol(fixture.ServerHostID, "", fixture.ServerIP, fixture.ServerPort, report.UDP)
+	)
+	rpt.Endpoint.Nodes[clientUDPID] = report.MakeNode(clientUDPID).WithTopology(report.Endpoint).WithLatests(map[string]string{
+		report.HostNodeID: fixture.ClientHostNodeID,
+	}).WithAdjacent(serverUDPID)
+	rpt.Endpoint.Nodes[serverUDPID] = report.MakeNode(serverUDPID).WithTopology(report.Endpoint).WithLatests(map[string]string{
+		report.HostNodeID: fixture.ServerHostNodeID,
+	})
+
+	nodes := render.HostRenderer.Render(rpt).Nodes
+	client := detailed.MakeNode("hosts", detailed.RenderContext{Report: rpt}, nodes, nodes[fixture.ClientHostNodeID])
+	have := map[string]string{}
+	for _, c := range client.Connections[1].Connections {
+		for _, row := range c.Metadata {
+			if row.ID == "count" {
+				have[c.ID] = row.Value
+			}
+		}
+	}
+	want := map[string]string{
+		connectionID(fixture.ServerHostNodeID, ""):                                      "2",
+		fmt.Sprintf("%s-%s-%s-%d-%s", fixture.ServerHostNodeID, "", "", 80, report.UDP): "1",
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("%s", test.Diff(want, have))
+	}
+}
+
+func TestMakeDetailedMergedConnections(t *testing.T) {
+	var (
+		rpt            = fixture.Report.Copy()
+		serverClientID = report.MakeEndpointNodeID(fixture.ServerHostID, "", fixture.ServerIP, "40000")
+		clientSSHID    = report.MakeEndpointNodeID(fixture.ClientHostID, "", fixture.ClientIP, "22")
+	)
+	// The server also connects back to the client, so the client host
+	// has traffic to the server in both directions.
+	rpt.Endpoint.Nodes[serverClientID] = report.MakeNode(serverClientID).WithTopology(report.Endpoint).WithLatests(map[string]string{
+		report.HostNodeID: fixture.ServerHostNodeID,
+	}).WithAdjacent(clientSSHID)
+	rpt.Endpoint.Nodes[clientSSHID] = report.MakeNode(clientSSHID).WithTopology(report.Endpoint).WithLatests(map[string]string{
+		report.HostNodeID: fixture.ClientHostNodeID,
+	})
+
+	nodes := render.HostRenderer.Render(rpt).Nodes
+	rc := detailed.RenderContext{Report: rpt, MergeConnections: true}
+	have := detailed.MakeNode("hosts", rc, nodes, nodes[fixture.ClientHostNodeID]).Connections
+	want := []detailed.ConnectionsSummary{
+		{
+			ID:         "connections",
+			TopologyID: "hosts",
+			Label:      "Connections",
+			Columns:    detailed.MergedColumns,
+			Connections: []detailed.Connection{
+				{
+					ID:            fmt.Sprintf("%s--", fixture.ServerHostNodeID),
+					NodeID:        fixture.ServerHostNodeID,
+					Label:         "server",
+					LabelMinor:    "hostname.com",
+					ProcessNodeID: fixture.ServerProcessNodeID,
+					Metadata: []report.MetadataRow{
+						{ID: "direction", Value: "both"},
+						{ID: "count_in", Value: "1"},
+						{ID: "count_out", Value: "2"},
+					},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("%s", test.Diff(want, have))
+	}
+}
+
+func TestMakeDetailedConnectionGeoIP(t *testing.T) {
+	db := geoip.NewDatabase()
+	_, network, _ := net.ParseCIDR("51.52.0.0/16")
+	db.AddCountry(network, "United Kingdom")
+	db.Sort()
+
+	nodes := render.ContainerWithImageNameRenderer.Render(fixture.Report).Nodes
+	rc := detailed.RenderContext{Report: fixture.Report, GeoIP: db}
+	have := detailed.MakeNode("containers", rc, nodes, nodes[fixture.ServerContainerNodeID]).Connections
+	incoming, outgoing := have[0], have[1]
+	if want := append(append([]detailed.Column{}, detailed.NormalColumns...), detailed.GeoColumns...); !reflect.DeepEqual(want, incoming.Columns) {
+		t.Errorf("%s", test.Diff(want, incoming.Columns))
+	}
+	if want := detailed.NormalColumns; !reflect.DeepEqual(want, outgoing.Columns) {
+		t.Errorf("%s", test.Diff(want, outgoing.Columns))
+	}
+	for _, c := range incoming.Connections {
+		var country string
+		for _, row := range c.Metadata {
+			if row.ID == "country" {
+				country = row.Value
+			}
+		}
+		want := ""
+		if c.NodeID == render.IncomingInternetID {
+			want = "United Kingdom"
+		}
+		if want != country {
+			t.Errorf("%s: want country %q, have %q", c.ID, want, country)
+		}
+	}
+}
+
+func TestMakeDetailedConnectionNameSource(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	rpt.Endpoint.Nodes[fixture.RandomClientNodeID] = rpt.Endpoint.Nodes[fixture.RandomClientNodeID].WithSets(report.MakeSets().
+		Add(endpoint.ReverseDNSNames, report.MakeStringSet("host.example.net")).
+		Add(endpoint.SnoopedDNSNames, report.MakeStringSet("www.example.com")),
+	)
+
+	nodes := render.ContainerWithImageNameRenderer.Render(rpt).Nodes
+	incoming := detailed.MakeNode("containers", detailed.RenderContext{Report: rpt}, nodes, nodes[fixture.ServerContainerNodeID]).Connections[0]
+	if want := append(append([]detailed.Column{}, detailed.NormalColumns...), detailed.NameSourceColumns...); !reflect.DeepEqual(want, incoming.Columns) {
+		t.Errorf("%s", test.Diff(want, incoming.Columns))
+	}
+	for _, c := range incoming.Connections {
+		var source string
+		for _, row := range c.Metadata {
+			if row.ID == "name_source" {
+				source = row.Value
+			}
+		}
+		want := ""
+		if c.NodeID == render.IncomingInternetID {
+			want = render.SnoopedDNSSource
+			if c.Label != "www.example.com ("+fixture.RandomClientIP+")" {
+				t.Errorf("unexpected label %q", c.Label)
+			}
+		}
+		if want != source {
+			t.Errorf("%s: want name source %q, have %q", c.ID, want, source)
+		}
+	}
+}
+
+func TestMakeDetailedConnectionRTT(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	for id, rtt := range map[string]string{
+		fixture.Client54001NodeID: "1000",
+		fixture.Client54002NodeID: "3000",
+	} {
+		rpt.Endpoint.Nodes[id] = rpt.Endpoint.Nodes[id].WithLatests(map[string]string{
+			endpoint.RTT: rtt,
+		})
+	}
+
+	nodes := render.HostRenderer.Render(rpt).Nodes
+	client := detailed.MakeNode("hosts", detailed.RenderContext{Report: rpt}, nodes, nodes[fixture.ClientHostNodeID])
+	want := []report.MetadataRow{
+		{ID: "port", Value: "80"},
+		{ID: "protocol", Value: "tcp"},
+		{ID: "count", Value: "2"},
+		{ID: "rate", Value: "1.00"},
+		{ID: "rtt_p50", Value: "1.00"},
+		{ID: "rtt_p99", Value: "3.00"},
+	}
+	if have := client.Connections[1].Connections[0].Metadata; !reflect.DeepEqual(want, have) {
+		t.Errorf("%s", test.Diff(want, have))
+	}
+}
+
+func TestMakeDetailedConnectionSNI(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	rpt.Endpoint.Nodes[fixture.Server80NodeID] = rpt.Endpoint.Nodes[fixture.Server80NodeID].WithSet(
+		endpoint.SNINames, report.MakeStringSet("api.example.com"),
+	)
+
+	nodes := render.HostRenderer.Render(rpt).Nodes
+	client := detailed.MakeNode("hosts", detailed.RenderContext{Report: rpt}, nodes, nodes[fixture.ClientHostNodeID])
+	want := []report.MetadataRow{
+		{ID: "port", Value: "80"},
+		{ID: "protocol", Value: "tcp"},
+		{ID: "count", Value: "2"},
+		{ID: "rate", Value: "1.00"},
+		{ID: "sni", Value: "api.example.com"},
+	}
+	if have := client.Connections[1].Connections[0].Metadata; !reflect.DeepEqual(want, have) {
+		t.Errorf("%s", test.Diff(want, have))
+	}
+}
+
+func TestMakeDetailedConnectionSampled(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	rpt.Endpoint.Nodes[fixture.Client54001NodeID] = rpt.Endpoint.Nodes[fixture.Client54001NodeID].WithCounters(
+		map[string]int{endpoint.SampledConnections: 10},
+	)
+
+	nodes := render.HostRenderer.Render(rpt).Nodes
+	client := detailed.MakeNode("hosts", detailed.RenderContext{Report: rpt}, nodes, nodes[fixture.ClientHostNodeID])
+	want := []report.MetadataRow{
+		{ID: "port", Value: "80"},
+		{ID: "protocol", Value: "tcp"},
+		{ID: "count", Value: "11"},
+		{ID: "rate", Value: "5.50"},
+	}
+	if have := client.Connections[1].Connections[0].Metadata; !reflect.DeepEqual(want, have) {
+		t.Errorf("%s", test.Diff(want, have))
+	}
+}
+
+func TestMakeDetailedConnectionFailures(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	rpt.Endpoint.Nodes[fixture.Client54001NodeID] = rpt.Endpoint.Nodes[fixture.Client54001NodeID].WithCounters(
+		map[string]int{endpoint.TCPRetransmits: 3, endpoint.TCPResets: 1},
+	)
+
+	nodes := render.HostRenderer.Render(rpt).Nodes
+	client := detailed.MakeNode("hosts", detailed.RenderContext{Report: rpt}, nodes, nodes[fixture.ClientHostNodeID])
+	want := []report.MetadataRow{
+		{ID: "port", Value: "80"},
+		{ID: "protocol", Value: "tcp"},
+		{ID: "count", Value: "2"},
+		{ID: "rate", Value: "1.00"},
+		{ID: "retransmits", Value: "3"},
+		{ID: "resets", Value: "1"},
+	}
+	if have := client.Connections[1].Connections[0].Metadata; !reflect.DeepEqual(want, have) {
+		t.Errorf("%s", test.Diff(want, have))
+	}
+}
+
+func TestMakeDetailedConnectionsFilter(t *testing.T) {
+	nodes := render.HostRenderer.Render(fixture.Report).Nodes
+	outgoing := func(filter detailed.ConnectionsFilter) []detailed.Connection {
+		rc := detailed.RenderContext{Report: fixture.Report, ConnectionsFilter: filter}
+		return detailed.MakeNode("hosts", rc, nodes, nodes[fixture.ClientHostNodeID]).Connections[1].Connections
+	}
+	_, server, _ := net.ParseCIDR(fixture.ServerIP + "/32")
+	_, other, _ := net.ParseCIDR("172.16.0.0/12")
+
+	for _, c := range []struct {
+		filter detailed.ConnectionsFilter
+		want   int
+	}{
+		{detailed.ConnectionsFilter{}, 1},
+		{detailed.ConnectionsFilter{MinPort: 80, MaxPort: 80}, 1},
+		{detailed.ConnectionsFilter{MinPort: 81, MaxPort: 1024}, 0},
+		{detailed.ConnectionsFilter{Networks: []*net.IPNet{other, server}}, 1},
+		{detailed.ConnectionsFilter{Networks: []*net.IPNet{other}}, 0},
+	} {
+		if have := len(outgoing(c.filter)); c.want != have {
+			t.Errorf("%+v: want %d rows, have %d", c.filter, c.want, have)
+		}
+	}
+}
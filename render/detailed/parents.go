@@ -20,10 +20,20 @@ var parentTopologies = []string{
 	report.DaemonSet,
 	report.StatefulSet,
 	report.CronJob,
+	report.Job,
+	report.CustomResource,
 	report.Service,
 	report.ECSTask,
 	report.ECSService,
+	report.NomadAllocation,
+	report.NomadTaskGroup,
+	report.NomadJob,
+	report.SwarmTask,
 	report.SwarmService,
+	report.ComposeService,
+	report.DockerNetwork,
+	report.DockerVolume,
+	report.SystemdUnit,
 	report.Host,
 }
 
@@ -0,0 +1,117 @@
+package detailed
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/report"
+)
+
+// NearLimit is the percentage of their limits above which the usage of pods
+// is highlighted.
+const NearLimit = 90.0
+
+// withResourceUsage adds, to a rendered pod, the usage of its containers to
+// its resources table, and its usage of its limits as metrics. The usage of
+// CPU is reported by docker as a percentage of the host, and so in cores
+// only when the host reports how many CPUs it has.
+func withResourceUsage(r report.Report, n report.Node) report.Node {
+	if n.Topology != report.Pod {
+		return n
+	}
+	var (
+		cpu, memory           float64
+		cpuKnown, memoryKnown bool
+		timestamp             time.Time
+	)
+	usages := map[string]string{}
+	n.Children.ForEach(func(child report.Node) {
+		if child.Topology != report.Container {
+			return
+		}
+		name, ok := child.Latest.Lookup(docker.LabelPrefix + KubernetesContainerNameLabel)
+		if !ok {
+			return
+		}
+		if s, ok := lastSample(child, docker.MemoryUsage); ok {
+			memory, memoryKnown = memory+s.Value, true
+			usages[resourcesKey(name, kubernetes.ResourceMemoryUsage)] = strconv.FormatFloat(s.Value, 'f', 0, 64)
+			timestamp = latest(timestamp, s.Timestamp)
+		}
+		if s, ok := lastSample(child, docker.CPUTotalUsage); ok {
+			if cpus, ok := hostCPUs(r, child); ok {
+				cores := s.Value / 100 * cpus
+				cpu, cpuKnown = cpu+cores, true
+				usages[resourcesKey(name, kubernetes.ResourceCPUUsage)] = strconv.FormatFloat(cores, 'f', 3, 64)
+				timestamp = latest(timestamp, s.Timestamp)
+			}
+		}
+	})
+	for k, v := range usages {
+		n = n.WithLatest(k, timestamp, v)
+	}
+	if limit, ok := latestFloat(n, kubernetes.CPULimit); ok && cpuKnown && limit > 0 {
+		n = n.WithMetric(kubernetes.CPULimitUsage, report.MakeSingletonMetric(timestamp, cpu/limit*100).WithMax(100))
+	}
+	if limit, ok := latestFloat(n, kubernetes.MemoryLimit); ok && memoryKnown && limit > 0 {
+		n = n.WithMetric(kubernetes.MemoryLimitUsage, report.MakeSingletonMetric(timestamp, memory/limit*100).WithMax(100))
+	}
+	return n
+}
+
+// nearLimits returns the resources, cpu and memory, a pod uses more than
+// NearLimit percent of the limits of.
+func nearLimits(n report.Node) []string {
+	var result []string
+	for _, limit := range []struct{ resource, metric string }{
+		{"cpu", kubernetes.CPULimitUsage},
+		{"memory", kubernetes.MemoryLimitUsage},
+	} {
+		if s, ok := lastSample(n, limit.metric); ok && s.Value >= NearLimit {
+			result = append(result, limit.resource)
+		}
+	}
+	return result
+}
+
+func resourcesKey(container, column string) string {
+	return kubernetes.ResourcesPrefix + strings.Join([]string{container, column}, report.TableEntryKeySeparator)
+}
+
+func lastSample(n report.Node, id string) (report.Sample, bool) {
+	metric, ok := n.Metrics.Lookup(id)
+	if !ok {
+		return report.Sample{}, false
+	}
+	return metric.LastSample()
+}
+
+func hostCPUs(r report.Report, n report.Node) (float64, bool) {
+	hostIDs, _ := n.Parents.Lookup(report.Host)
+	for _, id := range hostIDs {
+		if cpus, ok := latestFloat(r.Host.Nodes[id], host.CPUs); ok {
+			return cpus, true
+		}
+	}
+	return 0, false
+}
+
+func latestFloat(n report.Node, key string) (float64, bool) {
+	s, ok := n.Latest.Lookup(key)
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
+
+func latest(t1, t2 time.Time) time.Time {
+	if t2.After(t1) {
+		return t2
+	}
+	return t1
+}
@@ -0,0 +1,93 @@
+package detailed_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestPodResourceUsage(t *testing.T) {
+	var (
+		now    = time.Unix(1500000000, 0)
+		hostID = report.MakeHostNodeID("host1")
+		podID  = report.MakePodNodeID("pod1")
+	)
+	rpt := report.MakeReport()
+	rpt.Host.AddNode(report.MakeNodeWith(hostID, map[string]string{host.CPUs: "2"}))
+	rpt.Pod = rpt.Pod.
+		WithMetricTemplates(kubernetes.PodMetricTemplates).
+		WithTableTemplates(kubernetes.TableTemplates)
+
+	container := func(id, name string, cpu, memory float64) report.Node {
+		return report.MakeNodeWith(report.MakeContainerNodeID(id), map[string]string{
+			docker.LabelPrefix + detailed.KubernetesContainerNameLabel: name,
+		}).WithTopology(report.Container).
+			WithParents(report.MakeSets().Add(report.Host, report.MakeStringSet(hostID))).
+			WithMetrics(report.Metrics{
+				docker.CPUTotalUsage: report.MakeSingletonMetric(now, cpu),
+				docker.MemoryUsage:   report.MakeSingletonMetric(now, memory),
+			})
+	}
+	pod := report.MakeNodeWith(podID, map[string]string{
+		kubernetes.CPULimit:    "2",
+		kubernetes.MemoryLimit: "1000",
+	}).WithTopology(report.Pod).
+		AddPrefixMulticolumnTable(kubernetes.ResourcesPrefix, []report.Row{
+			{ID: "app", Entries: map[string]string{kubernetes.ResourceContainer: "app"}},
+			{ID: "sidecar", Entries: map[string]string{kubernetes.ResourceContainer: "sidecar"}},
+		}).
+		WithChildren(report.MakeNodeSet(
+			container("c1", "app", 40, 900),
+			container("c2", "sidecar", 10, 50),
+		))
+
+	summary, ok := detailed.MakeNodeSummary(detailed.RenderContext{Report: rpt}, pod)
+	if !ok {
+		t.Fatal("Expected a summary of the pod")
+	}
+
+	// 50% of 2 CPUs, of a limit of 2 cores; 950 bytes of a limit of 1000
+	metrics := map[string]float64{}
+	for _, m := range summary.Metrics {
+		metrics[m.ID] = m.Value
+	}
+	for id, want := range map[string]float64{
+		kubernetes.CPULimitUsage:    50,
+		kubernetes.MemoryLimitUsage: 95,
+	} {
+		if have, ok := metrics[id]; !ok || have != want {
+			t.Errorf("Expected metric %s: %v, got %v", id, want, have)
+		}
+	}
+	if want := []string{"memory"}; !reflect.DeepEqual(want, summary.NearLimits) {
+		t.Errorf("Expected to be near limits %v, got %v", want, summary.NearLimits)
+	}
+
+	var rows []report.Row
+	for _, table := range summary.Tables {
+		if table.ID == kubernetes.ResourcesPrefix {
+			rows = table.Rows
+		}
+	}
+	want := []report.Row{
+		{ID: "app", Entries: map[string]string{
+			kubernetes.ResourceContainer:   "app",
+			kubernetes.ResourceCPUUsage:    "0.800",
+			kubernetes.ResourceMemoryUsage: "900",
+		}},
+		{ID: "sidecar", Entries: map[string]string{
+			kubernetes.ResourceContainer:   "sidecar",
+			kubernetes.ResourceCPUUsage:    "0.200",
+			kubernetes.ResourceMemoryUsage: "50",
+		}},
+	}
+	if !reflect.DeepEqual(want, rows) {
+		t.Errorf("Expected resources %v, got %v", want, rows)
+	}
+}
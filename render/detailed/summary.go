@@ -7,8 +7,10 @@ import (
 	"github.com/weaveworks/scope/probe/awsecs"
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/probe/nomad"
 	"github.com/weaveworks/scope/probe/overlay"
 	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/probe/systemd"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/report"
 )
@@ -56,11 +58,25 @@ type BasicNodeSummary struct {
 // NodeSummary is summary information about a Node.
 type NodeSummary struct {
 	BasicNodeSummary
-	Metadata  []report.MetadataRow `json:"metadata,omitempty"`
-	Parents   []Parent             `json:"parents,omitempty"`
-	Metrics   []report.MetricRow   `json:"metrics,omitempty"`
-	Tables    []report.Table       `json:"tables,omitempty"`
-	Adjacency report.IDList        `json:"adjacency,omitempty"`
+	Metadata   []report.MetadataRow `json:"metadata,omitempty"`
+	Parents    []Parent             `json:"parents,omitempty"`
+	Metrics    []report.MetricRow   `json:"metrics,omitempty"`
+	Tables     []report.Table       `json:"tables,omitempty"`
+	Adjacency  report.IDList        `json:"adjacency,omitempty"`
+	Annotation *Annotation          `json:"annotation,omitempty"`
+	Anomalies  []Anomaly            `json:"anomalies,omitempty"`
+	// Policies are what Kubernetes network policies make of the traffic to
+	// each adjacent node: allowed, denied or unspecified.
+	Policies map[string]string `json:"policies,omitempty"`
+	// Mesh is what the Envoy sidecar of pods sees of the traffic to each
+	// adjacent node: the requests proxied and whether they use mTLS.
+	Mesh map[string]render.MeshEdge `json:"mesh,omitempty"`
+	// Weights are how heavy the traffic to each adjacent node is, so edges
+	// can be drawn thicker without fetching the details of every node.
+	Weights map[string]render.EdgeWeight `json:"weights,omitempty"`
+	// NearLimits are the resources, cpu and memory, pods use most of their
+	// limits of.
+	NearLimits []string `json:"nearLimits,omitempty"`
 }
 
 var renderers = map[string]func(BasicNodeSummary, report.Node) BasicNodeSummary{
@@ -74,12 +90,27 @@ var renderers = map[string]func(BasicNodeSummary, report.Node) BasicNodeSummary{
 	report.DaemonSet:      podGroupNodeSummary,
 	report.StatefulSet:    podGroupNodeSummary,
 	report.CronJob:        podGroupNodeSummary,
+	report.Job:            podGroupNodeSummary,
+	report.CustomResource: customResourceNodeSummary,
+	report.Ingress:        ingressNodeSummary,
 	report.ECSTask:        ecsTaskNodeSummary,
 	report.ECSService:     ecsServiceNodeSummary,
 	report.SwarmService:   swarmServiceNodeSummary,
+	report.SwarmTask:      swarmTaskNodeSummary,
+	report.ComposeService: composeServiceNodeSummary,
+	report.DockerNetwork:  dockerNetworkNodeSummary,
+	report.DockerVolume:   dockerVolumeNodeSummary,
+	report.SystemdUnit:    systemdUnitNodeSummary,
 	report.Host:           hostNodeSummary,
 	report.Overlay:        weaveNodeSummary,
 	report.Endpoint:       nil, // Do not render
+
+	report.NomadJob:        nomadJobNodeSummary,
+	report.NomadTaskGroup:  nomadTaskGroupNodeSummary,
+	report.NomadAllocation: nomadAllocationNodeSummary,
+
+	// Trees of processes may stand for their root process
+	render.ProcessTreeTopology: processTreeNodeSummary,
 }
 
 // For each report.Topology, map to a 'primary' API topology. This can then be used in a variety of places.
@@ -92,11 +123,23 @@ var primaryAPITopology = map[string]string{
 	report.DaemonSet:      "kube-controllers",
 	report.StatefulSet:    "kube-controllers",
 	report.CronJob:        "kube-controllers",
+	report.Job:            "kube-controllers",
+	report.CustomResource: "custom-resources",
+	report.Ingress:        "services",
 	report.Service:        "services",
 	report.ECSTask:        "ecs-tasks",
 	report.ECSService:     "ecs-services",
 	report.SwarmService:   "swarm-services",
+	report.SwarmTask:      "swarm-tasks",
+	report.ComposeService: "compose-services",
+	report.DockerNetwork:  "containers-by-network",
+	report.DockerVolume:   "containers-by-volume",
+	report.SystemdUnit:    "processes-by-unit",
 	report.Host:           "hosts",
+
+	report.NomadJob:        "nomad-jobs",
+	report.NomadTaskGroup:  "nomad-task-groups",
+	report.NomadAllocation: "nomad-allocations",
 }
 
 // MakeBasicNodeSummary returns a basic summary of a node, if
@@ -141,6 +184,9 @@ func MakeNodeSummary(rc RenderContext, n report.Node) (NodeSummary, bool) {
 	if !ok {
 		return NodeSummary{}, false
 	}
+	if label, ok := n.Latest.Lookup(render.Label); ok {
+		base.Label = label
+	}
 	summary := NodeSummary{
 		BasicNodeSummary: base,
 		Parents:          Parents(rc.Report, n),
@@ -148,15 +194,47 @@ func MakeNodeSummary(rc RenderContext, n report.Node) (NodeSummary, bool) {
 	}
 	// Only include metadata, metrics, tables when it's not a group node
 	if _, ok := n.Counters.Lookup(n.Topology); !ok {
+		n = withResourceUsage(rc.Report, n)
+		summary.NearLimits = nearLimits(n)
 		if topology, ok := rc.Topology(n.Topology); ok {
 			summary.Metadata = topology.MetadataTemplates.MetadataRows(n)
 			summary.Metrics = topology.MetricTemplates.MetricRows(n)
 			summary.Tables = topology.TableTemplates.Tables(n)
+		} else if original, _, ok := render.ParseGroupNodeTopology(n.Topology); ok {
+			// Group nodes show the sums of the metrics of their members
+			if topology, ok := rc.Topology(original); ok {
+				summary.Metrics = topology.MetricTemplates.MetricRows(n)
+			}
 		}
 	}
+	if a, ok := rc.Annotations[n.ID]; ok {
+		summary.Annotation = &a
+	}
+	summary.Anomalies = rc.Anomalies[n.ID]
+	if rows := exposureRows(n); len(rows) > 0 {
+		summary.Metadata = append(rows, summary.Metadata...)
+	}
 	return RenderMetricURLs(summary, n, rc.Report, rc.MetricsGraphURL), true
 }
 
+var exposureTemplates = []report.MetadataTemplate{
+	{ID: render.ExposedPorts, Label: "Exposed ports", From: report.FromSets, Priority: 0.1},
+	{ID: render.ExposedProcesses, Label: "Exposed by", From: report.FromSets, Priority: 0.2},
+	{ID: render.ExposedImages, Label: "Exposed image", From: report.FromSets, Priority: 0.3},
+}
+
+// exposureRows are the rows of what InternetExposedRenderer noted of a
+// node, first.
+func exposureRows(n report.Node) []report.MetadataRow {
+	var rows []report.MetadataRow
+	for _, t := range exposureTemplates {
+		if row, ok := t.MetadataRow(n); ok {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
 // SummarizeMetrics returns a copy of the NodeSummary where the metrics are
 // replaced with their summaries
 func (n NodeSummary) SummarizeMetrics() NodeSummary {
@@ -189,6 +267,11 @@ func pseudoNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
 		base.Label = n.ID[len(render.ServiceNodeIDPrefix):]
 		base.LabelMinor = ""
 		base.Shape = report.Cloud
+	case strings.HasPrefix(n.ID, render.LoadBalancerIDPrefix):
+		// render as a load balancer node
+		base.Label = n.ID[len(render.LoadBalancerIDPrefix):]
+		base.LabelMinor = render.LoadBalancerMinor
+		base.Shape = report.Cloud
 	case strings.HasPrefix(n.ID, render.UncontainedIDPrefix):
 		// render as an uncontained node
 		base.Label = render.UncontainedMajor
@@ -201,6 +284,12 @@ func pseudoNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
 		base.LabelMinor = n.ID[len(render.UnmanagedIDPrefix):]
 		base.Shape = report.Square
 		base.Stack = true
+	case strings.HasPrefix(n.ID, render.UninstrumentedIDPrefix):
+		// render as the peers of a subnet or domain no probe reports on
+		base.Label = n.ID[len(render.UninstrumentedIDPrefix):]
+		base.LabelMinor = pluralize(n.Counters, render.UninstrumentedAddresses, "uninstrumented address", "uninstrumented addresses")
+		base.Shape = report.Square
+		base.Stack = true
 	default:
 		// try rendering it as an endpoint
 		if _, addr, _, ok := report.ParseEndpointNodeID(n.ID); ok {
@@ -241,6 +330,14 @@ func processNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
 	return base
 }
 
+func processTreeNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base = processNodeSummary(base, n)
+	base.LabelMinor = fmt.Sprintf("%s, %s", base.LabelMinor, pluralize(n.Counters, report.Process, "process", "processes"))
+	base.Shape = report.Square
+	base.Stack = true
+	return base
+}
+
 func containerNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
 	var (
 		containerName = getRenderableContainerName(n)
@@ -292,6 +389,9 @@ func addKubernetesLabelAndRank(base BasicNodeSummary, n report.Node) BasicNodeSu
 		base.Label, _, _ = report.ParseNodeID(n.ID)
 	}
 	base.Rank = namespace + "/" + base.Label
+	if cluster, ok := n.Latest.Lookup(kubernetes.Cluster); ok {
+		base.Rank = cluster + "/" + base.Rank
+	}
 	return base
 }
 
@@ -306,6 +406,7 @@ var podGroupNodeTypeName = map[string]string{
 	report.DaemonSet:   "DaemonSet",
 	report.StatefulSet: "StatefulSet",
 	report.CronJob:     "CronJob",
+	report.Job:         "Job",
 }
 
 func podGroupNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
@@ -322,6 +423,21 @@ func podGroupNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary
 	return base
 }
 
+func customResourceNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base = podGroupNodeSummary(base, n)
+	if kind, ok := n.Latest.Lookup(kubernetes.NodeType); ok {
+		base.LabelMinor = fmt.Sprintf("%s of %s", kind, pluralize(n.Counters, report.Pod, "pod", "pods"))
+	}
+	return base
+}
+
+func ingressNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base = addKubernetesLabelAndRank(base, n)
+	hosts, _ := n.Sets.Lookup(kubernetes.IngressHosts)
+	base.LabelMinor = strings.Join(hosts, ", ")
+	return base
+}
+
 func ecsTaskNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
 	base.Label, _ = n.Latest.Lookup(awsecs.TaskFamily)
 	if base.Label == "" {
@@ -336,6 +452,37 @@ func ecsServiceNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummar
 	return base
 }
 
+func nomadJobNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	namespace, jobID, _ := report.ParseNomadJobNodeID(n.ID)
+	base.Label, _ = n.Latest.Lookup(nomad.JobName)
+	if base.Label == "" {
+		base.Label = jobID
+	}
+	base.LabelMinor = namespace
+	base.Stack = true
+	return base
+}
+
+func nomadTaskGroupNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	_, jobID, group, _ := report.ParseNomadTaskGroupNodeID(n.ID)
+	base.Label = group
+	base.LabelMinor, _ = n.Latest.Lookup(nomad.JobName)
+	if base.LabelMinor == "" {
+		base.LabelMinor = jobID
+	}
+	base.Stack = true
+	return base
+}
+
+func nomadAllocationNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base.Label, _ = n.Latest.Lookup(nomad.AllocationName)
+	if base.Label == "" {
+		base.Label, _ = report.ParseNomadAllocationNodeID(n.ID)
+	}
+	base.LabelMinor, _ = n.Latest.Lookup(nomad.AllocationStatus)
+	return base
+}
+
 func swarmServiceNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
 	base.Label, _ = n.Latest.Lookup(docker.ServiceName)
 	if base.Label == "" {
@@ -344,6 +491,59 @@ func swarmServiceNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSumm
 	return base
 }
 
+func swarmTaskNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base.Label, _ = n.Latest.Lookup(docker.SwarmTaskName)
+	if base.Label == "" {
+		base.Label, _ = report.ParseSwarmTaskNodeID(n.ID)
+	}
+	base.LabelMinor, _ = n.Latest.Lookup(docker.SwarmTaskNode)
+	return base
+}
+
+func composeServiceNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base.Label, _ = n.Latest.Lookup(docker.ComposeService)
+	base.LabelMinor, _ = n.Latest.Lookup(docker.ComposeProject)
+	if base.Label == "" {
+		base.Label, _ = report.ParseComposeServiceNodeID(n.ID)
+	}
+	base.Stack = true
+	return base
+}
+
+func dockerNetworkNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base.Label, _ = n.Latest.Lookup(docker.NetworkName)
+	base.LabelMinor, _ = n.Latest.Lookup(docker.NetworkDriver)
+	if base.Label == "" {
+		base.Label, _ = report.ParseDockerNetworkNodeID(n.ID)
+	}
+	base.Stack = true
+	return base
+}
+
+func dockerVolumeNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	hostID, source, _ := report.ParseDockerVolumeNodeID(n.ID)
+	base.Label, _ = n.Latest.Lookup(docker.VolumeName)
+	if base.Label == "" {
+		base.Label = source
+	}
+	base.LabelMinor = hostID
+	base.Rank = base.Label
+	base.Stack = true
+	return base
+}
+
+func systemdUnitNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	hostID, unit, _ := report.ParseSystemdUnitNodeID(n.ID)
+	base.Label = unit
+	base.LabelMinor = hostID
+	if state, ok := n.Latest.Lookup(systemd.ActiveState); ok && state != "active" {
+		base.LabelMinor = fmt.Sprintf("%s (%s)", hostID, state)
+	}
+	base.Rank = unit
+	base.Stack = true
+	return base
+}
+
 func hostNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
 	var (
 		hostname, _ = report.ParseHostNodeID(n.ID)
@@ -411,11 +611,17 @@ type NodeSummaries map[string]NodeSummary
 func Summaries(rc RenderContext, rns report.Nodes) NodeSummaries {
 
 	result := NodeSummaries{}
+	verdicts := render.NetworkPolicyVerdicts(rc.Report, rns)
+	mesh := render.MeshTraffic(rc.Report, rns)
+	weights := render.EdgeWeights(rns)
 	for id, node := range rns {
 		if summary, ok := MakeNodeSummary(rc, node); ok {
 			for i, m := range summary.Metrics {
 				summary.Metrics[i] = m.Summary()
 			}
+			summary.Policies = verdicts[id]
+			summary.Mesh = mesh[id]
+			summary.Weights = weights[id]
 			result[id] = summary
 		}
 	}
@@ -236,6 +236,21 @@ func TestMakeNodeSummaryNoMetadata(t *testing.T) {
 	}
 }
 
+func TestMakeNodeSummaryInternetExposed(t *testing.T) {
+	nodes := render.InternetExposedRenderer.Render(fixture.Report).Nodes
+	summary, ok := detailed.MakeNodeSummary(detailed.RenderContext{Report: fixture.Report}, nodes[fixture.ServerContainerNodeID])
+	if !ok {
+		t.Fatal("Expected a summary of the server container")
+	}
+	want := []report.MetadataRow{
+		{ID: render.ExposedPorts, Label: "Exposed ports", Value: fixture.ServerPort + "/tcp", Priority: 0.1},
+		{ID: render.ExposedProcesses, Label: "Exposed by", Value: fixture.ServerName, Priority: 0.2},
+	}
+	if have := summary.Metadata[:2]; !reflect.DeepEqual(want, have) {
+		t.Errorf("%s", test.Diff(want, have))
+	}
+}
+
 func TestNodeMetadata(t *testing.T) {
 	inputs := []struct {
 		name string
@@ -2,15 +2,19 @@ package detailed
 
 import (
 	"reflect"
+
+	"github.com/weaveworks/scope/render/layout"
 )
 
 // Diff is returned by TopoDiff. It represents the changes between two
-// NodeSummary maps.
+// NodeSummary maps. Layout, if asked for, has the positions of the nodes
+// which have moved, or been added.
 type Diff struct {
 	Add    []NodeSummary `json:"add"`
 	Update []NodeSummary `json:"update"`
 	Remove []string      `json:"remove"`
 	Reset  bool          `json:"reset,omitempty"`
+	Layout layout.Layout `json:"layout,omitempty"`
 }
 
 // TopoDiff gives you the diff to get from A to B.
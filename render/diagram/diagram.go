@@ -0,0 +1,123 @@
+// Package diagram writes rendered topologies as the text of diagrams, in
+// the DOT language of Graphviz, D2 or Mermaid, e.g. to generate
+// architecture diagrams from the dependencies observed.
+package diagram
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+// Diagram is a topology to write.
+type Diagram struct {
+	Title string
+	Nodes detailed.NodeSummaries
+}
+
+// node is a node of a diagram, named n0, n1... in the order of the IDs of
+// the nodes, as the IDs of rendered nodes are not valid in all formats.
+type node struct {
+	detailed.NodeSummary
+	name string
+}
+
+func (n node) label() string {
+	if n.LabelMinor == "" {
+		return n.Label
+	}
+	return n.Label + "\n" + n.LabelMinor
+}
+
+// layout returns the nodes of a diagram, and its edges, between those of
+// them, both in order.
+func (d Diagram) layout() ([]node, [][2]node) {
+	ids := make([]string, 0, len(d.Nodes))
+	for id := range d.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	nodes := make([]node, 0, len(ids))
+	byID := make(map[string]node, len(ids))
+	for i, id := range ids {
+		n := node{NodeSummary: d.Nodes[id], name: fmt.Sprintf("n%d", i)}
+		nodes = append(nodes, n)
+		byID[id] = n
+	}
+	var edges [][2]node
+	for _, n := range nodes {
+		for _, dstID := range n.Adjacency {
+			if dst, ok := byID[dstID]; ok && dstID != n.ID {
+				edges = append(edges, [2]node{n, dst})
+			}
+		}
+	}
+	return nodes, edges
+}
+
+// DOT and D2 escape quotes in strings alike.
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteDOT writes a diagram in the DOT language of Graphviz.
+func WriteDOT(w io.Writer, d Diagram) error {
+	nodes, edges := d.layout()
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "digraph \"%s\" {\n", escaper.Replace(d.Title))
+	fmt.Fprintf(b, "\tlabel=\"%s\";\n", escaper.Replace(d.Title))
+	for _, n := range nodes {
+		style := ""
+		if n.Pseudo {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(b, "\t%s [label=\"%s\"%s];\n", n.name, escaper.Replace(n.label()), style)
+	}
+	for _, e := range edges {
+		fmt.Fprintf(b, "\t%s -> %s;\n", e[0].name, e[1].name)
+	}
+	fmt.Fprintf(b, "}\n")
+	return b.Flush()
+}
+
+// WriteD2 writes a diagram in D2.
+func WriteD2(w io.Writer, d Diagram) error {
+	nodes, edges := d.layout()
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "title: \"%s\" {\n\tshape: text\n\tnear: top-center\n}\n", escaper.Replace(d.Title))
+	for _, n := range nodes {
+		if n.Pseudo {
+			fmt.Fprintf(b, "%s: \"%s\" {\n\tstyle.stroke-dash: 3\n}\n", n.name, escaper.Replace(n.label()))
+			continue
+		}
+		fmt.Fprintf(b, "%s: \"%s\"\n", n.name, escaper.Replace(n.label()))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(b, "%s -> %s\n", e[0].name, e[1].name)
+	}
+	return b.Flush()
+}
+
+// Mermaid has entities, rather than escapes, for quotes in labels.
+var mermaidEscaper = strings.NewReplacer(`"`, "#quot;", "\n", "<br/>")
+
+// WriteMermaid writes a diagram as a Mermaid flowchart.
+func WriteMermaid(w io.Writer, d Diagram) error {
+	nodes, edges := d.layout()
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "---\ntitle: \"%s\"\n---\nflowchart LR\n", strings.Replace(d.Title, `"`, `'`, -1))
+	for _, n := range nodes {
+		// Pseudo nodes are drawn as stadiums
+		start, end := "[", "]"
+		if n.Pseudo {
+			start, end = "([", "])"
+		}
+		fmt.Fprintf(b, "\t%s%s\"%s\"%s\n", n.name, start, mermaidEscaper.Replace(n.label()), end)
+	}
+	for _, e := range edges {
+		fmt.Fprintf(b, "\t%s --> %s\n", e[0].name, e[1].name)
+	}
+	return b.Flush()
+}
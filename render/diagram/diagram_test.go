@@ -0,0 +1,76 @@
+package diagram_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/render/diagram"
+	"github.com/weaveworks/scope/report"
+)
+
+func testDiagram() diagram.Diagram {
+	summary := func(id, label, minor string, pseudo bool, adjacent ...string) detailed.NodeSummary {
+		return detailed.NodeSummary{
+			BasicNodeSummary: detailed.BasicNodeSummary{ID: id, Label: label, LabelMinor: minor, Pseudo: pseudo},
+			Adjacency:        report.MakeIDList(adjacent...),
+		}
+	}
+	return diagram.Diagram{
+		Title: "Containers",
+		Nodes: detailed.NodeSummaries{
+			"web;<container>": summary("web;<container>", `front "end"`, "host1", false, "db;<container>", "gone"),
+			"db;<container>":  summary("db;<container>", "db", "", false),
+			"in-theinternet":  summary("in-theinternet", "The Internet", "", true, "web;<container>"),
+		},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		write func(io.Writer, diagram.Diagram) error
+		want  string
+	}{
+		{"dot", diagram.WriteDOT, `digraph "Containers" {
+	label="Containers";
+	n0 [label="db"];
+	n1 [label="The Internet", style=dashed];
+	n2 [label="front \"end\"\nhost1"];
+	n1 -> n2;
+	n2 -> n0;
+}
+`},
+		{"d2", diagram.WriteD2, `title: "Containers" {
+	shape: text
+	near: top-center
+}
+n0: "db"
+n1: "The Internet" {
+	style.stroke-dash: 3
+}
+n2: "front \"end\"\nhost1"
+n1 -> n2
+n2 -> n0
+`},
+		{"mermaid", diagram.WriteMermaid, `---
+title: "Containers"
+---
+flowchart LR
+	n0["db"]
+	n1(["The Internet"])
+	n2["front #quot;end#quot;<br/>host1"]
+	n1 --> n2
+	n2 --> n0
+`},
+	} {
+		var buf bytes.Buffer
+		if err := c.write(&buf, testDiagram()); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if have := buf.String(); have != c.want {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", c.name, c.want, have)
+		}
+	}
+}
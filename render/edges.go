@@ -0,0 +1,83 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/report"
+)
+
+// MakePortProtocol makes the port/protocol a connection is to, e.g.
+// "9100/tcp", as HideEdgesOnPorts takes them.
+func MakePortProtocol(port, protocol string) string {
+	return port + "/" + protocol
+}
+
+// HideEdgesOnPorts is a Transformer hiding the edges between rendered
+// nodes whose connections are all to the given ports, by port/protocol,
+// e.g. those of metrics scrapes. Edges with no connections of endpoints
+// beneath them are kept.
+type HideEdgesOnPorts map[string]struct{}
+
+// Transform implements Transformer
+func (h HideEdgesOnPorts) Transform(nodes Nodes) Nodes {
+	output := make(report.Nodes, len(nodes.Nodes))
+	for id, n := range nodes.Nodes {
+		if len(n.Adjacency) == 0 {
+			output[id] = n
+			continue
+		}
+		local := endpointChildren(n)
+		adjacency := report.MakeIDList()
+		for _, dstID := range n.Adjacency {
+			if dst, ok := nodes.Nodes[dstID]; ok && h.hidden(local, dst) {
+				continue
+			}
+			adjacency = adjacency.Add(dstID)
+		}
+		n.Adjacency = adjacency
+		output[id] = n
+	}
+	return Nodes{Nodes: output, Filtered: nodes.Filtered}
+}
+
+// hidden tells whether all the connections from the local endpoints to
+// dst are to hidden ports, and there are some.
+func (h HideEdgesOnPorts) hidden(local []report.Node, dst report.Node) bool {
+	remoteIDs := report.MakeIDList()
+	copies := map[string]string{}
+	dst.Children.ForEach(func(child report.Node) {
+		if child.Topology == report.Endpoint {
+			remoteIDs = remoteIDs.Add(child.ID)
+			if copyID, ok := child.Latest.Lookup(endpoint.CopyOf); ok {
+				copies[child.ID] = copyID
+			}
+		}
+	})
+	any := false
+	for _, ep := range local {
+		for _, remoteID := range ep.Adjacency.Intersection(remoteIDs) {
+			// The port of a NATed connection is the one it was to
+			if original, ok := copies[remoteID]; ok {
+				remoteID = original
+			}
+			_, _, port, protocol, ok := report.ParseEndpointNodeIDWithProtocol(remoteID)
+			if !ok {
+				return false
+			}
+			if _, ok := h[MakePortProtocol(port, protocol)]; !ok {
+				return false
+			}
+			any = true
+		}
+	}
+	return any
+}
+
+func endpointChildren(n report.Node) []report.Node {
+	result := []report.Node{}
+	n.Children.ForEach(func(child report.Node) {
+		if child.Topology == report.Endpoint {
+			result = append(result, child)
+		}
+	})
+	return result
+}
@@ -0,0 +1,48 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestHideEdgesOnPorts(t *testing.T) {
+	var (
+		clientScrape = report.MakeNode(report.MakeEndpointNodeID("", "", "10.0.0.1", "40001")).WithTopology(report.Endpoint).
+				WithAdjacent(report.MakeEndpointNodeID("", "", "10.0.0.2", "9100"))
+		clientQuery = report.MakeNode(report.MakeEndpointNodeID("", "", "10.0.0.1", "40002")).WithTopology(report.Endpoint).
+				WithAdjacent(report.MakeEndpointNodeIDWithProtocol("", "", "10.0.0.3", "53", report.UDP))
+		serverMetrics = report.MakeNode(report.MakeEndpointNodeID("", "", "10.0.0.2", "9100")).WithTopology(report.Endpoint)
+		serverDNS     = report.MakeNode(report.MakeEndpointNodeIDWithProtocol("", "", "10.0.0.3", "53", report.UDP)).WithTopology(report.Endpoint)
+	)
+	nodes := render.Nodes{Nodes: report.Nodes{
+		"client": report.MakeNode("client").WithAdjacent("metrics", "dns", "other").
+			WithChildren(report.MakeNodeSet(clientScrape, clientQuery)),
+		"metrics": report.MakeNode("metrics").WithChildren(report.MakeNodeSet(serverMetrics)),
+		"dns":     report.MakeNode("dns").WithChildren(report.MakeNodeSet(serverDNS)),
+		"other":   report.MakeNode("other"),
+	}}
+
+	for _, c := range []struct {
+		hide []string
+		want report.IDList
+	}{
+		{nil, report.MakeIDList("metrics", "dns", "other")},
+		{[]string{"9100/tcp"}, report.MakeIDList("dns", "other")},
+		{[]string{"9100/tcp", "53/udp"}, report.MakeIDList("other")},
+		{[]string{"53/tcp"}, report.MakeIDList("metrics", "dns", "other")},
+	} {
+		hide := render.HideEdgesOnPorts{}
+		for _, port := range c.hide {
+			hide[port] = struct{}{}
+		}
+		if have := hide.Transform(nodes).Nodes["client"].Adjacency; !reflect.DeepEqual(c.want, have) {
+			t.Errorf("Hiding %v, expected edges to %v, got %v", c.hide, c.want, have)
+		}
+	}
+	if have := nodes.Nodes["client"].Adjacency; len(have) != 3 {
+		t.Errorf("Expected the nodes transformed left alone, got %v", have)
+	}
+}
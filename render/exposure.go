@@ -0,0 +1,85 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+// Sets of what InternetExposedRenderer notes of the nodes the internet
+// connects to.
+const (
+	ExposedPorts     = "internet_exposed_ports"     // port/protocol, e.g. 443/tcp
+	ExposedProcesses = "internet_exposed_processes" // names of the processes listening on them
+	ExposedImages    = "internet_exposed_images"
+)
+
+// InternetExposedRenderer renders the containers, and uncontained
+// processes, the internet connects to, and the incoming internet node
+// only, with its edges to them, to audit what is exposed to the internet.
+var InternetExposedRenderer = Memoise(internetExposedRenderer{ContainerWithImageNameRenderer})
+
+type internetExposedRenderer struct {
+	Renderer
+}
+
+// Render implements Renderer
+func (r internetExposedRenderer) Render(rpt report.Report) Nodes {
+	nodes := r.Renderer.Render(rpt)
+	internet, ok := nodes.Nodes[IncomingInternetID]
+	if !ok {
+		return Nodes{Filtered: nodes.Filtered}
+	}
+	remoteIDs := report.MakeIDList()
+	for _, ep := range endpointChildren(internet) {
+		remoteIDs = remoteIDs.Merge(ep.Adjacency)
+	}
+
+	output := report.Nodes{}
+	for _, id := range internet.Adjacency {
+		n, ok := nodes.Nodes[id]
+		if !ok || IsInternetNode(n) {
+			continue
+		}
+		var (
+			ports     = report.MakeStringSet()
+			processes = report.MakeStringSet()
+			images    = report.MakeStringSet()
+		)
+		for _, ep := range endpointChildren(n) {
+			if !remoteIDs.Contains(ep.ID) {
+				continue
+			}
+			if _, _, port, protocol, ok := report.ParseEndpointNodeIDWithProtocol(ep.ID); ok {
+				ports = ports.Add(MakePortProtocol(port, protocol))
+			}
+			if pid, ok := ep.Latest.Lookup(process.PID); ok {
+				p := rpt.Process.Nodes[report.MakeProcessNodeID(report.ExtractHostID(ep), pid)]
+				if name, ok := p.Latest.Lookup(process.Name); ok {
+					processes = processes.Add(name)
+				}
+			}
+		}
+		if image, ok := n.Latest.Lookup(docker.ImageName); ok {
+			images = images.Add(image)
+		}
+		n.Adjacency = report.MakeIDList()
+		for key, values := range map[string]report.StringSet{ExposedPorts: ports, ExposedProcesses: processes, ExposedImages: images} {
+			if len(values) > 0 {
+				n = n.WithSet(key, values)
+			}
+		}
+		output[id] = n
+	}
+	internet.Adjacency = report.MakeIDList(keys(output)...)
+	output[IncomingInternetID] = internet
+	return Nodes{Nodes: output, Filtered: nodes.Filtered}
+}
+
+func keys(nodes report.Nodes) []string {
+	result := make([]string, 0, len(nodes))
+	for id := range nodes {
+		result = append(result, id)
+	}
+	return result
+}
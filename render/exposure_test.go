@@ -0,0 +1,39 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestInternetExposedRenderer(t *testing.T) {
+	have := render.InternetExposedRenderer.Render(fixture.Report).Nodes
+	if len(have) != 2 {
+		t.Fatalf("Expected the internet and the server container only, got %v", have)
+	}
+	internet := have[render.IncomingInternetID]
+	if want := report.MakeIDList(fixture.ServerContainerNodeID); !reflect.DeepEqual(want, internet.Adjacency) {
+		t.Errorf("Expected the internet to connect to %v, got %v", want, internet.Adjacency)
+	}
+	server := have[fixture.ServerContainerNodeID]
+	image, ok := fixture.Report.ContainerImage.Nodes[fixture.ServerContainerImageNodeID].Latest.Lookup(docker.ImageName)
+	if !ok {
+		t.Fatal("Expected the image of the server to have a name")
+	}
+	for key, want := range map[string]report.StringSet{
+		render.ExposedPorts:     report.MakeStringSet(render.MakePortProtocol(fixture.ServerPort, "tcp")),
+		render.ExposedProcesses: report.MakeStringSet(fixture.ServerName),
+		render.ExposedImages:    report.MakeStringSet(image),
+	} {
+		if have, _ := server.Sets.Lookup(key); !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: expected %v, got %v", key, want, have)
+		}
+	}
+	if len(server.Adjacency) != 0 {
+		t.Errorf("Expected no edges from the server, got %v", server.Adjacency)
+	}
+}
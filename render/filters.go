@@ -1,10 +1,12 @@
 package render
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/weaveworks/common/mtime"
 	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/probe/kubernetes"
 	"github.com/weaveworks/scope/report"
 )
@@ -262,15 +264,62 @@ func HasLabel(labelKey string, labelValue string) FilterFunc {
 	}
 }
 
+// HasLatest checks if the node has the value for the key in its Latest
+func HasLatest(key string, value string) FilterFunc {
+	return func(n report.Node) bool {
+		v, ok := n.Latest.Lookup(key)
+		return ok && v == value
+	}
+}
+
 // DoesNotHaveLabel checks if the node does NOT have the specified docker label
 func DoesNotHaveLabel(labelKey string, labelValue string) FilterFunc {
 	return Complement(HasLabel(labelKey, labelValue))
 }
 
+// HasCriticalVulnerabilities checks if the node is a container running, or
+// the image of containers, with critical CVEs
+func HasCriticalVulnerabilities(n report.Node) bool {
+	critical, _ := n.Latest.Lookup(docker.ImageCVEsCritical)
+	count, err := strconv.Atoi(critical)
+	return err == nil && count > 0
+}
+
+// IsPrivilegedContainer checks if the node is a container which is
+// privileged, or may as well be: it has all capabilities, or
+// CAP_SYS_ADMIN, or shares the PID or IPC namespace of the host.
+func IsPrivilegedContainer(n report.Node) bool {
+	if privileged, _ := n.Latest.Lookup(docker.ContainerPrivileged); privileged == "true" {
+		return true
+	}
+	if caps, ok := n.Sets.Lookup(docker.ContainerCapabilities); ok && (caps.Contains(docker.AllCapabilities) || caps.Contains("SYS_ADMIN")) {
+		return true
+	}
+	namespaces, _ := n.Sets.Lookup(docker.ContainerHostNamespaces)
+	return namespaces.Contains("pid") || namespaces.Contains("ipc")
+}
+
 // IsNotPseudo returns true if the node is not a pseudo node
-// or internet/service nodes.
+// or internet/service/load balancer nodes.
 func IsNotPseudo(n report.Node) bool {
-	return n.Topology != Pseudo || IsInternetNode(n) || strings.HasPrefix(n.ID, ServiceNodeIDPrefix)
+	return n.Topology != Pseudo || IsInternetNode(n) || strings.HasPrefix(n.ID, ServiceNodeIDPrefix) || strings.HasPrefix(n.ID, LoadBalancerIDPrefix)
+}
+
+// IsCluster checks if the node is in the specified kubernetes cluster
+func IsCluster(cluster string) FilterFunc {
+	return func(n report.Node) bool {
+		gotCluster, _ := n.Latest.Lookup(kubernetes.Cluster)
+		return cluster == gotCluster
+	}
+}
+
+// IsAvailabilityZone checks if the node is a host in the specified
+// availability zone of its cloud
+func IsAvailabilityZone(zone string) FilterFunc {
+	return func(n report.Node) bool {
+		gotZone, _ := n.Latest.Lookup(host.Zone)
+		return zone == gotZone
+	}
 }
 
 // IsNamespace checks if the node is a pod/service in the specified namespace
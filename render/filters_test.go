@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/reflect"
@@ -98,3 +99,20 @@ func TestFilterUnconnectedSelf(t *testing.T) {
 		}
 	}
 }
+
+func TestIsPrivilegedContainer(t *testing.T) {
+	for _, c := range []struct {
+		node report.Node
+		want bool
+	}{
+		{report.MakeNodeWith("a", map[string]string{docker.ContainerPrivileged: "true"}), true},
+		{report.MakeNodeWith("b", map[string]string{docker.ContainerPrivileged: "false"}).WithSet(docker.ContainerCapabilities, report.MakeStringSet("CHOWN", "SYS_ADMIN")), true},
+		{report.MakeNodeWith("c", map[string]string{docker.ContainerPrivileged: "false"}).WithSet(docker.ContainerHostNamespaces, report.MakeStringSet("pid")), true},
+		{report.MakeNodeWith("d", map[string]string{docker.ContainerPrivileged: "false"}).WithSet(docker.ContainerHostNamespaces, report.MakeStringSet("network")), false},
+		{report.MakeNode("e"), false},
+	} {
+		if have := render.IsPrivilegedContainer(c.node); have != c.want {
+			t.Errorf("%s: want %v, have %v", c.node.ID, c.want, have)
+		}
+	}
+}
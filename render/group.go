@@ -0,0 +1,74 @@
+package render
+
+import (
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+// GroupBy is a Transformer grouping the rendered nodes by the value they
+// have for a key of their Latest, e.g. a label or a metadata key. Each
+// group node counts its members, has them as children, and sums the last
+// values of their metrics, and has the value they share in its Latest.
+// Nodes without the key are dropped, and pseudo nodes are kept as they are.
+type GroupBy struct {
+	Key string
+}
+
+// Transform implements Transformer
+func (g GroupBy) Transform(nodes Nodes) Nodes {
+	ret := newJoinResults(nil)
+	sums := map[string]metricSums{}
+	filtered := nodes.Filtered
+	for _, n := range nodes.Nodes {
+		if n.Topology == Pseudo {
+			ret.passThrough(n)
+			continue
+		}
+		id, ok := n.Latest.Lookup(g.Key)
+		if !ok || id == "" {
+			filtered++
+			continue
+		}
+		ret.addChildAndChildren(n, id, MakeGroupNodeTopology(n.Topology, g.Key))
+		if _, ok := sums[id]; !ok {
+			sums[id] = metricSums{}
+		}
+		sums[id].add(n.Metrics)
+	}
+	now := mtime.Now()
+	for id, s := range sums {
+		node := ret.nodes[id].WithLatest(g.Key, now, id)
+		for key, metric := range s {
+			node = node.WithMetric(key, metric)
+		}
+		ret.nodes[id] = node
+	}
+	result := ret.result(nodes)
+	result.Filtered = filtered
+	return result
+}
+
+// metricSums adds up the last samples of the metrics of the members of a
+// group, and their maximums, so e.g. the CPU of a group is that of all its
+// members.
+type metricSums map[string]report.Metric
+
+func (s metricSums) add(metrics report.Metrics) {
+	for key, metric := range metrics {
+		sample, ok := metric.LastSample()
+		if !ok {
+			continue
+		}
+		sum, ok := s[key]
+		if !ok {
+			s[key] = report.MakeSingletonMetric(sample.Timestamp, sample.Value).WithMax(metric.Max)
+			continue
+		}
+		last, _ := sum.LastSample()
+		timestamp := last.Timestamp
+		if sample.Timestamp.After(timestamp) {
+			timestamp = sample.Timestamp
+		}
+		s[key] = report.MakeSingletonMetric(timestamp, last.Value+sample.Value).WithMax(sum.Max + metric.Max)
+	}
+}
@@ -0,0 +1,58 @@
+package render_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestGroupBy(t *testing.T) {
+	var (
+		now  = time.Now()
+		team = docker.LabelPrefix + "team"
+		cpu  = func(v float64) report.Metric { return report.MakeSingletonMetric(now, v).WithMax(100) }
+	)
+	nodes := render.Nodes{Nodes: report.Nodes{
+		"a": report.MakeNodeWith("a", map[string]string{team: "shop"}).WithTopology(report.Container).
+			WithMetric(docker.CPUTotalUsage, cpu(10)).WithAdjacent("c"),
+		"b": report.MakeNodeWith("b", map[string]string{team: "shop"}).WithTopology(report.Container).
+			WithMetric(docker.CPUTotalUsage, cpu(20)).WithAdjacent(render.IncomingInternetID),
+		"c": report.MakeNodeWith("c", map[string]string{team: "billing"}).WithTopology(report.Container).
+			WithAdjacent("d"),
+		"d":                       report.MakeNode("d").WithTopology(report.Container),
+		render.IncomingInternetID: report.MakeNode(render.IncomingInternetID).WithTopology(render.Pseudo),
+	}}
+	have := render.GroupBy{Key: team}.Transform(nodes)
+
+	ids := report.MakeIDList()
+	for id := range have.Nodes {
+		ids = ids.Add(id)
+	}
+	if want := report.MakeIDList("billing", render.IncomingInternetID, "shop"); !reflect.DeepEqual(want, ids) {
+		t.Fatalf("Expected groups %v, got %v", want, ids)
+	}
+	if have.Filtered != 1 {
+		t.Errorf("Expected the node without a team filtered, got %d", have.Filtered)
+	}
+	shop := have.Nodes["shop"]
+	if shop.Topology != render.MakeGroupNodeTopology(report.Container, team) {
+		t.Errorf("Expected a group node, got %s", shop.Topology)
+	}
+	if count, _ := shop.Counters.Lookup(report.Container); count != 2 {
+		t.Errorf("Expected 2 containers in the shop group, got %d", count)
+	}
+	if want := report.MakeIDList("billing", render.IncomingInternetID); !reflect.DeepEqual(want, shop.Adjacency) {
+		t.Errorf("Expected the edges of the group's members, got %v", shop.Adjacency)
+	}
+	if len(have.Nodes["billing"].Adjacency) != 0 {
+		t.Errorf("Expected no edges to dropped nodes, got %v", have.Nodes["billing"].Adjacency)
+	}
+	metric, _ := shop.Metrics.Lookup(docker.CPUTotalUsage)
+	if sample, _ := metric.LastSample(); sample.Value != 30 || metric.Max != 200 {
+		t.Errorf("Expected the CPU of the group summed, got %v of %v", sample.Value, metric.Max)
+	}
+}
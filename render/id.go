@@ -102,24 +102,36 @@ func externalNodeID(n report.Node, addr string, local report.Networks) (string,
 	return "", false
 }
 
+// Where the DNS names of a node came from, in order of priority
+const (
+	SnoopedDNSSource = "snooped" // sniffed DNS responses
+	ReverseDNSSource = "reverse" // reverse DNS lookups made by the probe
+)
+
 // DNSFirstMatch returns the first DNS name where match() returns
 // true, from a prioritized list of snooped and reverse-resolved DNS
 // names associated with node n.
 func DNSFirstMatch(n report.Node, match func(name string) bool) (string, bool) {
+	hostname, _, found := DNSFirstMatchWithSource(n, match)
+	return hostname, found
+}
+
+// DNSFirstMatchWithSource is like DNSFirstMatch, but also tells where
+// the name came from.
+func DNSFirstMatchWithSource(n report.Node, match func(name string) bool) (string, string, bool) {
 	// we rely on Sets being sorted, to make selection for display more
 	// deterministic
 	// prioritize snooped names
-	snoopedNames, _ := n.Sets.Lookup(endpoint.SnoopedDNSNames)
-	for _, hostname := range snoopedNames {
-		if match(hostname) {
-			return hostname, true
-		}
-	}
-	reverseNames, _ := n.Sets.Lookup(endpoint.ReverseDNSNames)
-	for _, hostname := range reverseNames {
-		if match(hostname) {
-			return hostname, true
+	for _, source := range []struct{ key, name string }{
+		{endpoint.SnoopedDNSNames, SnoopedDNSSource},
+		{endpoint.ReverseDNSNames, ReverseDNSSource},
+	} {
+		names, _ := n.Sets.Lookup(source.key)
+		for _, hostname := range names {
+			if match(hostname) {
+				return hostname, source.name, true
+			}
 		}
 	}
-	return "", false
+	return "", "", false
 }
@@ -0,0 +1,105 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/report"
+)
+
+// Constants are used in the tests.
+const (
+	LoadBalancerID    = "loadbalancer"
+	LoadBalancerMinor = "load balancer"
+)
+
+// LoadBalancerIDPrefix is the prefix of load balancer pseudo nodes
+var LoadBalancerIDPrefix = MakePseudoNodeID(LoadBalancerID, "")
+
+// EntryPoints returns a Renderer adding, to the services or pods r renders,
+// the entry points into the cluster towards them: the ingresses routing to
+// their services, and the load balancers and external IPs of their services.
+// Connections from the internet to the pods of a service with a load
+// balancer come in through it.
+func EntryPoints(r Renderer) Renderer {
+	return entryPoints{r}
+}
+
+type entryPoints struct {
+	Renderer
+}
+
+func (e entryPoints) Render(rpt report.Report) Nodes {
+	input := e.Renderer.Render(rpt)
+	if len(rpt.Ingress.Nodes) == 0 && !hasExternalIPs(rpt.Service) {
+		return input
+	}
+
+	// The rendered nodes of each service
+	backends := map[string]report.IDList{}
+	for id, n := range input.Nodes {
+		switch n.Topology {
+		case report.Service:
+			backends[id] = backends[id].Add(id)
+		case report.Pod:
+			serviceIDs, _ := n.Parents.Lookup(report.Service)
+			for _, serviceID := range serviceIDs {
+				backends[serviceID] = backends[serviceID].Add(id)
+			}
+		}
+	}
+
+	output := make(report.Nodes, len(input.Nodes))
+	for id, n := range input.Nodes {
+		output[id] = n
+	}
+	for id, n := range rpt.Ingress.Nodes {
+		adjacency := report.MakeIDList()
+		for _, serviceID := range n.Adjacency {
+			adjacency = adjacency.Merge(backends[serviceID])
+		}
+		if len(adjacency) == 0 {
+			continue
+		}
+		n = n.WithTopology(report.Ingress)
+		n.Adjacency = adjacency
+		output[id] = n
+	}
+
+	loadBalancers := map[string]report.IDList{}
+	for serviceID, ids := range backends {
+		externalIPs, _ := rpt.Service.Nodes[serviceID].Sets.Lookup(kubernetes.ExternalIPs)
+		for _, ip := range externalIPs {
+			id := MakePseudoNodeID(LoadBalancerID, ip)
+			n, ok := output[id]
+			if !ok {
+				n = report.MakeNode(id).WithTopology(Pseudo)
+			}
+			n.Adjacency = n.Adjacency.Merge(ids)
+			output[id] = n
+			for _, backendID := range ids {
+				loadBalancers[backendID] = loadBalancers[backendID].Add(id)
+			}
+		}
+	}
+	if internet, ok := output[IncomingInternetID]; ok && len(loadBalancers) > 0 {
+		adjacency := report.MakeIDList()
+		for _, id := range internet.Adjacency {
+			if ids, ok := loadBalancers[id]; ok {
+				adjacency = adjacency.Merge(ids)
+			} else {
+				adjacency = adjacency.Add(id)
+			}
+		}
+		internet.Adjacency = adjacency
+		output[IncomingInternetID] = internet
+	}
+	return Nodes{Nodes: output, Filtered: input.Filtered}
+}
+
+func hasExternalIPs(services report.Topology) bool {
+	for _, n := range services.Nodes {
+		if ips, ok := n.Sets.Lookup(kubernetes.ExternalIPs); ok && len(ips) > 0 {
+			return true
+		}
+	}
+	return false
+}
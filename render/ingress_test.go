@@ -0,0 +1,49 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestEntryPoints(t *testing.T) {
+	input := fixture.Report.Copy()
+	ingressID := report.MakeIngressNodeID("ingress1234")
+	input.Ingress.AddNode(report.MakeNodeWith(ingressID, map[string]string{
+		kubernetes.Name:      "pong-ingress",
+		kubernetes.Namespace: fixture.KubernetesNamespace,
+	}).WithAdjacent(fixture.ServiceNodeID).WithAdjacent(report.MakeServiceNodeID("gone")))
+	service := input.Service.Nodes[fixture.ServiceNodeID]
+	input.Service.Nodes[fixture.ServiceNodeID] = service.WithSets(service.Sets.Add(kubernetes.ExternalIPs, report.MakeStringSet("1.2.3.4")))
+
+	loadBalancerID := render.MakePseudoNodeID(render.LoadBalancerID, "1.2.3.4")
+	have := render.PodServiceRenderer.Render(input).Nodes
+	for id, want := range map[string]report.IDList{
+		ingressID:                 report.MakeIDList(fixture.ServiceNodeID),
+		loadBalancerID:            report.MakeIDList(fixture.ServiceNodeID),
+		render.IncomingInternetID: report.MakeIDList(loadBalancerID),
+	} {
+		if !reflect.DeepEqual(want, have[id].Adjacency) {
+			t.Errorf("%s: %s", id, test.Diff(want, have[id].Adjacency))
+		}
+	}
+	if topology := have[ingressID].Topology; topology != report.Ingress {
+		t.Errorf("Expected ingress %s in the %s topology, got %q", ingressID, report.Ingress, topology)
+	}
+
+	// In the pods view, the ingress and load balancer lead to the pods of
+	// the service
+	have = render.EntryPoints(render.PodRenderer).Render(input).Nodes
+	pods := report.MakeIDList(fixture.ClientPodNodeID, fixture.ServerPodNodeID)
+	if !reflect.DeepEqual(pods, have[ingressID].Adjacency) {
+		t.Error(test.Diff(pods, have[ingressID].Adjacency))
+	}
+	if want := report.MakeIDList(loadBalancerID); !reflect.DeepEqual(want, have[render.IncomingInternetID].Adjacency) {
+		t.Error(test.Diff(want, have[render.IncomingInternetID].Adjacency))
+	}
+}
@@ -0,0 +1,52 @@
+package render
+
+import (
+	"bytes"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+// Label is the key of the Latest of rendered nodes labelled by the
+// topology they are rendered in, which their summaries then take.
+const Label = "render_label"
+
+// Relabel is a Transformer labelling the rendered nodes with a template.
+// The template is given the Latest of each node, its "id", and for group
+// nodes the "count" of their members, e.g. `{{.docker_label_team}} ({{.count}})`.
+// Pseudo nodes keep their labels.
+type Relabel struct {
+	Template *template.Template
+}
+
+// Transform implements Transformer
+func (l Relabel) Transform(nodes Nodes) Nodes {
+	var (
+		output = make(report.Nodes, len(nodes.Nodes))
+		now    = mtime.Now()
+		buf    bytes.Buffer
+	)
+	for id, n := range nodes.Nodes {
+		if n.Topology == Pseudo {
+			output[id] = n
+			continue
+		}
+		data := map[string]string{"id": n.ID}
+		n.Latest.ForEach(func(key string, _ time.Time, value string) {
+			data[key] = value
+		})
+		if topology, _, ok := ParseGroupNodeTopology(n.Topology); ok {
+			count, _ := n.Counters.Lookup(topology)
+			data["count"] = strconv.Itoa(count)
+		}
+		buf.Reset()
+		if err := l.Template.Execute(&buf, data); err == nil {
+			n = n.WithLatest(Label, now, buf.String())
+		}
+		output[id] = n
+	}
+	return Nodes{Nodes: output, Filtered: nodes.Filtered}
+}
@@ -0,0 +1,143 @@
+package layout
+
+import (
+	"math"
+
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	forceIterations         = 200
+	forceRelayoutIterations = 50
+	forceGravity            = 0.05
+)
+
+// Force lays out nodes with Fruchterman and Reingold's force-directed
+// algorithm: adjacent nodes attract each other, all nodes repel the nodes
+// near them. Nodes laid out before start from where they were, and the
+// others next to a neighbour which was, so that layouts change no more
+// than the topology does.
+func Force(nodes report.Nodes, previous Layout) Layout {
+	g := newGraph(nodes)
+	n := len(g.ids)
+	if n == 0 {
+		return Layout{}
+	}
+
+	var (
+		xs     = make([]float64, n)
+		ys     = make([]float64, n)
+		placed = make([]bool, n)
+		seeded = 0
+		radius = Spacing * math.Sqrt(float64(n))
+	)
+	for i, id := range g.ids {
+		if p, ok := previous[id]; ok {
+			xs[i], ys[i], placed[i] = p.X, p.Y, true
+			seeded++
+		}
+	}
+	for _, e := range g.edges {
+		for _, ij := range [][2]int{{e[0], e[1]}, {e[1], e[0]}} {
+			i, j := ij[0], ij[1]
+			if !placed[i] && placed[j] {
+				angle := 2 * math.Pi * hash(g.ids[i])
+				xs[i] = xs[j] + Spacing*math.Cos(angle)
+				ys[i] = ys[j] + Spacing*math.Sin(angle)
+				placed[i] = true
+			}
+		}
+	}
+	for i, id := range g.ids {
+		if !placed[i] {
+			angle := 2 * math.Pi * hash(id)
+			r := radius * math.Sqrt(hash(id+"/r"))
+			xs[i], ys[i] = r*math.Cos(angle), r*math.Sin(angle)
+		}
+	}
+
+	iterations, temperature := forceIterations, radius/10
+	if seeded*2 >= n {
+		iterations, temperature = forceRelayoutIterations, Spacing/2
+	}
+	var (
+		k       = Spacing
+		dxs     = make([]float64, n)
+		dys     = make([]float64, n)
+		cooling = temperature / float64(iterations)
+	)
+	for it := 0; it < iterations; it++ {
+		for i := range dxs {
+			dxs[i], dys[i] = 0, 0
+		}
+
+		// Repulsion only between nodes within 2k of each other, found
+		// through a grid of cells as big, to scale to large topologies.
+		grid := map[[2]int][]int{}
+		cell := func(i int) [2]int {
+			return [2]int{int(math.Floor(xs[i] / (2 * k))), int(math.Floor(ys[i] / (2 * k)))}
+		}
+		for i := range xs {
+			c := cell(i)
+			grid[c] = append(grid[c], i)
+		}
+		for i := range xs {
+			c := cell(i)
+			for cx := c[0] - 1; cx <= c[0]+1; cx++ {
+				for cy := c[1] - 1; cy <= c[1]+1; cy++ {
+					for _, j := range grid[[2]int{cx, cy}] {
+						if j == i {
+							continue
+						}
+						dx, dy := xs[i]-xs[j], ys[i]-ys[j]
+						d := math.Hypot(dx, dy)
+						if d > 2*k {
+							continue
+						}
+						if d < 0.01 {
+							// coincident nodes are pushed apart in a
+							// direction of their own
+							angle := 2 * math.Pi * hash(g.ids[i])
+							dx, dy, d = math.Cos(angle)*0.01, math.Sin(angle)*0.01, 0.01
+						}
+						f := k * k / d
+						dxs[i] += dx / d * f
+						dys[i] += dy / d * f
+					}
+				}
+			}
+		}
+
+		for _, e := range g.edges {
+			i, j := e[0], e[1]
+			dx, dy := xs[i]-xs[j], ys[i]-ys[j]
+			d := math.Hypot(dx, dy)
+			if d < 0.01 {
+				continue
+			}
+			f := d * d / k
+			dxs[i] -= dx / d * f
+			dys[i] -= dy / d * f
+			dxs[j] += dx / d * f
+			dys[j] += dy / d * f
+		}
+
+		// A little gravity keeps unconnected nodes from drifting off.
+		for i := range xs {
+			dxs[i] -= xs[i] * forceGravity
+			dys[i] -= ys[i] * forceGravity
+		}
+
+		for i := range xs {
+			d := math.Hypot(dxs[i], dys[i])
+			if d < 0.01 {
+				continue
+			}
+			step := math.Min(d, temperature)
+			xs[i] += dxs[i] / d * step
+			ys[i] += dys[i] / d * step
+		}
+		temperature -= cooling
+	}
+	return g.layout(xs, ys)
+}
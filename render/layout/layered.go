@@ -0,0 +1,136 @@
+package layout
+
+import (
+	"sort"
+
+	"github.com/weaveworks/scope/report"
+)
+
+const layeredSweeps = 4
+
+// Layered lays out nodes in layers, as Sugiyama et al. do: edges point
+// down, from each layer to those below, cycles aside, and nodes are
+// ordered within their layer to keep edges from crossing. Layered layouts
+// only depend on the topology, not on any previous layout.
+func Layered(nodes report.Nodes, _ Layout) Layout {
+	g := newGraph(nodes)
+	n := len(g.ids)
+	if n == 0 {
+		return Layout{}
+	}
+
+	// Edges closing cycles are reversed, as found depth first.
+	out := make([][]int, n)
+	for _, e := range g.edges {
+		out[e[0]] = append(out[e[0]], e[1])
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	var (
+		state = make([]int, n)
+		down  [][2]int
+		visit func(int)
+	)
+	visit = func(i int) {
+		state[i] = visiting
+		for _, j := range out[i] {
+			switch state[j] {
+			case unvisited:
+				down = append(down, [2]int{i, j})
+				visit(j)
+			case visiting:
+				down = append(down, [2]int{j, i})
+			default:
+				down = append(down, [2]int{i, j})
+			}
+		}
+		state[i] = visited
+	}
+	for i := range g.ids {
+		if state[i] == unvisited {
+			visit(i)
+		}
+	}
+
+	// Each node is a layer below its lowest predecessor.
+	var (
+		preds = make([][]int, n)
+		succs = make([][]int, n)
+		layer = make([]int, n)
+		done  = make([]bool, n)
+		rank  func(int) int
+	)
+	for _, e := range down {
+		succs[e[0]] = append(succs[e[0]], e[1])
+		preds[e[1]] = append(preds[e[1]], e[0])
+	}
+	rank = func(i int) int {
+		if !done[i] {
+			done[i] = true
+			for _, p := range preds[i] {
+				if l := rank(p) + 1; l > layer[i] {
+					layer[i] = l
+				}
+			}
+		}
+		return layer[i]
+	}
+	layers := [][]int{}
+	for i := range g.ids {
+		l := rank(i)
+		for len(layers) <= l {
+			layers = append(layers, nil)
+		}
+		layers[l] = append(layers[l], i)
+	}
+
+	// Nodes are ordered by the average position of their neighbours in the
+	// layer above, then below, and so on.
+	position := make([]float64, n)
+	for _, nodes := range layers {
+		for p, i := range nodes {
+			position[i] = float64(p)
+		}
+	}
+	reorder := func(nodes []int, neighbours [][]int) {
+		barycenter := make(map[int]float64, len(nodes))
+		for _, i := range nodes {
+			if len(neighbours[i]) == 0 {
+				barycenter[i] = position[i]
+				continue
+			}
+			sum := 0.0
+			for _, j := range neighbours[i] {
+				sum += position[j]
+			}
+			barycenter[i] = sum / float64(len(neighbours[i]))
+		}
+		sort.SliceStable(nodes, func(a, b int) bool {
+			return barycenter[nodes[a]] < barycenter[nodes[b]]
+		})
+		for p, i := range nodes {
+			position[i] = float64(p)
+		}
+	}
+	for sweep := 0; sweep < layeredSweeps; sweep++ {
+		for l := 1; l < len(layers); l++ {
+			reorder(layers[l], preds)
+		}
+		for l := len(layers) - 2; l >= 0; l-- {
+			reorder(layers[l], succs)
+		}
+	}
+
+	xs, ys := make([]float64, n), make([]float64, n)
+	for l, nodes := range layers {
+		offset := float64(len(nodes)-1) / 2
+		for p, i := range nodes {
+			xs[i] = (float64(p) - offset) * Spacing
+			ys[i] = float64(l) * Spacing
+		}
+	}
+	return g.layout(xs, ys)
+}
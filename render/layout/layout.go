@@ -0,0 +1,90 @@
+// Package layout computes where to draw the nodes of rendered topologies,
+// for clients which can't afford to do it themselves.
+package layout
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Spacing is the distance between adjacent nodes, in the units of Points.
+const Spacing = 100.0
+
+// Point is where a node is laid out.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Layout is where each node of a topology is laid out, by ID.
+type Layout map[string]Point
+
+// Algorithm lays out nodes, starting from where they were laid out
+// before, if anywhere.
+type Algorithm func(nodes report.Nodes, previous Layout) Layout
+
+// Algorithms are the layout algorithms, by name.
+var Algorithms = map[string]Algorithm{
+	"force":   Force,
+	"layered": Layered,
+}
+
+// Moved returns the positions in b of the nodes which aren't where they
+// are in a, or aren't in a.
+func Moved(a, b Layout) Layout {
+	moved := Layout{}
+	for id, p := range b {
+		if old, ok := a[id]; !ok || old != p {
+			moved[id] = p
+		}
+	}
+	return moved
+}
+
+// graph is the nodes to lay out, sorted, and their edges, by index,
+// without self-edges, nor edges to nodes not laid out.
+type graph struct {
+	ids   []string
+	index map[string]int
+	edges [][2]int
+}
+
+func newGraph(nodes report.Nodes) graph {
+	g := graph{index: map[string]int{}}
+	for id := range nodes {
+		g.ids = append(g.ids, id)
+	}
+	sort.Strings(g.ids)
+	for i, id := range g.ids {
+		g.index[id] = i
+	}
+	for i, id := range g.ids {
+		for _, dst := range nodes[id].Adjacency {
+			if j, ok := g.index[dst]; ok && j != i {
+				g.edges = append(g.edges, [2]int{i, j})
+			}
+		}
+	}
+	return g
+}
+
+func (g graph) layout(xs, ys []float64) Layout {
+	l := make(Layout, len(g.ids))
+	for i, id := range g.ids {
+		// Whole units are precise enough, and keep nodes from moving by
+		// nothing at all.
+		l[id] = Point{X: math.Floor(xs[i] + 0.5), Y: math.Floor(ys[i] + 0.5)}
+	}
+	return l
+}
+
+// hash places nodes deterministically, where there's nothing better to go
+// by.
+func hash(id string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return float64(h.Sum32()) / math.MaxUint32
+}
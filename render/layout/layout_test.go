@@ -0,0 +1,80 @@
+package layout_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/scope/render/layout"
+	"github.com/weaveworks/scope/report"
+)
+
+// a -> b -> c, a -> c and d on its own
+func testNodes() report.Nodes {
+	return report.Nodes{
+		"a": report.MakeNode("a").WithAdjacent("b").WithAdjacent("c"),
+		"b": report.MakeNode("b").WithAdjacent("c"),
+		"c": report.MakeNode("c"),
+		"d": report.MakeNode("d"),
+	}
+}
+
+func distance(a, b layout.Point) float64 {
+	return math.Hypot(a.X-b.X, a.Y-b.Y)
+}
+
+func TestLayered(t *testing.T) {
+	have := layout.Layered(testNodes(), nil)
+	if len(have) != 4 {
+		t.Fatalf("want 4 nodes, have %v", have)
+	}
+	if !(have["a"].Y < have["b"].Y && have["b"].Y < have["c"].Y) {
+		t.Errorf("want a, b and c in successive layers, have %v", have)
+	}
+	if have["d"].Y != have["a"].Y {
+		t.Errorf("want d in the first layer, have %v", have)
+	}
+
+	// Cycles don't get in the way
+	nodes := testNodes()
+	nodes["c"] = nodes["c"].WithAdjacent("a")
+	if have := layout.Layered(nodes, nil); len(have) != 4 {
+		t.Errorf("want 4 nodes, have %v", have)
+	}
+}
+
+func TestForce(t *testing.T) {
+	nodes := testNodes()
+	have := layout.Force(nodes, nil)
+	if len(have) != 4 {
+		t.Fatalf("want 4 nodes, have %v", have)
+	}
+	if !reflect.DeepEqual(have, layout.Force(nodes, nil)) {
+		t.Errorf("want the same layout of the same nodes")
+	}
+	for _, pair := range [][2]string{{"a", "b"}, {"a", "c"}, {"b", "c"}, {"a", "d"}} {
+		if d := distance(have[pair[0]], have[pair[1]]); d < layout.Spacing/4 {
+			t.Errorf("%s and %s are too close: %v", pair[0], pair[1], have)
+		}
+	}
+
+	// Adding a node leaves the others about where they were
+	nodes["e"] = report.MakeNode("e").WithAdjacent("d")
+	relaid := layout.Force(nodes, have)
+	for id, p := range have {
+		if d := distance(p, relaid[id]); d > layout.Spacing {
+			t.Errorf("%s moved by %f", id, d)
+		}
+	}
+	if d := distance(relaid["e"], relaid["d"]); d > 2*layout.Spacing {
+		t.Errorf("want e next to d, have %v", relaid)
+	}
+}
+
+func TestMoved(t *testing.T) {
+	a := layout.Layout{"a": {X: 1}, "b": {X: 2}, "c": {X: 3}}
+	b := layout.Layout{"a": {X: 1}, "b": {X: 4}, "d": {X: 5}}
+	if want, have := (layout.Layout{"b": {X: 4}, "d": {X: 5}}), layout.Moved(a, b); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
@@ -0,0 +1,197 @@
+package render
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/report"
+)
+
+// kubernetesContainerNameLabel is the docker label of containers with the
+// name of their container in their pod.
+const kubernetesContainerNameLabel = docker.LabelPrefix + "io.kubernetes.container.name"
+
+// MeshEdge is what the Envoy sidecar of a rendered pod, or container, sees
+// of its traffic to an adjacent one: how many requests it has proxied to the
+// services of the other, and whether it authenticates them with mutual TLS.
+type MeshEdge struct {
+	Requests int  `json:"requests"`
+	MTLS     bool `json:"mtls"`
+}
+
+// MeshTraffic returns what the Envoy sidecars of rendered pods or containers
+// make of their edges, by source and destination. Edges from nodes without a
+// sidecar are left out, as are those to nodes in none of the services the
+// sidecars report traffic to.
+func MeshTraffic(rpt report.Report, nodes report.Nodes) map[string]map[string]MeshEdge {
+	template, ok := kubernetes.TableTemplates[kubernetes.MeshPrefix]
+	if !ok {
+		return nil
+	}
+	var result map[string]map[string]MeshEdge
+	for id, n := range nodes {
+		src, ok := meshPod(rpt, n)
+		if !ok {
+			continue
+		}
+		if _, ok := src.Latest.Lookup(kubernetes.Sidecar); !ok {
+			continue
+		}
+		rows := src.ExtractMulticolumnTable(template)
+		for _, dstID := range n.Adjacency {
+			dst, ok := meshPod(rpt, nodes[dstID])
+			if !ok {
+				continue
+			}
+			edge, found := meshEdge(rows, serviceHosts(rpt, dst))
+			if !found {
+				continue
+			}
+			if result == nil {
+				result = map[string]map[string]MeshEdge{}
+			}
+			if result[id] == nil {
+				result[id] = map[string]MeshEdge{}
+			}
+			result[id][dstID] = edge
+		}
+	}
+	return result
+}
+
+// meshEdge sums the outbound traffic of a sidecar to any of the services
+// with the hosts given.
+func meshEdge(rows []report.Row, hosts []string) (MeshEdge, bool) {
+	var (
+		edge  MeshEdge
+		found bool
+	)
+	for _, row := range rows {
+		if row.Entries[kubernetes.MeshDirection] != kubernetes.MeshOutbound {
+			continue
+		}
+		service := row.Entries[kubernetes.MeshService]
+		for _, host := range hosts {
+			// Services are named by their fully qualified host,
+			// <name>.<namespace>.svc.<cluster domain>
+			if service != host && !strings.HasPrefix(service, host+".") {
+				continue
+			}
+			requests, _ := strconv.Atoi(row.Entries[kubernetes.MeshRequests])
+			edge.Requests += requests
+			edge.MTLS = edge.MTLS || row.Entries[kubernetes.MeshMTLS] == "true"
+			found = true
+			break
+		}
+	}
+	return edge, found
+}
+
+// serviceHosts returns the hosts of the services of a pod, as Envoy names
+// them, short of the cluster domain.
+func serviceHosts(rpt report.Report, pod report.Node) []string {
+	ids, _ := pod.Parents.Lookup(report.Service)
+	hosts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		service, ok := rpt.Service.Nodes[id]
+		if !ok {
+			continue
+		}
+		name, _ := service.Latest.Lookup(kubernetes.Name)
+		namespace, _ := service.Latest.Lookup(kubernetes.Namespace)
+		hosts = append(hosts, name+"."+namespace+".svc")
+	}
+	return hosts
+}
+
+// meshPod returns the pod, as reported, a rendered node is or is in.
+func meshPod(rpt report.Report, n report.Node) (report.Node, bool) {
+	switch n.Topology {
+	case report.Pod:
+		pod, ok := rpt.Pod.Nodes[n.ID]
+		return pod, ok
+	case report.Container:
+		if ids, found := n.Parents.Lookup(report.Pod); found && len(ids) > 0 {
+			pod, ok := rpt.Pod.Nodes[ids[0]]
+			return pod, ok
+		}
+	}
+	return report.Node{}, false
+}
+
+// MergeSidecars is a Renderer which merges the Envoy sidecars of pods into
+// the other containers of their pods, so that the traffic through them shows
+// as between the containers proxied, without the hop through the proxies.
+func MergeSidecars(r Renderer) Renderer {
+	return sidecarMerger{r}
+}
+
+type sidecarMerger struct {
+	Renderer
+}
+
+// Render implements Renderer
+func (s sidecarMerger) Render(rpt report.Report) Nodes {
+	containers := s.Renderer.Render(rpt)
+
+	sidecars := map[string]string{} // pod ID -> sidecar container ID
+	apps := map[string][]string{}   // pod ID -> other container IDs
+	for id, n := range containers.Nodes {
+		podIDs, ok := n.Parents.Lookup(report.Pod)
+		if !ok || len(podIDs) == 0 {
+			continue
+		}
+		if name, _ := n.Latest.Lookup(kubernetesContainerNameLabel); name == kubernetes.EnvoySidecar {
+			sidecars[podIDs[0]] = id
+		} else {
+			apps[podIDs[0]] = append(apps[podIDs[0]], id)
+		}
+	}
+	merged := map[string][]string{} // sidecar ID -> container IDs it merges into
+	for podID, sidecarID := range sidecars {
+		if ids := apps[podID]; len(ids) > 0 {
+			sort.Strings(ids)
+			merged[sidecarID] = ids
+		}
+	}
+	if len(merged) == 0 {
+		return containers
+	}
+
+	remap := func(ids report.IDList, self string) report.IDList {
+		result := report.MakeIDList()
+		for _, id := range ids {
+			targets, ok := merged[id]
+			if !ok {
+				targets = []string{id}
+			}
+			for _, target := range targets {
+				if target != self {
+					result = result.Add(target)
+				}
+			}
+		}
+		return result
+	}
+	output := make(report.Nodes, len(containers.Nodes))
+	for id, n := range containers.Nodes {
+		if _, ok := merged[id]; ok {
+			continue
+		}
+		n.Adjacency = remap(n.Adjacency, id)
+		output[id] = n
+	}
+	for sidecarID, ids := range merged {
+		sidecar := containers.Nodes[sidecarID]
+		for _, id := range ids {
+			n := output[id]
+			n.Adjacency = n.Adjacency.Merge(remap(sidecar.Adjacency, id))
+			n.Children = n.Children.Merge(sidecar.Children)
+			output[id] = n
+		}
+	}
+	return Nodes{Nodes: output, Filtered: containers.Filtered}
+}
@@ -0,0 +1,89 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+var (
+	productpageID = report.MakePodNodeID("productpage")
+	reviewsID     = report.MakePodNodeID("reviews")
+	reviewsSvcID  = report.MakeServiceNodeID("reviews-svc")
+)
+
+func meshReport() report.Report {
+	rpt := report.MakeReport()
+	rpt.Service.AddNode(report.MakeNodeWith(reviewsSvcID, map[string]string{
+		kubernetes.Name:      "reviews",
+		kubernetes.Namespace: "shop",
+	}))
+	rpt.Pod.AddNode(report.MakeNodeWith(productpageID, map[string]string{
+		kubernetes.Sidecar: kubernetes.EnvoySidecar,
+	}).AddPrefixMulticolumnTable(kubernetes.MeshPrefix, []report.Row{
+		{ID: "outbound|9080||reviews.shop.svc.cluster.local", Entries: map[string]string{
+			kubernetes.MeshDirection: kubernetes.MeshOutbound,
+			kubernetes.MeshService:   "reviews.shop.svc.cluster.local",
+			kubernetes.MeshRequests:  "42",
+			kubernetes.MeshMTLS:      "true",
+		}},
+		{ID: "outbound|9080||reviewsv2.shop.svc.cluster.local", Entries: map[string]string{
+			kubernetes.MeshDirection: kubernetes.MeshOutbound,
+			kubernetes.MeshService:   "reviewsv2.shop.svc.cluster.local",
+			kubernetes.MeshRequests:  "5",
+		}},
+	}))
+	rpt.Pod.AddNode(report.MakeNode(reviewsID).
+		WithParents(report.MakeSets().Add(report.Service, report.MakeStringSet(reviewsSvcID))))
+	return rpt
+}
+
+func TestMeshTraffic(t *testing.T) {
+	nodes := report.Nodes{
+		productpageID: report.MakeNode(productpageID).WithTopology(report.Pod).WithAdjacent(reviewsID).WithAdjacent(render.IncomingInternetID),
+		reviewsID:     report.MakeNode(reviewsID).WithTopology(report.Pod).WithAdjacent(productpageID),
+	}
+	have := render.MeshTraffic(meshReport(), nodes)
+	want := map[string]map[string]render.MeshEdge{
+		productpageID: {reviewsID: {Requests: 42, MTLS: true}},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("Expected mesh traffic %v, got %v", want, have)
+	}
+}
+
+func TestMergeSidecars(t *testing.T) {
+	container := func(id, pod, name string, adjacent ...string) report.Node {
+		n := report.MakeNodeWith(id, map[string]string{
+			docker.LabelPrefix + "io.kubernetes.container.name": name,
+		}).WithTopology(report.Container).
+			WithParents(report.MakeSets().Add(report.Pod, report.MakeStringSet(pod)))
+		for _, a := range adjacent {
+			n = n.WithAdjacent(a)
+		}
+		return n
+	}
+	nodes := report.Nodes{
+		"app1":   container("app1", productpageID, "productpage", "proxy1"),
+		"proxy1": container("proxy1", productpageID, kubernetes.EnvoySidecar, "proxy2"),
+		"proxy2": container("proxy2", reviewsID, kubernetes.EnvoySidecar, "app2"),
+		"app2":   container("app2", reviewsID, "reviews"),
+	}
+	have := render.MergeSidecars(mockRenderer{Nodes: nodes}).Render(report.MakeReport()).Nodes
+
+	if _, ok := have["proxy1"]; ok {
+		t.Errorf("Expected sidecars to be merged, got %v", have)
+	}
+	for id, want := range map[string]report.IDList{
+		"app1": report.MakeIDList("app2"),
+		"app2": report.MakeIDList(),
+	} {
+		if !reflect.DeepEqual(want, have[id].Adjacency) {
+			t.Errorf("Expected %s to be adjacent to %v, got %v", id, want, have[id].Adjacency)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// DockerNetworkRenderer is a Renderer which produces a renderable Docker
+// network graph, with the containers attached to each network as its
+// children.
+//
+// not memoised
+var DockerNetworkRenderer = ConditionalRenderer(renderDockerNetworkTopologies,
+	renderParents(
+		report.Container, []string{report.DockerNetwork}, UnmanagedID,
+		MakeFilter(
+			IsRunning,
+			ContainerWithImageNameRenderer,
+		),
+	),
+)
+
+func renderDockerNetworkTopologies(rpt report.Report) bool {
+	return len(rpt.DockerNetwork.Nodes) >= 1
+}
@@ -0,0 +1,48 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// NomadAllocationRenderer is a Renderer for HashiCorp Nomad allocations,
+// with the containers the docker driver runs for them, and the processes
+// the other drivers do, as their children.
+var NomadAllocationRenderer = Memoise(ConditionalRenderer(renderNomadTopologies,
+	MakeReduce(
+		renderParents(
+			report.Container, []string{report.NomadAllocation}, UnmanagedID,
+			MakeFilter(
+				IsRunning,
+				ContainerWithImageNameRenderer,
+			),
+		),
+		renderParents(
+			report.Process, []string{report.NomadAllocation}, "",
+			ProcessWithContainerNameRenderer,
+		),
+	),
+))
+
+// NomadTaskGroupRenderer is a Renderer for the task groups of Nomad jobs.
+//
+// not memoised
+var NomadTaskGroupRenderer = ConditionalRenderer(renderNomadTopologies,
+	renderParents(
+		report.NomadAllocation, []string{report.NomadTaskGroup}, "",
+		NomadAllocationRenderer,
+	),
+)
+
+// NomadJobRenderer is a Renderer for Nomad jobs.
+//
+// not memoised
+var NomadJobRenderer = ConditionalRenderer(renderNomadTopologies,
+	renderParents(
+		report.NomadTaskGroup, []string{report.NomadJob}, "",
+		NomadTaskGroupRenderer,
+	),
+)
+
+func renderNomadTopologies(rpt report.Report) bool {
+	return len(rpt.NomadJob.Nodes)+len(rpt.NomadTaskGroup.Nodes)+len(rpt.NomadAllocation.Nodes) >= 1
+}
@@ -26,6 +26,8 @@ func renderKubernetesTopologies(rpt report.Report) bool {
 		&rpt.DaemonSet,
 		&rpt.StatefulSet,
 		&rpt.CronJob,
+		&rpt.Job,
+		&rpt.CustomResource,
 	}
 	for _, t := range topologies {
 		if len(t.Nodes) > 0 {
@@ -67,14 +69,15 @@ var PodRenderer = Memoise(ConditionalRenderer(renderKubernetesTopologies,
 ))
 
 // PodServiceRenderer is a Renderer which produces a renderable kubernetes services
-// graph by merging the pods graph and the services topology.
+// graph by merging the pods graph and the services topology, with the entry
+// points into the cluster in front of the services.
 //
 // not memoised
 var PodServiceRenderer = ConditionalRenderer(renderKubernetesTopologies,
-	renderParents(
+	EntryPoints(renderParents(
 		report.Pod, []string{report.Service}, "",
 		PodRenderer,
-	),
+	)),
 )
 
 // KubeControllerRenderer is a Renderer which combines all the 'controller' topologies.
@@ -85,7 +88,19 @@ var PodServiceRenderer = ConditionalRenderer(renderKubernetesTopologies,
 // not memoised
 var KubeControllerRenderer = ConditionalRenderer(renderKubernetesTopologies,
 	renderParents(
-		report.Pod, []string{report.Deployment, report.DaemonSet, report.StatefulSet, report.CronJob}, UnmanagedID,
+		report.Pod, []string{report.Deployment, report.DaemonSet, report.StatefulSet, report.CronJob, report.Job}, UnmanagedID,
+		PodRenderer,
+	),
+)
+
+// CustomResourceRenderer is a Renderer which produces a renderable
+// kubernetes custom resources graph by merging the pods graph and the custom
+// resources topology. Pods no custom resource owns are dropped.
+//
+// not memoised
+var CustomResourceRenderer = ConditionalRenderer(renderKubernetesTopologies,
+	renderParents(
+		report.Pod, []string{report.CustomResource}, "",
 		PodRenderer,
 	),
 )
@@ -123,7 +138,9 @@ func MapPod2IP(m report.Node) []string {
 	if !ok {
 		return nil
 	}
-	return []string{report.MakeScopedEndpointNodeID("", ip, "")}
+	// The endpoints of pods in named clusters are scoped by them.
+	cluster, _ := m.Latest.Lookup(kubernetes.Cluster)
+	return []string{report.MakeScopedEndpointNodeID(cluster, ip, "")}
 }
 
 // Map2Parent returns a MapFunc which maps Nodes to some parent grouping.
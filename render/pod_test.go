@@ -7,6 +7,7 @@ import (
 	"github.com/weaveworks/scope/probe/kubernetes"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/render/expected"
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/fixture"
 	"github.com/weaveworks/scope/test/reflect"
 	"github.com/weaveworks/scope/test/utils"
@@ -65,3 +66,91 @@ func TestPodServiceFilterRenderer(t *testing.T) {
 		t.Error(test.Diff(want, have))
 	}
 }
+
+func TestCustomResourceRenderer(t *testing.T) {
+	input := fixture.Report.Copy()
+	kafkaID := report.MakeCustomResourceNodeID("kafka1234")
+	input.CustomResource.AddNode(report.MakeNodeWith(kafkaID, map[string]string{
+		kubernetes.Name:      "pongs",
+		kubernetes.Namespace: fixture.KubernetesNamespace,
+		kubernetes.NodeType:  "Kafka",
+	}))
+	server := input.Pod.Nodes[fixture.ServerPodNodeID]
+	input.Pod.Nodes[fixture.ServerPodNodeID] = server.WithParents(server.Parents.Add(report.CustomResource, report.MakeStringSet(kafkaID)))
+
+	have := render.CustomResourceRenderer.Render(input).Nodes
+	kafka, ok := have[kafkaID]
+	if !ok {
+		t.Fatalf("Expected custom resource %s to be rendered, got %v", kafkaID, have)
+	}
+	if count, _ := kafka.Counters.Lookup(report.Pod); count != 1 {
+		t.Errorf("Expected custom resource %s to have 1 pod, got %d", kafkaID, count)
+	}
+	if _, ok := kafka.Children.Lookup(fixture.ServerPodNodeID); !ok {
+		t.Errorf("Expected custom resource %s to have the server pod as a child", kafkaID)
+	}
+	if _, ok := have[fixture.ClientPodNodeID]; ok {
+		t.Errorf("Expected the client pod, which no custom resource owns, to be dropped")
+	}
+	if !have[render.IncomingInternetID].Adjacency.Contains(kafkaID) {
+		t.Errorf("Expected connections to the server pod to end at custom resource %s, got %v", kafkaID, have)
+	}
+}
+
+func TestKubeControllerRendererJobs(t *testing.T) {
+	input := fixture.Report.Copy()
+	jobID := report.MakeJobNodeID("job1234")
+	input.Job.AddNode(report.MakeNodeWith(jobID, map[string]string{
+		kubernetes.Name:      "pong-job",
+		kubernetes.Namespace: fixture.KubernetesNamespace,
+		kubernetes.NodeType:  "Job",
+	}))
+	server := input.Pod.Nodes[fixture.ServerPodNodeID]
+	input.Pod.Nodes[fixture.ServerPodNodeID] = server.WithParents(server.Parents.Add(report.Job, report.MakeStringSet(jobID)))
+
+	have := render.KubeControllerRenderer.Render(input).Nodes
+	job, ok := have[jobID]
+	if !ok {
+		t.Fatalf("Expected job %s to be rendered, got %v", jobID, have)
+	}
+	if count, _ := job.Counters.Lookup(report.Pod); count != 1 {
+		t.Errorf("Expected job %s to have 1 pod, got %d", jobID, count)
+	}
+	if _, ok := job.Children.Lookup(fixture.ServerPodNodeID); !ok {
+		t.Errorf("Expected job %s to have the server pod as a child", jobID)
+	}
+}
+
+func TestPodRendererClusters(t *testing.T) {
+	// Two clusters, whose client pods both connect to the server pods of
+	// their own cluster, at the same IPs.
+	input := report.MakeReport()
+	podID := func(cluster, name string) string { return report.MakePodNodeID(cluster + "-" + name) }
+	for _, cluster := range []string{"east", "west"} {
+		for name, ip := range map[string]string{"client": "10.0.0.2", "server": "10.0.0.1"} {
+			input.Pod.AddNode(report.MakeNodeWith(podID(cluster, name), map[string]string{
+				kubernetes.Name:      name,
+				kubernetes.Namespace: "default",
+				kubernetes.IP:        ip,
+				kubernetes.Cluster:   cluster,
+			}).WithTopology(report.Pod))
+		}
+		host := map[string]string{report.HostNodeID: report.MakeHostNodeID(cluster + "-host")}
+		serverID := report.MakeScopedEndpointNodeID(cluster, "10.0.0.1", "80")
+		input.Endpoint.AddNode(report.MakeNodeWith(serverID, host).WithTopology(report.Endpoint))
+		input.Endpoint.AddNode(report.MakeNodeWith(report.MakeScopedEndpointNodeID(cluster, "10.0.0.2", "54001"), host).
+			WithTopology(report.Endpoint).WithAdjacent(serverID))
+	}
+
+	have := render.PodRenderer.Render(input).Nodes
+	for _, cluster := range []string{"east", "west"} {
+		client, ok := have[podID(cluster, "client")]
+		if !ok {
+			t.Fatalf("Expected client pod of cluster %s, got %v", cluster, have)
+		}
+		want := report.MakeIDList(podID(cluster, "server"))
+		if !reflect.DeepEqual(want, client.Adjacency) {
+			t.Errorf("Expected client pod of cluster %s to connect to %v, got %v", cluster, want, client.Adjacency)
+		}
+	}
+}
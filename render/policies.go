@@ -0,0 +1,134 @@
+package render
+
+import (
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/report"
+)
+
+// NetworkPolicies returns the Kubernetes network policies of a report, by
+// namespace, nil if there are none.
+func NetworkPolicies(rpt report.Report) map[string]kubernetes.Policies {
+	var result map[string]kubernetes.Policies
+	for id, n := range rpt.Namespace.Nodes {
+		encoded, ok := n.Latest.Lookup(kubernetes.NetworkPolicies)
+		if !ok {
+			continue
+		}
+		name, _ := n.Latest.Lookup(kubernetes.Name)
+		policies, err := kubernetes.ParsePolicies(encoded)
+		if err != nil {
+			log.Warnf("Invalid network policies of namespace %s: %v", id, err)
+			continue
+		}
+		if result == nil {
+			result = map[string]kubernetes.Policies{}
+		}
+		result[name] = policies
+	}
+	return result
+}
+
+// NetworkPolicyVerdicts returns what the network policies of a report make
+// of the edges of rendered pods or containers, by source and destination:
+// whether they allow the traffic, deny it or don't cover it at all. Edges
+// between nodes neither of which is a pod are left out, as are all edges
+// when there are no network policies.
+func NetworkPolicyVerdicts(rpt report.Report, nodes report.Nodes) map[string]map[string]string {
+	policies := NetworkPolicies(rpt)
+	if len(policies) == 0 {
+		return nil
+	}
+	namespaceLabels := map[string]map[string]string{}
+	for _, n := range rpt.Namespace.Nodes {
+		if name, ok := n.Latest.Lookup(kubernetes.Name); ok {
+			namespaceLabels[name] = kubernetes.NodeLabels(n)
+		}
+	}
+	peers := map[string]kubernetes.PolicyPeer{}
+	peer := func(id string) kubernetes.PolicyPeer {
+		p, ok := peers[id]
+		if !ok {
+			p = policyPeer(rpt, nodes[id], namespaceLabels)
+			peers[id] = p
+		}
+		return p
+	}
+
+	result := map[string]map[string]string{}
+	for id, n := range nodes {
+		for _, dstID := range n.Adjacency {
+			src, dst := peer(id), peer(dstID)
+			if src.Namespace == "" && dst.Namespace == "" {
+				continue
+			}
+			if result[id] == nil {
+				result[id] = map[string]string{}
+			}
+			result[id][dstID] = kubernetes.PolicyVerdict(policies, src, dst)
+		}
+	}
+	return result
+}
+
+// policyPeer returns the pod a rendered node is, or is in, as network
+// policies see it.
+func policyPeer(rpt report.Report, n report.Node, namespaceLabels map[string]map[string]string) kubernetes.PolicyPeer {
+	pod, ok := report.Node{}, false
+	switch n.Topology {
+	case report.Pod:
+		if pod, ok = rpt.Pod.Nodes[n.ID]; !ok {
+			pod, ok = n, true
+		}
+	case report.Container:
+		if ids, found := n.Parents.Lookup(report.Pod); found && len(ids) > 0 {
+			pod, ok = rpt.Pod.Nodes[ids[0]]
+		}
+	}
+	if !ok {
+		return kubernetes.PolicyPeer{}
+	}
+	namespace, _ := pod.Latest.Lookup(kubernetes.Namespace)
+	return kubernetes.PolicyPeer{
+		Namespace:       namespace,
+		Labels:          kubernetes.NodeLabels(pod),
+		NamespaceLabels: namespaceLabels[namespace],
+	}
+}
+
+// UncoveredTraffic is a Transformer keeping only the edges of rendered pods
+// or containers no network policy covers, and the nodes they are between.
+type UncoveredTraffic struct {
+	Report report.Report
+}
+
+// Transform implements Transformer
+func (u UncoveredTraffic) Transform(nodes Nodes) Nodes {
+	verdicts := NetworkPolicyVerdicts(u.Report, nodes.Nodes)
+	connected := map[string]struct{}{}
+	adjacencies := map[string]report.IDList{}
+	for id, dsts := range verdicts {
+		for dstID, verdict := range dsts {
+			if verdict != kubernetes.PolicyUnspecified {
+				continue
+			}
+			adjacencies[id] = adjacencies[id].Add(dstID)
+			connected[id], connected[dstID] = struct{}{}, struct{}{}
+		}
+	}
+	output := report.Nodes{}
+	filtered := nodes.Filtered
+	for id, node := range nodes.Nodes {
+		if _, ok := connected[id]; !ok {
+			filtered++
+			continue
+		}
+		node.Adjacency = adjacencies[id]
+		if node.Adjacency == nil {
+			node.Adjacency = report.MakeIDList()
+		}
+		output[id] = node
+	}
+	return Nodes{Nodes: output, Filtered: filtered}
+}
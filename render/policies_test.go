@@ -0,0 +1,102 @@
+package render_test
+
+import (
+	"testing"
+
+	apinetworkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func policyReport() (report.Report, report.Nodes) {
+	var (
+		frontendID  = report.MakePodNodeID("frontend")
+		backendID   = report.MakePodNodeID("backend")
+		adminID     = report.MakePodNodeID("admin")
+		containerID = report.MakeContainerNodeID("c1")
+	)
+	backend := metav1.LabelSelector{MatchLabels: map[string]string{"app": "backend"}}
+	frontend := metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}}
+	policies := kubernetes.Policies{
+		{Name: "backend", Spec: apinetworkingv1.NetworkPolicySpec{
+			PodSelector: backend,
+			Ingress: []apinetworkingv1.NetworkPolicyIngressRule{
+				{From: []apinetworkingv1.NetworkPolicyPeer{{PodSelector: &frontend}}},
+			},
+		}},
+	}
+
+	rpt := report.MakeReport()
+	rpt.Namespace.AddNode(report.MakeNodeWith(report.MakeNamespaceNodeID("shop"), map[string]string{
+		kubernetes.Name:            "shop",
+		kubernetes.NetworkPolicies: policies.String(),
+	}))
+	for id, app := range map[string]string{frontendID: "frontend", backendID: "backend", adminID: "admin"} {
+		rpt.Pod.AddNode(report.MakeNodeWith(id, map[string]string{
+			kubernetes.Namespace:           "shop",
+			kubernetes.LabelPrefix + "app": app,
+		}))
+	}
+
+	nodes := report.Nodes{
+		frontendID: report.MakeNode(frontendID).WithTopology(report.Pod).WithAdjacent(backendID).WithAdjacent(render.IncomingInternetID),
+		adminID:    report.MakeNode(adminID).WithTopology(report.Pod).WithAdjacent(backendID),
+		backendID:  report.MakeNode(backendID).WithTopology(report.Pod),
+		containerID: report.MakeNode(containerID).WithTopology(report.Container).
+			WithParents(report.MakeSets().Add(report.Pod, report.MakeStringSet(adminID))).
+			WithAdjacent(frontendID),
+		render.IncomingInternetID: report.MakeNode(render.IncomingInternetID).WithTopology(render.Pseudo).WithAdjacent(backendID),
+	}
+	return rpt, nodes
+}
+
+func TestNetworkPolicyVerdicts(t *testing.T) {
+	rpt, nodes := policyReport()
+	want := map[string]map[string]string{
+		report.MakePodNodeID("frontend"): {
+			report.MakePodNodeID("backend"): kubernetes.PolicyAllowed,
+			render.IncomingInternetID:       kubernetes.PolicyUnspecified,
+		},
+		report.MakePodNodeID("admin"): {
+			report.MakePodNodeID("backend"): kubernetes.PolicyDenied,
+		},
+		report.MakeContainerNodeID("c1"): {
+			report.MakePodNodeID("frontend"): kubernetes.PolicyUnspecified,
+		},
+		render.IncomingInternetID: {
+			report.MakePodNodeID("backend"): kubernetes.PolicyDenied,
+		},
+	}
+	if have := render.NetworkPolicyVerdicts(rpt, nodes); !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+
+	if have := render.NetworkPolicyVerdicts(report.MakeReport(), nodes); have != nil {
+		t.Errorf("Expected no verdicts without network policies, got %v", have)
+	}
+}
+
+func TestUncoveredTraffic(t *testing.T) {
+	rpt, nodes := policyReport()
+	have := render.UncoveredTraffic{Report: rpt}.Transform(render.Nodes{Nodes: nodes})
+	want := map[string]report.IDList{
+		report.MakePodNodeID("frontend"): report.MakeIDList(render.IncomingInternetID),
+		report.MakeContainerNodeID("c1"): report.MakeIDList(report.MakePodNodeID("frontend")),
+		render.IncomingInternetID:        report.MakeIDList(),
+	}
+	haveAdjacencies := map[string]report.IDList{}
+	for id, n := range have.Nodes {
+		haveAdjacencies[id] = n.Adjacency
+	}
+	if !reflect.DeepEqual(want, haveAdjacencies) {
+		t.Error(test.Diff(want, haveAdjacencies))
+	}
+	if have.Filtered != 2 {
+		t.Errorf("Expected 2 nodes filtered out, got %d", have.Filtered)
+	}
+}
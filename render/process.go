@@ -20,10 +20,10 @@ func renderProcesses(rpt report.Report) bool {
 }
 
 // ProcessRenderer is a Renderer which produces a renderable process
-// graph by merging the endpoint graph and the process topology. It
-// also colors connected nodes, so we can apply a filter to show/hide
-// unconnected nodes depending on user choice.
-var ProcessRenderer = Memoise(ColorConnected(endpoints2Processes{}))
+// graph by merging the endpoint and unix socket graphs and the process
+// topology. It also colors connected nodes, so we can apply a filter to
+// show/hide unconnected nodes depending on user choice.
+var ProcessRenderer = Memoise(ColorConnected(MakeReduce(endpoints2Processes{}, unixSockets2Processes{})))
 
 // processWithContainerNameRenderer is a Renderer which produces a process
 // graph enriched with container names where appropriate
@@ -64,6 +64,13 @@ var ProcessWithContainerNameRenderer = processWithContainerNameRenderer{ProcessR
 // not memoised
 var ProcessNameRenderer = CustomRenderer{RenderFunc: processes2Names, Renderer: ProcessRenderer}
 
+// ProcessTreeRenderer is a Renderer which produces a renderable process
+// tree graph, collapsing each process with all its descendants, as found
+// through their parent PIDs, into a node of its tree.
+//
+// not memoised
+var ProcessTreeRenderer = CustomRenderer{RenderFunc: processes2Trees, Renderer: ProcessWithContainerNameRenderer}
+
 // endpoints2Processes joins the endpoint topology to the process
 // topology, matching on hostID and pid.
 type endpoints2Processes struct {
@@ -91,6 +98,31 @@ func (e endpoints2Processes) Render(rpt report.Report) Nodes {
 		}, report.Process).Render(rpt)
 }
 
+// unixSockets2Processes joins the unix socket topology to the process
+// topology, matching on hostID and pid.
+type unixSockets2Processes struct {
+}
+
+func (e unixSockets2Processes) Render(rpt report.Report) Nodes {
+	if len(rpt.Process.Nodes) == 0 || len(rpt.UnixSocket.Nodes) == 0 {
+		return Nodes{}
+	}
+	sockets := SelectUnixSocket.Render(rpt)
+	ret := newJoinResults(SelectProcess.Render(rpt).Nodes)
+	for _, n := range sockets.Nodes {
+		pid, ok := n.Latest.Lookup(process.PID)
+		if !ok {
+			continue
+		}
+		hostID := report.ExtractHostID(n)
+		if hostID == "" {
+			continue
+		}
+		ret.addChild(n, report.MakeProcessNodeID(hostID, pid), report.Process)
+	}
+	return ret.result(sockets)
+}
+
 // When there is more than one connection originating from a source
 // endpoint, we cannot be sure that its pid is associated with all of
 // them, since the source endpoint may have been re-used by a
@@ -120,6 +152,51 @@ func hasMoreThanOneConnection(n report.Node, endpoints report.Nodes) bool {
 
 var processNameTopology = MakeGroupNodeTopology(report.Process, process.Name)
 
+// ProcessTreeTopology is the topology of the nodes of process trees.
+var ProcessTreeTopology = MakeGroupNodeTopology(report.Process, process.PPID)
+
+// processes2Trees maps process Nodes to Nodes for the tree of each service
+// supervisor, or any other process started by init, on each host. The node
+// of a tree has the ID and metadata of its root process.
+func processes2Trees(processes Nodes) Nodes {
+	ret := newJoinResults(nil)
+
+	for _, n := range processes.Nodes {
+		if n.Topology == Pseudo {
+			ret.passThrough(n)
+		} else if root, ok := processTreeRoot(n, processes.Nodes); ok {
+			ret.addChildAndChildren(n, root.ID, ProcessTreeTopology)
+			tree := ret.nodes[root.ID]
+			tree.Latest = root.Latest
+			ret.nodes[root.ID] = tree
+		}
+	}
+	return ret.result(processes)
+}
+
+// processTreeRoot returns the ancestor of the process whose parent is init,
+// or isn't reported, as when it runs in another PID namespace. Init, and
+// the kernel threads' parent, are the roots of their own trees.
+func processTreeRoot(n report.Node, processes report.Nodes) (report.Node, bool) {
+	hostID, _, ok := report.ParseProcessNodeID(n.ID)
+	if !ok {
+		return n, false
+	}
+	// At most one step per process, in case the PIDs were reused into a loop
+	for i := 0; i < len(processes); i++ {
+		ppid, ok := n.Latest.Lookup(process.PPID)
+		if !ok || ppid == "1" {
+			break
+		}
+		parent, ok := processes[report.MakeProcessNodeID(hostID, ppid)]
+		if !ok {
+			break
+		}
+		n = parent
+	}
+	return n, true
+}
+
 // processes2Names maps process Nodes to Nodes for each process name.
 func processes2Names(processes Nodes) Nodes {
 	ret := newJoinResults(nil)
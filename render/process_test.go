@@ -4,8 +4,10 @@ import (
 	"testing"
 
 	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/render/expected"
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/fixture"
 	"github.com/weaveworks/scope/test/reflect"
 	"github.com/weaveworks/scope/test/utils"
@@ -27,6 +29,31 @@ func TestProcessRenderer(t *testing.T) {
 	}
 }
 
+func TestProcessRendererUnixSockets(t *testing.T) {
+	var (
+		rpt      = fixture.Report.Copy()
+		serverID = report.MakeUnixSocketNodeID(fixture.ServerHostID, "23456")
+		clientID = report.MakeUnixSocketNodeID(fixture.ServerHostID, "23455")
+	)
+	rpt.UnixSocket = rpt.UnixSocket.
+		AddNode(report.MakeNodeWith(serverID, map[string]string{
+			report.PID:        fixture.ServerPID,
+			report.HostNodeID: fixture.ServerHostNodeID,
+		})).
+		AddNode(report.MakeNodeWith(clientID, map[string]string{
+			report.PID:        fixture.NonContainerPID,
+			report.HostNodeID: fixture.ServerHostNodeID,
+		}).WithAdjacent(serverID))
+
+	have := render.ProcessRenderer.Render(rpt).Nodes[fixture.NonContainerProcessNodeID]
+	if !have.Adjacency.Contains(fixture.ServerProcessNodeID) {
+		t.Errorf("expected an edge to the server process, have %v", have.Adjacency)
+	}
+	if _, ok := have.Children.Lookup(clientID); !ok {
+		t.Errorf("expected the client socket in the children of its process")
+	}
+}
+
 func TestProcessNameRenderer(t *testing.T) {
 	have := utils.Prune(render.ProcessNameRenderer.Render(fixture.Report).Nodes)
 	want := utils.Prune(expected.RenderedProcessNames)
@@ -34,3 +61,44 @@ func TestProcessNameRenderer(t *testing.T) {
 		t.Error(test.Diff(want, have))
 	}
 }
+
+func TestProcessTreeRenderer(t *testing.T) {
+	rpt := report.MakeReport()
+	for pid, ppid := range map[string]string{
+		"1":   "",
+		"100": "1",   // a supervisor
+		"101": "100", // its workers
+		"102": "100",
+		"103": "101",
+		"200": "1",
+		"301": "300", // in another PID namespace, its parent isn't reported
+	} {
+		latests := map[string]string{process.PID: pid, process.Name: "p" + pid}
+		if ppid != "" {
+			latests[process.PPID] = ppid
+		}
+		rpt.Process.AddNode(report.MakeNodeWith(report.MakeProcessNodeID("host", pid), latests).WithTopology(report.Process))
+	}
+
+	have := render.ProcessTreeRenderer.Render(rpt).Nodes
+	want := map[string]int{"1": 1, "100": 4, "200": 1, "301": 1}
+	if len(have) != len(want) {
+		t.Errorf("Expected %d trees, got %d", len(want), len(have))
+	}
+	for pid, processes := range want {
+		tree, ok := have[report.MakeProcessNodeID("host", pid)]
+		if !ok {
+			t.Errorf("Expected a tree rooted at %s", pid)
+			continue
+		}
+		if tree.Topology != render.ProcessTreeTopology {
+			t.Errorf("Expected the tree of %s in topology %q, got %q", pid, render.ProcessTreeTopology, tree.Topology)
+		}
+		if count, _ := tree.Counters.Lookup(report.Process); count != processes {
+			t.Errorf("Expected %d processes in the tree of %s, got %d", processes, pid, count)
+		}
+		if name, _ := tree.Latest.Lookup(process.Name); name != "p"+pid {
+			t.Errorf("Expected the tree of %s named after it, got %q", pid, name)
+		}
+	}
+}
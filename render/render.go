@@ -1,6 +1,9 @@
 package render
 
 import (
+	"strings"
+	"time"
+
 	"github.com/weaveworks/scope/report"
 )
 
@@ -139,6 +142,17 @@ func propagateLatest(key string, from, to report.Node) report.Node {
 	return to
 }
 
+// propagateLatestPrefix copies all the latest entries with the given
+// prefix, e.g. the rows of a table, from one node to another.
+func propagateLatestPrefix(prefix string, from, to report.Node) report.Node {
+	from.Latest.ForEach(func(key string, timestamp time.Time, value string) {
+		if strings.HasPrefix(key, prefix) {
+			to.Latest = to.Latest.Set(key, timestamp, value)
+		}
+	})
+	return to
+}
+
 // Condition is a predecate over the entire report that can evaluate to true or false.
 type Condition func(report.Report) bool
 
@@ -192,7 +206,7 @@ func (ret *joinResults) addUnmappedChild(m report.Node, id string, topology stri
 		result = report.MakeNode(id).WithTopology(topology)
 	}
 	result.Children = result.Children.Add(m)
-	if m.Topology != report.Endpoint { // optimisation: we never look at endpoint counts
+	if m.Topology != report.Endpoint && m.Topology != report.UnixSocket { // optimisation: we never look at endpoint or unix socket counts
 		result.Counters = result.Counters.Add(m.Topology, 1)
 	}
 	ret.nodes[id] = result
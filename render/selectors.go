@@ -28,8 +28,20 @@ var (
 	SelectDaemonSet      = TopologySelector(report.DaemonSet)
 	SelectStatefulSet    = TopologySelector(report.StatefulSet)
 	SelectCronJob        = TopologySelector(report.CronJob)
+	SelectJob            = TopologySelector(report.Job)
+	SelectCustomResource = TopologySelector(report.CustomResource)
 	SelectECSTask        = TopologySelector(report.ECSTask)
 	SelectECSService     = TopologySelector(report.ECSService)
 	SelectSwarmService   = TopologySelector(report.SwarmService)
+	SelectSwarmTask      = TopologySelector(report.SwarmTask)
+	SelectComposeService = TopologySelector(report.ComposeService)
+	SelectDockerNetwork  = TopologySelector(report.DockerNetwork)
+	SelectDockerVolume   = TopologySelector(report.DockerVolume)
+	SelectSystemdUnit    = TopologySelector(report.SystemdUnit)
 	SelectOverlay        = TopologySelector(report.Overlay)
+	SelectUnixSocket     = TopologySelector(report.UnixSocket)
+
+	SelectNomadJob        = TopologySelector(report.NomadJob)
+	SelectNomadTaskGroup  = TopologySelector(report.NomadTaskGroup)
+	SelectNomadAllocation = TopologySelector(report.NomadAllocation)
 )
@@ -0,0 +1,89 @@
+package snapshot
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"strings"
+)
+
+var htmlTemplate = template.Must(template.New("snapshot").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; color: #333; margin: 2em; }
+table { border-collapse: collapse; margin-top: 2em; }
+th, td { text-align: left; padding: 0.3em 1em; border-bottom: 1px solid #ddd; }
+.minor { color: #777; }
+</style>
+</head>
+<body>
+{{.SVG}}
+<table>
+<tr><th>Node</th><th>Details</th><th>Connected to</th><th>Annotation</th></tr>
+{{range .Nodes}}<tr>
+<td title="{{.ID}}">{{.Label}}</td>
+<td class="minor">{{.LabelMinor}}</td>
+<td>{{.Adjacent}}</td>
+<td>{{.Annotation}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type htmlNode struct {
+	ID, Label, LabelMinor, Adjacent, Annotation string
+}
+
+// WriteHTML writes a snapshot as an HTML page, with the topology drawn as
+// SVG and the nodes listed below it, needing nothing else to be viewed.
+func WriteHTML(w io.Writer, s Snapshot) error {
+	d := s.draw(0)
+	var svg bytes.Buffer
+	if err := d.writeSVG(&svg); err != nil {
+		return err
+	}
+
+	labels := map[string]string{}
+	for _, n := range d.nodes {
+		labels[n.ID] = n.Label
+	}
+	var nodes []htmlNode
+	for _, n := range d.nodes {
+		if n.Pseudo {
+			continue
+		}
+		var adjacent []string
+		for _, id := range n.Adjacency {
+			if label, ok := labels[id]; ok {
+				adjacent = append(adjacent, label)
+			}
+		}
+		var annotation string
+		if a := n.Annotation; a != nil {
+			annotation = strings.TrimSpace(a.Note + " " + strings.Join(a.Tags, " "))
+			if a.Pinned {
+				annotation = strings.TrimSpace("pinned " + annotation)
+			}
+		}
+		nodes = append(nodes, htmlNode{
+			ID:         n.ID,
+			Label:      n.Label,
+			LabelMinor: n.LabelMinor,
+			Adjacent:   strings.Join(adjacent, ", "),
+			Annotation: annotation,
+		})
+	}
+	return htmlTemplate.Execute(w, struct {
+		Title string
+		SVG   template.HTML
+		Nodes []htmlNode
+	}{
+		Title: d.title,
+		SVG:   template.HTML(svg.String()),
+		Nodes: nodes,
+	})
+}
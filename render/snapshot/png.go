@@ -0,0 +1,104 @@
+package snapshot
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// maxPNGSize is the most pixels PNG snapshots are wide or high; bigger
+// topologies are scaled down.
+const maxPNGSize = 4096
+
+// WritePNG draws a snapshot as a PNG image.
+func WritePNG(w io.Writer, s Snapshot) error {
+	d := s.draw(maxPNGSize)
+	img := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(d.width)), int(math.Ceil(d.height))))
+	draw.Draw(img, img.Bounds(), image.White, image.ZP, draw.Src)
+
+	for _, e := range d.edges {
+		drawLine(img, e[0].x, e[0].y, e[1].x, e[1].y, edgeColor)
+	}
+	radius := nodeRadius * d.scale
+	for _, n := range d.nodes {
+		if k := sides(n.Shape); k > 0 {
+			fillPolygon(img, polygon(k, n.x, n.y, radius), n.color())
+		} else {
+			fillCircle(img, n.x, n.y, radius, n.color())
+		}
+	}
+
+	// Labels are left out once too small to read.
+	drawText(img, margin/2*d.scale, titleSize*d.scale, d.title)
+	if d.scale >= 0.5 {
+		for _, n := range d.nodes {
+			drawText(img, n.x-float64(len(n.Label))*3.5, n.y+radius+14, n.Label)
+		}
+	}
+	return png.Encode(w, img)
+}
+
+func drawLine(img *image.RGBA, x1, y1, x2, y2 float64, c color.RGBA) {
+	steps := int(math.Ceil(math.Max(math.Abs(x2-x1), math.Abs(y2-y1))))
+	for i := 0; i <= steps; i++ {
+		t := 0.0
+		if steps > 0 {
+			t = float64(i) / float64(steps)
+		}
+		img.SetRGBA(int(x1+(x2-x1)*t), int(y1+(y2-y1)*t), c)
+	}
+}
+
+func fillCircle(img *image.RGBA, x, y, r float64, c color.RGBA) {
+	for py := int(y - r); py <= int(y+r); py++ {
+		for px := int(x - r); px <= int(x+r); px++ {
+			if distance(float64(px), float64(py), x, y) <= r {
+				img.SetRGBA(px, py, c)
+			}
+		}
+	}
+}
+
+func fillPolygon(img *image.RGBA, points [][2]float64, c color.RGBA) {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range points {
+		minX, minY = math.Min(minX, p[0]), math.Min(minY, p[1])
+		maxX, maxY = math.Max(maxX, p[0]), math.Max(maxY, p[1])
+	}
+	for py := int(minY); py <= int(maxY); py++ {
+		for px := int(minX); px <= int(maxX); px++ {
+			if inPolygon(points, float64(px), float64(py)) {
+				img.SetRGBA(px, py, c)
+			}
+		}
+	}
+}
+
+// inPolygon tells whether x, y is inside a polygon, by the even-odd rule.
+func inPolygon(points [][2]float64, x, y float64) bool {
+	inside := false
+	for i, j := 0, len(points)-1; i < len(points); j, i = i, i+1 {
+		a, b := points[i], points[j]
+		if (a[1] > y) != (b[1] > y) && x < (b[0]-a[0])*(y-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func drawText(img *image.RGBA, x, y float64, text string) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(textColor),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(int(x), int(y)),
+	}
+	d.DrawString(text)
+}
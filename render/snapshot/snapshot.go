@@ -0,0 +1,158 @@
+// Package snapshot draws rendered topologies as self-contained SVG, PNG
+// or HTML documents, e.g. to be attached to incident reports.
+package snapshot
+
+import (
+	"hash/fnv"
+	"image/color"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/render/layout"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	nodeRadius = 20.0
+	margin     = 80.0
+	titleSize  = 40.0
+)
+
+// Snapshot is a topology to draw, as laid out.
+type Snapshot struct {
+	Title  string
+	Time   time.Time
+	Nodes  detailed.NodeSummaries
+	Layout layout.Layout
+}
+
+// palette colours nodes by their label, so that the nodes of the same
+// image, say, look the same.
+var palette = []color.RGBA{
+	{0x3f, 0x8f, 0xc6, 0xff},
+	{0xe0, 0x7b, 0x39, 0xff},
+	{0x4c, 0xae, 0x71, 0xff},
+	{0xc4, 0x4f, 0x5a, 0xff},
+	{0x8e, 0x6c, 0xc1, 0xff},
+	{0x3f, 0xae, 0xa8, 0xff},
+	{0xc9, 0xa2, 0x27, 0xff},
+	{0x7f, 0x8c, 0x8d, 0xff},
+}
+
+var (
+	pseudoColor = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+	edgeColor   = color.RGBA{0xa0, 0xa7, 0xb0, 0xff}
+	textColor   = color.RGBA{0x33, 0x33, 0x33, 0xff}
+)
+
+type placedNode struct {
+	detailed.NodeSummary
+	x, y float64
+}
+
+func (n placedNode) color() color.RGBA {
+	if n.Pseudo {
+		return pseudoColor
+	}
+	h := fnv.New32a()
+	h.Write([]byte(n.Label))
+	return palette[h.Sum32()%uint32(len(palette))]
+}
+
+// drawing is a snapshot placed on a canvas of width by height, scaled so
+// that neither is bigger than max, if given.
+type drawing struct {
+	title         string
+	width, height float64
+	scale         float64
+	nodes         []placedNode
+	edges         [][2]placedNode
+}
+
+func (s Snapshot) draw(max float64) drawing {
+	d := drawing{title: s.Title, scale: 1}
+	if !s.Time.IsZero() {
+		d.title += " at " + s.Time.UTC().Format(time.RFC3339)
+	}
+	ids := make([]string, 0, len(s.Nodes))
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for id := range s.Nodes {
+		if p, ok := s.Layout[id]; ok {
+			ids = append(ids, id)
+			minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+			maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+		}
+	}
+	if len(ids) == 0 {
+		minX, minY, maxX, maxY = 0, 0, 0, 0
+	}
+	sort.Strings(ids)
+
+	d.width = maxX - minX + 2*margin
+	d.height = maxY - minY + 2*margin + titleSize
+	if max > 0 && math.Max(d.width, d.height) > max {
+		d.scale = max / math.Max(d.width, d.height)
+		d.width, d.height = d.width*d.scale, d.height*d.scale
+	}
+
+	index := map[string]placedNode{}
+	for _, id := range ids {
+		p := s.Layout[id]
+		n := placedNode{
+			NodeSummary: s.Nodes[id],
+			x:           (p.X - minX + margin) * d.scale,
+			y:           (p.Y - minY + margin + titleSize) * d.scale,
+		}
+		index[id] = n
+		d.nodes = append(d.nodes, n)
+	}
+	for _, n := range d.nodes {
+		for _, dst := range n.Adjacency {
+			if m, ok := index[dst]; ok && dst != n.ID {
+				d.edges = append(d.edges, [2]placedNode{n, m})
+			}
+		}
+	}
+	return d
+}
+
+func distance(x1, y1, x2, y2 float64) float64 {
+	return math.Hypot(x2-x1, y2-y1)
+}
+
+// sides is how many sides the polygon drawing a shape has, zero for a
+// circle.
+func sides(shape string) int {
+	switch shape {
+	case report.Triangle:
+		return 3
+	case report.Square:
+		return 4
+	case report.Pentagon:
+		return 5
+	case report.Hexagon:
+		return 6
+	case report.Heptagon:
+		return 7
+	case report.Octagon:
+		return 8
+	}
+	return 0
+}
+
+// polygon returns the vertices of a regular polygon around x, y, sitting
+// on a side.
+func polygon(n int, x, y, r float64) [][2]float64 {
+	start := -math.Pi / 2
+	if n%2 == 0 {
+		start += math.Pi / float64(n)
+	}
+	points := make([][2]float64, n)
+	for i := range points {
+		angle := start + 2*math.Pi*float64(i)/float64(n)
+		points[i] = [2]float64{x + r*math.Cos(angle), y + r*math.Sin(angle)}
+	}
+	return points
+}
@@ -0,0 +1,92 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/render/layout"
+	"github.com/weaveworks/scope/render/snapshot"
+	"github.com/weaveworks/scope/report"
+)
+
+func testSnapshot() snapshot.Snapshot {
+	summary := func(id, label, shape string, adjacent ...string) detailed.NodeSummary {
+		return detailed.NodeSummary{
+			BasicNodeSummary: detailed.BasicNodeSummary{ID: id, Label: label, Shape: shape},
+			Adjacency:        report.MakeIDList(adjacent...),
+		}
+	}
+	nodes := detailed.NodeSummaries{
+		"a": summary("a", "front<end>", report.Hexagon, "b"),
+		"b": summary("b", "back & end", report.Circle),
+	}
+	b := nodes["b"]
+	b.Annotation = &detailed.Annotation{NodeID: "b", Note: "suspicious", Pinned: true}
+	nodes["b"] = b
+	return snapshot.Snapshot{
+		Title:  "Containers",
+		Time:   time.Date(2017, 3, 3, 16, 4, 48, 0, time.UTC),
+		Nodes:  nodes,
+		Layout: layout.Layout{"a": {X: 0, Y: 0}, "b": {X: 0, Y: 100}},
+	}
+}
+
+// wellFormed checks a document is well-formed XML.
+func wellFormed(t *testing.T, doc []byte) {
+	decoder := xml.NewDecoder(bytes.NewReader(doc))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			return
+		} else if err != nil {
+			t.Fatalf("%v: %s", err, doc)
+		}
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := snapshot.WriteSVG(&buf, testSnapshot()); err != nil {
+		t.Fatal(err)
+	}
+	wellFormed(t, buf.Bytes())
+	for _, want := range []string{"Containers at 2017-03-03T16:04:48Z", "front&lt;end&gt;", "back &amp; end", "<polygon", "<line"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("want %q in %s", want, buf.String())
+		}
+	}
+}
+
+func TestWritePNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := snapshot.WritePNG(&buf, testSnapshot()); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X <= 0 || size.Y <= 100 {
+		t.Errorf("want the nodes fitted in, have %v", size)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := snapshot.WriteHTML(&buf, testSnapshot()); err != nil {
+		t.Fatal(err)
+	}
+	wellFormed(t, buf.Bytes())
+	for _, want := range []string{"<svg", "front&lt;end&gt;", "pinned suspicious"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("want %q in %s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package snapshot
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// WriteSVG draws a snapshot as an SVG image.
+func WriteSVG(w io.Writer, s Snapshot) error {
+	return s.draw(0).writeSVG(w)
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func (d drawing) writeSVG(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="sans-serif">`+"\n",
+		d.width, d.height, d.width, d.height)
+	fmt.Fprintf(b, `<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="6" markerHeight="6" orient="auto"><path d="M0,0 L10,5 L0,10 z" fill="%s"/></marker></defs>`+"\n", hex(edgeColor))
+	fmt.Fprintf(b, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+	fmt.Fprintf(b, `<text x="%.0f" y="%.0f" font-size="18" fill="%s">%s</text>`+"\n", margin/2, titleSize, hex(textColor), html.EscapeString(d.title))
+
+	for _, e := range d.edges {
+		// Edges end at the rim of the node they point to, arrow and all.
+		x1, y1, x2, y2 := e[0].x, e[0].y, e[1].x, e[1].y
+		if length := distance(x1, y1, x2, y2); length > nodeRadius {
+			x2 -= (x2 - x1) / length * nodeRadius
+			y2 -= (y2 - y1) / length * nodeRadius
+		}
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="1.5" marker-end="url(#arrow)"/>`+"\n",
+			x1, y1, x2, y2, hex(edgeColor))
+	}
+
+	for _, n := range d.nodes {
+		fill := hex(n.color())
+		stroke := `stroke="#555555" stroke-width="1"`
+		if n.Pseudo {
+			stroke = `stroke="#999999" stroke-width="1" stroke-dasharray="3,3"`
+		}
+		fmt.Fprintf(b, `<g><title>%s</title>`, html.EscapeString(n.ID))
+		if k := sides(n.Shape); k > 0 {
+			var points []string
+			for _, p := range polygon(k, n.x, n.y, nodeRadius) {
+				points = append(points, fmt.Sprintf("%.1f,%.1f", p[0], p[1]))
+			}
+			fmt.Fprintf(b, `<polygon points="%s" fill="%s" %s/>`, strings.Join(points, " "), fill, stroke)
+		} else {
+			fmt.Fprintf(b, `<circle cx="%.1f" cy="%.1f" r="%.0f" fill="%s" %s/>`, n.x, n.y, nodeRadius, fill, stroke)
+		}
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" font-size="12" text-anchor="middle" fill="%s">%s</text>`,
+			n.x, n.y+nodeRadius+14, hex(textColor), html.EscapeString(n.Label))
+		if n.LabelMinor != "" {
+			fmt.Fprintf(b, `<text x="%.1f" y="%.1f" font-size="10" text-anchor="middle" fill="#777777">%s</text>`,
+				n.x, n.y+nodeRadius+27, html.EscapeString(n.LabelMinor))
+		}
+		fmt.Fprintf(b, "</g>\n")
+	}
+	fmt.Fprintf(b, "</svg>\n")
+	return b.Flush()
+}
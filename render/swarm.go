@@ -4,19 +4,27 @@ import (
 	"github.com/weaveworks/scope/report"
 )
 
-// SwarmServiceRenderer is a Renderer for Docker Swarm services
-//
-// not memoised
-var SwarmServiceRenderer = ConditionalRenderer(renderSwarmTopologies,
+// SwarmTaskRenderer is a Renderer for the tasks of Docker Swarm services
+var SwarmTaskRenderer = Memoise(ConditionalRenderer(renderSwarmTopologies,
 	renderParents(
-		report.Container, []string{report.SwarmService}, UnmanagedID,
+		report.Container, []string{report.SwarmTask}, UnmanagedID,
 		MakeFilter(
 			IsRunning,
 			ContainerWithImageNameRenderer,
 		),
 	),
+))
+
+// SwarmServiceRenderer is a Renderer for Docker Swarm services
+//
+// not memoised
+var SwarmServiceRenderer = ConditionalRenderer(renderSwarmTopologies,
+	renderParents(
+		report.SwarmTask, []string{report.SwarmService}, "",
+		SwarmTaskRenderer,
+	),
 )
 
 func renderSwarmTopologies(rpt report.Report) bool {
-	return len(rpt.SwarmService.Nodes) >= 1
+	return len(rpt.SwarmService.Nodes)+len(rpt.SwarmTask.Nodes) >= 1
 }
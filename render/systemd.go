@@ -0,0 +1,20 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// SystemdUnitRenderer is a Renderer which produces a renderable systemd unit
+// graph, with the processes running in each unit as its children.
+//
+// not memoised
+var SystemdUnitRenderer = ConditionalRenderer(renderSystemdUnitTopologies,
+	renderParents(
+		report.Process, []string{report.SystemdUnit}, "",
+		ProcessWithContainerNameRenderer,
+	),
+)
+
+func renderSystemdUnitTopologies(rpt report.Report) bool {
+	return len(rpt.SystemdUnit.Nodes) >= 1
+}
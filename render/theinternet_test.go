@@ -40,3 +40,30 @@ func TestReportLocalNetworks(t *testing.T) {
 		t.Errorf("%s", test.Diff(want, have))
 	}
 }
+
+func TestIPv6InternetNodes(t *testing.T) {
+	var (
+		hostNodeID     = report.MakeHostNodeID("host1")
+		serverNodeID   = report.MakeEndpointNodeID("host1", "", "fd00:10::2", "80")
+		internetNodeID = report.MakeEndpointNodeID("host1", "", "2001:db8::1", "56789")
+		localNodeID    = report.MakeEndpointNodeID("host1", "", "fd00:10::3", "56789")
+	)
+	rpt := report.MakeReport()
+	rpt.Endpoint.AddNode(report.MakeNodeWith(serverNodeID, map[string]string{report.HostNodeID: hostNodeID}))
+	rpt.Endpoint.AddNode(report.MakeNode(internetNodeID).WithAdjacent(serverNodeID))
+	rpt.Endpoint.AddNode(report.MakeNode(localNodeID).WithAdjacent(serverNodeID))
+	rpt.Host.AddNode(report.MakeNodeWith(hostNodeID, map[string]string{report.HostNodeID: hostNodeID}).
+		WithSets(report.MakeSets().Add(host.LocalNetworks, report.MakeStringSet("10.0.0.0/8", "fd00:10::/64"))))
+
+	have := render.HostRenderer.Render(rpt).Nodes
+	internet, ok := have[render.IncomingInternetID]
+	if !ok {
+		t.Fatalf("expected an incoming internet node, have %v", have)
+	}
+	if !internet.Adjacency.Contains(hostNodeID) {
+		t.Errorf("expected the internet node to be connected to %s, have %v", hostNodeID, internet.Adjacency)
+	}
+	if children := internet.Children.Size(); children != 1 {
+		t.Errorf("expected only %s in the internet node, have %d children", internetNodeID, children)
+	}
+}
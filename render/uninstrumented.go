@@ -0,0 +1,142 @@
+package render
+
+import (
+	"net"
+	"strings"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Constants are used in the tests.
+const (
+	UninstrumentedID    = "uninstrumented"
+	UninstrumentedMajor = "Uninstrumented"
+
+	// UninstrumentedAddresses counts the addresses of the peers of an
+	// uninstrumented pseudo node.
+	UninstrumentedAddresses = "uninstrumented_addresses"
+)
+
+// UninstrumentedIDPrefix is the prefix of uninstrumented pseudo nodes
+var UninstrumentedIDPrefix = MakePseudoNodeID(UninstrumentedID, "")
+
+// How Uninstrumented groups peers.
+const (
+	BySubnet = "subnet" // the /24, or /64 for IPv6, they are in
+	ByDomain = "domain" // of their reverse DNS names, else their subnet
+)
+
+// Uninstrumented is a Transformer adding, to the rendered nodes, the peers
+// they connect to, or from, in the local networks which no probe reports
+// on, the blind spots of the probes, grouped into pseudo nodes by subnet or
+// domain. They are otherwise left out of topologies. Each counts the
+// addresses of its peers, and has their endpoints as children.
+type Uninstrumented struct {
+	Report report.Report
+	By     string
+}
+
+// Transform implements Transformer
+func (u Uninstrumented) Transform(nodes Nodes) Nodes {
+	// The rendered nodes of endpoints
+	owners := map[string]string{}
+	for id, n := range nodes.Nodes {
+		if n.Topology == Pseudo {
+			continue
+		}
+		n.Children.ForEach(func(child report.Node) {
+			if child.Topology == report.Endpoint {
+				owners[child.ID] = id
+			}
+		})
+	}
+	if len(owners) == 0 {
+		return nodes
+	}
+
+	var (
+		local     = LocalNetworks(u.Report)
+		output    = make(report.Nodes, len(nodes.Nodes))
+		addresses = map[string]map[string]struct{}{}
+	)
+	for id, n := range nodes.Nodes {
+		output[id] = n
+	}
+	add := func(peer report.Node, ownerID string, outgoing bool) {
+		id, addr, ok := u.groupOf(peer, local)
+		if !ok {
+			return
+		}
+		n, ok := output[id]
+		if !ok {
+			n = report.MakeNode(id).WithTopology(Pseudo)
+			addresses[id] = map[string]struct{}{}
+		}
+		n.Children = n.Children.Add(peer)
+		if outgoing {
+			owner := output[ownerID]
+			owner.Adjacency = owner.Adjacency.Merge(report.MakeIDList(id))
+			output[ownerID] = owner
+		} else {
+			n.Adjacency = n.Adjacency.Merge(report.MakeIDList(ownerID))
+		}
+		output[id] = n
+		addresses[id][addr] = struct{}{}
+	}
+	for _, ep := range u.Report.Endpoint.Nodes {
+		if ownerID, ok := owners[ep.ID]; ok {
+			for _, dst := range ep.Adjacency {
+				if peer, ok := u.Report.Endpoint.Nodes[dst]; ok {
+					add(peer, ownerID, true)
+				}
+			}
+			continue
+		}
+		for _, dst := range ep.Adjacency {
+			if ownerID, ok := owners[dst]; ok {
+				add(ep, ownerID, false)
+			}
+		}
+	}
+	for id, addrs := range addresses {
+		n := output[id]
+		n.Counters = n.Counters.Add(UninstrumentedAddresses, len(addrs))
+		output[id] = n
+	}
+	return Nodes{Nodes: output, Filtered: nodes.Filtered}
+}
+
+// groupOf returns the ID of the uninstrumented pseudo node of an endpoint,
+// and its address, if no probe reports on it, and it is not on the
+// internet, nor a known service.
+func (u Uninstrumented) groupOf(ep report.Node, local report.Networks) (string, string, bool) {
+	if _, ok := ep.Latest.Lookup(report.HostNodeID); ok {
+		return "", "", false
+	}
+	_, addr, _, ok := report.ParseEndpointNodeID(ep.ID)
+	if !ok {
+		return "", "", false
+	}
+	if _, ok := externalNodeID(ep, addr, local); ok {
+		return "", "", false
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", "", false
+	}
+	if u.By == ByDomain {
+		if name, ok := DNSFirstMatch(ep, func(string) bool { return true }); ok {
+			name = strings.TrimSuffix(name, ".")
+			if i := strings.Index(name, "."); i >= 0 {
+				name = name[i+1:]
+			}
+			return MakePseudoNodeID(UninstrumentedID, name), addr, true
+		}
+	}
+	mask := net.CIDRMask(24, 32)
+	if ip.To4() == nil {
+		mask = net.CIDRMask(64, 128)
+	}
+	subnet := net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	return MakePseudoNodeID(UninstrumentedID, subnet.String()), addr, true
+}
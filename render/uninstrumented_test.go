@@ -0,0 +1,64 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestUninstrumented(t *testing.T) {
+	var (
+		localID  = report.MakeEndpointNodeID("host1", "", "10.0.0.1", "40001")
+		local2ID = report.MakeEndpointNodeID("host1", "", "10.0.0.1", "80")
+		db1ID    = report.MakeEndpointNodeID("", "", "10.1.2.3", "5432")
+		db2ID    = report.MakeEndpointNodeID("", "", "10.1.2.4", "5432")
+		clientID = report.MakeEndpointNodeID("", "", "10.9.9.9", "50000")
+		webID    = report.MakeEndpointNodeID("", "", "1.2.3.4", "443")
+	)
+	rpt := report.MakeReport()
+	rpt.Host.AddNode(report.MakeNode(report.MakeHostNodeID("host1")).
+		WithSets(report.MakeSets().Add(host.LocalNetworks, report.MakeStringSet("10.0.0.0/8"))))
+	local := report.MakeNodeWith(localID, map[string]string{report.HostNodeID: report.MakeHostNodeID("host1")}).
+		WithTopology(report.Endpoint).WithAdjacent(db1ID, db2ID, webID)
+	local2 := report.MakeNodeWith(local2ID, map[string]string{report.HostNodeID: report.MakeHostNodeID("host1")}).
+		WithTopology(report.Endpoint)
+	rpt.Endpoint.AddNode(local)
+	rpt.Endpoint.AddNode(local2)
+	rpt.Endpoint.AddNode(report.MakeNode(db1ID).WithTopology(report.Endpoint).
+		WithSets(report.MakeSets().Add(endpoint.ReverseDNSNames, report.MakeStringSet("db1.corp.example."))))
+	rpt.Endpoint.AddNode(report.MakeNode(db2ID).WithTopology(report.Endpoint))
+	rpt.Endpoint.AddNode(report.MakeNode(clientID).WithTopology(report.Endpoint).WithAdjacent(local2ID))
+	rpt.Endpoint.AddNode(report.MakeNode(webID).WithTopology(report.Endpoint))
+
+	nodes := render.Nodes{Nodes: report.Nodes{
+		"web": report.MakeNode("web").WithTopology(report.Container).WithChildren(report.MakeNodeSet(local, local2)),
+	}}
+
+	var (
+		db      = render.MakePseudoNodeID(render.UninstrumentedID, "10.1.2.0/24")
+		corp    = render.MakePseudoNodeID(render.UninstrumentedID, "corp.example")
+		clients = render.MakePseudoNodeID(render.UninstrumentedID, "10.9.9.0/24")
+	)
+	have := render.Uninstrumented{Report: rpt, By: render.BySubnet}.Transform(nodes).Nodes
+	if want := report.MakeIDList(db); !reflect.DeepEqual(want, have["web"].Adjacency) {
+		t.Errorf("Expected edges to the uninstrumented peers only, got %v", have["web"].Adjacency)
+	}
+	if count, _ := have[db].Counters.Lookup(render.UninstrumentedAddresses); count != 2 {
+		t.Errorf("Expected 2 uninstrumented addresses in %s, got %d", db, count)
+	}
+	if want := report.MakeIDList("web"); !reflect.DeepEqual(want, have[clients].Adjacency) {
+		t.Errorf("Expected an edge from the uninstrumented clients, got %v", have[clients].Adjacency)
+	}
+	if len(nodes.Nodes["web"].Adjacency) != 0 {
+		t.Errorf("Expected the nodes transformed left alone")
+	}
+
+	have = render.Uninstrumented{Report: rpt, By: render.ByDomain}.Transform(nodes).Nodes
+	if want := report.MakeIDList(corp, db); !reflect.DeepEqual(want, have["web"].Adjacency) {
+		t.Errorf("Expected peers grouped by domain, or else subnet, got %v", have["web"].Adjacency)
+	}
+}
@@ -0,0 +1,24 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// DockerVolumeRenderer is a Renderer which produces a renderable Docker
+// volume graph, with the containers mounting each volume, or bind mount, as
+// its children.
+//
+// not memoised
+var DockerVolumeRenderer = ConditionalRenderer(renderDockerVolumeTopologies,
+	renderParents(
+		report.Container, []string{report.DockerVolume}, UnmanagedID,
+		MakeFilter(
+			IsRunning,
+			ContainerWithImageNameRenderer,
+		),
+	),
+)
+
+func renderDockerVolumeTopologies(rpt report.Report) bool {
+	return len(rpt.DockerVolume.Nodes) >= 1
+}
@@ -0,0 +1,80 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/report"
+)
+
+// EdgeWeight is how heavy the traffic along an edge is: how many of the
+// connections of the endpoints beneath the source are to the destination,
+// and the bytes they exchanged, if the probes sampled them.
+type EdgeWeight struct {
+	Connections int `json:"connections"`
+	Bytes       int `json:"bytes,omitempty"`
+}
+
+// EdgeWeights returns the weights of the edges between rendered nodes, by
+// source and destination. Edges with no connections of endpoints beneath
+// them, e.g. those of pseudo nodes the probes don't know the endpoints of,
+// are left out.
+func EdgeWeights(nodes report.Nodes) map[string]map[string]EdgeWeight {
+	var result map[string]map[string]EdgeWeight
+	for id, n := range nodes {
+		if len(n.Adjacency) == 0 {
+			continue
+		}
+		local := endpointChildren(n)
+		if len(local) == 0 {
+			continue
+		}
+		for _, dstID := range n.Adjacency {
+			dst, ok := nodes[dstID]
+			if !ok {
+				continue
+			}
+			weight, found := edgeWeight(local, dst)
+			if !found {
+				continue
+			}
+			if result == nil {
+				result = map[string]map[string]EdgeWeight{}
+			}
+			if result[id] == nil {
+				result[id] = map[string]EdgeWeight{}
+			}
+			result[id][dstID] = weight
+		}
+	}
+	return result
+}
+
+// edgeWeight sums the connections from the local endpoints to those of
+// dst. Byte counters are carried by the source endpoints of connections.
+func edgeWeight(local []report.Node, dst report.Node) (EdgeWeight, bool) {
+	remoteIDs := report.MakeIDList()
+	dst.Children.ForEach(func(child report.Node) {
+		if child.Topology == report.Endpoint {
+			remoteIDs = remoteIDs.Add(child.ID)
+		}
+	})
+	var (
+		weight EdgeWeight
+		found  bool
+	)
+	for _, ep := range local {
+		connections := len(ep.Adjacency.Intersection(remoteIDs))
+		if connections == 0 {
+			continue
+		}
+		// Sampled short-lived connections stand for several connections
+		if sampled, ok := ep.Counters.Lookup(endpoint.SampledConnections); ok && sampled > connections {
+			connections = sampled
+		}
+		weight.Connections += connections
+		egress, _ := ep.Counters.Lookup(endpoint.EgressBytes)
+		ingress, _ := ep.Counters.Lookup(endpoint.IngressBytes)
+		weight.Bytes += egress + ingress
+		found = true
+	}
+	return weight, found
+}
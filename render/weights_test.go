@@ -0,0 +1,37 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestEdgeWeights(t *testing.T) {
+	var (
+		clientA = report.MakeEndpointNodeID("host1", "", "10.0.0.1", "40001")
+		clientB = report.MakeEndpointNodeID("host1", "", "10.0.0.1", "40002")
+		server  = report.MakeEndpointNodeID("host2", "", "10.0.0.2", "80")
+	)
+	nodes := report.Nodes{
+		"client": report.MakeNode("client").WithAdjacent("server", "internet").WithChildren(report.MakeNodeSet(
+			report.MakeNode(clientA).WithTopology(report.Endpoint).WithAdjacent(server).
+				WithCounters(map[string]int{endpoint.EgressBytes: 100, endpoint.IngressBytes: 1000}),
+			report.MakeNode(clientB).WithTopology(report.Endpoint).WithAdjacent(server).
+				WithCounters(map[string]int{endpoint.SampledConnections: 3}),
+		)),
+		"server": report.MakeNode("server").WithChildren(report.MakeNodeSet(
+			report.MakeNode(server).WithTopology(report.Endpoint),
+		)),
+		"internet": report.MakeNode("internet").WithTopology(render.Pseudo),
+	}
+
+	want := map[string]map[string]render.EdgeWeight{
+		"client": {"server": {Connections: 4, Bytes: 1100}},
+	}
+	if have := render.EdgeWeights(nodes); !reflect.DeepEqual(want, have) {
+		t.Errorf("Expected %v, got %v", want, have)
+	}
+}
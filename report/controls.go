@@ -1,6 +1,8 @@
 package report
 
 import (
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/ugorji/go/codec"
@@ -12,10 +14,72 @@ type Controls map[string]Control
 
 // A Control basically describes an RPC
 type Control struct {
-	ID    string `json:"id"`
-	Human string `json:"human"`
-	Icon  string `json:"icon"` // from https://fortawesome.github.io/Font-Awesome/cheatsheet/ please
-	Rank  int    `json:"rank"`
+	ID     string         `json:"id"`
+	Human  string         `json:"human"`
+	Icon   string         `json:"icon"` // from https://fortawesome.github.io/Font-Awesome/cheatsheet/ please
+	Rank   int            `json:"rank"`
+	Params []ControlParam `json:"params,omitempty"` // the UI asks for, in a form, and passes as the arguments of the control
+
+	// Confirm is the question the UI asks before invoking destructive
+	// controls, e.g. "Stop this container?"; none if empty.
+	Confirm string `json:"confirm,omitempty"`
+	// DryRun is the ID of a control of the same nodes which describes
+	// what this one would do, without doing it, for the UI to show before
+	// the real action. Probes without it will not recognise it, rather
+	// than doing the real action.
+	DryRun string `json:"dryRun,omitempty"`
+}
+
+// Types of the parameters of controls
+const (
+	ControlParamString = "string"
+	ControlParamEnum   = "enum"
+	ControlParamInt    = "int"
+)
+
+// ControlParam is a parameter of a Control, e.g. the number of replicas to
+// scale to. Parameters without a default must be given.
+type ControlParam struct {
+	ID      string   `json:"id"`
+	Human   string   `json:"human"`
+	Type    string   `json:"type"`
+	Options []string `json:"options,omitempty"` // of enums
+	Default string   `json:"default,omitempty"`
+}
+
+// Args checks the arguments of a request of the control against its
+// parameters, returning them with the defaults of those not given.
+// Arguments not of parameters, like those of pipes, are kept.
+func (c Control) Args(args map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(args)+len(c.Params))
+	for k, v := range args {
+		result[k] = v
+	}
+	for _, p := range c.Params {
+		v, ok := result[p.ID]
+		if !ok {
+			if p.Default == "" {
+				return nil, fmt.Errorf("missing argument: %s", p.ID)
+			}
+			v = p.Default
+			result[p.ID] = v
+		}
+		switch p.Type {
+		case ControlParamInt:
+			if _, err := strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("argument %s is not an integer: %q", p.ID, v)
+			}
+		case ControlParamEnum:
+			valid := false
+			for _, option := range p.Options {
+				valid = valid || option == v
+			}
+			if !valid {
+				return nil, fmt.Errorf("argument %s is not one of %v: %q", p.ID, p.Options, v)
+			}
+		}
+	}
+	return result, nil
 }
 
 // Merge merges other with cs, returning a fresh Controls.
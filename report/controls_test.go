@@ -0,0 +1,45 @@
+package report_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestControlArgs(t *testing.T) {
+	control := report.Control{ID: "signal", Params: []report.ControlParam{
+		{ID: "signal", Type: report.ControlParamEnum, Options: []string{"HUP", "TERM", "KILL"}, Default: "TERM"},
+		{ID: "delay", Type: report.ControlParamInt, Default: "0"},
+		{ID: "reason", Type: report.ControlParamString},
+	}}
+
+	for _, c := range []struct {
+		args    map[string]string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			args: map[string]string{"reason": "stuck", "pipeID": "p"},
+			want: map[string]string{"signal": "TERM", "delay": "0", "reason": "stuck", "pipeID": "p"},
+		},
+		{
+			args: map[string]string{"signal": "HUP", "delay": "10", "reason": ""},
+			want: map[string]string{"signal": "HUP", "delay": "10", "reason": ""},
+		},
+		{args: map[string]string{}, wantErr: true},
+		{args: map[string]string{"reason": "stuck", "signal": "STOP"}, wantErr: true},
+		{args: map[string]string{"reason": "stuck", "delay": "soon"}, wantErr: true},
+	} {
+		have, err := control.Args(c.args)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%v: expected an error", c.args)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(c.want, have) {
+			t.Errorf("%v: expected %v, got %v (%v)", c.args, c.want, have, err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package report
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/common/mtime"
+)
+
+// DeltaContentType is the content type of gzipped msgpack encoded Deltas.
+const DeltaContentType = "application/x-scope-report-delta"
+
+// A Delta is a report expressed as changes to an earlier, full report: its
+// baseline. Delta.Report has everything of the report except the nodes which
+// are unchanged since the baseline; the nodes which are gone are listed by
+// topology in Removed.
+type Delta struct {
+	Baseline  string            `json:"baseline"` // ID of the baseline report
+	Report    Report            `json:"report"`
+	Removed   map[string]IDList `json:"removed,omitempty"`
+	Timestamp time.Time         `json:"timestamp"` // when the Delta was made
+}
+
+// MakeDelta makes the Delta turning baseline into rpt.
+func MakeDelta(baseline, rpt Report) Delta {
+	d := Delta{
+		Baseline:  baseline.ID,
+		Report:    rpt,
+		Removed:   map[string]IDList{},
+		Timestamp: mtime.Now(),
+	}
+	d.Report.WalkNamedTopologies(func(name string, t *Topology) {
+		before := baseline.topology(name).Nodes
+		changed := Nodes{}
+		for id, n := range t.Nodes {
+			if b, ok := before[id]; !ok || !sameNode(b, n) {
+				changed[id] = n
+			}
+		}
+		var removed IDList
+		for id := range before {
+			if _, ok := t.Nodes[id]; !ok {
+				removed = removed.Add(id)
+			}
+		}
+		if len(removed) > 0 {
+			d.Removed[name] = removed
+		}
+		t.Nodes = changed
+	})
+	return d
+}
+
+// Apply reassembles the full report of the Delta from its baseline.
+// Unchanged nodes are those of the baseline, with their timestamps brought
+// forward to that of the Delta, as the probe refreshed them: otherwise a
+// value changed and changed back since the baseline would be older than the
+// change, and lose to it when reports are merged.
+func (d Delta) Apply(baseline Report) (Report, error) {
+	if baseline.ID != d.Baseline {
+		return Report{}, fmt.Errorf("delta is against report %s, not %s", d.Baseline, baseline.ID)
+	}
+	rpt := d.Report
+	rpt.WalkNamedTopologies(func(name string, t *Topology) {
+		changed := t.Nodes
+		t.Nodes = Nodes{}
+		for id, n := range baseline.topology(name).Nodes {
+			if !d.Timestamp.IsZero() {
+				n = restamp(n, d.Timestamp)
+			}
+			t.Nodes[id] = n
+		}
+		for _, id := range d.Removed[name] {
+			delete(t.Nodes, id)
+		}
+		for id, n := range changed {
+			t.Nodes[id] = n
+		}
+	})
+	return rpt, nil
+}
+
+// WriteBinary writes a Delta as a gzipped msgpack.
+func (d Delta) WriteBinary(w io.Writer, compressionLevel int) error {
+	gzwriter, err := gzip.NewWriterLevel(w, compressionLevel)
+	if err != nil {
+		return err
+	}
+	if err = codec.NewEncoder(gzwriter, &codec.MsgpackHandle{}).Encode(&d); err != nil {
+		return err
+	}
+	return gzwriter.Close()
+}
+
+// ReadBinary reads a msgpack encoded Delta, decompressing it first if
+// gzipped is true.
+func (d *Delta) ReadBinary(r io.Reader, gzipped bool) error {
+	if gzipped {
+		gzreader, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		r = gzreader
+	}
+	return codec.NewDecoder(r, &codec.MsgpackHandle{}).Decode(d)
+}
+
+// restamp returns n with the timestamps of its latest entries and controls
+// which are before t brought forward to t.
+func restamp(n Node, t time.Time) Node {
+	latest := MakeStringLatestMap()
+	n.Latest.ForEach(func(k string, timestamp time.Time, v string) {
+		if timestamp.Before(t) {
+			timestamp = t
+		}
+		latest = latest.Set(k, timestamp, v)
+	})
+	latestControls := MakeNodeControlDataLatestMap()
+	n.LatestControls.ForEach(func(k string, timestamp time.Time, v NodeControlData) {
+		if timestamp.Before(t) {
+			timestamp = t
+		}
+		latestControls = latestControls.Set(k, timestamp, v)
+	})
+	n.Latest, n.LatestControls = latest, latestControls
+	if !n.Controls.Timestamp.IsZero() && n.Controls.Timestamp.Before(t) {
+		n.Controls.Timestamp = t
+	}
+	return n
+}
+
+// sameNode tells whether two nodes are equal, other than the timestamps of
+// their latest entries and controls, which probes refresh on every report.
+func sameNode(a, b Node) bool {
+	wa, wb := a.toWire(), b.toWire()
+	for _, w := range []*wireNode{wa, wb} {
+		w.ControlsTimestamp = 0
+		for _, e := range w.Latest {
+			e.Timestamp = 0
+		}
+		for _, e := range w.LatestControls {
+			e.Timestamp = 0
+		}
+	}
+	return reflect.DeepEqual(wa, wb)
+}
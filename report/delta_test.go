@@ -0,0 +1,102 @@
+package report_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestDelta(t *testing.T) {
+	start := time.Unix(0, time.Now().UnixNano())
+	mtime.NowForce(start)
+	defer mtime.NowReset()
+
+	baseline := report.MakeReport()
+	baseline.Host.AddNode(report.MakeNodeWith("unchanged", map[string]string{"os": "linux"}))
+	baseline.Host.AddNode(report.MakeNodeWith("changed", map[string]string{"os": "linux"}))
+	baseline.Host.AddNode(report.MakeNodeWith("removed", map[string]string{"os": "linux"}))
+
+	// Refreshed timestamps alone don't make a node changed.
+	mtime.NowForce(start.Add(time.Second))
+	rpt := report.MakeReport()
+	rpt.Host.AddNode(report.MakeNodeWith("unchanged", map[string]string{"os": "linux"}))
+	rpt.Host.AddNode(report.MakeNodeWith("changed", map[string]string{"os": "windows"}))
+	rpt.Host.AddNode(report.MakeNodeWith("added", map[string]string{"os": "linux"}))
+
+	delta := report.MakeDelta(baseline, rpt)
+	if want, have := 2, len(delta.Report.Host.Nodes); want != have {
+		t.Errorf("want %d changed nodes, have %d", want, have)
+	}
+	if want, have := (report.IDList{"removed"}), delta.Removed[report.Host]; !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+
+	buf := &bytes.Buffer{}
+	if err := delta.WriteBinary(buf, gzip.BestCompression); err != nil {
+		t.Fatal(err)
+	}
+	var decoded report.Delta
+	if err := decoded.ReadBinary(buf, true); err != nil {
+		t.Fatal(err)
+	}
+	have, err := decoded.Apply(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"added", "changed", "unchanged"}, sortedIDs(have.Host.Nodes); !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+	if os, _ := have.Host.Nodes["changed"].Latest.Lookup("os"); os != "windows" {
+		t.Errorf("want changed node from the delta, have os %q", os)
+	}
+
+	if _, err := decoded.Apply(report.MakeReport()); err == nil {
+		t.Error("expected an error applying a delta to another baseline")
+	}
+}
+
+// A value changed and changed back since the baseline is as new as the
+// report changing it back, so it wins when reports are merged.
+func TestDeltaChangedBack(t *testing.T) {
+	start := time.Unix(0, time.Now().UnixNano())
+	mtime.NowForce(start)
+	defer mtime.NowReset()
+
+	reportWith := func(state string) report.Report {
+		rpt := report.MakeReport()
+		rpt.Container.AddNode(report.MakeNodeWith("c", map[string]string{"state": state}))
+		return rpt
+	}
+	baseline := reportWith("running")
+
+	var reports []report.Report
+	for i, state := range []string{"paused", "running"} {
+		mtime.NowForce(start.Add(time.Duration(i+1) * time.Second))
+		rpt, err := report.MakeDelta(baseline, reportWith(state)).Apply(baseline)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reports = append(reports, rpt)
+	}
+	if _, timestamp, _ := reports[1].Container.Nodes["c"].Latest.LookupEntry("state"); !timestamp.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("want the unchanged node stamped by the delta, have %v", timestamp)
+	}
+	merged := reports[0].Merge(reports[1])
+	if state, _ := merged.Container.Nodes["c"].Latest.Lookup("state"); state != "running" {
+		t.Errorf("want running, have %q", state)
+	}
+}
+
+func sortedIDs(nodes report.Nodes) []string {
+	ids := report.MakeIDList()
+	for id := range nodes {
+		ids = ids.Add(id)
+	}
+	return []string(ids)
+}
@@ -0,0 +1,41 @@
+package report
+
+// DNSRecord contains the names an IP address is known by: the names
+// which resolve to it (Forward) and the names it reverse-resolves to
+// (Reverse).
+type DNSRecord struct {
+	Forward StringSet `json:"forward,omitempty"`
+	Reverse StringSet `json:"reverse,omitempty"`
+}
+
+// Merge merges two DNSRecords.
+func (r DNSRecord) Merge(other DNSRecord) DNSRecord {
+	return DNSRecord{
+		Forward: r.Forward.Merge(other.Forward),
+		Reverse: r.Reverse.Merge(other.Reverse),
+	}
+}
+
+// DNSRecords contains the DNSRecords of IP addresses, keyed by address.
+type DNSRecords map[string]DNSRecord
+
+// Copy makes a copy of the DNSRecords.
+func (r DNSRecords) Copy() DNSRecords {
+	cp := make(DNSRecords, len(r))
+	for k, v := range r {
+		cp[k] = v
+	}
+	return cp
+}
+
+// Merge merges the other records into a new DNSRecords.
+func (r DNSRecords) Merge(other DNSRecords) DNSRecords {
+	cp := r.Copy()
+	for k, v := range other {
+		if existing, ok := cp[k]; ok {
+			v = existing.Merge(v)
+		}
+		cp[k] = v
+	}
+	return cp
+}
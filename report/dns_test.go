@@ -0,0 +1,28 @@
+package report_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestDNSRecordsMerge(t *testing.T) {
+	a := report.DNSRecords{
+		"1.2.3.4": {Forward: report.MakeStringSet("a.example.com")},
+		"5.6.7.8": {Reverse: report.MakeStringSet("host.example.net")},
+	}
+	b := report.DNSRecords{
+		"1.2.3.4": {Forward: report.MakeStringSet("b.example.com"), Reverse: report.MakeStringSet("edge.example.net")},
+	}
+	want := report.DNSRecords{
+		"1.2.3.4": {Forward: report.MakeStringSet("a.example.com", "b.example.com"), Reverse: report.MakeStringSet("edge.example.net")},
+		"5.6.7.8": {Reverse: report.MakeStringSet("host.example.net")},
+	}
+	if have := a.Merge(b); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if len(a["1.2.3.4"].Forward) != 1 {
+		t.Errorf("Merge modified its receiver: %v", a)
+	}
+}
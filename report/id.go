@@ -25,20 +25,54 @@ const (
 
 	// DockerOverlayPeerPrefix is the prefix for docker peers in the overlay network
 	DockerOverlayPeerPrefix = "docker_peer_"
+
+	// TCP is the transport protocol assumed for endpoint node IDs which
+	// don't carry a protocol.
+	TCP = "tcp"
+
+	// UDP is the transport protocol for UDP endpoints.
+	UDP = "udp"
 )
 
 // MakeEndpointNodeID produces an endpoint node ID from its composite parts.
 func MakeEndpointNodeID(hostID, namespaceID, address, port string) string {
-	return makeAddressID(hostID, namespaceID, address) + ScopeDelim + port
+	return MakeClusterEndpointNodeID("", hostID, namespaceID, address, port, "")
+}
+
+// MakeEndpointNodeIDWithProtocol is like MakeEndpointNodeID, but also
+// records the transport protocol of the endpoint. TCP endpoints get the
+// same ID as from MakeEndpointNodeID, for compatibility with older
+// probes.
+func MakeEndpointNodeIDWithProtocol(hostID, namespaceID, address, port, protocol string) string {
+	return MakeClusterEndpointNodeID("", hostID, namespaceID, address, port, protocol)
+}
+
+// MakeClusterEndpointNodeID is like MakeEndpointNodeIDWithProtocol, but
+// scopes addresses which aren't scoped by host by the given cluster, if
+// any, so that one app can show several clusters, whose addresses can
+// clash.
+func MakeClusterEndpointNodeID(cluster, hostID, namespaceID, address, port, protocol string) string {
+	id := makeAddressID(cluster, hostID, namespaceID, address) + ScopeDelim + port
+	if protocol != "" && protocol != TCP {
+		id += ScopeDelim + protocol
+	}
+	return id
 }
 
 // MakeAddressNodeID produces an address node ID from its composite parts.
 func MakeAddressNodeID(hostID, address string) string {
-	return makeAddressID(hostID, "", address)
+	return makeAddressID("", hostID, "", address)
+}
+
+// MakeClusterAddressNodeID is like MakeAddressNodeID, but scopes addresses
+// which aren't scoped by host by the given cluster, if any, as
+// MakeClusterEndpointNodeID does.
+func MakeClusterAddressNodeID(cluster, hostID, address string) string {
+	return makeAddressID(cluster, hostID, "", address)
 }
 
-func makeAddressID(hostID, namespaceID, address string) string {
-	var scope string
+func makeAddressID(cluster, hostID, namespaceID, address string) string {
+	scope := cluster
 
 	// Loopback addresses and addresses explicitly marked as local get
 	// scoped by hostID
@@ -74,6 +108,35 @@ func MakeProcessNodeID(hostID, pid string) string {
 	return hostID + ScopeDelim + pid
 }
 
+// MakeUnixSocketNodeID produces a unix socket node ID from its composite
+// parts, the inode identifying the socket on its host.
+func MakeUnixSocketNodeID(hostID, inode string) string {
+	return hostID + ScopeDelim + inode
+}
+
+// MakeDockerVolumeNodeID produces a Docker volume node ID from its composite
+// parts, the source being the name of a volume or the path of a bind mount.
+func MakeDockerVolumeNodeID(hostID, source string) string {
+	return hostID + ScopeDelim + source
+}
+
+// MakeSystemdUnitNodeID produces a systemd unit node ID from its composite
+// parts.
+func MakeSystemdUnitNodeID(hostID, unit string) string {
+	return hostID + ScopeDelim + unit
+}
+
+// MakeNomadJobNodeID produces a Nomad job node ID from its composite parts.
+func MakeNomadJobNodeID(namespace, jobID string) string {
+	return namespace + ScopeDelim + jobID
+}
+
+// MakeNomadTaskGroupNodeID produces a Nomad task group node ID from its
+// composite parts.
+func MakeNomadTaskGroupNodeID(namespace, jobID, group string) string {
+	return namespace + ScopeDelim + jobID + ScopeDelim + group
+}
+
 // MakeECSServiceNodeID produces an ECS Service node ID from its composite parts.
 func MakeECSServiceNodeID(cluster, serviceName string) string {
 	return cluster + ScopeDelim + serviceName
@@ -140,6 +203,24 @@ var (
 	// ParseCronJobNodeID parses a cronjob node ID
 	ParseCronJobNodeID = parseSingleComponentID("cronjob")
 
+	// MakeJobNodeID produces a job node ID from its composite parts.
+	MakeJobNodeID = makeSingleComponentID("job")
+
+	// ParseJobNodeID parses a job node ID
+	ParseJobNodeID = parseSingleComponentID("job")
+
+	// MakeCustomResourceNodeID produces a custom resource node ID from its composite parts.
+	MakeCustomResourceNodeID = makeSingleComponentID("custom_resource")
+
+	// ParseCustomResourceNodeID parses a custom resource node ID
+	ParseCustomResourceNodeID = parseSingleComponentID("custom_resource")
+
+	// MakeIngressNodeID produces an ingress node ID from its composite parts.
+	MakeIngressNodeID = makeSingleComponentID("ingress")
+
+	// ParseIngressNodeID parses an ingress node ID
+	ParseIngressNodeID = parseSingleComponentID("ingress")
+
 	// MakeNamespaceNodeID produces a namespace node ID from its composite parts.
 	MakeNamespaceNodeID = makeSingleComponentID("namespace")
 
@@ -157,6 +238,30 @@ var (
 
 	// ParseSwarmServiceNodeID parses a Swarm service node ID
 	ParseSwarmServiceNodeID = parseSingleComponentID("swarm_service")
+
+	// MakeSwarmTaskNodeID produces a Swarm task node ID from its composite parts.
+	MakeSwarmTaskNodeID = makeSingleComponentID("swarm_task")
+
+	// ParseSwarmTaskNodeID parses a Swarm task node ID
+	ParseSwarmTaskNodeID = parseSingleComponentID("swarm_task")
+
+	// MakeComposeServiceNodeID produces a Compose service node ID from its composite parts.
+	MakeComposeServiceNodeID = makeSingleComponentID("compose_service")
+
+	// ParseComposeServiceNodeID parses a Compose service node ID
+	ParseComposeServiceNodeID = parseSingleComponentID("compose_service")
+
+	// MakeDockerNetworkNodeID produces a Docker network node ID from its composite parts.
+	MakeDockerNetworkNodeID = makeSingleComponentID("docker_network")
+
+	// ParseDockerNetworkNodeID parses a Docker network node ID
+	ParseDockerNetworkNodeID = parseSingleComponentID("docker_network")
+
+	// MakeNomadAllocationNodeID produces a Nomad allocation node ID from its composite parts.
+	MakeNomadAllocationNodeID = makeSingleComponentID("nomad_allocation")
+
+	// ParseNomadAllocationNodeID parses a Nomad allocation node ID
+	ParseNomadAllocationNodeID = parseSingleComponentID("nomad_allocation")
 )
 
 // makeSingleComponentID makes a single-component node id encoder
@@ -218,16 +323,28 @@ func ParseNodeID(nodeID string) (id string, tag string, ok bool) {
 // ParseEndpointNodeID produces the scope, address, and port and remainder.
 // Note that scope may be blank.
 func ParseEndpointNodeID(endpointNodeID string) (scope, address, port string, ok bool) {
+	scope, address, port, _, ok = ParseEndpointNodeIDWithProtocol(endpointNodeID)
+	return
+}
+
+// ParseEndpointNodeIDWithProtocol is like ParseEndpointNodeID, but also
+// produces the transport protocol of the endpoint, which defaults to TCP.
+func ParseEndpointNodeIDWithProtocol(endpointNodeID string) (scope, address, port, protocol string, ok bool) {
 	// Not using strings.SplitN() to avoid a heap allocation
 	first := strings.Index(endpointNodeID, ScopeDelim)
 	if first == -1 {
-		return "", "", "", false
+		return "", "", "", "", false
 	}
 	second := strings.Index(endpointNodeID[first+1:], ScopeDelim)
 	if second == -1 {
-		return "", "", "", false
+		return "", "", "", "", false
+	}
+	scope, address, port = endpointNodeID[:first], endpointNodeID[first+1:first+1+second], endpointNodeID[first+1+second+1:]
+	protocol = TCP
+	if third := strings.Index(port, ScopeDelim); third != -1 {
+		port, protocol = port[:third], port[third+1:]
 	}
-	return endpointNodeID[:first], endpointNodeID[first+1 : first+1+second], endpointNodeID[first+1+second+1:], true
+	return scope, address, port, protocol, true
 }
 
 // ParseAddressNodeID produces the host ID, address from an address node ID.
@@ -240,6 +357,34 @@ func ParseProcessNodeID(processNodeID string) (hostID, pid string, ok bool) {
 	return split2(processNodeID, ScopeDelim)
 }
 
+// ParseDockerVolumeNodeID produces the host ID and source from a Docker
+// volume node ID.
+func ParseDockerVolumeNodeID(volumeNodeID string) (hostID, source string, ok bool) {
+	return split2(volumeNodeID, ScopeDelim)
+}
+
+// ParseSystemdUnitNodeID produces the host ID and unit name from a systemd
+// unit node ID.
+func ParseSystemdUnitNodeID(unitNodeID string) (hostID, unit string, ok bool) {
+	return split2(unitNodeID, ScopeDelim)
+}
+
+// ParseNomadJobNodeID produces the namespace and job ID from a Nomad job
+// node ID.
+func ParseNomadJobNodeID(jobNodeID string) (namespace, jobID string, ok bool) {
+	return split2(jobNodeID, ScopeDelim)
+}
+
+// ParseNomadTaskGroupNodeID produces the namespace, job ID and group name
+// from a Nomad task group node ID.
+func ParseNomadTaskGroupNodeID(groupNodeID string) (namespace, jobID, group string, ok bool) {
+	fields := strings.SplitN(groupNodeID, ScopeDelim, 3)
+	if len(fields) != 3 {
+		return "", "", "", false
+	}
+	return fields[0], fields[1], fields[2], true
+}
+
 // ParseECSServiceNodeID produces the cluster, service name from an ECS Service node ID
 func ParseECSServiceNodeID(ecsServiceNodeID string) (cluster, serviceName string, ok bool) {
 	cluster, serviceName, ok = split2(ecsServiceNodeID, ScopeDelim)
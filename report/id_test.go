@@ -67,6 +67,46 @@ func TestEndpointNodeID(t *testing.T) {
 	}
 }
 
+func TestClusterNodeIDs(t *testing.T) {
+	for have, want := range map[string]string{
+		report.MakeClusterEndpointNodeID("east", "host.com", "", "10.0.0.1", "80", ""):             "east;10.0.0.1;80",
+		report.MakeClusterEndpointNodeID("east", "host.com", "", "10.0.0.1", "53", report.UDP):     "east;10.0.0.1;53;udp",
+		report.MakeClusterAddressNodeID("east", "host.com", "10.0.0.1"):                            "east;10.0.0.1",
+		report.MakeClusterEndpointNodeID("east", "host.com", "namespaceid", "127.0.0.1", "80", ""): "host.com-namespaceid;127.0.0.1;80",
+		report.MakeClusterEndpointNodeID("", "host.com", "", "10.0.0.1", "80", ""):                 ";10.0.0.1;80",
+	} {
+		if have != want {
+			t.Errorf("want %q, have %q", want, have)
+		}
+	}
+}
+
+func TestEndpointNodeIDWithProtocol(t *testing.T) {
+	for input, want := range map[string]struct{ name, address, port, protocol string }{
+		report.MakeEndpointNodeIDWithProtocol("host.com", "", "1.2.3.4", "53", report.UDP): {"", "1.2.3.4", "53", report.UDP},
+		report.MakeEndpointNodeIDWithProtocol("host.com", "", "1.2.3.4", "80", report.TCP): {"", "1.2.3.4", "80", report.TCP},
+		report.MakeEndpointNodeID("host.com", "", "1.2.3.4", "80"):                         {"", "1.2.3.4", "80", report.TCP},
+		"a;b;c;sctp": {"a", "b", "c", "sctp"},
+	} {
+		haveName, haveAddress, havePort, haveProtocol, ok := report.ParseEndpointNodeIDWithProtocol(input)
+		if !ok {
+			t.Errorf("%q: not OK", input)
+			continue
+		}
+		if want.name != haveName ||
+			want.address != haveAddress ||
+			want.port != havePort ||
+			want.protocol != haveProtocol {
+			t.Errorf("%q: want %q, have {%q, %q, %q, %q}", input, want, haveName, haveAddress, havePort, haveProtocol)
+		}
+	}
+
+	// Plain parsing must not leak the protocol into the port
+	if _, _, port, _ := report.ParseEndpointNodeID("a;b;53;udp"); port != "53" {
+		t.Errorf("want port 53, have %q", port)
+	}
+}
+
 func TestECSServiceNodeIDCompat(t *testing.T) {
 	testID := "my-service;<ecs_service>"
 	testName := "my-service"
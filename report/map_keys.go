@@ -6,12 +6,41 @@ const (
 	ReverseDNSNames = "reverse_dns_names"
 	SnoopedDNSNames = "snooped_dns_names"
 	CopyOf          = "copy_of"
+	EgressBytes     = "egress_bytes"
+	IngressBytes    = "ingress_bytes"
+	TCPRetransmits  = "tcp_retransmits"
+	TCPResets       = "tcp_resets"
+	RTT             = "rtt" // microseconds
+	SNINames        = "sni_names"
+	// estimated number of connections a sampled endpoint stands for
+	SampledConnections = "sampled_connections"
+	// virtual and real destinations of connections forwarded by IPVS
+	IPVSVirtualDestination = "ipvs_virtual_destination"
+	IPVSRealDestination    = "ipvs_real_destination"
+	// network interface (ENI), and EC2 instance, of an endpoint seen in
+	// VPC flow logs
+	VPCInterfaceID = "vpc_interface_id"
+	EC2InstanceID  = "ec2_instance_id"
+	// router or switch which exported the flows of an endpoint, over
+	// NetFlow, IPFIX or sFlow
+	FlowExporter = "flow_exporter"
+	// table of the listening sockets of a process or container
+	ListeningPortsTablePrefix = "listening_port_"
+	// probe/unixsocket
+	UnixSocketPath = "unix_socket_path"
 	// probe/process
 	PID     = "pid"
 	Name    = "name" // also used by probe/docker
 	PPID    = "ppid"
 	Cmdline = "cmdline"
 	Threads = "threads"
+	// probe/systemd
+	SystemdUnitName        = "systemd_unit_name"
+	SystemdUnitDescription = "systemd_unit_description"
+	SystemdUnitActiveState = "systemd_unit_active_state"
+	SystemdUnitSubState    = "systemd_unit_sub_state"
+	SystemdUnitRestarts    = "systemd_unit_restarts"
+	SystemdRestartUnit     = "systemd_restart_unit"
 	// probe/docker
 	DockerContainerID            = "docker_container_id"
 	DockerImageID                = "docker_image_id"
@@ -21,6 +50,39 @@ const (
 	DockerIsInHostNetwork        = "docker_is_in_host_network"
 	DockerServiceName            = "service_name"
 	DockerStackNamespace         = "stack_namespace"
+	DockerComposeProject         = "docker_compose_project"
+	DockerComposeService         = "docker_compose_service"
+	DockerSwarmServiceMode       = "docker_swarm_service_mode"
+	DockerSwarmServiceImage      = "docker_swarm_service_image"
+	DockerSwarmServiceReplicas   = "docker_swarm_service_replicas"
+	DockerSwarmServiceRunning    = "docker_swarm_service_running"
+	DockerSwarmServiceUpdate     = "docker_swarm_service_update"
+	DockerSwarmTaskName          = "docker_swarm_task_name"
+	DockerSwarmTaskState         = "docker_swarm_task_state"
+	DockerSwarmTaskDesiredState  = "docker_swarm_task_desired_state"
+	DockerSwarmTaskNode          = "docker_swarm_task_node"
+	DockerSwarmTaskCreated       = "docker_swarm_task_created"
+	DockerSwarmScaleUp           = "docker_swarm_scale_up"
+	DockerSwarmScaleDown         = "docker_swarm_scale_down"
+	DockerSwarmRollingRestart    = "docker_swarm_rolling_restart"
+	DockerNetworkName            = "docker_network_name"
+	DockerNetworkDriver          = "docker_network_driver"
+	DockerNetworkScope           = "docker_network_scope"
+	DockerNetworkInternal        = "docker_network_internal"
+	DockerNetworkSubnets         = "docker_network_subnets"
+	DockerVolumeName             = "docker_volume_name"
+	DockerVolumeType             = "docker_volume_type"
+	DockerVolumeDriver           = "docker_volume_driver"
+	DockerVolumeSource           = "docker_volume_source"
+	DockerVolumeUsage            = "docker_volume_usage"
+	DockerImageCVEsCritical      = "docker_image_cves_critical"
+	DockerImageCVEsHigh          = "docker_image_cves_high"
+	DockerImageCVEsMedium        = "docker_image_cves_medium"
+	DockerImageCVEsLow           = "docker_image_cves_low"
+	DockerImageDigest            = "docker_image_digest"
+	DockerImageCreated           = "docker_image_created"
+	DockerImageTagPushed         = "docker_image_tag_pushed"
+	DockerImageOutdated          = "docker_image_outdated"
 	DockerStopContainer          = "docker_stop_container"
 	DockerStartContainer         = "docker_start_container"
 	DockerRestartContainer       = "docker_restart_container"
@@ -69,6 +131,30 @@ const (
 	KubernetesSuspended            = "kubernetes_suspended"
 	KubernetesLastScheduled        = "kubernetes_last_scheduled"
 	KubernetesActiveJobs           = "kubernetes_active_jobs"
+	KubernetesNetworkPolicies      = "kubernetes_network_policies"
+	KubernetesAPIVersion           = "kubernetes_api_version"
+	KubernetesExternalIPs          = "kubernetes_external_ips"
+	KubernetesCompletions          = "kubernetes_completions"
+	KubernetesParallelism          = "kubernetes_parallelism"
+	KubernetesSucceeded            = "kubernetes_succeeded"
+	KubernetesFailed               = "kubernetes_failed"
+	KubernetesActive               = "kubernetes_active"
+	KubernetesStartTime            = "kubernetes_start_time"
+	KubernetesCompletionTime       = "kubernetes_completion_time"
+	KubernetesIngressHosts         = "kubernetes_ingress_hosts"
+	KubernetesCPURequest           = "kubernetes_cpu_request"
+	KubernetesCPULimit             = "kubernetes_cpu_limit"
+	KubernetesMemoryRequest        = "kubernetes_memory_request"
+	KubernetesMemoryLimit          = "kubernetes_memory_limit"
+	KubernetesAutoscaler           = "kubernetes_autoscaler"
+	KubernetesMinReplicas          = "kubernetes_min_replicas"
+	KubernetesMaxReplicas          = "kubernetes_max_replicas"
+	KubernetesAutoscalerMinUp      = "kubernetes_autoscaler_min_up"
+	KubernetesAutoscalerMinDown    = "kubernetes_autoscaler_min_down"
+	KubernetesAutoscalerMaxUp      = "kubernetes_autoscaler_max_up"
+	KubernetesAutoscalerMaxDown    = "kubernetes_autoscaler_max_down"
+	KubernetesCluster              = "kubernetes_cluster"
+	KubernetesSidecar              = "kubernetes_sidecar"
 	KubernetesStateDeleted         = "deleted"
 	// probe/awsecs
 	ECSCluster             = "ecs_cluster"
@@ -78,6 +164,19 @@ const (
 	ECSServiceRunningCount = "ecs_service_running_count"
 	ECSScaleUp             = "ecs_scale_up"
 	ECSScaleDown           = "ecs_scale_down"
+	// probe/nomad
+	NomadNamespace           = "nomad_namespace"
+	NomadJobName             = "nomad_job_name"
+	NomadJobType             = "nomad_job_type"
+	NomadJobStatus           = "nomad_job_status"
+	NomadTaskGroupCount      = "nomad_task_group_count"
+	NomadAllocationName      = "nomad_allocation_name"
+	NomadAllocationStatus    = "nomad_allocation_status"
+	NomadAllocationCreatedAt = "nomad_allocation_created_at"
+	NomadStopJob             = "nomad_stop_job"
+	NomadScaleUp             = "nomad_scale_up"
+	NomadScaleDown           = "nomad_scale_down"
+	NomadRestartAllocation   = "nomad_restart_allocation"
 )
 
 /* Lookup table to allow msgpack/json decoder to avoid heap allocation
@@ -96,12 +195,25 @@ var commonKeys = map[string]string{
 	DaemonSet:      DaemonSet,
 	StatefulSet:    StatefulSet,
 	CronJob:        CronJob,
+	Job:            Job,
+	CustomResource: CustomResource,
+	Ingress:        Ingress,
 	ContainerImage: ContainerImage,
 	Host:           Host,
 	Overlay:        Overlay,
 	ECSService:     ECSService,
 	ECSTask:        ECSTask,
 	SwarmService:   SwarmService,
+	SwarmTask:      SwarmTask,
+	ComposeService: ComposeService,
+	DockerNetwork:  DockerNetwork,
+	DockerVolume:   DockerVolume,
+	SystemdUnit:    SystemdUnit,
+	UnixSocket:     UnixSocket,
+
+	NomadJob:        NomadJob,
+	NomadTaskGroup:  NomadTaskGroup,
+	NomadAllocation: NomadAllocation,
 
 	HostNodeID:             HostNodeID,
 	ControlProbeID:         ControlProbeID,
@@ -110,6 +222,20 @@ var commonKeys = map[string]string{
 	ReverseDNSNames: ReverseDNSNames,
 	SnoopedDNSNames: SnoopedDNSNames,
 	CopyOf:          CopyOf,
+	EgressBytes:     EgressBytes,
+	IngressBytes:    IngressBytes,
+	TCPRetransmits:  TCPRetransmits,
+	TCPResets:       TCPResets,
+	RTT:             RTT,
+	SNINames:        SNINames,
+
+	SampledConnections:     SampledConnections,
+	IPVSVirtualDestination: IPVSVirtualDestination,
+	IPVSRealDestination:    IPVSRealDestination,
+	VPCInterfaceID:         VPCInterfaceID,
+	EC2InstanceID:          EC2InstanceID,
+	FlowExporter:           FlowExporter,
+	UnixSocketPath:         UnixSocketPath,
 
 	PID:     PID,
 	Name:    Name,
@@ -117,6 +243,13 @@ var commonKeys = map[string]string{
 	Cmdline: Cmdline,
 	Threads: Threads,
 
+	SystemdUnitName:        SystemdUnitName,
+	SystemdUnitDescription: SystemdUnitDescription,
+	SystemdUnitActiveState: SystemdUnitActiveState,
+	SystemdUnitSubState:    SystemdUnitSubState,
+	SystemdUnitRestarts:    SystemdUnitRestarts,
+	SystemdRestartUnit:     SystemdRestartUnit,
+
 	DockerContainerID:            DockerContainerID,
 	DockerImageID:                DockerImageID,
 	DockerImageName:              DockerImageName,
@@ -125,6 +258,39 @@ var commonKeys = map[string]string{
 	DockerIsInHostNetwork:        DockerIsInHostNetwork,
 	DockerServiceName:            DockerServiceName,
 	DockerStackNamespace:         DockerStackNamespace,
+	DockerComposeProject:         DockerComposeProject,
+	DockerComposeService:         DockerComposeService,
+	DockerSwarmServiceMode:       DockerSwarmServiceMode,
+	DockerSwarmServiceImage:      DockerSwarmServiceImage,
+	DockerSwarmServiceReplicas:   DockerSwarmServiceReplicas,
+	DockerSwarmServiceRunning:    DockerSwarmServiceRunning,
+	DockerSwarmServiceUpdate:     DockerSwarmServiceUpdate,
+	DockerSwarmTaskName:          DockerSwarmTaskName,
+	DockerSwarmTaskState:         DockerSwarmTaskState,
+	DockerSwarmTaskDesiredState:  DockerSwarmTaskDesiredState,
+	DockerSwarmTaskNode:          DockerSwarmTaskNode,
+	DockerSwarmTaskCreated:       DockerSwarmTaskCreated,
+	DockerSwarmScaleUp:           DockerSwarmScaleUp,
+	DockerSwarmScaleDown:         DockerSwarmScaleDown,
+	DockerSwarmRollingRestart:    DockerSwarmRollingRestart,
+	DockerNetworkName:            DockerNetworkName,
+	DockerNetworkDriver:          DockerNetworkDriver,
+	DockerNetworkScope:           DockerNetworkScope,
+	DockerNetworkInternal:        DockerNetworkInternal,
+	DockerNetworkSubnets:         DockerNetworkSubnets,
+	DockerVolumeName:             DockerVolumeName,
+	DockerVolumeType:             DockerVolumeType,
+	DockerVolumeDriver:           DockerVolumeDriver,
+	DockerVolumeSource:           DockerVolumeSource,
+	DockerVolumeUsage:            DockerVolumeUsage,
+	DockerImageCVEsCritical:      DockerImageCVEsCritical,
+	DockerImageCVEsHigh:          DockerImageCVEsHigh,
+	DockerImageCVEsMedium:        DockerImageCVEsMedium,
+	DockerImageCVEsLow:           DockerImageCVEsLow,
+	DockerImageDigest:            DockerImageDigest,
+	DockerImageCreated:           DockerImageCreated,
+	DockerImageTagPushed:         DockerImageTagPushed,
+	DockerImageOutdated:          DockerImageOutdated,
 	DockerStopContainer:          DockerStopContainer,
 	DockerStartContainer:         DockerStartContainer,
 	DockerRestartContainer:       DockerRestartContainer,
@@ -173,6 +339,30 @@ var commonKeys = map[string]string{
 	KubernetesSuspended:            KubernetesSuspended,
 	KubernetesLastScheduled:        KubernetesLastScheduled,
 	KubernetesActiveJobs:           KubernetesActiveJobs,
+	KubernetesNetworkPolicies:      KubernetesNetworkPolicies,
+	KubernetesAPIVersion:           KubernetesAPIVersion,
+	KubernetesExternalIPs:          KubernetesExternalIPs,
+	KubernetesCompletions:          KubernetesCompletions,
+	KubernetesParallelism:          KubernetesParallelism,
+	KubernetesSucceeded:            KubernetesSucceeded,
+	KubernetesFailed:               KubernetesFailed,
+	KubernetesActive:               KubernetesActive,
+	KubernetesStartTime:            KubernetesStartTime,
+	KubernetesCompletionTime:       KubernetesCompletionTime,
+	KubernetesIngressHosts:         KubernetesIngressHosts,
+	KubernetesCPURequest:           KubernetesCPURequest,
+	KubernetesCPULimit:             KubernetesCPULimit,
+	KubernetesMemoryRequest:        KubernetesMemoryRequest,
+	KubernetesMemoryLimit:          KubernetesMemoryLimit,
+	KubernetesAutoscaler:           KubernetesAutoscaler,
+	KubernetesMinReplicas:          KubernetesMinReplicas,
+	KubernetesMaxReplicas:          KubernetesMaxReplicas,
+	KubernetesAutoscalerMinUp:      KubernetesAutoscalerMinUp,
+	KubernetesAutoscalerMinDown:    KubernetesAutoscalerMinDown,
+	KubernetesAutoscalerMaxUp:      KubernetesAutoscalerMaxUp,
+	KubernetesAutoscalerMaxDown:    KubernetesAutoscalerMaxDown,
+	KubernetesCluster:              KubernetesCluster,
+	KubernetesSidecar:              KubernetesSidecar,
 
 	ECSCluster:             ECSCluster,
 	ECSCreatedAt:           ECSCreatedAt,
@@ -181,6 +371,19 @@ var commonKeys = map[string]string{
 	ECSServiceRunningCount: ECSServiceRunningCount,
 	ECSScaleUp:             ECSScaleUp,
 	ECSScaleDown:           ECSScaleDown,
+
+	NomadNamespace:           NomadNamespace,
+	NomadJobName:             NomadJobName,
+	NomadJobType:             NomadJobType,
+	NomadJobStatus:           NomadJobStatus,
+	NomadTaskGroupCount:      NomadTaskGroupCount,
+	NomadAllocationName:      NomadAllocationName,
+	NomadAllocationStatus:    NomadAllocationStatus,
+	NomadAllocationCreatedAt: NomadAllocationCreatedAt,
+	NomadStopJob:             NomadStopJob,
+	NomadScaleUp:             NomadScaleUp,
+	NomadScaleDown:           NomadScaleDown,
+	NomadRestartAllocation:   NomadRestartAllocation,
 }
 
 func lookupCommonKey(b []byte) string {
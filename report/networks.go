@@ -59,7 +59,7 @@ func LocalAddresses() ([]net.IP, error) {
 			return []net.IP{}, err
 		}
 
-		for _, ipnet := range ipv4Nets(addrs) {
+		for _, ipnet := range ipNets(addrs) {
 			result = append(result, ipnet.IP)
 		}
 	}
@@ -81,7 +81,7 @@ func AddLocalBridge(name string) error {
 		return err
 	}
 
-	for _, ipnet := range ipv4Nets(addrs) {
+	for _, ipnet := range ipNets(addrs) {
 		LocalNetworks.Add(ipnet)
 	}
 
@@ -94,15 +94,20 @@ func GetLocalNetworks() ([]*net.IPNet, error) {
 	if err != nil {
 		return nil, err
 	}
-	return ipv4Nets(addrs), nil
+	return ipNets(addrs), nil
 }
 
-func ipv4Nets(addrs []net.Addr) []*net.IPNet {
+// ipNets returns the IPv4 and IPv6 networks of addrs, so that
+// dual-stack hosts report both. IPv6 link-local networks are left out:
+// every interface has one, and they can't be reached from other links.
+func ipNets(addrs []net.Addr) []*net.IPNet {
 	nets := []*net.IPNet{}
 	for _, addr := range addrs {
-		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
-			nets = append(nets, ipnet)
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || (ipnet.IP.To4() == nil && ipnet.IP.IsLinkLocalUnicast()) {
+			continue
 		}
+		nets = append(nets, ipnet)
 	}
 	return nets
 }
@@ -40,3 +40,22 @@ func containingIPv4Networks(ipstrings []string) *net.IPNet {
 	}
 	return report.ContainingIPv4Network(ips)
 }
+
+func TestContainsIPv6(t *testing.T) {
+	networks := report.MakeNetworks()
+	for _, cidr := range []string{"10.0.0.0/8", "fd00:10::/64"} {
+		if err := networks.AddCIDR(cidr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for addr, want := range map[string]bool{
+		"fd00:10::2":  true,
+		"fd00:11::2":  false,
+		"2001:db8::1": false,
+		"10.0.0.1":    true,
+	} {
+		if have := networks.Contains(net.ParseIP(addr)); want != have {
+			t.Errorf("%s: want %v, have %v", addr, want, have)
+		}
+	}
+}
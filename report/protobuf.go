@@ -0,0 +1,511 @@
+package report
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+// ProtobufContentType is the content type of reports in the protobuf wire
+// format described by report.proto.
+const ProtobufContentType = "application/x-protobuf"
+
+// MarshalProtobuf encodes a Report as (uncompressed) protobuf.
+func (rep Report) MarshalProtobuf() ([]byte, error) {
+	return proto.Marshal(rep.toWire())
+}
+
+// WriteProtobuf writes a Report as a gzipped protobuf.
+func (rep Report) WriteProtobuf(w io.Writer, compressionLevel int) error {
+	buf, err := rep.MarshalProtobuf()
+	if err != nil {
+		return err
+	}
+	gzwriter, err := gzip.NewWriterLevel(w, compressionLevel)
+	if err != nil {
+		return err
+	}
+	if _, err := gzwriter.Write(buf); err != nil {
+		return err
+	}
+	return gzwriter.Close()
+}
+
+// ReadProtobuf reads a protobuf encoded Report, decompressing it first if
+// gzipped is true.
+func (rep *Report) ReadProtobuf(r io.Reader, gzipped bool) error {
+	if gzipped {
+		gzreader, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		r = gzreader
+	}
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var wire wireReport
+	if err := proto.Unmarshal(buf, &wire); err != nil {
+		return err
+	}
+	*rep = wire.fromWire()
+	return nil
+}
+
+// The wire* types mirror the messages of report.proto, and are encoded
+// by the proto package based on their struct tags.
+
+type wireReport struct {
+	Topologies    map[string]*wireTopology `protobuf:"bytes,1,rep,name=topologies" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	SamplingCount uint64                   `protobuf:"varint,2,opt,name=sampling_count,proto3"`
+	SamplingTotal uint64                   `protobuf:"varint,3,opt,name=sampling_total,proto3"`
+	Window        int64                    `protobuf:"varint,4,opt,name=window,proto3"`
+	Shortcut      bool                     `protobuf:"varint,5,opt,name=shortcut,proto3"`
+	Plugins       []*wirePluginSpec        `protobuf:"bytes,6,rep,name=plugins"`
+	ID            string                   `protobuf:"bytes,7,opt,name=id,proto3"`
+}
+
+type wireTopology struct {
+	Shape             string                           `protobuf:"bytes,1,opt,name=shape,proto3"`
+	Label             string                           `protobuf:"bytes,2,opt,name=label,proto3"`
+	LabelPlural       string                           `protobuf:"bytes,3,opt,name=label_plural,proto3"`
+	Nodes             []*wireNode                      `protobuf:"bytes,4,rep,name=nodes"`
+	Controls          map[string]*wireControl          `protobuf:"bytes,5,rep,name=controls" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	MetadataTemplates map[string]*wireMetadataTemplate `protobuf:"bytes,6,rep,name=metadata_templates" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	MetricTemplates   map[string]*wireMetricTemplate   `protobuf:"bytes,7,rep,name=metric_templates" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	TableTemplates    map[string]*wireTableTemplate    `protobuf:"bytes,8,rep,name=table_templates" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+type wireNode struct {
+	ID                string                  `protobuf:"bytes,1,opt,name=id,proto3"`
+	Topology          string                  `protobuf:"bytes,2,opt,name=topology,proto3"`
+	Counters          map[string]int64        `protobuf:"bytes,3,rep,name=counters" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Sets              map[string]*wireStrings `protobuf:"bytes,4,rep,name=sets" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Adjacency         []string                `protobuf:"bytes,5,rep,name=adjacency"`
+	ControlsTimestamp int64                   `protobuf:"varint,6,opt,name=controls_timestamp,proto3"`
+	Controls          []string                `protobuf:"bytes,7,rep,name=controls"`
+	LatestControls    []*wireLatestControl    `protobuf:"bytes,8,rep,name=latest_controls"`
+	Latest            []*wireLatest           `protobuf:"bytes,9,rep,name=latest"`
+	Metrics           map[string]*wireMetric  `protobuf:"bytes,10,rep,name=metrics" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Parents           map[string]*wireStrings `protobuf:"bytes,11,rep,name=parents" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Children          []*wireNode             `protobuf:"bytes,12,rep,name=children"`
+}
+
+type wireStrings struct {
+	Values []string `protobuf:"bytes,1,rep,name=values"`
+}
+
+type wireLatest struct {
+	Key       string `protobuf:"bytes,1,opt,name=key,proto3"`
+	Timestamp int64  `protobuf:"varint,2,opt,name=timestamp,proto3"`
+	Value     string `protobuf:"bytes,3,opt,name=value,proto3"`
+}
+
+type wireLatestControl struct {
+	Key       string `protobuf:"bytes,1,opt,name=key,proto3"`
+	Timestamp int64  `protobuf:"varint,2,opt,name=timestamp,proto3"`
+	Dead      bool   `protobuf:"varint,3,opt,name=dead,proto3"`
+}
+
+type wireMetric struct {
+	Timestamps []int64   `protobuf:"varint,1,rep,packed,name=timestamps"`
+	Values     []float64 `protobuf:"fixed64,2,rep,packed,name=values"`
+	Min        float64   `protobuf:"fixed64,3,opt,name=min,proto3"`
+	Max        float64   `protobuf:"fixed64,4,opt,name=max,proto3"`
+	First      int64     `protobuf:"varint,5,opt,name=first,proto3"`
+	Last       int64     `protobuf:"varint,6,opt,name=last,proto3"`
+}
+
+type wireControl struct {
+	ID      string              `protobuf:"bytes,1,opt,name=id,proto3"`
+	Human   string              `protobuf:"bytes,2,opt,name=human,proto3"`
+	Icon    string              `protobuf:"bytes,3,opt,name=icon,proto3"`
+	Rank    int64               `protobuf:"varint,4,opt,name=rank,proto3"`
+	Params  []*wireControlParam `protobuf:"bytes,5,rep,name=params"`
+	Confirm string              `protobuf:"bytes,6,opt,name=confirm,proto3"`
+	DryRun  string              `protobuf:"bytes,7,opt,name=dry_run,json=dryRun,proto3"`
+}
+
+type wireControlParam struct {
+	ID      string   `protobuf:"bytes,1,opt,name=id,proto3"`
+	Human   string   `protobuf:"bytes,2,opt,name=human,proto3"`
+	Type    string   `protobuf:"bytes,3,opt,name=type,proto3"`
+	Options []string `protobuf:"bytes,4,rep,name=options"`
+	Default string   `protobuf:"bytes,5,opt,name=default,proto3"`
+}
+
+type wireMetadataTemplate struct {
+	ID       string  `protobuf:"bytes,1,opt,name=id,proto3"`
+	Label    string  `protobuf:"bytes,2,opt,name=label,proto3"`
+	Truncate int64   `protobuf:"varint,3,opt,name=truncate,proto3"`
+	Datatype string  `protobuf:"bytes,4,opt,name=datatype,proto3"`
+	Priority float64 `protobuf:"fixed64,5,opt,name=priority,proto3"`
+	From     string  `protobuf:"bytes,6,opt,name=from,proto3"`
+}
+
+type wireMetricTemplate struct {
+	ID       string  `protobuf:"bytes,1,opt,name=id,proto3"`
+	Label    string  `protobuf:"bytes,2,opt,name=label,proto3"`
+	Format   string  `protobuf:"bytes,3,opt,name=format,proto3"`
+	Group    string  `protobuf:"bytes,4,opt,name=group,proto3"`
+	Priority float64 `protobuf:"fixed64,5,opt,name=priority,proto3"`
+}
+
+type wireTableTemplate struct {
+	ID        string            `protobuf:"bytes,1,opt,name=id,proto3"`
+	Label     string            `protobuf:"bytes,2,opt,name=label,proto3"`
+	Prefix    string            `protobuf:"bytes,3,opt,name=prefix,proto3"`
+	Type      string            `protobuf:"bytes,4,opt,name=type,proto3"`
+	Columns   []*wireColumn     `protobuf:"bytes,5,rep,name=columns"`
+	FixedRows map[string]string `protobuf:"bytes,6,rep,name=fixed_rows" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+type wireColumn struct {
+	ID       string `protobuf:"bytes,1,opt,name=id,proto3"`
+	Label    string `protobuf:"bytes,2,opt,name=label,proto3"`
+	DataType string `protobuf:"bytes,3,opt,name=data_type,proto3"`
+}
+
+type wirePluginSpec struct {
+	ID          string   `protobuf:"bytes,1,opt,name=id,proto3"`
+	Label       string   `protobuf:"bytes,2,opt,name=label,proto3"`
+	Description string   `protobuf:"bytes,3,opt,name=description,proto3"`
+	Interfaces  []string `protobuf:"bytes,4,rep,name=interfaces"`
+	APIVersion  string   `protobuf:"bytes,5,opt,name=api_version,proto3"`
+	Status      string   `protobuf:"bytes,6,opt,name=status,proto3"`
+}
+
+func (m *wireReport) Reset()                   { *m = wireReport{} }
+func (m *wireReport) String() string           { return proto.CompactTextString(m) }
+func (*wireReport) ProtoMessage()              {}
+func (m *wireTopology) Reset()                 { *m = wireTopology{} }
+func (m *wireTopology) String() string         { return proto.CompactTextString(m) }
+func (*wireTopology) ProtoMessage()            {}
+func (m *wireNode) Reset()                     { *m = wireNode{} }
+func (m *wireNode) String() string             { return proto.CompactTextString(m) }
+func (*wireNode) ProtoMessage()                {}
+func (m *wireStrings) Reset()                  { *m = wireStrings{} }
+func (m *wireStrings) String() string          { return proto.CompactTextString(m) }
+func (*wireStrings) ProtoMessage()             {}
+func (m *wireLatest) Reset()                   { *m = wireLatest{} }
+func (m *wireLatest) String() string           { return proto.CompactTextString(m) }
+func (*wireLatest) ProtoMessage()              {}
+func (m *wireLatestControl) Reset()            { *m = wireLatestControl{} }
+func (m *wireLatestControl) String() string    { return proto.CompactTextString(m) }
+func (*wireLatestControl) ProtoMessage()       {}
+func (m *wireMetric) Reset()                   { *m = wireMetric{} }
+func (m *wireMetric) String() string           { return proto.CompactTextString(m) }
+func (*wireMetric) ProtoMessage()              {}
+func (m *wireControl) Reset()                  { *m = wireControl{} }
+func (m *wireControl) String() string          { return proto.CompactTextString(m) }
+func (*wireControl) ProtoMessage()             {}
+func (m *wireControlParam) Reset()             { *m = wireControlParam{} }
+func (m *wireControlParam) String() string     { return proto.CompactTextString(m) }
+func (*wireControlParam) ProtoMessage()        {}
+func (m *wireMetadataTemplate) Reset()         { *m = wireMetadataTemplate{} }
+func (m *wireMetadataTemplate) String() string { return proto.CompactTextString(m) }
+func (*wireMetadataTemplate) ProtoMessage()    {}
+func (m *wireMetricTemplate) Reset()           { *m = wireMetricTemplate{} }
+func (m *wireMetricTemplate) String() string   { return proto.CompactTextString(m) }
+func (*wireMetricTemplate) ProtoMessage()      {}
+func (m *wireTableTemplate) Reset()            { *m = wireTableTemplate{} }
+func (m *wireTableTemplate) String() string    { return proto.CompactTextString(m) }
+func (*wireTableTemplate) ProtoMessage()       {}
+func (m *wireColumn) Reset()                   { *m = wireColumn{} }
+func (m *wireColumn) String() string           { return proto.CompactTextString(m) }
+func (*wireColumn) ProtoMessage()              {}
+func (m *wirePluginSpec) Reset()               { *m = wirePluginSpec{} }
+func (m *wirePluginSpec) String() string       { return proto.CompactTextString(m) }
+func (*wirePluginSpec) ProtoMessage()          {}
+
+func wireTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromWireTime(t int64) time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, t)
+}
+
+func (rep Report) toWire() *wireReport {
+	wire := &wireReport{
+		Topologies:    map[string]*wireTopology{},
+		SamplingCount: rep.Sampling.Count,
+		SamplingTotal: rep.Sampling.Total,
+		Window:        int64(rep.Window),
+		Shortcut:      rep.Shortcut,
+		ID:            rep.ID,
+	}
+	rep.WalkNamedTopologies(func(name string, t *Topology) {
+		wire.Topologies[name] = t.toWire()
+	})
+	rep.Plugins.ForEach(func(p xfer.PluginSpec) {
+		wire.Plugins = append(wire.Plugins, &wirePluginSpec{
+			ID:          p.ID,
+			Label:       p.Label,
+			Description: p.Description,
+			Interfaces:  p.Interfaces,
+			APIVersion:  p.APIVersion,
+			Status:      p.Status,
+		})
+	})
+	return wire
+}
+
+func (wire *wireReport) fromWire() Report {
+	rep := MakeReport()
+	rep.Sampling = Sampling{Count: wire.SamplingCount, Total: wire.SamplingTotal}
+	rep.Window = time.Duration(wire.Window)
+	rep.Shortcut = wire.Shortcut
+	rep.ID = wire.ID
+	rep.WalkNamedTopologies(func(name string, t *Topology) {
+		if wt, ok := wire.Topologies[name]; ok {
+			*t = wt.fromWire()
+		}
+	})
+	for _, p := range wire.Plugins {
+		rep.Plugins = rep.Plugins.Add(xfer.PluginSpec{
+			ID:          p.ID,
+			Label:       p.Label,
+			Description: p.Description,
+			Interfaces:  p.Interfaces,
+			APIVersion:  p.APIVersion,
+			Status:      p.Status,
+		})
+	}
+	return rep
+}
+
+func (t Topology) toWire() *wireTopology {
+	wire := &wireTopology{
+		Shape:             t.Shape,
+		Label:             t.Label,
+		LabelPlural:       t.LabelPlural,
+		Nodes:             make([]*wireNode, 0, len(t.Nodes)),
+		Controls:          map[string]*wireControl{},
+		MetadataTemplates: map[string]*wireMetadataTemplate{},
+		MetricTemplates:   map[string]*wireMetricTemplate{},
+		TableTemplates:    map[string]*wireTableTemplate{},
+	}
+	for _, n := range t.Nodes {
+		wire.Nodes = append(wire.Nodes, n.toWire())
+	}
+	for k, c := range t.Controls {
+		wc := &wireControl{ID: c.ID, Human: c.Human, Icon: c.Icon, Rank: int64(c.Rank), Confirm: c.Confirm, DryRun: c.DryRun}
+		for _, p := range c.Params {
+			wc.Params = append(wc.Params, &wireControlParam{ID: p.ID, Human: p.Human, Type: p.Type, Options: p.Options, Default: p.Default})
+		}
+		wire.Controls[k] = wc
+	}
+	for k, m := range t.MetadataTemplates {
+		wire.MetadataTemplates[k] = &wireMetadataTemplate{
+			ID:       m.ID,
+			Label:    m.Label,
+			Truncate: int64(m.Truncate),
+			Datatype: m.Datatype,
+			Priority: m.Priority,
+			From:     m.From,
+		}
+	}
+	for k, m := range t.MetricTemplates {
+		wire.MetricTemplates[k] = &wireMetricTemplate{
+			ID:       m.ID,
+			Label:    m.Label,
+			Format:   m.Format,
+			Group:    m.Group,
+			Priority: m.Priority,
+		}
+	}
+	for k, tt := range t.TableTemplates {
+		wt := &wireTableTemplate{
+			ID:        tt.ID,
+			Label:     tt.Label,
+			Prefix:    tt.Prefix,
+			Type:      tt.Type,
+			FixedRows: tt.FixedRows,
+		}
+		for _, c := range tt.Columns {
+			wt.Columns = append(wt.Columns, &wireColumn{ID: c.ID, Label: c.Label, DataType: c.DataType})
+		}
+		wire.TableTemplates[k] = wt
+	}
+	return wire
+}
+
+func (wire *wireTopology) fromWire() Topology {
+	t := MakeTopology()
+	t.Shape = wire.Shape
+	t.Label = wire.Label
+	t.LabelPlural = wire.LabelPlural
+	for _, n := range wire.Nodes {
+		t.Nodes[n.ID] = n.fromWire()
+	}
+	for k, c := range wire.Controls {
+		control := Control{ID: c.ID, Human: c.Human, Icon: c.Icon, Rank: int(c.Rank), Confirm: c.Confirm, DryRun: c.DryRun}
+		for _, p := range c.Params {
+			control.Params = append(control.Params, ControlParam{ID: p.ID, Human: p.Human, Type: p.Type, Options: p.Options, Default: p.Default})
+		}
+		t.Controls[k] = control
+	}
+	if len(wire.MetadataTemplates) > 0 {
+		t.MetadataTemplates = MetadataTemplates{}
+	}
+	for k, m := range wire.MetadataTemplates {
+		t.MetadataTemplates[k] = MetadataTemplate{
+			ID:       m.ID,
+			Label:    m.Label,
+			Truncate: int(m.Truncate),
+			Datatype: m.Datatype,
+			Priority: m.Priority,
+			From:     m.From,
+		}
+	}
+	if len(wire.MetricTemplates) > 0 {
+		t.MetricTemplates = MetricTemplates{}
+	}
+	for k, m := range wire.MetricTemplates {
+		t.MetricTemplates[k] = MetricTemplate{
+			ID:       m.ID,
+			Label:    m.Label,
+			Format:   m.Format,
+			Group:    m.Group,
+			Priority: m.Priority,
+		}
+	}
+	if len(wire.TableTemplates) > 0 {
+		t.TableTemplates = TableTemplates{}
+	}
+	for k, wt := range wire.TableTemplates {
+		tt := TableTemplate{
+			ID:        wt.ID,
+			Label:     wt.Label,
+			Prefix:    wt.Prefix,
+			Type:      wt.Type,
+			FixedRows: map[string]string{},
+		}
+		for key, value := range wt.FixedRows {
+			tt.FixedRows[key] = value
+		}
+		for _, c := range wt.Columns {
+			tt.Columns = append(tt.Columns, Column{ID: c.ID, Label: c.Label, DataType: c.DataType})
+		}
+		t.TableTemplates[k] = tt
+	}
+	return t
+}
+
+func setsToWire(s Sets) map[string]*wireStrings {
+	result := map[string]*wireStrings{}
+	if s.psMap != nil {
+		s.psMap.ForEach(func(key string, value interface{}) {
+			result[key] = &wireStrings{Values: value.(StringSet)}
+		})
+	}
+	return result
+}
+
+func setsFromWire(wire map[string]*wireStrings) Sets {
+	s := MakeSets()
+	for key, value := range wire {
+		s = s.Add(key, StringSet(value.Values))
+	}
+	return s
+}
+
+func (n Node) toWire() *wireNode {
+	wire := &wireNode{
+		ID:                n.ID,
+		Topology:          n.Topology,
+		Counters:          map[string]int64{},
+		Sets:              setsToWire(n.Sets),
+		Adjacency:         n.Adjacency,
+		ControlsTimestamp: wireTime(n.Controls.Timestamp),
+		Controls:          n.Controls.Controls,
+		LatestControls:    make([]*wireLatestControl, 0, len(n.LatestControls)),
+		Latest:            make([]*wireLatest, 0, len(n.Latest)),
+		Metrics:           map[string]*wireMetric{},
+		Parents:           setsToWire(n.Parents),
+	}
+	if n.Counters.psMap != nil {
+		n.Counters.psMap.ForEach(func(key string, value interface{}) {
+			wire.Counters[key] = int64(value.(int))
+		})
+	}
+	for _, e := range n.LatestControls {
+		wire.LatestControls = append(wire.LatestControls, &wireLatestControl{Key: e.key, Timestamp: wireTime(e.Timestamp), Dead: e.Value.Dead})
+	}
+	for _, e := range n.Latest {
+		wire.Latest = append(wire.Latest, &wireLatest{Key: e.key, Timestamp: wireTime(e.Timestamp), Value: e.Value})
+	}
+	for key, m := range n.Metrics {
+		wm := &wireMetric{
+			Timestamps: make([]int64, len(m.Samples)),
+			Values:     make([]float64, len(m.Samples)),
+			Min:        m.Min,
+			Max:        m.Max,
+			First:      wireTime(m.First),
+			Last:       wireTime(m.Last),
+		}
+		for i, s := range m.Samples {
+			wm.Timestamps[i], wm.Values[i] = wireTime(s.Timestamp), s.Value
+		}
+		wire.Metrics[key] = wm
+	}
+	n.Children.ForEach(func(child Node) {
+		wire.Children = append(wire.Children, child.toWire())
+	})
+	return wire
+}
+
+// fromWire relies on the latest entries being sorted by key, as they are
+// when encoded.
+func (wire *wireNode) fromWire() Node {
+	n := MakeNode(wire.ID)
+	n.Topology = wire.Topology
+	for key, value := range wire.Counters {
+		n.Counters = n.Counters.Add(key, int(value))
+	}
+	n.Sets = setsFromWire(wire.Sets)
+	n.Adjacency = MakeIDList(wire.Adjacency...)
+	n.Controls = NodeControls{
+		Timestamp: fromWireTime(wire.ControlsTimestamp),
+		Controls:  MakeStringSet(wire.Controls...),
+	}
+	for _, e := range wire.LatestControls {
+		n.LatestControls = append(n.LatestControls, nodeControlDataLatestEntry{key: e.Key, Timestamp: fromWireTime(e.Timestamp), Value: NodeControlData{Dead: e.Dead}})
+	}
+	for _, e := range wire.Latest {
+		n.Latest = append(n.Latest, stringLatestEntry{key: e.Key, Timestamp: fromWireTime(e.Timestamp), Value: e.Value})
+	}
+	for key, wm := range wire.Metrics {
+		m := Metric{
+			Min:   wm.Min,
+			Max:   wm.Max,
+			First: fromWireTime(wm.First),
+			Last:  fromWireTime(wm.Last),
+		}
+		if len(wm.Timestamps) > 0 && len(wm.Timestamps) == len(wm.Values) {
+			m.Samples = make([]Sample, len(wm.Timestamps))
+			for i := range wm.Timestamps {
+				m.Samples[i] = Sample{Timestamp: fromWireTime(wm.Timestamps[i]), Value: wm.Values[i]}
+			}
+		}
+		n.Metrics[key] = m
+	}
+	n.Parents = setsFromWire(wire.Parents)
+	for _, child := range wire.Children {
+		n.Children = n.Children.Add(child.fromWire())
+	}
+	return n
+}
@@ -0,0 +1,62 @@
+package report_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestProtobufRoundtrip(t *testing.T) {
+	now := time.Unix(0, time.Now().UnixNano())
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	r1 := report.MakeReport()
+	r1.Window = 15 * time.Second
+	r1.Shortcut = true
+	r1.Sampling = report.Sampling{Count: 1, Total: 2}
+	r1.Plugins = r1.Plugins.Add(xfer.PluginSpec{ID: "plugin", Label: "Plugin", Interfaces: []string{"reporter"}})
+	r1.Container = r1.Container.
+		WithMetadataTemplates(report.MetadataTemplates{
+			"foo": {ID: "foo", Label: "Foo", Truncate: 12, From: report.FromLatest, Priority: 1},
+		}).
+		WithMetricTemplates(report.MetricTemplates{
+			"cpu": {ID: "cpu", Label: "CPU", Format: report.PercentFormat, Priority: 2},
+		}).
+		WithTableTemplates(report.TableTemplates{
+			"table_": {ID: "table_", Label: "Table", Prefix: "table_", Type: report.MulticolumnTableType,
+				Columns: []report.Column{{ID: "a", Label: "A"}}},
+		})
+	r1.Container.Controls.AddControl(report.Control{ID: "stop", Human: "Stop", Icon: "fa-stop", Rank: 3, Confirm: "Stop?", DryRun: "stop_dry_run"})
+	r1.Container.Controls.AddControl(report.Control{ID: "kill", Human: "Kill", Icon: "fa-bolt", Rank: 4, Params: []report.ControlParam{
+		{ID: "signal", Human: "Signal", Type: report.ControlParamEnum, Options: []string{"HUP", "KILL"}, Default: "KILL"},
+	}})
+	r1.Container.AddNode(report.MakeNodeWith("a", map[string]string{"foo": "bar", "baz": "qux"}).
+		WithTopology(report.Container).
+		WithCounters(map[string]int{"count": 3}).
+		WithSets(report.MakeSets().Add("set", report.MakeStringSet("x", "y"))).
+		WithAdjacent("b").
+		WithParents(report.MakeSets().Add(report.Host, report.MakeStringSet("host"))).
+		WithMetrics(report.Metrics{"cpu": report.MakeMetric([]report.Sample{{Timestamp: now, Value: 0.5}})}).
+		WithLatestControls(map[string]report.NodeControlData{"stop": {Dead: true}}).
+		WithChild(report.MakeNode("child").WithTopology(report.Process)))
+
+	var buf bytes.Buffer
+	if err := r1.WriteProtobuf(&buf, gzip.DefaultCompression); err != nil {
+		t.Fatal(err)
+	}
+	var r2 report.Report
+	if err := r2.ReadProtobuf(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r1, r2) {
+		t.Error(test.Diff(r1, r2))
+	}
+}
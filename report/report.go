@@ -22,6 +22,9 @@ const (
 	DaemonSet      = "daemon_set"
 	StatefulSet    = "stateful_set"
 	CronJob        = "cron_job"
+	Job            = "job"
+	CustomResource = "custom_resource"
+	Ingress        = "ingress"
 	Namespace      = "namespace"
 	ContainerImage = "container_image"
 	Host           = "host"
@@ -29,6 +32,16 @@ const (
 	ECSService     = "ecs_service"
 	ECSTask        = "ecs_task"
 	SwarmService   = "swarm_service"
+	SwarmTask      = "swarm_task"
+	ComposeService = "compose_service"
+	DockerNetwork  = "docker_network"
+	DockerVolume   = "docker_volume"
+	SystemdUnit    = "systemd_unit"
+	UnixSocket     = "unix_socket"
+	// HashiCorp Nomad
+	NomadJob        = "nomad_job"
+	NomadTaskGroup  = "nomad_task_group"
+	NomadAllocation = "nomad_allocation"
 
 	// Shapes used for different nodes
 	Circle   = "circle"
@@ -57,12 +70,24 @@ var topologyNames = []string{
 	DaemonSet,
 	StatefulSet,
 	CronJob,
+	Job,
+	CustomResource,
+	Ingress,
 	Namespace,
 	Host,
 	Overlay,
 	ECSTask,
 	ECSService,
 	SwarmService,
+	SwarmTask,
+	ComposeService,
+	DockerNetwork,
+	DockerVolume,
+	SystemdUnit,
+	UnixSocket,
+	NomadJob,
+	NomadTaskGroup,
+	NomadAllocation,
 }
 
 // Report is the core data type. It's produced by probes, and consumed and
@@ -117,6 +142,21 @@ type Report struct {
 	// present.
 	CronJob Topology
 
+	// Job nodes represent the Kubernetes Jobs no Cron Job owns. Metadata
+	// includes things like Job id, name, completions, etc. Edges are not
+	// present.
+	Job Topology
+
+	// CustomResource nodes represent the Kubernetes custom resources of the
+	// kinds the probes are configured to watch. Metadata includes things like
+	// their kind, name, etc. Edges are not present.
+	CustomResource Topology
+
+	// Ingress nodes represent all Kubernetes Ingresses. Metadata includes
+	// things like Ingress id, name, hosts, etc. Edges are present, going to
+	// the services the Ingress routes to.
+	Ingress Topology
+
 	// Namespace nodes represent all Kubernetes Namespaces running on hosts running probes.
 	// Metadata includes things like Namespace id, name, etc. Edges are not
 	// present.
@@ -146,11 +186,56 @@ type Report struct {
 	// Edges are not present.
 	SwarmService Topology
 
+	// Swarm Task nodes are the tasks of Docker Swarm services, each of which
+	// runs a container on a node of the swarm, as its manager schedules it.
+	// Edges are not present.
+	SwarmTask Topology
+
+	// Compose Service nodes are the services of Docker Compose projects,
+	// which group the containers run for them on non-orchestrated hosts.
+	// Edges are not present.
+	ComposeService Topology
+
+	// Docker Network nodes are the networks of Docker hosts, such as
+	// bridges, overlays and macvlans, which containers are attached to.
+	// Edges are not present.
+	DockerNetwork Topology
+
+	// Docker Volume nodes are the volumes and bind mounts of the containers
+	// of Docker hosts, identified by their host and source.
+	// Edges are not present.
+	DockerVolume Topology
+
+	// Systemd Unit nodes are the services systemd manages on hosts,
+	// identified by their host and unit name.
+	// Edges are not present.
+	SystemdUnit Topology
+
 	// Overlay nodes are active peers in any software-defined network that's
 	// overlaid on the infrastructure. The information is scraped by polling
 	// their status endpoints. Edges are present.
 	Overlay Topology
 
+	// Nomad Job nodes are the jobs of HashiCorp Nomad clusters, made of
+	// task groups, identified by their namespace and job ID.
+	// Edges are not present.
+	NomadJob Topology
+
+	// Nomad Task Group nodes are the task groups of Nomad jobs, which are
+	// run a desired count of times, as allocations.
+	// Edges are not present.
+	NomadTaskGroup Topology
+
+	// Nomad Allocation nodes are the allocations of Nomad task groups to the
+	// hosts running probes, which run their tasks, as containers or processes.
+	// Edges are not present.
+	NomadAllocation Topology
+
+	// UnixSocket nodes are the connected ends of unix domain sockets on
+	// each host, and can be traced back to a process. Edges are present,
+	// going from the client end to the server end.
+	UnixSocket Topology
+
 	// Sampling data for this report.
 	Sampling Sampling
 
@@ -224,6 +309,18 @@ func MakeReport() Report {
 			WithShape(Triangle).
 			WithLabel("cron job", "cron jobs"),
 
+		Job: MakeTopology().
+			WithShape(Triangle).
+			WithLabel("job", "jobs"),
+
+		CustomResource: MakeTopology().
+			WithShape(Hexagon).
+			WithLabel("custom resource", "custom resources"),
+
+		Ingress: MakeTopology().
+			WithShape(Triangle).
+			WithLabel("ingress", "ingresses"),
+
 		Namespace: MakeTopology(),
 
 		Overlay: MakeTopology().
@@ -242,6 +339,40 @@ func MakeReport() Report {
 			WithShape(Heptagon).
 			WithLabel("service", "services"),
 
+		SwarmTask: MakeTopology().
+			WithShape(Hexagon).
+			WithLabel("task", "tasks"),
+
+		ComposeService: MakeTopology().
+			WithShape(Heptagon).
+			WithLabel("service", "services"),
+
+		DockerNetwork: MakeTopology().
+			WithShape(Cloud).
+			WithLabel("network", "networks"),
+
+		DockerVolume: MakeTopology().
+			WithShape(Pentagon).
+			WithLabel("volume", "volumes"),
+
+		SystemdUnit: MakeTopology().
+			WithShape(Octagon).
+			WithLabel("unit", "units"),
+
+		UnixSocket: MakeTopology(),
+
+		NomadJob: MakeTopology().
+			WithShape(Octagon).
+			WithLabel("job", "jobs"),
+
+		NomadTaskGroup: MakeTopology().
+			WithShape(Heptagon).
+			WithLabel("task group", "task groups"),
+
+		NomadAllocation: MakeTopology().
+			WithShape(Hexagon).
+			WithLabel("allocation", "allocations"),
+
 		Sampling: Sampling{},
 		Window:   0,
 		Plugins:  xfer.MakePluginSpecs(),
@@ -326,6 +457,12 @@ func (r *Report) topology(name string) *Topology {
 		return &r.StatefulSet
 	case CronJob:
 		return &r.CronJob
+	case Job:
+		return &r.Job
+	case CustomResource:
+		return &r.CustomResource
+	case Ingress:
+		return &r.Ingress
 	case Namespace:
 		return &r.Namespace
 	case Host:
@@ -338,6 +475,24 @@ func (r *Report) topology(name string) *Topology {
 		return &r.ECSService
 	case SwarmService:
 		return &r.SwarmService
+	case SwarmTask:
+		return &r.SwarmTask
+	case ComposeService:
+		return &r.ComposeService
+	case DockerNetwork:
+		return &r.DockerNetwork
+	case DockerVolume:
+		return &r.DockerVolume
+	case SystemdUnit:
+		return &r.SystemdUnit
+	case UnixSocket:
+		return &r.UnixSocket
+	case NomadJob:
+		return &r.NomadJob
+	case NomadTaskGroup:
+		return &r.NomadTaskGroup
+	case NomadAllocation:
+		return &r.NomadAllocation
 	}
 	return nil
 }
@@ -445,7 +600,7 @@ func (r Report) upgradeNamespaces() Report {
 	}
 
 	namespaces := map[string]struct{}{}
-	for _, t := range []Topology{r.Pod, r.Service, r.Deployment, r.DaemonSet, r.StatefulSet, r.CronJob} {
+	for _, t := range []Topology{r.Pod, r.Service, r.Deployment, r.DaemonSet, r.StatefulSet, r.CronJob, r.Job, r.CustomResource, r.Ingress} {
 		for _, n := range t.Nodes {
 			if state, ok := n.Latest.Lookup(KubernetesState); ok && state == KubernetesStateDeleted {
 				continue